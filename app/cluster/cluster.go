@@ -0,0 +1,491 @@
+// Package cluster shares the states of users with the other servers of a
+// cluster, over Redis, for their restrictions to hold across all of them:
+// the IPs users are online from, the traffic they have used, and the sources
+// banned by the guard.
+package cluster
+
+import (
+	"context"
+	"maps"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/app/stats"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/events"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/redis"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/extension"
+	feature_stats "github.com/xtls/xray-core/features/stats"
+)
+
+const (
+	defaultInterval = 10 * time.Second
+	redisTimeout    = 10 * time.Second
+	// expiry is how many intervals a server is in the cluster for since its
+	// last sync.
+	expiry = 3
+)
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		c, err := New(ctx, config.(*Config))
+		if err != nil {
+			return nil, err
+		}
+		if err := core.RequireFeatures(ctx, func(sm feature_stats.Manager) error {
+			c.stats, _ = sm.(*stats.Manager)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		core.OptionalFeatures(ctx, func(g extension.Guard) {
+			c.guard = g
+		})
+		return c, nil
+	}))
+}
+
+// Cluster implements extension.Cluster. In Redis, the servers are in the
+// sorted set "[prefix]:nodes" by the time of their last sync, the IPs of the
+// users online at each one in the hash "[prefix]:online:[node]", the traffic
+// of the users at all of them in the hash "[prefix]:traffic", both by the
+// names of the users in the stats, and the banned
+// sources in the sorted set "[prefix]:bans" by the time they are banned
+// until, changes of which are published to the channel of the same name.
+type Cluster struct {
+	node     string
+	prefix   string
+	interval time.Duration
+	client   *redis.Client
+	stats    *stats.Manager
+	guard    extension.Guard
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// sent are the states of the user traffic counters as of the last sync,
+	// by name.
+	sent map[string]counterState
+
+	access  sync.RWMutex
+	online  map[string][]string
+	traffic map[string]int64
+	// counted are the states of sent included in traffic.
+	counted map[string]counterState
+}
+
+// counterState is the value of a counter, and how many times it is set, as
+// it is reset.
+type counterState struct {
+	value int64
+	sets  uint64
+}
+
+func stateOf(counter feature_stats.Counter) counterState {
+	s := counterState{value: counter.Value()}
+	if c, ok := counter.(*stats.Counter); ok {
+		s.sets = c.Sets()
+	}
+	return s
+}
+
+// resetSince returns whether the counter of s is reset since it was last.
+func (s counterState) resetSince(last counterState) bool {
+	return s.sets != last.sets || s.value < last.value
+}
+
+// New creates a Cluster from config.
+func New(ctx context.Context, config *Config) (*Cluster, error) {
+	if config.Redis == nil || config.Redis.Address == "" {
+		return nil, errors.New("cluster requires the address of Redis")
+	}
+	c := &Cluster{
+		node:     config.Node,
+		prefix:   config.Prefix,
+		interval: time.Duration(config.Interval),
+		client: &redis.Client{
+			Address:  config.Redis.Address,
+			Password: config.Redis.Password,
+			DB:       config.Redis.Db,
+			Timeout:  redisTimeout,
+		},
+		sent: make(map[string]counterState),
+	}
+	if c.node == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, errors.New("failed to name the node of this server").Base(err)
+		}
+		c.node = hostname
+	}
+	if c.prefix == "" {
+		c.prefix = "xray:cluster"
+	}
+	if c.interval <= 0 {
+		c.interval = defaultInterval
+	}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	return c, nil
+}
+
+// Type implements common.HasType.
+func (*Cluster) Type() interface{} {
+	return extension.ClusterType()
+}
+
+// Start implements common.Runnable.
+func (c *Cluster) Start() error {
+	go c.run()
+	if c.guard != nil {
+		go c.publishBans()
+		go c.subscribeBans()
+	}
+	return nil
+}
+
+// Close implements common.Closable.
+func (c *Cluster) Close() error {
+	c.cancel()
+	return c.client.Close()
+}
+
+// OnlineIPs implements extension.Cluster.
+func (c *Cluster) OnlineIPs(user string) []string {
+	c.access.RLock()
+	defer c.access.RUnlock()
+	return c.online[user]
+}
+
+// Traffic implements extension.Cluster. The traffic of user at this server
+// since the last sync is added, for it not to be over its quota for as long at
+// each server. Once a counter of user is reset here, its traffic is the one of
+// the counters until the next sync resets it at all servers.
+func (c *Cluster) Traffic(user string) int64 {
+	c.access.RLock()
+	defer c.access.RUnlock()
+	n := c.traffic[user]
+	if c.stats == nil {
+		return n
+	}
+	var local, since int64
+	reset := false
+	for _, direction := range []string{"uplink", "downlink"} {
+		name := user + ">>>traffic>>>" + direction
+		if counter := c.stats.GetCounter(name); counter != nil {
+			s, last := stateOf(counter), c.counted[name]
+			reset = reset || s.resetSince(last)
+			local += s.value
+			since += s.value - last.value
+		}
+	}
+	if reset {
+		return local
+	}
+	return n + since
+}
+
+func (c *Cluster) key(name ...string) string {
+	return c.prefix + ":" + strings.Join(name, ":")
+}
+
+func (c *Cluster) run() {
+	for {
+		if err := c.sync(); err != nil {
+			errors.LogWarningInner(c.ctx, err, "cluster: failed to sync")
+		}
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(c.interval):
+		}
+	}
+}
+
+// sync sends the states of this server, and receives the ones of the others.
+func (c *Cluster) sync() error {
+	now := time.Now()
+	since := now.Add(-expiry * c.interval)
+	nodes := c.key("nodes")
+	if _, err := c.client.Do(c.ctx, "ZADD", nodes, strconv.FormatInt(now.Unix(), 10), c.node); err != nil {
+		return err
+	}
+	if _, err := c.client.Do(c.ctx, "ZREMRANGEBYSCORE", nodes, "-inf", "("+strconv.FormatInt(since.Unix(), 10)); err != nil {
+		return err
+	}
+	if c.stats != nil {
+		if err := c.sendOnline(); err != nil {
+			return err
+		}
+		if err := c.sendTraffic(); err != nil {
+			return err
+		}
+	}
+
+	reply, err := c.client.Do(c.ctx, "ZRANGE", nodes, "0", "-1")
+	if err != nil {
+		return err
+	}
+	online := make(map[string][]string)
+	for _, node := range redis.Strings(reply) {
+		if node == c.node {
+			continue
+		}
+		reply, err := c.client.Do(c.ctx, "HGETALL", c.key("online", node))
+		if err != nil {
+			return err
+		}
+		fields := redis.Strings(reply)
+		for i := 0; i+1 < len(fields); i += 2 {
+			online[fields[i]] = append(online[fields[i]], strings.Split(fields[i+1], ",")...)
+		}
+	}
+	reply, err = c.client.Do(c.ctx, "HGETALL", c.key("traffic"))
+	if err != nil {
+		return err
+	}
+	traffic := make(map[string]int64)
+	fields := redis.Strings(reply)
+	for i := 0; i+1 < len(fields); i += 2 {
+		traffic[fields[i]], _ = strconv.ParseInt(fields[i+1], 10, 64)
+	}
+	counted := maps.Clone(c.sent)
+	c.access.Lock()
+	c.online = online
+	c.traffic = traffic
+	c.counted = counted
+	c.access.Unlock()
+
+	if c.guard != nil {
+		return c.receiveBans(now)
+	}
+	return nil
+}
+
+// userStat returns the user of the stat of name, "user>>>[email]" in the
+// namespace of a tenant or not, and the stat under it, for users of the same
+// email in different tenants to stay apart.
+func userStat(name string) (string, string, bool) {
+	rest := name
+	if tenant, ok := strings.CutPrefix(rest, "tenant>>>"); ok {
+		if _, rest, ok = strings.Cut(tenant, ">>>"); !ok {
+			return "", "", false
+		}
+	}
+	rest, ok := strings.CutPrefix(rest, "user>>>")
+	if !ok {
+		return "", "", false
+	}
+	_, stat, ok := strings.Cut(rest, ">>>")
+	if !ok {
+		return "", "", false
+	}
+	return name[:len(name)-len(">>>")-len(stat)], stat, true
+}
+
+// sendOnline replaces the IPs the users are online from at this server. The
+// hash expires as this server leaves the cluster.
+func (c *Cluster) sendOnline() error {
+	online := make(map[string][]string)
+	c.stats.VisitOnlineMaps(func(name string, om feature_stats.OnlineMap) bool {
+		if user, stat, ok := userStat(name); ok && stat == "online" {
+			for _, ip := range om.List() {
+				online[user] = append(online[user], ip)
+			}
+		}
+		return true
+	})
+	key := c.key("online", c.node)
+	if len(online) == 0 {
+		_, err := c.client.Do(c.ctx, "DEL", key)
+		return err
+	}
+	// The hash is written aside and renamed, for the others not to see it
+	// partly.
+	args := []string{"HSET", key + ":new"}
+	for user, ips := range online {
+		args = append(args, user, strings.Join(ips, ","))
+	}
+	if _, err := c.client.Do(c.ctx, "DEL", key+":new"); err != nil {
+		return err
+	}
+	if _, err := c.client.Do(c.ctx, args...); err != nil {
+		return err
+	}
+	ttl := strconv.FormatInt(int64(expiry*c.interval/time.Second)+1, 10)
+	if _, err := c.client.Do(c.ctx, "EXPIRE", key+":new", ttl); err != nil {
+		return err
+	}
+	_, err := c.client.Do(c.ctx, "RENAME", key+":new", key)
+	return err
+}
+
+// sendTraffic adds the traffic of the users since the last sync to the ones
+// at all servers. A user a counter of which is reset, such as by statsreset,
+// has its traffic at all servers reset to the one of its counters here, which
+// lifts its quota as it does without a cluster.
+func (c *Cluster) sendTraffic() error {
+	users := make(map[string]map[string]counterState)
+	c.stats.VisitCounters(func(name string, counter feature_stats.Counter) bool {
+		if user, stat, ok := userStat(name); ok && strings.HasPrefix(stat, "traffic>>>") {
+			if users[user] == nil {
+				users[user] = make(map[string]counterState)
+			}
+			users[user][name] = stateOf(counter)
+		}
+		return true
+	})
+	key := c.key("traffic")
+	for user, counters := range users {
+		var value, delta int64
+		reset := false
+		for name, s := range counters {
+			last := c.sent[name]
+			reset = reset || s.resetSince(last)
+			value += s.value
+			delta += s.value - last.value
+		}
+		if reset {
+			if _, err := c.client.Do(c.ctx, "HDEL", key, user); err != nil {
+				return err
+			}
+			errors.LogInfo(c.ctx, "cluster: traffic of ", user, " reset")
+			delta = value
+		}
+		if delta != 0 {
+			if _, err := c.client.Do(c.ctx, "HINCRBY", key, user, strconv.FormatInt(delta, 10)); err != nil {
+				return err
+			}
+		}
+		maps.Copy(c.sent, counters)
+	}
+	return nil
+}
+
+// receiveBans applies the bans of the cluster, which may be missed on the
+// channel.
+func (c *Cluster) receiveBans(now time.Time) error {
+	key := c.key("bans")
+	unix := strconv.FormatInt(now.Unix(), 10)
+	if _, err := c.client.Do(c.ctx, "ZREMRANGEBYSCORE", key, "-inf", unix); err != nil {
+		return err
+	}
+	reply, err := c.client.Do(c.ctx, "ZRANGEBYSCORE", key, "("+unix, "+inf", "WITHSCORES")
+	if err != nil {
+		return err
+	}
+	bans := redis.Strings(reply)
+	for i := 0; i+1 < len(bans); i += 2 {
+		c.ban(bans[i], bans[i+1])
+	}
+	return nil
+}
+
+// ban applies a ban of ip until the unix time of until, or lifts it if the
+// time has passed.
+func (c *Cluster) ban(ip string, until string) {
+	addr := net.ParseIP(ip)
+	seconds, err := strconv.ParseInt(until, 10, 64)
+	if addr == nil || err != nil {
+		return
+	}
+	c.guard.Ban(addr, time.Unix(seconds, 0))
+}
+
+// publishBans sends the bans of the guard of this server, and their lifts,
+// to the cluster.
+func (c *Cluster) publishBans() {
+	subscriber := events.Subscribe()
+	defer subscriber.Close()
+	key := c.key("bans")
+	for {
+		var e *events.Event
+		select {
+		case <-c.ctx.Done():
+			return
+		case value := <-subscriber.Wait():
+			e = value.(*events.Event)
+		}
+		ip := e.Data["ip"]
+		var err error
+		switch e.Type {
+		case events.SourceBanned:
+			until, parseErr := time.Parse(time.RFC3339, e.Data["until"])
+			if parseErr != nil {
+				continue
+			}
+			unix := strconv.FormatInt(until.Unix(), 10)
+			if _, err = c.client.Do(c.ctx, "ZADD", key, unix, ip); err == nil {
+				_, err = c.client.Do(c.ctx, "PUBLISH", key, ip+" "+unix)
+			}
+		case events.SourceUnbanned:
+			if _, err = c.client.Do(c.ctx, "ZREM", key, ip); err == nil {
+				_, err = c.client.Do(c.ctx, "PUBLISH", key, ip+" 0")
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			errors.LogWarningInner(c.ctx, err, "cluster: failed to share the ban of ", ip)
+		}
+	}
+}
+
+// subscribeBans applies the bans, and their lifts, published by the servers
+// of the cluster, this one included.
+func (c *Cluster) subscribeBans() {
+	for {
+		err := c.receive()
+		select {
+		case <-c.ctx.Done():
+			return
+		default:
+		}
+		errors.LogWarningInner(c.ctx, err, "cluster: failed to subscribe to bans")
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(c.interval):
+		}
+	}
+}
+
+func (c *Cluster) receive() error {
+	conn, err := c.client.Dial(c.ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-c.ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	if err := conn.Send("SUBSCRIBE", c.key("bans")); err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+	for {
+		reply, err := conn.Read()
+		if err != nil {
+			return err
+		}
+		// Messages are of ["message", channel, "[ip] [until]"].
+		message := redis.Strings(reply)
+		if len(message) != 3 || message[0] != "message" {
+			continue
+		}
+		if ip, until, ok := strings.Cut(message[2], " "); ok {
+			c.ban(ip, until)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package commander
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/xtls/xray-core/common/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// shortServiceName returns the short name of a gRPC service, such as "stats"
+// for xray.app.stats.command.StatsService.
+func shortServiceName(fullName string) string {
+	name := fullName[strings.LastIndexByte(fullName, '.')+1:]
+	return strings.TrimSuffix(strings.ToLower(name), "service")
+}
+
+// authorizer checks the credential of each call against its permissions.
+type authorizer struct {
+	credentials []*Credential
+}
+
+func (a *authorizer) authorize(ctx context.Context, fullMethod string) error {
+	credential := a.identify(ctx)
+	if credential == nil {
+		return status.Error(codes.Unauthenticated, "no valid credential")
+	}
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	service = shortServiceName(service)
+	for _, permission := range credential.Permission {
+		if permits(permission, service, method) {
+			return nil
+		}
+	}
+	errors.LogWarning(ctx, "API credential ", credential.Name, " is denied ", service, "/", method)
+	return status.Error(codes.PermissionDenied, "permission denied to "+service+"/"+method)
+}
+
+// identify returns the credential of the bearer token of the call, or of
+// the verified client certificate of its connection.
+func (a *authorizer) identify(ctx context.Context) *Credential {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, v := range md.Get("authorization") {
+			token, found := strings.CutPrefix(v, "Bearer ")
+			if !found {
+				continue
+			}
+			for _, c := range a.credentials {
+				if len(c.Token) > 0 && subtle.ConstantTimeCompare([]byte(token), []byte(c.Token)) == 1 {
+					return c
+				}
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+			commonName := info.State.VerifiedChains[0][0].Subject.CommonName
+			for _, c := range a.credentials {
+				if len(c.CommonName) > 0 && c.CommonName == commonName {
+					return c
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func permits(permission, service, method string) bool {
+	if permission == "*" {
+		return true
+	}
+	s, m, found := strings.Cut(permission, "/")
+	if s != service {
+		return false
+	}
+	if !found {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(m, "*"); ok {
+		return strings.HasPrefix(method, prefix)
+	}
+	return m == method
+}
+
+func (a *authorizer) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := a.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authorizer) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.authorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func newTLSConfig(config *TLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(config.CertificateFile, config.KeyFile)
+	if err != nil {
+		return nil, errors.New("failed to load API certificate").Base(err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+	if len(config.ClientCaFile) > 0 {
+		pem, err := os.ReadFile(config.ClientCaFile)
+		if err != nil {
+			return nil, errors.New("failed to read API client CA").Base(err)
+		}
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate in API client CA file ", config.ClientCaFile)
+		}
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// gatewayConn is a connection of the HTTP gateway, which is in process and
+// so not secured with TLS.
+type gatewayConn struct {
+	net.Conn
+}
+
+// serverCredentials is TLS, except for the connections of the HTTP gateway.
+type serverCredentials struct {
+	credentials.TransportCredentials
+}
+
+// gatewayAuthInfo is the AuthInfo of connections of the HTTP gateway.
+type gatewayAuthInfo struct {
+	credentials.CommonAuthInfo
+}
+
+func (gatewayAuthInfo) AuthType() string {
+	return "gateway"
+}
+
+// ServerHandshake implements credentials.TransportCredentials.
+func (c *serverCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	if _, ok := conn.(*gatewayConn); ok {
+		return conn, gatewayAuthInfo{credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity}}, nil
+	}
+	return c.TransportCredentials.ServerHandshake(conn)
+}
+
+// Clone implements credentials.TransportCredentials.
+func (c *serverCredentials) Clone() credentials.TransportCredentials {
+	return &serverCredentials{c.TransportCredentials.Clone()}
+}
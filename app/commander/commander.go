@@ -11,6 +11,7 @@ import (
 	core "github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/outbound"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 // Commander is a Xray feature that provides gRPC methods to external clients.
@@ -21,6 +22,10 @@ type Commander struct {
 	ohm      outbound.Manager
 	tag      string
 	listen   string
+	http     *HTTPConfig
+	gateway  *httpGateway
+	tls      *TLSConfig
+	auth     *authorizer
 }
 
 // NewCommander creates a new Commander based on the given config.
@@ -28,6 +33,11 @@ func NewCommander(ctx context.Context, config *Config) (*Commander, error) {
 	c := &Commander{
 		tag:    config.Tag,
 		listen: config.Listen,
+		http:   config.Http,
+		tls:    config.Tls,
+	}
+	if len(config.Credential) > 0 {
+		c.auth = &authorizer{credentials: config.Credential}
 	}
 
 	common.Must(core.RequireFeatures(ctx, func(om outbound.Manager) {
@@ -60,8 +70,23 @@ func (c *Commander) Type() interface{} {
 
 // Start implements common.Runnable.
 func (c *Commander) Start() error {
+	var options []grpc.ServerOption
+	if c.tls != nil {
+		tlsConfig, err := newTLSConfig(c.tls)
+		if err != nil {
+			return err
+		}
+		options = append(options, grpc.Creds(&serverCredentials{credentials.NewTLS(tlsConfig)}))
+	}
+	if c.auth != nil {
+		options = append(options,
+			grpc.UnaryInterceptor(c.auth.unaryInterceptor),
+			grpc.StreamInterceptor(c.auth.streamInterceptor),
+		)
+	}
+
 	c.Lock()
-	c.server = grpc.NewServer()
+	c.server = grpc.NewServer(options...)
 	for _, service := range c.services {
 		service.Register(c.server)
 	}
@@ -73,6 +98,22 @@ func (c *Commander) Start() error {
 		}
 	}
 
+	if c.http != nil {
+		listener := newInProcessListener()
+		go listen(listener)
+		gateway, err := newHTTPGateway(c.http, c.server, listener)
+		if err != nil {
+			return err
+		}
+		if err := gateway.start(); err != nil {
+			gateway.close()
+			return err
+		}
+		c.Lock()
+		c.gateway = gateway
+		c.Unlock()
+	}
+
 	if len(c.listen) > 0 {
 		if l, err := net.Listen("tcp", c.listen); err != nil {
 			errors.LogErrorInner(context.Background(), err, "API server failed to listen on ", c.listen)
@@ -106,6 +147,10 @@ func (c *Commander) Close() error {
 	c.Lock()
 	defer c.Unlock()
 
+	if c.gateway != nil {
+		c.gateway.close()
+		c.gateway = nil
+	}
 	if c.server != nil {
 		c.server.Stop()
 		c.server = nil
@@ -0,0 +1,257 @@
+package commander
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/signal/done"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// httpGateway serves the unary methods of the gRPC services as HTTP JSON, at
+// POST /v1/<service>/<method>, where service is the short name of the
+// service, such as "stats" for StatsService. GET /v1 lists the methods.
+type httpGateway struct {
+	token   string
+	server  *http.Server
+	conn    *grpc.ClientConn
+	methods map[string]map[string]*httpMethod
+}
+
+type httpMethod struct {
+	path   string
+	input  protoreflect.MessageType
+	output protoreflect.MessageType
+}
+
+// newHTTPGateway creates a gateway to the services of server, which it
+// connects to in process through listener.
+func newHTTPGateway(config *HTTPConfig, server *grpc.Server, listener *OutboundListener) (*httpGateway, error) {
+	conn, err := grpc.NewClient("passthrough:///commander",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			client, server := net.Pipe()
+			listener.add(&gatewayConn{server})
+			return client, nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &httpGateway{
+		token:   config.Token,
+		conn:    conn,
+		methods: make(map[string]map[string]*httpMethod),
+	}
+	for name, info := range server.GetServiceInfo() {
+		desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			continue
+		}
+		service, ok := desc.(protoreflect.ServiceDescriptor)
+		if !ok {
+			continue
+		}
+		methods := make(map[string]*httpMethod)
+		for _, m := range info.Methods {
+			if m.IsClientStream || m.IsServerStream {
+				continue
+			}
+			method := service.Methods().ByName(protoreflect.Name(m.Name))
+			if method == nil {
+				continue
+			}
+			input, err := protoregistry.GlobalTypes.FindMessageByName(method.Input().FullName())
+			if err != nil {
+				continue
+			}
+			output, err := protoregistry.GlobalTypes.FindMessageByName(method.Output().FullName())
+			if err != nil {
+				continue
+			}
+			methods[m.Name] = &httpMethod{
+				path:   "/" + name + "/" + m.Name,
+				input:  input,
+				output: output,
+			}
+		}
+		if len(methods) > 0 {
+			g.methods[shortServiceName(name)] = methods
+		}
+	}
+
+	g.server = &http.Server{
+		Addr:              config.Listen,
+		Handler:           g,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if len(config.CertificateFile) > 0 || len(config.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(config.CertificateFile, config.KeyFile)
+		if err != nil {
+			conn.Close()
+			return nil, errors.New("failed to load certificate of API HTTP gateway").Base(err)
+		}
+		g.server.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		}
+	}
+	return g, nil
+}
+
+func (g *httpGateway) start() error {
+	l, err := net.Listen("tcp", g.server.Addr)
+	if err != nil {
+		return errors.New("API HTTP gateway failed to listen on ", g.server.Addr).Base(err)
+	}
+	if g.server.TLSConfig != nil {
+		l = tls.NewListener(l, g.server.TLSConfig)
+	}
+	errors.LogInfo(context.Background(), "API HTTP gateway listening on ", l.Addr())
+	go func() {
+		if err := g.server.Serve(l); err != nil && err != http.ErrServerClosed {
+			errors.LogErrorInner(context.Background(), err, "failed to serve API HTTP gateway")
+		}
+	}()
+	return nil
+}
+
+func (g *httpGateway) close() error {
+	g.conn.Close()
+	return g.server.Close()
+}
+
+// ServeHTTP implements http.Handler.
+func (g *httpGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(g.token) > 0 {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(g.token)) != 1 {
+			writeHTTPError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "v1" {
+		if r.Method != http.MethodGet {
+			writeHTTPError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		list := make(map[string][]string, len(g.methods))
+		for service, methods := range g.methods {
+			for name := range methods {
+				list[service] = append(list[service], name)
+			}
+			slices.Sort(list[service])
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) != 3 || parts[0] != "v1" {
+		writeHTTPError(w, http.StatusNotFound, "not found")
+		return
+	}
+	method := g.methods[parts[1]][parts[2]]
+	if method == nil {
+		writeHTTPError(w, http.StatusNotFound, "unknown method "+parts[1]+"/"+parts[2])
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeHTTPError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 16<<20))
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	input := method.input.New().Interface()
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if err := protojson.Unmarshal(body, input); err != nil {
+			writeHTTPError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+			return
+		}
+	}
+	// The token is passed on, for the credentials of the services.
+	ctx := r.Context()
+	if authorization := r.Header.Get("Authorization"); len(authorization) > 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", authorization)
+	}
+	output := method.output.New().Interface()
+	if err := g.conn.Invoke(ctx, method.path, input, output); err != nil {
+		s := status.Convert(err)
+		writeHTTPError(w, httpStatusOf(s.Code()), s.Message())
+		return
+	}
+	writeHTTPResponse(w, output)
+}
+
+func writeHTTPResponse(w http.ResponseWriter, m proto.Message) {
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+func writeHTTPError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func httpStatusOf(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Canceled:
+		return 499
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// newInProcessListener returns a listener for connections from the gateway.
+func newInProcessListener() *OutboundListener {
+	return &OutboundListener{
+		buffer: make(chan net.Conn, 4),
+		done:   done.New(),
+	}
+}
@@ -7,22 +7,32 @@ import (
 	"sync"
 	"time"
 
+	"github.com/xtls/xray-core/app/proxyman"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/protocol/bittorrent"
 	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/tracing"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/dns"
+	"github.com/xtls/xray-core/features/extension"
 	"github.com/xtls/xray-core/features/outbound"
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
 	routing_session "github.com/xtls/xray-core/features/routing/session"
 	"github.com/xtls/xray-core/features/stats"
 	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/internet/reality"
+	"github.com/xtls/xray-core/transport/internet/stat"
+	"github.com/xtls/xray-core/transport/internet/tls"
 	"github.com/xtls/xray-core/transport/pipe"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 var errSniffingTimeout = errors.New("timeout on sniffing")
@@ -97,6 +107,13 @@ type DefaultDispatcher struct {
 	policy policy.Manager
 	stats  stats.Manager
 	fdns   dns.FakeDNSEngine
+	// cluster shares the restrictions of users with other servers, if any.
+	cluster extension.Cluster
+	// overQuota has the stat names of the users rejected for their quota,
+	// until their traffic is under it again.
+	overQuota sync.Map
+
+	udpStats udpStats
 }
 
 func init() {
@@ -106,6 +123,9 @@ func init() {
 			core.OptionalFeatures(ctx, func(fdns dns.FakeDNSEngine) {
 				d.fdns = fdns
 			})
+			core.OptionalFeatures(ctx, func(cluster extension.Cluster) {
+				d.cluster = cluster
+			})
 			return d.Init(config.(*Config), om, router, pm, sm)
 		}); err != nil {
 			return nil, err
@@ -158,9 +178,10 @@ func (d *DefaultDispatcher) getLink(ctx context.Context) (*transport.Link, *tran
 	}
 
 	if user != nil && len(user.Email) > 0 {
+		tenant := d.tenant(ctx)
 		p := d.policy.ForLevel(user.Level)
 		if p.Stats.UserUplink {
-			name := "user>>>" + user.Email + ">>>traffic>>>uplink"
+			name := tenant.StatName("user>>>" + user.Email + ">>>traffic>>>uplink")
 			if c, _ := stats.GetOrRegisterCounter(d.stats, name); c != nil {
 				inboundLink.Writer = &SizeStatWriter{
 					Counter: c,
@@ -169,7 +190,7 @@ func (d *DefaultDispatcher) getLink(ctx context.Context) (*transport.Link, *tran
 			}
 		}
 		if p.Stats.UserDownlink {
-			name := "user>>>" + user.Email + ">>>traffic>>>downlink"
+			name := tenant.StatName("user>>>" + user.Email + ">>>traffic>>>downlink")
 			if c, _ := stats.GetOrRegisterCounter(d.stats, name); c != nil {
 				outboundLink.Writer = &SizeStatWriter{
 					Counter: c,
@@ -177,18 +198,6 @@ func (d *DefaultDispatcher) getLink(ctx context.Context) (*transport.Link, *tran
 				}
 			}
 		}
-
-		if p.Stats.UserOnline {
-			name := "user>>>" + user.Email + ">>>online"
-			if om, _ := stats.GetOrRegisterOnlineMap(d.stats, name); om != nil {
-				sessionInbounds := session.InboundFromContext(ctx)
-				userIP := sessionInbounds.Source.Address.String()
-				om.AddIP(userIP)
-				// log Online user with ips
-				// errors.LogDebug(ctx, "user>>>" + user.Email + ">>>online", om.Count(), om.List())
-
-			}
-		}
 	}
 
 	return inboundLink, outboundLink
@@ -259,20 +268,33 @@ func (d *DefaultDispatcher) Dispatch(ctx context.Context, destination net.Destin
 	}
 
 	sniffingRequest := content.SniffingRequest
+	sniffing := shouldSniff(sniffingRequest, destination)
+	sessionPolicy := d.sessionPolicy(ctx)
+	blockBittorrent := sessionPolicy.Restriction.BlockBittorrent
+	ctx, span := startTrace(ctx, sessionPolicy, destination)
 	inbound, outbound := d.getLink(ctx)
-	if !sniffingRequest.Enabled {
-		go d.routedDispatch(ctx, outbound, destination)
+	if !sniffing && !blockBittorrent {
+		go func() {
+			defer span.End()
+			d.routedDispatch(ctx, outbound, destination)
+		}()
 	} else {
 		go func() {
+			defer span.End()
 			cReader := &cachedReader{
 				reader: outbound.Reader.(*pipe.Reader),
 			}
 			outbound.Reader = cReader
-			result, err := sniffer(ctx, cReader, sniffingRequest.MetadataOnly, destination.Network)
+			result, err := traceSniffer(ctx, cReader, sniffingRequest.MetadataOnly && !blockBittorrent, destination.Network)
 			if err == nil {
 				content.Protocol = result.Protocol()
+				setSniffedAttributes(content, result)
+			}
+			if err == nil && blockBittorrent && isBittorrent(result, content) {
+				d.rejectBittorrent(ctx, outbound, destination)
+				return
 			}
-			if err == nil && d.shouldOverride(ctx, result, sniffingRequest, destination) {
+			if err == nil && sniffing && d.shouldOverride(ctx, result, sniffingRequest, destination) {
 				domain := result.Domain()
 				errors.LogInfo(ctx, "sniffed domain: ", domain)
 				destination.Address = net.ParseAddress(domain)
@@ -290,6 +312,9 @@ func (d *DefaultDispatcher) Dispatch(ctx context.Context, destination net.Destin
 					ob.Target = destination
 				}
 			}
+			if err == nil && sniffing {
+				destination = d.redirect(ctx, result, sniffingRequest, destination)
+			}
 			d.routedDispatch(ctx, outbound, destination)
 		}()
 	}
@@ -315,18 +340,28 @@ func (d *DefaultDispatcher) DispatchLink(ctx context.Context, destination net.De
 		ctx = session.ContextWithContent(ctx, content)
 	}
 	sniffingRequest := content.SniffingRequest
-	if !sniffingRequest.Enabled {
+	sniffing := shouldSniff(sniffingRequest, destination)
+	sessionPolicy := d.sessionPolicy(ctx)
+	blockBittorrent := sessionPolicy.Restriction.BlockBittorrent
+	ctx, span := startTrace(ctx, sessionPolicy, destination)
+	defer span.End()
+	if !sniffing && !blockBittorrent {
 		d.routedDispatch(ctx, outbound, destination)
 	} else {
 		cReader := &cachedReader{
 			reader: outbound.Reader.(*pipe.Reader),
 		}
 		outbound.Reader = cReader
-		result, err := sniffer(ctx, cReader, sniffingRequest.MetadataOnly, destination.Network)
+		result, err := traceSniffer(ctx, cReader, sniffingRequest.MetadataOnly && !blockBittorrent, destination.Network)
 		if err == nil {
 			content.Protocol = result.Protocol()
+			setSniffedAttributes(content, result)
+		}
+		if err == nil && blockBittorrent && isBittorrent(result, content) {
+			d.rejectBittorrent(ctx, outbound, destination)
+			return nil
 		}
-		if err == nil && d.shouldOverride(ctx, result, sniffingRequest, destination) {
+		if err == nil && sniffing && d.shouldOverride(ctx, result, sniffingRequest, destination) {
 			domain := result.Domain()
 			errors.LogInfo(ctx, "sniffed domain: ", domain)
 			destination.Address = net.ParseAddress(domain)
@@ -344,12 +379,136 @@ func (d *DefaultDispatcher) DispatchLink(ctx context.Context, destination net.De
 				ob.Target = destination
 			}
 		}
+		if err == nil && sniffing {
+			destination = d.redirect(ctx, result, sniffingRequest, destination)
+		}
 		d.routedDispatch(ctx, outbound, destination)
 	}
 
 	return nil
 }
 
+// sessionPolicy returns the policy of the user of the inbound connection, or of
+// level 0 if there is none.
+func (d *DefaultDispatcher) sessionPolicy(ctx context.Context) policy.Session {
+	var level uint32
+	if inbound := session.InboundFromContext(ctx); inbound != nil && inbound.User != nil {
+		level = inbound.User.Level
+	}
+	return d.policy.ForLevel(level)
+}
+
+// startTrace starts the span of the connection of ctx if its policy traces it,
+// with the span of its accept stage, from when it was accepted until now.
+func startTrace(ctx context.Context, p policy.Session, destination net.Destination) (context.Context, trace.Span) {
+	if !p.Tracing.Enabled {
+		return ctx, noop.Span{}
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("network", destination.Network.SystemString()),
+		attribute.String("target", destination.NetAddr()),
+	}
+	var accepted time.Time
+	if inbound := session.InboundFromContext(ctx); inbound != nil {
+		accepted = inbound.Accepted
+		if len(inbound.Tag) > 0 {
+			attrs = append(attrs, attribute.String("inbound", inbound.Tag))
+		}
+		if inbound.Source.IsValid() {
+			attrs = append(attrs, attribute.String("source", inbound.Source.NetAddr()))
+		}
+		if inbound.User != nil && len(inbound.User.Email) > 0 {
+			attrs = append(attrs, attribute.String("user", inbound.User.Email))
+		}
+	}
+	ctx, span := tracing.StartConnection(ctx, accepted, attrs...)
+	_, accept := tracing.StartAt(ctx, tracing.Accept, accepted)
+	accept.End()
+	return ctx, span
+}
+
+// isBittorrent returns whether the sniffed traffic is BitTorrent, including
+// HTTP requests to trackers.
+func isBittorrent(result SniffResult, content *session.Content) bool {
+	protocol := result.Protocol()
+	if protocol == "bittorrent" {
+		return true
+	}
+	return strings.HasPrefix(protocol, "http") && bittorrent.IsTrackerRequest(content.Attribute(":path"))
+}
+
+// rejectBittorrent closes link, and counts the attempt for the inbound and the
+// user in "inbound>>>[tag]>>>bittorrent>>>blocked" and
+// "user>>>[email]>>>bittorrent>>>blocked", in the namespace of their tenants.
+func (d *DefaultDispatcher) rejectBittorrent(ctx context.Context, link *transport.Link, destination net.Destination) {
+	errors.LogInfo(ctx, "blocked BitTorrent traffic to ", destination)
+	if inbound := session.InboundFromContext(ctx); inbound != nil {
+		tenants := d.policy.ForSystem().Tenants
+		if inbound.Tag != "" {
+			name := tenants.ForInbound(inbound.Tag).StatName("inbound>>>" + inbound.Tag + ">>>bittorrent>>>blocked")
+			if c, _ := stats.GetOrRegisterCounter(d.stats, name); c != nil {
+				c.Add(1)
+			}
+		}
+		if inbound.User != nil && len(inbound.User.Email) > 0 {
+			name := d.tenant(ctx).StatName("user>>>" + inbound.User.Email + ">>>bittorrent>>>blocked")
+			if c, _ := stats.GetOrRegisterCounter(d.stats, name); c != nil {
+				c.Add(1)
+			}
+		}
+	}
+	if accessMessage := log.AccessMessageFromContext(ctx); accessMessage != nil {
+		accessMessage.Status = log.AccessRejected
+		accessMessage.Reason = "bittorrent"
+		log.Record(accessMessage)
+	}
+	common.Close(link.Writer)
+	common.Interrupt(link.Reader)
+}
+
+// redirect returns where the connection to destination is sent, as the
+// sniffed domain is redirected by request.
+func (d *DefaultDispatcher) redirect(ctx context.Context, result SniffResult, request session.SniffingRequest, destination net.Destination) net.Destination {
+	domain := result.Domain()
+	if domain == "" || request.RedirectForDomain == nil {
+		return destination
+	}
+	redirected, ok := request.RedirectForDomain(strings.ToLower(domain), destination)
+	if !ok {
+		return destination
+	}
+	errors.LogInfo(ctx, "redirecting sniffed domain ", domain, " to ", redirected)
+	ob := session.OutboundsFromContext(ctx)
+	ob[len(ob)-1].Target = redirected
+	ob[len(ob)-1].RouteTarget = net.Destination{}
+	return redirected
+}
+
+func shouldSniff(request session.SniffingRequest, destination net.Destination) bool {
+	return request.Enabled && (request.ExcludeForDestination == nil || !request.ExcludeForDestination(destination))
+}
+
+// setSniffedAttributes adds the details of the sniffed protocol, if any, to the
+// attributes of content.
+func setSniffedAttributes(content *session.Content, result SniffResult) {
+	if r, ok := result.(SnifferResultAttributes); ok {
+		for key, value := range r.Attributes() {
+			content.SetAttribute(key, value)
+		}
+	}
+}
+
+// traceSniffer is sniffer, traced as the sniff stage of the connection.
+func traceSniffer(ctx context.Context, cReader *cachedReader, metadataOnly bool, network net.Network) (SniffResult, error) {
+	ctx, span := tracing.Start(ctx, tracing.Sniff)
+	defer span.End()
+	result, err := sniffer(ctx, cReader, metadataOnly, network)
+	if err == nil {
+		span.SetAttributes(attribute.String("protocol", result.Protocol()))
+	}
+	return result, err
+}
+
 func sniffer(ctx context.Context, cReader *cachedReader, metadataOnly bool, network net.Network) (SniffResult, error) {
 	payload := buf.NewWithSize(32767)
 	defer payload.Release()
@@ -411,7 +570,32 @@ func (d *DefaultDispatcher) routedDispatch(ctx context.Context, link *transport.
 
 	var handler outbound.Handler
 
-	routingLink := routing_session.AsRoutingContext(ctx)
+	// The handshake is done by now, as the inbound has read the request.
+	if inbound := session.InboundFromContext(ctx); inbound != nil {
+		if inbound.TLSClient == "" {
+			inbound.TLSClient = tls.ClientIdentity(inbound.Conn)
+		}
+		if inbound.TLSServerName == "" && inbound.TLSALPN == "" {
+			inbound.TLSServerName, inbound.TLSALPN = handshakeInfo(inbound.Conn)
+		}
+	}
+	tenant := d.tenant(ctx)
+	// The IP of the user is online once admitted.
+	if reason := d.admitUser(ctx, tenant); reason != "" {
+		d.rejectUser(ctx, link, reason)
+		return
+	}
+	if tenant != nil {
+		if !tenant.Acquire() {
+			d.rejectTenant(ctx, link, tenant)
+			return
+		}
+		defer tenant.Release()
+		resolveHosts(ctx, ob, tenant)
+	}
+	routeCtx, span := tracing.Start(ctx, tracing.Route)
+	defer span.End()
+	routingLink := routing_session.AsRoutingContext(routeCtx)
 	inTag := routingLink.GetInboundTag()
 	isPickRoute := 0
 	if forcedOutboundTag := session.GetForcedOutboundTagFromContext(ctx); forcedOutboundTag != "" {
@@ -431,6 +615,7 @@ func (d *DefaultDispatcher) routedDispatch(ctx context.Context, link *transport.
 			outTag := route.GetOutboundTag()
 			if h := d.ohm.GetHandler(outTag); h != nil {
 				isPickRoute = 2
+				ob.RuleTag = route.GetRuleTag()
 				if route.GetRuleTag() == "" {
 					errors.LogInfo(ctx, "taking detour [", outTag, "] for [", destination, "]")
 				} else {
@@ -457,7 +642,19 @@ func (d *DefaultDispatcher) routedDispatch(ctx context.Context, link *transport.
 	}
 
 	ob.Tag = handler.Tag()
+	if len(ob.Tag) > 0 {
+		span.SetAttributes(attribute.String("outbound", ob.Tag))
+	}
+	if len(ob.RuleTag) > 0 {
+		span.SetAttributes(attribute.String("rule", ob.RuleTag))
+	}
+	span.End()
 	if accessMessage := log.AccessMessageFromContext(ctx); accessMessage != nil {
+		accessMessage.TLSClient = routingLink.GetTLSClient()
+		accessMessage.TLSSNI = routingLink.GetTLSServerName()
+		accessMessage.TLSALPN = routingLink.GetTLSALPN()
+		accessMessage.InboundTag = inTag
+		accessMessage.OutboundTag = handler.Tag()
 		if tag := handler.Tag(); tag != "" {
 			if inTag == "" {
 				accessMessage.Detour = tag
@@ -472,5 +669,50 @@ func (d *DefaultDispatcher) routedDispatch(ctx context.Context, link *transport.
 		log.Record(accessMessage)
 	}
 
+	ctx, termination := session.ContextWithTermination(ctx)
+	d.idle(ctx, link, destination)
+	d.countRule(ctx, link, ob.RuleTag)
+	d.countUDP(ctx, link, destination)
+	d.shape(ctx, link, ob.RuleTag, tenant)
+	proxyman.FilterNAT(link, proxyman.NATBehaviorFromContext(ctx), destination)
 	handler.Dispatch(ctx, link)
+	logTermination(ctx, termination)
+}
+
+// logTermination records the access message of the connection again once it
+// terminates, unless closed normally. Connections handed over to mux are not
+// recorded, as they terminate apart.
+func logTermination(ctx context.Context, record *session.TerminationRecord) {
+	accessMessage := log.AccessMessageFromContext(ctx)
+	if accessMessage == nil {
+		return
+	}
+	t, _ := record.Termination()
+	if t == "" || t == session.TerminationClosed {
+		return
+	}
+	msg := *accessMessage
+	msg.Status = log.AccessTerminated
+	msg.Reason = string(t)
+	log.Record(&msg)
+}
+
+// handshakeInfo returns the server name the client sent and the protocol
+// negotiated by ALPN, if conn is a TLS or REALITY server connection done with
+// the handshake.
+func handshakeInfo(conn net.Conn) (string, string) {
+	if counter, ok := conn.(*stat.CounterConnection); ok {
+		conn = counter.Connection
+	}
+	switch c := conn.(type) {
+	case *tls.Conn:
+		if state := c.ConnectionState(); state.HandshakeComplete {
+			return state.ServerName, state.NegotiatedProtocol
+		}
+	case *reality.Conn:
+		if state := c.ConnectionState(); state.HandshakeComplete {
+			return state.ServerName, state.NegotiatedProtocol
+		}
+	}
+	return "", ""
 }
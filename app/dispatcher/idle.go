@@ -0,0 +1,153 @@
+package dispatcher
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/transport"
+)
+
+// idle cuts the connection of link once it is idle for the timeout of its
+// protocol, when shorter than ConnectionIdle, which the handlers enforce.
+// After one direction of it is done, the other is cut once idle for
+// UplinkOnly or DownlinkOnly. Spliced traffic bypasses link, so TCP
+// connections timed here are not spliced.
+func (d *DefaultDispatcher) idle(ctx context.Context, link *transport.Link, destination net.Destination) {
+	timeouts := d.sessionPolicy(ctx).Timeouts
+	var protocol string
+	if content := session.ContentFromContext(ctx); content != nil {
+		protocol = content.Protocol
+	}
+	timeout := timeouts.ConnectionIdleFor(destination.Network, protocol)
+	if destination.Network == net.Network_UDP && protocol == "stun" {
+		// WebRTC keeps its mappings alive with sparse STUN checks, which may
+		// hold for longer than other UDP is.
+		if inbound := session.InboundFromContext(ctx); inbound != nil && inbound.KeepMapping != nil {
+			inbound.KeepMapping(timeout)
+		}
+	}
+	if timeout <= 0 || timeout >= timeouts.ConnectionIdle {
+		return
+	}
+
+	t := &idleTimer{timeouts: timeouts}
+	reader, writer := link.Reader, link.Writer
+	t.timer = signal.CancelAfterInactivity(ctx, func() {
+		if t.stopped.Load() {
+			return
+		}
+		errors.LogInfo(ctx, "connection idle for ", timeout)
+		common.Interrupt(reader)
+		common.Interrupt(writer)
+	}, timeout)
+	link.Reader = &idleReader{reader: reader, timer: t}
+	link.Writer = &idleWriter{writer: writer, timer: t}
+	if inbound := session.InboundFromContext(ctx); inbound != nil && destination.Network == net.Network_TCP {
+		inbound.CanSpliceCopy = 3
+	}
+}
+
+// idleTimer is the timer of a connection, switched to the timeout of the
+// direction left once the other is done, and stopped once both are.
+type idleTimer struct {
+	timer    *signal.ActivityTimer
+	timeouts policy.Timeout
+	stopped  atomic.Bool
+
+	access       sync.Mutex
+	uplinkDone   bool
+	downlinkDone bool
+}
+
+func (t *idleTimer) finish(uplink bool) {
+	t.access.Lock()
+	defer t.access.Unlock()
+
+	if uplink {
+		if t.uplinkDone {
+			return
+		}
+		t.uplinkDone = true
+	} else {
+		if t.downlinkDone {
+			return
+		}
+		t.downlinkDone = true
+	}
+	switch {
+	case t.uplinkDone && t.downlinkDone:
+		t.stopped.Store(true)
+		t.timer.SetTimeout(0)
+	case t.uplinkDone:
+		t.timer.SetTimeout(t.timeouts.DownlinkOnly)
+	default:
+		t.timer.SetTimeout(t.timeouts.UplinkOnly)
+	}
+}
+
+type idleReader struct {
+	reader buf.Reader
+	timer  *idleTimer
+}
+
+func (r *idleReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	mb, err := r.reader.ReadMultiBuffer()
+	r.update(mb, err)
+	return mb, err
+}
+
+func (r *idleReader) ReadMultiBufferTimeout(timeout time.Duration) (buf.MultiBuffer, error) {
+	reader, ok := r.reader.(buf.TimeoutReader)
+	if !ok {
+		return r.ReadMultiBuffer()
+	}
+	mb, err := reader.ReadMultiBufferTimeout(timeout)
+	if err == buf.ErrReadTimeout {
+		return mb, err
+	}
+	r.update(mb, err)
+	return mb, err
+}
+
+func (r *idleReader) update(mb buf.MultiBuffer, err error) {
+	if !mb.IsEmpty() {
+		r.timer.timer.Update()
+	}
+	if err != nil {
+		r.timer.finish(true)
+	}
+}
+
+func (r *idleReader) Interrupt() {
+	common.Interrupt(r.reader)
+	r.timer.finish(true)
+}
+
+type idleWriter struct {
+	writer buf.Writer
+	timer  *idleTimer
+}
+
+func (w *idleWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	w.timer.timer.Update()
+	return w.writer.WriteMultiBuffer(mb)
+}
+
+func (w *idleWriter) Close() error {
+	w.timer.finish(false)
+	return common.Close(w.writer)
+}
+
+func (w *idleWriter) Interrupt() {
+	common.Interrupt(w.writer)
+	w.timer.finish(false)
+}
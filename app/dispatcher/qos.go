@@ -0,0 +1,89 @@
+package dispatcher
+
+import (
+	"context"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/qos"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/transport"
+)
+
+// shape shapes the traffic of link in the QoS classes of the connection, once
+// routed, and in the class of its tenant, if any. Shaped connections are not
+// spliced, as the kernel would bypass the shapers.
+func (d *DefaultDispatcher) shape(ctx context.Context, link *transport.Link, ruleTag string, tenant *policy.Tenant) {
+	var classes []policy.QoSClass
+	inbound := session.InboundFromContext(ctx)
+	if q := d.policy.ForSystem().QoS; q.Default.Uplink != nil || q.Default.Downlink != nil {
+		var level uint32
+		if inbound != nil && inbound.User != nil {
+			level = inbound.User.Level
+		}
+		classes = append(classes, q.ForConnection(level, ruleTag))
+	}
+	if tenant != nil {
+		classes = append(classes, tenant.Class)
+	}
+
+	shaped := false
+	for _, class := range classes {
+		if class.Uplink != nil {
+			link.Reader = &shapedReader{class: class.Uplink, reader: link.Reader}
+			shaped = true
+		}
+		if class.Downlink != nil {
+			link.Writer = &shapedWriter{class: class.Downlink, writer: link.Writer}
+			shaped = true
+		}
+	}
+	if shaped && inbound != nil {
+		inbound.CanSpliceCopy = 3
+	}
+}
+
+type shapedReader struct {
+	class  *qos.Class
+	reader buf.Reader
+}
+
+func (r *shapedReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	mb, err := r.reader.ReadMultiBuffer()
+	r.class.Wait(mb.Len())
+	return mb, err
+}
+
+func (r *shapedReader) ReadMultiBufferTimeout(timeout time.Duration) (buf.MultiBuffer, error) {
+	reader, ok := r.reader.(buf.TimeoutReader)
+	if !ok {
+		return r.ReadMultiBuffer()
+	}
+	mb, err := reader.ReadMultiBufferTimeout(timeout)
+	r.class.Wait(mb.Len())
+	return mb, err
+}
+
+func (r *shapedReader) Interrupt() {
+	common.Interrupt(r.reader)
+}
+
+type shapedWriter struct {
+	class  *qos.Class
+	writer buf.Writer
+}
+
+func (w *shapedWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	w.class.Wait(mb.Len())
+	return w.writer.WriteMultiBuffer(mb)
+}
+
+func (w *shapedWriter) Close() error {
+	return common.Close(w.writer)
+}
+
+func (w *shapedWriter) Interrupt() {
+	common.Interrupt(w.writer)
+}
@@ -0,0 +1,95 @@
+package dispatcher
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/events"
+	"github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/transport"
+)
+
+// admitUser counts the IP of the user of the connection of ctx online, and
+// returns why the restriction of the user rejects the connection, or "" if
+// it does not.
+func (d *DefaultDispatcher) admitUser(ctx context.Context, tenant *policy.Tenant) string {
+	inbound := session.InboundFromContext(ctx)
+	if inbound == nil || inbound.User == nil || len(inbound.User.Email) == 0 {
+		return ""
+	}
+	email := inbound.User.Email
+	name := tenant.StatName("user>>>" + email)
+	p := d.policy.ForLevel(inbound.User.Level)
+	restriction := p.Restriction
+	if restriction.Quota > 0 {
+		used := d.traffic(name)
+		if used < restriction.Quota {
+			d.overQuota.Delete(name)
+		} else {
+			errors.LogInfo(ctx, "user ", email, " has used its quota of ", restriction.Quota, " bytes")
+			if _, loaded := d.overQuota.LoadOrStore(name, struct{}{}); !loaded {
+				data := []string{"user", email, "quota", strconv.FormatInt(restriction.Quota, 10), "used", strconv.FormatInt(used, 10)}
+				if tenant != nil {
+					data = append(data, "tenant", tenant.Name)
+				}
+				events.Emit(events.UserOverQuota, data...)
+			}
+			return "quota"
+		}
+	}
+	if !p.Stats.UserOnline && restriction.MaxIPs == 0 {
+		return ""
+	}
+	om, _ := stats.GetOrRegisterOnlineMap(d.stats, name+">>>online")
+	if om == nil || !inbound.Source.IsValid() {
+		return ""
+	}
+	ip := inbound.Source.Address.String()
+	if restriction.MaxIPs > 0 {
+		ips := om.List()
+		if d.cluster != nil {
+			ips = append(ips, d.cluster.OnlineIPs(name)...)
+		}
+		if !slices.Contains(ips, ip) {
+			slices.Sort(ips)
+			if n := len(slices.Compact(ips)); n >= int(restriction.MaxIPs) {
+				errors.LogInfo(ctx, "user ", email, " is online from ", n, " IPs already")
+				return "ips"
+			}
+		}
+	}
+	om.AddIP(ip)
+	return ""
+}
+
+// traffic returns the bytes used by user, named as in the stats, at all
+// servers of the cluster if any.
+func (d *DefaultDispatcher) traffic(user string) int64 {
+	if d.cluster != nil {
+		return d.cluster.Traffic(user)
+	}
+	var n int64
+	for _, direction := range []string{"uplink", "downlink"} {
+		if c := d.stats.GetCounter(user + ">>>traffic>>>" + direction); c != nil {
+			n += c.Value()
+		}
+	}
+	return n
+}
+
+// rejectUser closes link of a user rejected by its restriction for reason.
+func (d *DefaultDispatcher) rejectUser(ctx context.Context, link *transport.Link, reason string) {
+	if accessMessage := log.AccessMessageFromContext(ctx); accessMessage != nil {
+		accessMessage.Status = log.AccessRejected
+		accessMessage.Reason = "user " + reason
+		log.Record(accessMessage)
+	}
+	common.Close(link.Writer)
+	common.Interrupt(link.Reader)
+}
@@ -8,8 +8,11 @@ import (
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/protocol/bittorrent"
+	"github.com/xtls/xray-core/common/protocol/dns"
 	"github.com/xtls/xray-core/common/protocol/http"
 	"github.com/xtls/xray-core/common/protocol/quic"
+	"github.com/xtls/xray-core/common/protocol/ssh"
+	"github.com/xtls/xray-core/common/protocol/stun"
 	"github.com/xtls/xray-core/common/protocol/tls"
 )
 
@@ -18,10 +21,14 @@ type SniffResult interface {
 	Domain() string
 }
 
-type protocolSniffer func(context.Context, []byte) (SniffResult, error)
+// SnifferFunc detects a protocol from the first bytes of a connection. It
+// returns common.ErrNoClue if it needs more bytes to tell, and
+// protocol.ErrProtoNeedMoreData if the protocol matched but more bytes are
+// needed to complete the result.
+type SnifferFunc func(context.Context, []byte) (SniffResult, error)
 
 type protocolSnifferWithMetadata struct {
-	protocolSniffer protocolSniffer
+	protocolSniffer SnifferFunc
 	// A Metadata sniffer will be invoked on connection establishment only, with nil body,
 	// for both TCP and UDP connections
 	// It will not be shown as a traffic type for routing unless there is no other successful sniffing.
@@ -29,19 +36,38 @@ type protocolSnifferWithMetadata struct {
 	network         net.Network
 }
 
+var registeredSniffers []protocolSnifferWithMetadata
+
+// RegisterSniffer adds a sniffer for connections of the given network. Sniffers
+// are tried in the order they are registered. It is not thread-safe, and is
+// intended to be called during package init.
+func RegisterSniffer(network net.Network, sniffer SnifferFunc) {
+	registeredSniffers = append(registeredSniffers, protocolSnifferWithMetadata{
+		protocolSniffer: sniffer,
+		network:         network,
+	})
+}
+
+func init() {
+	RegisterSniffer(net.Network_TCP, func(c context.Context, b []byte) (SniffResult, error) { return http.SniffHTTP(b, c) })
+	RegisterSniffer(net.Network_TCP, func(c context.Context, b []byte) (SniffResult, error) { return tls.SniffTLS(b) })
+	RegisterSniffer(net.Network_TCP, func(c context.Context, b []byte) (SniffResult, error) { return bittorrent.SniffBittorrent(b) })
+	RegisterSniffer(net.Network_TCP, func(c context.Context, b []byte) (SniffResult, error) { return ssh.SniffSSH(b) })
+	RegisterSniffer(net.Network_UDP, func(c context.Context, b []byte) (SniffResult, error) { return quic.SniffQUIC(b) })
+	RegisterSniffer(net.Network_UDP, func(c context.Context, b []byte) (SniffResult, error) { return bittorrent.SniffUTP(b) })
+	RegisterSniffer(net.Network_UDP, func(c context.Context, b []byte) (SniffResult, error) { return bittorrent.SniffDHT(b) })
+	RegisterSniffer(net.Network_UDP, func(c context.Context, b []byte) (SniffResult, error) { return bittorrent.SniffUDPTracker(b) })
+	RegisterSniffer(net.Network_UDP, func(c context.Context, b []byte) (SniffResult, error) { return stun.SniffSTUN(b) })
+	RegisterSniffer(net.Network_UDP, func(c context.Context, b []byte) (SniffResult, error) { return dns.SniffDNS(b) })
+}
+
 type Sniffer struct {
 	sniffer []protocolSnifferWithMetadata
 }
 
 func NewSniffer(ctx context.Context) *Sniffer {
 	ret := &Sniffer{
-		sniffer: []protocolSnifferWithMetadata{
-			{func(c context.Context, b []byte) (SniffResult, error) { return http.SniffHTTP(b, c) }, false, net.Network_TCP},
-			{func(c context.Context, b []byte) (SniffResult, error) { return tls.SniffTLS(b) }, false, net.Network_TCP},
-			{func(c context.Context, b []byte) (SniffResult, error) { return bittorrent.SniffBittorrent(b) }, false, net.Network_TCP},
-			{func(c context.Context, b []byte) (SniffResult, error) { return quic.SniffQUIC(b) }, false, net.Network_UDP},
-			{func(c context.Context, b []byte) (SniffResult, error) { return bittorrent.SniffUTP(b) }, false, net.Network_UDP},
-		},
+		sniffer: append([]protocolSnifferWithMetadata(nil), registeredSniffers...),
 	}
 	if sniffer, err := newFakeDNSSniffer(ctx); err == nil {
 		others := ret.sniffer
@@ -133,6 +159,13 @@ func (c compositeResult) ProtocolForDomainResult() string {
 	return c.domainResult.Protocol()
 }
 
+func (c compositeResult) Attributes() map[string]string {
+	if r, ok := c.protocolResult.(SnifferResultAttributes); ok {
+		return r.Attributes()
+	}
+	return nil
+}
+
 type SnifferResultComposite interface {
 	ProtocolForDomainResult() string
 }
@@ -140,3 +173,10 @@ type SnifferResultComposite interface {
 type SnifferIsProtoSubsetOf interface {
 	IsProtoSubsetOf(protocolName string) bool
 }
+
+// SnifferResultAttributes is implemented by results that carry details of the
+// protocol, such as the negotiated ALPN. They are added to the attributes of the
+// connection, for routing rules to match.
+type SnifferResultAttributes interface {
+	Attributes() map[string]string
+}
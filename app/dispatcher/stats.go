@@ -1,9 +1,14 @@
 package dispatcher
 
 import (
+	"context"
+	"time"
+
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/transport"
 )
 
 type SizeStatWriter struct {
@@ -23,3 +28,61 @@ func (w *SizeStatWriter) Close() error {
 func (w *SizeStatWriter) Interrupt() {
 	common.Interrupt(w.Writer)
 }
+
+// SizeStatReader counts the size of what is read from Reader.
+type SizeStatReader struct {
+	Counter stats.Counter
+	Reader  buf.Reader
+}
+
+func (r *SizeStatReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	mb, err := r.Reader.ReadMultiBuffer()
+	r.Counter.Add(int64(mb.Len()))
+	return mb, err
+}
+
+func (r *SizeStatReader) ReadMultiBufferTimeout(timeout time.Duration) (buf.MultiBuffer, error) {
+	reader, ok := r.Reader.(buf.TimeoutReader)
+	if !ok {
+		return r.ReadMultiBuffer()
+	}
+	mb, err := reader.ReadMultiBufferTimeout(timeout)
+	r.Counter.Add(int64(mb.Len()))
+	return mb, err
+}
+
+func (r *SizeStatReader) Interrupt() {
+	common.Interrupt(r.Reader)
+}
+
+// countRule counts a connection that hits the routing rule of ruleTag, and
+// its traffic on link, when the stats of rules are enabled. Counting the
+// traffic disables splice, as the spliced traffic passes by link.
+func (d *DefaultDispatcher) countRule(ctx context.Context, link *transport.Link, ruleTag string) {
+	if ruleTag == "" {
+		return
+	}
+	s := d.policy.ForSystem().Stats
+	name := "rule>>>" + ruleTag
+	if s.RuleMatched {
+		if c, _ := stats.GetOrRegisterCounter(d.stats, name+">>>matched"); c != nil {
+			c.Add(1)
+		}
+	}
+	if !s.RuleUplink && !s.RuleDownlink {
+		return
+	}
+	if s.RuleUplink {
+		if c, _ := stats.GetOrRegisterCounter(d.stats, name+">>>traffic>>>uplink"); c != nil {
+			link.Reader = &SizeStatReader{Counter: c, Reader: link.Reader}
+		}
+	}
+	if s.RuleDownlink {
+		if c, _ := stats.GetOrRegisterCounter(d.stats, name+">>>traffic>>>downlink"); c != nil {
+			link.Writer = &SizeStatWriter{Counter: c, Writer: link.Writer}
+		}
+	}
+	if inbound := session.InboundFromContext(ctx); inbound != nil {
+		inbound.CanSpliceCopy = 3
+	}
+}
@@ -0,0 +1,66 @@
+package dispatcher
+
+import (
+	"context"
+	"strings"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/transport"
+)
+
+// tenant returns the tenant of the connection of ctx, by its inbound and
+// user, and sets it to the inbound. It returns nil if there is none.
+func (d *DefaultDispatcher) tenant(ctx context.Context) *policy.Tenant {
+	inbound := session.InboundFromContext(ctx)
+	if inbound == nil {
+		return nil
+	}
+	var email string
+	if inbound.User != nil {
+		email = inbound.User.Email
+	}
+	tenants := d.policy.ForSystem().Tenants
+	t := tenants.ForConnection(inbound.Tag, email)
+	if t != nil {
+		inbound.Tenant = t.Name
+	}
+	return t
+}
+
+// resolveHosts points the target of ob to the address of its domain in the
+// hosts of t, if any. The domain is still routed on, as sniffed domains of
+// routeOnly are.
+func resolveHosts(ctx context.Context, ob *session.Outbound, t *policy.Tenant) {
+	if len(t.Hosts) == 0 || !ob.Target.Address.Family().IsDomain() {
+		return
+	}
+	domain := ob.Target.Address.Domain()
+	if address, ok := t.Hosts[strings.ToLower(domain)]; ok {
+		errors.LogInfo(ctx, "tenant ", t.Name, " points ", domain, " to ", address)
+		if !ob.RouteTarget.IsValid() {
+			ob.RouteTarget = ob.Target
+		}
+		ob.Target.Address = address
+	}
+}
+
+// rejectTenant closes link of a tenant with too many connections, and counts
+// the attempt in "tenant>>>[name]>>>connections>>>rejected".
+func (d *DefaultDispatcher) rejectTenant(ctx context.Context, link *transport.Link, t *policy.Tenant) {
+	errors.LogInfo(ctx, "tenant ", t.Name, " has ", t.MaxConnections, " connections already")
+	if c, _ := stats.GetOrRegisterCounter(d.stats, t.StatName("connections>>>rejected")); c != nil {
+		c.Add(1)
+	}
+	if accessMessage := log.AccessMessageFromContext(ctx); accessMessage != nil {
+		accessMessage.Status = log.AccessRejected
+		accessMessage.Reason = "tenant connections"
+		log.Record(accessMessage)
+	}
+	common.Close(link.Writer)
+	common.Interrupt(link.Reader)
+}
@@ -0,0 +1,203 @@
+package dispatcher
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/transport"
+)
+
+// udpCounters are the counters of UDP flows, in all or to a destination.
+type udpCounters struct {
+	// flows is set to the number of the active flows, kept in active, as it
+	// can be reset. The counters of a destination are unregistered once it
+	// has none.
+	flows           stats.Counter
+	active          int64
+	uplinkPackets   stats.Counter
+	uplinkTraffic   stats.Counter
+	downlinkPackets stats.Counter
+	downlinkTraffic stats.Counter
+}
+
+var udpCounterNames = []string{"flows", "packets>>>uplink", "traffic>>>uplink", "packets>>>downlink", "traffic>>>downlink"}
+
+func registerUDPCounters(m stats.Manager, name string) *udpCounters {
+	var counters [5]stats.Counter
+	for i, n := range udpCounterNames {
+		c, err := stats.GetOrRegisterCounter(m, name+">>>"+n)
+		if err != nil {
+			return nil
+		}
+		counters[i] = c
+	}
+	return &udpCounters{
+		flows:           counters[0],
+		uplinkPackets:   counters[1],
+		uplinkTraffic:   counters[2],
+		downlinkPackets: counters[3],
+		downlinkTraffic: counters[4],
+	}
+}
+
+func (c *udpCounters) addFlow(delta int64) int64 {
+	c.active += delta
+	c.flows.Set(c.active)
+	return c.active
+}
+
+func (c *udpCounters) add(uplink bool, mb buf.MultiBuffer) {
+	if uplink {
+		c.uplinkPackets.Add(int64(len(mb)))
+		c.uplinkTraffic.Add(int64(mb.Len()))
+	} else {
+		c.downlinkPackets.Add(int64(len(mb)))
+		c.downlinkTraffic.Add(int64(mb.Len()))
+	}
+}
+
+// udpStats keeps the counters of the UDP flows of a dispatcher, named
+// udp>>>all>>>... for all and udp>>>DEST>>>... for each destination.
+type udpStats struct {
+	access sync.Mutex
+	all    *udpCounters
+	dests  map[string]*udpCounters
+}
+
+// countUDP counts the flow of link to destination, if UDP, when the stats of
+// UDP flows are enabled.
+func (d *DefaultDispatcher) countUDP(ctx context.Context, link *transport.Link, destination net.Destination) {
+	if destination.Network != net.Network_UDP || !d.policy.ForSystem().Stats.UDPFlows {
+		return
+	}
+	name := "udp>>>" + destination.NetAddr()
+
+	s := &d.udpStats
+	s.access.Lock()
+	if s.all == nil {
+		s.all = registerUDPCounters(d.stats, "udp>>>all")
+		s.dests = make(map[string]*udpCounters)
+	}
+	dest := s.dests[name]
+	if dest == nil {
+		dest = registerUDPCounters(d.stats, name)
+		s.dests[name] = dest
+	}
+	if s.all == nil || dest == nil {
+		s.access.Unlock()
+		return
+	}
+	s.all.addFlow(1)
+	dest.addFlow(1)
+	s.access.Unlock()
+
+	f := &udpFlow{
+		counters: []*udpCounters{s.all, dest},
+		done: func() {
+			s.access.Lock()
+			defer s.access.Unlock()
+			s.all.addFlow(-1)
+			if dest.addFlow(-1) == 0 && s.dests[name] == dest {
+				delete(s.dests, name)
+				for _, n := range udpCounterNames {
+					d.stats.UnregisterCounter(name + ">>>" + n)
+				}
+			}
+		},
+	}
+	link.Reader = &udpFlowReader{reader: link.Reader, flow: f}
+	link.Writer = &udpFlowWriter{writer: link.Writer, flow: f}
+}
+
+// udpFlow counts the packets of a flow, until done with both directions.
+type udpFlow struct {
+	counters []*udpCounters
+	done     func()
+
+	access       sync.Mutex
+	uplinkDone   bool
+	downlinkDone bool
+}
+
+func (f *udpFlow) add(uplink bool, mb buf.MultiBuffer) {
+	if mb.IsEmpty() {
+		return
+	}
+	for _, c := range f.counters {
+		c.add(uplink, mb)
+	}
+}
+
+func (f *udpFlow) finish(uplink bool) {
+	f.access.Lock()
+	defer f.access.Unlock()
+
+	if f.uplinkDone && f.downlinkDone {
+		return
+	}
+	if uplink {
+		f.uplinkDone = true
+	} else {
+		f.downlinkDone = true
+	}
+	if f.uplinkDone && f.downlinkDone {
+		f.done()
+	}
+}
+
+type udpFlowReader struct {
+	reader buf.Reader
+	flow   *udpFlow
+}
+
+func (r *udpFlowReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	mb, err := r.reader.ReadMultiBuffer()
+	r.flow.add(true, mb)
+	if err != nil {
+		r.flow.finish(true)
+	}
+	return mb, err
+}
+
+func (r *udpFlowReader) ReadMultiBufferTimeout(timeout time.Duration) (buf.MultiBuffer, error) {
+	reader, ok := r.reader.(buf.TimeoutReader)
+	if !ok {
+		return r.ReadMultiBuffer()
+	}
+	mb, err := reader.ReadMultiBufferTimeout(timeout)
+	r.flow.add(true, mb)
+	if err != nil && err != buf.ErrReadTimeout {
+		r.flow.finish(true)
+	}
+	return mb, err
+}
+
+func (r *udpFlowReader) Interrupt() {
+	common.Interrupt(r.reader)
+	r.flow.finish(true)
+}
+
+type udpFlowWriter struct {
+	writer buf.Writer
+	flow   *udpFlow
+}
+
+func (w *udpFlowWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	w.flow.add(false, mb)
+	return w.writer.WriteMultiBuffer(mb)
+}
+
+func (w *udpFlowWriter) Close() error {
+	w.flow.finish(false)
+	return common.Close(w.writer)
+}
+
+func (w *udpFlowWriter) Interrupt() {
+	common.Interrupt(w.writer)
+	w.flow.finish(false)
+}
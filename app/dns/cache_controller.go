@@ -10,6 +10,7 @@ import (
 	"github.com/xtls/xray-core/common/task"
 	dns_feature "github.com/xtls/xray-core/features/dns"
 	"golang.org/x/net/dns/dnsmessage"
+	"slices"
 	"sync"
 	"time"
 )
@@ -167,6 +168,29 @@ func (c *CacheController) findIPsForDomain(domain string, option dns_feature.IPO
 	return nil, rTTL, errors.Combine(errs...)
 }
 
+// findCNAMEsForDomain returns the CNAME chain of domain in the records not
+// expired, of A then of AAAA.
+func (c *CacheController) findCNAMEsForDomain(domain string) []string {
+	c.RLock()
+	defer c.RUnlock()
+	record, found := c.ips[domain]
+	if !found {
+		return nil
+	}
+	var cnames []string
+	for _, r := range []*IPRecord{record.A, record.AAAA} {
+		if r == nil || time.Until(r.Expire) <= 0 {
+			continue
+		}
+		for _, name := range r.CNAME {
+			if !slices.Contains(cnames, name) {
+				cnames = append(cnames, name)
+			}
+		}
+	}
+	return cnames
+}
+
 func (c *CacheController) registerSubscribers(domain string, option dns_feature.IPOption) (sub4 *pubsub.Subscriber, sub6 *pubsub.Subscriber) {
 	// ipv4 and ipv6 belong to different subscription groups
 	if option.IPv4Enable {
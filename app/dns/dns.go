@@ -184,10 +184,17 @@ func (s *DNS) IsOwnLink(ctx context.Context) bool {
 
 // LookupIP implements dns.Client.
 func (s *DNS) LookupIP(domain string, option dns.IPOption) ([]net.IP, uint32, error) {
+	ips, _, ttl, err := s.LookupIPWithCNAME(domain, option)
+	return ips, ttl, err
+}
+
+// LookupIPWithCNAME implements dns.CNAMEClient. A domain replaced in the
+// static hosts is the first name of its chain.
+func (s *DNS) LookupIPWithCNAME(domain string, option dns.IPOption) ([]net.IP, []string, uint32, error) {
 	// Normalize the FQDN form query
 	domain = strings.TrimSuffix(domain, ".")
 	if domain == "" {
-		return nil, 0, errors.New("empty domain name")
+		return nil, nil, 0, errors.New("empty domain name")
 	}
 
 	if s.checkSystem {
@@ -200,30 +207,32 @@ func (s *DNS) LookupIP(domain string, option dns.IPOption) ([]net.IP, uint32, er
 	}
 
 	if !option.IPv4Enable && !option.IPv6Enable {
-		return nil, 0, dns.ErrEmptyResponse
+		return nil, nil, 0, dns.ErrEmptyResponse
 	}
 
+	var cnames []string
 	// Static host lookup
 	switch addrs, err := s.hosts.Lookup(domain, option); {
 	case err != nil:
 		if go_errors.Is(err, dns.ErrEmptyResponse) {
-			return nil, 0, dns.ErrEmptyResponse
+			return nil, nil, 0, dns.ErrEmptyResponse
 		}
-		return nil, 0, errors.New("returning nil for domain ", domain).Base(err)
+		return nil, nil, 0, errors.New("returning nil for domain ", domain).Base(err)
 	case addrs == nil: // Domain not recorded in static host
 		break
 	case len(addrs) == 0: // Domain recorded, but no valid IP returned (e.g. IPv4 address with only IPv6 enabled)
-		return nil, 0, dns.ErrEmptyResponse
+		return nil, nil, 0, dns.ErrEmptyResponse
 	case len(addrs) == 1 && addrs[0].Family().IsDomain(): // Domain replacement
 		errors.LogInfo(s.ctx, "domain replaced: ", domain, " -> ", addrs[0].Domain())
 		domain = addrs[0].Domain()
+		cnames = append(cnames, domain)
 	default: // Successfully found ip records in static host
 		errors.LogInfo(s.ctx, "returning ", len(addrs), " IP(s) for domain ", domain, " -> ", addrs)
 		ips, err := toNetIP(addrs)
 		if err != nil {
-			return nil, 0, err
+			return nil, nil, 0, err
 		}
-		return ips, 10, nil // Hosts ttl is 10
+		return ips, nil, 10, nil // Hosts ttl is 10
 	}
 
 	// Name servers lookup
@@ -240,7 +249,7 @@ func (s *DNS) LookupIP(domain string, option dns.IPOption) ([]net.IP, uint32, er
 			if ttl == 0 {
 				ttl = 1
 			}
-			return ips, ttl, nil
+			return ips, append(cnames, client.cnames(domain)...), ttl, nil
 		}
 
 		errors.LogInfoInner(s.ctx, err, "failed to lookup ip for domain ", domain, " at server ", client.Name())
@@ -259,13 +268,13 @@ func (s *DNS) LookupIP(domain string, option dns.IPOption) ([]net.IP, uint32, er
 		err0 := errs[0]
 		if errors.AllEqual(err0, allErrs) {
 			if go_errors.Is(err0, dns.ErrEmptyResponse) {
-				return nil, 0, dns.ErrEmptyResponse
+				return nil, nil, 0, dns.ErrEmptyResponse
 			}
-			return nil, 0, errors.New("returning nil for domain ", domain).Base(err0)
+			return nil, nil, 0, errors.New("returning nil for domain ", domain).Base(err0)
 		}
-		return nil, 0, errors.New("returning nil for domain ", domain).Base(allErrs)
+		return nil, nil, 0, errors.New("returning nil for domain ", domain).Base(allErrs)
 	}
-	return nil, 0, dns.ErrEmptyResponse
+	return nil, nil, 0, dns.ErrEmptyResponse
 }
 
 func (s *DNS) sortClients(domain string) []*Client {
@@ -3,6 +3,7 @@ package dns
 import (
 	"context"
 	"encoding/binary"
+	"maps"
 	"strings"
 	"time"
 
@@ -36,6 +37,8 @@ type IPRecord struct {
 	Expire    time.Time
 	RCode     dnsmessage.RCode
 	RawHeader *dnsmessage.Header
+	// CNAME is the names the CNAME records of the answer lead to, in order.
+	CNAME []string
 }
 
 func (r *IPRecord) getIPs() ([]net.IP, uint32, error) {
@@ -233,6 +236,13 @@ L:
 			if len(newIP) == net.IPv6len {
 				ipRecord.IP = append(ipRecord.IP, newIP)
 			}
+		case dnsmessage.TypeCNAME:
+			ans, err := parser.CNAMEResource()
+			if err != nil {
+				errors.LogInfoInner(context.Background(), err, "failed to parse CNAME record for domain: ", ah.Name)
+				break L
+			}
+			ipRecord.CNAME = append(ipRecord.CNAME, strings.ToLower(strings.TrimSuffix(ans.CNAME.String(), ".")))
 		default:
 			if err := parser.SkipAnswer(); err != nil {
 				errors.LogInfoInner(context.Background(), err, "failed to skip answer")
@@ -246,12 +256,27 @@ L:
 }
 
 // toDnsContext create a new background context with parent inbound, session and dns log
-func toDnsContext(ctx context.Context, addr string) context.Context {
+// toDnsContext returns the context the queries to addr are dispatched in,
+// sent through the outbound of outboundTag if it is not empty.
+func toDnsContext(ctx context.Context, addr string, outboundTag string) context.Context {
 	dnsCtx := core.ToBackgroundDetachedContext(ctx)
 	if inbound := session.InboundFromContext(ctx); inbound != nil {
 		dnsCtx = session.ContextWithInbound(dnsCtx, inbound)
 	}
-	dnsCtx = session.ContextWithContent(dnsCtx, session.ContentFromContext(ctx))
+	content := session.ContentFromContext(ctx)
+	if outboundTag != "" {
+		// The content may be of the query, so the tag is set on a copy.
+		forced := session.Content{}
+		if content != nil {
+			forced = *content
+			forced.Attributes = maps.Clone(content.Attributes)
+		}
+		content = &forced
+	}
+	dnsCtx = session.ContextWithContent(dnsCtx, content)
+	if outboundTag != "" {
+		dnsCtx = session.SetForcedOutboundTagToContext(dnsCtx, outboundTag)
+	}
 	dnsCtx = log.ContextWithAccessMessage(dnsCtx, &log.AccessMessage{
 		From:   "DNS",
 		To:     addr,
@@ -2,6 +2,7 @@ package fakedns
 
 import (
 	"context"
+	"encoding/json"
 	"math"
 	"math/big"
 	gonet "net"
@@ -21,6 +22,15 @@ type Holder struct {
 	mu         *sync.Mutex
 
 	config *FakeDnsPool
+	// imported is the state imported before the holder starts.
+	imported []poolState
+}
+
+// poolState is the state of a pool handed over to the process upgrading this
+// one, with the mappings from the least recently used.
+type poolState struct {
+	IPPool   string      `json:"ipPool"`
+	Mappings [][2]string `json:"mappings"`
 }
 
 func (fkdns *Holder) IsIPInIPPool(ip net.Address) bool {
@@ -44,11 +54,68 @@ func (*Holder) Type() interface{} {
 
 func (fkdns *Holder) Start() error {
 	if fkdns.config != nil && fkdns.config.IpPool != "" && fkdns.config.LruSize != 0 {
-		return fkdns.initializeFromConfig()
+		if err := fkdns.initializeFromConfig(); err != nil {
+			return err
+		}
+		fkdns.restore(fkdns.imported)
+		fkdns.imported = nil
+		return nil
 	}
 	return errors.New("invalid fakeDNS setting")
 }
 
+// state returns the state of the pool, or false if it is not started.
+func (fkdns *Holder) state() (poolState, bool) {
+	if fkdns.domainToIP == nil {
+		return poolState{}, false
+	}
+	state := poolState{IPPool: fkdns.ipRange.String()}
+	fkdns.domainToIP.Range(func(key, value interface{}) {
+		state.Mappings = append(state.Mappings, [2]string{key.(string), value.(net.Address).String()})
+	})
+	return state, true
+}
+
+// restore puts the mappings of the state of the same pool in states.
+func (fkdns *Holder) restore(states []poolState) {
+	for _, state := range states {
+		if state.IPPool != fkdns.ipRange.String() {
+			continue
+		}
+		fkdns.mu.Lock()
+		for _, m := range state.Mappings {
+			if ip := net.ParseAddress(m[1]); fkdns.IsIPInIPPool(ip) {
+				fkdns.domainToIP.Put(m[0], ip)
+			}
+		}
+		fkdns.mu.Unlock()
+	}
+}
+
+// ExportState implements features.Stateful.
+func (fkdns *Holder) ExportState() ([]byte, error) {
+	var states []poolState
+	if state, ok := fkdns.state(); ok {
+		states = append(states, state)
+	}
+	return json.Marshal(states)
+}
+
+// ImportState implements features.Stateful. The mappings of the same pool are
+// restored, those of other pools are ignored.
+func (fkdns *Holder) ImportState(b []byte) error {
+	var states []poolState
+	if err := json.Unmarshal(b, &states); err != nil {
+		return err
+	}
+	if fkdns.domainToIP != nil {
+		fkdns.restore(states)
+	} else {
+		fkdns.imported = states
+	}
+	return nil
+}
+
 func (fkdns *Holder) Close() error {
 	fkdns.domainToIP = nil
 	fkdns.ipRange = nil
@@ -71,7 +138,7 @@ func NewFakeDNSHolder() (*Holder, error) {
 }
 
 func NewFakeDNSHolderConfigOnly(conf *FakeDnsPool) (*Holder, error) {
-	return &Holder{nil, nil, nil, conf}, nil
+	return &Holder{nil, nil, nil, conf, nil}, nil
 }
 
 func (fkdns *Holder) initializeFromConfig() error {
@@ -202,6 +269,27 @@ func (h *HolderMulti) Start() error {
 	return nil
 }
 
+// ExportState implements features.Stateful.
+func (h *HolderMulti) ExportState() ([]byte, error) {
+	var states []poolState
+	for _, v := range h.holders {
+		if state, ok := v.state(); ok {
+			states = append(states, state)
+		}
+	}
+	return json.Marshal(states)
+}
+
+// ImportState implements features.Stateful.
+func (h *HolderMulti) ImportState(b []byte) error {
+	for _, v := range h.holders {
+		if err := v.ImportState(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (h *HolderMulti) Close() error {
 	for _, v := range h.holders {
 		if err := v.Close(); err != nil {
@@ -41,7 +41,47 @@ type Client struct {
 }
 
 // NewServer creates a name server object according to the network destination url.
-func NewServer(ctx context.Context, dest net.Destination, dispatcher routing.Dispatcher, disableCache bool, clientIP net.IP) (Server, error) {
+func NewServer(ctx context.Context, dest net.Destination, dispatcher routing.Dispatcher, disableCache bool, clientIP net.IP, fingerprint string, outboundTag string) (Server, error) {
+	server, err := newServer(ctx, dest, dispatcher, disableCache, clientIP, fingerprint, outboundTag)
+	if err == nil && !disableCache {
+		shareCache(core.ResourcesFromContext(ctx), server, clientIP, outboundTag)
+	}
+	return server, err
+}
+
+// shareCache makes server use the cache of the same name server in resources,
+// so that instances sharing resources share the answers they get.
+func shareCache(resources *core.Resources, server Server, clientIP net.IP, outboundTag string) {
+	if resources == nil {
+		return
+	}
+	cache := cacheOf(server)
+	if cache == nil {
+		return
+	}
+	own := *cache
+	shared, _ := resources.Load("dns.cache:"+own.name+":"+clientIP.String()+":"+outboundTag, func() (interface{}, error) {
+		return own, nil
+	})
+	*cache = shared.(*CacheController)
+}
+
+// cacheOf returns where the cache of server is kept, or nil if it has none.
+func cacheOf(server Server) **CacheController {
+	switch s := server.(type) {
+	case *ClassicNameServer:
+		return &s.cacheController
+	case *DoHNameServer:
+		return &s.cacheController
+	case *QUICNameServer:
+		return &s.cacheController
+	case *TCPNameServer:
+		return &s.cacheController
+	}
+	return nil
+}
+
+func newServer(ctx context.Context, dest net.Destination, dispatcher routing.Dispatcher, disableCache bool, clientIP net.IP, fingerprint string, outboundTag string) (Server, error) {
 	if address := dest.Address; address.Family().IsDomain() {
 		u, err := url.Parse(address.Domain())
 		if err != nil {
@@ -51,17 +91,17 @@ func NewServer(ctx context.Context, dest net.Destination, dispatcher routing.Dis
 		case strings.EqualFold(u.String(), "localhost"):
 			return NewLocalNameServer(), nil
 		case strings.EqualFold(u.Scheme, "https"): // DNS-over-HTTPS Remote mode
-			return NewDoHNameServer(u, dispatcher, false, disableCache, clientIP), nil
+			return NewDoHNameServer(u, dispatcher, false, disableCache, clientIP, fingerprint, outboundTag), nil
 		case strings.EqualFold(u.Scheme, "h2c"): // DNS-over-HTTPS h2c Remote mode
-			return NewDoHNameServer(u, dispatcher, true, disableCache, clientIP), nil
+			return NewDoHNameServer(u, dispatcher, true, disableCache, clientIP, fingerprint, outboundTag), nil
 		case strings.EqualFold(u.Scheme, "https+local"): // DNS-over-HTTPS Local mode
-			return NewDoHNameServer(u, nil, false, disableCache, clientIP), nil
+			return NewDoHNameServer(u, nil, false, disableCache, clientIP, fingerprint, ""), nil
 		case strings.EqualFold(u.Scheme, "h2c+local"): // DNS-over-HTTPS h2c Local mode
-			return NewDoHNameServer(u, nil, true, disableCache, clientIP), nil
+			return NewDoHNameServer(u, nil, true, disableCache, clientIP, fingerprint, ""), nil
 		case strings.EqualFold(u.Scheme, "quic+local"): // DNS-over-QUIC Local mode
 			return NewQUICNameServer(u, disableCache, clientIP)
 		case strings.EqualFold(u.Scheme, "tcp"): // DNS-over-TCP Remote mode
-			return NewTCPNameServer(u, dispatcher, disableCache, clientIP)
+			return NewTCPNameServer(u, dispatcher, disableCache, clientIP, outboundTag)
 		case strings.EqualFold(u.Scheme, "tcp+local"): // DNS-over-TCP Local mode
 			return NewTCPLocalNameServer(u, disableCache, clientIP)
 		case strings.EqualFold(u.String(), "fakedns"):
@@ -79,7 +119,7 @@ func NewServer(ctx context.Context, dest net.Destination, dispatcher routing.Dis
 		dest.Network = net.Network_UDP
 	}
 	if dest.Network == net.Network_UDP { // UDP classic DNS mode
-		return NewClassicNameServer(dest, dispatcher, disableCache, clientIP), nil
+		return NewClassicNameServer(dest, dispatcher, disableCache, clientIP, outboundTag), nil
 	}
 	return nil, errors.New("No available name server could be created from ", dest).AtWarning()
 }
@@ -99,7 +139,7 @@ func NewClient(
 
 	err := core.RequireFeatures(ctx, func(dispatcher routing.Dispatcher) error {
 		// Create a new server for each client for now
-		server, err := NewServer(ctx, ns.Address.AsDestination(), dispatcher, disableCache, clientIP)
+		server, err := NewServer(ctx, ns.Address.AsDestination(), dispatcher, disableCache, clientIP, ns.Fingerprint, ns.OutboundTag)
 		if err != nil {
 			return errors.New("failed to create nameserver").Base(err).AtWarning()
 		}
@@ -215,6 +255,15 @@ func (c *Client) IsFinalQuery() bool {
 	return c.finalQuery
 }
 
+// cnames returns the CNAME chain of domain as the server last answered it.
+func (c *Client) cnames(domain string) []string {
+	cache := cacheOf(c.server)
+	if cache == nil {
+		return nil
+	}
+	return (*cache).findCNAMEsForDomain(Fqdn(domain))
+}
+
 // QueryIP sends DNS query to the name server with the client's IP.
 func (c *Client) QueryIP(ctx context.Context, domain string, option dns.IPOption) ([]net.IP, uint32, error) {
 	if c.checkSystem {
@@ -36,6 +36,7 @@ func NewTCPNameServer(
 	dispatcher routing.Dispatcher,
 	disableCache bool,
 	clientIP net.IP,
+	outboundTag string,
 ) (*TCPNameServer, error) {
 	s, err := baseTCPNameServer(url, "TCP", disableCache, clientIP)
 	if err != nil {
@@ -43,7 +44,7 @@ func NewTCPNameServer(
 	}
 
 	s.dial = func(ctx context.Context) (net.Conn, error) {
-		link, err := dispatcher.Dispatch(toDnsContext(ctx, s.destination.String()), *s.destination)
+		link, err := dispatcher.Dispatch(toDnsContext(ctx, s.destination.String(), outboundTag), *s.destination)
 		if err != nil {
 			return nil, err
 		}
@@ -31,6 +31,7 @@ type ClassicNameServer struct {
 	requestsCleanup *task.Periodic
 	reqID           uint32
 	clientIP        net.IP
+	outboundTag     string
 }
 
 type udpDnsRequest struct {
@@ -39,7 +40,7 @@ type udpDnsRequest struct {
 }
 
 // NewClassicNameServer creates udp server object for remote resolving.
-func NewClassicNameServer(address net.Destination, dispatcher routing.Dispatcher, disableCache bool, clientIP net.IP) *ClassicNameServer {
+func NewClassicNameServer(address net.Destination, dispatcher routing.Dispatcher, disableCache bool, clientIP net.IP, outboundTag string) *ClassicNameServer {
 	// default to 53 if unspecific
 	if address.Port == 0 {
 		address.Port = net.Port(53)
@@ -50,6 +51,7 @@ func NewClassicNameServer(address net.Destination, dispatcher routing.Dispatcher
 		address:         &address,
 		requests:        make(map[uint16]*udpDnsRequest),
 		clientIP:        clientIP,
+		outboundTag:     outboundTag,
 	}
 	s.requestsCleanup = &task.Periodic{
 		Interval: time.Minute,
@@ -125,7 +127,7 @@ func (s *ClassicNameServer) HandleResponse(ctx context.Context, packet *udp_prot
 			newReq.msg = &newMsg
 			s.addPendingRequest(&newReq)
 			b, _ := dns.PackMessage(newReq.msg)
-			s.udpServer.Dispatch(toDnsContext(newReq.ctx, s.address.String()), *s.address, b)
+			s.udpServer.Dispatch(toDnsContext(newReq.ctx, s.address.String(), s.outboundTag), *s.address, b)
 			return
 		}
 	}
@@ -158,7 +160,7 @@ func (s *ClassicNameServer) sendQuery(ctx context.Context, _ chan<- error, domai
 		}
 		s.addPendingRequest(udpReq)
 		b, _ := dns.PackMessage(req.msg)
-		s.udpServer.Dispatch(toDnsContext(ctx, s.address.String()), *s.address, b)
+		s.udpServer.Dispatch(toDnsContext(ctx, s.address.String(), s.outboundTag), *s.address, b)
 	}
 }
 
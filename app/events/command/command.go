@@ -0,0 +1,46 @@
+package command
+
+import (
+	"context"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/events"
+	"google.golang.org/grpc"
+)
+
+type service struct {
+	UnimplementedEventServiceServer
+}
+
+func (s *service) SubscribeEvents(request *SubscribeEventsRequest, stream EventService_SubscribeEventsServer) error {
+	subscriber := events.Subscribe()
+	defer subscriber.Close()
+	for {
+		select {
+		case value := <-subscriber.Wait():
+			e := value.(*events.Event)
+			if !events.Match(request.Type, e.Type) {
+				continue
+			}
+			if err := stream.Send(&Event{
+				Type: e.Type,
+				Time: e.Time.UnixMilli(),
+				Data: e.Data,
+			}); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+func (s *service) Register(server *grpc.Server) {
+	RegisterEventServiceServer(server, s)
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, cfg interface{}) (interface{}, error) {
+		return &service{}, nil
+	}))
+}
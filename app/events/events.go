@@ -0,0 +1,217 @@
+// Package events sends the events of the process to the configured sinks,
+// webhooks and commands.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/events"
+	"github.com/xtls/xray-core/common/signal/done"
+	"github.com/xtls/xray-core/common/signal/pubsub"
+)
+
+const (
+	// queueSize is how many events a sink may fall behind, before further
+	// ones are dropped.
+	queueSize = 64
+	// closeTimeout is how long Close waits for the sinks to send the events
+	// left, such as core.stopped.
+	closeTimeout = 5 * time.Second
+)
+
+// sink sends the events of its types, one at a time.
+type sink struct {
+	name  string
+	types []string
+	send  func(*events.Event, []byte) error
+	queue chan *events.Event
+}
+
+func (s *sink) run(wg *sync.WaitGroup) {
+	defer wg.Done()
+	for e := range s.queue {
+		body, err := json.Marshal(e)
+		common.Must(err)
+		if err := s.send(e, body); err != nil {
+			errors.LogWarningInner(context.Background(), err, "failed to send event ", e.Type, " to ", s.name)
+		}
+	}
+}
+
+// Sinks is the feature that sends events to the sinks.
+type Sinks struct {
+	sinks      []*sink
+	subscriber *pubsub.Subscriber
+	done       *done.Instance
+	stopped    chan struct{}
+	wg         sync.WaitGroup
+}
+
+// New creates Sinks from config.
+func New(ctx context.Context, config *Config) (*Sinks, error) {
+	s := &Sinks{}
+	client := &http.Client{Timeout: 10 * time.Second}
+	for _, w := range config.Webhook {
+		if w.Url == "" {
+			return nil, errors.New("webhook without URL")
+		}
+		s.sinks = append(s.sinks, &sink{
+			name:  w.Url,
+			types: w.Type,
+			send:  newWebhookSender(client, w),
+		})
+	}
+	for _, e := range config.Exec {
+		if len(e.Command) == 0 {
+			return nil, errors.New("exec hook without command")
+		}
+		s.sinks = append(s.sinks, &sink{
+			name:  e.Command[0],
+			types: e.Type,
+			send:  newExecSender(e),
+		})
+	}
+	return s, nil
+}
+
+func newWebhookSender(client *http.Client, config *Webhook) func(*events.Event, []byte) error {
+	return func(e *events.Event, body []byte) error {
+		var err error
+		for attempt := 0; attempt < 3; attempt++ {
+			if attempt > 0 {
+				time.Sleep(time.Duration(attempt) * time.Second)
+			}
+			var request *http.Request
+			request, err = http.NewRequest(http.MethodPost, config.Url, bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			request.Header.Set("Content-Type", "application/json")
+			for k, v := range config.Header {
+				request.Header.Set(k, v)
+			}
+			var response *http.Response
+			if response, err = client.Do(request); err != nil {
+				continue
+			}
+			response.Body.Close()
+			if response.StatusCode < 300 {
+				return nil
+			}
+			err = errors.New("webhook responded ", response.Status)
+			if response.StatusCode < 500 {
+				return err
+			}
+		}
+		return err
+	}
+}
+
+func newExecSender(config *Exec) func(*events.Event, []byte) error {
+	return func(e *events.Event, body []byte) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, config.Command[0], config.Command[1:]...)
+		cmd.Stdin = bytes.NewReader(body)
+		cmd.Env = append(os.Environ(), "XRAY_EVENT_TYPE="+e.Type)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return errors.New("command failed: ", string(bytes.TrimSpace(output))).Base(err)
+		}
+		return nil
+	}
+}
+
+// Type implements common.HasType.
+func (s *Sinks) Type() interface{} {
+	return (*Sinks)(nil)
+}
+
+// Start implements common.Runnable.
+func (s *Sinks) Start() error {
+	if len(s.sinks) == 0 {
+		return nil
+	}
+	s.subscriber = events.Subscribe()
+	s.done = done.New()
+	s.stopped = make(chan struct{})
+	for _, sink := range s.sinks {
+		sink.queue = make(chan *events.Event, queueSize)
+		s.wg.Add(1)
+		go sink.run(&s.wg)
+	}
+	go func() {
+		defer close(s.stopped)
+		for {
+			select {
+			case value := <-s.subscriber.Wait():
+				s.dispatch(value.(*events.Event))
+			case <-s.done.Wait():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *Sinks) dispatch(e *events.Event) {
+	for _, sink := range s.sinks {
+		if !events.Match(sink.types, e.Type) {
+			continue
+		}
+		select {
+		case sink.queue <- e:
+		default:
+			errors.LogWarning(context.Background(), "dropped event ", e.Type, " for ", sink.name, " falling behind")
+		}
+	}
+}
+
+// Close implements common.Closable. It sends the events left, for a while.
+func (s *Sinks) Close() error {
+	if s.subscriber == nil {
+		return nil
+	}
+	s.done.Close()
+	<-s.stopped
+	s.subscriber.Close()
+	for {
+		select {
+		case value := <-s.subscriber.Wait():
+			s.dispatch(value.(*events.Event))
+			continue
+		default:
+		}
+		break
+	}
+	for _, sink := range s.sinks {
+		close(sink.queue)
+	}
+
+	sent := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(sent)
+	}()
+	select {
+	case <-sent:
+	case <-time.After(closeTimeout):
+		errors.LogWarning(context.Background(), "events left unsent on close")
+	}
+	s.subscriber = nil
+	return nil
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		return New(ctx, config.(*Config))
+	}))
+}
@@ -0,0 +1,61 @@
+package command
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/xtls/xray-core/app/guard"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	core "github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/extension"
+	"google.golang.org/grpc"
+)
+
+type service struct {
+	UnimplementedGuardServiceServer
+
+	guard *guard.Guard
+}
+
+func (s *service) ListBans(ctx context.Context, request *ListBansRequest) (*ListBansResponse, error) {
+	bans := s.guard.Bans()
+	response := &ListBansResponse{Ban: make([]*Ban, 0, len(bans))}
+	for _, ban := range bans {
+		response.Ban = append(response.Ban, &Ban{
+			Ip:    ban.IP.String(),
+			Until: ban.Until.Unix(),
+		})
+	}
+	return response, nil
+}
+
+func (s *service) Unban(ctx context.Context, request *UnbanRequest) (*UnbanResponse, error) {
+	ip, err := netip.ParseAddr(request.Ip)
+	if err != nil {
+		return nil, errors.New("invalid IP: ", request.Ip).Base(err)
+	}
+	return &UnbanResponse{Banned: s.guard.Unban(ip)}, nil
+}
+
+func (s *service) Register(server *grpc.Server) {
+	RegisterGuardServiceServer(server, s)
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, cfg interface{}) (interface{}, error) {
+		s := core.MustFromContext(ctx)
+		sv := &service{}
+		err := s.RequireFeatures(func(g extension.Guard) error {
+			var ok bool
+			if sv.guard, ok = g.(*guard.Guard); !ok {
+				return errors.New("guard does not list bans")
+			}
+			return nil
+		}, false)
+		if err != nil {
+			return nil, err
+		}
+		return sv, nil
+	}))
+}
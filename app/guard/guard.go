@@ -0,0 +1,238 @@
+// Package guard bans the sources that fail to authenticate to the inbounds
+// too often, as fail2ban does from the logs, so that their connections and
+// packets are dropped for a while.
+package guard
+
+import (
+	"context"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/events"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/features/extension"
+)
+
+// Ban is a source banned until a time.
+type Ban struct {
+	IP    netip.Addr
+	Until time.Time
+}
+
+// Guard implements extension.Guard.
+type Guard struct {
+	maxFailures int
+	findTime    time.Duration
+	banTime     time.Duration
+	ignore      []netip.Prefix
+	forwarded   bool
+
+	access   sync.Mutex
+	failures map[netip.Addr][]time.Time
+	bans     map[netip.Addr]time.Time
+	cleanup  *task.Periodic
+}
+
+// New creates a Guard from config.
+func New(ctx context.Context, config *Config) (*Guard, error) {
+	g := &Guard{
+		maxFailures: int(config.MaxFailures),
+		findTime:    time.Duration(config.FindTime) * time.Second,
+		banTime:     time.Duration(config.BanTime) * time.Second,
+		forwarded:   config.TrustForwardedFor,
+		failures:    make(map[netip.Addr][]time.Time),
+		bans:        make(map[netip.Addr]time.Time),
+	}
+	if g.maxFailures <= 0 || g.findTime <= 0 || g.banTime <= 0 {
+		return nil, errors.New("guard needs max failures, find time and ban time")
+	}
+	for _, s := range config.Ignore {
+		prefix, err := parsePrefix(s)
+		if err != nil {
+			return nil, errors.New("invalid IP or CIDR to ignore: ", s).Base(err)
+		}
+		g.ignore = append(g.ignore, prefix)
+	}
+	g.cleanup = &task.Periodic{
+		Interval: time.Minute,
+		Execute:  g.clean,
+	}
+	return g, nil
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func addrOf(ip net.IP) (netip.Addr, bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	return addr.Unmap(), ok
+}
+
+func (g *Guard) ignored(addr netip.Addr) bool {
+	if addr.IsLoopback() {
+		return true
+	}
+	for _, prefix := range g.ignore {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Type implements common.HasType.
+func (g *Guard) Type() interface{} {
+	return extension.GuardType()
+}
+
+// Start implements common.Runnable.
+func (g *Guard) Start() error {
+	return g.cleanup.Start()
+}
+
+// Close implements common.Closable.
+func (g *Guard) Close() error {
+	return g.cleanup.Close()
+}
+
+// Failed implements extension.Guard. It bans ip on its max failures within
+// the find time.
+func (g *Guard) Failed(ip net.IP) {
+	addr, ok := addrOf(ip)
+	if !ok || g.ignored(addr) {
+		return
+	}
+	now := time.Now()
+
+	g.access.Lock()
+	if until, found := g.bans[addr]; found && now.Before(until) {
+		g.access.Unlock()
+		return
+	}
+	failures := g.failures[addr]
+	for len(failures) > 0 && now.Sub(failures[0]) > g.findTime {
+		failures = failures[1:]
+	}
+	failures = append(failures, now)
+	if len(failures) < g.maxFailures {
+		g.failures[addr] = failures
+		g.access.Unlock()
+		return
+	}
+	delete(g.failures, addr)
+	until := now.Add(g.banTime)
+	g.bans[addr] = until
+	g.access.Unlock()
+
+	errors.LogWarning(context.Background(), "banned ", addr, " for ", g.banTime, " after ", len(failures), " failed authentications")
+	events.Emit(events.SourceBanned, "ip", addr.String(), "failures", strconv.Itoa(len(failures)), "until", until.UTC().Format(time.RFC3339))
+}
+
+// Banned implements extension.Guard.
+func (g *Guard) Banned(ip net.IP) bool {
+	addr, ok := addrOf(ip)
+	if !ok {
+		return false
+	}
+	g.access.Lock()
+	defer g.access.Unlock()
+	until, found := g.bans[addr]
+	return found && time.Now().Before(until)
+}
+
+// TrustsForwarded implements extension.Guard.
+func (g *Guard) TrustsForwarded() bool {
+	return g.forwarded
+}
+
+// Ban implements extension.Guard. A ban until a later time is kept.
+func (g *Guard) Ban(ip net.IP, until time.Time) {
+	addr, ok := addrOf(ip)
+	if !ok {
+		return
+	}
+	g.access.Lock()
+	defer g.access.Unlock()
+	if !time.Now().Before(until) {
+		delete(g.bans, addr)
+		return
+	}
+	if until.After(g.bans[addr]) {
+		g.bans[addr] = until
+		delete(g.failures, addr)
+	}
+}
+
+// Bans returns the sources banned, sorted by address.
+func (g *Guard) Bans() []Ban {
+	now := time.Now()
+	g.access.Lock()
+	bans := make([]Ban, 0, len(g.bans))
+	for addr, until := range g.bans {
+		if now.Before(until) {
+			bans = append(bans, Ban{IP: addr, Until: until})
+		}
+	}
+	g.access.Unlock()
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].IP.Less(bans[j].IP)
+	})
+	return bans
+}
+
+// Unban lifts the ban of ip, and forgets its failures. It returns whether ip
+// was banned.
+func (g *Guard) Unban(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	g.access.Lock()
+	until, found := g.bans[ip]
+	delete(g.bans, ip)
+	delete(g.failures, ip)
+	g.access.Unlock()
+	banned := found && time.Now().Before(until)
+	if banned {
+		events.Emit(events.SourceUnbanned, "ip", ip.String())
+	}
+	return banned
+}
+
+// clean forgets the expired bans and failures.
+func (g *Guard) clean() error {
+	now := time.Now()
+	g.access.Lock()
+	defer g.access.Unlock()
+	for addr, until := range g.bans {
+		if !now.Before(until) {
+			delete(g.bans, addr)
+		}
+	}
+	for addr, failures := range g.failures {
+		if now.Sub(failures[len(failures)-1]) > g.findTime {
+			delete(g.failures, addr)
+		}
+	}
+	return nil
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		return New(ctx, config.(*Config))
+	}))
+}
@@ -0,0 +1,118 @@
+package log
+
+import (
+	"slices"
+	"sync/atomic"
+
+	"github.com/xtls/xray-core/app/router"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/common/net"
+)
+
+// accessFilter matches the access messages of any of its inbounds or
+// outbounds, or to any of its domains.
+type accessFilter struct {
+	inboundTags  []string
+	outboundTags []string
+	domains      *router.DomainMatcher
+}
+
+func newAccessFilter(config *AccessFilter) (*accessFilter, error) {
+	if config == nil {
+		return nil, nil
+	}
+	f := &accessFilter{
+		inboundTags:  config.InboundTags,
+		outboundTags: config.OutboundTags,
+	}
+	if len(config.Domains) > 0 {
+		matcher, err := router.NewMphMatcherGroup(config.Domains)
+		if err != nil {
+			return nil, errors.New("failed to build domains of access log filter").Base(err)
+		}
+		f.domains = matcher
+	}
+	return f, nil
+}
+
+func (f *accessFilter) match(msg *log.AccessMessage) bool {
+	if msg.InboundTag != "" && slices.Contains(f.inboundTags, msg.InboundTag) {
+		return true
+	}
+	if msg.OutboundTag != "" && slices.Contains(f.outboundTags, msg.OutboundTag) {
+		return true
+	}
+	if f.domains != nil {
+		if domain := accessDomain(msg.To); domain != "" && f.domains.ApplyDomain(domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// accessDomain returns the domain of the destination of an access message, if
+// any.
+func accessDomain(to interface{}) string {
+	var dest net.Destination
+	switch to := to.(type) {
+	case net.Destination:
+		dest = to
+	case *net.Destination:
+		dest = *to
+	case string:
+		d, err := net.ParseDestination(to)
+		if err != nil {
+			return ""
+		}
+		dest = d
+	default:
+		return ""
+	}
+	if dest.Address == nil || !dest.Address.Family().IsDomain() {
+		return ""
+	}
+	return dest.Address.Domain()
+}
+
+// accessSampler filters and samples the access messages to record.
+type accessSampler struct {
+	include *accessFilter
+	exclude *accessFilter
+	sample  uint64
+	count   atomic.Uint64
+}
+
+func newAccessSampler(config *Config) (*accessSampler, error) {
+	include, err := newAccessFilter(config.AccessInclude)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := newAccessFilter(config.AccessExclude)
+	if err != nil {
+		return nil, err
+	}
+	if include == nil && exclude == nil && config.AccessSample <= 1 {
+		return nil, nil
+	}
+	return &accessSampler{
+		include: include,
+		exclude: exclude,
+		sample:  uint64(config.AccessSample),
+	}, nil
+}
+
+// record returns whether msg is to be recorded. Messages are sampled among
+// the ones passing the filters.
+func (s *accessSampler) record(msg *log.AccessMessage) bool {
+	if s.include != nil && !s.include.match(msg) {
+		return false
+	}
+	if s.exclude != nil && s.exclude.match(msg) {
+		return false
+	}
+	if s.sample > 1 {
+		return s.count.Add(1)%s.sample == 1
+	}
+	return true
+}
@@ -20,14 +20,21 @@ type Instance struct {
 	errorLogger  log.Handler
 	active       bool
 	dns          bool
+	// access filters and samples the access messages, nil to record all.
+	access *accessSampler
 }
 
 // New creates a new log.Instance based on the given config.
 func New(ctx context.Context, config *Config) (*Instance, error) {
+	access, err := newAccessSampler(config)
+	if err != nil {
+		return nil, err
+	}
 	g := &Instance{
 		config: config,
 		active: false,
 		dns:    config.EnableDnsLog,
+		access: access,
 	}
 	log.RegisterHandler(g)
 
@@ -111,7 +118,7 @@ func (g *Instance) Handle(msg log.Message) {
 
 	switch msg := msg.(type) {
 	case *log.AccessMessage:
-		if g.accessLogger != nil {
+		if g.accessLogger != nil && (g.access == nil || g.access.record(msg)) {
 			g.accessLogger.Handle(Msg)
 		}
 	case *log.DNSLog:
@@ -2,14 +2,20 @@ package metrics
 
 import (
 	"context"
+	"crypto/subtle"
+	goerrors "errors"
 	"expvar"
+	gonet "net"
 	"net/http"
 	_ "net/http/pprof"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/xtls/xray-core/app/observatory"
 	"github.com/xtls/xray-core/app/stats"
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/bytespool"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/signal/done"
@@ -17,6 +23,7 @@ import (
 	"github.com/xtls/xray-core/features/extension"
 	"github.com/xtls/xray-core/features/outbound"
 	feature_stats "github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/transport/internet/tls"
 )
 
 type MetricsHandler struct {
@@ -25,6 +32,7 @@ type MetricsHandler struct {
 	observatory  extension.Observatory
 	tag          string
 	listen       string
+	token        string
 	tcpListener  net.Listener
 }
 
@@ -33,6 +41,7 @@ func NewMetricsHandler(ctx context.Context, config *Config) (*MetricsHandler, er
 	c := &MetricsHandler{
 		tag:    config.Tag,
 		listen: config.Listen,
+		token:  config.Token,
 	}
 	common.Must(core.RequireFeatures(ctx, func(om outbound.Manager, sm feature_stats.Manager) {
 		c.statsManager = sm
@@ -82,9 +91,33 @@ func NewMetricsHandler(ctx context.Context, config *Config) (*MetricsHandler, er
 		}
 		return resp
 	}))
+	expvar.Publish("bufferpools", expvar.Func(func() interface{} {
+		return bytespool.Stats()
+	}))
+	expvar.Publish("ocsp", expvar.Func(func() interface{} {
+		return tls.OCSPStats()
+	}))
 	return c, nil
 }
 
+// handler serves pprof and expvar, to the requests with the token if any.
+func (p *MetricsHandler) handler() http.Handler {
+	if p.token == "" {
+		return http.DefaultServeMux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(p.token)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		http.DefaultServeMux.ServeHTTP(w, r)
+	})
+}
+
 func (p *MetricsHandler) Type() interface{} {
 	return (*MetricsHandler)(nil)
 }
@@ -99,9 +132,12 @@ func (p *MetricsHandler) Start() error {
 		}
 		p.tcpListener = TCPlistener
 		errors.LogInfo(context.Background(), "Metrics server listening on ", p.listen)
+		if addr, ok := TCPlistener.Addr().(*net.TCPAddr); ok && !addr.IP.IsLoopback() && p.token == "" {
+			errors.LogWarning(context.Background(), "Metrics server on ", p.listen, " exposes pprof without a token")
+		}
 
 		go func() {
-			if err := http.Serve(TCPlistener, http.DefaultServeMux); err != nil {
+			if err := http.Serve(TCPlistener, p.handler()); err != nil && !goerrors.Is(err, gonet.ErrClosed) {
 				errors.LogErrorInner(context.Background(), err, "failed to start metrics server")
 			}
 		}()
@@ -113,7 +149,7 @@ func (p *MetricsHandler) Start() error {
 	}
 
 	go func() {
-		if err := http.Serve(listener, http.DefaultServeMux); err != nil {
+		if err := http.Serve(listener, p.handler()); err != nil {
 			errors.LogErrorInner(context.Background(), err, "failed to start metrics server")
 		}
 	}()
@@ -129,10 +165,25 @@ func (p *MetricsHandler) Start() error {
 }
 
 func (p *MetricsHandler) Close() error {
+	if p.tcpListener != nil {
+		return p.tcpListener.Close()
+	}
 	return nil
 }
 
 func init() {
+	start := time.Now()
+	expvar.Publish("runtime", expvar.Func(func() interface{} {
+		return map[string]interface{}{
+			"version":    core.Version(),
+			"go":         runtime.Version(),
+			"goroutines": runtime.NumGoroutine(),
+			"cpus":       runtime.NumCPU(),
+			"gomaxprocs": runtime.GOMAXPROCS(0),
+			"uptime":     int64(time.Since(start) / time.Second),
+		}
+	}))
+
 	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, cfg interface{}) (interface{}, error) {
 		return NewMetricsHandler(ctx, cfg.(*Config))
 	}))
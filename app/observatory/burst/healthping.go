@@ -3,12 +3,15 @@ package burst
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/xtls/xray-core/common/dice"
 	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/events"
 	"github.com/xtls/xray-core/features/routing"
 )
 
@@ -20,6 +23,17 @@ type HealthPingSettings struct {
 	SamplingCount int           `json:"sampling"`
 	Timeout       time.Duration `json:"timeout"`
 	HttpMethod    string        `json:"httpMethod"`
+	Concurrency   int           `json:"concurrency"`
+	Jitter        float64       `json:"jitter"`
+
+	IntervalOverrides []*HealthPingIntervalSettings `json:"intervalOverrides"`
+}
+
+// HealthPingIntervalSettings overrides the interval of the health checks of
+// the outbounds with tags of its prefixes
+type HealthPingIntervalSettings struct {
+	SubjectSelector []string      `json:"subjectSelector"`
+	Interval        time.Duration `json:"interval"`
 }
 
 // HealthPing is the health checker for balancers
@@ -29,6 +43,12 @@ type HealthPing struct {
 	access      sync.Mutex
 	ticker      *time.Ticker
 	tickerClose chan struct{}
+	// limiter limits the pings at the same time, nil if unlimited
+	limiter chan struct{}
+
+	scheduleAccess sync.Mutex
+	// scheduled closes the checks of each outbound scheduled
+	scheduled map[string]chan struct{}
 
 	Settings *HealthPingSettings
 	Results  map[string]*HealthPingRTTS
@@ -53,6 +73,17 @@ func NewHealthPing(ctx context.Context, dispatcher routing.Dispatcher, config *H
 			SamplingCount: int(config.SamplingCount),
 			Timeout:       time.Duration(config.Timeout),
 			HttpMethod:    httpMethod,
+			Concurrency:   int(config.Concurrency),
+			Jitter:        float64(config.Jitter),
+		}
+		for _, o := range config.IntervalOverrides {
+			if o.Interval <= 0 {
+				continue
+			}
+			settings.IntervalOverrides = append(settings.IntervalOverrides, &HealthPingIntervalSettings{
+				SubjectSelector: o.SubjectSelector,
+				Interval:        time.Duration(o.Interval),
+			})
 		}
 	}
 	if settings.Destination == "" {
@@ -75,9 +106,19 @@ func NewHealthPing(ctx context.Context, dispatcher routing.Dispatcher, config *H
 		// a larger timeout could possibly makes checks run longer
 		settings.Timeout = time.Duration(5) * time.Second
 	}
+	if settings.Jitter <= 0 {
+		settings.Jitter = 0.5
+	} else if settings.Jitter > 1 {
+		settings.Jitter = 1
+	}
+	var limiter chan struct{}
+	if settings.Concurrency > 0 {
+		limiter = make(chan struct{}, settings.Concurrency)
+	}
 	return &HealthPing{
 		ctx:        ctx,
 		dispatcher: dispatcher,
+		limiter:    limiter,
 		Settings:   settings,
 		Results:    nil,
 	}
@@ -88,31 +129,21 @@ func (h *HealthPing) StartScheduler(selector func() ([]string, error)) {
 	if h.ticker != nil {
 		return
 	}
-	interval := h.Settings.Interval * time.Duration(h.Settings.SamplingCount)
-	ticker := time.NewTicker(interval)
+	// Outbounds are selected every interval, and each one selected is checked
+	// on its own schedule, so that they are not checked all at once.
+	ticker := time.NewTicker(h.Settings.Interval)
 	tickerClose := make(chan struct{})
 	h.ticker = ticker
 	h.tickerClose = tickerClose
-	go func() {
-		tags, err := selector()
-		if err != nil {
-			errors.LogWarning(h.ctx, "error select outbounds for initial health check: ", err)
-			return
-		}
-		h.Check(tags)
-	}()
-
 	go func() {
 		for {
-			go func() {
-				tags, err := selector()
-				if err != nil {
-					errors.LogWarning(h.ctx, "error select outbounds for scheduled health check: ", err)
-					return
-				}
-				h.doCheck(tags, interval, h.Settings.SamplingCount)
+			tags, err := selector()
+			if err != nil {
+				errors.LogWarning(h.ctx, "error select outbounds for scheduled health check: ", err)
+			} else {
+				h.schedule(tags, tickerClose)
 				h.Cleanup(tags)
-			}()
+			}
 			select {
 			case <-ticker.C:
 				continue
@@ -123,6 +154,75 @@ func (h *HealthPing) StartScheduler(selector func() ([]string, error)) {
 	}()
 }
 
+// schedule starts the checks of the tags not scheduled yet, and stops those
+// of the tags not any more.
+func (h *HealthPing) schedule(tags []string, closed chan struct{}) {
+	h.scheduleAccess.Lock()
+	defer h.scheduleAccess.Unlock()
+	if h.scheduled == nil {
+		h.scheduled = make(map[string]chan struct{})
+	}
+	for tag, stop := range h.scheduled {
+		if !slices.Contains(tags, tag) {
+			close(stop)
+			delete(h.scheduled, tag)
+		}
+	}
+	for _, tag := range tags {
+		if _, found := h.scheduled[tag]; found {
+			continue
+		}
+		stop := make(chan struct{})
+		h.scheduled[tag] = stop
+		go h.run(tag, stop, closed)
+	}
+}
+
+// run checks tag at once and then every interval of it, until stopped or the
+// scheduler is closed.
+func (h *HealthPing) run(tag string, stop, closed chan struct{}) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-stop:
+			return
+		case <-closed:
+			return
+		}
+		if rtt := h.ping(tag); rtt > 0 {
+			// should not put results when network is down
+			h.PutResult(tag, rtt)
+		}
+		timer.Reset(h.nextDelay(tag))
+	}
+}
+
+// intervalOf returns the interval of the checks of tag, of the first override
+// matching if any.
+func (h *HealthPing) intervalOf(tag string) time.Duration {
+	for _, o := range h.Settings.IntervalOverrides {
+		for _, prefix := range o.SubjectSelector {
+			if strings.HasPrefix(tag, prefix) {
+				return o.Interval
+			}
+		}
+	}
+	return h.Settings.Interval
+}
+
+// nextDelay returns the delay of the next check of tag, which is its interval
+// deviated randomly by up to the jitter either way.
+func (h *HealthPing) nextDelay(tag string) time.Duration {
+	interval := h.intervalOf(tag)
+	jitter := int64(float64(interval) * h.Settings.Jitter)
+	if jitter <= 0 {
+		return interval
+	}
+	return interval - time.Duration(jitter) + time.Duration(dice.RollInt63n(jitter*2))
+}
+
 // StopScheduler implements the HealthChecker
 func (h *HealthPing) StopScheduler() {
 	if h.ticker == nil {
@@ -132,6 +232,9 @@ func (h *HealthPing) StopScheduler() {
 	h.ticker = nil
 	close(h.tickerClose)
 	h.tickerClose = nil
+	h.scheduleAccess.Lock()
+	h.scheduled = nil
+	h.scheduleAccess.Unlock()
 }
 
 // Check implements the HealthChecker
@@ -160,45 +263,15 @@ func (h *HealthPing) doCheck(tags []string, duration time.Duration, rounds int)
 
 	for _, tag := range tags {
 		handler := tag
-		client := newPingClient(
-			h.ctx,
-			h.dispatcher,
-			h.Settings.Destination,
-			h.Settings.Timeout,
-			handler,
-		)
 		for i := 0; i < rounds; i++ {
 			delay := time.Duration(0)
 			if duration > 0 {
 				delay = time.Duration(dice.RollInt63n(int64(duration)))
 			}
 			time.AfterFunc(delay, func() {
-				errors.LogDebug(h.ctx, "checking ", handler)
-				delay, err := client.MeasureDelay(h.Settings.HttpMethod)
-				if err == nil {
-					ch <- &rtt{
-						handler: handler,
-						value:   delay,
-					}
-					return
-				}
-				if !h.checkConnectivity() {
-					errors.LogWarning(h.ctx, "network is down")
-					ch <- &rtt{
-						handler: handler,
-						value:   0,
-					}
-					return
-				}
-				errors.LogWarning(h.ctx, fmt.Sprintf(
-					"error ping %s with %s: %s",
-					h.Settings.Destination,
-					handler,
-					err,
-				))
 				ch <- &rtt{
 					handler: handler,
-					value:   rttFailed,
+					value:   h.ping(handler),
 				}
 			})
 		}
@@ -212,6 +285,38 @@ func (h *HealthPing) doCheck(tags []string, duration time.Duration, rounds int)
 	}
 }
 
+// ping returns the rtt of a ping through the outbound tag, rttFailed if it
+// fails, or 0 if the network is down. It waits for the limiter if any.
+func (h *HealthPing) ping(tag string) time.Duration {
+	if h.limiter != nil {
+		h.limiter <- struct{}{}
+		defer func() { <-h.limiter }()
+	}
+	client := newPingClient(
+		h.ctx,
+		h.dispatcher,
+		h.Settings.Destination,
+		h.Settings.Timeout,
+		tag,
+	)
+	errors.LogDebug(h.ctx, "checking ", tag)
+	delay, err := client.MeasureDelay(h.Settings.HttpMethod)
+	if err == nil {
+		return delay
+	}
+	if !h.checkConnectivity() {
+		errors.LogWarning(h.ctx, "network is down")
+		return 0
+	}
+	errors.LogWarning(h.ctx, fmt.Sprintf(
+		"error ping %s with %s: %s",
+		h.Settings.Destination,
+		tag,
+		err,
+	))
+	return rttFailed
+}
+
 // PutResult put a ping rtt to results
 func (h *HealthPing) PutResult(tag string, rtt time.Duration) {
 	h.access.Lock()
@@ -225,11 +330,21 @@ func (h *HealthPing) PutResult(tag string, rtt time.Duration) {
 		// distributed in the time line randomly, in extreme cases,
 		// Previous checks are distributed on the left, and later ones
 		// on the right
-		validity := h.Settings.Interval * time.Duration(h.Settings.SamplingCount) * 2
+		validity := h.intervalOf(tag) * time.Duration(h.Settings.SamplingCount) * 2
 		r = NewHealthPingResult(h.Settings.SamplingCount, validity)
 		h.Results[tag] = r
 	}
+	before := r.getStatistics()
 	r.Put(rtt)
+	after := r.getStatistics()
+
+	wasAlive := !ok || before.All != before.Fail
+	switch alive := after.All != after.Fail; {
+	case wasAlive && !alive:
+		events.Emit(events.OutboundDown, "outbound", tag, "error", "all pings failed")
+	case !wasAlive && alive:
+		events.Emit(events.OutboundUp, "outbound", tag, "delay", strconv.FormatInt(after.Average.Milliseconds(), 10))
+	}
 }
 
 // Cleanup removes results of removed handlers,
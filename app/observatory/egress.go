@@ -0,0 +1,126 @@
+package observatory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/events"
+	"github.com/xtls/xray-core/common/geodata"
+	"github.com/xtls/xray-core/common/platform"
+)
+
+const defaultEgressInterval = 10 * time.Minute
+
+// checkEgress checks where outbound exits to the Internet from, if the
+// egress is checked and outbound is alive, once every egress interval.
+func (o *Observer) checkEgress(outbound string, result *ProbeResult) {
+	if o.config.EgressUrl == "" || !result.Alive {
+		return
+	}
+	interval := defaultEgressInterval
+	if o.config.EgressInterval != 0 {
+		interval = time.Duration(o.config.EgressInterval)
+	}
+	now := time.Now()
+	o.statusLock.Lock()
+	location := o.findStatusLocationLockHolderOnly(outbound)
+	if location == -1 || now.Before(time.Unix(o.status[location].EgressTime, 0).Add(interval)) {
+		o.statusLock.Unlock()
+		return
+	}
+	lastIP, lastCountry := o.status[location].EgressIp, o.status[location].EgressCountry
+	o.statusLock.Unlock()
+
+	ip, err := o.egress(outbound)
+	country := lastCountry
+	if err == nil && ip.String() != lastIP {
+		if country, err = geodata.Country(platform.GetAssetLocation("geoip.dat"), ip); err != nil {
+			err = errors.New("failed to look up the country of ", ip).Base(err)
+		}
+	}
+
+	// Failed checks are retried in the next interval too, keeping what was
+	// found before.
+	o.statusLock.Lock()
+	if location = o.findStatusLocationLockHolderOnly(outbound); location != -1 {
+		status := o.status[location]
+		if err == nil {
+			status.EgressIp = ip.String()
+			status.EgressCountry = country
+		}
+		status.EgressTime = now.Unix()
+	}
+	o.statusLock.Unlock()
+	if err != nil {
+		errors.LogWarningInner(o.ctx, err, "failed to check the egress of the outbound ", outbound)
+		return
+	}
+	if ip.String() != lastIP {
+		errors.LogInfo(o.ctx, "the outbound ", outbound, " exits from ", ip, " in ", countryName(country))
+	}
+	if lastIP != "" && country != lastCountry {
+		events.Emit(events.OutboundEgressChanged, "outbound", outbound, "ip", ip.String(), "country", country, "previous", lastCountry)
+	}
+}
+
+// egress requests the egress URL through outbound, and returns the address
+// it responds with.
+func (o *Observer) egress(outbound string) (net.IP, error) {
+	request, err := http.NewRequestWithContext(o.ctx, http.MethodGet, o.config.EgressUrl, nil)
+	if err != nil {
+		return nil, errors.New("invalid egress URL").Base(err)
+	}
+	response, err := o.client(outbound, newErrorCollector()).Do(request)
+	if err != nil {
+		return nil, errors.New("outbound failed to relay connection").Base(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("unexpected status ", response.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxProbeBody))
+	if err != nil {
+		return nil, errors.New("failed to read response").Base(err)
+	}
+	ip := parseEgress(body)
+	if ip == nil {
+		return nil, errors.New("response without an IP")
+	}
+	return ip, nil
+}
+
+// parseEgress returns the IP in body, which is either the IP alone, lines of
+// key=value with an ip= one as of Cloudflare's /cdn-cgi/trace, or a JSON
+// object of "ip".
+func parseEgress(body []byte) net.IP {
+	body = bytes.TrimSpace(body)
+	if ip := net.ParseIP(string(body)); ip != nil {
+		return ip
+	}
+	var object struct {
+		IP string `json:"ip"`
+	}
+	if json.Unmarshal(body, &object) == nil {
+		return net.ParseIP(object.IP)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		if value, found := bytes.CutPrefix(bytes.TrimSpace(scanner.Bytes()), []byte("ip=")); found {
+			return net.ParseIP(string(value))
+		}
+	}
+	return nil
+}
+
+func countryName(country string) string {
+	if country == "" {
+		return "an unknown country"
+	}
+	return country
+}
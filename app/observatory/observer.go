@@ -1,16 +1,23 @@
 package observatory
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"io"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"slices"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/events"
 	v2net "github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal/done"
@@ -23,6 +30,13 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
+const (
+	defaultHistorySize = 10
+	// maxProbeBody is the size of the body of a response read at most to find
+	// the expected body in.
+	maxProbeBody = 64 * 1024
+)
+
 type Observer struct {
 	config *Config
 	ctx    context.Context
@@ -31,23 +45,45 @@ type Observer struct {
 	status     []*OutboundStatus
 
 	finished *done.Instance
+	// ready is closed once all outbounds are probed once.
+	ready     chan struct{}
+	readyOnce sync.Once
 
 	ohm        outbound.Manager
 	dispatcher routing.Dispatcher
 }
 
 func (o *Observer) GetObservation(ctx context.Context) (proto.Message, error) {
-	return &ObservationResult{Status: o.status}, nil
+	o.statusLock.Lock()
+	defer o.statusLock.Unlock()
+	status := make([]*OutboundStatus, 0, len(o.status))
+	for _, s := range o.status {
+		status = append(status, proto.Clone(s).(*OutboundStatus))
+	}
+	return &ObservationResult{Status: status}, nil
 }
 
 func (o *Observer) Type() interface{} {
 	return extension.ObservatoryType()
 }
 
+// Ready implements features.Readiness.
+func (o *Observer) Ready() <-chan struct{} {
+	return o.ready
+}
+
+func (o *Observer) setReady() {
+	o.readyOnce.Do(func() {
+		close(o.ready)
+	})
+}
+
 func (o *Observer) Start() error {
 	if o.config != nil && len(o.config.SubjectSelector) != 0 {
 		o.finished = done.New()
 		go o.background()
+	} else {
+		o.setReady()
 	}
 	return nil
 }
@@ -81,11 +117,13 @@ func (o *Observer) background() {
 			for _, v := range outbounds {
 				result := o.probe(v)
 				o.updateStatusForResult(v, &result)
+				o.checkEgress(v, &result)
 				if o.finished.Done() {
 					return
 				}
 				time.Sleep(sleepTime)
 			}
+			o.setReady()
 			continue
 		}
 
@@ -95,6 +133,7 @@ func (o *Observer) background() {
 			go func(v string) {
 				result := o.probe(v)
 				o.updateStatusForResult(v, &result)
+				o.checkEgress(v, &result)
 				ch <- struct{}{}
 			}(v)
 		}
@@ -106,6 +145,7 @@ func (o *Observer) background() {
 				return
 			}
 		}
+		o.setReady()
 		time.Sleep(sleepTime)
 	}
 }
@@ -117,9 +157,9 @@ func (o *Observer) updateStatus(outbounds []string) {
 	_ = outbounds
 }
 
-func (o *Observer) probe(outbound string) ProbeResult {
-	errorCollectorForRequest := newErrorCollector()
-
+// client returns the HTTP client whose requests are relayed by outbound,
+// with the errors of its connections collected by errorCollectorForRequest.
+func (o *Observer) client(outbound string, errorCollectorForRequest *errorCollector) *http.Client {
 	httpTransport := http.Transport{
 		Proxy: func(*http.Request) (*url.URL, error) {
 			return nil, nil
@@ -147,7 +187,7 @@ func (o *Observer) probe(outbound string) ProbeResult {
 		},
 		TLSHandshakeTimeout: time.Second * 5,
 	}
-	httpClient := &http.Client{
+	return &http.Client{
 		Transport: &httpTransport,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
@@ -155,19 +195,41 @@ func (o *Observer) probe(outbound string) ProbeResult {
 		Jar:     nil,
 		Timeout: time.Second * 5,
 	}
-	var GETTime time.Duration
+}
+
+func (o *Observer) probe(outbound string) ProbeResult {
+	errorCollectorForRequest := newErrorCollector()
+	httpClient := o.client(outbound, errorCollectorForRequest)
+	var GETTime, handshakeTime, firstByteTime time.Duration
 	err := task.Run(o.ctx, func() error {
 		startTime := time.Now()
 		probeURL := "https://www.google.com/generate_204"
 		if o.config.ProbeUrl != "" {
 			probeURL = o.config.ProbeUrl
 		}
-		response, err := httpClient.Get(probeURL)
+		var handshakeStart time.Time
+		trace := &httptrace.ClientTrace{
+			TLSHandshakeStart: func() {
+				handshakeStart = time.Now()
+			},
+			TLSHandshakeDone: func(tls.ConnectionState, error) {
+				handshakeTime = time.Since(handshakeStart)
+			},
+			GotFirstResponseByte: func() {
+				firstByteTime = time.Since(startTime)
+			},
+		}
+		request, err := http.NewRequestWithContext(httptrace.WithClientTrace(o.ctx, trace), http.MethodGet, probeURL, nil)
+		if err != nil {
+			return errors.New("invalid probe URL").Base(err)
+		}
+		response, err := httpClient.Do(request)
 		if err != nil {
 			return errors.New("outbound failed to relay connection").Base(err)
 		}
-		if response.Body != nil {
-			response.Body.Close()
+		defer response.Body.Close()
+		if err := o.validate(response); err != nil {
+			return err
 		}
 		endTime := time.Now()
 		GETTime = endTime.Sub(startTime)
@@ -179,31 +241,77 @@ func (o *Observer) probe(outbound string) ProbeResult {
 		return ProbeResult{Alive: false, LastErrorReason: errorMessage}
 	}
 	errors.LogInfo(o.ctx, "the outbound ", outbound, " is alive:", GETTime.Seconds())
-	return ProbeResult{Alive: true, Delay: GETTime.Milliseconds()}
+	return ProbeResult{
+		Alive:     true,
+		Delay:     GETTime.Milliseconds(),
+		Handshake: handshakeTime.Milliseconds(),
+		Ttfb:      firstByteTime.Milliseconds(),
+	}
+}
+
+// validate checks the response to a probe request for the expected status
+// and body.
+func (o *Observer) validate(response *http.Response) error {
+	if len(o.config.ExpectedStatus) > 0 && !slices.Contains(o.config.ExpectedStatus, uint32(response.StatusCode)) {
+		return errors.New("unexpected status ", response.Status)
+	}
+	if o.config.ExpectedBody != "" {
+		body, err := io.ReadAll(io.LimitReader(response.Body, maxProbeBody))
+		if err != nil {
+			return errors.New("failed to read response").Base(err)
+		}
+		if !bytes.Contains(body, []byte(o.config.ExpectedBody)) {
+			return errors.New("response without the expected body")
+		}
+	}
+	return nil
 }
 
 func (o *Observer) updateStatusForResult(outbound string, result *ProbeResult) {
 	o.statusLock.Lock()
 	defer o.statusLock.Unlock()
 	var status *OutboundStatus
+	wasAlive := true
 	if location := o.findStatusLocationLockHolderOnly(outbound); location != -1 {
 		status = o.status[location]
+		wasAlive = status.Alive
 	} else {
 		status = &OutboundStatus{}
 		o.status = append(o.status, status)
 	}
+	switch {
+	case wasAlive && !result.Alive:
+		events.Emit(events.OutboundDown, "outbound", outbound, "error", result.LastErrorReason)
+	case !wasAlive && result.Alive:
+		events.Emit(events.OutboundUp, "outbound", outbound, "delay", strconv.FormatInt(result.Delay, 10))
+		// It may exit elsewhere now.
+		status.EgressTime = 0
+	}
 
 	status.LastTryTime = time.Now().Unix()
 	status.OutboundTag = outbound
 	status.Alive = result.Alive
 	if result.Alive {
 		status.Delay = result.Delay
+		status.Handshake = result.Handshake
+		status.Ttfb = result.Ttfb
 		status.LastSeenTime = status.LastTryTime
 		status.LastErrorReason = ""
 	} else {
 		status.LastErrorReason = result.LastErrorReason
 		status.Delay = 99999999
 	}
+
+	historySize := int(o.config.HistorySize)
+	if historySize == 0 {
+		historySize = defaultHistorySize
+	}
+	record := proto.Clone(result).(*ProbeResult)
+	record.Time = status.LastTryTime
+	status.History = append(status.History, record)
+	if len(status.History) > historySize {
+		status.History = slices.Delete(status.History, 0, len(status.History)-historySize)
+	}
 }
 
 func (o *Observer) findStatusLocationLockHolderOnly(outbound string) int {
@@ -228,6 +336,7 @@ func New(ctx context.Context, config *Config) (*Observer, error) {
 	return &Observer{
 		config:     config,
 		ctx:        ctx,
+		ready:      make(chan struct{}),
 		ohm:        outboundManager,
 		dispatcher: dispatcher,
 	}, nil
@@ -1,8 +1,12 @@
 package policy
 
 import (
+	"math"
+	"strings"
 	"time"
 
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/qos"
 	"github.com/xtls/xray-core/features/policy"
 )
 
@@ -43,6 +47,21 @@ func (p *Policy_Timeout) overrideWith(another *Policy_Timeout) {
 	if another.DownlinkOnly != nil {
 		p.DownlinkOnly = &Second{Value: another.DownlinkOnly.Value}
 	}
+	if another.ConnectionIdleUdp != nil {
+		p.ConnectionIdleUdp = &Second{Value: another.ConnectionIdleUdp.Value}
+	}
+	if another.ConnectionIdleQuic != nil {
+		p.ConnectionIdleQuic = &Second{Value: another.ConnectionIdleQuic.Value}
+	}
+	if another.ConnectionIdleStun != nil {
+		p.ConnectionIdleStun = &Second{Value: another.ConnectionIdleStun.Value}
+	}
+	for protocol, timeout := range another.ConnectionIdleProtocols {
+		if p.ConnectionIdleProtocols == nil {
+			p.ConnectionIdleProtocols = make(map[string]*Second)
+		}
+		p.ConnectionIdleProtocols[protocol] = &Second{Value: timeout.Value}
+	}
 }
 
 func (p *Policy) overrideWith(another *Policy) {
@@ -55,7 +74,21 @@ func (p *Policy) overrideWith(another *Policy) {
 	}
 	if another.Buffer != nil {
 		p.Buffer = &Policy_Buffer{
-			Connection: another.Buffer.Connection,
+			Connection:    another.Buffer.Connection,
+			ConnectionMin: another.Buffer.ConnectionMin,
+			ConnectionMax: another.Buffer.ConnectionMax,
+		}
+	}
+	if another.Restriction != nil {
+		p.Restriction = &Policy_Restriction{
+			BlockBittorrent: another.Restriction.BlockBittorrent,
+			MaxIps:          another.Restriction.MaxIps,
+			Quota:           another.Restriction.Quota,
+		}
+	}
+	if another.Tracing != nil {
+		p.Tracing = &Policy_Tracing{
+			Enabled: another.Tracing.Enabled,
 		}
 	}
 }
@@ -69,6 +102,29 @@ func (p *Policy) ToCorePolicy() policy.Session {
 		cp.Timeouts.Handshake = p.Timeout.Handshake.Duration()
 		cp.Timeouts.DownlinkOnly = p.Timeout.DownlinkOnly.Duration()
 		cp.Timeouts.UplinkOnly = p.Timeout.UplinkOnly.Duration()
+
+		t := &cp.Timeouts
+		t.ConnectionIdleTCP = t.ConnectionIdle
+		t.ConnectionIdleUDP = t.ConnectionIdle
+		if p.Timeout.ConnectionIdleUdp != nil {
+			t.ConnectionIdleUDP = p.Timeout.ConnectionIdleUdp.Duration()
+		}
+		t.ConnectionIdleQUIC = t.ConnectionIdleUDP
+		if p.Timeout.ConnectionIdleQuic != nil {
+			t.ConnectionIdleQUIC = p.Timeout.ConnectionIdleQuic.Duration()
+		}
+		t.ConnectionIdleSTUN = t.ConnectionIdleUDP
+		if p.Timeout.ConnectionIdleStun != nil {
+			t.ConnectionIdleSTUN = p.Timeout.ConnectionIdleStun.Duration()
+		}
+		t.ConnectionIdle = max(t.ConnectionIdleTCP, t.ConnectionIdleUDP, t.ConnectionIdleQUIC, t.ConnectionIdleSTUN)
+		if len(p.Timeout.ConnectionIdleProtocols) > 0 {
+			t.ConnectionIdleProtocols = make(map[string]time.Duration, len(p.Timeout.ConnectionIdleProtocols))
+			for protocol, timeout := range p.Timeout.ConnectionIdleProtocols {
+				t.ConnectionIdleProtocols[protocol] = timeout.Duration()
+				t.ConnectionIdle = max(t.ConnectionIdle, timeout.Duration())
+			}
+		}
 	}
 	if p.Stats != nil {
 		cp.Stats.UserUplink = p.Stats.UserUplink
@@ -77,6 +133,16 @@ func (p *Policy) ToCorePolicy() policy.Session {
 	}
 	if p.Buffer != nil {
 		cp.Buffer.PerConnection = p.Buffer.Connection
+		cp.Buffer.MinPerConnection = p.Buffer.ConnectionMin
+		cp.Buffer.MaxPerConnection = p.Buffer.ConnectionMax
+	}
+	if p.Restriction != nil {
+		cp.Restriction.BlockBittorrent = p.Restriction.BlockBittorrent
+		cp.Restriction.MaxIPs = p.Restriction.MaxIps
+		cp.Restriction.Quota = int64(p.Restriction.Quota)
+	}
+	if p.Tracing != nil {
+		cp.Tracing.Enabled = p.Tracing.Enabled
 	}
 	return cp
 }
@@ -85,10 +151,90 @@ func (p *Policy) ToCorePolicy() policy.Session {
 func (p *SystemPolicy) ToCorePolicy() policy.System {
 	return policy.System{
 		Stats: policy.SystemStats{
-			InboundUplink:    p.Stats.InboundUplink,
-			InboundDownlink:  p.Stats.InboundDownlink,
-			OutboundUplink:   p.Stats.OutboundUplink,
-			OutboundDownlink: p.Stats.OutboundDownlink,
+			InboundUplink:       p.Stats.InboundUplink,
+			InboundDownlink:     p.Stats.InboundDownlink,
+			OutboundUplink:      p.Stats.OutboundUplink,
+			OutboundDownlink:    p.Stats.OutboundDownlink,
+			RuleMatched:         p.Stats.RuleMatched,
+			RuleUplink:          p.Stats.RuleUplink,
+			RuleDownlink:        p.Stats.RuleDownlink,
+			UDPFlows:            p.Stats.UdpFlows,
+			OutboundTermination: p.Stats.OutboundTermination,
 		},
+		DrainTimeout: time.Duration(p.DrainTimeout) * time.Second,
+	}
+}
+
+// ToCorePolicy builds the shapers of this QoS, and sorts the classes of the
+// connections into them.
+func (q *SystemPolicy_QoS) ToCorePolicy() policy.QoS {
+	p := policy.QoS{
+		RuleTags: make(map[string]policy.QoSClass),
+		Levels:   make(map[uint32]policy.QoSClass),
+	}
+	var uplink, downlink *qos.Shaper
+	if q.Uplink > 0 {
+		uplink = qos.NewShaper(q.Uplink)
+	}
+	if q.Downlink > 0 {
+		downlink = qos.NewShaper(q.Downlink)
+	}
+	newClass := func(priority uint32, weight uint32) policy.QoSClass {
+		var c policy.QoSClass
+		if uplink != nil {
+			c.Uplink = uplink.NewClass(priority, weight)
+		}
+		if downlink != nil {
+			c.Downlink = downlink.NewClass(priority, weight)
+		}
+		return c
+	}
+
+	var lowest uint32
+	for _, class := range q.Class {
+		c := newClass(class.Priority, class.Weight)
+		for _, level := range class.Levels {
+			p.Levels[level] = c
+		}
+		for _, tag := range class.RuleTags {
+			p.RuleTags[tag] = c
+		}
+		lowest = max(lowest, class.Priority)
+	}
+	p.Default = newClass(lowest, 1)
+	return p
+}
+
+// tenantsToCorePolicy builds the shapers of the tenants, and sorts their
+// inbounds and users into them.
+func tenantsToCorePolicy(tenants []*SystemPolicy_Tenant) policy.Tenants {
+	p := policy.Tenants{
+		Inbounds: make(map[string]*policy.Tenant),
+		Users:    make(map[string]*policy.Tenant),
+	}
+	for _, t := range tenants {
+		tenant := &policy.Tenant{
+			Name:           t.Name,
+			MaxConnections: int32(min(t.MaxConnections, math.MaxInt32)),
+		}
+		if t.Uplink > 0 {
+			tenant.Class.Uplink = qos.NewShaper(t.Uplink).NewClass(0, 1)
+		}
+		if t.Downlink > 0 {
+			tenant.Class.Downlink = qos.NewShaper(t.Downlink).NewClass(0, 1)
+		}
+		if len(t.Hosts) > 0 {
+			tenant.Hosts = make(map[string]net.Address, len(t.Hosts))
+			for domain, address := range t.Hosts {
+				tenant.Hosts[strings.ToLower(domain)] = net.ParseAddress(address)
+			}
+		}
+		for _, tag := range t.InboundTag {
+			p.Inbounds[tag] = tenant
+		}
+		for _, email := range t.UserEmail {
+			p.Users[email] = tenant
+		}
 	}
+	return p
 }
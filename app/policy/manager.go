@@ -9,8 +9,10 @@ import (
 
 // Instance is an instance of Policy manager.
 type Instance struct {
-	levels map[uint32]*Policy
-	system *SystemPolicy
+	levels  map[uint32]*Policy
+	system  *SystemPolicy
+	qos     policy.QoS
+	tenants policy.Tenants
 }
 
 // New creates new Policy manager instance.
@@ -26,6 +28,10 @@ func New(ctx context.Context, config *Config) (*Instance, error) {
 			m.levels[lv] = pp
 		}
 	}
+	if q := config.System.GetQos(); q != nil {
+		m.qos = q.ToCorePolicy()
+	}
+	m.tenants = tenantsToCorePolicy(config.System.GetTenant())
 
 	return m, nil
 }
@@ -48,7 +54,10 @@ func (m *Instance) ForSystem() policy.System {
 	if m.system == nil {
 		return policy.System{}
 	}
-	return m.system.ToCorePolicy()
+	p := m.system.ToCorePolicy()
+	p.QoS = m.qos
+	p.Tenants = m.tenants
+	return p
 }
 
 // Start implements common.Runnable.Start().
@@ -0,0 +1,256 @@
+// Package provider keeps the outbounds of subscriptions up to date.
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	gonet "net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/signal/done"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/infra/conf/link"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultInterval = time.Hour
+	fetchTimeout    = time.Minute
+)
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		m := &Manager{}
+		if err := core.RequireFeatures(ctx, func(ohm outbound.Manager) error {
+			return m.Init(ctx, config.(*Config), ohm)
+		}); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}))
+}
+
+// Manager keeps the outbounds of the providers.
+type Manager struct {
+	providers []*provider
+}
+
+func (m *Manager) Init(ctx context.Context, config *Config, ohm outbound.Manager) error {
+	instance := core.MustFromContext(ctx)
+	for _, c := range config.Providers {
+		if c.Url == "" {
+			return errors.New("provider ", c.Name, " without URL")
+		}
+		if c.TagPrefix == "" {
+			return errors.New("provider ", c.Name, " without tag prefix")
+		}
+		m.providers = append(m.providers, &provider{
+			ctx:       ctx,
+			config:    c,
+			instance:  instance,
+			ohm:       ohm,
+			outbounds: make(map[string]*core.OutboundHandlerConfig),
+			done:      done.New(),
+		})
+	}
+	return nil
+}
+
+// Type implements common.HasType.
+func (*Manager) Type() interface{} {
+	return (*Manager)(nil)
+}
+
+// Start implements common.Runnable.
+func (m *Manager) Start() error {
+	for _, p := range m.providers {
+		go p.run()
+	}
+	return nil
+}
+
+// Close implements common.Closable.
+func (m *Manager) Close() error {
+	for _, p := range m.providers {
+		p.done.Close()
+	}
+	return nil
+}
+
+// provider materializes the outbounds of a subscription, tagged with its tag
+// prefix, so balancers and observatories select them by the prefix.
+type provider struct {
+	ctx      context.Context
+	config   *Provider
+	instance *core.Instance
+	ohm      outbound.Manager
+
+	// outbounds are the ones added, by tag.
+	outbounds map[string]*core.OutboundHandlerConfig
+	done      *done.Instance
+}
+
+func (p *provider) run() {
+	interval := time.Duration(p.config.Interval)
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	for {
+		if err := p.update(); err != nil {
+			errors.LogWarningInner(p.ctx, err, "provider ", p.config.Name, ": failed to update")
+		}
+		select {
+		case <-p.done.Wait():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// update fetches the subscription, and replaces the outbounds that are changed
+// or gone.
+func (p *provider) update() error {
+	b, err := p.fetch()
+	if err != nil {
+		return err
+	}
+	configs, err := p.parse(b)
+	if err != nil {
+		return err
+	}
+	if len(configs) == 0 {
+		return errors.New("no outbound in subscription")
+	}
+
+	var added, removed int
+	for tag, old := range p.outbounds {
+		if c, found := configs[tag]; found && proto.Equal(c, old) {
+			continue
+		}
+		if h := p.ohm.GetHandler(tag); h != nil {
+			p.ohm.RemoveHandler(p.ctx, tag)
+			common.Close(h)
+		}
+		delete(p.outbounds, tag)
+		removed++
+	}
+	for tag, c := range configs {
+		if _, found := p.outbounds[tag]; found {
+			continue
+		}
+		if err := core.AddOutboundHandler(p.instance, c); err != nil {
+			errors.LogWarningInner(p.ctx, err, "provider ", p.config.Name, ": failed to add outbound ", tag)
+			continue
+		}
+		p.outbounds[tag] = c
+		added++
+	}
+	if added == 0 && removed == 0 {
+		errors.LogDebug(p.ctx, "provider ", p.config.Name, ": ", len(p.outbounds), " outbounds, unchanged")
+		return nil
+	}
+	errors.LogInfo(p.ctx, "provider ", p.config.Name, ": ", len(p.outbounds), " outbounds, ", added, " added, ", removed, " removed")
+	return nil
+}
+
+func (p *provider) fetch() ([]byte, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (gonet.Conn, error) {
+				dest, err := net.ParseDestination(network + ":" + addr)
+				if err != nil {
+					return nil, err
+				}
+				if p.config.OutboundTag != "" {
+					content := new(session.Content)
+					content.SkipDNSResolve = true
+					ctx = session.ContextWithContent(ctx, content)
+					ctx = session.SetForcedOutboundTagToContext(ctx, p.config.OutboundTag)
+				}
+				return core.Dial(ctx, p.instance, dest)
+			},
+			DisableKeepAlives: true,
+		},
+		Timeout: fetchTimeout,
+	}
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodGet, p.config.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Xray/"+core.Version())
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.New("failed to fetch subscription").Base(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("failed to fetch subscription: ", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// parse returns the outbounds of a subscription, by their tags with the
+// prefix: the outbounds of an Xray config in JSON, or of links.
+func (p *provider) parse(b []byte) (map[string]*core.OutboundHandlerConfig, error) {
+	var outbounds []*core.OutboundHandlerConfig
+	if b = bytes.TrimSpace(b); bytes.HasPrefix(b, []byte("{")) {
+		config, err := core.LoadConfig("json", bytes.NewReader(b))
+		if err != nil {
+			return nil, errors.New("failed to load subscription config").Base(err)
+		}
+		outbounds = config.Outbound
+	} else {
+		for _, l := range link.Subscription(b) {
+			outbound, err := loadLink(l)
+			if err != nil {
+				errors.LogInfoInner(p.ctx, err, "provider ", p.config.Name, ": skipped link")
+				continue
+			}
+			outbounds = append(outbounds, outbound)
+		}
+	}
+
+	configs := make(map[string]*core.OutboundHandlerConfig, len(outbounds))
+	for i, outbound := range outbounds {
+		name := outbound.Tag
+		if name == "" {
+			name = strconv.Itoa(i + 1)
+		}
+		tag := p.config.TagPrefix + name
+		for n := 2; configs[tag] != nil; n++ {
+			tag = p.config.TagPrefix + name + "-" + strconv.Itoa(n)
+		}
+		outbound.Tag = tag
+		configs[tag] = outbound
+	}
+	return configs, nil
+}
+
+// loadLink returns the outbound of a share link.
+func loadLink(l string) (*core.OutboundHandlerConfig, error) {
+	outbound, err := link.Parse(l)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(map[string]interface{}{"outbounds": []interface{}{outbound}})
+	if err != nil {
+		return nil, err
+	}
+	config, err := core.LoadConfig("json", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Outbound) != 1 {
+		return nil, errors.New("invalid outbound of link")
+	}
+	return config.Outbound[0], nil
+}
@@ -11,6 +11,8 @@ import (
 	"github.com/xtls/xray-core/features/inbound"
 	"github.com/xtls/xray-core/features/outbound"
 	"github.com/xtls/xray-core/proxy"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/tls"
 	grpc "google.golang.org/grpc"
 )
 
@@ -64,6 +66,43 @@ func (op *RemoveUserOperation) ApplyInbound(ctx context.Context, handler inbound
 	return um.RemoveUser(ctx, op.Email)
 }
 
+// ApplyInbound implements InboundOperation.
+func (op *SetFallbacksOperation) ApplyInbound(ctx context.Context, handler inbound.Handler) error {
+	p, err := getInbound(handler)
+	if err != nil {
+		return err
+	}
+	fm, ok := p.(proxy.FallbackManager)
+	if !ok {
+		return errors.New("proxy is not a FallbackManager")
+	}
+	return fm.SetFallbacks(ctx, op.Fallbacks)
+}
+
+// ApplyInbound implements InboundOperation.
+func (op *SetTLSPolicyOperation) ApplyInbound(ctx context.Context, handler inbound.Handler) error {
+	sg, ok := handler.(interface {
+		StreamSettings() *internet.MemoryStreamConfig
+	})
+	if !ok {
+		return errors.New("can't get stream settings from handler")
+	}
+	config := tls.ConfigFromStreamSettings(sg.StreamSettings())
+	if config == nil {
+		return errors.New("inbound is not of TLS")
+	}
+	if op.Tls == nil {
+		return errors.New("no TLS policy")
+	}
+	switch op.Tls.AlpnOrder {
+	case "", "server", "client":
+	default:
+		return errors.New("unknown ALPN order: ", op.Tls.AlpnOrder)
+	}
+	config.SetPolicy(op.Tls)
+	return nil
+}
+
 type handlerServer struct {
 	s   *core.Instance
 	ihm inbound.Manager
@@ -204,6 +243,28 @@ func (s *handlerServer) ListOutbounds(ctx context.Context, request *ListOutbound
 	return response, nil
 }
 
+func (s *handlerServer) SelectOutbound(ctx context.Context, request *SelectOutboundRequest) (*SelectOutboundResponse, error) {
+	handler := s.ohm.GetHandler(request.Tag)
+	if handler == nil {
+		return nil, errors.New("outbound ", request.Tag, " not found")
+	}
+	gi, ok := handler.(proxy.GetOutbound)
+	if !ok {
+		return nil, errors.New("can't get outbound proxy from handler.")
+	}
+	selector, ok := gi.GetOutbound().(proxy.OutboundSelector)
+	if !ok {
+		return nil, errors.New("outbound ", request.Tag, " is not a selector")
+	}
+	if request.OutboundTag != "" {
+		if err := selector.Select(ctx, request.OutboundTag); err != nil {
+			return nil, err
+		}
+	}
+	selected, candidates := selector.Selected()
+	return &SelectOutboundResponse{Selected: selected, Candidates: candidates}, nil
+}
+
 func (s *handlerServer) mustEmbedUnimplementedHandlerServiceServer() {}
 
 type service struct {
@@ -29,6 +29,7 @@ const (
 	HandlerService_RemoveOutbound_FullMethodName       = "/xray.app.proxyman.command.HandlerService/RemoveOutbound"
 	HandlerService_AlterOutbound_FullMethodName        = "/xray.app.proxyman.command.HandlerService/AlterOutbound"
 	HandlerService_ListOutbounds_FullMethodName        = "/xray.app.proxyman.command.HandlerService/ListOutbounds"
+	HandlerService_SelectOutbound_FullMethodName       = "/xray.app.proxyman.command.HandlerService/SelectOutbound"
 )
 
 // HandlerServiceClient is the client API for HandlerService service.
@@ -45,6 +46,7 @@ type HandlerServiceClient interface {
 	RemoveOutbound(ctx context.Context, in *RemoveOutboundRequest, opts ...grpc.CallOption) (*RemoveOutboundResponse, error)
 	AlterOutbound(ctx context.Context, in *AlterOutboundRequest, opts ...grpc.CallOption) (*AlterOutboundResponse, error)
 	ListOutbounds(ctx context.Context, in *ListOutboundsRequest, opts ...grpc.CallOption) (*ListOutboundsResponse, error)
+	SelectOutbound(ctx context.Context, in *SelectOutboundRequest, opts ...grpc.CallOption) (*SelectOutboundResponse, error)
 }
 
 type handlerServiceClient struct {
@@ -155,6 +157,16 @@ func (c *handlerServiceClient) ListOutbounds(ctx context.Context, in *ListOutbou
 	return out, nil
 }
 
+func (c *handlerServiceClient) SelectOutbound(ctx context.Context, in *SelectOutboundRequest, opts ...grpc.CallOption) (*SelectOutboundResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SelectOutboundResponse)
+	err := c.cc.Invoke(ctx, HandlerService_SelectOutbound_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // HandlerServiceServer is the server API for HandlerService service.
 // All implementations must embed UnimplementedHandlerServiceServer
 // for forward compatibility.
@@ -169,6 +181,7 @@ type HandlerServiceServer interface {
 	RemoveOutbound(context.Context, *RemoveOutboundRequest) (*RemoveOutboundResponse, error)
 	AlterOutbound(context.Context, *AlterOutboundRequest) (*AlterOutboundResponse, error)
 	ListOutbounds(context.Context, *ListOutboundsRequest) (*ListOutboundsResponse, error)
+	SelectOutbound(context.Context, *SelectOutboundRequest) (*SelectOutboundResponse, error)
 	mustEmbedUnimplementedHandlerServiceServer()
 }
 
@@ -209,6 +222,9 @@ func (UnimplementedHandlerServiceServer) AlterOutbound(context.Context, *AlterOu
 func (UnimplementedHandlerServiceServer) ListOutbounds(context.Context, *ListOutboundsRequest) (*ListOutboundsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListOutbounds not implemented")
 }
+func (UnimplementedHandlerServiceServer) SelectOutbound(context.Context, *SelectOutboundRequest) (*SelectOutboundResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SelectOutbound not implemented")
+}
 func (UnimplementedHandlerServiceServer) mustEmbedUnimplementedHandlerServiceServer() {}
 func (UnimplementedHandlerServiceServer) testEmbeddedByValue()                        {}
 
@@ -410,6 +426,24 @@ func _HandlerService_ListOutbounds_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _HandlerService_SelectOutbound_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SelectOutboundRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HandlerServiceServer).SelectOutbound(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HandlerService_SelectOutbound_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HandlerServiceServer).SelectOutbound(ctx, req.(*SelectOutboundRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // HandlerService_ServiceDesc is the grpc.ServiceDesc for HandlerService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -457,6 +491,10 @@ var HandlerService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListOutbounds",
 			Handler:    _HandlerService_ListOutbounds_Handler,
 		},
+		{
+			MethodName: "SelectOutbound",
+			Handler:    _HandlerService_SelectOutbound_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "app/proxyman/command/command.proto",
@@ -2,6 +2,7 @@ package inbound
 
 import (
 	"context"
+	"sync/atomic"
 
 	"github.com/xtls/xray-core/app/proxyman"
 	"github.com/xtls/xray-core/common"
@@ -23,9 +24,10 @@ func getStatCounter(v *core.Instance, tag string) (stats.Counter, stats.Counter)
 	var downlinkCounter stats.Counter
 
 	policy := v.GetFeature(policy.ManagerType()).(policy.Manager)
+	tenants := policy.ForSystem().Tenants
 	if len(tag) > 0 && policy.ForSystem().Stats.InboundUplink {
 		statsManager := v.GetFeature(stats.ManagerType()).(stats.Manager)
-		name := "inbound>>>" + tag + ">>>traffic>>>uplink"
+		name := tenants.ForInbound(tag).StatName("inbound>>>" + tag + ">>>traffic>>>uplink")
 		c, _ := stats.GetOrRegisterCounter(statsManager, name)
 		if c != nil {
 			uplinkCounter = c
@@ -33,7 +35,7 @@ func getStatCounter(v *core.Instance, tag string) (stats.Counter, stats.Counter)
 	}
 	if len(tag) > 0 && policy.ForSystem().Stats.InboundDownlink {
 		statsManager := v.GetFeature(stats.ManagerType()).(stats.Manager)
-		name := "inbound>>>" + tag + ">>>traffic>>>downlink"
+		name := tenants.ForInbound(tag).StatName("inbound>>>" + tag + ">>>traffic>>>downlink")
 		c, _ := stats.GetOrRegisterCounter(statsManager, name)
 		if c != nil {
 			downlinkCounter = c
@@ -46,10 +48,13 @@ func getStatCounter(v *core.Instance, tag string) (stats.Counter, stats.Counter)
 type AlwaysOnInboundHandler struct {
 	proxyConfig    interface{}
 	receiverConfig *proxyman.ReceiverConfig
+	streamSettings *internet.MemoryStreamConfig
 	proxy          proxy.Inbound
 	workers        []worker
 	mux            *mux.Server
 	tag            string
+	connections    atomic.Int64
+	knock          *knockGate
 }
 
 func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *proxyman.ReceiverConfig, proxyConfig interface{}) (*AlwaysOnInboundHandler, error) {
@@ -84,6 +89,23 @@ func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *
 		return nil, errors.New("failed to parse stream config").Base(err).AtWarning()
 	}
 
+	h.streamSettings = mss
+
+	sniffingExcluded, err := newSniffingExclusion(receiverConfig.GetEffectiveSniffingSettings())
+	if err != nil {
+		return nil, err
+	}
+	sniffingRedirect, err := newSniffingRedirect(receiverConfig.GetEffectiveSniffingSettings())
+	if err != nil {
+		return nil, err
+	}
+
+	h.knock, err = newKnockGate(receiverConfig.Knock, address)
+	if err != nil {
+		return nil, err
+	}
+	ctx = contextWithListenGate(ctx, h.knock)
+
 	if receiverConfig.ReceiveOriginalDestination {
 		if mss.SocketSettings == nil {
 			mss.SocketSettings = &internet.SocketConfig{}
@@ -98,15 +120,18 @@ func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *
 			errors.LogDebug(ctx, "creating unix domain socket worker on ", address)
 
 			worker := &dsWorker{
-				address:         address,
-				proxy:           p,
-				stream:          mss,
-				tag:             tag,
-				dispatcher:      h.mux,
-				sniffingConfig:  receiverConfig.GetEffectiveSniffingSettings(),
-				uplinkCounter:   uplinkCounter,
-				downlinkCounter: downlinkCounter,
-				ctx:             ctx,
+				address:          address,
+				proxy:            p,
+				stream:           mss,
+				tag:              tag,
+				dispatcher:       h.mux,
+				sniffingConfig:   receiverConfig.GetEffectiveSniffingSettings(),
+				sniffingExcluded: sniffingExcluded,
+				sniffingRedirect: sniffingRedirect,
+				uplinkCounter:    uplinkCounter,
+				downlinkCounter:  downlinkCounter,
+				connections:      &h.connections,
+				ctx:              ctx,
 			}
 			h.workers = append(h.workers, worker)
 		}
@@ -114,37 +139,43 @@ func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *
 	if pl != nil {
 		for _, pr := range pl.Range {
 			for port := pr.From; port <= pr.To; port++ {
-				if net.HasNetwork(nl, net.Network_TCP) {
+				if net.HasNetwork(nl, net.Network_TCP) || (mss.CarriesUDP() && net.HasNetwork(nl, net.Network_UDP)) {
 					errors.LogDebug(ctx, "creating stream worker on ", address, ":", port)
 
 					worker := &tcpWorker{
-						address:         address,
-						port:            net.Port(port),
-						proxy:           p,
-						stream:          mss,
-						recvOrigDest:    receiverConfig.ReceiveOriginalDestination,
-						tag:             tag,
-						dispatcher:      h.mux,
-						sniffingConfig:  receiverConfig.GetEffectiveSniffingSettings(),
-						uplinkCounter:   uplinkCounter,
-						downlinkCounter: downlinkCounter,
-						ctx:             ctx,
+						address:          address,
+						port:             net.Port(port),
+						proxy:            p,
+						stream:           mss,
+						recvOrigDest:     receiverConfig.ReceiveOriginalDestination,
+						tag:              tag,
+						dispatcher:       h.mux,
+						sniffingConfig:   receiverConfig.GetEffectiveSniffingSettings(),
+						sniffingExcluded: sniffingExcluded,
+						sniffingRedirect: sniffingRedirect,
+						uplinkCounter:    uplinkCounter,
+						downlinkCounter:  downlinkCounter,
+						connections:      &h.connections,
+						ctx:              ctx,
 					}
 					h.workers = append(h.workers, worker)
 				}
 
-				if net.HasNetwork(nl, net.Network_UDP) {
+				if net.HasNetwork(nl, net.Network_UDP) && !mss.CarriesUDP() {
 					worker := &udpWorker{
-						tag:             tag,
-						proxy:           p,
-						address:         address,
-						port:            net.Port(port),
-						dispatcher:      h.mux,
-						sniffingConfig:  receiverConfig.GetEffectiveSniffingSettings(),
-						uplinkCounter:   uplinkCounter,
-						downlinkCounter: downlinkCounter,
-						stream:          mss,
-						ctx:             ctx,
+						tag:              tag,
+						proxy:            p,
+						address:          address,
+						port:             net.Port(port),
+						dispatcher:       h.mux,
+						sniffingConfig:   receiverConfig.GetEffectiveSniffingSettings(),
+						sniffingExcluded: sniffingExcluded,
+						sniffingRedirect: sniffingRedirect,
+						uplinkCounter:    uplinkCounter,
+						downlinkCounter:  downlinkCounter,
+						stream:           mss,
+						connections:      &h.connections,
+						ctx:              ctx,
 					}
 					h.workers = append(h.workers, worker)
 				}
@@ -157,6 +188,11 @@ func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *
 
 // Start implements common.Runnable.
 func (h *AlwaysOnInboundHandler) Start() error {
+	if h.knock != nil {
+		if err := h.knock.Start(); err != nil {
+			return err
+		}
+	}
 	for _, worker := range h.workers {
 		if err := worker.Start(); err != nil {
 			return err
@@ -171,13 +207,36 @@ func (h *AlwaysOnInboundHandler) Close() error {
 	for _, worker := range h.workers {
 		errs = append(errs, worker.Close())
 	}
-	errs = append(errs, h.mux.Close())
+	errs = append(errs, h.mux.Close(), common.Close(h.proxy))
+	if h.knock != nil {
+		errs = append(errs, h.knock.Close())
+	}
 	if err := errors.Combine(errs...); err != nil {
 		return errors.New("failed to close all resources").Base(err)
 	}
 	return nil
 }
 
+// Suspend stops the workers from listening, keeping their connections,
+// until Resume.
+func (h *AlwaysOnInboundHandler) Suspend() error {
+	var errs []error
+	for _, worker := range h.workers {
+		errs = append(errs, worker.suspend())
+	}
+	return errors.Combine(errs...)
+}
+
+// Resume starts listening again after Suspend.
+func (h *AlwaysOnInboundHandler) Resume() error {
+	return h.Start()
+}
+
+// ActiveConnections returns the number of connections being processed.
+func (h *AlwaysOnInboundHandler) ActiveConnections() int64 {
+	return h.connections.Load()
+}
+
 func (h *AlwaysOnInboundHandler) GetRandomInboundProxy() (interface{}, net.Port, int) {
 	if len(h.workers) == 0 {
 		return nil, 0, 0
@@ -194,6 +253,11 @@ func (h *AlwaysOnInboundHandler) GetInbound() proxy.Inbound {
 	return h.proxy
 }
 
+// StreamSettings returns the stream settings of the workers of h.
+func (h *AlwaysOnInboundHandler) StreamSettings() *internet.MemoryStreamConfig {
+	return h.streamSettings
+}
+
 // ReceiverSettings implements inbound.Handler.
 func (h *AlwaysOnInboundHandler) ReceiverSettings() *serial.TypedMessage {
 	return serial.ToTypedMessage(h.receiverConfig)
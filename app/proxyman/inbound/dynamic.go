@@ -3,9 +3,11 @@ package inbound
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/dice"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/mux"
@@ -31,6 +33,13 @@ type DynamicInboundHandler struct {
 	lastRefresh    time.Time
 	mux            *mux.Server
 	task           *task.Periodic
+	knock          *knockGate
+	connections    atomic.Int64
+
+	// sniffingExcluded and sniffingRedirect are shared by the workers, see
+	// newSniffingExclusion and newSniffingRedirect.
+	sniffingExcluded func(net.Destination) bool
+	sniffingRedirect func(string, net.Destination) (net.Destination, bool)
 
 	ctx context.Context
 }
@@ -63,6 +72,21 @@ func NewDynamicInboundHandler(ctx context.Context, tag string, receiverConfig *p
 
 	h.streamSettings = mss
 
+	h.sniffingExcluded, err = newSniffingExclusion(receiverConfig.GetEffectiveSniffingSettings())
+	if err != nil {
+		return nil, err
+	}
+	h.sniffingRedirect, err = newSniffingRedirect(receiverConfig.GetEffectiveSniffingSettings())
+	if err != nil {
+		return nil, err
+	}
+
+	h.knock, err = newKnockGate(receiverConfig.Knock, receiverConfig.Listen.AsAddress())
+	if err != nil {
+		return nil, err
+	}
+	h.ctx = contextWithListenGate(h.ctx, h.knock)
+
 	h.task = &task.Periodic{
 		Interval: time.Minute * time.Duration(h.receiverConfig.AllocationStrategy.GetRefreshValue()),
 		Execute:  h.refresh,
@@ -124,26 +148,29 @@ func (h *DynamicInboundHandler) refresh() error {
 
 	for i := uint32(0); i < concurrency; i++ {
 		port := h.allocatePort()
-		rawProxy, err := core.CreateObject(h.v, h.proxyConfig)
+		rawProxy, err := common.CreateObject(h.ctx, h.proxyConfig)
 		if err != nil {
 			errors.LogWarningInner(h.ctx, err, "failed to create proxy instance")
 			continue
 		}
 		p := rawProxy.(proxy.Inbound)
 		nl := p.Network()
-		if net.HasNetwork(nl, net.Network_TCP) {
+		if net.HasNetwork(nl, net.Network_TCP) || (h.streamSettings.CarriesUDP() && net.HasNetwork(nl, net.Network_UDP)) {
 			worker := &tcpWorker{
-				tag:             h.tag,
-				address:         address,
-				port:            port,
-				proxy:           p,
-				stream:          h.streamSettings,
-				recvOrigDest:    h.receiverConfig.ReceiveOriginalDestination,
-				dispatcher:      h.mux,
-				sniffingConfig:  h.receiverConfig.GetEffectiveSniffingSettings(),
-				uplinkCounter:   uplinkCounter,
-				downlinkCounter: downlinkCounter,
-				ctx:             h.ctx,
+				tag:              h.tag,
+				address:          address,
+				port:             port,
+				proxy:            p,
+				stream:           h.streamSettings,
+				recvOrigDest:     h.receiverConfig.ReceiveOriginalDestination,
+				dispatcher:       h.mux,
+				sniffingConfig:   h.receiverConfig.GetEffectiveSniffingSettings(),
+				sniffingExcluded: h.sniffingExcluded,
+				sniffingRedirect: h.sniffingRedirect,
+				uplinkCounter:    uplinkCounter,
+				downlinkCounter:  downlinkCounter,
+				connections:      &h.connections,
+				ctx:              h.ctx,
 			}
 			if err := worker.Start(); err != nil {
 				errors.LogWarningInner(h.ctx, err, "failed to create TCP worker")
@@ -152,18 +179,21 @@ func (h *DynamicInboundHandler) refresh() error {
 			workers = append(workers, worker)
 		}
 
-		if net.HasNetwork(nl, net.Network_UDP) {
+		if net.HasNetwork(nl, net.Network_UDP) && !h.streamSettings.CarriesUDP() {
 			worker := &udpWorker{
-				tag:             h.tag,
-				proxy:           p,
-				address:         address,
-				port:            port,
-				dispatcher:      h.mux,
-				sniffingConfig:  h.receiverConfig.GetEffectiveSniffingSettings(),
-				uplinkCounter:   uplinkCounter,
-				downlinkCounter: downlinkCounter,
-				stream:          h.streamSettings,
-				ctx:             h.ctx,
+				tag:              h.tag,
+				proxy:            p,
+				address:          address,
+				port:             port,
+				dispatcher:       h.mux,
+				sniffingConfig:   h.receiverConfig.GetEffectiveSniffingSettings(),
+				sniffingExcluded: h.sniffingExcluded,
+				sniffingRedirect: h.sniffingRedirect,
+				uplinkCounter:    uplinkCounter,
+				downlinkCounter:  downlinkCounter,
+				stream:           h.streamSettings,
+				connections:      &h.connections,
+				ctx:              h.ctx,
 			}
 			if err := worker.Start(); err != nil {
 				errors.LogWarningInner(h.ctx, err, "failed to create UDP worker")
@@ -185,13 +215,44 @@ func (h *DynamicInboundHandler) refresh() error {
 }
 
 func (h *DynamicInboundHandler) Start() error {
+	if h.knock != nil {
+		if err := h.knock.Start(); err != nil {
+			return err
+		}
+	}
 	return h.task.Start()
 }
 
 func (h *DynamicInboundHandler) Close() error {
+	if h.knock != nil {
+		h.knock.Close()
+	}
 	return h.task.Close()
 }
 
+// Suspend stops allocating workers, and closes the listening ones, until
+// Resume.
+func (h *DynamicInboundHandler) Suspend() error {
+	h.task.Close()
+	h.workerMutex.Lock()
+	workers := h.worker
+	h.worker = nil
+	h.workerMutex.Unlock()
+	h.closeWorkers(workers)
+	return nil
+}
+
+// Resume allocates workers again after Suspend.
+func (h *DynamicInboundHandler) Resume() error {
+	return h.task.Start()
+}
+
+// ActiveConnections returns the number of connections being processed,
+// including the ones of the workers closed since.
+func (h *DynamicInboundHandler) ActiveConnections() int64 {
+	return h.connections.Load()
+}
+
 func (h *DynamicInboundHandler) GetRandomInboundProxy() (interface{}, net.Port, int) {
 	h.workerMutex.RLock()
 	defer h.workerMutex.RUnlock()
@@ -208,6 +269,11 @@ func (h *DynamicInboundHandler) Tag() string {
 	return h.tag
 }
 
+// StreamSettings returns the stream settings of the workers of h.
+func (h *DynamicInboundHandler) StreamSettings() *internet.MemoryStreamConfig {
+	return h.streamSettings
+}
+
 // ReceiverSettings implements inbound.Handler.
 func (h *DynamicInboundHandler) ReceiverSettings() *serial.TypedMessage {
 	return serial.ToTypedMessage(h.receiverConfig)
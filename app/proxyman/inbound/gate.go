@@ -0,0 +1,44 @@
+package inbound
+
+import (
+	"context"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/extension"
+)
+
+// listenGate allows the sources to the listeners of a handler that are not
+// banned by the guard, and have knocked if the handler has a knock gate.
+type listenGate struct {
+	knock *knockGate
+	guard extension.Guard
+}
+
+// contextWithListenGate returns ctx with the gate of the handler of knock, if
+// it has one.
+func contextWithListenGate(ctx context.Context, knock *knockGate) context.Context {
+	gate := &listenGate{knock: knock}
+	if v := core.FromContext(ctx); v != nil {
+		gate.guard, _ = v.GetFeature(extension.GuardType()).(extension.Guard)
+	}
+	if gate.knock == nil && gate.guard == nil {
+		return ctx
+	}
+	return session.ContextWithListenGate(ctx, gate)
+}
+
+// Allow implements session.ListenGate.
+func (g *listenGate) Allow(ip net.IP) bool {
+	if g.guard != nil && g.guard.Banned(ip) {
+		return false
+	}
+	return g.knock == nil || g.knock.Allow(ip)
+}
+
+// AllowForwarded implements session.ListenGate. Forwarded sources are only
+// checked, against the bans, if the guard trusts them.
+func (g *listenGate) AllowForwarded(ip net.IP) bool {
+	return g.guard == nil || !g.guard.TrustsForwarded() || !g.guard.Banned(ip)
+}
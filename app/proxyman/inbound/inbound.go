@@ -125,6 +125,62 @@ func (m *Manager) Start() error {
 	return nil
 }
 
+// suspendable is an inbound handler that can stop accepting connections for
+// a while.
+type suspendable interface {
+	Suspend() error
+	Resume() error
+}
+
+// Suspend stops the handlers from accepting connections, keeping the ones
+// they have, until Resume. Handlers added meanwhile are not suspended.
+func (m *Manager) Suspend() error {
+	return m.forEachSuspendable(suspendable.Suspend)
+}
+
+// Resume makes the handlers accept connections again after Suspend.
+func (m *Manager) Resume() error {
+	return m.forEachSuspendable(suspendable.Resume)
+}
+
+func (m *Manager) forEachSuspendable(f func(suspendable) error) error {
+	m.access.RLock()
+	defer m.access.RUnlock()
+
+	var errs []error
+	for _, handler := range m.taggedHandlers {
+		if s, ok := handler.(suspendable); ok {
+			errs = append(errs, f(s))
+		}
+	}
+	for _, handler := range m.untaggedHandlers {
+		if s, ok := handler.(suspendable); ok {
+			errs = append(errs, f(s))
+		}
+	}
+	return errors.Combine(errs...)
+}
+
+// ActiveConnections returns the number of connections being processed by the
+// handlers that count them.
+func (m *Manager) ActiveConnections() int64 {
+	m.access.RLock()
+	defer m.access.RUnlock()
+
+	var n int64
+	for _, handler := range m.taggedHandlers {
+		if c, ok := handler.(interface{ ActiveConnections() int64 }); ok {
+			n += c.ActiveConnections()
+		}
+	}
+	for _, handler := range m.untaggedHandlers {
+		if c, ok := handler.(interface{ ActiveConnections() int64 }); ok {
+			n += c.ActiveConnections()
+		}
+	}
+	return n
+}
+
 // Close implements common.Closable.
 func (m *Manager) Close() error {
 	m.access.Lock()
@@ -177,6 +233,7 @@ func NewHandler(ctx context.Context, config *core.InboundHandlerConfig) (inbound
 	if streamSettings != nil && streamSettings.ProtocolName == "splithttp" {
 		ctx = session.ContextWithAllowedNetwork(ctx, net.Network_UDP)
 	}
+	ctx = proxyman.ContextWithNATBehavior(ctx, receiverSettings.Nat)
 
 	allocStrategy := receiverSettings.AllocationStrategy
 	if allocStrategy == nil || allocStrategy.Type == proxyman.AllocationStrategy_Always {
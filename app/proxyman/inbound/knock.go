@@ -0,0 +1,95 @@
+package inbound
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/knock"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+// knockGate is the knock.Gate of the listeners of a handler, with the socket
+// it receives the knocks on.
+type knockGate struct {
+	*knock.Gate
+	address net.Address
+	port    net.Port
+
+	access sync.Mutex
+	conn   net.PacketConn
+}
+
+// newKnockGate creates the gate of config, receiving knocks at address, or nil
+// if config is nil.
+func newKnockGate(config *proxyman.KnockConfig, address net.Address) (*knockGate, error) {
+	if config == nil {
+		return nil, nil
+	}
+	if len(config.Key) == 0 {
+		return nil, errors.New("knock without key")
+	}
+	if config.Port == 0 || config.Port > 65535 {
+		return nil, errors.New("invalid knock port ", config.Port)
+	}
+	if address == nil {
+		address = net.AnyIP
+	}
+	if address.Family().IsDomain() {
+		if address.Domain() != "localhost" {
+			return nil, errors.New("knock is not supported when listening on ", address)
+		}
+		address = net.LocalHostIP
+	}
+	return &knockGate{
+		Gate:    knock.NewGate([]byte(config.Key), time.Duration(config.Ttl)*time.Second),
+		address: address,
+		port:    net.Port(config.Port),
+	}, nil
+}
+
+// Start starts receiving knocks, if it has not.
+func (g *knockGate) Start() error {
+	g.access.Lock()
+	defer g.access.Unlock()
+
+	if g.conn != nil {
+		return nil
+	}
+	conn, err := internet.ListenSystemPacket(context.Background(), &net.UDPAddr{
+		IP:   g.address.IP(),
+		Port: int(g.port),
+	}, nil)
+	if err != nil {
+		return errors.New("failed to listen knocks on ", g.address, ":", g.port).Base(err)
+	}
+	g.conn = conn
+	go g.Serve(conn)
+	return nil
+}
+
+// Close stops receiving knocks.
+func (g *knockGate) Close() error {
+	g.access.Lock()
+	defer g.access.Unlock()
+
+	if g.conn == nil {
+		return nil
+	}
+	err := g.conn.Close()
+	g.conn = nil
+	return err
+}
+
+// listenContext returns the context for the listeners of a worker of ctx,
+// carrying the gate of its handler.
+func listenContext(ctx context.Context) context.Context {
+	if gate := session.ListenGateFromContext(ctx); gate != nil {
+		return session.ContextWithListenGate(context.Background(), gate)
+	}
+	return context.Background()
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/app/router"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	c "github.com/xtls/xray-core/common/ctx"
@@ -20,6 +21,7 @@ import (
 	"github.com/xtls/xray-core/features/stats"
 	"github.com/xtls/xray-core/proxy"
 	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/ebpf"
 	"github.com/xtls/xray-core/transport/internet/stat"
 	"github.com/xtls/xray-core/transport/internet/tcp"
 	"github.com/xtls/xray-core/transport/internet/udp"
@@ -31,19 +33,25 @@ type worker interface {
 	Close() error
 	Port() net.Port
 	Proxy() proxy.Inbound
+	// suspend stops listening, keeping the connections, until Start.
+	suspend() error
 }
 
 type tcpWorker struct {
-	address         net.Address
-	port            net.Port
-	proxy           proxy.Inbound
-	stream          *internet.MemoryStreamConfig
-	recvOrigDest    bool
-	tag             string
-	dispatcher      routing.Dispatcher
-	sniffingConfig  *proxyman.SniffingConfig
-	uplinkCounter   stats.Counter
-	downlinkCounter stats.Counter
+	address          net.Address
+	port             net.Port
+	proxy            proxy.Inbound
+	stream           *internet.MemoryStreamConfig
+	recvOrigDest     bool
+	tag              string
+	dispatcher       routing.Dispatcher
+	sniffingConfig   *proxyman.SniffingConfig
+	sniffingExcluded func(net.Destination) bool
+	sniffingRedirect func(string, net.Destination) (net.Destination, bool)
+	uplinkCounter    stats.Counter
+	downlinkCounter  stats.Counter
+	// connections counts the connections being processed, for the handler.
+	connections *atomic.Int64
 
 	hub internet.Listener
 
@@ -57,7 +65,89 @@ func getTProxyType(s *internet.MemoryStreamConfig) internet.SocketConfig_TProxyM
 	return s.SocketSettings.Tproxy
 }
 
+// newSniffingExclusion returns whether a destination is excluded from sniffing
+// by config, or nil if none is.
+func newSniffingExclusion(config *proxyman.SniffingConfig) (func(net.Destination) bool, error) {
+	if config == nil || (len(config.PortsExcluded.GetRange()) == 0 && len(config.IpsExcluded) == 0) {
+		return nil, nil
+	}
+	var ports net.MemoryPortList
+	if config.PortsExcluded != nil {
+		ports = net.PortListFromProto(config.PortsExcluded)
+	}
+	var ips []*router.GeoIPMatcher
+	for _, geoip := range config.IpsExcluded {
+		matcher, err := router.GlobalGeoIPContainer.Add(geoip)
+		if err != nil {
+			return nil, errors.New("failed to build IPs excluded from sniffing").Base(err)
+		}
+		ips = append(ips, matcher)
+	}
+	return func(dest net.Destination) bool {
+		if ports.Contains(dest.Port) {
+			return true
+		}
+		if dest.Address.Family().IsIP() {
+			for _, matcher := range ips {
+				if matcher.Match(dest.Address.IP()) {
+					return true
+				}
+			}
+		}
+		return false
+	}, nil
+}
+
+// newSniffingRedirect returns the function redirecting connections to the
+// domains sniffed, or nil if there are no redirects.
+func newSniffingRedirect(config *proxyman.SniffingConfig) (func(string, net.Destination) (net.Destination, bool), error) {
+	if config == nil || len(config.Redirect) == 0 {
+		return nil, nil
+	}
+	type redirect struct {
+		matcher *router.DomainMatcher
+		host    net.Address
+		port    net.Port
+	}
+	redirects := make([]redirect, 0, len(config.Redirect))
+	for _, r := range config.Redirect {
+		matcher, err := router.NewDomainMatcher(r.Domain)
+		if err != nil {
+			return nil, errors.New("failed to build domains of sniffing redirect").Base(err)
+		}
+		host, port := r.Destination, ""
+		if h, p, err := net.SplitHostPort(r.Destination); err == nil {
+			host, port = h, p
+		}
+		if host == "" {
+			return nil, errors.New("sniffing redirect without host: ", r.Destination)
+		}
+		rd := redirect{matcher: matcher, host: net.ParseAddress(host)}
+		if port != "" {
+			if rd.port, err = net.PortFromString(port); err != nil {
+				return nil, errors.New("invalid port of sniffing redirect: ", r.Destination).Base(err)
+			}
+		}
+		redirects = append(redirects, rd)
+	}
+	return func(domain string, dest net.Destination) (net.Destination, bool) {
+		for _, r := range redirects {
+			if r.matcher.ApplyDomain(domain) {
+				dest.Address = r.host
+				if r.port != 0 {
+					dest.Port = r.port
+				}
+				return dest, true
+			}
+		}
+		return dest, false
+	}, nil
+}
+
 func (w *tcpWorker) callback(conn stat.Connection) {
+	w.connections.Add(1)
+	defer w.connections.Add(-1)
+
 	ctx, cancel := context.WithCancel(w.ctx)
 	sid := session.NewID()
 	ctx = c.ContextWithID(ctx, sid)
@@ -66,13 +156,20 @@ func (w *tcpWorker) callback(conn stat.Connection) {
 	if w.recvOrigDest {
 		var dest net.Destination
 		switch getTProxyType(w.stream) {
-		case internet.SocketConfig_Redirect:
+		case internet.SocketConfig_Redirect, internet.SocketConfig_WinDivert:
 			d, err := tcp.GetOriginalDestination(conn)
 			if err != nil {
 				errors.LogInfoInner(ctx, err, "failed to get original destination")
 			} else {
 				dest = d
 			}
+		case internet.SocketConfig_Ebpf:
+			d, err := ebpf.OriginalDestination(conn.RemoteAddr())
+			if err != nil {
+				errors.LogInfoInner(ctx, err, "failed to get original destination")
+			} else {
+				dest = d
+			}
 		case internet.SocketConfig_TProxy:
 			dest = net.DestinationFromAddr(conn.LocalAddr())
 		}
@@ -90,10 +187,11 @@ func (w *tcpWorker) callback(conn stat.Connection) {
 		}
 	}
 	ctx = session.ContextWithInbound(ctx, &session.Inbound{
-		Source:  net.DestinationFromAddr(conn.RemoteAddr()),
-		Gateway: net.TCPDestination(w.address, w.port),
-		Tag:     w.tag,
-		Conn:    conn,
+		Source:   net.DestinationFromAddr(conn.RemoteAddr()),
+		Gateway:  net.TCPDestination(w.address, w.port),
+		Tag:      w.tag,
+		Conn:     conn,
+		Accepted: time.Now(),
 	})
 
 	content := new(session.Content)
@@ -103,10 +201,22 @@ func (w *tcpWorker) callback(conn stat.Connection) {
 		content.SniffingRequest.ExcludeForDomain = w.sniffingConfig.DomainsExcluded
 		content.SniffingRequest.MetadataOnly = w.sniffingConfig.MetadataOnly
 		content.SniffingRequest.RouteOnly = w.sniffingConfig.RouteOnly
+		content.SniffingRequest.ExcludeForDestination = w.sniffingExcluded
+		content.SniffingRequest.RedirectForDomain = w.sniffingRedirect
 	}
 	ctx = session.ContextWithContent(ctx, content)
 
-	if err := w.proxy.Process(ctx, net.Network_TCP, conn, w.dispatcher); err != nil {
+	network := net.Network_TCP
+	if _, ok := conn.(stat.PacketConnection); ok {
+		network = net.Network_UDP
+	}
+	if !net.HasNetwork(w.proxy.Network(), network) {
+		errors.LogInfo(ctx, "dropping ", network, " connection that the inbound does not accept")
+		cancel()
+		conn.Close()
+		return
+	}
+	if err := w.proxy.Process(ctx, network, conn, w.dispatcher); err != nil {
 		errors.LogInfoInner(ctx, err, "connection ends")
 	}
 	cancel()
@@ -118,7 +228,7 @@ func (w *tcpWorker) Proxy() proxy.Inbound {
 }
 
 func (w *tcpWorker) Start() error {
-	ctx := context.Background()
+	ctx := listenContext(w.ctx)
 	hub, err := internet.ListenTCP(ctx, w.address, w.port, w.stream, func(conn stat.Connection) {
 		go w.callback(conn)
 	})
@@ -135,9 +245,9 @@ func (w *tcpWorker) Close() error {
 		if err := common.Close(w.hub); err != nil {
 			errs = append(errs, err)
 		}
-		if err := common.Close(w.proxy); err != nil {
-			errs = append(errs, err)
-		}
+	}
+	if err := common.Close(w.proxy); err != nil {
+		errs = append(errs, err)
 	}
 	if len(errs) > 0 {
 		return errors.New("failed to close all resources").Base(errors.New(serial.Concat(errs...)))
@@ -146,6 +256,15 @@ func (w *tcpWorker) Close() error {
 	return nil
 }
 
+func (w *tcpWorker) suspend() error {
+	if w.hub == nil {
+		return nil
+	}
+	err := w.hub.Close()
+	w.hub = nil
+	return err
+}
+
 func (w *tcpWorker) Port() net.Port {
 	return w.port
 }
@@ -155,6 +274,7 @@ type udpConn struct {
 	reader           buf.Reader
 	writer           buf.Writer
 	output           func([]byte) (int, error)
+	outputMulti      func(buf.MultiBuffer) (int, error)
 	remote           net.Addr
 	local            net.Addr
 	done             *done.Instance
@@ -162,6 +282,9 @@ type udpConn struct {
 	downlink         stats.Counter
 	inactive         bool
 	cancel           context.CancelFunc
+	// keep is how long the conn is kept while idle, in seconds, if longer
+	// than udpIdle.
+	keep atomic.Int64
 }
 
 func (c *udpConn) setInactive() {
@@ -203,6 +326,18 @@ func (c *udpConn) Write(buf []byte) (int, error) {
 	return n, err
 }
 
+// WriteMultiBuffer implements buf.Writer, writing the packets of mb together.
+func (c *udpConn) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	n, err := c.outputMulti(mb)
+	if c.downlink != nil {
+		c.downlink.Add(int64(n))
+	}
+	if err == nil {
+		c.updateActivity()
+	}
+	return err
+}
+
 func (c *udpConn) Close() error {
 	if c.cancel != nil {
 		c.cancel()
@@ -240,19 +375,25 @@ type connID struct {
 type udpWorker struct {
 	sync.RWMutex
 
-	proxy           proxy.Inbound
-	hub             *udp.Hub
-	address         net.Address
-	port            net.Port
-	tag             string
-	stream          *internet.MemoryStreamConfig
-	dispatcher      routing.Dispatcher
-	sniffingConfig  *proxyman.SniffingConfig
-	uplinkCounter   stats.Counter
-	downlinkCounter stats.Counter
+	proxy            proxy.Inbound
+	hub              *udp.Hub
+	address          net.Address
+	port             net.Port
+	tag              string
+	stream           *internet.MemoryStreamConfig
+	dispatcher       routing.Dispatcher
+	sniffingConfig   *proxyman.SniffingConfig
+	sniffingExcluded func(net.Destination) bool
+	sniffingRedirect func(string, net.Destination) (net.Destination, bool)
+	uplinkCounter    stats.Counter
+	downlinkCounter  stats.Counter
+	// connections counts the connections being processed, for the handler.
+	connections *atomic.Int64
 
 	checker    *task.Periodic
 	activeConn map[connID]*udpConn
+	// suspended is whether hub has been closed by suspend.
+	suspended bool
 
 	ctx  context.Context
 	cone bool
@@ -274,6 +415,9 @@ func (w *udpWorker) getConnection(id connID) (*udpConn, bool) {
 		output: func(b []byte) (int, error) {
 			return w.hub.WriteTo(b, id.src)
 		},
+		outputMulti: func(mb buf.MultiBuffer) (int, error) {
+			return w.hub.WriteMultiBufferTo(mb, id.src)
+		},
 		remote: &net.UDPAddr{
 			IP:   id.src.Address.IP(),
 			Port: int(id.src.Port),
@@ -310,7 +454,10 @@ func (w *udpWorker) callback(b *buf.Buffer, source net.Destination, originalDest
 	if !existing {
 		common.Must(w.checker.Start())
 
+		w.connections.Add(1)
 		go func() {
+			defer w.connections.Add(-1)
+
 			ctx, cancel := context.WithCancel(w.ctx)
 			conn.cancel = cancel
 			sid := session.NewID()
@@ -322,9 +469,13 @@ func (w *udpWorker) callback(b *buf.Buffer, source net.Destination, originalDest
 			}
 			ctx = session.ContextWithOutbounds(ctx, outbounds)
 			ctx = session.ContextWithInbound(ctx, &session.Inbound{
-				Source:  source,
-				Gateway: net.UDPDestination(w.address, w.port),
-				Tag:     w.tag,
+				Source:   source,
+				Gateway:  net.UDPDestination(w.address, w.port),
+				Tag:      w.tag,
+				Accepted: time.Now(),
+				KeepMapping: func(d time.Duration) {
+					conn.keep.Store(int64(d / time.Second))
+				},
 			})
 			content := new(session.Content)
 			if w.sniffingConfig != nil {
@@ -333,6 +484,8 @@ func (w *udpWorker) callback(b *buf.Buffer, source net.Destination, originalDest
 				content.SniffingRequest.ExcludeForDomain = w.sniffingConfig.DomainsExcluded
 				content.SniffingRequest.MetadataOnly = w.sniffingConfig.MetadataOnly
 				content.SniffingRequest.RouteOnly = w.sniffingConfig.RouteOnly
+				content.SniffingRequest.ExcludeForDestination = w.sniffingExcluded
+				content.SniffingRequest.RedirectForDomain = w.sniffingRedirect
 			}
 			ctx = session.ContextWithContent(ctx, content)
 			if err := w.proxy.Process(ctx, net.Network_UDP, conn, w.dispatcher); err != nil {
@@ -361,6 +514,9 @@ func (w *udpWorker) handlePackets() {
 	}
 }
 
+// udpIdle is how long conns are kept while idle, in seconds.
+const udpIdle = int64(internet.UDPSessionIdle / time.Second)
+
 func (w *udpWorker) clean() error {
 	nowSec := time.Now().Unix()
 	w.Lock()
@@ -371,7 +527,7 @@ func (w *udpWorker) clean() error {
 	}
 
 	for addr, conn := range w.activeConn {
-		if nowSec-atomic.LoadInt64(&conn.lastActivityTime) > 2*60 {
+		if nowSec-atomic.LoadInt64(&conn.lastActivityTime) > max(udpIdle, conn.keep.Load()) {
 			if !conn.inactive {
 				conn.setInactive()
 				delete(w.activeConn, addr)
@@ -389,7 +545,7 @@ func (w *udpWorker) clean() error {
 
 func (w *udpWorker) Start() error {
 	w.activeConn = make(map[connID]*udpConn, 16)
-	ctx := context.Background()
+	ctx := listenContext(w.ctx)
 	h, err := udp.ListenUDP(ctx, w.address, w.port, w.stream, udp.HubCapacity(256))
 	if err != nil {
 		return err
@@ -403,17 +559,40 @@ func (w *udpWorker) Start() error {
 	}
 
 	w.hub = h
+	w.suspended = false
 	go w.handlePackets()
 	return nil
 }
 
+func (w *udpWorker) suspend() error {
+	w.Lock()
+	defer w.Unlock()
+
+	var errs []interface{}
+	if w.hub != nil && !w.suspended {
+		if err := w.hub.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		w.suspended = true
+	}
+	if w.checker != nil {
+		if err := w.checker.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New("failed to suspend").Base(errors.New(serial.Concat(errs...)))
+	}
+	return nil
+}
+
 func (w *udpWorker) Close() error {
 	w.Lock()
 	defer w.Unlock()
 
 	var errs []interface{}
 
-	if w.hub != nil {
+	if w.hub != nil && !w.suspended {
 		if err := w.hub.Close(); err != nil {
 			errs = append(errs, err)
 		}
@@ -444,14 +623,18 @@ func (w *udpWorker) Proxy() proxy.Inbound {
 }
 
 type dsWorker struct {
-	address         net.Address
-	proxy           proxy.Inbound
-	stream          *internet.MemoryStreamConfig
-	tag             string
-	dispatcher      routing.Dispatcher
-	sniffingConfig  *proxyman.SniffingConfig
-	uplinkCounter   stats.Counter
-	downlinkCounter stats.Counter
+	address          net.Address
+	proxy            proxy.Inbound
+	stream           *internet.MemoryStreamConfig
+	tag              string
+	dispatcher       routing.Dispatcher
+	sniffingConfig   *proxyman.SniffingConfig
+	sniffingExcluded func(net.Destination) bool
+	sniffingRedirect func(string, net.Destination) (net.Destination, bool)
+	uplinkCounter    stats.Counter
+	downlinkCounter  stats.Counter
+	// connections counts the connections being processed, for the handler.
+	connections *atomic.Int64
 
 	hub internet.Listener
 
@@ -459,9 +642,13 @@ type dsWorker struct {
 }
 
 func (w *dsWorker) callback(conn stat.Connection) {
+	w.connections.Add(1)
+	defer w.connections.Add(-1)
+
 	ctx, cancel := context.WithCancel(w.ctx)
 	sid := session.NewID()
 	ctx = c.ContextWithID(ctx, sid)
+	ctx = session.ContextWithOutbounds(ctx, []*session.Outbound{{}})
 
 	if w.uplinkCounter != nil || w.downlinkCounter != nil {
 		conn = &stat.CounterConnection{
@@ -471,10 +658,11 @@ func (w *dsWorker) callback(conn stat.Connection) {
 		}
 	}
 	ctx = session.ContextWithInbound(ctx, &session.Inbound{
-		Source:  net.DestinationFromAddr(conn.RemoteAddr()),
-		Gateway: net.UnixDestination(w.address),
-		Tag:     w.tag,
-		Conn:    conn,
+		Source:   net.DestinationFromAddr(conn.RemoteAddr()),
+		Gateway:  net.UnixDestination(w.address),
+		Tag:      w.tag,
+		Conn:     conn,
+		Accepted: time.Now(),
 	})
 
 	content := new(session.Content)
@@ -484,6 +672,8 @@ func (w *dsWorker) callback(conn stat.Connection) {
 		content.SniffingRequest.ExcludeForDomain = w.sniffingConfig.DomainsExcluded
 		content.SniffingRequest.MetadataOnly = w.sniffingConfig.MetadataOnly
 		content.SniffingRequest.RouteOnly = w.sniffingConfig.RouteOnly
+		content.SniffingRequest.ExcludeForDestination = w.sniffingExcluded
+		content.SniffingRequest.RedirectForDomain = w.sniffingRedirect
 	}
 	ctx = session.ContextWithContent(ctx, content)
 
@@ -505,7 +695,7 @@ func (w *dsWorker) Port() net.Port {
 }
 
 func (w *dsWorker) Start() error {
-	ctx := context.Background()
+	ctx := listenContext(w.ctx)
 	hub, err := internet.ListenUnix(ctx, w.address, w.stream, func(conn stat.Connection) {
 		go w.callback(conn)
 	})
@@ -516,15 +706,24 @@ func (w *dsWorker) Start() error {
 	return nil
 }
 
+func (w *dsWorker) suspend() error {
+	if w.hub == nil {
+		return nil
+	}
+	err := w.hub.Close()
+	w.hub = nil
+	return err
+}
+
 func (w *dsWorker) Close() error {
 	var errs []interface{}
 	if w.hub != nil {
 		if err := common.Close(w.hub); err != nil {
 			errs = append(errs, err)
 		}
-		if err := common.Close(w.proxy); err != nil {
-			errs = append(errs, err)
-		}
+	}
+	if err := common.Close(w.proxy); err != nil {
+		errs = append(errs, err)
 	}
 	if len(errs) > 0 {
 		return errors.New("failed to close all resources").Base(errors.New(serial.Concat(errs...)))
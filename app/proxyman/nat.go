@@ -0,0 +1,139 @@
+package proxyman
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport"
+)
+
+type natBehaviorKey struct{}
+
+// ContextWithNATBehavior returns ctx where UDP is relayed as of behavior. It
+// overrides the cone behavior of the proxies created with it and of the
+// connections of it, unless behavior is Default.
+func ContextWithNATBehavior(ctx context.Context, behavior NATBehavior) context.Context {
+	if behavior == NATBehavior_Default {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, "cone", behavior != NATBehavior_Symmetric)
+	return context.WithValue(ctx, natBehaviorKey{}, behavior)
+}
+
+// NATBehaviorFromContext returns the NAT behavior of ctx, Default if none.
+func NATBehaviorFromContext(ctx context.Context) NATBehavior {
+	if behavior, ok := ctx.Value(natBehaviorKey{}).(NATBehavior); ok {
+		return behavior
+	}
+	return NATBehavior_Default
+}
+
+// FilterNAT drops the packets relayed back through link from the addresses,
+// or the addresses and ports, not sent to, as of behavior, if dest is UDP.
+// The packets of link are read from its reader and relayed back to its
+// writer, as they are for outbounds.
+func FilterNAT(link *transport.Link, behavior NATBehavior, dest net.Destination) {
+	if dest.Network != net.Network_UDP || (behavior != NATBehavior_AddressRestricted && behavior != NATBehavior_Symmetric) {
+		return
+	}
+	f := &natFilter{
+		port: behavior == NATBehavior_Symmetric,
+		dest: dest,
+		sent: make(map[string]struct{}),
+	}
+	link.Reader = &natReader{reader: link.Reader, filter: f}
+	link.Writer = &natWriter{writer: link.Writer, filter: f}
+}
+
+// natFilter keeps the endpoints packets are sent to. Packets without their
+// own destination or source are of dest. Endpoints are as the client wrote
+// them, domains included, which freedom tags the replies from the addresses
+// it resolved them to, or synthesized for them by NAT64, with.
+type natFilter struct {
+	access sync.RWMutex
+	port   bool
+	dest   net.Destination
+	sent   map[string]struct{}
+}
+
+func (f *natFilter) key(dest *net.Destination) string {
+	if dest == nil {
+		dest = &f.dest
+	}
+	if f.port {
+		return dest.NetAddr()
+	}
+	return dest.Address.String()
+}
+
+func (f *natFilter) send(mb buf.MultiBuffer) {
+	f.access.Lock()
+	defer f.access.Unlock()
+	for _, b := range mb {
+		f.sent[f.key(b.UDP)] = struct{}{}
+	}
+}
+
+func (f *natFilter) receive(mb buf.MultiBuffer) buf.MultiBuffer {
+	f.access.RLock()
+	defer f.access.RUnlock()
+	filtered := mb[:0]
+	for _, b := range mb {
+		if _, found := f.sent[f.key(b.UDP)]; found || b.UDP == nil {
+			filtered = append(filtered, b)
+		} else {
+			b.Release()
+		}
+	}
+	return filtered
+}
+
+type natReader struct {
+	reader buf.Reader
+	filter *natFilter
+}
+
+func (r *natReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	mb, err := r.reader.ReadMultiBuffer()
+	r.filter.send(mb)
+	return mb, err
+}
+
+func (r *natReader) ReadMultiBufferTimeout(timeout time.Duration) (buf.MultiBuffer, error) {
+	reader, ok := r.reader.(buf.TimeoutReader)
+	if !ok {
+		return r.ReadMultiBuffer()
+	}
+	mb, err := reader.ReadMultiBufferTimeout(timeout)
+	r.filter.send(mb)
+	return mb, err
+}
+
+func (r *natReader) Interrupt() {
+	common.Interrupt(r.reader)
+}
+
+type natWriter struct {
+	writer buf.Writer
+	filter *natFilter
+}
+
+func (w *natWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	mb = w.filter.receive(mb)
+	if mb.IsEmpty() {
+		return nil
+	}
+	return w.writer.WriteMultiBuffer(mb)
+}
+
+func (w *natWriter) Close() error {
+	return common.Close(w.writer)
+}
+
+func (w *natWriter) Interrupt() {
+	common.Interrupt(w.writer)
+}
@@ -8,6 +8,7 @@ import (
 	"math/big"
 	gonet "net"
 	"os"
+	"time"
 
 	"github.com/xtls/xray-core/app/proxyman"
 	"github.com/xtls/xray-core/common"
@@ -56,10 +57,42 @@ func getStatCounter(v *core.Instance, tag string) (stats.Counter, stats.Counter)
 	return uplinkCounter, downlinkCounter
 }
 
+// getSpliceCounter registers the counter of the traffic an outbound splices in
+// the kernel, when its traffic has stats.
+func getSpliceCounter(v *core.Instance, tag string) stats.Counter {
+	policy := v.GetFeature(policy.ManagerType()).(policy.Manager)
+	if len(tag) == 0 || !policy.ForSystem().Stats.OutboundUplink && !policy.ForSystem().Stats.OutboundDownlink {
+		return nil
+	}
+	statsManager := v.GetFeature(stats.ManagerType()).(stats.Manager)
+	c, _ := stats.GetOrRegisterCounter(statsManager, "outbound>>>"+tag+">>>traffic>>>spliced")
+	return c
+}
+
+// getMuxStats registers the gauges of the mux connections of an outbound,
+// when stats are enabled.
+func getMuxStats(v *core.Instance, tag string) mux.ClientStats {
+	var muxStats mux.ClientStats
+	if len(tag) == 0 {
+		return muxStats
+	}
+	statsManager := v.GetFeature(stats.ManagerType()).(stats.Manager)
+	if c, _ := stats.GetOrRegisterCounter(statsManager, "outbound>>>"+tag+">>>mux>>>connections"); c != nil {
+		muxStats.Connections = c
+	}
+	if c, _ := stats.GetOrRegisterCounter(statsManager, "outbound>>>"+tag+">>>mux>>>sessions"); c != nil {
+		muxStats.Sessions = c
+	}
+	return muxStats
+}
+
 // Handler implements outbound.Handler.
 type Handler struct {
 	tag             string
 	senderSettings  *proxyman.SenderConfig
+	viaPool         *viaPool
+	dialSettings    *proxyman.DialConfig
+	nat             proxyman.NATBehavior
 	streamSettings  *internet.MemoryStreamConfig
 	proxyConfig     proto.Message
 	proxy           proxy.Outbound
@@ -69,6 +102,10 @@ type Handler struct {
 	udp443          string
 	uplinkCounter   stats.Counter
 	downlinkCounter stats.Counter
+	spliceCounter   stats.Counter
+	// terminationStats counts the terminations of the connections, nil if not
+	// enabled.
+	terminationStats stats.Manager
 }
 
 // NewHandler creates a new Handler based on the given configuration.
@@ -76,10 +113,12 @@ func NewHandler(ctx context.Context, config *core.OutboundHandlerConfig) (outbou
 	v := core.MustFromContext(ctx)
 	uplinkCounter, downlinkCounter := getStatCounter(v, config.Tag)
 	h := &Handler{
-		tag:             config.Tag,
-		outboundManager: v.GetFeature(outbound.ManagerType()).(outbound.Manager),
-		uplinkCounter:   uplinkCounter,
-		downlinkCounter: downlinkCounter,
+		tag:              config.Tag,
+		outboundManager:  v.GetFeature(outbound.ManagerType()).(outbound.Manager),
+		uplinkCounter:    uplinkCounter,
+		downlinkCounter:  downlinkCounter,
+		spliceCounter:    getSpliceCounter(v, config.Tag),
+		terminationStats: getTerminationStats(v, config.Tag),
 	}
 
 	if config.SenderSettings != nil {
@@ -95,6 +134,15 @@ func NewHandler(ctx context.Context, config *core.OutboundHandlerConfig) (outbou
 				return nil, errors.New("failed to parse stream settings").Base(err).AtWarning()
 			}
 			h.streamSettings = mss
+			h.dialSettings = s.DialSettings
+			h.nat = s.Nat
+			ctx = proxyman.ContextWithNATBehavior(ctx, s.Nat)
+			if len(s.ViaPool) > 0 {
+				h.viaPool, err = newViaPool(s.ViaPool, s.ViaStrategy)
+				if err != nil {
+					return nil, err
+				}
+			}
 		default:
 			return nil, errors.New("settings is not SenderConfig")
 		}
@@ -118,6 +166,15 @@ func NewHandler(ctx context.Context, config *core.OutboundHandlerConfig) (outbou
 
 	if h.senderSettings != nil && h.senderSettings.MultiplexSettings != nil {
 		if config := h.senderSettings.MultiplexSettings; config.Enabled {
+			strategy := mux.ClientStrategy{
+				MaxConnection: 128,
+				MaxLifetime:   time.Duration(config.MaxLifetime) * time.Second,
+				Padding:       config.Padding,
+			}
+			if config.MaxConnections > 0 {
+				strategy.MaxConnection = uint32(config.MaxConnections)
+			}
+			muxStats := getMuxStats(v, h.tag)
 			if config.Concurrency < 0 {
 				h.mux = &mux.ClientManager{Enabled: false}
 			}
@@ -125,16 +182,15 @@ func NewHandler(ctx context.Context, config *core.OutboundHandlerConfig) (outbou
 				config.Concurrency = 8 // same as before
 			}
 			if config.Concurrency > 0 {
+				strategy.MaxConcurrency = uint32(config.Concurrency)
 				h.mux = &mux.ClientManager{
 					Enabled: true,
 					Picker: &mux.IncrementalWorkerPicker{
 						Factory: &mux.DialingWorkerFactory{
-							Proxy:  proxyHandler,
-							Dialer: h,
-							Strategy: mux.ClientStrategy{
-								MaxConcurrency: uint32(config.Concurrency),
-								MaxConnection:  128,
-							},
+							Proxy:    proxyHandler,
+							Dialer:   h,
+							Strategy: strategy,
+							Stats:    muxStats,
 						},
 					},
 				}
@@ -146,16 +202,15 @@ func NewHandler(ctx context.Context, config *core.OutboundHandlerConfig) (outbou
 				h.xudp = nil // same as before
 			}
 			if config.XudpConcurrency > 0 {
+				strategy.MaxConcurrency = uint32(config.XudpConcurrency)
 				h.xudp = &mux.ClientManager{
 					Enabled: true,
 					Picker: &mux.IncrementalWorkerPicker{
 						Factory: &mux.DialingWorkerFactory{
-							Proxy:  proxyHandler,
-							Dialer: h,
-							Strategy: mux.ClientStrategy{
-								MaxConcurrency: uint32(config.XudpConcurrency),
-								MaxConnection:  128,
-							},
+							Proxy:    proxyHandler,
+							Dialer:   h,
+							Strategy: strategy,
+							Stats:    muxStats,
 						},
 					},
 				}
@@ -175,15 +230,35 @@ func (h *Handler) Tag() string {
 
 // Dispatch implements proxy.Outbound.Dispatch.
 func (h *Handler) Dispatch(ctx context.Context, link *transport.Link) {
+	// Each connection counts its dials apart, and does not inherit the
+	// policy of the outbound proxying it.
+	if d := h.dialSettings; d != nil {
+		ctx = session.ContextWithDialPolicy(ctx, &session.DialPolicy{
+			Timeout:  time.Duration(d.Timeout),
+			Attempts: int(d.Attempts),
+			Backoff:  time.Duration(d.Backoff),
+			NextIP:   d.NextIp,
+		})
+	} else if session.DialPolicyFromContext(ctx) != nil {
+		ctx = session.ContextWithDialPolicy(ctx, nil)
+	}
+	if session.TerminationFromContext(ctx) == nil {
+		ctx, _ = session.ContextWithTermination(ctx)
+	}
 	outbounds := session.OutboundsFromContext(ctx)
 	ob := outbounds[len(outbounds)-1]
 	if ob.Target.Network == net.Network_UDP && ob.OriginalTarget.Address != nil && ob.OriginalTarget.Address != ob.Target.Address {
 		link.Reader = &buf.EndpointOverrideReader{Reader: link.Reader, Dest: ob.Target.Address, OriginalDest: ob.OriginalTarget.Address}
 		link.Writer = &buf.EndpointOverrideWriter{Writer: link.Writer, Dest: ob.Target.Address, OriginalDest: ob.OriginalTarget.Address}
 	}
+	if h.nat != proxyman.NATBehavior_Default {
+		ctx = proxyman.ContextWithNATBehavior(ctx, h.nat)
+		proxyman.FilterNAT(link, h.nat, ob.Target)
+	}
 	if h.mux != nil {
 		test := func(err error) {
 			if err != nil {
+				h.terminate(ctx, err)
 				err := errors.New("failed to process mux outbound traffic").Base(err)
 				session.SubmitOutboundErrorToOriginator(ctx, err)
 				errors.LogInfo(ctx, err.Error())
@@ -213,6 +288,7 @@ func (h *Handler) Dispatch(ctx context.Context, link *transport.Link) {
 	}
 out:
 	err := h.proxy.Process(ctx, link, h)
+	h.terminate(ctx, err)
 	if err != nil {
 		if goerrors.Is(err, io.EOF) || goerrors.Is(err, io.ErrClosedPipe) || goerrors.Is(err, context.Canceled) {
 			err = nil
@@ -275,7 +351,12 @@ func (h *Handler) Dial(ctx context.Context, dest net.Destination) (stat.Connecti
 			errors.LogWarning(ctx, "failed to get outbound handler with tag: ", tag)
 		}
 
-		if h.senderSettings.Via != nil {
+		if h.viaPool != nil {
+			outbounds := session.OutboundsFromContext(ctx)
+			ob := outbounds[len(outbounds)-1]
+			ob.Gateway = h.viaPool.pick(ob.Target, dest)
+			errors.LogDebug(ctx, "send through ", ob.Gateway, " from the pool")
+		} else if h.senderSettings.Via != nil {
 
 			outbounds := session.OutboundsFromContext(ctx)
 			ob := outbounds[len(outbounds)-1]
@@ -322,6 +403,9 @@ func (h *Handler) Dial(ctx context.Context, dest net.Destination) (stat.Connecti
 	}
 
 	conn, err := internet.Dial(ctx, dest, h.streamSettings)
+	if record := session.TerminationFromContext(ctx); record != nil {
+		record.Dialed(err)
+	}
 	conn = h.getStatCouterConnection(conn)
 	outbounds := session.OutboundsFromContext(ctx)
 	ob := outbounds[len(outbounds)-1]
@@ -332,9 +416,10 @@ func (h *Handler) Dial(ctx context.Context, dest net.Destination) (stat.Connecti
 func (h *Handler) getStatCouterConnection(conn stat.Connection) stat.Connection {
 	if h.uplinkCounter != nil || h.downlinkCounter != nil {
 		return &stat.CounterConnection{
-			Connection:   conn,
-			ReadCounter:  h.downlinkCounter,
-			WriteCounter: h.uplinkCounter,
+			Connection:    conn,
+			ReadCounter:   h.downlinkCounter,
+			WriteCounter:  h.uplinkCounter,
+			SpliceCounter: h.spliceCounter,
 		}
 	}
 	return conn
@@ -0,0 +1,97 @@
+package outbound
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	goerrors "errors"
+	"io"
+	gonet "net"
+	"strings"
+	"syscall"
+
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/features/stats"
+)
+
+// getTerminationStats returns the stats manager to count the terminations of
+// the connections of an outbound in, when enabled.
+func getTerminationStats(v *core.Instance, tag string) stats.Manager {
+	policy := v.GetFeature(policy.ManagerType()).(policy.Manager)
+	if len(tag) == 0 || !policy.ForSystem().Stats.OutboundTermination {
+		return nil
+	}
+	return v.GetFeature(stats.ManagerType()).(stats.Manager)
+}
+
+// terminate records the termination of the connection of ctx for err, unless
+// a handler it is forwarded to records it first, and counts it.
+func (h *Handler) terminate(ctx context.Context, err error) {
+	record := session.TerminationFromContext(ctx)
+	if record == nil {
+		return
+	}
+	_, dialErr := record.Termination()
+	record.Terminate(classifyTermination(err, dialErr))
+	if h.terminationStats == nil {
+		return
+	}
+	t, _ := record.Termination()
+	c, _ := stats.GetOrRegisterCounter(h.terminationStats, "outbound>>>"+h.tag+">>>termination>>>"+string(t))
+	if c != nil {
+		c.Add(1)
+	}
+}
+
+// classifyTermination returns the class of err a connection terminates with,
+// given the error of its last dial.
+func classifyTermination(err error, dialErr error) session.Termination {
+	switch {
+	case err == nil:
+		return session.TerminationClosed
+	case dialErr != nil && !goerrors.Is(dialErr, context.Canceled):
+		switch {
+		case isTLSError(dialErr):
+			return session.TerminationTLSHandshake
+		case isTimeout(dialErr):
+			return session.TerminationDialTimeout
+		default:
+			return session.TerminationDialFailed
+		}
+	case goerrors.Is(err, io.EOF) || goerrors.Is(err, io.ErrClosedPipe) || goerrors.Is(err, context.Canceled):
+		return session.TerminationClosed
+	case isTLSError(err):
+		return session.TerminationTLSHandshake
+	case goerrors.Is(err, syscall.ECONNRESET) || strings.Contains(err.Error(), "connection reset"):
+		return session.TerminationReset
+	default:
+		return session.TerminationError
+	}
+}
+
+// isTLSError returns whether err is of a TLS or REALITY handshake. Errors
+// wrapped as messages are told by their text.
+func isTLSError(err error) bool {
+	var recordHeaderErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var verificationErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if goerrors.As(err, &recordHeaderErr) || goerrors.As(err, &alertErr) || goerrors.As(err, &verificationErr) ||
+		goerrors.As(err, &unknownAuthorityErr) || goerrors.As(err, &hostnameErr) || goerrors.As(err, &invalidErr) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "tls: ") || strings.Contains(msg, "REALITY")
+}
+
+func isTimeout(err error) bool {
+	var netErr gonet.Error
+	if goerrors.As(err, &netErr) && netErr.Timeout() || goerrors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	return strings.Contains(err.Error(), "timeout")
+}
@@ -0,0 +1,103 @@
+package outbound
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+
+	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/common/dice"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+)
+
+// viaPool picks the address each connection is sent through from a pool of
+// addresses and CIDRs.
+type viaPool struct {
+	strategy proxyman.SenderConfig_ViaStrategy
+	all      []netip.Prefix
+	ipv4     []netip.Prefix
+	ipv6     []netip.Prefix
+	next     atomic.Uint64
+}
+
+func newViaPool(addresses []string, strategy proxyman.SenderConfig_ViaStrategy) (*viaPool, error) {
+	p := &viaPool{strategy: strategy}
+	for _, s := range addresses {
+		var prefix netip.Prefix
+		if strings.Contains(s, "/") {
+			pr, err := netip.ParsePrefix(s)
+			if err != nil {
+				return nil, errors.New("invalid CIDR to send through: ", s).Base(err)
+			}
+			prefix = pr.Masked()
+		} else {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, errors.New("invalid address to send through: ", s).Base(err)
+			}
+			addr = addr.Unmap()
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		p.all = append(p.all, prefix)
+		if prefix.Addr().Is4() {
+			p.ipv4 = append(p.ipv4, prefix)
+		} else {
+			p.ipv6 = append(p.ipv6, prefix)
+		}
+	}
+	if len(p.all) == 0 {
+		return nil, errors.New("empty pool of addresses to send through")
+	}
+	return p, nil
+}
+
+// pick returns the address to dial dest through, for a connection to target.
+// Addresses of the family of dest are preferred, if it is an IP.
+func (p *viaPool) pick(target net.Destination, dest net.Destination) net.Address {
+	candidates := p.all
+	switch {
+	case dest.Address == nil:
+	case dest.Address.Family().IsIPv4() && len(p.ipv4) > 0:
+		candidates = p.ipv4
+	case dest.Address.Family().IsIPv6() && len(p.ipv6) > 0:
+		candidates = p.ipv6
+	}
+
+	var n uint64
+	switch p.strategy {
+	case proxyman.SenderConfig_Sticky:
+		h := fnv.New64a()
+		if target.Address != nil {
+			h.Write([]byte(target.Address.String()))
+		}
+		n = h.Sum64()
+	case proxyman.SenderConfig_Random:
+		n = dice.RollUint64()
+	default:
+		n = p.next.Add(1) - 1
+	}
+
+	// n picks the prefix, and then the address in it, so that round robin
+	// goes through all addresses of each prefix.
+	count := uint64(len(candidates))
+	return addressInPrefix(candidates[n%count], n/count)
+}
+
+// addressInPrefix returns the address at offset in prefix, wrapping around.
+func addressInPrefix(prefix netip.Prefix, offset uint64) net.Address {
+	addr := prefix.Addr()
+	hostBits := addr.BitLen() - prefix.Bits()
+	if hostBits < 64 {
+		offset &= 1<<hostBits - 1
+	}
+	b := addr.AsSlice()
+	if addr.Is4() {
+		binary.BigEndian.PutUint32(b, binary.BigEndian.Uint32(b)|uint32(offset))
+	} else {
+		binary.BigEndian.PutUint64(b[8:], binary.BigEndian.Uint64(b[8:])|offset)
+	}
+	return net.IPAddress(b)
+}
@@ -2,6 +2,8 @@ package router
 
 import (
 	"context"
+	"slices"
+	"strings"
 	sync "sync"
 
 	"github.com/xtls/xray-core/app/observatory"
@@ -87,6 +89,11 @@ type Balancer struct {
 	strategy    BalancingStrategy
 	ohm         outbound.Manager
 	fallbackTag string
+	// countries are those the outbounds must exit in, as the observatory
+	// found them to.
+	countries   []string
+	observatory extension.Observatory
+	ctx         context.Context
 
 	override override
 }
@@ -119,6 +126,13 @@ func (b *Balancer) PickOutbound() (string, error) {
 }
 
 func (b *Balancer) InjectContext(ctx context.Context) {
+	b.ctx = ctx
+	if len(b.countries) > 0 {
+		common.Must(core.RequireFeatures(ctx, func(observatory extension.Observatory) error {
+			b.observatory = observatory
+			return nil
+		}))
+	}
 	if contextReceiver, ok := b.strategy.(extension.ContextReceiver); ok {
 		contextReceiver.InjectContext(ctx)
 	}
@@ -131,9 +145,42 @@ func (b *Balancer) SelectOutbounds() ([]string, error) {
 		return nil, errors.New("outbound.Manager is not a HandlerSelector")
 	}
 	tags := hs.Select(b.selectors)
+	if len(b.countries) > 0 {
+		return b.filterCountries(tags), nil
+	}
 	return tags, nil
 }
 
+// filterCountries returns the tags of the outbounds exiting in the countries
+// of the Balancer. Those not known to exit anywhere yet are left out.
+func (b *Balancer) filterCountries(tags []string) []string {
+	if b.observatory == nil {
+		return nil
+	}
+	observeReport, err := b.observatory.GetObservation(b.ctx)
+	if err != nil {
+		errors.LogInfoInner(b.ctx, err, "cannot get observer report")
+		return nil
+	}
+	result, ok := observeReport.(*observatory.ObservationResult)
+	if !ok {
+		return nil
+	}
+	countries := make(map[string]string, len(result.Status))
+	for _, status := range result.Status {
+		countries[status.OutboundTag] = status.EgressCountry
+	}
+	filtered := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if country := countries[tag]; country != "" && slices.ContainsFunc(b.countries, func(c string) bool {
+			return strings.EqualFold(c, country)
+		}) {
+			filtered = append(filtered, tag)
+		}
+	}
+	return filtered
+}
+
 // GetPrincipleTarget implements routing.BalancerPrincipleTarget
 func (r *Router) GetPrincipleTarget(tag string) ([]string, error) {
 	if b, ok := r.balancers[tag]; ok {
@@ -0,0 +1,202 @@
+package router
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/features/stats"
+)
+
+const (
+	defaultRouteCacheTTL  = time.Minute
+	defaultRouteCacheSize = 65536
+)
+
+// routeCache keeps the rules hit by the routing contexts of the same key, nil
+// for none, until they expire or the rules change.
+type routeCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[string]routeEntry
+	// generation is bumped as the rules change, for the decisions made with
+	// the rules before not to be kept.
+	generation uint64
+	// source is whether any rule matches the sources, which are then of the
+	// keys.
+	source bool
+	// cname is whether any rule matches the CNAME chains of the targets,
+	// which are then of the keys.
+	cname bool
+
+	hits   stats.Counter
+	misses stats.Counter
+}
+
+type routeEntry struct {
+	rule   *Rule
+	expire time.Time
+}
+
+func newRouteCache(config *RouteCache) *routeCache {
+	c := &routeCache{
+		ttl:     time.Duration(config.Ttl),
+		size:    int(config.Size),
+		entries: make(map[string]routeEntry),
+	}
+	if c.ttl <= 0 {
+		c.ttl = defaultRouteCacheTTL
+	}
+	if c.size <= 0 {
+		c.size = defaultRouteCacheSize
+	}
+	return c
+}
+
+// get returns the rule kept for key, and the generation to put the decision
+// made in if there is none.
+func (c *routeCache) get(key string) (*Rule, bool, uint64) {
+	c.Lock()
+	defer c.Unlock()
+	entry, found := c.entries[key]
+	if found && time.Now().After(entry.expire) {
+		delete(c.entries, key)
+		found = false
+	}
+	if found {
+		if c.hits != nil {
+			c.hits.Add(1)
+		}
+	} else if c.misses != nil {
+		c.misses.Add(1)
+	}
+	return entry.rule, found, c.generation
+}
+
+// put keeps rule for key, if the rules did not change since generation.
+func (c *routeCache) put(key string, rule *Rule, generation uint64) {
+	c.Lock()
+	defer c.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if len(c.entries) >= c.size {
+		now := time.Now()
+		for k, entry := range c.entries {
+			if now.After(entry.expire) {
+				delete(c.entries, k)
+			}
+		}
+		// Arbitrary ones are dropped if too many are alive, as maps are
+		// iterated in random order.
+		for k := range c.entries {
+			if len(c.entries) < c.size*3/4 {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = routeEntry{rule: rule, expire: time.Now().Add(c.ttl)}
+}
+
+// reset drops the decisions kept, as rules are now the rules.
+func (c *routeCache) reset(rules []*Rule) {
+	c.Lock()
+	defer c.Unlock()
+	c.generation++
+	clear(c.entries)
+	c.source = slices.ContainsFunc(rules, func(rule *Rule) bool {
+		return matchesSource(rule.Condition)
+	})
+	c.cname = slices.ContainsFunc(rules, func(rule *Rule) bool {
+		return matchesCNAME(rule.Condition)
+	})
+}
+
+// matchesSource returns whether cond matches the source of connections.
+func matchesSource(cond Condition) bool {
+	switch cond := cond.(type) {
+	case *ConditionChan:
+		return slices.ContainsFunc(*cond, matchesSource)
+	case *MultiGeoIPMatcher:
+		return cond.onSource
+	case *PortMatcher:
+		return cond.onSource
+	}
+	return false
+}
+
+// matchesCNAME returns whether cond matches the CNAME chain of the target.
+func matchesCNAME(cond Condition) bool {
+	switch cond := cond.(type) {
+	case *ConditionChan:
+		return slices.ContainsFunc(*cond, matchesCNAME)
+	case *CNAMEMatcher:
+		return true
+	}
+	return false
+}
+
+// key returns the key of the fields of ctx the rules may match.
+func (c *routeCache) key(ctx routing.Context) string {
+	c.Lock()
+	source, cname := c.source, c.cname
+	c.Unlock()
+
+	var b strings.Builder
+	field := func(s string) {
+		b.WriteString(s)
+		b.WriteByte(0)
+	}
+	ips := func(ips []net.IP) {
+		for _, ip := range ips {
+			b.Write(ip)
+			b.WriteByte(',')
+		}
+		b.WriteByte(0)
+	}
+	field(ctx.GetInboundTag())
+	field(ctx.GetNetwork().String())
+	// The target IPs are of the key with the domain too, as the domain may be
+	// sniffed, or routed only, for a target of another IP.
+	field(ctx.GetTargetDomain())
+	ips(ctx.GetTargetIPs())
+	if cname {
+		for _, name := range ctx.GetTargetCNAMEs() {
+			b.WriteString(name)
+			b.WriteByte(',')
+		}
+		b.WriteByte(0)
+	}
+	field(strconv.Itoa(int(ctx.GetTargetPort())))
+	field(ctx.GetProtocol())
+	field(ctx.GetUser())
+	field(ctx.GetTLSClient())
+	field(ctx.GetTLSServerName())
+	field(ctx.GetTLSALPN())
+	field(ctx.GetTenant())
+	field(strconv.FormatBool(ctx.GetSkipDNSResolve()))
+	attributes := ctx.GetAttributes()
+	field(strconv.Itoa(len(attributes)))
+	if len(attributes) > 0 {
+		keys := make([]string, 0, len(attributes))
+		for k := range attributes {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			field(k)
+			field(attributes[k])
+		}
+	}
+	if source {
+		ips(ctx.GetSourceIPs())
+		field(strconv.Itoa(int(ctx.GetSourcePort())))
+	}
+	return b.String()
+}
@@ -50,18 +50,23 @@ func AsRoutingRoute(r *RoutingContext) routing.Route {
 }
 
 var fieldMap = map[string]func(*RoutingContext, routing.Route){
-	"inbound":        func(s *RoutingContext, r routing.Route) { s.InboundTag = r.GetInboundTag() },
-	"network":        func(s *RoutingContext, r routing.Route) { s.Network = r.GetNetwork() },
-	"ip_source":      func(s *RoutingContext, r routing.Route) { s.SourceIPs = mapIPsToBytes(r.GetSourceIPs()) },
-	"ip_target":      func(s *RoutingContext, r routing.Route) { s.TargetIPs = mapIPsToBytes(r.GetTargetIPs()) },
-	"port_source":    func(s *RoutingContext, r routing.Route) { s.SourcePort = uint32(r.GetSourcePort()) },
-	"port_target":    func(s *RoutingContext, r routing.Route) { s.TargetPort = uint32(r.GetTargetPort()) },
-	"domain":         func(s *RoutingContext, r routing.Route) { s.TargetDomain = r.GetTargetDomain() },
-	"protocol":       func(s *RoutingContext, r routing.Route) { s.Protocol = r.GetProtocol() },
-	"user":           func(s *RoutingContext, r routing.Route) { s.User = r.GetUser() },
-	"attributes":     func(s *RoutingContext, r routing.Route) { s.Attributes = r.GetAttributes() },
-	"outbound_group": func(s *RoutingContext, r routing.Route) { s.OutboundGroupTags = r.GetOutboundGroupTags() },
-	"outbound":       func(s *RoutingContext, r routing.Route) { s.OutboundTag = r.GetOutboundTag() },
+	"inbound":         func(s *RoutingContext, r routing.Route) { s.InboundTag = r.GetInboundTag() },
+	"network":         func(s *RoutingContext, r routing.Route) { s.Network = r.GetNetwork() },
+	"ip_source":       func(s *RoutingContext, r routing.Route) { s.SourceIPs = mapIPsToBytes(r.GetSourceIPs()) },
+	"ip_target":       func(s *RoutingContext, r routing.Route) { s.TargetIPs = mapIPsToBytes(r.GetTargetIPs()) },
+	"port_source":     func(s *RoutingContext, r routing.Route) { s.SourcePort = uint32(r.GetSourcePort()) },
+	"port_target":     func(s *RoutingContext, r routing.Route) { s.TargetPort = uint32(r.GetTargetPort()) },
+	"domain":          func(s *RoutingContext, r routing.Route) { s.TargetDomain = r.GetTargetDomain() },
+	"cname":           func(s *RoutingContext, r routing.Route) { s.TargetCNAMEs = r.GetTargetCNAMEs() },
+	"protocol":        func(s *RoutingContext, r routing.Route) { s.Protocol = r.GetProtocol() },
+	"user":            func(s *RoutingContext, r routing.Route) { s.User = r.GetUser() },
+	"attributes":      func(s *RoutingContext, r routing.Route) { s.Attributes = r.GetAttributes() },
+	"tls_client":      func(s *RoutingContext, r routing.Route) { s.TLSClient = r.GetTLSClient() },
+	"tls_server_name": func(s *RoutingContext, r routing.Route) { s.TLSServerName = r.GetTLSServerName() },
+	"tls_alpn":        func(s *RoutingContext, r routing.Route) { s.TLSALPN = r.GetTLSALPN() },
+	"tenant":          func(s *RoutingContext, r routing.Route) { s.Tenant = r.GetTenant() },
+	"outbound_group":  func(s *RoutingContext, r routing.Route) { s.OutboundGroupTags = r.GetOutboundGroupTags() },
+	"outbound":        func(s *RoutingContext, r routing.Route) { s.OutboundTag = r.GetOutboundTag() },
 }
 
 // AsProtobufMessage takes selectors of fields and returns a function to convert routing.Route to protobuf RoutingContext.
@@ -111,6 +111,22 @@ func (m *DomainMatcher) Apply(ctx routing.Context) bool {
 	return m.ApplyDomain(domain)
 }
 
+// CNAMEMatcher matches the names of the CNAME chain of the target domain, as
+// DomainMatcher does the target domain.
+type CNAMEMatcher struct {
+	*DomainMatcher
+}
+
+// Apply implements Condition.
+func (m *CNAMEMatcher) Apply(ctx routing.Context) bool {
+	for _, name := range ctx.GetTargetCNAMEs() {
+		if m.ApplyDomain(name) {
+			return true
+		}
+	}
+	return false
+}
+
 type MultiGeoIPMatcher struct {
 	matchers []*GeoIPMatcher
 	onSource bool
@@ -219,7 +235,10 @@ func NewUserMatcher(users []string) *UserMatcher {
 
 // Apply implements Condition.
 func (v *UserMatcher) Apply(ctx routing.Context) bool {
-	user := ctx.GetUser()
+	return v.match(ctx.GetUser())
+}
+
+func (v *UserMatcher) match(user string) bool {
 	if len(user) == 0 {
 		return false
 	}
@@ -236,6 +255,66 @@ func (v *UserMatcher) Apply(ctx routing.Context) bool {
 	return false
 }
 
+// TLSClientMatcher matches the identity of TLS client certificates, as
+// UserMatcher does the user email.
+type TLSClientMatcher struct {
+	*UserMatcher
+}
+
+func NewTLSClientMatcher(clients []string) *TLSClientMatcher {
+	return &TLSClientMatcher{NewUserMatcher(clients)}
+}
+
+// Apply implements Condition.
+func (v *TLSClientMatcher) Apply(ctx routing.Context) bool {
+	return v.match(ctx.GetTLSClient())
+}
+
+// TLSServerNameMatcher matches the server names TLS clients sent, as
+// UserMatcher does the user email.
+type TLSServerNameMatcher struct {
+	*UserMatcher
+}
+
+func NewTLSServerNameMatcher(names []string) *TLSServerNameMatcher {
+	return &TLSServerNameMatcher{NewUserMatcher(names)}
+}
+
+// Apply implements Condition.
+func (v *TLSServerNameMatcher) Apply(ctx routing.Context) bool {
+	return v.match(ctx.GetTLSServerName())
+}
+
+// TLSALPNMatcher matches the protocols negotiated by ALPN with TLS clients, as
+// UserMatcher does the user email.
+type TLSALPNMatcher struct {
+	*UserMatcher
+}
+
+func NewTLSALPNMatcher(protocols []string) *TLSALPNMatcher {
+	return &TLSALPNMatcher{NewUserMatcher(protocols)}
+}
+
+// Apply implements Condition.
+func (v *TLSALPNMatcher) Apply(ctx routing.Context) bool {
+	return v.match(ctx.GetTLSALPN())
+}
+
+// TenantMatcher matches the tenants of connections, as UserMatcher does the
+// user email.
+type TenantMatcher struct {
+	*UserMatcher
+}
+
+func NewTenantMatcher(tenants []string) *TenantMatcher {
+	return &TenantMatcher{NewUserMatcher(tenants)}
+}
+
+// Apply implements Condition.
+func (v *TenantMatcher) Apply(ctx routing.Context) bool {
+	return v.match(ctx.GetTenant())
+}
+
 type InboundTagMatcher struct {
 	tags []string
 }
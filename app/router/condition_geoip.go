@@ -3,6 +3,7 @@ package router
 import (
 	"net/netip"
 	"strconv"
+	"sync"
 
 	"github.com/xtls/xray-core/common/net"
 	"go4.org/netipx"
@@ -88,12 +89,16 @@ func (m *GeoIPMatcher) Match(ip net.IP) bool {
 
 // GeoIPMatcherContainer is a container for GeoIPMatchers. It keeps unique copies of GeoIPMatcher by country code.
 type GeoIPMatcherContainer struct {
+	access   sync.Mutex
 	matchers []*GeoIPMatcher
 }
 
 // Add adds a new GeoIP set into the container.
 // If the country code of GeoIP is not empty, GeoIPMatcherContainer will try to find an existing one, instead of adding a new one.
 func (c *GeoIPMatcherContainer) Add(geoip *GeoIP) (*GeoIPMatcher, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+
 	if len(geoip.CountryCode) > 0 {
 		for _, m := range c.matchers {
 			if m.countryCode == geoip.CountryCode && m.reverseMatch == geoip.ReverseMatch {
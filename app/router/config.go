@@ -2,10 +2,13 @@ package router
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"regexp"
 	"strings"
 
 	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/outbound"
 	"github.com/xtls/xray-core/features/routing"
 )
@@ -30,32 +33,50 @@ func (r *Rule) Apply(ctx routing.Context) bool {
 }
 
 func (rr *RoutingRule) BuildCondition() (Condition, error) {
+	return rr.buildCondition(nil)
+}
+
+// buildCondition builds the condition of rr, sharing the domain matcher with
+// other instances through resources, if not nil.
+func (rr *RoutingRule) buildCondition(resources *core.Resources) (Condition, error) {
 	conds := NewConditionChan()
 
 	if len(rr.Domain) > 0 {
-		switch rr.DomainMatcher {
-		case "linear":
-			matcher, err := NewDomainMatcher(rr.Domain)
-			if err != nil {
-				return nil, errors.New("failed to build domain condition").Base(err)
-			}
-			conds.Add(matcher)
-		case "mph", "hybrid":
-			fallthrough
-		default:
-			matcher, err := NewMphMatcherGroup(rr.Domain)
-			if err != nil {
-				return nil, errors.New("failed to build domain condition with MphDomainMatcher").Base(err)
-			}
-			errors.LogDebug(context.Background(), "MphDomainMatcher is enabled for ", len(rr.Domain), " domain rule(s)")
-			conds.Add(matcher)
+		matcher, err := loadDomainMatcher(resources, rr.DomainMatcher, rr.Domain)
+		if err != nil {
+			return nil, err
+		}
+		conds.Add(matcher)
+	}
+
+	if len(rr.Cname) > 0 {
+		matcher, err := loadDomainMatcher(resources, rr.DomainMatcher, rr.Cname)
+		if err != nil {
+			return nil, err
 		}
+		conds.Add(&CNAMEMatcher{matcher})
 	}
 
 	if len(rr.UserEmail) > 0 {
 		conds.Add(NewUserMatcher(rr.UserEmail))
 	}
 
+	if len(rr.TlsClient) > 0 {
+		conds.Add(NewTLSClientMatcher(rr.TlsClient))
+	}
+
+	if len(rr.TlsServerName) > 0 {
+		conds.Add(NewTLSServerNameMatcher(rr.TlsServerName))
+	}
+
+	if len(rr.TlsAlpn) > 0 {
+		conds.Add(NewTLSALPNMatcher(rr.TlsAlpn))
+	}
+
+	if len(rr.Tenant) > 0 {
+		conds.Add(NewTenantMatcher(rr.Tenant))
+	}
+
 	if len(rr.InboundTag) > 0 {
 		conds.Add(NewInboundTagMatcher(rr.InboundTag))
 	}
@@ -108,6 +129,48 @@ func (rr *RoutingRule) BuildCondition() (Condition, error) {
 }
 
 // Build builds the balancing rule
+func buildDomainMatcher(matcherType string, domains []*Domain) (*DomainMatcher, error) {
+	switch matcherType {
+	case "linear":
+		matcher, err := NewDomainMatcher(domains)
+		if err != nil {
+			return nil, errors.New("failed to build domain condition").Base(err)
+		}
+		return matcher, nil
+	case "mph", "hybrid":
+		fallthrough
+	default:
+		matcher, err := NewMphMatcherGroup(domains)
+		if err != nil {
+			return nil, errors.New("failed to build domain condition with MphDomainMatcher").Base(err)
+		}
+		errors.LogDebug(context.Background(), "MphDomainMatcher is enabled for ", len(domains), " domain rule(s)")
+		return matcher, nil
+	}
+}
+
+// loadDomainMatcher builds the matcher of domains, or takes the one built for
+// the same domains from resources.
+func loadDomainMatcher(resources *core.Resources, matcherType string, domains []*Domain) (*DomainMatcher, error) {
+	if resources == nil {
+		return buildDomainMatcher(matcherType, domains)
+	}
+	h := sha256.New()
+	for _, d := range domains {
+		h.Write([]byte{byte(d.Type)})
+		h.Write([]byte(d.Value))
+		h.Write([]byte{0})
+	}
+	key := "router.domain:" + matcherType + ":" + hex.EncodeToString(h.Sum(nil))
+	matcher, err := resources.Load(key, func() (interface{}, error) {
+		return buildDomainMatcher(matcherType, domains)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matcher.(*DomainMatcher), nil
+}
+
 func (br *BalancingRule) Build(ohm outbound.Manager, dispatcher routing.Dispatcher) (*Balancer, error) {
 	switch strings.ToLower(br.Strategy) {
 	case "leastping":
@@ -116,6 +179,7 @@ func (br *BalancingRule) Build(ohm outbound.Manager, dispatcher routing.Dispatch
 			strategy:    &LeastPingStrategy{},
 			fallbackTag: br.FallbackTag,
 			ohm:         ohm,
+			countries:   br.Countries,
 		}, nil
 	case "roundrobin":
 		return &Balancer{
@@ -123,6 +187,7 @@ func (br *BalancingRule) Build(ohm outbound.Manager, dispatcher routing.Dispatch
 			strategy:    &RoundRobinStrategy{FallbackTag: br.FallbackTag},
 			fallbackTag: br.FallbackTag,
 			ohm:         ohm,
+			countries:   br.Countries,
 		}, nil
 	case "leastload":
 		i, err := br.StrategySettings.GetInstance()
@@ -137,6 +202,7 @@ func (br *BalancingRule) Build(ohm outbound.Manager, dispatcher routing.Dispatch
 		return &Balancer{
 			selectors:   br.OutboundSelector,
 			ohm:         ohm,
+			countries:   br.Countries,
 			fallbackTag: br.FallbackTag,
 			strategy:    leastLoadStrategy,
 		}, nil
@@ -146,6 +212,7 @@ func (br *BalancingRule) Build(ohm outbound.Manager, dispatcher routing.Dispatch
 		return &Balancer{
 			selectors:   br.OutboundSelector,
 			ohm:         ohm,
+			countries:   br.Countries,
 			fallbackTag: br.FallbackTag,
 			strategy:    &RandomStrategy{FallbackTag: br.FallbackTag},
 		}, nil
@@ -12,6 +12,7 @@ import (
 	"github.com/xtls/xray-core/features/outbound"
 	"github.com/xtls/xray-core/features/routing"
 	routing_dns "github.com/xtls/xray-core/features/routing/dns"
+	"github.com/xtls/xray-core/features/stats"
 )
 
 // Router is an implementation of routing.Router.
@@ -20,6 +21,7 @@ type Router struct {
 	rules          []*Rule
 	balancers      map[string]*Balancer
 	dns            dns.Client
+	cache          *routeCache
 
 	ctx        context.Context
 	ohm        outbound.Manager
@@ -55,7 +57,7 @@ func (r *Router) Init(ctx context.Context, config *Config, d dns.Client, ohm out
 
 	r.rules = make([]*Rule, 0, len(config.Rule))
 	for _, rule := range config.Rule {
-		cond, err := rule.BuildCondition()
+		cond, err := rule.buildCondition(core.ResourcesFromContext(r.ctx))
 		if err != nil {
 			return err
 		}
@@ -75,12 +77,21 @@ func (r *Router) Init(ctx context.Context, config *Config, d dns.Client, ohm out
 		r.rules = append(r.rules, rr)
 	}
 
+	if config.Cache != nil {
+		r.cache = newRouteCache(config.Cache)
+		r.cache.reset(r.rules)
+		core.RequireFeatures(ctx, func(sm stats.Manager) {
+			r.cache.hits, _ = stats.GetOrRegisterCounter(sm, "routing>>>cache>>>hit")
+			r.cache.misses, _ = stats.GetOrRegisterCounter(sm, "routing>>>cache>>>miss")
+		})
+	}
+
 	return nil
 }
 
 // PickRoute implements routing.Router.
 func (r *Router) PickRoute(ctx routing.Context) (routing.Route, error) {
-	rule, ctx, err := r.pickRouteInternal(ctx)
+	rule, ctx, err := r.pickRouteCached(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -107,6 +118,7 @@ func (r *Router) AddRule(config *serial.TypedMessage, shouldAppend bool) error {
 func (r *Router) ReloadRules(config *Config, shouldAppend bool) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	defer r.resetCache()
 
 	if !shouldAppend {
 		r.balancers = make(map[string]*Balancer, len(config.BalancingRule))
@@ -129,7 +141,7 @@ func (r *Router) ReloadRules(config *Config, shouldAppend bool) error {
 		if r.RuleExists(rule.GetRuleTag()) {
 			return errors.New("duplicate ruleTag ", rule.GetRuleTag())
 		}
-		cond, err := rule.BuildCondition()
+		cond, err := rule.buildCondition(core.ResourcesFromContext(r.ctx))
 		if err != nil {
 			return err
 		}
@@ -167,6 +179,7 @@ func (r *Router) RuleExists(tag string) bool {
 func (r *Router) RemoveRule(tag string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	defer r.resetCache()
 
 	newRules := []*Rule{}
 	if tag != "" {
@@ -181,6 +194,36 @@ func (r *Router) RemoveRule(tag string) error {
 	return errors.New("empty tag name!")
 
 }
+
+// resetCache drops the routing decisions kept, as the rules changed.
+func (r *Router) resetCache() {
+	if r.cache != nil {
+		r.cache.reset(r.rules)
+	}
+}
+
+// pickRouteCached picks the rule of ctx as pickRouteInternal does, taking the
+// one kept for the fields of ctx if any.
+func (r *Router) pickRouteCached(ctx routing.Context) (*Rule, routing.Context, error) {
+	if r.cache == nil {
+		return r.pickRouteInternal(ctx)
+	}
+	key := r.cache.key(ctx)
+	rule, found, generation := r.cache.get(key)
+	if !found {
+		var err error
+		rule, ctx, err = r.pickRouteInternal(ctx)
+		if err != nil && err != common.ErrNoClue {
+			return nil, ctx, err
+		}
+		r.cache.put(key, rule, generation)
+	}
+	if rule == nil {
+		return nil, ctx, common.ErrNoClue
+	}
+	return rule, ctx, nil
+}
+
 func (r *Router) pickRouteInternal(ctx routing.Context) (*Rule, routing.Context, error) {
 	// SkipDNSResolve is set from DNS module.
 	// the DOH remote server maybe a domain name,
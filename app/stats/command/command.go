@@ -7,6 +7,7 @@ import (
 
 	"github.com/xtls/xray-core/app/stats"
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/bytespool"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/strmatcher"
 	"github.com/xtls/xray-core/core"
@@ -130,6 +131,14 @@ func (s *statsServer) GetSysStats(ctx context.Context, request *SysStatsRequest)
 		NumGC:        rtm.NumGC,
 		PauseTotalNs: rtm.PauseTotalNs,
 	}
+	for _, pool := range bytespool.Stats() {
+		response.BufferPools = append(response.BufferPools, &BufferPoolStats{
+			Size:      uint32(pool.Size),
+			Allocated: pool.Allocated,
+			InUse:     pool.Gets - pool.Puts,
+			Gets:      pool.Gets,
+		})
+	}
 
 	return response, nil
 }
@@ -5,6 +5,7 @@ import "sync/atomic"
 // Counter is an implementation of stats.Counter.
 type Counter struct {
 	value int64
+	sets  atomic.Uint64
 }
 
 // Value implements stats.Counter.
@@ -14,9 +15,16 @@ func (c *Counter) Value() int64 {
 
 // Set implements stats.Counter.
 func (c *Counter) Set(newValue int64) int64 {
+	c.sets.Add(1)
 	return atomic.SwapInt64(&c.value, newValue)
 }
 
+// Sets returns how many times the value of c is set, such as reset by the
+// stats API.
+func (c *Counter) Sets() uint64 {
+	return c.sets.Load()
+}
+
 // Add implements stats.Counter.
 func (c *Counter) Add(delta int64) int64 {
 	return atomic.AddInt64(&c.value, delta)
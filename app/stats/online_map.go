@@ -5,6 +5,9 @@ import (
 	"time"
 )
 
+// onlineExpiry is how long an IP is online for since its last access.
+const onlineExpiry = 20 * time.Second
+
 // OnlineMap is an implementation of stats.OnlineMap.
 type OnlineMap struct {
 	value         int
@@ -41,9 +44,7 @@ func (c *OnlineMap) AddIP(ip string) {
 		return
 	}
 	c.access.Lock()
-	if _, ok := list[ip]; !ok {
-		list[ip] = time.Now()
-	}
+	list[ip] = time.Now()
 	c.access.Unlock()
 	if time.Since(c.lastCleanup) > c.cleanupPeriod {
 		list = c.RemoveExpiredIPs(list)
@@ -54,13 +55,17 @@ func (c *OnlineMap) AddIP(ip string) {
 	c.ipList = list
 }
 
+// GetKeys returns the IPs online, the expired ones not yet removed left out.
 func (c *OnlineMap) GetKeys() []string {
 	c.access.RLock()
 	defer c.access.RUnlock()
 
+	now := time.Now()
 	keys := []string{}
-	for k := range c.ipList {
-		keys = append(keys, k)
+	for k, t := range c.ipList {
+		if now.Sub(t) <= onlineExpiry {
+			keys = append(keys, k)
+		}
 	}
 	return keys
 }
@@ -71,8 +76,7 @@ func (c *OnlineMap) RemoveExpiredIPs(list map[string]time.Time) map[string]time.
 
 	now := time.Now()
 	for k, t := range list {
-		diff := now.Sub(t)
-		if diff.Seconds() > 20 {
+		if now.Sub(t) > onlineExpiry {
 			delete(list, k)
 		}
 	}
@@ -0,0 +1,125 @@
+// Package tracing exports the spans of the connections traced by their
+// policy levels to an OpenTelemetry collector, via OTLP.
+package tracing
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// shutdownTimeout is how long Close waits for the spans left to be exported.
+const shutdownTimeout = 5 * time.Second
+
+// Exporter is the feature that exports the spans, as the global tracer
+// provider of OpenTelemetry while it runs.
+type Exporter struct {
+	config   *Config
+	provider *sdktrace.TracerProvider
+}
+
+// New creates an Exporter from config.
+func New(ctx context.Context, config *Config) (*Exporter, error) {
+	if len(config.Endpoint) == 0 {
+		return nil, errors.New("tracing without endpoint")
+	}
+	switch config.Protocol {
+	case "", "grpc", "http":
+	default:
+		return nil, errors.New("unknown OTLP protocol ", config.Protocol)
+	}
+	if config.SampleRatio < 0 || config.SampleRatio > 1 {
+		return nil, errors.New("sample ratio of tracing not in [0, 1]: ", config.SampleRatio)
+	}
+	return &Exporter{config: config}, nil
+}
+
+func (e *Exporter) newClient() otlptrace.Client {
+	isURL := strings.Contains(e.config.Endpoint, "://")
+	if e.config.Protocol == "http" {
+		options := []otlptracehttp.Option{otlptracehttp.WithHeaders(e.config.Header)}
+		if isURL {
+			options = append(options, otlptracehttp.WithEndpointURL(e.config.Endpoint))
+		} else {
+			options = append(options, otlptracehttp.WithEndpoint(e.config.Endpoint))
+		}
+		if e.config.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.NewClient(options...)
+	}
+	options := []otlptracegrpc.Option{otlptracegrpc.WithHeaders(e.config.Header)}
+	if isURL {
+		options = append(options, otlptracegrpc.WithEndpointURL(e.config.Endpoint))
+	} else {
+		options = append(options, otlptracegrpc.WithEndpoint(e.config.Endpoint))
+	}
+	if e.config.Insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	return otlptracegrpc.NewClient(options...)
+}
+
+// Type implements common.HasType.
+func (e *Exporter) Type() interface{} {
+	return (*Exporter)(nil)
+}
+
+// Start implements common.Runnable.
+func (e *Exporter) Start() error {
+	exporter, err := otlptrace.New(context.Background(), e.newClient())
+	if err != nil {
+		return errors.New("failed to create OTLP exporter").Base(err)
+	}
+	serviceName := e.config.ServiceName
+	if len(serviceName) == 0 {
+		serviceName = "xray"
+	}
+	ratio := e.config.SampleRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+	e.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(ratio)),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(e.provider)
+	errors.LogInfo(context.Background(), "exporting traces to ", e.config.Endpoint)
+	return nil
+}
+
+// Close implements common.Closable. It exports the spans left, for a while.
+func (e *Exporter) Close() error {
+	if e.provider == nil {
+		return nil
+	}
+	if otel.GetTracerProvider() == e.provider {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := e.provider.Shutdown(ctx)
+	e.provider = nil
+	return err
+}
+
+func init() {
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		errors.LogWarningInner(context.Background(), err, "failed to export traces")
+	}))
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		return New(ctx, config.(*Config))
+	}))
+}
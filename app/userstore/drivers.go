@@ -0,0 +1,7 @@
+package userstore
+
+import (
+	// The drivers are pure Go, to be built in without cgo as releases are.
+	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
+)
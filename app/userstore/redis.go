@@ -0,0 +1,115 @@
+package userstore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/redis"
+)
+
+const redisTimeout = 10 * time.Second
+
+// redisBackend keeps the users in a hash of Redis, and watches a channel for
+// the emails of the changed ones.
+type redisBackend struct {
+	config *Redis
+	client *redis.Client
+}
+
+func newRedisBackend(config *Redis) *redisBackend {
+	return &redisBackend{
+		config: config,
+		client: &redis.Client{
+			Address:  config.Address,
+			Password: config.Password,
+			DB:       config.Db,
+			Timeout:  redisTimeout,
+		},
+	}
+}
+
+func (b *redisBackend) load(ctx context.Context) ([]*User, error) {
+	reply, err := b.client.Do(ctx, "HGETALL", b.config.Key)
+	if err != nil {
+		return nil, err
+	}
+	fields, _ := reply.([]interface{})
+	users := make([]*User, 0, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		email, _ := fields[i].(string)
+		value, _ := fields[i+1].(string)
+		u, err := parseRedisUser(email, value)
+		if err != nil {
+			errors.LogWarningInner(ctx, err, "skipped user")
+			continue
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func (b *redisBackend) get(ctx context.Context, email string) (*User, error) {
+	reply, err := b.client.Do(ctx, "HGET", b.config.Key, email)
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	value, _ := reply.(string)
+	return parseRedisUser(email, value)
+}
+
+// parseRedisUser returns the user of the field of email in the hash.
+func parseRedisUser(email string, value string) (*User, error) {
+	u := new(User)
+	if err := json.Unmarshal([]byte(value), u); err != nil {
+		return nil, errors.New("invalid user ", email).Base(err)
+	}
+	u.Email = email
+	return u, nil
+}
+
+func (b *redisBackend) watch(ctx context.Context, changed func(emails ...string)) error {
+	if b.config.Channel == "" {
+		<-ctx.Done()
+		return nil
+	}
+	conn, err := b.client.Dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	if err := conn.Send("SUBSCRIBE", b.config.Channel); err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+	for {
+		reply, err := conn.Read()
+		if err != nil {
+			return err
+		}
+		// Messages are of ["message", channel, payload].
+		message, _ := reply.([]interface{})
+		if len(message) != 3 || message[0] != "message" {
+			continue
+		}
+		if email, _ := message[2].(string); email == "" {
+			changed()
+		} else {
+			changed(email)
+		}
+	}
+}
+
+func (b *redisBackend) close() error {
+	return b.client.Close()
+}
@@ -0,0 +1,95 @@
+package userstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+)
+
+// versionInterval is how often the version query is polled.
+const versionInterval = 5 * time.Second
+
+// sqlBackend keeps the users in the rows of a query, and polls the version
+// query for when they change.
+type sqlBackend struct {
+	config *SQL
+	db     *sql.DB
+}
+
+func newSQLBackend(config *SQL) (*sqlBackend, error) {
+	if config.Query == "" {
+		return nil, errors.New("no query of users")
+	}
+	// The database is connected to once queried.
+	db, err := sql.Open(config.Driver, config.Dsn)
+	if err != nil {
+		return nil, errors.New("failed to open ", config.Driver, " database").Base(err)
+	}
+	return &sqlBackend{config: config, db: db}, nil
+}
+
+func (b *sqlBackend) load(ctx context.Context) ([]*User, error) {
+	rows, err := b.db.QueryContext(ctx, b.config.Query)
+	if err != nil {
+		return nil, errors.New("failed to query users").Base(err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	var users []*User
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, errors.New("failed to read users").Base(err)
+		}
+		u := new(User)
+		for i, column := range columns {
+			if err := u.setField(column, values[i].String); err != nil {
+				return nil, err
+			}
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("failed to read users").Base(err)
+	}
+	return users, nil
+}
+
+func (b *sqlBackend) watch(ctx context.Context, changed func(emails ...string)) error {
+	if b.config.VersionQuery == "" {
+		<-ctx.Done()
+		return nil
+	}
+	var last sql.NullString
+	if err := b.db.QueryRowContext(ctx, b.config.VersionQuery).Scan(&last); err != nil {
+		return errors.New("failed to query version of users").Base(err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(versionInterval):
+		}
+		var version sql.NullString
+		if err := b.db.QueryRowContext(ctx, b.config.VersionQuery).Scan(&version); err != nil {
+			return errors.New("failed to query version of users").Base(err)
+		}
+		if version != last {
+			last = version
+			changed()
+		}
+	}
+}
+
+func (b *sqlBackend) close() error {
+	return b.db.Close()
+}
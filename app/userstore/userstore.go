@@ -0,0 +1,364 @@
+// Package userstore keeps the users of inbounds in sync with databases.
+package userstore
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/inbound"
+	"github.com/xtls/xray-core/proxy"
+	"github.com/xtls/xray-core/proxy/trojan"
+	"github.com/xtls/xray-core/proxy/vless"
+	vlessin "github.com/xtls/xray-core/proxy/vless/inbound"
+	"github.com/xtls/xray-core/proxy/vmess"
+	vmessin "github.com/xtls/xray-core/proxy/vmess/inbound"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultInterval = 10 * time.Minute
+	// retryDelay is how long to wait for watching a backend again once it
+	// fails.
+	retryDelay = 5 * time.Second
+)
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		m := &Manager{}
+		if err := core.RequireFeatures(ctx, func(ihm inbound.Manager) error {
+			return m.Init(ctx, config.(*Config), ihm)
+		}); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}))
+}
+
+// User is a user of a store, in JSON in Redis and in the columns of SQL. ID is
+// of VLESS and VMess, and Password of Trojan.
+type User struct {
+	Email    string `json:"email"`
+	Level    uint32 `json:"level"`
+	ID       string `json:"id"`
+	Password string `json:"password"`
+	Flow     string `json:"flow"`
+}
+
+// backend is a database of users.
+type backend interface {
+	// load returns all the users.
+	load(ctx context.Context) ([]*User, error)
+	// watch calls changed with the emails of the users once they change, or
+	// with none if all may have, until it fails or ctx is done.
+	watch(ctx context.Context, changed func(emails ...string)) error
+	close() error
+}
+
+// getter is a backend that looks its users up by email.
+type getter interface {
+	// get returns the user of email, or nil if there is none.
+	get(ctx context.Context, email string) (*User, error)
+}
+
+// Manager keeps the users of the stores.
+type Manager struct {
+	stores []*store
+}
+
+func (m *Manager) Init(ctx context.Context, config *Config, ihm inbound.Manager) error {
+	for _, c := range config.Stores {
+		if len(c.InboundTag) == 0 {
+			return errors.New("user store ", c.Name, " without inbounds")
+		}
+		var b backend
+		switch backend := c.Backend.(type) {
+		case *Store_Redis:
+			b = newRedisBackend(backend.Redis)
+		case *Store_Sql:
+			var err error
+			if b, err = newSQLBackend(backend.Sql); err != nil {
+				return errors.New("user store ", c.Name).Base(err)
+			}
+		default:
+			return errors.New("user store ", c.Name, " without backend")
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		m.stores = append(m.stores, &store{
+			ctx:     ctx,
+			cancel:  cancel,
+			config:  c,
+			ihm:     ihm,
+			backend: b,
+			users:   make(map[string]*User),
+			reload:  make(chan struct{}, 1),
+		})
+	}
+	return nil
+}
+
+// Type implements common.HasType.
+func (*Manager) Type() interface{} {
+	return (*Manager)(nil)
+}
+
+// Start implements common.Runnable.
+func (m *Manager) Start() error {
+	for _, s := range m.stores {
+		go s.run()
+		go s.watch()
+	}
+	return nil
+}
+
+// Close implements common.Closable.
+func (m *Manager) Close() error {
+	for _, s := range m.stores {
+		s.cancel()
+		s.backend.close()
+	}
+	return nil
+}
+
+// store syncs the users of its inbounds with its backend. The users of the
+// inbounds of the same emails as the ones of the backend are replaced.
+type store struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	config  *Store
+	ihm     inbound.Manager
+	backend backend
+
+	access sync.Mutex
+	// users are the ones synced, by email.
+	users  map[string]*User
+	reload chan struct{}
+}
+
+func (s *store) run() {
+	interval := time.Duration(s.config.Interval)
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	for {
+		if err := s.sync(); err != nil {
+			errors.LogWarningInner(s.ctx, err, "user store ", s.config.Name, ": failed to sync")
+		}
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.reload:
+		case <-time.After(interval):
+		}
+	}
+}
+
+// watch syncs the users changed in the backend, once it tells.
+func (s *store) watch() {
+	for {
+		err := s.backend.watch(s.ctx, s.changed)
+		select {
+		case <-s.ctx.Done():
+			return
+		default:
+		}
+		if err != nil {
+			errors.LogWarningInner(s.ctx, err, "user store ", s.config.Name, ": failed to watch")
+		}
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+		// Changes may be missed in between.
+		s.changed()
+	}
+}
+
+// changed syncs the users of emails, or all of them if there are none or the
+// backend does not look them up.
+func (s *store) changed(emails ...string) {
+	g, ok := s.backend.(getter)
+	if len(emails) == 0 || !ok {
+		select {
+		case s.reload <- struct{}{}:
+		default:
+		}
+		return
+	}
+	for _, email := range emails {
+		u, err := g.get(s.ctx, email)
+		if err != nil {
+			errors.LogWarningInner(s.ctx, err, "user store ", s.config.Name, ": failed to get ", email)
+			continue
+		}
+		s.access.Lock()
+		s.apply(email, u)
+		s.access.Unlock()
+	}
+}
+
+// sync syncs all the users, adding the ones missing in the inbounds as well,
+// such as of the inbounds added since.
+func (s *store) sync() error {
+	users, err := s.backend.load(s.ctx)
+	if err != nil {
+		return err
+	}
+	loaded := make(map[string]*User, len(users))
+	for _, u := range users {
+		if u.Email != "" {
+			loaded[u.Email] = u
+		}
+	}
+
+	s.access.Lock()
+	defer s.access.Unlock()
+	var added, removed int
+	for email := range s.users {
+		if _, found := loaded[email]; !found {
+			s.apply(email, nil)
+			removed++
+		}
+	}
+	for email, u := range loaded {
+		if old, found := s.users[email]; found && *old == *u {
+			s.restore(u)
+			continue
+		}
+		s.apply(email, u)
+		added++
+	}
+	if added == 0 && removed == 0 {
+		errors.LogDebug(s.ctx, "user store ", s.config.Name, ": ", len(s.users), " users, unchanged")
+		return nil
+	}
+	errors.LogInfo(s.ctx, "user store ", s.config.Name, ": ", len(s.users), " users, ", added, " added or changed, ", removed, " removed")
+	return nil
+}
+
+// apply replaces the user of email in the inbounds with u, or removes it if u
+// is nil. s.access must be held.
+func (s *store) apply(email string, u *User) {
+	old := s.users[email]
+	if old == nil && u == nil || old != nil && u != nil && *old == *u {
+		return
+	}
+	for _, tag := range s.config.InboundTag {
+		p, um := s.userManager(tag)
+		if um == nil {
+			continue
+		}
+		if um.GetUser(s.ctx, email) != nil {
+			um.RemoveUser(s.ctx, email)
+		}
+		if u != nil {
+			s.add(tag, p, um, u)
+		}
+	}
+	if u == nil {
+		delete(s.users, email)
+	} else {
+		s.users[email] = u
+	}
+}
+
+// restore adds u, which is synced, to the inbounds missing it. s.access must
+// be held.
+func (s *store) restore(u *User) {
+	for _, tag := range s.config.InboundTag {
+		if p, um := s.userManager(tag); um != nil && um.GetUser(s.ctx, u.Email) == nil {
+			s.add(tag, p, um, u)
+		}
+	}
+}
+
+func (s *store) add(tag string, p proxy.Inbound, um proxy.UserManager, u *User) {
+	account, err := newAccount(p, u)
+	if err == nil {
+		var mu *protocol.MemoryUser
+		user := &protocol.User{
+			Email:   u.Email,
+			Level:   u.Level,
+			Account: serial.ToTypedMessage(account),
+		}
+		if mu, err = user.ToMemoryUser(); err == nil {
+			err = um.AddUser(s.ctx, mu)
+		}
+	}
+	if err != nil {
+		errors.LogWarningInner(s.ctx, err, "user store ", s.config.Name, ": failed to add ", u.Email, " to ", tag)
+	}
+}
+
+// userManager returns the proxy of the inbound of tag and its users, or nil if
+// it is gone.
+func (s *store) userManager(tag string) (proxy.Inbound, proxy.UserManager) {
+	handler, err := s.ihm.GetHandler(s.ctx, tag)
+	if err != nil {
+		return nil, nil
+	}
+	gi, ok := handler.(proxy.GetInbound)
+	if !ok {
+		return nil, nil
+	}
+	p := gi.GetInbound()
+	um, ok := p.(proxy.UserManager)
+	if !ok {
+		return nil, nil
+	}
+	return p, um
+}
+
+// newAccount returns the account of u for the protocol of p.
+func newAccount(p proxy.Inbound, u *User) (proto.Message, error) {
+	switch p.(type) {
+	case *vlessin.Handler:
+		if u.ID == "" {
+			return nil, errors.New("no id")
+		}
+		return &vless.Account{Id: u.ID, Flow: u.Flow}, nil
+	case *vmessin.Handler:
+		if u.ID == "" {
+			return nil, errors.New("no id")
+		}
+		return &vmess.Account{Id: u.ID}, nil
+	case *trojan.Server:
+		if u.Password == "" {
+			return nil, errors.New("no password")
+		}
+		return &trojan.Account{Password: u.Password, Flow: u.Flow}, nil
+	}
+	return nil, errors.New("not a VLESS, VMess or Trojan inbound")
+}
+
+// setField sets the field of u of a column, if it is one.
+func (u *User) setField(column string, value string) error {
+	switch strings.ToLower(column) {
+	case "email":
+		u.Email = value
+	case "level":
+		if value == "" {
+			return nil
+		}
+		level, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return errors.New("invalid level of ", u.Email).Base(err)
+		}
+		u.Level = uint32(level)
+	case "id", "uuid":
+		u.ID = value
+	case "password":
+		u.Password = value
+	case "flow":
+		u.Flow = value
+	}
+	return nil
+}
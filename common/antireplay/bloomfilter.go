@@ -0,0 +1,205 @@
+package antireplay
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/task"
+)
+
+const (
+	// bloomHashes is the number of bits set for each record.
+	bloomHashes = 7
+	// bloomSaveInterval is how often a BloomFilter is saved to its file, if
+	// it changed.
+	bloomSaveInterval = 10 * time.Second
+	bloomHeaderSize   = 4 + 16 + 8 + 8 + 8
+)
+
+// bloomMagic starts the file of a BloomFilter. It is followed by the salt,
+// the interval, the time of the last swap and the number of words of each
+// pool, then by the words of the current pool and of the previous one.
+var bloomMagic = [4]byte{'X', 'R', 'B', 1}
+
+// BloomFilter checks for replay attacks as ReplayFilter does, in two bloom
+// filters of a bounded size. It may be saved to a file and loaded from it as
+// it is created, so that records are remembered across restarts.
+type BloomFilter struct {
+	lock     sync.Mutex
+	salt     [16]byte
+	current  []uint64
+	previous []uint64
+	lastSwap int64
+	interval int64
+
+	path  string
+	dirty bool
+	save  *task.Periodic
+	// saving serializes the writes of the file.
+	saving sync.Mutex
+}
+
+// NewBloomFilter creates a new filter of records expiring after interval
+// seconds, whose pools take memory bytes. If path is not empty, the filter is
+// loaded from the file there, if it is of the same interval and size, and
+// saved to it every few seconds and as it is closed.
+func NewBloomFilter(interval int64, memory uint64, path string) *BloomFilter {
+	words := max(memory/16, 1)
+	filter := &BloomFilter{
+		current:  make([]uint64, words),
+		previous: make([]uint64, words),
+		lastSwap: time.Now().Unix(),
+		interval: interval,
+		path:     path,
+	}
+	rand.Read(filter.salt[:])
+	if path == "" {
+		return filter
+	}
+	if err := filter.load(); err != nil && !os.IsNotExist(err) {
+		errors.LogWarningInner(context.Background(), err, "failed to load replay filter from ", path)
+	}
+	filter.save = &task.Periodic{
+		Interval: bloomSaveInterval,
+		Execute: func() error {
+			if err := filter.store(); err != nil {
+				errors.LogWarningInner(context.Background(), err, "failed to save replay filter to ", path)
+			}
+			return nil
+		},
+	}
+	filter.save.Start()
+	return filter
+}
+
+// Interval in second for expiration time for duplicate records.
+func (f *BloomFilter) Interval() int64 {
+	return f.interval
+}
+
+// Check determines if there are duplicate records.
+func (f *BloomFilter) Check(sum []byte) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.swap(time.Now().Unix())
+	hash := sha256.New()
+	hash.Write(f.salt[:])
+	hash.Write(sum)
+	digest := hash.Sum(nil)
+	h1 := binary.BigEndian.Uint64(digest)
+	h2 := binary.BigEndian.Uint64(digest[8:]) | 1
+
+	bits := uint64(len(f.current)) * 64
+	found := true
+	for i := range uint64(bloomHashes) {
+		bit := (h1 + i*h2) % bits
+		if f.current[bit/64]&(1<<(bit%64)) == 0 && f.previous[bit/64]&(1<<(bit%64)) == 0 {
+			found = false
+			break
+		}
+	}
+	if found {
+		return false
+	}
+	for i := range uint64(bloomHashes) {
+		bit := (h1 + i*h2) % bits
+		f.current[bit/64] |= 1 << (bit % 64)
+	}
+	f.dirty = true
+	return true
+}
+
+// swap expires the records of the previous pool once an interval passed since
+// the last swap, and of both pools once two did. f.lock must be held.
+func (f *BloomFilter) swap(now int64) {
+	elapsed := now - f.lastSwap
+	if elapsed < f.interval {
+		return
+	}
+	f.current, f.previous = f.previous, f.current
+	clear(f.current)
+	if elapsed >= 2*f.interval {
+		clear(f.previous)
+	}
+	f.lastSwap = now
+	f.dirty = true
+}
+
+func (f *BloomFilter) load() error {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	words := uint64(len(f.current))
+	if len(data) < bloomHeaderSize || [4]byte(data) != bloomMagic {
+		return errors.New("invalid replay filter file")
+	}
+	if int64(binary.BigEndian.Uint64(data[20:])) != f.interval || binary.BigEndian.Uint64(data[36:]) != words || uint64(len(data)) != bloomHeaderSize+words*16 {
+		// It is of another configuration, whose records can not be told.
+		return errors.New("replay filter file of another window or memory")
+	}
+	copy(f.salt[:], data[4:])
+	f.lastSwap = int64(binary.BigEndian.Uint64(data[28:]))
+	pools := data[bloomHeaderSize:]
+	for i := range words {
+		f.current[i] = binary.BigEndian.Uint64(pools[i*8:])
+		f.previous[i] = binary.BigEndian.Uint64(pools[(words+i)*8:])
+	}
+	f.swap(time.Now().Unix())
+	errors.LogInfo(context.Background(), "replay filter loaded from ", f.path)
+	return nil
+}
+
+// store saves f to its file, if it changed since it was last saved.
+func (f *BloomFilter) store() error {
+	f.saving.Lock()
+	defer f.saving.Unlock()
+	f.lock.Lock()
+	if !f.dirty {
+		f.lock.Unlock()
+		return nil
+	}
+	words := uint64(len(f.current))
+	data := make([]byte, bloomHeaderSize, bloomHeaderSize+words*16)
+	copy(data, bloomMagic[:])
+	copy(data[4:], f.salt[:])
+	binary.BigEndian.PutUint64(data[20:], uint64(f.interval))
+	binary.BigEndian.PutUint64(data[28:], uint64(f.lastSwap))
+	binary.BigEndian.PutUint64(data[36:], words)
+	for _, w := range f.current {
+		data = binary.BigEndian.AppendUint64(data, w)
+	}
+	for _, w := range f.previous {
+		data = binary.BigEndian.AppendUint64(data, w)
+	}
+	f.dirty = false
+	f.lock.Unlock()
+
+	temp := f.path + ".tmp"
+	err := os.WriteFile(temp, data, 0o600)
+	if err == nil {
+		err = os.Rename(temp, f.path)
+	}
+	if err != nil {
+		f.lock.Lock()
+		f.dirty = true
+		f.lock.Unlock()
+	}
+	return err
+}
+
+// Close implements common.Closable. It saves f to its file a last time.
+func (f *BloomFilter) Close() error {
+	if f.save == nil {
+		return nil
+	}
+	f.save.Close()
+	return f.store()
+}
@@ -17,12 +17,22 @@ type ReplayFilter struct {
 	poolSwap bool
 	lastSwap int64
 	interval int64
+	capacity uint
 }
 
 // NewReplayFilter create a new filter with specifying the expiration time interval in seconds.
 func NewReplayFilter(interval int64) *ReplayFilter {
 	filter := &ReplayFilter{}
 	filter.interval = interval
+	filter.capacity = replayFilterCapacity
+	return filter
+}
+
+// NewReplayFilterOfMemory creates a new filter as NewReplayFilter does,
+// whose pools take about memory bytes, of a record a byte.
+func NewReplayFilterOfMemory(interval int64, memory uint64) *ReplayFilter {
+	filter := NewReplayFilter(interval)
+	filter.capacity = uint(max(memory/2, 4))
 	return filter
 }
 
@@ -39,8 +49,8 @@ func (filter *ReplayFilter) Check(sum []byte) bool {
 	now := time.Now().Unix()
 	if filter.lastSwap == 0 {
 		filter.lastSwap = now
-		filter.poolA = cuckoo.NewFilter(replayFilterCapacity)
-		filter.poolB = cuckoo.NewFilter(replayFilterCapacity)
+		filter.poolA = cuckoo.NewFilter(filter.capacity)
+		filter.poolB = cuckoo.NewFilter(filter.capacity)
 	}
 
 	elapsed := now - filter.lastSwap
@@ -15,8 +15,6 @@ const (
 
 var zero = [Size * 10]byte{0}
 
-var pool = bytespool.GetPool(Size)
-
 // ownership represents the data owner of the buffer.
 type ownership uint8
 
@@ -39,7 +37,7 @@ type Buffer struct {
 
 // New creates a Buffer with 0 length and 8K capacity, managed.
 func New() *Buffer {
-	buf := pool.Get().([]byte)
+	buf := bytespool.Alloc(Size)
 	if cap(buf) >= Size {
 		buf = buf[:Size]
 	} else {
@@ -80,7 +78,7 @@ func FromBytes(b []byte) *Buffer {
 // StackNew creates a new Buffer object on stack, managed.
 // This method is for buffers that is released in the same function.
 func StackNew() Buffer {
-	buf := pool.Get().([]byte)
+	buf := bytespool.Alloc(Size)
 	if cap(buf) >= Size {
 		buf = buf[:Size]
 	} else {
@@ -113,7 +111,7 @@ func (b *Buffer) Release() {
 	switch b.ownership {
 	case managed:
 		if cap(p) == Size {
-			pool.Put(p)
+			bytespool.Free(p)
 		}
 	case bytespools:
 		bytespool.Free(p)
@@ -26,7 +26,11 @@ func readOneUDP(r io.Reader) (*Buffer, error) {
 
 // ReadBuffer reads a Buffer from the given reader.
 func ReadBuffer(r io.Reader) (*Buffer, error) {
-	b := New()
+	return readBuffer(r, New())
+}
+
+// readBuffer reads from r into b, and releases b if nothing is read.
+func readBuffer(r io.Reader, b *Buffer) (*Buffer, error) {
 	n, err := b.ReadFrom(r)
 	if n > 0 {
 		return b, err
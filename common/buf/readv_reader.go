@@ -11,8 +11,23 @@ import (
 	"github.com/xtls/xray-core/features/stats"
 )
 
+const (
+	// smallSize is the size of the buffers a connection reads into while its
+	// reads are small, such as DNS queries or interactive traffic.
+	smallSize = 2048
+	// smallReads is how many reads in a row have to fit in smallSize before
+	// a connection reads into buffers of that size.
+	smallReads = 8
+)
+
+// allocStrategy picks the buffers of the next read of a connection from how
+// much it has been reading: more buffers while reads fill them all, and
+// smaller ones while reads only take a fraction of one.
 type allocStrategy struct {
 	current uint32
+	small   bool
+	// fits counts the reads in a row that fit in smallSize.
+	fits uint32
 }
 
 func (s *allocStrategy) Current() uint32 {
@@ -35,6 +50,30 @@ func (s *allocStrategy) Adjust(n uint32) {
 	}
 }
 
+// New returns a buffer for a read of one buffer.
+func (s *allocStrategy) New() *Buffer {
+	if s.small {
+		return NewWithSize(smallSize)
+	}
+	return New()
+}
+
+// AdjustSize records a read of one buffer into b.
+func (s *allocStrategy) AdjustSize(b *Buffer) {
+	switch {
+	case b.IsFull():
+		s.small = false
+		s.fits = 0
+	case b.Len() <= smallSize:
+		if s.fits < smallReads {
+			s.fits++
+		}
+		s.small = s.fits == smallReads
+	default:
+		s.fits = 0
+	}
+}
+
 func (s *allocStrategy) Alloc() []*Buffer {
 	bs := make([]*Buffer, s.current)
 	for i := range bs {
@@ -122,8 +161,12 @@ func (r *ReadVReader) readMulti() (MultiBuffer, error) {
 // ReadMultiBuffer implements Reader.
 func (r *ReadVReader) ReadMultiBuffer() (MultiBuffer, error) {
 	if r.alloc.Current() == 1 {
-		b, err := ReadBuffer(r.Reader)
-		if b.IsFull() {
+		small := r.alloc.small
+		b, err := readBuffer(r.Reader, r.alloc.New())
+		if b != nil {
+			r.alloc.AdjustSize(b)
+		}
+		if b.IsFull() && !small {
 			r.alloc.Adjust(1)
 		}
 		if r.counter != nil && b != nil {
@@ -1,35 +1,37 @@
 package bytespool
 
-import "sync"
-
-func createAllocFunc(size int32) func() interface{} {
-	return func() interface{} {
-		return make([]byte, size)
-	}
-}
+import (
+	"sync"
+	"sync/atomic"
+)
 
-// The following parameters controls the size of buffer pools.
-// There are numPools pools. Starting from 2k size, the size of each pool is sizeMulti of the previous one.
+// poolSizes are the size classes of the buffer pools, ascending.
 // Package buf is guaranteed to not use buffers larger than the largest pool.
 // Other packets may use larger buffers.
-const (
-	numPools  = 4
-	sizeMulti = 4
-)
+var poolSizes = [...]int32{2048, 8192, 32768, 65536}
+
+const numPools = len(poolSizes)
 
 var (
-	pool     [numPools]sync.Pool
-	poolSize [numPools]int32
+	pool      [numPools]sync.Pool
+	poolSize  [numPools]int32
+	poolStats [numPools]struct {
+		allocated atomic.Uint64
+		gets      atomic.Uint64
+		puts      atomic.Uint64
+	}
 )
 
 func init() {
-	size := int32(2048)
-	for i := 0; i < numPools; i++ {
+	for i, size := range poolSizes {
+		stats := &poolStats[i]
 		pool[i] = sync.Pool{
-			New: createAllocFunc(size),
+			New: func() interface{} {
+				stats.allocated.Add(1)
+				return make([]byte, size)
+			},
 		}
 		poolSize[i] = size
-		size *= sizeMulti
 	}
 }
 
@@ -50,9 +52,11 @@ func GetPool(size int32) *sync.Pool {
 //
 // xray:api:stable
 func Alloc(size int32) []byte {
-	pool := GetPool(size)
-	if pool != nil {
-		return pool.Get().([]byte)
+	for idx, ps := range poolSize {
+		if size <= ps {
+			poolStats[idx].gets.Add(1)
+			return pool[idx].Get().([]byte)
+		}
 	}
 	return make([]byte, size)
 }
@@ -65,8 +69,36 @@ func Free(b []byte) {
 	b = b[0:cap(b)]
 	for i := numPools - 1; i >= 0; i-- {
 		if size >= poolSize[i] {
+			poolStats[i].puts.Add(1)
 			pool[i].Put(b)
 			return
 		}
 	}
 }
+
+// PoolStats is the utilization of a pool, for the arrays taken with Alloc.
+type PoolStats struct {
+	// Size is the size of the arrays of the pool.
+	Size int32
+	// Allocated is how many arrays the pool has created, as it had none to
+	// reuse.
+	Allocated uint64
+	// Gets and Puts are how many arrays have been taken from and put into the
+	// pool. Their difference is how many are in use.
+	Gets uint64
+	Puts uint64
+}
+
+// Stats returns the utilization of each pool, by ascending size.
+func Stats() []PoolStats {
+	stats := make([]PoolStats, numPools)
+	for i := range stats {
+		stats[i] = PoolStats{
+			Size:      poolSize[i],
+			Allocated: poolStats[i].allocated.Load(),
+			Gets:      poolStats[i].gets.Load(),
+			Puts:      poolStats[i].puts.Load(),
+		}
+	}
+	return stats
+}
@@ -11,6 +11,9 @@ type Lru interface {
 	GetKeyFromValue(value interface{}) (key interface{}, ok bool)
 	PeekKeyFromValue(value interface{}) (key interface{}, ok bool) // Peek means check but NOT bring to top
 	Put(key, value interface{})
+	// Range calls f for each entry from the least recently used, so that
+	// putting them in order makes a cache of the same order.
+	Range(f func(key, value interface{}))
 }
 
 type lru struct {
@@ -87,3 +90,12 @@ func (l *lru) Put(key, value interface{}) {
 	}
 	l.mu.Unlock()
 }
+
+func (l *lru) Range(f func(key, value interface{})) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for element := l.doubleLinkedlist.Back(); element != nil; element = element.Prev() {
+		e := element.Value.(*lruElement)
+		f(e.key, e.value)
+	}
+}
@@ -0,0 +1,103 @@
+// Package events is the bus of the notable events of the process, such as an
+// outbound going down, for them to be notified to operators.
+package events
+
+import (
+	"strings"
+	"time"
+
+	"github.com/xtls/xray-core/common/signal/pubsub"
+)
+
+// Types of events.
+const (
+	// CoreStarted is emitted as an instance has started.
+	CoreStarted = "core.started"
+	// CoreStopped is emitted as an instance has stopped.
+	CoreStopped = "core.stopped"
+	// OutboundDown is emitted as the observatory finds an outbound failing
+	// its probes, with its "outbound" and the "error".
+	OutboundDown = "outbound.down"
+	// OutboundUp is emitted as an outbound that was down passes its probes
+	// again, with its "outbound" and the "delay" in ms.
+	OutboundUp = "outbound.up"
+	// OutboundEgressChanged is emitted as the observatory finds an outbound
+	// exiting in another country than before, with its "outbound", the "ip"
+	// and "country" it exits from, and the "previous" country.
+	OutboundEgressChanged = "outbound.egress.changed"
+	// CertificateRenewed is emitted as a certificate is issued by ACME or
+	// reloaded from its file, with its "domains", the time it expires
+	// "notAfter", and its "source", "acme" or "file" with its "path".
+	CertificateRenewed = "certificate.renewed"
+	// SourceBanned is emitted as the guard bans a source, with its "ip", the
+	// number of "failures" to authenticate, and the time it is banned
+	// "until".
+	SourceBanned = "source.banned"
+	// UserOverQuota is emitted as a user is first rejected for using its
+	// quota, with its "user", its "tenant" if any, the "quota" and the bytes
+	// "used", in both directions.
+	UserOverQuota = "user.over.quota"
+	// SourceUnbanned is emitted as the ban of a source is lifted before it
+	// expires, with its "ip".
+	SourceUnbanned = "source.unbanned"
+	// RealityTargetDown is emitted as the check of a REALITY target fails,
+	// with its "dest", the "serverName" checked and the "error".
+	RealityTargetDown = "reality.target.down"
+	// RealityTargetUp is emitted as a REALITY target that was down passes its
+	// check again, with its "dest" and the "serverName".
+	RealityTargetUp = "reality.target.up"
+	// RealityTargetChanged is emitted as a REALITY target serves otherwise
+	// than in the last check, with its "dest", the "serverName" and the
+	// "changes".
+	RealityTargetChanged = "reality.target.changed"
+)
+
+// Event is a notable event.
+type Event struct {
+	Type string            `json:"type"`
+	Time time.Time         `json:"time"`
+	Data map[string]string `json:"data,omitempty"`
+}
+
+const topic = "event"
+
+var bus = pubsub.NewService()
+
+// Emit emits an event of eventType, with data as "key", "value" pairs.
+func Emit(eventType string, data ...string) {
+	e := &Event{
+		Type: eventType,
+		Time: time.Now(),
+	}
+	if len(data) > 0 {
+		e.Data = make(map[string]string, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			e.Data[data[i]] = data[i+1]
+		}
+	}
+	bus.Publish(topic, e)
+}
+
+// Subscribe returns a subscriber to all events, which receives *Event.
+// Events are dropped for a subscriber that falls behind.
+func Subscribe() *pubsub.Subscriber {
+	return bus.Subscribe(topic)
+}
+
+// Match returns whether eventType matches one of types, each the type of an
+// event, or a prefix ending in "*". Any type matches empty types.
+func Match(types []string, eventType string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if prefix, ok := strings.CutSuffix(t, "*"); ok {
+			if strings.HasPrefix(eventType, prefix) {
+				return true
+			}
+		} else if t == eventType {
+			return true
+		}
+	}
+	return false
+}
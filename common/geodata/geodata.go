@@ -0,0 +1,358 @@
+// Package geodata reads the entries of geoip and geosite files one at a time,
+// so that loading a code does not read and decode the whole file.
+//
+// A geodata file is either a plain list, as geoip.dat and geosite.dat are,
+// or a compiled one, which starts with an index of its entries. The entries
+// of a plain list are indexed by a scan of the file as it is first opened.
+package geodata
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// magic starts a compiled geodata file. It is followed by the number of
+// entries, and then by the code, offset and length of each entry, ordered by
+// code, before the entries themselves.
+var magic = [4]byte{'X', 'G', 'D', 1}
+
+type span struct {
+	offset int64
+	length uint32
+}
+
+// File is the index of a geodata file.
+type File struct {
+	path    string
+	size    int64
+	modTime time.Time
+	entries map[string]span
+}
+
+// Open indexes the geodata file at path.
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	file := &File{
+		path:    path,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+	}
+	r := bufio.NewReaderSize(f, 64*1024)
+	if head, err := r.Peek(len(magic)); err == nil && [4]byte(head) == magic {
+		file.entries, err = readIndex(r)
+	} else {
+		file.entries, err = scan(r)
+	}
+	if err != nil {
+		return nil, errors.New("invalid geodata file: ", path).Base(err)
+	}
+	return file, nil
+}
+
+func readIndex(r *bufio.Reader) (map[string]span, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	count := binary.BigEndian.Uint32(header[4:])
+	entries := make(map[string]span, count)
+	for range count {
+		codeLen, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		entry := make([]byte, int(codeLen)+12)
+		if _, err := io.ReadFull(r, entry); err != nil {
+			return nil, err
+		}
+		entries[string(entry[:codeLen])] = span{
+			offset: int64(binary.BigEndian.Uint64(entry[codeLen:])),
+			length: binary.BigEndian.Uint32(entry[codeLen+8:]),
+		}
+	}
+	return entries, nil
+}
+
+// scan indexes a plain list, where each entry is a message of field 1 that
+// starts with its code as field 1.
+func scan(r *bufio.Reader) (map[string]span, error) {
+	entries := make(map[string]span)
+	var offset int64
+	for {
+		tag, err := r.ReadByte()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if tag != 0x0a {
+			return nil, errors.New("unexpected field at ", offset)
+		}
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		offset += 1 + int64(uvarintLen(length))
+		entry := span{offset: offset, length: uint32(length)}
+
+		// The code is short, so its tag and length take a byte each.
+		head, err := r.Peek(min(int(length), 2))
+		if err != nil {
+			return nil, err
+		}
+		if len(head) == 2 && head[0] == 0x0a && int(head[1])+2 <= int(length) {
+			code, err := r.Peek(2 + int(head[1]))
+			if err != nil {
+				return nil, err
+			}
+			if _, found := entries[string(code[2:])]; !found {
+				entries[string(code[2:])] = entry
+			}
+		}
+		if _, err := r.Discard(int(length)); err != nil {
+			return nil, err
+		}
+		offset += int64(length)
+	}
+}
+
+func uvarintLen(x uint64) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(buf[:], x)
+}
+
+// Codes returns the codes of the entries of f, in order.
+func (f *File) Codes() []string {
+	codes := make([]string, 0, len(f.entries))
+	for code := range f.entries {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	return codes
+}
+
+// Load reads the entry of code, which is a GeoIP or GeoSite message. It
+// returns nil if f has no such entry.
+func (f *File) Load(code string) ([]byte, error) {
+	entry, found := f.entries[code]
+	if !found {
+		return nil, nil
+	}
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data := make([]byte, entry.length)
+	if _, err := file.ReadAt(data, entry.offset); err != nil {
+		return nil, errors.New("failed to read ", code, " in ", f.path).Base(err)
+	}
+	return data, nil
+}
+
+var (
+	access sync.Mutex
+	files  = make(map[string]*File)
+)
+
+// Load reads the entry of code in the geodata file at path, as File.Load
+// does. The index of the file is kept, until the file changes.
+func Load(path string, code string) ([]byte, error) {
+	file, err := cached(path)
+	if err != nil {
+		return nil, err
+	}
+	return file.Load(code)
+}
+
+// Country returns the code of the country whose entry in the geoip file at
+// path contains ip, or "" if none does. Countries are the entries of codes
+// of two letters, so that ones like PRIVATE or CLOUDFLARE are not taken for
+// them.
+func Country(path string, ip net.IP) (string, error) {
+	file, err := cached(path)
+	if err != nil {
+		return "", err
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, code := range file.Codes() {
+		if len(code) != 2 {
+			continue
+		}
+		entry, err := file.Load(code)
+		if err != nil {
+			return "", err
+		}
+		contains, err := geoIPContains(entry, ip)
+		if err != nil {
+			return "", errors.New("invalid geoip ", code, " in ", path).Base(err)
+		}
+		if contains {
+			return strings.ToUpper(code), nil
+		}
+	}
+	return "", nil
+}
+
+// geoIPContains returns whether the GeoIP message entry has a CIDR that
+// contains ip, without decoding the rest of it.
+func geoIPContains(entry []byte, ip net.IP) (bool, error) {
+	for len(entry) > 0 {
+		num, typ, n := protowire.ConsumeTag(entry)
+		if n < 0 {
+			return false, protowire.ParseError(n)
+		}
+		entry = entry[n:]
+		if num != 2 || typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, entry); n < 0 {
+				return false, protowire.ParseError(n)
+			}
+			entry = entry[n:]
+			continue
+		}
+		cidr, n := protowire.ConsumeBytes(entry)
+		if n < 0 {
+			return false, protowire.ParseError(n)
+		}
+		entry = entry[n:]
+		prefix, err := cidrPrefix(cidr, len(ip))
+		if err != nil {
+			return false, err
+		}
+		if prefix != nil && prefix.Contains(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// cidrPrefix returns the network of the CIDR message cidr, or nil if its IP
+// is not of size.
+func cidrPrefix(cidr []byte, size int) (*net.IPNet, error) {
+	var ip []byte
+	var prefix uint64
+	for len(cidr) > 0 {
+		num, typ, n := protowire.ConsumeTag(cidr)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		cidr = cidr[n:]
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			ip, n = protowire.ConsumeBytes(cidr)
+		case num == 2 && typ == protowire.VarintType:
+			prefix, n = protowire.ConsumeVarint(cidr)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, cidr)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		cidr = cidr[n:]
+	}
+	if len(ip) != size || prefix > uint64(size*8) {
+		return nil, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(int(prefix), size*8)}, nil
+}
+
+// cached returns the index of the geodata file at path, indexing it again if
+// it changed.
+func cached(path string) (*File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	access.Lock()
+	defer access.Unlock()
+	file := files[path]
+	if file == nil || file.size != info.Size() || !file.modTime.Equal(info.ModTime()) {
+		if file, err = Open(path); err != nil {
+			return nil, err
+		}
+		files[path] = file
+	}
+	return file, nil
+}
+
+// Compile writes the geodata file at src to dst in the compiled form, which
+// is indexed without a scan. dst may be src.
+func Compile(dst, src string) error {
+	index, err := Open(src)
+	if err != nil {
+		return err
+	}
+	codes := index.Codes()
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	w := bufio.NewWriterSize(out, 64*1024)
+	header := binary.BigEndian.AppendUint32(magic[:], uint32(len(codes)))
+	offset := int64(len(header))
+	for _, code := range codes {
+		if len(code) > 255 {
+			return errors.New("code too long: ", code)
+		}
+		offset += int64(1 + len(code) + 12)
+	}
+	for _, code := range codes {
+		entry := index.entries[code]
+		header = append(header, byte(len(code)))
+		header = append(header, code...)
+		header = binary.BigEndian.AppendUint64(header, uint64(offset))
+		header = binary.BigEndian.AppendUint32(header, entry.length)
+		offset += int64(entry.length)
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	for _, code := range codes {
+		entry := index.entries[code]
+		if _, err := io.Copy(w, io.NewSectionReader(in, entry.offset, int64(entry.length))); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := out.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
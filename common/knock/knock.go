@@ -0,0 +1,152 @@
+// Package knock implements single packet authorization: a gate that allows
+// the sources that knock with a packet authenticated by a shared key, for a
+// while, and the packets to knock with.
+package knock
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+)
+
+const (
+	version   = 1
+	nonceSize = 16
+
+	// PacketSize is the size of a knock packet: the version, the time of the
+	// knock in Unix seconds, a random nonce, and the HMAC-SHA256 of them.
+	PacketSize = 1 + 8 + nonceSize + sha256.Size
+
+	// Window is how far the time of a knock may be from the time it is
+	// received.
+	Window = 30 * time.Second
+)
+
+// NewPacket returns a packet to knock at now with, authenticated by key.
+func NewPacket(key []byte, now time.Time) []byte {
+	packet := make([]byte, PacketSize)
+	packet[0] = version
+	binary.BigEndian.PutUint64(packet[1:9], uint64(now.Unix()))
+	rand.Read(packet[9 : 9+nonceSize])
+	copy(packet[9+nonceSize:], sign(key, packet[:9+nonceSize]))
+	return packet
+}
+
+func sign(key []byte, b []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+	return mac.Sum(nil)
+}
+
+// Gate allows the sources that knock with a valid packet, for ttl after the
+// knock.
+type Gate struct {
+	key []byte
+	ttl time.Duration
+
+	access    sync.Mutex
+	allowed   map[netip.Addr]time.Time
+	nonces    map[[nonceSize]byte]time.Time
+	lastClean time.Time
+}
+
+// NewGate creates a Gate of the packets authenticated by key.
+func NewGate(key []byte, ttl time.Duration) *Gate {
+	return &Gate{
+		key:     key,
+		ttl:     ttl,
+		allowed: make(map[netip.Addr]time.Time),
+		nonces:  make(map[[nonceSize]byte]time.Time),
+	}
+}
+
+func addrOf(ip net.IP) (netip.Addr, bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	return addr.Unmap(), ok
+}
+
+// Allow returns whether ip has knocked within the ttl.
+func (g *Gate) Allow(ip net.IP) bool {
+	addr, ok := addrOf(ip)
+	if !ok {
+		return false
+	}
+	g.access.Lock()
+	defer g.access.Unlock()
+	expire, found := g.allowed[addr]
+	return found && time.Now().Before(expire)
+}
+
+// Knock allows ip if packet is valid, and not replayed. It returns whether it
+// is.
+func (g *Gate) Knock(packet []byte, ip net.IP) bool {
+	if len(packet) != PacketSize || packet[0] != version {
+		return false
+	}
+	addr, ok := addrOf(ip)
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	at := time.Unix(int64(binary.BigEndian.Uint64(packet[1:9])), 0)
+	if at.Before(now.Add(-Window)) || at.After(now.Add(Window)) {
+		return false
+	}
+	if !hmac.Equal(packet[9+nonceSize:], sign(g.key, packet[:9+nonceSize])) {
+		return false
+	}
+
+	g.access.Lock()
+	defer g.access.Unlock()
+	g.clean(now)
+	var nonce [nonceSize]byte
+	copy(nonce[:], packet[9:9+nonceSize])
+	if _, found := g.nonces[nonce]; found {
+		return false
+	}
+	// A nonce can not be replayed once the time of its knock is out of the
+	// window.
+	g.nonces[nonce] = at.Add(Window)
+	g.allowed[addr] = now.Add(g.ttl)
+	return true
+}
+
+// clean removes the expired sources and nonces, once in a window.
+func (g *Gate) clean(now time.Time) {
+	if now.Sub(g.lastClean) < Window {
+		return
+	}
+	g.lastClean = now
+	for addr, expire := range g.allowed {
+		if !now.Before(expire) {
+			delete(g.allowed, addr)
+		}
+	}
+	for nonce, expire := range g.nonces {
+		if now.After(expire) {
+			delete(g.nonces, nonce)
+		}
+	}
+}
+
+// Serve receives knocks from conn, until it is closed.
+func (g *Gate) Serve(conn net.PacketConn) error {
+	b := make([]byte, PacketSize+1)
+	for {
+		n, addr, err := conn.ReadFrom(b)
+		if err != nil {
+			return err
+		}
+		if addr, ok := addr.(*net.UDPAddr); ok && g.Knock(b[:n], addr.IP) {
+			errors.LogInfo(context.Background(), "allowed ", addr.IP, " for ", g.ttl, " by knock")
+		}
+	}
+}
@@ -18,15 +18,24 @@ type AccessStatus string
 const (
 	AccessAccepted = AccessStatus("accepted")
 	AccessRejected = AccessStatus("rejected")
+	// AccessTerminated is of connections terminated abnormally, for the
+	// reason of the message.
+	AccessTerminated = AccessStatus("terminated")
 )
 
 type AccessMessage struct {
-	From   interface{}
-	To     interface{}
-	Status AccessStatus
-	Reason interface{}
-	Email  string
-	Detour string
+	From      interface{}
+	To        interface{}
+	Status    AccessStatus
+	Reason    interface{}
+	Email     string
+	TLSClient string
+	TLSSNI    string
+	TLSALPN   string
+	Detour    string
+	// Tags of the inbound and the outbound of the connection, not printed.
+	InboundTag  string
+	OutboundTag string
 }
 
 func (m *AccessMessage) String() string {
@@ -55,6 +64,21 @@ func (m *AccessMessage) String() string {
 		builder.WriteString(m.Email)
 	}
 
+	if len(m.TLSClient) > 0 {
+		builder.WriteString(" tls client: ")
+		builder.WriteString(m.TLSClient)
+	}
+
+	if len(m.TLSSNI) > 0 {
+		builder.WriteString(" tls sni: ")
+		builder.WriteString(m.TLSSNI)
+	}
+
+	if len(m.TLSALPN) > 0 {
+		builder.WriteString(" tls alpn: ")
+		builder.WriteString(m.TLSALPN)
+	}
+
 	return builder.String()
 }
 
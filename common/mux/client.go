@@ -15,6 +15,7 @@ import (
 	"github.com/xtls/xray-core/common/signal/done"
 	"github.com/xtls/xray-core/common/task"
 	"github.com/xtls/xray-core/common/xudp"
+	"github.com/xtls/xray-core/features/stats"
 	"github.com/xtls/xray-core/proxy"
 	"github.com/xtls/xray-core/transport"
 	"github.com/xtls/xray-core/transport/internet"
@@ -132,6 +133,7 @@ type DialingWorkerFactory struct {
 	Proxy    proxy.Outbound
 	Dialer   internet.Dialer
 	Strategy ClientStrategy
+	Stats    ClientStats
 }
 
 func (f *DialingWorkerFactory) Create() (*ClientWorker, error) {
@@ -139,13 +141,10 @@ func (f *DialingWorkerFactory) Create() (*ClientWorker, error) {
 	uplinkReader, upLinkWriter := pipe.New(opts...)
 	downlinkReader, downlinkWriter := pipe.New(opts...)
 
-	c, err := NewClientWorker(transport.Link{
+	c := newClientWorker(transport.Link{
 		Reader: downlinkReader,
 		Writer: upLinkWriter,
-	}, f.Strategy)
-	if err != nil {
-		return nil, err
-	}
+	}, f.Strategy, f.Stats)
 
 	go func(p proxy.Outbound, d internet.Dialer, c common.Closable) {
 		outbounds := []*session.Outbound{{
@@ -167,6 +166,18 @@ func (f *DialingWorkerFactory) Create() (*ClientWorker, error) {
 type ClientStrategy struct {
 	MaxConcurrency uint32
 	MaxConnection  uint32
+	// MaxLifetime is the age after which a worker takes no new sessions.
+	MaxLifetime time.Duration
+	// Padding adds random padding frames after data frames.
+	Padding bool
+}
+
+// ClientStats holds the optional gauges of the workers of a factory.
+type ClientStats struct {
+	// Connections is the number of open mux connections.
+	Connections stats.Counter
+	// Sessions is the number of sessions carried by them.
+	Sessions stats.Counter
 }
 
 type ClientWorker struct {
@@ -175,6 +186,8 @@ type ClientWorker struct {
 	done           *done.Instance
 	timer          *time.Ticker
 	strategy       ClientStrategy
+	created        time.Time
+	connections    stats.Counter
 }
 
 var (
@@ -184,18 +197,28 @@ var (
 
 // NewClientWorker creates a new mux.Client.
 func NewClientWorker(stream transport.Link, s ClientStrategy) (*ClientWorker, error) {
+	return newClientWorker(stream, s, ClientStats{}), nil
+}
+
+func newClientWorker(stream transport.Link, s ClientStrategy, st ClientStats) *ClientWorker {
 	c := &ClientWorker{
 		sessionManager: NewSessionManager(),
 		link:           stream,
 		done:           done.New(),
 		timer:          time.NewTicker(time.Second * 16),
 		strategy:       s,
+		created:        time.Now(),
+		connections:    st.Connections,
+	}
+	c.sessionManager.counter = st.Sessions
+	if c.connections != nil {
+		c.connections.Add(1)
 	}
 
 	go c.fetchOutput()
 	go c.monitor()
 
-	return c, nil
+	return c
 }
 
 func (m *ClientWorker) TotalConnections() uint32 {
@@ -224,6 +247,9 @@ func (m *ClientWorker) monitor() {
 			m.sessionManager.Close()
 			common.Close(m.link.Writer)
 			common.Interrupt(m.link.Reader)
+			if m.connections != nil {
+				m.connections.Add(-1)
+			}
 			return
 		case <-m.timer.C:
 			size := m.sessionManager.Size()
@@ -247,7 +273,7 @@ func writeFirstPayload(reader buf.Reader, writer *Writer) error {
 	return nil
 }
 
-func fetchInput(ctx context.Context, s *Session, output buf.Writer) {
+func fetchInput(ctx context.Context, s *Session, output buf.Writer, padding bool) {
 	outbounds := session.OutboundsFromContext(ctx)
 	ob := outbounds[len(outbounds)-1]
 	transferType := protocol.TransferTypeStream
@@ -256,6 +282,7 @@ func fetchInput(ctx context.Context, s *Session, output buf.Writer) {
 	}
 	s.transferType = transferType
 	writer := NewWriter(s.ID, ob.Target, output, transferType, xudp.GetGlobalID(ctx))
+	writer.padding = padding
 	defer s.Close(false)
 	defer writer.Close()
 
@@ -278,6 +305,9 @@ func (m *ClientWorker) IsClosing() bool {
 	if m.strategy.MaxConnection > 0 && sm.Count() >= int(m.strategy.MaxConnection) {
 		return true
 	}
+	if m.strategy.MaxLifetime > 0 && time.Since(m.created) >= m.strategy.MaxLifetime {
+		return true
+	}
 	return false
 }
 
@@ -307,7 +337,7 @@ func (m *ClientWorker) Dispatch(ctx context.Context, link *transport.Link) bool
 	}
 	s.input = link.Reader
 	s.output = link.Writer
-	go fetchInput(ctx, s, m.link.Writer)
+	go fetchInput(ctx, s, m.link.Writer, m.strategy.Padding)
 	return true
 }
 
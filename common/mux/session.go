@@ -12,6 +12,7 @@ import (
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/features/stats"
 	"github.com/xtls/xray-core/transport/pipe"
 )
 
@@ -20,6 +21,8 @@ type SessionManager struct {
 	sessions map[uint16]*Session
 	count    uint16
 	closed   bool
+	// counter, if set, follows the number of sessions.
+	counter stats.Counter
 }
 
 func NewSessionManager() *SessionManager {
@@ -67,6 +70,9 @@ func (m *SessionManager) Allocate(Strategy *ClientStrategy) *Session {
 		parent: m,
 	}
 	m.sessions[s.ID] = s
+	if m.counter != nil {
+		m.counter.Add(1)
+	}
 	return s
 }
 
@@ -80,6 +86,9 @@ func (m *SessionManager) Add(s *Session) bool {
 
 	m.count++
 	m.sessions[s.ID] = s
+	if m.counter != nil {
+		m.counter.Add(1)
+	}
 	return true
 }
 
@@ -94,6 +103,9 @@ func (m *SessionManager) Remove(locked bool, id uint16) {
 		return
 	}
 
+	if _, found := m.sessions[id]; found && m.counter != nil {
+		m.counter.Add(-1)
+	}
 	delete(m.sessions, id)
 
 	/*
@@ -144,6 +156,9 @@ func (m *SessionManager) Close() error {
 	for _, s := range m.sessions {
 		s.Close(true)
 	}
+	if m.counter != nil {
+		m.counter.Add(-int64(len(m.sessions)))
+	}
 
 	m.sessions = nil
 	return nil
@@ -1,8 +1,11 @@
 package mux
 
 import (
+	"crypto/rand"
+
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/dice"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
@@ -16,6 +19,7 @@ type Writer struct {
 	hasError     bool
 	transferType protocol.TransferType
 	globalID     [8]byte
+	padding      bool
 }
 
 func NewWriter(id uint16, dest net.Destination, writer buf.Writer, transferType protocol.TransferType, globalID [8]byte) *Writer {
@@ -86,7 +90,25 @@ func (w *Writer) writeData(mb buf.MultiBuffer) error {
 	meta := w.getNextFrameMeta()
 	meta.Option.Set(OptionData)
 
-	return writeMetaWithFrame(w.writer, meta, mb)
+	if err := writeMetaWithFrame(w.writer, meta, mb); err != nil {
+		return err
+	}
+	if w.padding {
+		return w.writePadding()
+	}
+	return nil
+}
+
+// writePadding sends random bytes in a KeepAlive frame, which peers discard.
+func (w *Writer) writePadding() error {
+	meta := FrameMetadata{
+		SessionID:     w.id,
+		SessionStatus: SessionStatusKeepAlive,
+	}
+	meta.Option.Set(OptionData)
+	padding := buf.New()
+	common.Must2(padding.ReadFullFrom(rand.Reader, int32(1+dice.Roll(256))))
+	return writeMetaWithFrame(w.writer, meta, buf.MultiBuffer{padding})
 }
 
 // WriteMultiBuffer implements buf.Writer.
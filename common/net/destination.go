@@ -2,6 +2,7 @@ package net
 
 import (
 	"net"
+	"path/filepath"
 	"strings"
 )
 
@@ -69,6 +70,18 @@ func TCPDestination(address Address, port Port) Destination {
 	}
 }
 
+// StreamDestination creates a Unix destination if address is a path or an
+// @abstract name, or a TCP destination otherwise. It is for the addresses of
+// the config only, as the ones clients send may point to any local socket.
+func StreamDestination(address Address, port Port) Destination {
+	if address.Family().IsDomain() {
+		if domain := address.Domain(); filepath.IsAbs(domain) || domain[0] == '@' {
+			return UnixDestination(address)
+		}
+	}
+	return TCPDestination(address, port)
+}
+
 // UDPDestination creates a UDP destination with given address
 func UDPDestination(address Address, port Port) Destination {
 	return Destination{
@@ -23,11 +23,17 @@ var LookupIP = net.LookupIP
 
 var FileConn = net.FileConn
 
+var FileListener = net.FileListener
+
+var FilePacketConn = net.FilePacketConn
+
 // ParseIP is an alias of net.ParseIP
 var ParseIP = net.ParseIP
 
 var SplitHostPort = net.SplitHostPort
 
+var JoinHostPort = net.JoinHostPort
+
 var CIDRMask = net.CIDRMask
 
 type (
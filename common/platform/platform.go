@@ -17,6 +17,7 @@ const (
 
 	UseReadV         = "xray.buf.readv"
 	UseFreedomSplice = "xray.buf.splice"
+	UseSockmap       = "xray.buf.sockmap"
 	UseVmessPadding  = "xray.vmess.padding"
 	UseCone          = "xray.cone.disabled"
 
@@ -24,6 +25,7 @@ const (
 	BrowserDialerAddress = "xray.browser.dialer"
 	XUDPLog              = "xray.xudp.show"
 	XUDPBaseKey          = "xray.xudp.basekey"
+	TLSFingerprint       = "xray.tls.fingerprint"
 )
 
 type EnvFlag struct {
@@ -1,16 +1,18 @@
 package bittorrent
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
-	"math"
-	"time"
+	"strings"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 )
 
-type SniffHeader struct{}
+type SniffHeader struct {
+	kind string
+}
 
 func (h *SniffHeader) Protocol() string {
 	return "bittorrent"
@@ -20,6 +22,12 @@ func (h *SniffHeader) Domain() string {
 	return ""
 }
 
+// Attributes returns what the traffic is as ":bittorrent",
+// one of "peer", "utp", "dht" and "tracker".
+func (h *SniffHeader) Attributes() map[string]string {
+	return map[string]string{":bittorrent": h.kind}
+}
+
 var errNotBittorrent = errors.New("not bittorrent header")
 
 func SniffBittorrent(b []byte) (*SniffHeader, error) {
@@ -28,7 +36,7 @@ func SniffBittorrent(b []byte) (*SniffHeader, error) {
 	}
 
 	if b[0] == 19 && string(b[1:20]) == "BitTorrent protocol" {
-		return &SniffHeader{}, nil
+		return &SniffHeader{kind: "peer"}, nil
 	}
 
 	return nil, errNotBittorrent
@@ -74,17 +82,59 @@ func SniffUTP(b []byte) (*SniffHeader, error) {
 		}
 	}
 
-	if common.Error2(buffer.ReadBytes(2)) != nil {
+	// The first packet of a connection is a ST_SYN, which carries no data.
+	// The timestamps are of the clocks of the peers, so they tell nothing.
+	if b[0]>>4 != stSYN || buffer.Len() != 18 {
+		return nil, errNotBittorrent
+	}
+
+	return &SniffHeader{kind: "utp"}, nil
+}
+
+const stSYN = 4
+
+// SniffDHT detects a KRPC message of the DHT, a bencoded dictionary with
+// the type of the message in key "y". See https://www.bittorrent.org/beps/bep_0005.html.
+func SniffDHT(b []byte) (*SniffHeader, error) {
+	if len(b) < 12 {
 		return nil, common.ErrNoClue
 	}
 
-	var timestamp uint32
-	if err := binary.Read(buffer, binary.BigEndian, &timestamp); err != nil {
+	if b[0] != 'd' || b[len(b)-1] != 'e' || !bytes.Contains(b, []byte("1:t")) {
+		return nil, errNotBittorrent
+	}
+	for _, y := range []string{"1:y1:q", "1:y1:r", "1:y1:e"} {
+		if bytes.Contains(b, []byte(y)) {
+			return &SniffHeader{kind: "dht"}, nil
+		}
+	}
+
+	return nil, errNotBittorrent
+}
+
+// udpTrackerProtocolID is the magic constant of a connect request to a UDP
+// tracker. See https://www.bittorrent.org/beps/bep_0015.html.
+const udpTrackerProtocolID = 0x41727101980
+
+// SniffUDPTracker detects the connect request a client sends first to a UDP tracker.
+func SniffUDPTracker(b []byte) (*SniffHeader, error) {
+	if len(b) < 16 {
 		return nil, common.ErrNoClue
 	}
-	if math.Abs(float64(time.Now().UnixMicro()-int64(timestamp))) > float64(24*time.Hour) {
+
+	if binary.BigEndian.Uint64(b[0:8]) != udpTrackerProtocolID || binary.BigEndian.Uint32(b[8:12]) != 0 /* connect */ {
 		return nil, errNotBittorrent
 	}
 
-	return &SniffHeader{}, nil
+	return &SniffHeader{kind: "tracker"}, nil
+}
+
+// IsTrackerRequest returns whether the path of an HTTP request is an announce
+// or scrape of a tracker, which identifies the torrent by "info_hash".
+func IsTrackerRequest(path string) bool {
+	_, query, ok := strings.Cut(path, "?")
+	if !ok {
+		return false
+	}
+	return strings.HasPrefix(query, "info_hash=") || strings.Contains(query, "&info_hash=")
 }
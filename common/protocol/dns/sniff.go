@@ -0,0 +1,55 @@
+package dns
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/xtls/xray-core/common"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type SniffHeader struct {
+	name  string
+	qtype string
+}
+
+func (h *SniffHeader) Protocol() string {
+	return "dns"
+}
+
+// Domain returns no domain, as the query is not of the destination.
+func (h *SniffHeader) Domain() string {
+	return ""
+}
+
+// Attributes returns the name and the type, such as "A" or "HTTPS", queried.
+func (h *SniffHeader) Attributes() map[string]string {
+	return map[string]string{":dns-name": h.name, ":dns-type": h.qtype}
+}
+
+var errNotDNS = errors.New("not dns query")
+
+// SniffDNS detects a standard query of a single question in a UDP packet, as
+// in https://tools.ietf.org/html/rfc1035#section-4.1.
+func SniffDNS(b []byte) (*SniffHeader, error) {
+	if len(b) < 12 {
+		return nil, common.ErrNoClue
+	}
+	var parser dnsmessage.Parser
+	header, err := parser.Start(b)
+	if err != nil || header.Response || header.OpCode != 0 {
+		return nil, errNotDNS
+	}
+	// Queries have no answers, and at most the OPT record of EDNS.
+	if b[4] != 0 || b[5] != 1 || b[6]|b[7]|b[8]|b[9]|b[10] != 0 || b[11] > 1 {
+		return nil, errNotDNS
+	}
+	question, err := parser.Question()
+	if err != nil {
+		return nil, errNotDNS
+	}
+	return &SniffHeader{
+		name:  strings.ToLower(strings.TrimSuffix(question.Name.String(), ".")),
+		qtype: strings.TrimPrefix(question.Type.String(), "Type"),
+	}, nil
+}
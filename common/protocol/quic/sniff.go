@@ -6,6 +6,7 @@ import (
 	"crypto/tls"
 	"encoding/binary"
 	"io"
+	"strings"
 
 	"github.com/quic-go/quic-go/quicvarint"
 	"github.com/xtls/xray-core/common"
@@ -18,6 +19,7 @@ import (
 
 type SniffHeader struct {
 	domain string
+	alpn   []string
 }
 
 func (s SniffHeader) Protocol() string {
@@ -28,6 +30,14 @@ func (s SniffHeader) Domain() string {
 	return s.domain
 }
 
+// Attributes returns the offered ALPN as ":alpn", comma separated.
+func (s SniffHeader) Attributes() map[string]string {
+	if len(s.alpn) == 0 {
+		return nil
+	}
+	return map[string]string{":alpn": strings.Join(s.alpn, ",")}
+}
+
 const (
 	versionDraft29 uint32 = 0xff00001d
 	version1       uint32 = 0x1
@@ -260,7 +270,7 @@ func SniffQUIC(b []byte) (*SniffHeader, error) {
 			b = restPayload
 			continue
 		}
-		return &SniffHeader{domain: tlsHdr.Domain()}, nil
+		return &SniffHeader{domain: tlsHdr.Domain(), alpn: tlsHdr.ALPN()}, nil
 	}
 	// All payload is parsed as valid QUIC packets, but we need more packets for crypto data to read client hello.
 	return nil, protocol.ErrProtoNeedMoreData
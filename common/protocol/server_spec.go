@@ -59,7 +59,7 @@ func NewServerSpec(dest net.Destination, valid ValidationStrategy, users ...*Mem
 }
 
 func NewServerSpecFromPB(spec *ServerEndpoint) (*ServerSpec, error) {
-	dest := net.TCPDestination(spec.Address.AsAddress(), net.Port(spec.Port))
+	dest := net.StreamDestination(spec.Address.AsAddress(), net.Port(spec.Port))
 	mUsers := make([]*MemoryUser, len(spec.User))
 	for idx, u := range spec.User {
 		mUser, err := u.ToMemoryUser()
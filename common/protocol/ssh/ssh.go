@@ -0,0 +1,65 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/protocol"
+)
+
+type SniffHeader struct {
+	software string
+}
+
+func (h *SniffHeader) Protocol() string {
+	return "ssh"
+}
+
+func (h *SniffHeader) Domain() string {
+	return ""
+}
+
+// Attributes returns the software of the peer, as in its identification string.
+func (h *SniffHeader) Attributes() map[string]string {
+	return map[string]string{":ssh-software": h.software}
+}
+
+var errNotSSH = errors.New("not ssh identification")
+
+// maxIdentificationLen is the maximum length of the identification string,
+// including CR LF. See https://tools.ietf.org/html/rfc4253#section-4.2.
+const maxIdentificationLen = 255
+
+// SniffSSH detects the identification string a SSH client sends first,
+// "SSH-protoversion-softwareversion SP comments CR LF".
+func SniffSSH(b []byte) (*SniffHeader, error) {
+	if len(b) < 4 {
+		return nil, common.ErrNoClue
+	}
+	if string(b[:4]) != "SSH-" {
+		return nil, errNotSSH
+	}
+
+	end := bytes.IndexByte(b, '\n')
+	if end < 0 {
+		if len(b) >= maxIdentificationLen {
+			return nil, errNotSSH
+		}
+		return nil, protocol.ErrProtoNeedMoreData
+	}
+	line := bytes.TrimSuffix(b[4:end], []byte{'\r'})
+
+	version, software, ok := bytes.Cut(line, []byte{'-'})
+	if !ok || (string(version) != "2.0" && string(version) != "1.99") {
+		return nil, errNotSSH
+	}
+	if i := bytes.IndexByte(software, ' '); i >= 0 {
+		software = software[:i]
+	}
+	if len(software) == 0 {
+		return nil, errNotSSH
+	}
+
+	return &SniffHeader{software: string(software)}, nil
+}
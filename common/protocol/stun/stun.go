@@ -0,0 +1,70 @@
+package stun
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/xtls/xray-core/common"
+)
+
+type SniffHeader struct {
+	method string
+}
+
+func (h *SniffHeader) Protocol() string {
+	return "stun"
+}
+
+func (h *SniffHeader) Domain() string {
+	return ""
+}
+
+// Attributes returns the method of the message, such as "binding" or "allocate" of TURN.
+func (h *SniffHeader) Attributes() map[string]string {
+	return map[string]string{":stun-method": h.method}
+}
+
+var errNotSTUN = errors.New("not stun message")
+
+const (
+	headerLen   = 20
+	magicCookie = 0x2112A442
+)
+
+var methods = map[uint16]string{
+	0x001: "binding",
+	0x003: "allocate",
+	0x004: "refresh",
+	0x006: "send",
+	0x007: "data",
+	0x008: "createpermission",
+	0x009: "channelbind",
+}
+
+// SniffSTUN detects a STUN (and TURN) message, as in https://tools.ietf.org/html/rfc8489#section-5.
+func SniffSTUN(b []byte) (*SniffHeader, error) {
+	if len(b) < headerLen {
+		return nil, common.ErrNoClue
+	}
+
+	// The most significant 2 bits of every message are zeroes.
+	if b[0]&0xC0 != 0 {
+		return nil, errNotSTUN
+	}
+	length := int(binary.BigEndian.Uint16(b[2:4]))
+	if length%4 != 0 || headerLen+length != len(b) {
+		return nil, errNotSTUN
+	}
+	if binary.BigEndian.Uint32(b[4:8]) != magicCookie {
+		return nil, errNotSTUN
+	}
+
+	// The method bits are interleaved with the 2 class bits.
+	t := binary.BigEndian.Uint16(b[0:2])
+	method, ok := methods[t&0xF|(t&0xE0)>>1|(t&0x3E00)>>2]
+	if !ok {
+		method = "unknown"
+	}
+
+	return &SniffHeader{method: method}, nil
+}
@@ -3,6 +3,7 @@ package tls
 import (
 	"encoding/binary"
 	"errors"
+	"strings"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/protocol"
@@ -10,6 +11,7 @@ import (
 
 type SniffHeader struct {
 	domain string
+	alpn   []string
 }
 
 func (h *SniffHeader) Protocol() string {
@@ -20,6 +22,19 @@ func (h *SniffHeader) Domain() string {
 	return h.domain
 }
 
+// ALPN returns the protocols the client offers in the ALPN extension, if any.
+func (h *SniffHeader) ALPN() []string {
+	return h.alpn
+}
+
+// Attributes returns the offered ALPN as ":alpn", comma separated.
+func (h *SniffHeader) Attributes() map[string]string {
+	if len(h.alpn) == 0 {
+		return nil
+	}
+	return map[string]string{":alpn": strings.Join(h.alpn, ",")}
+}
+
 var (
 	errNotTLS         = errors.New("not TLS header")
 	errNotClientHello = errors.New("not client hello")
@@ -29,9 +44,21 @@ func IsValidTLSVersion(major, minor byte) bool {
 	return major == 3
 }
 
-// ReadClientHello returns server name (if any) from TLS client hello message.
+// ReadClientHello returns server name (if any) and ALPN from TLS client hello message.
 // https://github.com/golang/go/blob/master/src/crypto/tls/handshake_messages.go#L300
 func ReadClientHello(data []byte, h *SniffHeader) error {
+	if err := readClientHello(data, h); err != nil {
+		return err
+	}
+	if h.domain == "" {
+		return errNotTLS
+	}
+	return nil
+}
+
+// readClientHello is ReadClientHello, for client hello messages with or
+// without a server name.
+func readClientHello(data []byte, h *SniffHeader) error {
 	if len(data) < 42 {
 		return common.ErrNoClue
 	}
@@ -71,13 +98,13 @@ func ReadClientHello(data []byte, h *SniffHeader) error {
 
 	for len(data) != 0 {
 		if len(data) < 4 {
-			return errNotClientHello
+			break
 		}
 		extension := uint16(data[0])<<8 | uint16(data[1])
 		length := int(data[2])<<8 | int(data[3])
 		data = data[4:]
 		if len(data) < length {
-			return errNotClientHello
+			break
 		}
 
 		if extension == 0x00 { /* extensionServerName */
@@ -117,18 +144,53 @@ func ReadClientHello(data []byte, h *SniffHeader) error {
 					}
 					serverName := string(d[:nameLen])
 					h.domain = serverName
-					return nil
+					break
 				}
 				d = d[nameLen:]
 			}
 		}
+		if extension == 0x10 { /* extensionALPN */
+			h.alpn = readALPN(data[:length])
+		}
 		data = data[length:]
 	}
 
-	return errNotTLS
+	if h.domain == "" && len(data) != 0 {
+		return errNotClientHello
+	}
+	return nil
+}
+
+// readALPN returns the protocol names of an ALPN extension. A malformed
+// extension does not fail sniffing, as the server name is all that matters.
+func readALPN(d []byte) []string {
+	if len(d) < 2 || int(d[0])<<8|int(d[1]) != len(d)-2 {
+		return nil
+	}
+	d = d[2:]
+	var protocols []string
+	for len(d) > 0 {
+		l := int(d[0])
+		if l == 0 || len(d) < 1+l {
+			return nil
+		}
+		protocols = append(protocols, string(d[1:1+l]))
+		d = d[1+l:]
+	}
+	return protocols
 }
 
 func SniffTLS(b []byte) (*SniffHeader, error) {
+	return sniffRecord(b, ReadClientHello)
+}
+
+// SniffClientHello is SniffTLS, for client hellos with or without a server
+// name, whose domain is then empty.
+func SniffClientHello(b []byte) (*SniffHeader, error) {
+	return sniffRecord(b, readClientHello)
+}
+
+func sniffRecord(b []byte, read func([]byte, *SniffHeader) error) (*SniffHeader, error) {
 	if len(b) < 5 {
 		return nil, common.ErrNoClue
 	}
@@ -145,7 +207,7 @@ func SniffTLS(b []byte) (*SniffHeader, error) {
 	}
 
 	h := &SniffHeader{}
-	err := ReadClientHello(b[5:5+headerLen], h)
+	err := read(b[5:5+headerLen], h)
 	if err == nil {
 		return h, nil
 	}
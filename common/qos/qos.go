@@ -0,0 +1,187 @@
+// Package qos shapes traffic to a bandwidth. Traffic is sorted into classes,
+// which are served by priority, and by weight among classes of the same
+// priority.
+package qos
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// chunkSize is the most a Class waits for at once, so that classes of
+	// higher priority get served in between the chunks of larger writes.
+	chunkSize = 16 * 1024
+	// quantum is what a class of weight 1 is served a round.
+	quantum = chunkSize
+)
+
+// Shaper shapes traffic to a rate. It serves waiting classes of higher
+// priority first, so that they are not delayed by the others, and shares the
+// rest among the classes of the same priority by their weights.
+type Shaper struct {
+	rate  float64 // bytes per second
+	burst float64
+
+	access  sync.Mutex
+	levels  []*level
+	tokens  float64
+	last    time.Time
+	waiting int
+	running bool
+}
+
+// level holds the classes of a priority, which are served with deficit round
+// robin.
+type level struct {
+	priority uint32
+	classes  []*Class
+	current  int
+	started  bool
+}
+
+// Class is a class of traffic of a Shaper.
+type Class struct {
+	shaper  *Shaper
+	weight  float64
+	queue   []*request
+	deficit float64
+}
+
+type request struct {
+	size  float64
+	ready chan struct{}
+}
+
+// NewShaper returns a Shaper to rate, in bytes per second, which must not be 0.
+func NewShaper(rate uint64) *Shaper {
+	s := &Shaper{
+		rate:  float64(rate),
+		burst: max(float64(rate)/50, 2*chunkSize),
+		last:  time.Now(),
+	}
+	s.tokens = s.burst
+	return s
+}
+
+// NewClass adds a class to s. Classes of smaller priority values are served
+// first. Among classes of the same priority, a class is served in proportion
+// to weight.
+func (s *Shaper) NewClass(priority uint32, weight uint32) *Class {
+	s.access.Lock()
+	defer s.access.Unlock()
+
+	c := &Class{
+		shaper: s,
+		weight: float64(max(weight, 1)),
+	}
+	for i, l := range s.levels {
+		if l.priority == priority {
+			l.classes = append(l.classes, c)
+			return c
+		}
+		if l.priority > priority {
+			s.levels = append(s.levels[:i], append([]*level{{priority: priority, classes: []*Class{c}}}, s.levels[i:]...)...)
+			return c
+		}
+	}
+	s.levels = append(s.levels, &level{priority: priority, classes: []*Class{c}})
+	return c
+}
+
+// Wait blocks until c may pass size bytes.
+func (c *Class) Wait(size int32) {
+	for size > 0 {
+		n := min(size, chunkSize)
+		c.wait(float64(n))
+		size -= n
+	}
+}
+
+func (c *Class) wait(size float64) {
+	s := c.shaper
+	s.access.Lock()
+	s.refill()
+	if s.waiting == 0 && s.tokens > 0 {
+		s.tokens -= size
+		s.access.Unlock()
+		return
+	}
+	r := &request{size: size, ready: make(chan struct{})}
+	c.queue = append(c.queue, r)
+	s.waiting++
+	if !s.running {
+		s.running = true
+		go s.run()
+	}
+	s.access.Unlock()
+	<-r.ready
+}
+
+func (s *Shaper) refill() {
+	now := time.Now()
+	s.tokens = min(s.tokens+now.Sub(s.last).Seconds()*s.rate, s.burst)
+	s.last = now
+}
+
+// run serves the waiting requests as the tokens allow, until none waits.
+func (s *Shaper) run() {
+	for {
+		s.access.Lock()
+		s.refill()
+		for s.tokens > 0 && s.waiting > 0 {
+			r := s.next()
+			s.waiting--
+			s.tokens -= r.size
+			close(r.ready)
+		}
+		if s.waiting == 0 {
+			s.running = false
+			s.access.Unlock()
+			return
+		}
+		wait := time.Duration((1 - s.tokens) / s.rate * float64(time.Second))
+		s.access.Unlock()
+		time.Sleep(max(wait, time.Millisecond))
+	}
+}
+
+// next dequeues the request to serve next. There must be one.
+func (s *Shaper) next() *request {
+	for _, l := range s.levels {
+		if r := l.next(); r != nil {
+			return r
+		}
+	}
+	panic("qos: no waiting request")
+}
+
+func (l *level) next() *request {
+	for idle := 0; idle < len(l.classes); {
+		c := l.classes[l.current]
+		if len(c.queue) == 0 {
+			c.deficit = 0
+			l.advance()
+			idle++
+			continue
+		}
+		if !l.started {
+			c.deficit += quantum * c.weight
+			l.started = true
+		}
+		if r := c.queue[0]; r.size <= c.deficit {
+			c.queue[0] = nil
+			c.queue = c.queue[1:]
+			c.deficit -= r.size
+			return r
+		}
+		l.advance()
+		idle = 0
+	}
+	return nil
+}
+
+func (l *level) advance() {
+	l.current = (l.current + 1) % len(l.classes)
+	l.started = false
+}
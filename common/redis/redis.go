@@ -0,0 +1,202 @@
+// Package redis is a client of Redis that speaks just enough of RESP, its
+// protocol, for the commands of the apps.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+)
+
+// Error is an error reply of Redis.
+type Error string
+
+func (e Error) Error() string {
+	return "redis: " + string(e)
+}
+
+// Client runs commands on a server, over a connection dialed once needed.
+type Client struct {
+	Address  string
+	Password string
+	DB       uint32
+	// Timeout is of dialing and of each command.
+	Timeout time.Duration
+
+	access sync.Mutex
+	conn   *Conn
+}
+
+// Dial returns a new connection to the server, such as for subscribing to
+// channels, with its deadline of dialing left.
+func (c *Client) Dial(ctx context.Context) (*Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	return Dial(ctx, c.Address, c.Password, c.DB)
+}
+
+// Do runs a command, over a new connection if the last one failed. A command
+// failed over the last connection, which the server may have closed since, is
+// run once again over a new one.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	for retry := c.conn != nil; ; retry = false {
+		if c.conn == nil {
+			conn, err := c.Dial(ctx)
+			if err != nil {
+				return nil, err
+			}
+			c.conn = conn
+		}
+		c.conn.SetDeadline(time.Now().Add(c.Timeout))
+		reply, err := c.conn.Do(args...)
+		if _, ok := err.(Error); err == nil || ok {
+			return reply, err
+		}
+		c.conn.Close()
+		c.conn = nil
+		if !retry {
+			return nil, err
+		}
+	}
+}
+
+// Close closes the connection of the commands, if any.
+func (c *Client) Close() error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// Conn is a connection to Redis.
+type Conn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// Dial connects to the server at address, authenticated with password if
+// any, and with the database db selected. Deadlines of the connection are
+// of the caller to set, such as of ctx for dialing.
+func Dial(ctx context.Context, address string, password string, db uint32) (*Conn, error) {
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, errors.New("failed to connect to Redis").Base(err)
+	}
+	conn := &Conn{Conn: c, reader: bufio.NewReader(c)}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if password != "" {
+		if _, err := conn.Do("AUTH", password); err != nil {
+			conn.Close()
+			return nil, errors.New("failed to authenticate to Redis").Base(err)
+		}
+	}
+	if db != 0 {
+		if _, err := conn.Do("SELECT", strconv.FormatUint(uint64(db), 10)); err != nil {
+			conn.Close()
+			return nil, errors.New("failed to select Redis database").Base(err)
+		}
+	}
+	return conn, nil
+}
+
+// Do runs a command, and returns its reply. An error reply is returned as an
+// Error.
+func (c *Conn) Do(args ...string) (interface{}, error) {
+	if err := c.Send(args...); err != nil {
+		return nil, err
+	}
+	reply, err := c.Read()
+	if e, ok := reply.(Error); ok {
+		return nil, e
+	}
+	return reply, err
+}
+
+// Send writes a command, as an array of bulk strings.
+func (c *Conn) Send(args ...string) error {
+	b := make([]byte, 0, 64)
+	b = append(b, '*')
+	b = strconv.AppendInt(b, int64(len(args)), 10)
+	b = append(b, '\r', '\n')
+	for _, arg := range args {
+		b = append(b, '$')
+		b = strconv.AppendInt(b, int64(len(arg)), 10)
+		b = append(b, '\r', '\n')
+		b = append(b, arg...)
+		b = append(b, '\r', '\n')
+	}
+	_, err := c.Write(b)
+	return err
+}
+
+// Read returns a reply: a string, an int64, nil, an Error, or an array of
+// them.
+func (c *Conn) Read() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("invalid Redis reply")
+	}
+	kind, line := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return Error(line), nil
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, b); err != nil {
+			return nil, err
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		array := make([]interface{}, n)
+		for i := range array {
+			if array[i], err = c.Read(); err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	}
+	return nil, errors.New("invalid Redis reply")
+}
+
+// Strings returns the strings of the array of reply, skipping the other
+// ones.
+func Strings(reply interface{}) []string {
+	array, _ := reply.([]interface{})
+	s := make([]string, 0, len(array))
+	for _, v := range array {
+		if v, ok := v.(string); ok {
+			s = append(s, v)
+		}
+	}
+	return s
+}
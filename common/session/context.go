@@ -6,6 +6,7 @@ import (
 
 	"github.com/xtls/xray-core/common/ctx"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/signal"
 	"github.com/xtls/xray-core/features/routing"
 )
 
@@ -25,6 +26,10 @@ const (
 	handlerSessionKey         ctx.SessionKey = 10
 	mitmAlpn11Key             ctx.SessionKey = 11
 	mitmServerNameKey         ctx.SessionKey = 12
+	dialerChainKey            ctx.SessionKey = 13
+	listenGateKey             ctx.SessionKey = 14
+	dialPolicyKey             ctx.SessionKey = 15
+	terminationKey            ctx.SessionKey = 16
 )
 
 func ContextWithInbound(ctx context.Context, inbound *Inbound) context.Context {
@@ -194,3 +199,67 @@ func MitmServerNameFromContext(ctx context.Context) string {
 	}
 	return ""
 }
+
+// ContextWithDialerChain returns a new context with the outbounds the next
+// outbound dials through, overriding its dialer proxy.
+func ContextWithDialerChain(ctx context.Context, chain []string) context.Context {
+	return context.WithValue(ctx, dialerChainKey, chain)
+}
+
+func DialerChainFromContext(ctx context.Context) []string {
+	if val, ok := ctx.Value(dialerChainKey).([]string); ok {
+		return val
+	}
+	return nil
+}
+
+// ListenGate decides whether the listeners of an inbound accept the
+// connections and packets of a source.
+type ListenGate interface {
+	Allow(ip net.IP) bool
+	// AllowForwarded returns whether the requests whose source ip is
+	// forwarded in a header, such as X-Forwarded-For, are accepted.
+	AllowForwarded(ip net.IP) bool
+}
+
+// ContextWithListenGate returns a new context with the gate of the listeners
+// created with it.
+func ContextWithListenGate(ctx context.Context, gate ListenGate) context.Context {
+	return context.WithValue(ctx, listenGateKey, gate)
+}
+
+func ListenGateFromContext(ctx context.Context) ListenGate {
+	if val, ok := ctx.Value(listenGateKey).(ListenGate); ok {
+		return val
+	}
+	return nil
+}
+
+// ContextWithDialPolicy returns a new context with the policy of the outbound
+// dialing the connection.
+func ContextWithDialPolicy(ctx context.Context, p *DialPolicy) context.Context {
+	return context.WithValue(ctx, dialPolicyKey, p)
+}
+
+func DialPolicyFromContext(ctx context.Context) *DialPolicy {
+	if val, ok := ctx.Value(dialPolicyKey).(*DialPolicy); ok {
+		return val
+	}
+	return nil
+}
+
+// ContextWithTermination returns a new context with a record of the
+// termination of the connection, in which the activity timers record
+// TerminationIdleTimeout.
+func ContextWithTermination(ctx context.Context) (context.Context, *TerminationRecord) {
+	r := new(TerminationRecord)
+	ctx = context.WithValue(ctx, terminationKey, r)
+	return signal.ContextWithIdleHandler(ctx, func() { r.Terminate(TerminationIdleTimeout) }), r
+}
+
+func TerminationFromContext(ctx context.Context) *TerminationRecord {
+	if val, ok := ctx.Value(terminationKey).(*TerminationRecord); ok {
+		return val
+	}
+	return nil
+}
@@ -4,7 +4,11 @@ package session // import "github.com/xtls/xray-core/common/session"
 import (
 	"context"
 	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/xtls/xray-core/common/buf"
 	c "github.com/xtls/xray-core/common/ctx"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
@@ -46,8 +50,20 @@ type Inbound struct {
 	User *protocol.MemoryUser
 	// Conn is actually internet.Connection. May be nil.
 	Conn net.Conn
+	// Accepted is the time the connection was accepted. May be zero.
+	Accepted time.Time
+	// TLSClient is the identity of the certificate the client presented to a TLS inbound. May be empty.
+	TLSClient string
+	// TLSServerName and TLSALPN are the server name the client sent to a TLS or REALITY inbound, and the protocol negotiated by ALPN. May be empty.
+	TLSServerName string
+	TLSALPN       string
+	// Tenant is the name of the tenant of the connection. May be empty.
+	Tenant string
 	// Timer of the inbound buf copier. May be nil.
 	Timer *signal.ActivityTimer
+	// KeepMapping keeps the UDP mapping of the connection while idle for up to
+	// the duration, if longer than the inbound keeps it for. May be nil.
+	KeepMapping func(time.Duration)
 	// CanSpliceCopy is a property for this connection
 	// 1 = can, 2 = after processing protocol info should be able to, 3 = cannot
 	CanSpliceCopy int
@@ -59,17 +75,52 @@ type Outbound struct {
 	OriginalTarget net.Destination
 	Target         net.Destination
 	RouteTarget    net.Destination
+	// TargetCNAMEs is the CNAME chain of the target domain, as it is resolved
+	// in routing.
+	TargetCNAMEs []string
 	// Gateway address
 	Gateway net.Address
+	// GatewayPort is the local port to send UDP from, if not zero and free.
+	GatewayPort net.Port
 	// Tag of the outbound proxy that handles the connection.
 	Tag string
 	// Name of the outbound proxy that handles the connection.
 	Name string
+	// Tag of the routing rule that picked the outbound, if any.
+	RuleTag string
 	// Conn is actually internet.Connection. May be nil. It is currently nil for outbound with proxySettings
 	Conn net.Conn
 	// CanSpliceCopy is a property for this connection
 	// 1 = can, 2 = after processing protocol info should be able to, 3 = cannot
 	CanSpliceCopy int
+	// SpliceHandover is set by inbounds that relay their connection as is,
+	// for the outbound to splice the uplink from it. May be nil.
+	SpliceHandover *SpliceHandover
+}
+
+// SpliceHandover hands the connection of an inbound over to the outbound,
+// which then splices the rest of the uplink from it in the kernel.
+type SpliceHandover struct {
+	// Ready is closed by the outbound once it writes the uplink as is.
+	Ready chan struct{}
+	// Handed is closed by the inbound once it has set Conn and Writer and
+	// reads no more. It closes Writer right after, so that the outbound
+	// writes everything before splicing.
+	Handed chan struct{}
+	// Done is closed by the outbound once the uplink ends.
+	Done chan struct{}
+	// Conn is the connection of the inbound.
+	Conn net.Conn
+	// Writer is the link writer of the inbound.
+	Writer buf.Writer
+}
+
+func NewSpliceHandover() *SpliceHandover {
+	return &SpliceHandover{
+		Ready:  make(chan struct{}),
+		Handed: make(chan struct{}),
+		Done:   make(chan struct{}),
+	}
 }
 
 // SniffingRequest controls the behavior of content sniffing.
@@ -79,6 +130,12 @@ type SniffingRequest struct {
 	Enabled                        bool
 	MetadataOnly                   bool
 	RouteOnly                      bool
+	// ExcludeForDestination reports whether connections to the destination are
+	// not sniffed. It may be nil.
+	ExcludeForDestination func(net.Destination) bool // read-only once set
+	// RedirectForDomain returns where connections to destination are sent in
+	// place of the sniffed domain, if they are redirected. It may be nil.
+	RedirectForDomain func(domain string, destination net.Destination) (net.Destination, bool) // read-only once set
 }
 
 // Content is the metadata of the connection content.
@@ -93,6 +150,80 @@ type Content struct {
 	SkipDNSResolve bool
 }
 
+// DialPolicy is the policy of an outbound dialing a connection.
+type DialPolicy struct {
+	// Timeout of connecting a socket. 0 for the one of the system dialer.
+	Timeout time.Duration
+	// Attempts of dialing, with the delay before each retry growing by Backoff.
+	// 0 for the ones of the proxy.
+	Attempts int
+	Backoff  time.Duration
+	// NextIP dials the next one of the IPs a domain resolves to in each retry,
+	// in place of a random one.
+	NextIP bool
+	// Dials is the number of sockets dialed so far.
+	Dials atomic.Int32
+}
+
+// Termination is the class of the reason a connection terminates for.
+type Termination string
+
+const (
+	// TerminationClosed is of connections closed by either end.
+	TerminationClosed = Termination("closed")
+	// TerminationDialTimeout is of connections the last dial of which times out.
+	TerminationDialTimeout = Termination("dial_timeout")
+	// TerminationDialFailed is of connections the last dial of which fails
+	// otherwise.
+	TerminationDialFailed = Termination("dial_failed")
+	// TerminationTLSHandshake is of connections failing their TLS or REALITY
+	// handshake.
+	TerminationTLSHandshake = Termination("tls_handshake")
+	// TerminationReset is of connections reset by the peer.
+	TerminationReset = Termination("reset")
+	// TerminationIdleTimeout is of connections idle for the idle timeout of
+	// their policy.
+	TerminationIdleTimeout = Termination("idle_timeout")
+	// TerminationQuotaExceeded is of connections cut for exceeding a quota of
+	// traffic.
+	TerminationQuotaExceeded = Termination("quota_exceeded")
+	// TerminationError is of connections failing for any other error.
+	TerminationError = Termination("error")
+)
+
+// TerminationRecord records why a connection terminates.
+type TerminationRecord struct {
+	access      sync.Mutex
+	termination Termination
+	dialErr     error
+}
+
+// Terminate records t, unless another one is recorded already, as the one
+// closest to the cause comes first.
+func (r *TerminationRecord) Terminate(t Termination) {
+	r.access.Lock()
+	defer r.access.Unlock()
+	if r.termination == "" {
+		r.termination = t
+	}
+}
+
+// Dialed records the error of the last dial of the connection, nil if it
+// succeeds.
+func (r *TerminationRecord) Dialed(err error) {
+	r.access.Lock()
+	defer r.access.Unlock()
+	r.dialErr = err
+}
+
+// Termination returns the termination recorded, empty if none yet, and the
+// error of the last dial.
+func (r *TerminationRecord) Termination() (Termination, error) {
+	r.access.Lock()
+	defer r.access.Unlock()
+	return r.termination, r.dialErr
+}
+
 // Sockopt is the settings for socket connection.
 type Sockopt struct {
 	// Mark of the socket connection.
@@ -18,6 +18,9 @@ type ActivityTimer struct {
 	updated   chan struct{}
 	checkTask *task.Periodic
 	onTimeout func()
+	// onIdle is called before onTimeout, if the timer times out on its first
+	// timeout.
+	onIdle func()
 }
 
 func (t *ActivityTimer) Update() {
@@ -31,6 +34,13 @@ func (t *ActivityTimer) check() error {
 	select {
 	case <-t.updated:
 	default:
+		t.Lock()
+		onIdle := t.onIdle
+		t.onIdle = nil
+		t.Unlock()
+		if onIdle != nil {
+			onIdle()
+		}
 		t.finish()
 	}
 	return nil
@@ -63,6 +73,7 @@ func (t *ActivityTimer) SetTimeout(timeout time.Duration) {
 
 	t.Lock()
 
+	t.onIdle = nil
 	if t.checkTask != nil {
 		t.checkTask.Close()
 	}
@@ -78,5 +89,21 @@ func CancelAfterInactivity(ctx context.Context, cancel context.CancelFunc, timeo
 		onTimeout: cancel,
 	}
 	timer.SetTimeout(timeout)
+	if onIdle, ok := ctx.Value(idleHandlerKey).(func()); ok {
+		timer.Lock()
+		timer.onIdle = onIdle
+		timer.Unlock()
+	}
 	return timer
 }
+
+type timerKey int
+
+const idleHandlerKey timerKey = 0
+
+// ContextWithIdleHandler returns a context, in which the timers of
+// CancelAfterInactivity call onIdle when they time out on the timeout they
+// are created with, i.e. not on one set later for a half closed connection.
+func ContextWithIdleHandler(ctx context.Context, onIdle func()) context.Context {
+	return context.WithValue(ctx, idleHandlerKey, onIdle)
+}
@@ -6,6 +6,7 @@ import (
 	B "github.com/sagernet/sing/common/buf"
 	"github.com/sagernet/sing/common/bufio"
 	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/transport"
@@ -80,3 +81,49 @@ func (w *PacketConnWrapper) Close() error {
 	buf.ReleaseMulti(w.cached)
 	return nil
 }
+
+// PacketReader is a buf.Reader of the packets of a sing packet conn, each
+// carrying its source in UDP.
+type PacketReader struct {
+	N.PacketReader
+}
+
+func (r *PacketReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	packet := B.NewPacket()
+	defer packet.Release()
+	source, err := r.ReadPacket(packet)
+	if err != nil {
+		return nil, err
+	}
+	var b *buf.Buffer
+	if packet.Len() > buf.Size {
+		b = buf.NewWithSize(int32(packet.Len()))
+	} else {
+		b = buf.New()
+	}
+	b.Write(packet.Bytes())
+	endpoint := ToDestination(source, net.Network_UDP)
+	b.UDP = &endpoint
+	return buf.MultiBuffer{b}, nil
+}
+
+// PacketWriter is a buf.Writer sending each buffer as a packet of a sing
+// packet conn, to its UDP destination or Dest.
+type PacketWriter struct {
+	N.PacketWriter
+	Dest net.Destination
+}
+
+func (w *PacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	defer buf.ReleaseMulti(mb)
+	for _, b := range mb {
+		destination := w.Dest
+		if b.UDP != nil {
+			destination = *b.UDP
+		}
+		if err := w.WritePacket(B.As(b.Bytes()), ToSocksaddr(destination)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Package tracing traces the stages of connections, from accepting them to
+// the handshake of their outbound, as OpenTelemetry spans. The spans are
+// exported by the tracing app, which sets the global tracer provider.
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const instrumentationName = "github.com/xtls/xray-core"
+
+// Names of the spans of the stages.
+const (
+	Connection = "connection"
+	Accept     = "accept"
+	Sniff      = "sniff"
+	Route      = "route"
+	Resolve    = "resolve"
+	Dial       = "dial"
+	Handshake  = "handshake"
+)
+
+// StartConnection starts the span of a connection, accepted at start, or now
+// if start is zero. Its stages are traced in the returned context.
+func StartConnection(ctx context.Context, start time.Time, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	options := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrs...),
+	}
+	if !start.IsZero() {
+		options = append(options, trace.WithTimestamp(start))
+	}
+	return otel.Tracer(instrumentationName).Start(ctx, Connection, options...)
+}
+
+// Start starts the span of a stage of the connection of ctx, if it is traced.
+// Otherwise it returns ctx, and a span that does nothing.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return StartAt(ctx, name, time.Time{}, attrs...)
+}
+
+// StartAt is Start for a stage that started at start, or now if start is zero.
+func StartAt(ctx context.Context, name string, start time.Time, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, noop.Span{}
+	}
+	options := []trace.SpanStartOption{
+		trace.WithAttributes(attrs...),
+	}
+	if !start.IsZero() {
+		options = append(options, trace.WithTimestamp(start))
+	}
+	return otel.Tracer(instrumentationName).Start(ctx, name, options...)
+}
+
+// End ends span, recording err if it is not nil.
+func End(span trace.Span, err error) {
+	if err != nil && span.IsRecording() {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
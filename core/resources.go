@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// Resources holds what several instances in one process may share, such as
+// the matchers built from geodata and the caches of name servers. Instances
+// created with NewWithContext on a context from ContextWithResources share
+// its Resources, so that each of them is loaded once for all.
+//
+// xray:api:beta
+type Resources struct {
+	access  sync.Mutex
+	entries map[string]*resource
+}
+
+type resource struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+// NewResources returns an empty Resources.
+func NewResources() *Resources {
+	return &Resources{
+		entries: make(map[string]*resource),
+	}
+}
+
+// Load returns the resource of key, calling build to load it if it is not
+// loaded yet. A resource that failed to load is loaded again next time.
+func (r *Resources) Load(key string, build func() (interface{}, error)) (interface{}, error) {
+	r.access.Lock()
+	e, found := r.entries[key]
+	if !found {
+		e = &resource{}
+		r.entries[key] = e
+	}
+	r.access.Unlock()
+
+	e.once.Do(func() {
+		e.value, e.err = build()
+	})
+	if e.err != nil {
+		r.access.Lock()
+		if r.entries[key] == e {
+			delete(r.entries, key)
+		}
+		r.access.Unlock()
+	}
+	return e.value, e.err
+}
+
+const resourcesKey XrayKey = 2
+
+// ContextWithResources returns a context carrying r, for instances created
+// with it to share resources.
+//
+// xray:api:beta
+func ContextWithResources(ctx context.Context, r *Resources) context.Context {
+	return context.WithValue(ctx, resourcesKey, r)
+}
+
+// ResourcesFromContext returns the Resources in ctx, or nil if there is none.
+func ResourcesFromContext(ctx context.Context) *Resources {
+	if r, ok := ctx.Value(resourcesKey).(*Resources); ok {
+		return r
+	}
+	return nil
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"reflect"
 	"sync"
+	"sync/atomic"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/events"
 	"github.com/xtls/xray-core/common/platform"
 	"github.com/xtls/xray-core/common/serial"
 	"github.com/xtls/xray-core/features"
@@ -78,21 +80,47 @@ func (r *resolution) callbackResolution(allFeatures []features.Feature) error {
 	return err
 }
 
+const (
+	stateNew int32 = iota
+	stateRunning
+	stateClosed
+)
+
 // Instance combines all Xray features.
 type Instance struct {
 	statusLock                 sync.Mutex
 	features                   []features.Feature
 	pendingResolutions         []resolution
 	pendingOptionalResolutions []resolution
-	running                    bool
+	state                      atomic.Int32
 	resolveLock                sync.Mutex
 
-	ctx context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+	// stopAfter stops closing the instance once its context is done.
+	stopAfter func() bool
+	ready     chan struct{}
+	done      chan struct{}
 }
 
 // Instance state
 func (server *Instance) IsRunning() bool {
-	return server.running
+	return server.state.Load() == stateRunning
+}
+
+// Ready returns a channel closed once the instance is started and all its
+// features implementing features.Readiness are ready.
+//
+// xray:api:beta
+func (s *Instance) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Done returns a channel closed once the instance is closed.
+//
+// xray:api:beta
+func (s *Instance) Done() <-chan struct{} {
+	return s.done
 }
 
 func AddInboundHandler(server *Instance, config *InboundHandlerConfig) error {
@@ -165,24 +193,30 @@ func OptionalFeatures(ctx context.Context, callback interface{}) error {
 // The instance is not started at this point.
 // To ensure Xray instance works properly, the config must contain one Dispatcher, one InboundHandlerManager and one OutboundHandlerManager. Other features are optional.
 func New(config *Config) (*Instance, error) {
-	server := &Instance{ctx: context.Background()}
-
-	done, err := initInstanceWithConfig(config, server)
-	if done {
-		return nil, err
-	}
-
-	return server, nil
+	return NewWithContext(context.Background(), config)
 }
 
+// NewWithContext is New, with the instance closed once ctx is done. Features
+// are created on a context derived from ctx, which is canceled once the
+// instance is closed.
 func NewWithContext(ctx context.Context, config *Config) (*Instance, error) {
-	server := &Instance{ctx: ctx}
+	ctx, cancel := context.WithCancel(ctx)
+	server := &Instance{
+		ctx:    ctx,
+		cancel: cancel,
+		ready:  make(chan struct{}),
+		done:   make(chan struct{}),
+	}
 
 	done, err := initInstanceWithConfig(config, server)
 	if done {
+		cancel()
 		return nil, err
 	}
 
+	server.stopAfter = context.AfterFunc(ctx, func() {
+		server.Close()
+	})
 	return server, nil
 }
 
@@ -254,12 +288,34 @@ func (s *Instance) Type() interface{} {
 	return ServerType()
 }
 
-// Close shutdown the Xray instance.
+// Close shutdown the Xray instance. It may be called more than once, and
+// concurrently with Start, closing the instance once.
+//
+// xray:api:stable
 func (s *Instance) Close() error {
 	s.statusLock.Lock()
 	defer s.statusLock.Unlock()
 
-	s.running = false
+	if s.state.Load() == stateClosed {
+		return nil
+	}
+	return s.close()
+}
+
+// close closes the instance, with statusLock held.
+func (s *Instance) close() error {
+	// Emitted before the features close, for the sinks of events to send it.
+	if s.state.Load() == stateRunning {
+		events.Emit(events.CoreStopped, "version", Version())
+	}
+	s.state.Store(stateClosed)
+	if s.stopAfter != nil {
+		s.stopAfter()
+	}
+	defer func() {
+		s.cancel()
+		close(s.done)
+	}()
 
 	var errs []interface{}
 	for _, f := range s.features {
@@ -317,7 +373,7 @@ func (s *Instance) RequireFeatures(callback interface{}, optional bool) error {
 
 // AddFeature registers a feature into current Instance.
 func (s *Instance) AddFeature(feature features.Feature) error {
-	if s.running {
+	if s.state.Load() == stateRunning {
 		if err := feature.Start(); err != nil {
 			errors.LogInfoInner(s.ctx, err, "failed to start feature")
 		}
@@ -377,22 +433,93 @@ func (s *Instance) GetFeature(featureType interface{}) features.Feature {
 	return getFeature(s.features, reflect.TypeOf(featureType))
 }
 
-// Start starts the Xray instance, including all registered features. When Start returns error, the state of the instance is unknown.
-// A Xray instance can be started only once. Upon closing, the instance is not guaranteed to start again.
+// stateKey returns the key of the state of f, by its type.
+func stateKey(f features.Feature) string {
+	return reflect.TypeOf(f.Type()).String()
+}
+
+// ExportStates returns the states of the features implementing
+// features.Stateful, keyed by their types, for ImportStates of the instance
+// upgrading this one.
+//
+// xray:api:beta
+func (s *Instance) ExportStates() (map[string][]byte, error) {
+	states := make(map[string][]byte)
+	for _, f := range s.features {
+		if sf, ok := f.(features.Stateful); ok {
+			state, err := sf.ExportState()
+			if err != nil {
+				return nil, errors.New("failed to export the state of ", stateKey(f)).Base(err)
+			}
+			states[stateKey(f)] = state
+		}
+	}
+	return states, nil
+}
+
+// ImportStates restores the states of ExportStates to the features of the
+// same types, before the instance starts. The states of the features which
+// the instance does not have are ignored.
+//
+// xray:api:beta
+func (s *Instance) ImportStates(states map[string][]byte) error {
+	for _, f := range s.features {
+		sf, ok := f.(features.Stateful)
+		if !ok {
+			continue
+		}
+		if state, found := states[stateKey(f)]; found {
+			if err := sf.ImportState(state); err != nil {
+				return errors.New("failed to import the state of ", stateKey(f)).Base(err)
+			}
+		}
+	}
+	return nil
+}
+
+// Start starts the Xray instance, including all registered features. When Start returns error, the instance is closed.
+// A Xray instance can be started only once. Starting it again is a no-op while it runs, and an error once closed.
 //
 // xray:api:stable
 func (s *Instance) Start() error {
 	s.statusLock.Lock()
 	defer s.statusLock.Unlock()
 
-	s.running = true
+	switch s.state.Load() {
+	case stateRunning:
+		return nil
+	case stateClosed:
+		return errors.New("Xray instance is closed")
+	}
+
+	s.state.Store(stateRunning)
 	for _, f := range s.features {
 		if err := f.Start(); err != nil {
+			s.close()
 			return err
 		}
 	}
 
 	errors.LogWarning(s.ctx, "Xray ", Version(), " started")
+	events.Emit(events.CoreStarted, "version", Version())
 
+	go s.waitReady()
 	return nil
 }
+
+// waitReady closes ready once all features implementing features.Readiness
+// are ready, unless the instance is closed before.
+func (s *Instance) waitReady() {
+	for _, f := range s.features {
+		r, ok := f.(features.Readiness)
+		if !ok {
+			continue
+		}
+		select {
+		case <-r.Ready():
+		case <-s.ctx.Done():
+			return
+		}
+	}
+	close(s.ready)
+}
@@ -24,6 +24,18 @@ type Client interface {
 	LookupIP(domain string, option IPOption) ([]net.IP, uint32, error)
 }
 
+// CNAMEClient is a Client telling the CNAME chains of the domains it
+// resolves.
+//
+// xray:api:beta
+type CNAMEClient interface {
+	Client
+
+	// LookupIPWithCNAME returns IP address for the given domain as LookupIP
+	// does, and the names its CNAME records lead to, in order.
+	LookupIPWithCNAME(domain string, option IPOption) ([]net.IP, []string, uint32, error)
+}
+
 // ClientType returns the type of Client interface. Can be used for implementing common.HasType.
 //
 // xray:api:beta
@@ -0,0 +1,23 @@
+package extension
+
+import (
+	"github.com/xtls/xray-core/features"
+)
+
+// Cluster shares the states of users among the servers of a cluster, for
+// their restrictions to hold across all of them. Users are named as in the
+// stats, "user>>>[email]" in the namespace of their tenant if any.
+type Cluster interface {
+	features.Feature
+
+	// OnlineIPs returns the IPs user is online from at the other servers.
+	OnlineIPs(user string) []string
+	// Traffic returns the bytes user has used at all servers, as of the last
+	// sync.
+	Traffic(user string) int64
+}
+
+// ClusterType returns the type of Cluster interface.
+func ClusterType() interface{} {
+	return (*Cluster)(nil)
+}
@@ -0,0 +1,29 @@
+package extension
+
+import (
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/features"
+)
+
+// Guard bans the sources that fail to authenticate too often.
+type Guard interface {
+	features.Feature
+
+	// Failed records a failed authentication from ip.
+	Failed(ip net.IP)
+	// Banned returns whether ip is banned.
+	Banned(ip net.IP) bool
+	// Ban bans ip until a time, as it is banned by another server, or lifts
+	// its ban if the time has passed. No events are emitted for it.
+	Ban(ip net.IP, until time.Time)
+	// TrustsForwarded returns whether the sources forwarded in the headers of
+	// requests, such as X-Forwarded-For, are banned instead of the peers.
+	TrustsForwarded() bool
+}
+
+// GuardType returns the type of Guard interface.
+func GuardType() interface{} {
+	return (*Guard)(nil)
+}
@@ -10,3 +10,20 @@ type Feature interface {
 	common.HasType
 	common.Runnable
 }
+
+// Readiness is implemented by the features that are ready some time after
+// they start, such as once they complete their first round of work.
+type Readiness interface {
+	// Ready returns a channel closed once the feature is ready.
+	Ready() <-chan struct{}
+}
+
+// Stateful is implemented by the features whose state is handed over to the
+// process upgrading this one, such as the mappings of fake DNS.
+type Stateful interface {
+	// ExportState returns the state of the feature.
+	ExportState() ([]byte, error)
+	// ImportState restores the state exported by the same type of feature,
+	// before the feature starts.
+	ImportState(state []byte) error
+}
@@ -3,9 +3,12 @@ package policy
 import (
 	"context"
 	"runtime"
+	"sync/atomic"
 	"time"
 
+	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/platform"
+	"github.com/xtls/xray-core/common/qos"
 	"github.com/xtls/xray-core/features"
 )
 
@@ -19,6 +22,42 @@ type Timeout struct {
 	UplinkOnly time.Duration
 	// Timeout for an downlink only connection, i.e., the uplink of the connection has been closed.
 	DownlinkOnly time.Duration
+	// Timeouts for connection being idle over TCP, UDP and sniffed QUIC and
+	// STUN. The handlers enforce ConnectionIdle, the longest of them, and the
+	// dispatcher the shorter ones. The UDP mappings of sniffed STUN, as of
+	// WebRTC, are also kept for ConnectionIdleSTUN by the inbounds.
+	ConnectionIdleTCP  time.Duration
+	ConnectionIdleUDP  time.Duration
+	ConnectionIdleQUIC time.Duration
+	ConnectionIdleSTUN time.Duration
+	// ConnectionIdleProtocols are the timeouts for connections of sniffed
+	// protocols being idle, by the names of the protocols, in place of the
+	// ones above. "http" is of "http1" and "http2", unless they have their
+	// own.
+	ConnectionIdleProtocols map[string]time.Duration
+}
+
+// ConnectionIdleFor returns the timeout for a connection over network, of
+// the sniffed protocol if not empty, being idle.
+func (t *Timeout) ConnectionIdleFor(network net.Network, protocol string) time.Duration {
+	if timeout, found := t.ConnectionIdleProtocols[protocol]; found {
+		return timeout
+	}
+	if protocol == "http1" || protocol == "http2" {
+		if timeout, found := t.ConnectionIdleProtocols["http"]; found {
+			return timeout
+		}
+	}
+	if network != net.Network_UDP {
+		return t.ConnectionIdleTCP
+	}
+	switch protocol {
+	case "quic":
+		return t.ConnectionIdleQUIC
+	case "stun":
+		return t.ConnectionIdleSTUN
+	}
+	return t.ConnectionIdleUDP
 }
 
 // Stats contains settings for stats counters.
@@ -35,6 +74,32 @@ type Stats struct {
 type Buffer struct {
 	// Size of buffer per connection, in bytes. -1 for unlimited buffer.
 	PerConnection int32
+	// If MaxPerConnection is positive, the size of buffer per connection is
+	// tuned between MinPerConnection and it, from the traffic of the
+	// connection, in place of PerConnection.
+	MinPerConnection int32
+	MaxPerConnection int32
+}
+
+// Restriction contains settings for traffic that is not allowed.
+type Restriction struct {
+	// Whether or not to block BitTorrent traffic. Connections are sniffed for it
+	// even when sniffing of the inbound is disabled.
+	BlockBittorrent bool
+	// MaxIPs is the most IPs a user is online from at once, 0 for no limit.
+	// The IPs are the ones of its connections in the last 20 seconds, at this
+	// server and the others of the cluster.
+	MaxIPs uint32
+	// Quota is the bytes a user may use before its new connections are
+	// rejected, 0 for no limit. They are counted by the user traffic stats,
+	// at all servers of the cluster.
+	Quota int64
+}
+
+// Tracing contains settings for tracing connections.
+type Tracing struct {
+	// Whether or not to trace the stages of connections as OpenTelemetry spans.
+	Enabled bool
 }
 
 // SystemStats contains stat policy settings on system level.
@@ -47,19 +112,136 @@ type SystemStats struct {
 	OutboundUplink bool
 	// Whether or not to enable stat counter for downlink traffic in outbound handlers.
 	OutboundDownlink bool
+	// Whether or not to enable stat counter for connections that hit tagged routing rules.
+	RuleMatched bool
+	// Whether or not to enable stat counter for uplink traffic of tagged routing rules.
+	RuleUplink bool
+	// Whether or not to enable stat counter for downlink traffic of tagged routing rules.
+	RuleDownlink bool
+	// Whether or not to enable stat counter for UDP flows and their traffic, to each destination.
+	UDPFlows bool
+	// Whether or not to enable stat counter for connections of outbound handlers by how they terminate.
+	OutboundTermination bool
+}
+
+// QoSClass contains the traffic classes a connection is shaped in, for each
+// direction. A nil class is not shaped.
+type QoSClass struct {
+	Uplink   *qos.Class
+	Downlink *qos.Class
+}
+
+// QoS contains the traffic classes of connections, by the tag of the routing
+// rule they hit or by the level of their user.
+type QoS struct {
+	RuleTags map[string]QoSClass
+	Levels   map[uint32]QoSClass
+	// Default is the class of the other connections.
+	Default QoSClass
+}
+
+// ForConnection returns the class of a connection. The class of ruleTag takes
+// precedence over the class of level.
+func (q *QoS) ForConnection(level uint32, ruleTag string) QoSClass {
+	if c, ok := q.RuleTags[ruleTag]; ok && ruleTag != "" {
+		return c
+	}
+	if c, ok := q.Levels[level]; ok {
+		return c
+	}
+	return q.Default
+}
+
+// Tenant is a group of inbounds and users apart from the others. Its stats are
+// named under "tenant>>>[name]>>>", its routing rules and hosts take
+// precedence, and its connections are limited in aggregate.
+type Tenant struct {
+	Name string
+	// Class shapes the traffic of all connections of the tenant.
+	Class QoSClass
+	// MaxConnections is the most connections of the tenant at once, 0 for no
+	// limit.
+	MaxConnections int32
+	// Hosts are the addresses of domains for the connections of the tenant, in
+	// place of resolving them.
+	Hosts map[string]net.Address
+
+	connections atomic.Int32
+}
+
+// StatName returns name in the stats namespace of t, or name itself if t is
+// nil.
+func (t *Tenant) StatName(name string) string {
+	if t == nil {
+		return name
+	}
+	return "tenant>>>" + t.Name + ">>>" + name
+}
+
+// Acquire counts a connection of t, unless t has MaxConnections already.
+func (t *Tenant) Acquire() bool {
+	for {
+		n := t.connections.Load()
+		if t.MaxConnections > 0 && n >= t.MaxConnections {
+			return false
+		}
+		if t.connections.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// Release uncounts a connection acquired of t.
+func (t *Tenant) Release() {
+	t.connections.Add(-1)
+}
+
+// Connections returns the number of connections of t.
+func (t *Tenant) Connections() int32 {
+	return t.connections.Load()
+}
+
+// Tenants contains the tenants of inbounds and users.
+type Tenants struct {
+	Inbounds map[string]*Tenant
+	Users    map[string]*Tenant
+}
+
+// ForConnection returns the tenant of a connection of an inbound and a user,
+// or nil if there is none. The tenant of the user takes precedence.
+func (t *Tenants) ForConnection(inboundTag string, email string) *Tenant {
+	if tenant, ok := t.Users[email]; ok && email != "" {
+		return tenant
+	}
+	return t.ForInbound(inboundTag)
+}
+
+// ForInbound returns the tenant of an inbound, or nil if there is none.
+func (t *Tenants) ForInbound(tag string) *Tenant {
+	if tag == "" {
+		return nil
+	}
+	return t.Inbounds[tag]
 }
 
 // System contains policy settings at system level.
 type System struct {
-	Stats  SystemStats
-	Buffer Buffer
+	Stats   SystemStats
+	Buffer  Buffer
+	QoS     QoS
+	Tenants Tenants
+	// DrainTimeout is how long to wait on shutdown for the connections to
+	// finish, once the inbounds stop accepting new ones.
+	DrainTimeout time.Duration
 }
 
 // Session is session based settings for controlling Xray requests. It contains various settings (or limits) that may differ for different users in the context.
 type Session struct {
-	Timeouts Timeout // Timeout settings
-	Stats    Stats
-	Buffer   Buffer
+	Timeouts    Timeout // Timeout settings
+	Stats       Stats
+	Buffer      Buffer
+	Restriction Restriction
+	Tracing     Tracing
 }
 
 // Manager is a feature that provides Policy for the given user by its id or level.
@@ -117,10 +299,14 @@ func SessionDefault() Session {
 		Timeouts: Timeout{
 			// Align Handshake timeout with nginx client_header_timeout
 			// So that this value will not indicate server identity
-			Handshake:      time.Second * 60,
-			ConnectionIdle: time.Second * 300,
-			UplinkOnly:     time.Second * 1,
-			DownlinkOnly:   time.Second * 1,
+			Handshake:          time.Second * 60,
+			ConnectionIdle:     time.Second * 300,
+			UplinkOnly:         time.Second * 1,
+			DownlinkOnly:       time.Second * 1,
+			ConnectionIdleTCP:  time.Second * 300,
+			ConnectionIdleUDP:  time.Second * 300,
+			ConnectionIdleQUIC: time.Second * 300,
+			ConnectionIdleSTUN: time.Second * 300,
 		},
 		Stats: Stats{
 			UserUplink:   false,
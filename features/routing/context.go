@@ -26,6 +26,9 @@ type Context interface {
 	// GetTargetDomain returns the target domain of the connection, if exists.
 	GetTargetDomain() string
 
+	// GetTargetCNAMEs returns the names the CNAME records of the target domain lead to, if resolved.
+	GetTargetCNAMEs() []string
+
 	// GetNetwork returns the network type of the connection.
 	GetNetwork() net.Network
 
@@ -35,6 +38,18 @@ type Context interface {
 	// GetUser returns the user email from the connection content, if exists.
 	GetUser() string
 
+	// GetTLSClient returns the identity of the client certificate of a TLS inbound, if exists.
+	GetTLSClient() string
+
+	// GetTLSServerName returns the server name the client sent to a TLS or REALITY inbound, if exists.
+	GetTLSServerName() string
+
+	// GetTLSALPN returns the protocol negotiated by ALPN with a TLS or REALITY inbound, if exists.
+	GetTLSALPN() string
+
+	// GetTenant returns the name of the tenant of the connection, if exists.
+	GetTenant() string
+
 	// GetAttributes returns extra attributes from the conneciont content.
 	GetAttributes() map[string]string
 
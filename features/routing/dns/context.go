@@ -5,36 +5,29 @@ import (
 
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/tracing"
 	"github.com/xtls/xray-core/features/dns"
 	"github.com/xtls/xray-core/features/routing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // ResolvableContext is an implementation of routing.Context, with domain resolving capability.
 type ResolvableContext struct {
 	routing.Context
-	dnsClient   dns.Client
-	resolvedIPs []net.IP
+	dnsClient      dns.Client
+	resolved       bool
+	resolvedIPs    []net.IP
+	resolvedCNAMEs []string
 }
 
 // GetTargetIPs overrides original routing.Context's implementation.
 func (ctx *ResolvableContext) GetTargetIPs() []net.IP {
+	ctx.resolve()
 	if len(ctx.resolvedIPs) > 0 {
 		return ctx.resolvedIPs
 	}
 
-	if domain := ctx.GetTargetDomain(); len(domain) != 0 {
-		ips, _, err := ctx.dnsClient.LookupIP(domain, dns.IPOption{
-			IPv4Enable: true,
-			IPv6Enable: true,
-			FakeEnable: false,
-		})
-		if err == nil {
-			ctx.resolvedIPs = ips
-			return ips
-		}
-		errors.LogInfoInner(context.Background(), err, "resolve ip for ", domain)
-	}
-
 	if ips := ctx.Context.GetTargetIPs(); len(ips) != 0 {
 		return ips
 	}
@@ -42,6 +35,54 @@ func (ctx *ResolvableContext) GetTargetIPs() []net.IP {
 	return nil
 }
 
+// GetTargetCNAMEs overrides original routing.Context's implementation.
+func (ctx *ResolvableContext) GetTargetCNAMEs() []string {
+	ctx.resolve()
+	if len(ctx.resolvedCNAMEs) > 0 {
+		return ctx.resolvedCNAMEs
+	}
+	return ctx.Context.GetTargetCNAMEs()
+}
+
+// resolve resolves the target domain once, and records its CNAME chain in
+// the session.
+func (ctx *ResolvableContext) resolve() {
+	domain := ctx.GetTargetDomain()
+	if ctx.resolved || len(domain) == 0 {
+		return
+	}
+	ctx.resolved = true
+
+	sessionCtx := context.Background()
+	if c, ok := ctx.Context.(interface{ SessionContext() context.Context }); ok {
+		sessionCtx = c.SessionContext()
+	}
+	_, span := tracing.Start(sessionCtx, tracing.Resolve, attribute.String("domain", domain))
+	option := dns.IPOption{
+		IPv4Enable: true,
+		IPv6Enable: true,
+		FakeEnable: false,
+	}
+	var ips []net.IP
+	var cnames []string
+	var err error
+	if client, ok := ctx.dnsClient.(dns.CNAMEClient); ok {
+		ips, cnames, _, err = client.LookupIPWithCNAME(domain, option)
+	} else {
+		ips, _, err = ctx.dnsClient.LookupIP(domain, option)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		errors.LogInfoInner(context.Background(), err, "resolve ip for ", domain)
+		return
+	}
+	ctx.resolvedIPs = ips
+	ctx.resolvedCNAMEs = cnames
+	if outbounds := session.OutboundsFromContext(sessionCtx); len(cnames) > 0 && len(outbounds) > 0 {
+		outbounds[len(outbounds)-1].TargetCNAMEs = cnames
+	}
+}
+
 // ContextWithDNSClient creates a new routing context with domain resolving capability.
 // Resolved domain IPs can be retrieved by GetTargetIPs().
 func ContextWithDNSClient(ctx routing.Context, client dns.Client) routing.Context {
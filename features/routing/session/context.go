@@ -13,6 +13,17 @@ type Context struct {
 	Inbound  *session.Inbound
 	Outbound *session.Outbound
 	Content  *session.Content
+
+	ctx context.Context
+}
+
+// SessionContext returns the context of the session, in which the stages of
+// routing, such as resolving the target, are traced.
+func (ctx *Context) SessionContext() context.Context {
+	if ctx.ctx == nil {
+		return context.Background()
+	}
+	return ctx.ctx
 }
 
 // GetInboundTag implements routing.Context.
@@ -82,6 +93,14 @@ func (ctx *Context) GetTargetDomain() string {
 	return dest.Address.Domain()
 }
 
+// GetTargetCNAMEs implements routing.Context.
+func (ctx *Context) GetTargetCNAMEs() []string {
+	if ctx.Outbound == nil {
+		return nil
+	}
+	return ctx.Outbound.TargetCNAMEs
+}
+
 // GetNetwork implements routing.Context.
 func (ctx *Context) GetNetwork() net.Network {
 	if ctx.Outbound == nil {
@@ -106,6 +125,38 @@ func (ctx *Context) GetUser() string {
 	return ctx.Inbound.User.Email
 }
 
+// GetTLSClient implements routing.Context.
+func (ctx *Context) GetTLSClient() string {
+	if ctx.Inbound == nil {
+		return ""
+	}
+	return ctx.Inbound.TLSClient
+}
+
+// GetTLSServerName implements routing.Context.
+func (ctx *Context) GetTLSServerName() string {
+	if ctx.Inbound == nil {
+		return ""
+	}
+	return ctx.Inbound.TLSServerName
+}
+
+// GetTLSALPN implements routing.Context.
+func (ctx *Context) GetTLSALPN() string {
+	if ctx.Inbound == nil {
+		return ""
+	}
+	return ctx.Inbound.TLSALPN
+}
+
+// GetTenant implements routing.Context.
+func (ctx *Context) GetTenant() string {
+	if ctx.Inbound == nil {
+		return ""
+	}
+	return ctx.Inbound.Tenant
+}
+
 // GetAttributes implements routing.Context.
 func (ctx *Context) GetAttributes() map[string]string {
 	if ctx.Content == nil {
@@ -130,5 +181,6 @@ func AsRoutingContext(ctx context.Context) routing.Context {
 		Inbound:  session.InboundFromContext(ctx),
 		Outbound: ob,
 		Content:  session.ContentFromContext(ctx),
+		ctx:      ctx,
 	}
 }
@@ -4,6 +4,8 @@ import (
 	"strings"
 
 	"github.com/xtls/xray-core/app/commander"
+	eventservice "github.com/xtls/xray-core/app/events/command"
+	guardservice "github.com/xtls/xray-core/app/guard/command"
 	loggerservice "github.com/xtls/xray-core/app/log/command"
 	observatoryservice "github.com/xtls/xray-core/app/observatory/command"
 	handlerservice "github.com/xtls/xray-core/app/proxyman/command"
@@ -14,9 +16,74 @@ import (
 )
 
 type APIConfig struct {
-	Tag      string   `json:"tag"`
-	Listen   string   `json:"listen"`
-	Services []string `json:"services"`
+	Tag      string         `json:"tag"`
+	Listen   string         `json:"listen"`
+	Services []string       `json:"services"`
+	HTTP     *APIHTTPConfig `json:"http"`
+
+	TLS         *APITLSConfig          `json:"tls"`
+	Credentials []*APICredentialConfig `json:"credentials"`
+}
+
+// APITLSConfig is the TLS of the gRPC API server.
+type APITLSConfig struct {
+	CertificateFile string `json:"certificateFile"`
+	KeyFile         string `json:"keyFile"`
+	ClientCAFile    string `json:"clientCaFile"`
+}
+
+func (c *APITLSConfig) Build() (*commander.TLSConfig, error) {
+	if c.CertificateFile == "" || c.KeyFile == "" {
+		return nil, errors.New("API TLS needs both certificateFile and keyFile.")
+	}
+	return &commander.TLSConfig{
+		CertificateFile: c.CertificateFile,
+		KeyFile:         c.KeyFile,
+		ClientCaFile:    c.ClientCAFile,
+	}, nil
+}
+
+// APICredentialConfig is an identity that may call some API methods.
+type APICredentialConfig struct {
+	Name        string     `json:"name"`
+	Token       string     `json:"token"`
+	CommonName  string     `json:"commonName"`
+	Permissions StringList `json:"permissions"`
+}
+
+func (c *APICredentialConfig) Build() (*commander.Credential, error) {
+	if c.Token == "" && c.CommonName == "" {
+		return nil, errors.New("API credential ", c.Name, " needs a token or a commonName.")
+	}
+	return &commander.Credential{
+		Name:       c.Name,
+		Token:      c.Token,
+		CommonName: c.CommonName,
+		Permission: c.Permissions,
+	}, nil
+}
+
+// APIHTTPConfig is the HTTP JSON gateway to the API services.
+type APIHTTPConfig struct {
+	Listen          string `json:"listen"`
+	Token           string `json:"token"`
+	CertificateFile string `json:"certificateFile"`
+	KeyFile         string `json:"keyFile"`
+}
+
+func (c *APIHTTPConfig) Build() (*commander.HTTPConfig, error) {
+	if c.Listen == "" {
+		return nil, errors.New("API HTTP gateway needs an address to listen on.")
+	}
+	if (c.CertificateFile == "") != (c.KeyFile == "") {
+		return nil, errors.New("API HTTP gateway needs both certificateFile and keyFile for TLS.")
+	}
+	return &commander.HTTPConfig{
+		Listen:          c.Listen,
+		Token:           c.Token,
+		CertificateFile: c.CertificateFile,
+		KeyFile:         c.KeyFile,
+	}, nil
 }
 
 func (c *APIConfig) Build() (*commander.Config, error) {
@@ -39,12 +106,41 @@ func (c *APIConfig) Build() (*commander.Config, error) {
 			services = append(services, serial.ToTypedMessage(&observatoryservice.Config{}))
 		case "routingservice":
 			services = append(services, serial.ToTypedMessage(&routerservice.Config{}))
+		case "eventservice":
+			services = append(services, serial.ToTypedMessage(&eventservice.Config{}))
+		case "guardservice":
+			services = append(services, serial.ToTypedMessage(&guardservice.Config{}))
 		}
 	}
 
-	return &commander.Config{
+	config := &commander.Config{
 		Tag:     c.Tag,
 		Listen:  c.Listen,
 		Service: services,
-	}, nil
+	}
+	if c.TLS != nil {
+		tls, err := c.TLS.Build()
+		if err != nil {
+			return nil, err
+		}
+		config.Tls = tls
+	}
+	for _, credential := range c.Credentials {
+		if credential.CommonName != "" && (c.TLS == nil || c.TLS.ClientCAFile == "") {
+			return nil, errors.New("API credential ", credential.Name, " is identified by commonName, which needs tls.clientCaFile.")
+		}
+		cred, err := credential.Build()
+		if err != nil {
+			return nil, err
+		}
+		config.Credential = append(config.Credential, cred)
+	}
+	if c.HTTP != nil {
+		http, err := c.HTTP.Build()
+		if err != nil {
+			return nil, err
+		}
+		config.Http = http
+	}
+	return config, nil
 }
@@ -0,0 +1,39 @@
+package conf
+
+import (
+	"github.com/xtls/xray-core/app/cluster"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/infra/conf/cfgcommon/duration"
+	"google.golang.org/protobuf/proto"
+)
+
+type ClusterRedisConfig struct {
+	Address  string `json:"address"`
+	Password string `json:"password"`
+	DB       uint32 `json:"db"`
+}
+
+// ClusterConfig shares the states of users with the other servers of a
+// cluster.
+type ClusterConfig struct {
+	Node     string              `json:"node"`
+	Redis    *ClusterRedisConfig `json:"redis"`
+	Prefix   string              `json:"prefix"`
+	Interval duration.Duration   `json:"interval"`
+}
+
+func (c *ClusterConfig) Build() (proto.Message, error) {
+	if c.Redis == nil || c.Redis.Address == "" {
+		return nil, errors.New("cluster requires the address of Redis")
+	}
+	return &cluster.Config{
+		Node: c.Node,
+		Redis: &cluster.Redis{
+			Address:  c.Redis.Address,
+			Password: c.Redis.Password,
+			Db:       c.Redis.DB,
+		},
+		Prefix:   c.Prefix,
+		Interval: int64(c.Interval),
+	}, nil
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/xtls/xray-core/app/router"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet/tls"
 )
 
 type NameServerConfig struct {
@@ -29,6 +30,8 @@ type NameServerConfig struct {
 	DisableCache  bool       `json:"disableCache"`
 	FinalQuery    bool       `json:"finalQuery"`
 	UnexpectedIPs StringList `json:"unexpectedIPs"`
+	Fingerprint   string     `json:"fingerprint"`
+	OutboundTag   string     `json:"outboundTag"`
 }
 
 // UnmarshalJSON implements encoding/json.Unmarshaler.UnmarshalJSON
@@ -53,6 +56,8 @@ func (c *NameServerConfig) UnmarshalJSON(data []byte) error {
 		DisableCache  bool       `json:"disableCache"`
 		FinalQuery    bool       `json:"finalQuery"`
 		UnexpectedIPs StringList `json:"unexpectedIPs"`
+		Fingerprint   string     `json:"fingerprint"`
+		OutboundTag   string     `json:"outboundTag"`
 	}
 	if err := json.Unmarshal(data, &advanced); err == nil {
 		c.Address = advanced.Address
@@ -68,6 +73,8 @@ func (c *NameServerConfig) UnmarshalJSON(data []byte) error {
 		c.DisableCache = advanced.DisableCache
 		c.FinalQuery = advanced.FinalQuery
 		c.UnexpectedIPs = advanced.UnexpectedIPs
+		c.Fingerprint = advanced.Fingerprint
+		c.OutboundTag = advanced.OutboundTag
 		return nil
 	}
 
@@ -157,6 +164,11 @@ func (c *NameServerConfig) Build() (*dns.NameServer, error) {
 		myClientIP = []byte(c.ClientIP.IP())
 	}
 
+	fingerprint, err := parseDNSFingerprint(c.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
 	return &dns.NameServer{
 		Address: &net.Endpoint{
 			Network: net.Network_UDP,
@@ -176,9 +188,19 @@ func (c *NameServerConfig) Build() (*dns.NameServer, error) {
 		FinalQuery:        c.FinalQuery,
 		UnexpectedGeoip:   unexpectedGeoipList,
 		ActUnprior:        actUnprior,
+		Fingerprint:       fingerprint,
+		OutboundTag:       c.OutboundTag,
 	}, nil
 }
 
+func parseDNSFingerprint(fingerprint string) (string, error) {
+	fingerprint = strings.ToLower(fingerprint)
+	if fingerprint != "" && fingerprint != "unsafe" && tls.GetFingerprint(fingerprint) == nil {
+		return "", errors.New(`unknown "fingerprint": `, fingerprint)
+	}
+	return fingerprint, nil
+}
+
 var typeMap = map[router.Domain_Type]dns.DomainMatchingType{
 	router.Domain_Full:   dns.DomainMatchingType_Full,
 	router.Domain_Domain: dns.DomainMatchingType_Subdomain,
@@ -197,6 +219,7 @@ type DNSConfig struct {
 	DisableFallback        bool                `json:"disableFallback"`
 	DisableFallbackIfMatch bool                `json:"disableFallbackIfMatch"`
 	UseSystemHosts         bool                `json:"useSystemHosts"`
+	Fingerprint            string              `json:"fingerprint"`
 }
 
 type HostAddress struct {
@@ -403,11 +426,18 @@ func (c *DNSConfig) Build() (*dns.Config, error) {
 		config.ClientIp = []byte(c.ClientIP.IP())
 	}
 
+	fingerprint, err := parseDNSFingerprint(c.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
 	for _, server := range c.Servers {
 		ns, err := server.Build()
 		if err != nil {
 			return nil, errors.New("failed to build nameserver").Base(err)
 		}
+		if ns.Fingerprint == "" {
+			ns.Fingerprint = fingerprint
+		}
 		config.NameServer = append(config.NameServer, ns)
 	}
 
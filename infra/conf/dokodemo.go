@@ -1,6 +1,9 @@
 package conf
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/proxy/dokodemo"
@@ -22,14 +25,63 @@ func (v *DokodemoConfig) Build() (proto.Message, error) {
 		config.Address = v.Address.Build()
 	}
 	config.Port = uint32(v.Port)
-	config.PortMap = v.PortMap
-	for _, v := range config.PortMap {
-		if _, _, err := net.SplitHostPort(v); err != nil {
-			return nil, errors.New("invalid portMap: ", v).Base(err)
-		}
+	portMap, err := buildDokodemoPortMap(v.PortMap)
+	if err != nil {
+		return nil, err
 	}
+	config.PortMap = portMap
 	config.Networks = v.Network.Build()
 	config.FollowRedirect = v.FollowRedirect
 	config.UserLevel = v.UserLevel
 	return config, nil
 }
+
+// buildDokodemoPortMap expands port ranges of portMap, so that
+// "10000-10100": "host:20000-20100" maps every port to the one at the same
+// offset. A single target port or no port at all applies to the whole range.
+func buildDokodemoPortMap(portMap map[string]string) (map[string]string, error) {
+	if portMap == nil {
+		return nil, nil
+	}
+	config := make(map[string]string, len(portMap))
+	for key, value := range portMap {
+		host, port, err := net.SplitHostPort(value)
+		if err != nil {
+			return nil, errors.New("invalid portMap: ", value).Base(err)
+		}
+		if !strings.Contains(key, "-") && !strings.Contains(port, "-") {
+			if _, found := config[key]; found {
+				return nil, errors.New("portMap: port ", key, " is mapped more than once")
+			}
+			config[key] = value
+			continue
+		}
+		from, to, err := parseStringPort(key)
+		if err != nil || from > to {
+			return nil, errors.New("invalid portMap port range: ", key).Base(err)
+		}
+		var targetFrom, targetTo net.Port
+		if port != "" {
+			if targetFrom, targetTo, err = parseStringPort(port); err != nil || targetFrom > targetTo {
+				return nil, errors.New("invalid portMap target port range: ", port).Base(err)
+			}
+			if targetFrom != targetTo && targetTo-targetFrom != to-from {
+				return nil, errors.New("portMap: ", key, " and ", port, " are ranges of different sizes")
+			}
+		}
+		for p := uint32(from); p <= uint32(to); p++ {
+			target := ""
+			if targetFrom != targetTo {
+				target = strconv.Itoa(int(uint32(targetFrom) + p - uint32(from)))
+			} else if targetFrom != 0 {
+				target = targetFrom.String()
+			}
+			source := strconv.Itoa(int(p))
+			if _, found := config[source]; found {
+				return nil, errors.New("portMap: port ", source, " is mapped more than once")
+			}
+			config[source] = net.JoinHostPort(host, target)
+		}
+	}
+	return config, nil
+}
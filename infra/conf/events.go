@@ -0,0 +1,46 @@
+package conf
+
+import (
+	"github.com/xtls/xray-core/app/events"
+	"github.com/xtls/xray-core/common/errors"
+)
+
+type EventWebhookConfig struct {
+	URL     string            `json:"url"`
+	Types   StringList        `json:"types"`
+	Headers map[string]string `json:"headers"`
+}
+
+type EventExecConfig struct {
+	Command StringList `json:"command"`
+	Types   StringList `json:"types"`
+}
+
+type EventsConfig struct {
+	Webhooks []*EventWebhookConfig `json:"webhooks"`
+	Exec     []*EventExecConfig    `json:"exec"`
+}
+
+func (c *EventsConfig) Build() (*events.Config, error) {
+	config := &events.Config{}
+	for _, w := range c.Webhooks {
+		if w.URL == "" {
+			return nil, errors.New("Event webhook must have a url.")
+		}
+		config.Webhook = append(config.Webhook, &events.Webhook{
+			Url:    w.URL,
+			Type:   w.Types,
+			Header: w.Headers,
+		})
+	}
+	for _, e := range c.Exec {
+		if len(e.Command) == 0 {
+			return nil, errors.New("Event exec hook must have a command.")
+		}
+		config.Exec = append(config.Exec, &events.Exec{
+			Command: e.Command,
+			Type:    e.Types,
+		})
+	}
+	return config, nil
+}
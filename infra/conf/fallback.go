@@ -0,0 +1,123 @@
+package conf
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/proxy/fallback"
+)
+
+// FallbackConfig is the configuration of a fallback of VLESS or Trojan. Dest
+// is a port, an address, a path of unix socket, an upstream URL to proxy to,
+// an object of type and dest, or a list of them, tried in order.
+type FallbackConfig struct {
+	Name string          `json:"name"`
+	Alpn string          `json:"alpn"`
+	Path string          `json:"path"`
+	Type string          `json:"type"`
+	Dest json.RawMessage `json:"dest"`
+	Xver uint64          `json:"xver"`
+}
+
+type FallbackDestConfig struct {
+	Type string          `json:"type"`
+	Dest json.RawMessage `json:"dest"`
+}
+
+type FallbackCheckConfig struct {
+	Interval uint32 `json:"interval"`
+	Timeout  uint32 `json:"timeout"`
+}
+
+func (c *FallbackCheckConfig) Build() *fallback.HealthCheck {
+	return &fallback.HealthCheck{
+		Interval: c.Interval,
+		Timeout:  c.Timeout,
+	}
+}
+
+// parseFallbackDest parses a port or a string.
+func parseFallbackDest(raw json.RawMessage) string {
+	var i uint16
+	var s string
+	if err := json.Unmarshal(raw, &i); err == nil {
+		s = strconv.Itoa(int(i))
+	} else {
+		_ = json.Unmarshal(raw, &s)
+	}
+	return s
+}
+
+// Build builds the fallback, of the inbounds of protocol.
+func (c *FallbackConfig) Build(protocol string) (*fallback.Fallback, error) {
+	prefix := "fallbacks: "
+	if protocol != "" {
+		prefix = protocol + " " + prefix
+	}
+	config := &fallback.Fallback{
+		Name: c.Name,
+		Alpn: c.Alpn,
+		Path: c.Path,
+		Xver: c.Xver,
+	}
+	/*
+		if config.Alpn == "h2" && config.Path != "" {
+			return nil, errors.New(prefix + `"alpn":"h2" doesn't support "path"`)
+		}
+	*/
+	if config.Path != "" && config.Path[0] != '/' {
+		return nil, errors.New(prefix + `"path" must be empty or start with "/"`)
+	}
+	if config.Xver > 2 {
+		return nil, errors.New(prefix + `invalid PROXY protocol version, "xver" only accepts 0, 1, 2`)
+	}
+
+	var raws []json.RawMessage
+	if err := json.Unmarshal(c.Dest, &raws); err != nil {
+		raws = []json.RawMessage{c.Dest}
+	}
+	for _, raw := range raws {
+		dest := &fallback.Dest{Type: c.Type}
+		var d FallbackDestConfig
+		if len(raw) > 0 && raw[0] == '{' {
+			if err := json.Unmarshal(raw, &d); err != nil {
+				return nil, errors.New(prefix + `invalid "dest"`).Base(err)
+			}
+			dest.Type = d.Type
+			raw = d.Dest
+		}
+		dest.Dest = parseFallbackDest(raw)
+		if dest.Type == "" && dest.Dest != "" {
+			if dest.Dest == "serve-ws-none" {
+				dest.Type = "serve"
+			} else if strings.HasPrefix(dest.Dest, "http://") || strings.HasPrefix(dest.Dest, "https://") {
+				dest.Type = "proxy"
+			} else if filepath.IsAbs(dest.Dest) || dest.Dest[0] == '@' {
+				dest.Type = "unix"
+				if strings.HasPrefix(dest.Dest, "@@") && (runtime.GOOS == "linux" || runtime.GOOS == "android") {
+					fullAddr := make([]byte, len(syscall.RawSockaddrUnix{}.Path)) // may need padding to work with haproxy
+					copy(fullAddr, dest.Dest[1:])
+					dest.Dest = string(fullAddr)
+				}
+			} else {
+				if _, err := strconv.Atoi(dest.Dest); err == nil {
+					dest.Dest = "localhost:" + dest.Dest
+				}
+				if _, _, err := net.SplitHostPort(dest.Dest); err == nil {
+					dest.Type = "tcp"
+				}
+			}
+		}
+		if dest.Type == "" {
+			return nil, errors.New(prefix + `please fill in a valid value for every "dest"`)
+		}
+		config.Dest = append(config.Dest, dest)
+	}
+	return config, nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"net"
+	"path/filepath"
 	"strings"
 
 	"github.com/xtls/xray-core/common/errors"
@@ -14,13 +15,15 @@ import (
 )
 
 type FreedomConfig struct {
-	DomainStrategy string    `json:"domainStrategy"`
-	Redirect       string    `json:"redirect"`
-	UserLevel      uint32    `json:"userLevel"`
-	Fragment       *Fragment `json:"fragment"`
-	Noise          *Noise    `json:"noise"`
-	Noises         []*Noise  `json:"noises"`
-	ProxyProtocol  uint32    `json:"proxyProtocol"`
+	DomainStrategy string          `json:"domainStrategy"`
+	Redirect       string          `json:"redirect"`
+	UserLevel      uint32          `json:"userLevel"`
+	Fragment       *Fragment       `json:"fragment"`
+	FragmentRules  []*FragmentRule `json:"fragmentRules"`
+	Noise          *Noise          `json:"noise"`
+	Noises         []*Noise        `json:"noises"`
+	ProxyProtocol  uint32          `json:"proxyProtocol"`
+	Nat64Prefix    string          `json:"nat64Prefix"`
 }
 
 type Fragment struct {
@@ -29,6 +32,14 @@ type Fragment struct {
 	Interval *Int32Range `json:"interval"`
 }
 
+// FragmentRule selects its fragment for the connections routed by the rules
+// of RuleTags, or to Domains.
+type FragmentRule struct {
+	RuleTags StringList `json:"ruleTag"`
+	Domains  StringList `json:"domain"`
+	Fragment
+}
+
 type Noise struct {
 	Type   string      `json:"type"`
 	Packet string      `json:"packet"`
@@ -66,48 +77,33 @@ func (c *FreedomConfig) Build() (proto.Message, error) {
 	}
 
 	if c.Fragment != nil {
-		config.Fragment = new(freedom.Fragment)
-
-		switch strings.ToLower(c.Fragment.Packets) {
-		case "tlshello":
-			// TLS Hello Fragmentation (into multiple handshake messages)
-			config.Fragment.PacketsFrom = 0
-			config.Fragment.PacketsTo = 1
-		case "":
-			// TCP Segmentation (all packets)
-			config.Fragment.PacketsFrom = 0
-			config.Fragment.PacketsTo = 0
-		default:
-			// TCP Segmentation (range)
-			from, to, err := ParseRangeString(c.Fragment.Packets)
-			if err != nil {
-				return nil, errors.New("Invalid PacketsFrom").Base(err)
-			}
-			config.Fragment.PacketsFrom = uint64(from)
-			config.Fragment.PacketsTo = uint64(to)
-			if config.Fragment.PacketsFrom == 0 {
-				return nil, errors.New("PacketsFrom can't be 0")
-			}
+		fragment, err := c.Fragment.Build()
+		if err != nil {
+			return nil, err
 		}
+		config.Fragment = fragment
+	}
 
-		{
-			if c.Fragment.Length == nil {
-				return nil, errors.New("Length can't be empty")
-			}
-			config.Fragment.LengthMin = uint64(c.Fragment.Length.From)
-			config.Fragment.LengthMax = uint64(c.Fragment.Length.To)
-			if config.Fragment.LengthMin == 0 {
-				return nil, errors.New("LengthMin can't be 0")
-			}
+	for _, rule := range c.FragmentRules {
+		if len(rule.RuleTags) == 0 && len(rule.Domains) == 0 {
+			return nil, errors.New("fragment rule needs ruleTag or domain")
 		}
-
-		{
-			if c.Fragment.Interval == nil {
-				return nil, errors.New("Interval can't be empty")
+		fragment, err := rule.Fragment.Build()
+		if err != nil {
+			return nil, err
+		}
+		fr := &freedom.FragmentRule{
+			RuleTags: rule.RuleTags,
+			Fragment: fragment,
+		}
+		for _, domain := range rule.Domains {
+			rules, err := parseDomainRule(domain)
+			if err != nil {
+				return nil, errors.New("failed to parse domain rule: ", domain).Base(err)
 			}
-			config.Fragment.IntervalMin = uint64(c.Fragment.Interval.From)
-			config.Fragment.IntervalMax = uint64(c.Fragment.Interval.To)
+			fr.Domains = append(fr.Domains, rules...)
 		}
+		config.FragmentRules = append(config.FragmentRules, fr)
 	}
 
 	if c.Noise != nil {
@@ -125,7 +121,14 @@ func (c *FreedomConfig) Build() (proto.Message, error) {
 	}
 
 	config.UserLevel = c.UserLevel
-	if len(c.Redirect) > 0 {
+	config.Nat64Prefix = c.Nat64Prefix
+	if len(c.Redirect) > 0 && (filepath.IsAbs(c.Redirect) || c.Redirect[0] == '@') {
+		config.DestinationOverride = &freedom.DestinationOverride{
+			Server: &protocol.ServerEndpoint{
+				Address: v2net.NewIPOrDomain(v2net.DomainAddress(c.Redirect)),
+			},
+		}
+	} else if len(c.Redirect) > 0 {
 		host, portStr, err := net.SplitHostPort(c.Redirect)
 		if err != nil {
 			return nil, errors.New("invalid redirect address: ", c.Redirect, ": ", err).Base(err)
@@ -150,6 +153,60 @@ func (c *FreedomConfig) Build() (proto.Message, error) {
 	return config, nil
 }
 
+func (c *Fragment) Build() (*freedom.Fragment, error) {
+	config := new(freedom.Fragment)
+
+	switch strings.ToLower(c.Packets) {
+	case "tlshello":
+		// TLS Hello Fragmentation (into multiple handshake messages)
+		config.PacketsFrom = 0
+		config.PacketsTo = 1
+	case "tlsrecord":
+		// TLS Record Segmentation (at record boundaries)
+		config.TlsRecord = true
+		if c.Interval != nil {
+			config.IntervalMin = uint64(c.Interval.From)
+			config.IntervalMax = uint64(c.Interval.To)
+		}
+		return config, nil
+	case "":
+		// TCP Segmentation (all packets)
+		config.PacketsFrom = 0
+		config.PacketsTo = 0
+	default:
+		// TCP Segmentation (range)
+		from, to, err := ParseRangeString(c.Packets)
+		if err != nil {
+			return nil, errors.New("Invalid PacketsFrom").Base(err)
+		}
+		config.PacketsFrom = uint64(from)
+		config.PacketsTo = uint64(to)
+		if config.PacketsFrom == 0 {
+			return nil, errors.New("PacketsFrom can't be 0")
+		}
+	}
+
+	{
+		if c.Length == nil {
+			return nil, errors.New("Length can't be empty")
+		}
+		config.LengthMin = uint64(c.Length.From)
+		config.LengthMax = uint64(c.Length.To)
+		if config.LengthMin == 0 {
+			return nil, errors.New("LengthMin can't be 0")
+		}
+	}
+
+	{
+		if c.Interval == nil {
+			return nil, errors.New("Interval can't be empty")
+		}
+		config.IntervalMin = uint64(c.Interval.From)
+		config.IntervalMax = uint64(c.Interval.To)
+	}
+	return config, nil
+}
+
 func ParseNoise(noise *Noise) (*freedom.Noise, error) {
 	var err error
 	NConfig := new(freedom.Noise)
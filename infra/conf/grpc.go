@@ -6,14 +6,17 @@ import (
 )
 
 type GRPCConfig struct {
-	Authority           string `json:"authority"`
-	ServiceName         string `json:"serviceName"`
-	MultiMode           bool   `json:"multiMode"`
-	IdleTimeout         int32  `json:"idle_timeout"`
-	HealthCheckTimeout  int32  `json:"health_check_timeout"`
-	PermitWithoutStream bool   `json:"permit_without_stream"`
-	InitialWindowsSize  int32  `json:"initial_windows_size"`
-	UserAgent           string `json:"user_agent"`
+	Authority             string `json:"authority"`
+	ServiceName           string `json:"serviceName"`
+	MultiMode             bool   `json:"multiMode"`
+	IdleTimeout           int32  `json:"idle_timeout"`
+	HealthCheckTimeout    int32  `json:"health_check_timeout"`
+	PermitWithoutStream   bool   `json:"permit_without_stream"`
+	InitialWindowsSize    int32  `json:"initial_windows_size"`
+	UserAgent             string `json:"user_agent"`
+	InitialConnWindowSize int32  `json:"initial_conn_window_size"`
+	MaxConcurrentStreams  uint32 `json:"max_concurrent_streams"`
+	KeepaliveMinTime      int32  `json:"keepalive_min_time"`
 }
 
 func (g *GRPCConfig) Build() (proto.Message, error) {
@@ -27,15 +30,24 @@ func (g *GRPCConfig) Build() (proto.Message, error) {
 		// default window size of gRPC-go
 		g.InitialWindowsSize = 0
 	}
+	if g.InitialConnWindowSize < 0 {
+		g.InitialConnWindowSize = 0
+	}
+	if g.KeepaliveMinTime < 0 {
+		g.KeepaliveMinTime = 0
+	}
 
 	return &grpc.Config{
-		Authority:           g.Authority,
-		ServiceName:         g.ServiceName,
-		MultiMode:           g.MultiMode,
-		IdleTimeout:         g.IdleTimeout,
-		HealthCheckTimeout:  g.HealthCheckTimeout,
-		PermitWithoutStream: g.PermitWithoutStream,
-		InitialWindowsSize:  g.InitialWindowsSize,
-		UserAgent:           g.UserAgent,
+		Authority:             g.Authority,
+		ServiceName:           g.ServiceName,
+		MultiMode:             g.MultiMode,
+		IdleTimeout:           g.IdleTimeout,
+		HealthCheckTimeout:    g.HealthCheckTimeout,
+		PermitWithoutStream:   g.PermitWithoutStream,
+		InitialWindowsSize:    g.InitialWindowsSize,
+		UserAgent:             g.UserAgent,
+		InitialConnWindowSize: g.InitialConnWindowSize,
+		MaxConcurrentStreams:  g.MaxConcurrentStreams,
+		KeepaliveMinTime:      g.KeepaliveMinTime,
 	}, nil
 }
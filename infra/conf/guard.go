@@ -0,0 +1,33 @@
+package conf
+
+import (
+	"github.com/xtls/xray-core/app/guard"
+)
+
+type GuardConfig struct {
+	MaxFailures       uint32     `json:"maxFailures"`
+	FindTime          uint32     `json:"findTime"`
+	BanTime           uint32     `json:"banTime"`
+	Ignore            StringList `json:"ignore"`
+	TrustForwardedFor bool       `json:"trustForwardedFor"`
+}
+
+func (c *GuardConfig) Build() (*guard.Config, error) {
+	config := &guard.Config{
+		MaxFailures:       5,
+		FindTime:          600,
+		BanTime:           3600,
+		Ignore:            c.Ignore,
+		TrustForwardedFor: c.TrustForwardedFor,
+	}
+	if c.MaxFailures > 0 {
+		config.MaxFailures = c.MaxFailures
+	}
+	if c.FindTime > 0 {
+		config.FindTime = c.FindTime
+	}
+	if c.BanTime > 0 {
+		config.BanTime = c.BanTime
+	}
+	return config, nil
+}
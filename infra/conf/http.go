@@ -2,11 +2,14 @@ package conf
 
 import (
 	"encoding/json"
+	"net/url"
+	"strings"
 
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
 	"github.com/xtls/xray-core/proxy/http"
+	"github.com/xtls/xray-core/transport/internet/tls"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -23,9 +26,23 @@ func (v *HTTPAccount) Build() *http.Account {
 }
 
 type HTTPServerConfig struct {
-	Accounts    []*HTTPAccount `json:"accounts"`
-	Transparent bool           `json:"allowTransparent"`
-	UserLevel   uint32         `json:"userLevel"`
+	Accounts    []*HTTPAccount      `json:"accounts"`
+	Transparent bool                `json:"allowTransparent"`
+	UserLevel   uint32              `json:"userLevel"`
+	Reverse     []*HTTPReverseRoute `json:"reverse"`
+}
+
+// HTTPReverseRoute is of the requests an HTTP inbound relays to an upstream,
+// as a reverse proxy.
+type HTTPReverseRoute struct {
+	Hosts           StringList        `json:"hosts"`
+	Path            string            `json:"path"`
+	Upstream        string            `json:"upstream"`
+	OutboundTag     string            `json:"outboundTag"`
+	RequestHeaders  map[string]string `json:"requestHeaders"`
+	ResponseHeaders map[string]string `json:"responseHeaders"`
+	StripPath       bool              `json:"stripPath"`
+	PreserveHost    bool              `json:"preserveHost"`
 }
 
 func (c *HTTPServerConfig) Build() (proto.Message, error) {
@@ -34,6 +51,31 @@ func (c *HTTPServerConfig) Build() (proto.Message, error) {
 		UserLevel:        c.UserLevel,
 	}
 
+	if len(c.Reverse) > 0 && len(c.Accounts) > 0 {
+		return nil, errors.New("accounts of HTTP inbound are of the forward proxy, not the reverse one")
+	}
+	for _, r := range c.Reverse {
+		if r.Path != "" && !strings.HasPrefix(r.Path, "/") {
+			return nil, errors.New("path of HTTP reverse route must start with /: ", r.Path)
+		}
+		if r.Upstream != "" {
+			u, err := url.Parse(r.Upstream)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, errors.New("invalid upstream URL of HTTP reverse route: ", r.Upstream)
+			}
+		}
+		config.Reverse = append(config.Reverse, &http.ReverseRoute{
+			Hosts:           r.Hosts,
+			Path:            r.Path,
+			Upstream:        r.Upstream,
+			OutboundTag:     r.OutboundTag,
+			RequestHeaders:  r.RequestHeaders,
+			ResponseHeaders: r.ResponseHeaders,
+			StripPath:       r.StripPath,
+			PreserveHost:    r.PreserveHost,
+		})
+	}
+
 	if len(c.Accounts) > 0 {
 		config.Accounts = make(map[string]string)
 		for _, account := range c.Accounts {
@@ -51,8 +93,10 @@ type HTTPRemoteConfig struct {
 }
 
 type HTTPClientConfig struct {
-	Servers []*HTTPRemoteConfig `json:"servers"`
-	Headers map[string]string   `json:"headers"`
+	Servers     []*HTTPRemoteConfig `json:"servers"`
+	Headers     map[string]string   `json:"headers"`
+	Version     string              `json:"version"`
+	TLSSettings *TLSConfig          `json:"tlsSettings"`
 }
 
 func (v *HTTPClientConfig) Build() (proto.Message, error) {
@@ -84,5 +128,27 @@ func (v *HTTPClientConfig) Build() (proto.Message, error) {
 			Value: value,
 		})
 	}
+	switch strings.ToLower(v.Version) {
+	case "", "auto":
+		config.Version = http.Version_Auto
+	case "1.1", "http/1.1", "h1":
+		config.Version = http.Version_HTTP1
+	case "2", "h2":
+		config.Version = http.Version_HTTP2
+	case "3", "h3":
+		config.Version = http.Version_HTTP3
+	default:
+		return nil, errors.New("unsupported HTTP version: ", v.Version).AtError()
+	}
+	if v.TLSSettings != nil {
+		if config.Version != http.Version_HTTP3 {
+			return nil, errors.New("tlsSettings is only used by HTTP/3, set TLS in streamSettings instead").AtError()
+		}
+		ts, err := v.TLSSettings.Build()
+		if err != nil {
+			return nil, errors.New("failed to build HTTP/3 TLS config").Base(err).AtError()
+		}
+		config.TlsSettings = ts.(*tls.Config)
+	}
 	return config, nil
 }
@@ -0,0 +1,61 @@
+package conf
+
+import (
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/proxy/hysteria2"
+	"github.com/xtls/xray-core/transport/internet/tls"
+	"google.golang.org/protobuf/proto"
+)
+
+type Hysteria2ObfsConfig struct {
+	Type     string `json:"type"`
+	Password string `json:"password"`
+}
+
+type Hysteria2ClientConfig struct {
+	Address     *Address             `json:"address"`
+	Port        uint16               `json:"port"`
+	Password    string               `json:"password"`
+	Obfs        *Hysteria2ObfsConfig `json:"obfs"`
+	TLSSettings *TLSConfig           `json:"tlsSettings"`
+	Up          uint64               `json:"up"`   // Mbps
+	Down        uint64               `json:"down"` // Mbps
+	Level       byte                 `json:"level"`
+	Email       string               `json:"email"`
+}
+
+// Build implements Buildable
+func (c *Hysteria2ClientConfig) Build() (proto.Message, error) {
+	if c.Address == nil {
+		return nil, errors.New("Hysteria2 server address is not set.")
+	}
+	if c.Port == 0 {
+		return nil, errors.New("Invalid Hysteria2 port.")
+	}
+	config := &hysteria2.ClientConfig{
+		Address:  c.Address.Build(),
+		Port:     uint32(c.Port),
+		Password: c.Password,
+		Up:       c.Up * 1000 * 1000 / 8,
+		Down:     c.Down * 1000 * 1000 / 8,
+		Level:    uint32(c.Level),
+		Email:    c.Email,
+	}
+	if c.Obfs != nil && c.Obfs.Type != "" {
+		if c.Obfs.Type != "salamander" {
+			return nil, errors.New(`Hysteria2 "obfs": unknown type `, c.Obfs.Type)
+		}
+		config.Obfs = &hysteria2.Obfs{
+			Type:     c.Obfs.Type,
+			Password: c.Obfs.Password,
+		}
+	}
+	if c.TLSSettings != nil {
+		ts, err := c.TLSSettings.Build()
+		if err != nil {
+			return nil, errors.New("Failed to build Hysteria2 TLS config.").Base(err)
+		}
+		config.TlsSettings = ts.(*tls.Config)
+	}
+	return config, nil
+}
@@ -0,0 +1,302 @@
+// Package link converts the share links of proxies to outbound configs.
+package link
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Parse returns the JSON of the outbound of a vless://, vmess://, trojan:// or
+// ss:// share link, tagged by the name of the link.
+func Parse(link string) (map[string]interface{}, error) {
+	if strings.HasPrefix(link, "vmess://") {
+		return parseVMess(strings.TrimPrefix(link, "vmess://"))
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "ss" && u.User == nil {
+		// The legacy form of ss://base64(method:password@host:port)#name.
+		b, err := decodeBase64(u.Host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ss link: %s", err)
+		}
+		fragment := u.Fragment
+		if u, err = url.Parse("ss://" + string(b)); err != nil {
+			return nil, err
+		}
+		u.Fragment = fragment
+	}
+	if u.User == nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("link without user or host")
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid port %q", u.Port())
+	}
+	address := u.Hostname()
+	params := u.Query()
+
+	outbound := map[string]interface{}{"protocol": u.Scheme}
+	if u.Fragment != "" {
+		outbound["tag"] = u.Fragment
+	}
+	switch u.Scheme {
+	case "vless":
+		user := map[string]interface{}{"id": u.User.Username(), "encryption": "none"}
+		if encryption := params.Get("encryption"); encryption != "" && encryption != "none" {
+			return nil, fmt.Errorf("vless encryption %s is not supported", encryption)
+		}
+		if flow := params.Get("flow"); flow != "" {
+			user["flow"] = flow
+		}
+		outbound["settings"] = map[string]interface{}{"vnext": []interface{}{map[string]interface{}{
+			"address": address,
+			"port":    port,
+			"users":   []interface{}{user},
+		}}}
+	case "trojan":
+		server := map[string]interface{}{
+			"address":  address,
+			"port":     port,
+			"password": u.User.Username(),
+		}
+		if flow := params.Get("flow"); flow != "" {
+			server["flow"] = flow
+		}
+		outbound["settings"] = map[string]interface{}{"servers": []interface{}{server}}
+		if params.Get("security") == "" {
+			params.Set("security", "tls")
+		}
+	case "ss":
+		if plugin := params.Get("plugin"); plugin != "" {
+			return nil, fmt.Errorf("plugin %s is not supported", plugin)
+		}
+		method, password, ok := u.User.Username(), "", false
+		if password, ok = u.User.Password(); !ok {
+			b, err := decodeBase64(method)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ss user info: %s", err)
+			}
+			if method, password, ok = strings.Cut(string(b), ":"); !ok {
+				return nil, fmt.Errorf("ss user info without password")
+			}
+		}
+		outbound["protocol"] = "shadowsocks"
+		outbound["settings"] = map[string]interface{}{"servers": []interface{}{map[string]interface{}{
+			"address":  address,
+			"port":     port,
+			"method":   method,
+			"password": password,
+		}}}
+	default:
+		return nil, fmt.Errorf("links of %s are not supported", u.Scheme)
+	}
+
+	stream, err := parseStream(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(stream) > 0 {
+		outbound["streamSettings"] = stream
+	}
+	return outbound, nil
+}
+
+// parseVMess returns the outbound of the base64 JSON of v2rayN.
+func parseVMess(encoded string) (map[string]interface{}, error) {
+	b, err := decodeBase64(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vmess link: %s", err)
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("invalid vmess link: %s", err)
+	}
+	get := func(key string) string {
+		switch value := v[key].(type) {
+		case string:
+			return value
+		case float64:
+			return strconv.Itoa(int(value))
+		default:
+			return ""
+		}
+	}
+	port, err := strconv.Atoi(get("port"))
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid port %q", get("port"))
+	}
+	if aid := get("aid"); aid != "" && aid != "0" {
+		return nil, fmt.Errorf("vmess alterId %s is not supported", aid)
+	}
+	security := get("scy")
+	if security == "" {
+		security = "auto"
+	}
+
+	params := url.Values{}
+	for key, param := range map[string]string{
+		"net":  "type",
+		"host": "host",
+		"path": "path",
+		"tls":  "security",
+		"sni":  "sni",
+		"alpn": "alpn",
+		"fp":   "fp",
+		"pbk":  "pbk",
+		"sid":  "sid",
+	} {
+		setParam(params, param, get(key))
+	}
+	if params.Get("type") == "grpc" {
+		params.Set("serviceName", params.Get("path"))
+	}
+	if headerType := get("type"); headerType != "" && headerType != "none" {
+		params.Set("headerType", headerType)
+	}
+
+	outbound := map[string]interface{}{
+		"protocol": "vmess",
+		"settings": map[string]interface{}{"vnext": []interface{}{map[string]interface{}{
+			"address": get("add"),
+			"port":    port,
+			"users":   []interface{}{map[string]interface{}{"id": get("id"), "security": security}},
+		}}},
+	}
+	if name := get("ps"); name != "" {
+		outbound["tag"] = name
+	}
+	stream, err := parseStream(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(stream) > 0 {
+		outbound["streamSettings"] = stream
+	}
+	return outbound, nil
+}
+
+// parseStream returns the streamSettings of the query parameters of a link.
+func parseStream(params url.Values) (map[string]interface{}, error) {
+	stream := map[string]interface{}{}
+	http := func() map[string]interface{} {
+		settings := map[string]interface{}{}
+		if path := params.Get("path"); path != "" {
+			settings["path"] = path
+		}
+		if host := params.Get("host"); host != "" {
+			settings["host"] = host
+		}
+		return settings
+	}
+	switch network := params.Get("type"); network {
+	case "", "tcp", "raw":
+		if headerType := params.Get("headerType"); headerType != "" && headerType != "none" {
+			return nil, fmt.Errorf("header %s is not supported", headerType)
+		}
+	case "ws":
+		stream["network"] = "ws"
+		stream["wsSettings"] = http()
+	case "httpupgrade":
+		stream["network"] = "httpupgrade"
+		stream["httpupgradeSettings"] = http()
+	case "xhttp", "splithttp":
+		settings := http()
+		if mode := params.Get("mode"); mode != "" {
+			settings["mode"] = mode
+		}
+		stream["network"] = "xhttp"
+		stream["xhttpSettings"] = settings
+	case "grpc":
+		settings := map[string]interface{}{"serviceName": params.Get("serviceName")}
+		if params.Get("mode") == "multi" {
+			settings["multiMode"] = true
+		}
+		stream["network"] = "grpc"
+		stream["grpcSettings"] = settings
+	default:
+		return nil, fmt.Errorf("transport %s is not supported", network)
+	}
+
+	switch security := params.Get("security"); security {
+	case "", "none":
+	case "tls":
+		settings := map[string]interface{}{}
+		if sni := params.Get("sni"); sni != "" {
+			settings["serverName"] = sni
+		}
+		if alpn := params.Get("alpn"); alpn != "" {
+			settings["alpn"] = strings.Split(alpn, ",")
+		}
+		if fp := params.Get("fp"); fp != "" {
+			settings["fingerprint"] = fp
+		}
+		if insecure := params.Get("allowInsecure"); insecure == "1" || insecure == "true" {
+			settings["allowInsecure"] = true
+		}
+		stream["security"] = "tls"
+		stream["tlsSettings"] = settings
+	case "reality":
+		if params.Get("pbk") == "" {
+			return nil, fmt.Errorf("REALITY without public key")
+		}
+		fingerprint := params.Get("fp")
+		if fingerprint == "" {
+			fingerprint = "chrome"
+		}
+		settings := map[string]interface{}{
+			"serverName":  params.Get("sni"),
+			"fingerprint": fingerprint,
+			"publicKey":   params.Get("pbk"),
+			"shortId":     params.Get("sid"),
+		}
+		if spiderX := params.Get("spx"); spiderX != "" {
+			settings["spiderX"] = spiderX
+		}
+		if verify := params.Get("pqv"); verify != "" {
+			settings["mldsa65Verify"] = verify
+		}
+		stream["security"] = "reality"
+		stream["realitySettings"] = settings
+	default:
+		return nil, fmt.Errorf("security %s is not supported", security)
+	}
+	return stream, nil
+}
+
+// decodeBase64 decodes s of any of the encodings of links.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+func setParam(params url.Values, key, value string) {
+	if value != "" {
+		params.Set(key, value)
+	}
+}
+
+// Subscription returns the links of the body of a subscription, one per line,
+// in base64 or not.
+func Subscription(b []byte) []string {
+	s := strings.TrimSpace(string(b))
+	if decoded, err := decodeBase64(strings.Join(strings.Fields(s), "")); err == nil {
+		s = string(decoded)
+	}
+	var links []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			links = append(links, line)
+		}
+	}
+	return links
+}
@@ -4,6 +4,7 @@ import (
 	"strings"
 
 	"github.com/xtls/xray-core/app/log"
+	"github.com/xtls/xray-core/common/errors"
 	clog "github.com/xtls/xray-core/common/log"
 )
 
@@ -21,11 +22,44 @@ type LogConfig struct {
 	LogLevel    string `json:"loglevel"`
 	DNSLog      bool   `json:"dnsLog"`
 	MaskAddress string `json:"maskAddress"`
+
+	AccessSample  uint32           `json:"accessSample"`
+	AccessInclude *AccessLogFilter `json:"accessInclude"`
+	AccessExclude *AccessLogFilter `json:"accessExclude"`
+}
+
+// AccessLogFilter matches the access logs of any of its inbounds or
+// outbounds, or to any of its domains.
+type AccessLogFilter struct {
+	InboundTags  StringList `json:"inboundTag"`
+	OutboundTags StringList `json:"outboundTag"`
+	Domains      StringList `json:"domain"`
+}
+
+func (f *AccessLogFilter) Build() (*log.AccessFilter, error) {
+	if f == nil {
+		return nil, nil
+	}
+	if len(f.InboundTags) == 0 && len(f.OutboundTags) == 0 && len(f.Domains) == 0 {
+		return nil, errors.New("access log filter needs inboundTag, outboundTag or domain")
+	}
+	filter := &log.AccessFilter{
+		InboundTags:  f.InboundTags,
+		OutboundTags: f.OutboundTags,
+	}
+	for _, domain := range f.Domains {
+		rules, err := parseDomainRule(domain)
+		if err != nil {
+			return nil, errors.New("failed to parse domain rule: ", domain).Base(err)
+		}
+		filter.Domains = append(filter.Domains, rules...)
+	}
+	return filter, nil
 }
 
-func (v *LogConfig) Build() *log.Config {
+func (v *LogConfig) Build() (*log.Config, error) {
 	if v == nil {
-		return nil
+		return nil, nil
 	}
 	config := &log.Config{
 		ErrorLogType:  log.LogType_Console,
@@ -61,5 +95,14 @@ func (v *LogConfig) Build() *log.Config {
 		config.ErrorLogLevel = clog.Severity_Warning
 	}
 	config.MaskAddress = v.MaskAddress
-	return config
+
+	config.AccessSample = v.AccessSample
+	var err error
+	if config.AccessInclude, err = v.AccessInclude.Build(); err != nil {
+		return nil, errors.New("invalid accessInclude").Base(err)
+	}
+	if config.AccessExclude, err = v.AccessExclude.Build(); err != nil {
+		return nil, errors.New("invalid accessExclude").Base(err)
+	}
+	return config, nil
 }
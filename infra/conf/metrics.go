@@ -8,6 +8,7 @@ import (
 type MetricsConfig struct {
 	Tag    string `json:"tag"`
 	Listen string `json:"listen"`
+	Token  string `json:"token"`
 }
 
 func (c *MetricsConfig) Build() (*metrics.Config, error) {
@@ -22,5 +23,6 @@ func (c *MetricsConfig) Build() (*metrics.Config, error) {
 	return &metrics.Config{
 		Tag:    c.Tag,
 		Listen: c.Listen,
+		Token:  c.Token,
 	}, nil
 }
@@ -14,10 +14,25 @@ type ObservatoryConfig struct {
 	ProbeURL          string            `json:"probeURL"`
 	ProbeInterval     duration.Duration `json:"probeInterval"`
 	EnableConcurrency bool              `json:"enableConcurrency"`
+	ExpectedStatus    []uint32          `json:"expectedStatus"`
+	ExpectedBody      string            `json:"expectedBody"`
+	HistorySize       uint32            `json:"historySize"`
+	EgressURL         string            `json:"egressURL"`
+	EgressInterval    duration.Duration `json:"egressInterval"`
 }
 
 func (o *ObservatoryConfig) Build() (proto.Message, error) {
-	return &observatory.Config{SubjectSelector: o.SubjectSelector, ProbeUrl: o.ProbeURL, ProbeInterval: int64(o.ProbeInterval), EnableConcurrency: o.EnableConcurrency}, nil
+	return &observatory.Config{
+		SubjectSelector:   o.SubjectSelector,
+		ProbeUrl:          o.ProbeURL,
+		ProbeInterval:     int64(o.ProbeInterval),
+		EnableConcurrency: o.EnableConcurrency,
+		ExpectedStatus:    o.ExpectedStatus,
+		ExpectedBody:      o.ExpectedBody,
+		HistorySize:       o.HistorySize,
+		EgressUrl:         o.EgressURL,
+		EgressInterval:    int64(o.EgressInterval),
+	}, nil
 }
 
 type BurstObservatoryConfig struct {
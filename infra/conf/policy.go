@@ -1,18 +1,38 @@
 package conf
 
 import (
+	"math"
+	"strings"
+
 	"github.com/xtls/xray-core/app/policy"
+	"github.com/xtls/xray-core/common/errors"
 )
 
+// BufferAutoTune bounds the buffer size of each connection, in KB, while it
+// is tuned from the traffic of the connection.
+type BufferAutoTune struct {
+	Min uint32 `json:"min"`
+	Max uint32 `json:"max"`
+}
+
 type Policy struct {
-	Handshake         *uint32 `json:"handshake"`
-	ConnectionIdle    *uint32 `json:"connIdle"`
-	UplinkOnly        *uint32 `json:"uplinkOnly"`
-	DownlinkOnly      *uint32 `json:"downlinkOnly"`
-	StatsUserUplink   bool    `json:"statsUserUplink"`
-	StatsUserDownlink bool    `json:"statsUserDownlink"`
-	StatsUserOnline   bool    `json:"statsUserOnline"`
-	BufferSize        *int32  `json:"bufferSize"`
+	Handshake         *uint32           `json:"handshake"`
+	ConnectionIdle    *uint32           `json:"connIdle"`
+	UplinkOnly        *uint32           `json:"uplinkOnly"`
+	DownlinkOnly      *uint32           `json:"downlinkOnly"`
+	ConnIdleUDP       *uint32           `json:"connIdleUdp"`
+	ConnIdleQUIC      *uint32           `json:"connIdleQuic"`
+	ConnIdleSTUN      *uint32           `json:"connIdleStun"`
+	ConnIdleProtocols map[string]uint32 `json:"connIdleProtocols"`
+	StatsUserUplink   bool              `json:"statsUserUplink"`
+	StatsUserDownlink bool              `json:"statsUserDownlink"`
+	StatsUserOnline   bool              `json:"statsUserOnline"`
+	BufferSize        *int32            `json:"bufferSize"`
+	BufferAutoTune    *BufferAutoTune   `json:"bufferAutoTune"`
+	BlockBittorrent   bool              `json:"blockBittorrent"`
+	MaxIPs            uint32            `json:"maxIPs"`
+	Quota             uint64            `json:"quota"` // in MB
+	Trace             bool              `json:"trace"`
 }
 
 func (t *Policy) Build() (*policy.Policy, error) {
@@ -29,6 +49,21 @@ func (t *Policy) Build() (*policy.Policy, error) {
 	if t.DownlinkOnly != nil {
 		config.DownlinkOnly = &policy.Second{Value: *t.DownlinkOnly}
 	}
+	if t.ConnIdleUDP != nil {
+		config.ConnectionIdleUdp = &policy.Second{Value: *t.ConnIdleUDP}
+	}
+	if t.ConnIdleQUIC != nil {
+		config.ConnectionIdleQuic = &policy.Second{Value: *t.ConnIdleQUIC}
+	}
+	if t.ConnIdleSTUN != nil {
+		config.ConnectionIdleStun = &policy.Second{Value: *t.ConnIdleSTUN}
+	}
+	if len(t.ConnIdleProtocols) > 0 {
+		config.ConnectionIdleProtocols = make(map[string]*policy.Second, len(t.ConnIdleProtocols))
+		for protocol, timeout := range t.ConnIdleProtocols {
+			config.ConnectionIdleProtocols[strings.ToLower(protocol)] = &policy.Second{Value: timeout}
+		}
+	}
 
 	p := &policy.Policy{
 		Timeout: config,
@@ -48,26 +83,114 @@ func (t *Policy) Build() (*policy.Policy, error) {
 			Connection: bs,
 		}
 	}
+	if t.BufferAutoTune != nil {
+		if t.BufferSize != nil {
+			return nil, errors.New("bufferSize and bufferAutoTune can not be both set")
+		}
+		tune := t.BufferAutoTune
+		if tune.Max == 0 || tune.Min > tune.Max || tune.Max > math.MaxInt32/1024 {
+			return nil, errors.New("invalid bufferAutoTune: ", tune.Min, " - ", tune.Max)
+		}
+		p.Buffer = &policy.Policy_Buffer{
+			ConnectionMin: int32(tune.Min) * 1024,
+			ConnectionMax: int32(tune.Max) * 1024,
+		}
+	}
+	if t.BlockBittorrent || t.MaxIPs > 0 || t.Quota > 0 {
+		if t.Quota > math.MaxInt64/(1024*1024) {
+			return nil, errors.New("quota too large: ", t.Quota)
+		}
+		p.Restriction = &policy.Policy_Restriction{
+			BlockBittorrent: t.BlockBittorrent,
+			MaxIps:          t.MaxIPs,
+			Quota:           t.Quota * 1024 * 1024,
+		}
+	}
+	if t.Trace {
+		p.Tracing = &policy.Policy_Tracing{
+			Enabled: true,
+		}
+	}
 
 	return p, nil
 }
 
+type QoSClass struct {
+	Priority uint32      `json:"priority"`
+	Weight   uint32      `json:"weight"`
+	Levels   []uint32    `json:"levels"`
+	RuleTags *StringList `json:"ruleTags"`
+}
+
+// QoS shapes the traffic to a bandwidth, in Mbps.
+type QoS struct {
+	Uplink   uint64      `json:"uplink"`
+	Downlink uint64      `json:"downlink"`
+	Classes  []*QoSClass `json:"classes"`
+}
+
+func (q *QoS) Build() (*policy.SystemPolicy_QoS, error) {
+	if q.Uplink == 0 && q.Downlink == 0 {
+		return nil, errors.New("qos requires uplink or downlink")
+	}
+	config := &policy.SystemPolicy_QoS{
+		Uplink:   q.Uplink * 1000 * 1000 / 8,
+		Downlink: q.Downlink * 1000 * 1000 / 8,
+	}
+	for _, c := range q.Classes {
+		class := &policy.SystemPolicy_QoS_Class{
+			Priority: c.Priority,
+			Weight:   c.Weight,
+			Levels:   c.Levels,
+		}
+		if class.Weight == 0 {
+			class.Weight = 1
+		}
+		if c.RuleTags != nil {
+			class.RuleTags = *c.RuleTags
+		}
+		config.Class = append(config.Class, class)
+	}
+	return config, nil
+}
+
 type SystemPolicy struct {
-	StatsInboundUplink    bool `json:"statsInboundUplink"`
-	StatsInboundDownlink  bool `json:"statsInboundDownlink"`
-	StatsOutboundUplink   bool `json:"statsOutboundUplink"`
-	StatsOutboundDownlink bool `json:"statsOutboundDownlink"`
+	StatsInboundUplink       bool   `json:"statsInboundUplink"`
+	StatsInboundDownlink     bool   `json:"statsInboundDownlink"`
+	StatsOutboundUplink      bool   `json:"statsOutboundUplink"`
+	StatsOutboundDownlink    bool   `json:"statsOutboundDownlink"`
+	StatsRuleMatched         bool   `json:"statsRuleMatched"`
+	StatsRuleUplink          bool   `json:"statsRuleUplink"`
+	StatsRuleDownlink        bool   `json:"statsRuleDownlink"`
+	StatsUDPFlows            bool   `json:"statsUdpFlows"`
+	StatsOutboundTermination bool   `json:"statsOutboundTermination"`
+	QoS                      *QoS   `json:"qos"`
+	DrainTimeout             uint32 `json:"drainTimeout"`
 }
 
 func (p *SystemPolicy) Build() (*policy.SystemPolicy, error) {
-	return &policy.SystemPolicy{
+	config := &policy.SystemPolicy{
 		Stats: &policy.SystemPolicy_Stats{
-			InboundUplink:    p.StatsInboundUplink,
-			InboundDownlink:  p.StatsInboundDownlink,
-			OutboundUplink:   p.StatsOutboundUplink,
-			OutboundDownlink: p.StatsOutboundDownlink,
+			InboundUplink:       p.StatsInboundUplink,
+			InboundDownlink:     p.StatsInboundDownlink,
+			OutboundUplink:      p.StatsOutboundUplink,
+			OutboundDownlink:    p.StatsOutboundDownlink,
+			RuleMatched:         p.StatsRuleMatched,
+			RuleUplink:          p.StatsRuleUplink,
+			RuleDownlink:        p.StatsRuleDownlink,
+			UdpFlows:            p.StatsUDPFlows,
+			OutboundTermination: p.StatsOutboundTermination,
 		},
-	}, nil
+		DrainTimeout: p.DrainTimeout,
+	}
+	if p.QoS != nil {
+		q, err := p.QoS.Build()
+		if err != nil {
+			return nil, err
+		}
+		config.Qos = q
+	}
+	return config, nil
 }
 
 type PolicyConfig struct {
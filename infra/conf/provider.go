@@ -0,0 +1,52 @@
+package conf
+
+import (
+	"net/url"
+
+	"github.com/xtls/xray-core/app/provider"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/infra/conf/cfgcommon/duration"
+	"google.golang.org/protobuf/proto"
+)
+
+// OutboundProviderConfig is a subscription of outbounds, tagged with the tag
+// prefix, which the selectors of balancers and observatories select.
+type OutboundProviderConfig struct {
+	Name        string            `json:"name"`
+	URL         string            `json:"url"`
+	TagPrefix   string            `json:"tagPrefix"`
+	Interval    duration.Duration `json:"interval"`
+	OutboundTag string            `json:"outboundTag"`
+}
+
+type OutboundProvidersConfig []*OutboundProviderConfig
+
+func (c OutboundProvidersConfig) Build() (proto.Message, error) {
+	config := &provider.Config{}
+	names := make(map[string]bool, len(c))
+	for _, p := range c {
+		if p.Name == "" {
+			return nil, errors.New("outbound provider without name")
+		}
+		if names[p.Name] {
+			return nil, errors.New("duplicate outbound provider ", p.Name)
+		}
+		names[p.Name] = true
+		u, err := url.Parse(p.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, errors.New("invalid URL of outbound provider ", p.Name, ": ", p.URL)
+		}
+		tagPrefix := p.TagPrefix
+		if tagPrefix == "" {
+			tagPrefix = p.Name + "-"
+		}
+		config.Providers = append(config.Providers, &provider.Provider{
+			Name:        p.Name,
+			Url:         p.URL,
+			TagPrefix:   tagPrefix,
+			Interval:    int64(p.Interval),
+			OutboundTag: p.OutboundTag,
+		})
+	}
+	return config, nil
+}
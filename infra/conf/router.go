@@ -2,15 +2,19 @@ package conf
 
 import (
 	"encoding/json"
+	"os"
 	"runtime"
 	"strconv"
 	"strings"
 
 	"github.com/xtls/xray-core/app/router"
 	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/geodata"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/platform"
 	"github.com/xtls/xray-core/common/platform/filesystem"
 	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/infra/conf/cfgcommon/duration"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -25,6 +29,7 @@ type BalancingRule struct {
 	Selectors   StringList     `json:"selector"`
 	Strategy    StrategyConfig `json:"strategy"`
 	FallbackTag string         `json:"fallbackTag"`
+	Countries   StringList     `json:"countries"`
 }
 
 // Build builds the balancing rule
@@ -45,6 +50,14 @@ func (r *BalancingRule) Build() (*router.BalancingRule, error) {
 		return nil, errors.New("unknown balancing strategy: " + r.Strategy.Type)
 	}
 
+	countries := make([]string, 0, len(r.Countries))
+	for _, country := range r.Countries {
+		if len(country) != 2 {
+			return nil, errors.New("invalid country of balancer ", r.Tag, ": ", country)
+		}
+		countries = append(countries, strings.ToUpper(country))
+	}
+
 	settings := []byte("{}")
 	if r.Strategy.Settings != nil {
 		settings = ([]byte)(*r.Strategy.Settings)
@@ -67,6 +80,7 @@ func (r *BalancingRule) Build() (*router.BalancingRule, error) {
 		FallbackTag:      r.FallbackTag,
 		OutboundSelector: r.Selectors,
 		Tag:              r.Tag,
+		Countries:        countries,
 	}, nil
 }
 
@@ -75,7 +89,14 @@ type RouterConfig struct {
 	DomainStrategy *string           `json:"domainStrategy"`
 	Balancers      []*BalancingRule  `json:"balancers"`
 
-	DomainMatcher string `json:"domainMatcher"`
+	DomainMatcher string            `json:"domainMatcher"`
+	Cache         *RouteCacheConfig `json:"cache"`
+}
+
+// RouteCacheConfig is the config of the cache of routing decisions.
+type RouteCacheConfig struct {
+	TTL  duration.Duration `json:"ttl"`
+	Size uint32            `json:"size"`
 }
 
 func (c *RouterConfig) getDomainStrategy() router.Config_DomainStrategy {
@@ -124,6 +145,15 @@ func (c *RouterConfig) Build() (*router.Config, error) {
 		}
 		config.BalancingRule = append(config.BalancingRule, balancer)
 	}
+	if c.Cache != nil {
+		if c.Cache.TTL < 0 {
+			return nil, errors.New("negative routing cache ttl")
+		}
+		config.Cache = &router.RouteCache{
+			Ttl:  int64(c.Cache.TTL),
+			Size: c.Cache.Size,
+		}
+	}
 	return config, nil
 }
 
@@ -211,14 +241,29 @@ func loadFile(file string) ([]byte, error) {
 	return FileCache[file], nil
 }
 
+// loadEntry reads the entry of code in the geodata file, or returns nil if
+// there is none. It reads only the entry, unless the file is not on the file
+// system, as with a custom filesystem.NewFileReader.
+func loadEntry(file, code string) ([]byte, error) {
+	if bs, err := geodata.Load(platform.GetAssetLocation(file), code); err == nil {
+		return bs, nil
+	} else if !os.IsNotExist(err) {
+		return nil, errors.New("failed to load file: ", file).Base(err)
+	}
+	bs, err := loadFile(file)
+	if err != nil {
+		return nil, errors.New("failed to load file: ", file).Base(err)
+	}
+	return find(bs, []byte(code)), nil
+}
+
 func loadIP(file, code string) ([]*router.CIDR, error) {
 	index := file + ":" + code
 	if IPCache[index] == nil {
-		bs, err := loadFile(file)
+		bs, err := loadEntry(file, code)
 		if err != nil {
-			return nil, errors.New("failed to load file: ", file).Base(err)
+			return nil, err
 		}
-		bs = find(bs, []byte(code))
 		if bs == nil {
 			return nil, errors.New("code not found in ", file, ": ", code)
 		}
@@ -236,11 +281,10 @@ func loadIP(file, code string) ([]*router.CIDR, error) {
 func loadSite(file, code string) ([]*router.Domain, error) {
 	index := file + ":" + code
 	if SiteCache[index] == nil {
-		bs, err := loadFile(file)
+		bs, err := loadEntry(file, code)
 		if err != nil {
-			return nil, errors.New("failed to load file: ", file).Base(err)
+			return nil, err
 		}
-		bs = find(bs, []byte(code))
 		if bs == nil {
 			return nil, errors.New("list not found in ", file, ": ", code)
 		}
@@ -533,12 +577,16 @@ func parseFieldRule(msg json.RawMessage) (*router.RoutingRule, error) {
 		RouterRule
 		Domain     *StringList       `json:"domain"`
 		Domains    *StringList       `json:"domains"`
+		CNAME      *StringList       `json:"cname"`
 		IP         *StringList       `json:"ip"`
 		Port       *PortList         `json:"port"`
 		Network    *NetworkList      `json:"network"`
 		SourceIP   *StringList       `json:"source"`
 		SourcePort *PortList         `json:"sourcePort"`
 		User       *StringList       `json:"user"`
+		TLSClient  *StringList       `json:"tlsClient"`
+		TLSSNI     *StringList       `json:"tlsSni"`
+		TLSALPN    *StringList       `json:"tlsAlpn"`
 		InboundTag *StringList       `json:"inboundTag"`
 		Protocols  *StringList       `json:"protocol"`
 		Attributes map[string]string `json:"attrs"`
@@ -588,6 +636,16 @@ func parseFieldRule(msg json.RawMessage) (*router.RoutingRule, error) {
 		}
 	}
 
+	if rawFieldRule.CNAME != nil {
+		for _, domain := range *rawFieldRule.CNAME {
+			rules, err := parseDomainRule(domain)
+			if err != nil {
+				return nil, errors.New("failed to parse cname rule: ", domain).Base(err)
+			}
+			rule.Cname = append(rule.Cname, rules...)
+		}
+	}
+
 	if rawFieldRule.IP != nil {
 		geoipList, err := ToCidrList(*rawFieldRule.IP)
 		if err != nil {
@@ -622,6 +680,18 @@ func parseFieldRule(msg json.RawMessage) (*router.RoutingRule, error) {
 		}
 	}
 
+	if rawFieldRule.TLSClient != nil {
+		rule.TlsClient = *rawFieldRule.TLSClient
+	}
+
+	if rawFieldRule.TLSSNI != nil {
+		rule.TlsServerName = *rawFieldRule.TLSSNI
+	}
+
+	if rawFieldRule.TLSALPN != nil {
+		rule.TlsAlpn = *rawFieldRule.TLSALPN
+	}
+
 	if rawFieldRule.InboundTag != nil {
 		for _, s := range *rawFieldRule.InboundTag {
 			rule.InboundTag = append(rule.InboundTag, s)
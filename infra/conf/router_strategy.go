@@ -6,6 +6,7 @@ import (
 
 	"github.com/xtls/xray-core/app/observatory/burst"
 	"github.com/xtls/xray-core/app/router"
+	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/infra/conf/cfgcommon/duration"
 )
 
@@ -53,6 +54,17 @@ type healthCheckSettings struct {
 	SamplingCount int               `json:"sampling"`
 	Timeout       duration.Duration `json:"timeout"`
 	HttpMethod    string            `json:"httpMethod"`
+	Concurrency   int               `json:"concurrency"`
+	Jitter        float32           `json:"jitter"`
+
+	IntervalOverrides []*healthCheckInterval `json:"intervalOverrides"`
+}
+
+// healthCheckInterval overrides the interval of health checks for the
+// outbounds matching
+type healthCheckInterval struct {
+	SubjectSelector []string          `json:"subjectSelector"`
+	Interval        duration.Duration `json:"interval"`
 }
 
 func (h healthCheckSettings) Build() (proto.Message, error) {
@@ -62,13 +74,29 @@ func (h healthCheckSettings) Build() (proto.Message, error) {
 	} else {
 		httpMethod = strings.TrimSpace(h.HttpMethod)
 	}
+	if h.Jitter < 0 || h.Jitter > 1 {
+		return nil, errors.New("jitter of health checks must be within 0 to 1")
+	}
+	overrides := make([]*burst.HealthPingInterval, 0, len(h.IntervalOverrides))
+	for _, o := range h.IntervalOverrides {
+		if len(o.SubjectSelector) == 0 || o.Interval <= 0 {
+			return nil, errors.New("interval override requires subjectSelector and interval")
+		}
+		overrides = append(overrides, &burst.HealthPingInterval{
+			SubjectSelector: o.SubjectSelector,
+			Interval:        int64(o.Interval),
+		})
+	}
 	return &burst.HealthPingConfig{
-		Destination:   h.Destination,
-		Connectivity:  h.Connectivity,
-		Interval:      int64(h.Interval),
-		Timeout:       int64(h.Timeout),
-		SamplingCount: int32(h.SamplingCount),
-		HttpMethod:    httpMethod,
+		Destination:       h.Destination,
+		Connectivity:      h.Connectivity,
+		Interval:          int64(h.Interval),
+		Timeout:           int64(h.Timeout),
+		SamplingCount:     int32(h.SamplingCount),
+		HttpMethod:        httpMethod,
+		Concurrency:       int32(h.Concurrency),
+		Jitter:            h.Jitter,
+		IntervalOverrides: overrides,
 	}, nil
 }
 
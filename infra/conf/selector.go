@@ -0,0 +1,19 @@
+package conf
+
+import (
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/proxy/selector"
+	"google.golang.org/protobuf/proto"
+)
+
+type SelectorConfig struct {
+	Candidates []string `json:"candidates"`
+	Selected   string   `json:"selected"`
+}
+
+func (c *SelectorConfig) Build() (proto.Message, error) {
+	if len(c.Candidates) == 0 {
+		return nil, errors.New("selector without candidates")
+	}
+	return &selector.Config{Candidates: c.Candidates, Selected: c.Selected}, nil
+}
@@ -100,7 +100,9 @@ func (v *ShadowsocksServerConfig) Build() (proto.Message, error) {
 }
 
 func buildShadowsocks2022(v *ShadowsocksServerConfig) (proto.Message, error) {
-	if len(v.Users) == 0 {
+	// An explicit empty "clients" list still selects the multi-user server,
+	// so that users can be managed later through the API.
+	if v.Users == nil {
 		config := new(shadowsocks_2022.ServerConfig)
 		config.Method = v.Cipher
 		config.Key = v.Password
@@ -116,7 +118,7 @@ func buildShadowsocks2022(v *ShadowsocksServerConfig) (proto.Message, error) {
 		return nil, errors.New("shadowsocks 2022 (multi-user): only blake3-aes-*-gcm methods are supported")
 	}
 
-	if v.Users[0].Address == nil {
+	if len(v.Users) == 0 || v.Users[0].Address == nil {
 		config := new(shadowsocks_2022.MultiUserServerConfig)
 		config.Method = v.Cipher
 		config.Key = v.Password
@@ -219,6 +221,9 @@ func (v *ShadowsocksClientConfig) Build() (proto.Message, error) {
 		if server.Password == "" {
 			return nil, errors.New("Shadowsocks password is not specified.")
 		}
+		if server.UoT != v.Servers[0].UoT || server.UoTVersion != v.Servers[0].UoTVersion {
+			return nil, errors.New("Shadowsocks servers must share the same uot settings.")
+		}
 		account := &shadowsocks.Account{
 			Password: server.Password,
 		}
@@ -245,6 +250,8 @@ func (v *ShadowsocksClientConfig) Build() (proto.Message, error) {
 	}
 
 	config.Server = serverSpecs
+	config.UdpOverTcp = v.Servers[0].UoT
+	config.UdpOverTcpVersion = uint32(v.Servers[0].UoTVersion)
 
 	return config, nil
 }
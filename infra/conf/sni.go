@@ -0,0 +1,40 @@
+package conf
+
+import (
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/proxy/sni"
+	"google.golang.org/protobuf/proto"
+)
+
+type SNIRoute struct {
+	ServerNames StringList `json:"serverNames"`
+	OutboundTag string     `json:"outboundTag"`
+	Dest        string     `json:"dest"`
+}
+
+type SNIConfig struct {
+	Routes    []*SNIRoute `json:"routes"`
+	UserLevel uint32      `json:"userLevel"`
+}
+
+// Build implements Buildable.
+func (c *SNIConfig) Build() (proto.Message, error) {
+	if len(c.Routes) == 0 {
+		return nil, errors.New("SNI inbound without routes")
+	}
+	config := &sni.Config{UserLevel: c.UserLevel}
+	for _, r := range c.Routes {
+		if r.Dest != "" {
+			if _, _, err := net.SplitHostPort(r.Dest); err != nil {
+				return nil, errors.New("invalid SNI route dest ", r.Dest, ", not host:port").Base(err)
+			}
+		}
+		config.Routes = append(config.Routes, &sni.Route{
+			ServerNames: r.ServerNames,
+			OutboundTag: r.OutboundTag,
+			Dest:        r.Dest,
+		})
+	}
+	return config, nil
+}
@@ -70,11 +70,15 @@ type SocksRemoteConfig struct {
 }
 
 type SocksClientConfig struct {
-	Servers []*SocksRemoteConfig `json:"servers"`
+	Servers    []*SocksRemoteConfig `json:"servers"`
+	UoT        bool                 `json:"uot"`
+	UoTVersion int                  `json:"uotVersion"`
 }
 
 func (v *SocksClientConfig) Build() (proto.Message, error) {
 	config := new(socks.ClientConfig)
+	config.UdpOverTcp = v.UoT
+	config.UdpOverTcpVersion = uint32(v.UoTVersion)
 	config.Server = make([]*protocol.ServerEndpoint, len(v.Servers))
 	for idx, serverConfig := range v.Servers {
 		server := &protocol.ServerEndpoint{
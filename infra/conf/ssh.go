@@ -0,0 +1,60 @@
+package conf
+
+import (
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/proxy/ssh"
+	"google.golang.org/protobuf/proto"
+)
+
+type SSHClientConfig struct {
+	Address              *Address    `json:"address"`
+	Port                 uint16      `json:"port"`
+	User                 string      `json:"user"`
+	Password             string      `json:"password"`
+	PrivateKey           string      `json:"privateKey"`
+	PrivateKeyPassphrase string      `json:"privateKeyPassphrase"`
+	UseAgent             bool        `json:"useAgent"`
+	HostKey              *StringList `json:"hostKey"`
+	HostKeyAlgorithms    *StringList `json:"hostKeyAlgorithms"`
+	ClientVersion        string      `json:"clientVersion"`
+	Level                byte        `json:"level"`
+	InsecureSkipHostKey  bool        `json:"insecureSkipHostKey"`
+}
+
+// Build implements Buildable
+func (c *SSHClientConfig) Build() (proto.Message, error) {
+	if c.Address == nil {
+		return nil, errors.New("SSH server address is not set.")
+	}
+	if c.Port == 0 {
+		c.Port = 22
+	}
+	if c.User == "" {
+		return nil, errors.New("SSH user is not specified.")
+	}
+	if c.Password == "" && c.PrivateKey == "" && !c.UseAgent {
+		return nil, errors.New(`SSH requires one of "password", "privateKey" or "useAgent".`)
+	}
+	config := &ssh.ClientConfig{
+		Address:              c.Address.Build(),
+		Port:                 uint32(c.Port),
+		User:                 c.User,
+		Password:             c.Password,
+		PrivateKey:           c.PrivateKey,
+		PrivateKeyPassphrase: c.PrivateKeyPassphrase,
+		UseAgent:             c.UseAgent,
+		ClientVersion:        c.ClientVersion,
+		Level:                uint32(c.Level),
+		InsecureSkipHostKey:  c.InsecureSkipHostKey,
+	}
+	if c.HostKey != nil {
+		config.HostKey = []string(*c.HostKey)
+	}
+	if len(config.HostKey) == 0 && !c.InsecureSkipHostKey {
+		return nil, errors.New(`SSH requires "hostKey", or "insecureSkipHostKey" to accept any.`)
+	}
+	if c.HostKeyAlgorithms != nil {
+		config.HostKeyAlgorithms = []string(*c.HostKeyAlgorithms)
+	}
+	return config, nil
+}
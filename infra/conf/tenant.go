@@ -0,0 +1,91 @@
+package conf
+
+import (
+	"encoding/json"
+
+	"github.com/xtls/xray-core/app/policy"
+	"github.com/xtls/xray-core/app/router"
+	"github.com/xtls/xray-core/common/errors"
+)
+
+// TenantConfig groups inbounds and users apart from the others, with their
+// own routing rules and hosts, limited in aggregate. Bandwidths are in Mbps,
+// as of QoS.
+type TenantConfig struct {
+	Name           string            `json:"name"`
+	Inbounds       *StringList       `json:"inbounds"`
+	Users          *StringList       `json:"users"`
+	Uplink         uint64            `json:"uplink"`
+	Downlink       uint64            `json:"downlink"`
+	MaxConnections uint32            `json:"maxConnections"`
+	Hosts          map[string]string `json:"hosts"`
+	Rules          []json.RawMessage `json:"rules"`
+}
+
+// Build returns the tenant, and its routing rules, which match only its
+// connections.
+func (c *TenantConfig) Build() (*policy.SystemPolicy_Tenant, []*router.RoutingRule, error) {
+	if c.Name == "" {
+		return nil, nil, errors.New("tenant requires name")
+	}
+	config := &policy.SystemPolicy_Tenant{
+		Name:           c.Name,
+		Uplink:         c.Uplink * 1000 * 1000 / 8,
+		Downlink:       c.Downlink * 1000 * 1000 / 8,
+		MaxConnections: c.MaxConnections,
+		Hosts:          c.Hosts,
+	}
+	if c.Inbounds != nil {
+		config.InboundTag = *c.Inbounds
+	}
+	if c.Users != nil {
+		config.UserEmail = *c.Users
+	}
+	if len(config.InboundTag) == 0 && len(config.UserEmail) == 0 {
+		return nil, nil, errors.New("tenant ", c.Name, " has no inbounds or users")
+	}
+	var rules []*router.RoutingRule
+	for _, msg := range c.Rules {
+		rule, err := ParseRule(msg)
+		if err != nil {
+			return nil, nil, errors.New("invalid rule of tenant ", c.Name).Base(err)
+		}
+		rule.Tenant = []string{c.Name}
+		rules = append(rules, rule)
+	}
+	return config, rules, nil
+}
+
+// buildTenants returns the tenants of configs, and their routing rules.
+func buildTenants(configs []*TenantConfig) ([]*policy.SystemPolicy_Tenant, []*router.RoutingRule, error) {
+	var tenants []*policy.SystemPolicy_Tenant
+	var rules []*router.RoutingRule
+	names := make(map[string]bool)
+	inbounds := make(map[string]string)
+	users := make(map[string]string)
+	for _, c := range configs {
+		tenant, r, err := c.Build()
+		if err != nil {
+			return nil, nil, err
+		}
+		if names[tenant.Name] {
+			return nil, nil, errors.New("duplicated tenant ", tenant.Name)
+		}
+		names[tenant.Name] = true
+		for _, tag := range tenant.InboundTag {
+			if other, ok := inbounds[tag]; ok {
+				return nil, nil, errors.New("inbound ", tag, " is of tenants ", other, " and ", tenant.Name)
+			}
+			inbounds[tag] = tenant.Name
+		}
+		for _, email := range tenant.UserEmail {
+			if other, ok := users[email]; ok {
+				return nil, nil, errors.New("user ", email, " is of tenants ", other, " and ", tenant.Name)
+			}
+			users[email] = tenant.Name
+		}
+		tenants = append(tenants, tenant)
+		rules = append(rules, r...)
+	}
+	return tenants, rules, nil
+}
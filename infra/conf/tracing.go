@@ -0,0 +1,40 @@
+package conf
+
+import (
+	"github.com/xtls/xray-core/app/tracing"
+	"github.com/xtls/xray-core/common/errors"
+)
+
+type TracingConfig struct {
+	Endpoint    string            `json:"endpoint"`
+	Protocol    string            `json:"protocol"`
+	Insecure    bool              `json:"insecure"`
+	Headers     map[string]string `json:"headers"`
+	ServiceName string            `json:"serviceName"`
+	SampleRatio *float64          `json:"sampleRatio"`
+}
+
+func (c *TracingConfig) Build() (*tracing.Config, error) {
+	if c.Endpoint == "" {
+		return nil, errors.New("Tracing must have an endpoint.")
+	}
+	config := &tracing.Config{
+		Endpoint:    c.Endpoint,
+		Protocol:    c.Protocol,
+		Insecure:    c.Insecure,
+		Header:      c.Headers,
+		ServiceName: c.ServiceName,
+	}
+	switch c.Protocol {
+	case "", "grpc", "http":
+	default:
+		return nil, errors.New("Unknown tracing protocol: ", c.Protocol)
+	}
+	if c.SampleRatio != nil {
+		if *c.SampleRatio <= 0 || *c.SampleRatio > 1 {
+			return nil, errors.New("Tracing sampleRatio must be in (0, 1].")
+		}
+		config.SampleRatio = *c.SampleRatio
+	}
+	return config, nil
+}
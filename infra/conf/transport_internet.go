@@ -1,6 +1,8 @@
 package conf
 
 import (
+	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -18,6 +20,9 @@ import (
 	"github.com/xtls/xray-core/transport/internet"
 	"github.com/xtls/xray-core/transport/internet/httpupgrade"
 	"github.com/xtls/xray-core/transport/internet/kcp"
+	"github.com/xtls/xray-core/transport/internet/padding"
+	"github.com/xtls/xray-core/transport/internet/plugin"
+	"github.com/xtls/xray-core/transport/internet/quic"
 	"github.com/xtls/xray-core/transport/internet/reality"
 	"github.com/xtls/xray-core/transport/internet/splithttp"
 	"github.com/xtls/xray-core/transport/internet/tcp"
@@ -44,15 +49,20 @@ var (
 )
 
 type KCPConfig struct {
-	Mtu             *uint32         `json:"mtu"`
-	Tti             *uint32         `json:"tti"`
-	UpCap           *uint32         `json:"uplinkCapacity"`
-	DownCap         *uint32         `json:"downlinkCapacity"`
-	Congestion      *bool           `json:"congestion"`
-	ReadBufferSize  *uint32         `json:"readBufferSize"`
-	WriteBufferSize *uint32         `json:"writeBufferSize"`
-	HeaderConfig    json.RawMessage `json:"header"`
-	Seed            *string         `json:"seed"`
+	Mtu               *uint32         `json:"mtu"`
+	Tti               *uint32         `json:"tti"`
+	UpCap             *uint32         `json:"uplinkCapacity"`
+	DownCap           *uint32         `json:"downlinkCapacity"`
+	Congestion        *bool           `json:"congestion"`
+	ReadBufferSize    *uint32         `json:"readBufferSize"`
+	WriteBufferSize   *uint32         `json:"writeBufferSize"`
+	HeaderConfig      json.RawMessage `json:"header"`
+	Seed              *string         `json:"seed"`
+	DataShards        uint32          `json:"dataShards"`
+	ParityShards      uint32          `json:"parityShards"`
+	SendWindow        uint32          `json:"sendWindow"`
+	ReceiveWindow     uint32          `json:"receiveWindow"`
+	CongestionControl string          `json:"congestionControl"`
 }
 
 // Build implements Buildable.
@@ -114,9 +124,72 @@ func (c *KCPConfig) Build() (proto.Message, error) {
 		config.Seed = &kcp.EncryptionSeed{Seed: *c.Seed}
 	}
 
+	if c.ParityShards > 0 {
+		if c.DataShards == 0 || c.DataShards+c.ParityShards > 255 {
+			return nil, errors.New("invalid mKCP FEC shards: ", c.DataShards, "+", c.ParityShards).AtError()
+		}
+		config.DataShards = c.DataShards
+		config.ParityShards = c.ParityShards
+	}
+	config.SendWindow = c.SendWindow
+	config.ReceiveWindow = c.ReceiveWindow
+	switch strings.ToLower(c.CongestionControl) {
+	case "", "loss":
+	case "bbr":
+		config.CongestionControl = "bbr"
+		config.Congestion = true
+	default:
+		return nil, errors.New("unknown mKCP congestion control: ", c.CongestionControl).AtError()
+	}
+
 	return config, nil
 }
 
+type QUICConfig struct {
+	ZeroRTT            bool  `json:"zeroRtt"`
+	MaxIdleTimeout     int32 `json:"maxIdleTimeout"`
+	KeepAlivePeriod    int32 `json:"keepAlivePeriod"`
+	MaxIncomingStreams int32 `json:"maxIncomingStreams"`
+	HTTP3              bool  `json:"http3"`
+}
+
+// Build implements Buildable.
+func (c *QUICConfig) Build() (proto.Message, error) {
+	if c.MaxIdleTimeout < 0 {
+		return nil, errors.New("invalid QUIC maxIdleTimeout: ", c.MaxIdleTimeout)
+	}
+	if c.MaxIncomingStreams < 0 {
+		return nil, errors.New("invalid QUIC maxIncomingStreams: ", c.MaxIncomingStreams)
+	}
+	return &quic.Config{
+		ZeroRtt:            c.ZeroRTT,
+		MaxIdleTimeout:     c.MaxIdleTimeout,
+		KeepAlivePeriod:    c.KeepAlivePeriod,
+		MaxIncomingStreams: c.MaxIncomingStreams,
+		Http3:              c.HTTP3,
+	}, nil
+}
+
+type PluginConfig struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	Options string   `json:"options"`
+	Address string   `json:"address"`
+}
+
+// Build implements Buildable.
+func (c *PluginConfig) Build() (proto.Message, error) {
+	if (c.Command == "") == (c.Address == "") {
+		return nil, errors.New(`plugin requires either "command" or "address"`)
+	}
+	return &plugin.Config{
+		Command: c.Command,
+		Args:    c.Args,
+		Options: c.Options,
+		Address: c.Address,
+	}, nil
+}
+
 type TCPConfig struct {
 	HeaderConfig        json.RawMessage `json:"header"`
 	AcceptProxyProtocol bool            `json:"acceptProxyProtocol"`
@@ -148,6 +221,7 @@ type WebSocketConfig struct {
 	Headers             map[string]string `json:"headers"`
 	AcceptProxyProtocol bool              `json:"acceptProxyProtocol"`
 	HeartbeatPeriod     uint32            `json:"heartbeatPeriod"`
+	Mode                string            `json:"mode"`
 }
 
 // Build implements Buildable.
@@ -173,6 +247,11 @@ func (c *WebSocketConfig) Build() (proto.Message, error) {
 			delete(c.Headers, k)
 		}
 	}
+	switch c.Mode {
+	case "", "h2", "h3":
+	default:
+		return nil, errors.New("unsupported WebSocket mode: ", c.Mode)
+	}
 	config := &websocket.Config{
 		Path:                path,
 		Host:                c.Host,
@@ -180,14 +259,17 @@ func (c *WebSocketConfig) Build() (proto.Message, error) {
 		AcceptProxyProtocol: c.AcceptProxyProtocol,
 		Ed:                  ed,
 		HeartbeatPeriod:     c.HeartbeatPeriod,
+		Mode:                c.Mode,
 	}
 	return config, nil
 }
 
 type HttpUpgradeConfig struct {
 	Host                string            `json:"host"`
+	Hosts               []string          `json:"hosts"`
 	Path                string            `json:"path"`
 	Headers             map[string]string `json:"headers"`
+	ResponseHeaders     map[string]string `json:"responseHeaders"`
 	AcceptProxyProtocol bool              `json:"acceptProxyProtocol"`
 }
 
@@ -201,19 +283,31 @@ func (c *HttpUpgradeConfig) Build() (proto.Message, error) {
 			ed = uint32(Ed)
 			q.Del("ed")
 			u.RawQuery = q.Encode()
-			path = u.String()
+			// Keeps the placeholders of the path unescaped.
+			path = strings.SplitN(path, "?", 2)[0]
+			if u.RawQuery != "" {
+				path += "?" + u.RawQuery
+			}
 		}
 	}
-	// Priority (client): host > serverName > address
+	// Priority (client): hosts > host > serverName > address
 	for k := range c.Headers {
 		if strings.ToLower(k) == "host" {
 			return nil, errors.New(`"headers" can't contain "host"`)
 		}
 	}
+	for k := range c.ResponseHeaders {
+		switch strings.ToLower(k) {
+		case "connection", "upgrade":
+			return nil, errors.New(`"responseHeaders" can't contain "`, k, `"`)
+		}
+	}
 	config := &httpupgrade.Config{
 		Path:                path,
 		Host:                c.Host,
+		Hosts:               c.Hosts,
 		Header:              c.Headers,
+		ResponseHeader:      c.ResponseHeaders,
 		AcceptProxyProtocol: c.AcceptProxyProtocol,
 		Ed:                  ed,
 	}
@@ -343,19 +437,118 @@ func readFileOrString(f string, s []string) ([]byte, error) {
 }
 
 type TLSCertConfig struct {
-	CertFile       string   `json:"certificateFile"`
-	CertStr        []string `json:"certificate"`
-	KeyFile        string   `json:"keyFile"`
-	KeyStr         []string `json:"key"`
-	Usage          string   `json:"usage"`
-	OcspStapling   uint64   `json:"ocspStapling"`
-	OneTimeLoading bool     `json:"oneTimeLoading"`
-	BuildChain     bool     `json:"buildChain"`
+	CertFile       string      `json:"certificateFile"`
+	CertStr        []string    `json:"certificate"`
+	KeyFile        string      `json:"keyFile"`
+	KeyStr         []string    `json:"key"`
+	Usage          string      `json:"usage"`
+	OcspStapling   uint64      `json:"ocspStapling"`
+	OcspCache      string      `json:"ocspCache"`
+	OneTimeLoading bool        `json:"oneTimeLoading"`
+	BuildChain     bool        `json:"buildChain"`
+	ACME           *ACMEConfig `json:"acme"`
+}
+
+var acmeDirectories = map[string]string{
+	"":                    "https://acme-v02.api.letsencrypt.org/directory",
+	"letsencrypt":         "https://acme-v02.api.letsencrypt.org/directory",
+	"letsencrypt-staging": "https://acme-staging-v02.api.letsencrypt.org/directory",
+	"buypass":             "https://api.buypass.com/acme/directory",
+	"google":              "https://dv.acme-v02.api.pki.goog/directory",
+}
+
+type ACMEHTTP01Config struct {
+	Listen string `json:"listen"`
+}
+
+type ACMEDNS01Config struct {
+	Provider string            `json:"provider"`
+	Options  map[string]string `json:"options"`
+}
+
+type ACMEConfig struct {
+	Domains  []string          `json:"domains"`
+	Email    string            `json:"email"`
+	Provider string            `json:"provider"`
+	Storage  string            `json:"storage"`
+	HTTP01   *ACMEHTTP01Config `json:"http01"`
+	DNS01    *ACMEDNS01Config  `json:"dns01"`
+}
+
+// Build implements Buildable.
+func (c *ACMEConfig) Build() (*tls.Acme, error) {
+	config := &tls.Acme{
+		Email:       c.Email,
+		StoragePath: c.Storage,
+	}
+	if len(c.Domains) == 0 {
+		return nil, errors.New(`empty "domains"`)
+	}
+	for _, domain := range c.Domains {
+		domain = strings.ToLower(domain)
+		if !net.ParseAddress(domain).Family().IsDomain() {
+			return nil, errors.New(`invalid domain in "domains": `, domain)
+		}
+		if strings.Contains(domain, "*") && c.DNS01 == nil {
+			return nil, errors.New(`wildcard domain `, domain, ` requires "dns01"`)
+		}
+		config.Domains = append(config.Domains, domain)
+	}
+	if strings.Contains(c.Provider, "://") {
+		config.DirectoryUrl = c.Provider
+	} else if config.DirectoryUrl = acmeDirectories[strings.ToLower(c.Provider)]; config.DirectoryUrl == "" {
+		return nil, errors.New(`unknown ACME "provider": `, c.Provider)
+	}
+	if config.StoragePath == "" {
+		config.StoragePath = "acme"
+	}
+	if c.DNS01 != nil {
+		if c.HTTP01 != nil {
+			return nil, errors.New(`"http01" and "dns01" can not be both set`)
+		}
+		if !tls.IsACMEDNSProviderRegistered(c.DNS01.Provider) {
+			return nil, errors.New(`unknown "dns01" provider: `, c.DNS01.Provider)
+		}
+		config.Dns01 = &tls.AcmeDns01{
+			Provider: c.DNS01.Provider,
+			Options:  c.DNS01.Options,
+		}
+	} else {
+		config.Http01Listen = ":80"
+		if c.HTTP01 != nil && c.HTTP01.Listen != "" {
+			config.Http01Listen = c.HTTP01.Listen
+		}
+		if _, _, err := net.SplitHostPort(config.Http01Listen); err != nil {
+			return nil, errors.New(`invalid "http01.listen": `, config.Http01Listen).Base(err)
+		}
+	}
+	return config, nil
 }
 
 // Build implements Buildable.
 func (c *TLSCertConfig) Build() (*tls.Certificate, error) {
 	certificate := new(tls.Certificate)
+	if c.OcspCache != "" && c.OcspStapling == 0 {
+		return nil, errors.New(`"ocspCache" requires "ocspStapling"`)
+	}
+	certificate.OcspStapling = c.OcspStapling
+	certificate.OcspCache = c.OcspCache
+
+	if c.ACME != nil {
+		if c.CertFile != "" || len(c.CertStr) > 0 || c.KeyFile != "" || len(c.KeyStr) > 0 {
+			return nil, errors.New(`"acme" can not be set along with a certificate or key`)
+		}
+		if c.Usage != "" && strings.ToLower(c.Usage) != "encipherment" {
+			return nil, errors.New(`"acme" certificates are only for "encipherment"`)
+		}
+		acme, err := c.ACME.Build()
+		if err != nil {
+			return nil, errors.New("failed to build ACME config").Base(err)
+		}
+		certificate.Acme = acme
+		certificate.OneTimeLoading = true
+		return certificate, nil
+	}
 
 	cert, err := readFileOrString(c.CertFile, c.CertStr)
 	if err != nil {
@@ -388,34 +581,94 @@ func (c *TLSCertConfig) Build() (*tls.Certificate, error) {
 	} else {
 		certificate.OneTimeLoading = c.OneTimeLoading
 	}
-	certificate.OcspStapling = c.OcspStapling
 	certificate.BuildChain = c.BuildChain
 
 	return certificate, nil
 }
 
+type TLSClientVerificationConfig struct {
+	CAFile            string      `json:"caFile"`
+	CAStr             []string    `json:"ca"`
+	CertificateSha256 []string    `json:"certificateSha256"`
+	Subjects          *StringList `json:"subjects"`
+	CRLFiles          *StringList `json:"crlFile"`
+	OCSP              bool        `json:"ocsp"`
+}
+
+// Build implements Buildable.
+func (c *TLSClientVerificationConfig) Build() (*tls.ClientVerification, error) {
+	config := new(tls.ClientVerification)
+	if c.CAFile != "" || len(c.CAStr) > 0 {
+		ca, err := readFileOrString(c.CAFile, c.CAStr)
+		if err != nil {
+			return nil, errors.New("failed to read client CA").Base(err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(ca) {
+			return nil, errors.New("no valid client CA certificate")
+		}
+		config.CertificateAuthority = ca
+	}
+	for _, v := range c.CertificateSha256 {
+		hashValue, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(hashValue) != sha256.Size {
+			return nil, errors.New(`invalid "certificateSha256": `, v)
+		}
+		config.CertificateSha256 = append(config.CertificateSha256, hashValue)
+	}
+	if config.CertificateAuthority == nil && config.CertificateSha256 == nil {
+		return nil, errors.New(`"ca" or "certificateSha256" must be set to verify client certificates`)
+	}
+	if c.Subjects != nil {
+		config.Subjects = *c.Subjects
+	}
+	if c.CRLFiles != nil {
+		for _, path := range *c.CRLFiles {
+			crl, err := filesystem.ReadCert(path)
+			if err != nil {
+				return nil, errors.New("failed to read CRL ", path).Base(err)
+			}
+			if _, err := tls.ParseCRLs(crl); err != nil {
+				return nil, errors.New("invalid CRL ", path).Base(err)
+			}
+			config.Crl = append(config.Crl, crl)
+		}
+	}
+	config.Ocsp = c.OCSP
+	if (config.Crl != nil || config.Ocsp) && config.CertificateAuthority == nil {
+		return nil, errors.New(`"crlFile" and "ocsp" require "ca"`)
+	}
+	return config, nil
+}
+
 type TLSConfig struct {
-	Insecure                             bool             `json:"allowInsecure"`
-	Certs                                []*TLSCertConfig `json:"certificates"`
-	ServerName                           string           `json:"serverName"`
-	ALPN                                 *StringList      `json:"alpn"`
-	EnableSessionResumption              bool             `json:"enableSessionResumption"`
-	DisableSystemRoot                    bool             `json:"disableSystemRoot"`
-	MinVersion                           string           `json:"minVersion"`
-	MaxVersion                           string           `json:"maxVersion"`
-	CipherSuites                         string           `json:"cipherSuites"`
-	Fingerprint                          string           `json:"fingerprint"`
-	RejectUnknownSNI                     bool             `json:"rejectUnknownSni"`
-	PinnedPeerCertificateChainSha256     *[]string        `json:"pinnedPeerCertificateChainSha256"`
-	PinnedPeerCertificatePublicKeySha256 *[]string        `json:"pinnedPeerCertificatePublicKeySha256"`
-	CurvePreferences                     *StringList      `json:"curvePreferences"`
-	MasterKeyLog                         string           `json:"masterKeyLog"`
-	ServerNameToVerify                   string           `json:"serverNameToVerify"`
-	VerifyPeerCertInNames                []string         `json:"verifyPeerCertInNames"`
-	ECHServerKeys                        string           `json:"echServerKeys"`
-	ECHConfigList                        string           `json:"echConfigList"`
-	ECHForceQuery                        string           `json:"echForceQuery"`
-	ECHSocketSettings                    *SocketConfig    `json:"echSockopt"`
+	Insecure                             bool                         `json:"allowInsecure"`
+	Certs                                []*TLSCertConfig             `json:"certificates"`
+	ServerName                           string                       `json:"serverName"`
+	ALPN                                 *StringList                  `json:"alpn"`
+	EnableSessionResumption              bool                         `json:"enableSessionResumption"`
+	DisableSystemRoot                    bool                         `json:"disableSystemRoot"`
+	MinVersion                           string                       `json:"minVersion"`
+	MaxVersion                           string                       `json:"maxVersion"`
+	CipherSuites                         string                       `json:"cipherSuites"`
+	Fingerprint                          string                       `json:"fingerprint"`
+	RejectUnknownSNI                     bool                         `json:"rejectUnknownSni"`
+	PinnedPeerCertificateChainSha256     *[]string                    `json:"pinnedPeerCertificateChainSha256"`
+	PinnedPeerCertificatePublicKeySha256 *[]string                    `json:"pinnedPeerCertificatePublicKeySha256"`
+	CurvePreferences                     *StringList                  `json:"curvePreferences"`
+	MasterKeyLog                         string                       `json:"masterKeyLog"`
+	ServerNameToVerify                   string                       `json:"serverNameToVerify"`
+	VerifyPeerCertInNames                []string                     `json:"verifyPeerCertInNames"`
+	ECHServerKeys                        string                       `json:"echServerKeys"`
+	ECHServerKeysFile                    string                       `json:"echServerKeysFile"`
+	ECHConfigList                        string                       `json:"echConfigList"`
+	ECHForceQuery                        string                       `json:"echForceQuery"`
+	ECHSocketSettings                    *SocketConfig                `json:"echSockopt"`
+	VerifyClientCertificate              *TLSClientVerificationConfig `json:"verifyClientCertificate"`
+	SessionTicketKeys                    []string                     `json:"sessionTicketKeys"`
+	SessionTicketKeyRotation             uint64                       `json:"sessionTicketKeyRotation"`
+	SessionCacheSize                     uint32                       `json:"sessionCacheSize"`
+	ALPNOrder                            string                       `json:"alpnOrder"`
+	AllowALPNMismatch                    bool                         `json:"allowAlpnMismatch"`
 }
 
 // Build implements Buildable.
@@ -444,10 +697,32 @@ func (c *TLSConfig) Build() (proto.Message, error) {
 			}
 		}
 	}
+	switch c.ALPNOrder {
+	case "", "server", "client":
+		config.AlpnOrder = c.ALPNOrder
+	default:
+		return nil, errors.New(`unknown "alpnOrder": `, c.ALPNOrder)
+	}
+	config.AllowAlpnMismatch = c.AllowALPNMismatch
 	if c.CurvePreferences != nil && len(*c.CurvePreferences) > 0 {
 		config.CurvePreferences = []string(*c.CurvePreferences)
 	}
 	config.EnableSessionResumption = c.EnableSessionResumption
+	for _, v := range c.SessionTicketKeys {
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(key) != 32 {
+			return nil, errors.New(`invalid "sessionTicketKeys": `, v, ", 32 bytes in base64 expected")
+		}
+		config.SessionTicketKeys = append(config.SessionTicketKeys, key)
+	}
+	if c.SessionTicketKeyRotation != 0 && c.SessionTicketKeyRotation < 60 {
+		return nil, errors.New(`"sessionTicketKeyRotation" must be at least 60 seconds`)
+	}
+	config.SessionTicketKeyRotation = c.SessionTicketKeyRotation
+	config.SessionCacheSize = c.SessionCacheSize
+	if !c.EnableSessionResumption && (config.SessionTicketKeys != nil || c.SessionTicketKeyRotation != 0 || c.SessionCacheSize != 0) {
+		return nil, errors.New(`"sessionTicketKeys", "sessionTicketKeyRotation" and "sessionCacheSize" require "enableSessionResumption"`)
+	}
 	config.DisableSystemRoot = c.DisableSystemRoot
 	config.MinVersion = c.MinVersion
 	config.MaxVersion = c.MaxVersion
@@ -494,6 +769,15 @@ func (c *TLSConfig) Build() (proto.Message, error) {
 		}
 		config.EchServerKeys = EchPrivateKey
 	}
+	if c.ECHServerKeysFile != "" {
+		if c.ECHServerKeys != "" {
+			return nil, errors.New(`"echServerKeys" and "echServerKeysFile" can not be both set`)
+		}
+		if _, err := tls.ReadECHServerKeys(c.ECHServerKeysFile); err != nil {
+			return nil, errors.New(`invalid "echServerKeysFile": `, c.ECHServerKeysFile).Base(err)
+		}
+		config.EchServerKeysPath = c.ECHServerKeysFile
+	}
 	switch c.ECHForceQuery {
 	case "none", "half", "full", "":
 		config.EchForceQuery = c.ECHForceQuery
@@ -509,6 +793,13 @@ func (c *TLSConfig) Build() (proto.Message, error) {
 		}
 		config.EchSocketSettings = ss
 	}
+	if c.VerifyClientCertificate != nil {
+		verification, err := c.VerifyClientCertificate.Build()
+		if err != nil {
+			return nil, errors.New(`failed to build "verifyClientCertificate"`).Base(err)
+		}
+		config.VerifyClientCertificate = verification
+	}
 
 	return config, nil
 }
@@ -534,16 +825,75 @@ type REALITYConfig struct {
 	ShortIds     []string        `json:"shortIds"`
 	Mldsa65Seed  string          `json:"mldsa65Seed"`
 
+	Targets       []REALITYTarget `json:"targets"`
+	CheckInterval uint64          `json:"checkInterval"`
+
 	LimitFallbackUpload   LimitFallback `json:"limitFallbackUpload"`
 	LimitFallbackDownload LimitFallback `json:"limitFallbackDownload"`
 
-	Fingerprint   string `json:"fingerprint"`
-	ServerName    string `json:"serverName"`
-	Password      string `json:"password"`
-	PublicKey     string `json:"publicKey"`
-	ShortId       string `json:"shortId"`
-	Mldsa65Verify string `json:"mldsa65Verify"`
-	SpiderX       string `json:"spiderX"`
+	Fingerprint         string   `json:"fingerprint"`
+	Fingerprints        []string `json:"fingerprints"`
+	FingerprintInterval uint64   `json:"fingerprintInterval"`
+	CurvePreferences    []string `json:"curvePreferences"`
+	ServerName          string   `json:"serverName"`
+	Password            string   `json:"password"`
+	PublicKey           string   `json:"publicKey"`
+	ShortId             string   `json:"shortId"`
+	Mldsa65Verify       string   `json:"mldsa65Verify"`
+	SpiderX             string   `json:"spiderX"`
+}
+
+// REALITYTarget is a website served to the clients asking for its server names
+// instead of the target of the inbound.
+type REALITYTarget struct {
+	Target      json.RawMessage `json:"target"`
+	Dest        json.RawMessage `json:"dest"`
+	Type        string          `json:"type"`
+	Xver        uint64          `json:"xver"`
+	ServerNames []string        `json:"serverNames"`
+	ShortIds    []string        `json:"shortIds"`
+}
+
+// parseREALITYTarget returns the address of a REALITY target and its network,
+// which is detected when typ is empty and left empty if dest is invalid.
+func parseREALITYTarget(dest json.RawMessage, typ string) (string, string) {
+	var i uint16
+	var s string
+	if err := json.Unmarshal(dest, &i); err == nil {
+		s = strconv.Itoa(int(i))
+	} else {
+		_ = json.Unmarshal(dest, &s)
+	}
+	if typ == "" && s != "" {
+		switch s[0] {
+		case '@', '/':
+			typ = "unix"
+			if s[0] == '@' && len(s) > 1 && s[1] == '@' && (runtime.GOOS == "linux" || runtime.GOOS == "android") {
+				fullAddr := make([]byte, len(syscall.RawSockaddrUnix{}.Path)) // may need padding to work with haproxy
+				copy(fullAddr, s[1:])
+				s = string(fullAddr)
+			}
+		default:
+			if _, err := strconv.Atoi(s); err == nil {
+				s = "localhost:" + s
+			}
+			if _, _, err := net.SplitHostPort(s); err == nil {
+				typ = "tcp"
+			}
+		}
+	}
+	return s, typ
+}
+
+func parseREALITYShortIds(shortIds []string, field string) ([][]byte, error) {
+	ids := make([][]byte, len(shortIds))
+	for i, s := range shortIds {
+		ids[i] = make([]byte, 8)
+		if _, err := hex.Decode(ids[i], []byte(s)); err != nil {
+			return nil, errors.New(`invalid "`, field, `[`, i, `]": `, s)
+		}
+	}
+	return ids, nil
 }
 
 func (c *REALITYConfig) Build() (proto.Message, error) {
@@ -555,32 +905,8 @@ func (c *REALITYConfig) Build() (proto.Message, error) {
 		c.Dest = c.Target
 	}
 	if c.Dest != nil {
-		var i uint16
 		var s string
-		if err = json.Unmarshal(c.Dest, &i); err == nil {
-			s = strconv.Itoa(int(i))
-		} else {
-			_ = json.Unmarshal(c.Dest, &s)
-		}
-		if c.Type == "" && s != "" {
-			switch s[0] {
-			case '@', '/':
-				c.Type = "unix"
-				if s[0] == '@' && len(s) > 1 && s[1] == '@' && (runtime.GOOS == "linux" || runtime.GOOS == "android") {
-					fullAddr := make([]byte, len(syscall.RawSockaddrUnix{}.Path)) // may need padding to work with haproxy
-					copy(fullAddr, s[1:])
-					s = string(fullAddr)
-				}
-			default:
-				if _, err = strconv.Atoi(s); err == nil {
-					s = "localhost:" + s
-				}
-				if _, _, err = net.SplitHostPort(s); err == nil {
-					c.Type = "tcp"
-				}
-			}
-		}
-		if c.Type == "" {
+		if s, c.Type = parseREALITYTarget(c.Dest, c.Type); c.Type == "" {
 			return nil, errors.New(`please fill in a valid value for "target"`)
 		}
 		if c.Xver > 2 {
@@ -626,12 +952,8 @@ func (c *REALITYConfig) Build() (proto.Message, error) {
 		if len(c.ShortIds) == 0 {
 			return nil, errors.New(`empty "shortIds"`)
 		}
-		config.ShortIds = make([][]byte, len(c.ShortIds))
-		for i, s := range c.ShortIds {
-			config.ShortIds[i] = make([]byte, 8)
-			if _, err = hex.Decode(config.ShortIds[i], []byte(s)); err != nil {
-				return nil, errors.New(`invalid "shortIds[`, i, `]": `, s)
-			}
+		if config.ShortIds, err = parseREALITYShortIds(c.ShortIds, "shortIds"); err != nil {
+			return nil, err
 		}
 		config.Dest = s
 		config.Type = c.Type
@@ -639,6 +961,43 @@ func (c *REALITYConfig) Build() (proto.Message, error) {
 		config.ServerNames = c.ServerNames
 		config.MaxTimeDiff = c.MaxTimeDiff
 
+		served := make(map[string]bool)
+		for _, serverName := range c.ServerNames {
+			served[serverName] = true
+		}
+		for i, t := range c.Targets {
+			target := new(reality.Target)
+			if t.Target != nil {
+				t.Dest = t.Target
+			}
+			if t.Dest == nil {
+				return nil, errors.New(`empty "targets[`, i, `].target"`)
+			}
+			if target.Dest, target.Type = parseREALITYTarget(t.Dest, t.Type); target.Type == "" {
+				return nil, errors.New(`please fill in a valid value for "targets[`, i, `].target"`)
+			}
+			if t.Xver > 2 {
+				return nil, errors.New(`invalid PROXY protocol version, "targets[`, i, `].xver" only accepts 0, 1, 2`)
+			}
+			target.Xver = t.Xver
+			if len(t.ServerNames) == 0 {
+				return nil, errors.New(`empty "targets[`, i, `].serverNames"`)
+			}
+			for _, serverName := range t.ServerNames {
+				if served[serverName] {
+					return nil, errors.New(`server name "`, serverName, `" of "targets[`, i, `]" is already served`)
+				}
+				served[serverName] = true
+			}
+			target.ServerNames = t.ServerNames
+			// Without shortIds of its own, a target accepts those of the inbound.
+			if target.ShortIds, err = parseREALITYShortIds(t.ShortIds, "targets["+strconv.Itoa(i)+"].shortIds"); err != nil {
+				return nil, err
+			}
+			config.Targets = append(config.Targets, target)
+		}
+		config.CheckInterval = c.CheckInterval
+
 		if c.Mldsa65Seed != "" {
 			if c.Mldsa65Seed == c.PrivateKey {
 				return nil, errors.New(`"mldsa65Seed" and "privateKey" can not be the same value: `, c.Mldsa65Seed)
@@ -664,9 +1023,36 @@ func (c *REALITYConfig) Build() (proto.Message, error) {
 		if tls.GetFingerprint(config.Fingerprint) == nil {
 			return nil, errors.New(`unknown "fingerprint": `, config.Fingerprint)
 		}
+		for i, fingerprint := range c.Fingerprints {
+			fingerprint = strings.ToLower(fingerprint)
+			if fingerprint == "unsafe" || fingerprint == "hellogolang" {
+				return nil, errors.New(`invalid "fingerprints[`, i, `]": `, fingerprint)
+			}
+			if tls.GetFingerprint(fingerprint) == nil {
+				return nil, errors.New(`unknown "fingerprints[`, i, `]": `, fingerprint)
+			}
+			config.Fingerprints = append(config.Fingerprints, fingerprint)
+		}
+		if c.FingerprintInterval != 0 && len(c.Fingerprints) == 0 {
+			return nil, errors.New(`"fingerprintInterval" requires "fingerprints"`)
+		}
+		config.FingerprintInterval = c.FingerprintInterval
+		if len(c.CurvePreferences) > 0 {
+			// REALITY authenticates with the X25519 part of the first key share.
+			if first := strings.ToLower(c.CurvePreferences[0]); first != "x25519" && first != "x25519mlkem768" {
+				return nil, errors.New(`"curvePreferences" of REALITY must start with "x25519" or "x25519mlkem768"`)
+			}
+			if len(tls.ParseCurveName(c.CurvePreferences)) != len(c.CurvePreferences) {
+				return nil, errors.New(`unknown curve in "curvePreferences": `, strings.Join(c.CurvePreferences, ","))
+			}
+			config.CurvePreferences = c.CurvePreferences
+		}
 		if len(c.ServerNames) != 0 {
 			return nil, errors.New(`non-empty "serverNames", please use "serverName" instead`)
 		}
+		if c.CheckInterval != 0 {
+			return nil, errors.New(`"checkInterval" is only for servers`)
+		}
 		if c.Password != "" {
 			c.PublicKey = c.Password
 		}
@@ -747,7 +1133,9 @@ func (p TransportProtocol) Build() (string, error) {
 	case "h2", "h3", "http":
 		return "", errors.PrintRemovedFeatureError("HTTP transport (without header padding, etc.)", "XHTTP stream-one H2 & H3")
 	case "quic":
-		return "", errors.PrintRemovedFeatureError("QUIC transport (without web service, etc.)", "XHTTP stream-one H3")
+		return "quic", nil
+	case "plugin":
+		return "plugin", nil
 	default:
 		return "", errors.New("Config: unknown transport protocol: ", p)
 	}
@@ -786,18 +1174,109 @@ func (h *HappyEyeballsConfig) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+var paddingPresets = map[string]*padding.Config{
+	"light": {
+		Buckets:    []uint32{128, 256, 512, 1024, 1400},
+		Packets:    8,
+		NoiseCount: 1,
+		NoiseMin:   16,
+		NoiseMax:   64,
+	},
+	"medium": {
+		Buckets:    []uint32{256, 512, 1024, 1400},
+		Packets:    16,
+		NoiseCount: 2,
+		NoiseMin:   64,
+		NoiseMax:   256,
+		Jitter:     10,
+	},
+	"heavy": {
+		Buckets:    []uint32{512, 1400},
+		Packets:    64,
+		NoiseCount: 4,
+		NoiseMin:   128,
+		NoiseMax:   1024,
+		Jitter:     20,
+	},
+}
+
+// PaddingConfig is of the padding of streams, into "buckets". It is the one of
+// the "preset" if any, with the fields set in place of its ones, and may be
+// just the name of a preset.
+type PaddingConfig struct {
+	Preset      string      `json:"preset"`
+	Buckets     []uint32    `json:"buckets"`
+	Packets     *uint32     `json:"packets"`
+	NoiseCount  *uint32     `json:"noiseCount"`
+	NoiseLength *Int32Range `json:"noiseLength"`
+	Jitter      *uint32     `json:"jitter"`
+}
+
+func (c *PaddingConfig) UnmarshalJSON(data []byte) error {
+	var preset string
+	if err := json.Unmarshal(data, &preset); err == nil {
+		c.Preset = preset
+		return nil
+	}
+	type config PaddingConfig
+	return json.Unmarshal(data, (*config)(c))
+}
+
+// Build implements Buildable.
+func (c *PaddingConfig) Build() (*padding.Config, error) {
+	config := new(padding.Config)
+	if c.Preset != "" {
+		preset, found := paddingPresets[strings.ToLower(c.Preset)]
+		if !found {
+			return nil, errors.New(`unknown "preset": `, c.Preset)
+		}
+		config = proto.Clone(preset).(*padding.Config)
+	}
+	if c.Buckets != nil {
+		config.Buckets = c.Buckets
+	}
+	for i, b := range config.Buckets {
+		if b <= 4 || b > 0xffff || i > 0 && b <= config.Buckets[i-1] {
+			return nil, errors.New(`"buckets" must be ascending, between 5 and 65535: `, b)
+		}
+	}
+	if c.Packets != nil {
+		config.Packets = *c.Packets
+	}
+	if c.NoiseCount != nil {
+		config.NoiseCount = *c.NoiseCount
+	}
+	if c.NoiseLength != nil {
+		if c.NoiseLength.From < 0 || c.NoiseLength.To > 0xffff {
+			return nil, errors.New(`"noiseLength" must be between 0 and 65535: `, c.NoiseLength.String())
+		}
+		config.NoiseMin, config.NoiseMax = uint32(c.NoiseLength.From), uint32(c.NoiseLength.To)
+	}
+	if c.Jitter != nil {
+		config.Jitter = *c.Jitter
+	}
+	if len(config.Buckets) == 0 && config.NoiseCount == 0 && config.Jitter == 0 {
+		return nil, errors.New(`neither "buckets", "noiseCount" nor "jitter"`)
+	}
+	return config, nil
+}
+
 type SocketConfig struct {
 	Mark                  int32                  `json:"mark"`
 	TFO                   interface{}            `json:"tcpFastOpen"`
 	TProxy                string                 `json:"tproxy"`
 	AcceptProxyProtocol   bool                   `json:"acceptProxyProtocol"`
+	ProxyProtocolUDP      bool                   `json:"acceptProxyProtocolUdp"`
+	SendProxyProtocol     uint32                 `json:"sendProxyProtocol"`
 	DomainStrategy        string                 `json:"domainStrategy"`
-	DialerProxy           string                 `json:"dialerProxy"`
+	DialerProxy           StringList             `json:"dialerProxy"`
 	TCPKeepAliveInterval  int32                  `json:"tcpKeepAliveInterval"`
 	TCPKeepAliveIdle      int32                  `json:"tcpKeepAliveIdle"`
 	TCPCongestion         string                 `json:"tcpCongestion"`
+	TCPMaxPacingRate      uint64                 `json:"tcpMaxPacingRate"` // Mbps
 	TCPWindowClamp        int32                  `json:"tcpWindowClamp"`
 	TCPMaxSeg             int32                  `json:"tcpMaxSeg"`
+	MtuDiscover           string                 `json:"mtuDiscover"`
 	Penetrate             bool                   `json:"penetrate"`
 	TCPUserTimeout        int32                  `json:"tcpUserTimeout"`
 	V6only                bool                   `json:"v6only"`
@@ -806,6 +1285,9 @@ type SocketConfig struct {
 	CustomSockopt         []*CustomSockoptConfig `json:"customSockopt"`
 	AddressPortStrategy   string                 `json:"addressPortStrategy"`
 	HappyEyeballsSettings *HappyEyeballsConfig   `json:"happyEyeballs"`
+	WindivertFilter       string                 `json:"windivertFilter"`
+	EbpfCgroup            string                 `json:"ebpfCgroup"`
+	Padding               *PaddingConfig         `json:"padding"`
 }
 
 // Build implements Buildable.
@@ -831,6 +1313,10 @@ func (c *SocketConfig) Build() (*internet.SocketConfig, error) {
 		tproxy = internet.SocketConfig_TProxy
 	case "redirect":
 		tproxy = internet.SocketConfig_Redirect
+	case "windivert":
+		tproxy = internet.SocketConfig_WinDivert
+	case "ebpf":
+		tproxy = internet.SocketConfig_Ebpf
 	default:
 		tproxy = internet.SocketConfig_Off
 	}
@@ -863,6 +1349,41 @@ func (c *SocketConfig) Build() (*internet.SocketConfig, error) {
 		return nil, errors.New("unsupported domain strategy: ", c.DomainStrategy)
 	}
 
+	if c.SendProxyProtocol > 2 {
+		return nil, errors.New("sendProxyProtocol: only 0, 1 and 2 are acceptable")
+	}
+
+	mtuDiscover := internet.SocketConfig_Default
+	switch strings.ToLower(c.MtuDiscover) {
+	case "":
+	case "dont":
+		mtuDiscover = internet.SocketConfig_Dont
+	case "want":
+		mtuDiscover = internet.SocketConfig_Want
+	case "do":
+		mtuDiscover = internet.SocketConfig_Do
+	case "probe":
+		mtuDiscover = internet.SocketConfig_Probe
+	default:
+		return nil, errors.New("unsupported mtuDiscover: ", c.MtuDiscover)
+	}
+
+	var dialerProxy string
+	var dialerChain []string
+	if len(c.DialerProxy) > 0 {
+		for _, tag := range c.DialerProxy {
+			if tag == "" {
+				return nil, errors.New("dialerProxy can't contain an empty tag")
+			}
+		}
+		dialerProxy = c.DialerProxy[len(c.DialerProxy)-1]
+		dialerChain = c.DialerProxy[:len(c.DialerProxy)-1]
+	}
+
+	if c.TCPCongestion == "brutal" && c.TCPMaxPacingRate == 0 {
+		return nil, errors.New(`tcpCongestion "brutal" requires tcpMaxPacingRate`)
+	}
+
 	var customSockopts []*internet.CustomSockopt
 
 	for _, copt := range c.CustomSockopt {
@@ -905,18 +1426,33 @@ func (c *SocketConfig) Build() (*internet.SocketConfig, error) {
 		happyEyeballs.MaxConcurrentTry = c.HappyEyeballsSettings.MaxConcurrentTry
 	}
 
-	return &internet.SocketConfig{
+	var paddingConfig *padding.Config
+	if c.Padding != nil {
+		var err error
+		if paddingConfig, err = c.Padding.Build(); err != nil {
+			return nil, errors.New(`invalid "padding"`).Base(err)
+		}
+	}
+
+	config := &internet.SocketConfig{
 		Mark:                 c.Mark,
 		Tfo:                  tfo,
 		Tproxy:               tproxy,
+		WindivertFilter:      c.WindivertFilter,
+		EbpfCgroup:           c.EbpfCgroup,
+		Padding:              paddingConfig,
 		DomainStrategy:       dStrategy,
 		AcceptProxyProtocol:  c.AcceptProxyProtocol,
-		DialerProxy:          c.DialerProxy,
+		SendProxyProtocol:    c.SendProxyProtocol,
+		DialerProxy:          dialerProxy,
+		DialerChain:          dialerChain,
 		TcpKeepAliveInterval: c.TCPKeepAliveInterval,
 		TcpKeepAliveIdle:     c.TCPKeepAliveIdle,
 		TcpCongestion:        c.TCPCongestion,
+		TcpMaxPacingRate:     c.TCPMaxPacingRate * 1000 * 1000 / 8,
 		TcpWindowClamp:       c.TCPWindowClamp,
 		TcpMaxSeg:            c.TCPMaxSeg,
+		MtuDiscover:          mtuDiscover,
 		Penetrate:            c.Penetrate,
 		TcpUserTimeout:       c.TCPUserTimeout,
 		V6Only:               c.V6only,
@@ -925,7 +1461,9 @@ func (c *SocketConfig) Build() (*internet.SocketConfig, error) {
 		CustomSockopt:        customSockopts,
 		AddressPortStrategy:  addressPortStrategy,
 		HappyEyeballs:        happyEyeballs,
-	}, nil
+	}
+	config.AcceptProxyProtocolUdp = c.ProxyProtocolUDP
+	return config, nil
 }
 
 type StreamConfig struct {
@@ -941,6 +1479,8 @@ type StreamConfig struct {
 	SplitHTTPSettings   *SplitHTTPConfig   `json:"splithttpSettings"`
 	KCPSettings         *KCPConfig         `json:"kcpSettings"`
 	GRPCSettings        *GRPCConfig        `json:"grpcSettings"`
+	QUICSettings        *QUICConfig        `json:"quicSettings"`
+	PluginSettings      *PluginConfig      `json:"pluginSettings"`
 	WSSettings          *WebSocketConfig   `json:"wsSettings"`
 	HTTPUPGRADESettings *HttpUpgradeConfig `json:"httpupgradeSettings"`
 	SocketSettings      *SocketConfig      `json:"sockopt"`
@@ -995,6 +1535,9 @@ func (c *StreamConfig) Build() (*internet.StreamConfig, error) {
 	default:
 		return nil, errors.New(`Unknown security "` + c.Security + `".`)
 	}
+	if config.ProtocolName == "quic" && strings.ToLower(c.Security) != "tls" {
+		return nil, errors.New("QUIC requires TLS.")
+	}
 	if c.RAWSettings != nil {
 		c.TCPSettings = c.RAWSettings
 	}
@@ -1041,6 +1584,26 @@ func (c *StreamConfig) Build() (*internet.StreamConfig, error) {
 			Settings:     serial.ToTypedMessage(gs),
 		})
 	}
+	if c.QUICSettings != nil {
+		qs, err := c.QUICSettings.Build()
+		if err != nil {
+			return nil, errors.New("Failed to build QUIC config.").Base(err)
+		}
+		config.TransportSettings = append(config.TransportSettings, &internet.TransportConfig{
+			ProtocolName: "quic",
+			Settings:     serial.ToTypedMessage(qs),
+		})
+	}
+	if c.PluginSettings != nil {
+		ps, err := c.PluginSettings.Build()
+		if err != nil {
+			return nil, errors.New("Failed to build plugin config.").Base(err)
+		}
+		config.TransportSettings = append(config.TransportSettings, &internet.TransportConfig{
+			ProtocolName: "plugin",
+			Settings:     serial.ToTypedMessage(ps),
+		})
+	}
 	if c.WSSettings != nil {
 		ts, err := c.WSSettings.Build()
 		if err != nil {
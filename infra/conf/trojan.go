@@ -2,17 +2,12 @@ package conf
 
 import (
 	"encoding/json"
-	"path/filepath"
-	"runtime"
-	"strconv"
-	"strings"
-	"syscall"
 
 	"github.com/xtls/xray-core/common/errors"
-	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
 	"github.com/xtls/xray-core/proxy/trojan"
+	"github.com/xtls/xray-core/proxy/vless"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -51,8 +46,10 @@ func (c *TrojanClientConfig) Build() (proto.Message, error) {
 		if rec.Password == "" {
 			return nil, errors.New("Trojan password is not specified.")
 		}
-		if rec.Flow != "" {
-			return nil, errors.PrintRemovedFeatureError(`Flow for Trojan`, ``)
+		switch rec.Flow {
+		case "", vless.XRV:
+		default:
+			return nil, errors.New(`Trojan servers: "flow" doesn't support "` + rec.Flow + `" in this version`)
 		}
 
 		config.Server[idx] = &protocol.ServerEndpoint{
@@ -64,6 +61,7 @@ func (c *TrojanClientConfig) Build() (proto.Message, error) {
 					Email: rec.Email,
 					Account: serial.ToTypedMessage(&trojan.Account{
 						Password: rec.Password,
+						Flow:     rec.Flow,
 					}),
 				},
 			},
@@ -93,8 +91,9 @@ type TrojanUserConfig struct {
 
 // TrojanServerConfig is Inbound configuration
 type TrojanServerConfig struct {
-	Clients   []*TrojanUserConfig      `json:"clients"`
-	Fallbacks []*TrojanInboundFallback `json:"fallbacks"`
+	Clients       []*TrojanUserConfig      `json:"clients"`
+	Fallbacks     []*TrojanInboundFallback `json:"fallbacks"`
+	FallbackCheck *FallbackCheckConfig     `json:"fallbackCheck"`
 }
 
 // Build implements Buildable
@@ -104,8 +103,10 @@ func (c *TrojanServerConfig) Build() (proto.Message, error) {
 	}
 
 	for idx, rawUser := range c.Clients {
-		if rawUser.Flow != "" {
-			return nil, errors.PrintRemovedFeatureError(`Flow for Trojan`, ``)
+		switch rawUser.Flow {
+		case "", vless.XRV:
+		default:
+			return nil, errors.New(`Trojan clients: "flow" doesn't support "` + rawUser.Flow + `" in this version`)
 		}
 
 		config.Users[idx] = &protocol.User{
@@ -113,61 +114,28 @@ func (c *TrojanServerConfig) Build() (proto.Message, error) {
 			Email: rawUser.Email,
 			Account: serial.ToTypedMessage(&trojan.Account{
 				Password: rawUser.Password,
+				Flow:     rawUser.Flow,
 			}),
 		}
 	}
 
 	for _, fb := range c.Fallbacks {
-		var i uint16
-		var s string
-		if err := json.Unmarshal(fb.Dest, &i); err == nil {
-			s = strconv.Itoa(int(i))
-		} else {
-			_ = json.Unmarshal(fb.Dest, &s)
+		f, err := (*FallbackConfig)(fb).Build("Trojan")
+		if err != nil {
+			return nil, err
 		}
 		config.Fallbacks = append(config.Fallbacks, &trojan.Fallback{
-			Name: fb.Name,
-			Alpn: fb.Alpn,
-			Path: fb.Path,
-			Type: fb.Type,
-			Dest: s,
-			Xver: fb.Xver,
+			Name:   f.Name,
+			Alpn:   f.Alpn,
+			Path:   f.Path,
+			Type:   f.Dest[0].Type,
+			Dest:   f.Dest[0].Dest,
+			Xver:   f.Xver,
+			Backup: f.Dest[1:],
 		})
 	}
-	for _, fb := range config.Fallbacks {
-		/*
-			if fb.Alpn == "h2" && fb.Path != "" {
-				return nil, errors.New(`Trojan fallbacks: "alpn":"h2" doesn't support "path"`)
-			}
-		*/
-		if fb.Path != "" && fb.Path[0] != '/' {
-			return nil, errors.New(`Trojan fallbacks: "path" must be empty or start with "/"`)
-		}
-		if fb.Type == "" && fb.Dest != "" {
-			if fb.Dest == "serve-ws-none" {
-				fb.Type = "serve"
-			} else if filepath.IsAbs(fb.Dest) || fb.Dest[0] == '@' {
-				fb.Type = "unix"
-				if strings.HasPrefix(fb.Dest, "@@") && (runtime.GOOS == "linux" || runtime.GOOS == "android") {
-					fullAddr := make([]byte, len(syscall.RawSockaddrUnix{}.Path)) // may need padding to work with haproxy
-					copy(fullAddr, fb.Dest[1:])
-					fb.Dest = string(fullAddr)
-				}
-			} else {
-				if _, err := strconv.Atoi(fb.Dest); err == nil {
-					fb.Dest = "localhost:" + fb.Dest
-				}
-				if _, _, err := net.SplitHostPort(fb.Dest); err == nil {
-					fb.Type = "tcp"
-				}
-			}
-		}
-		if fb.Type == "" {
-			return nil, errors.New(`Trojan fallbacks: please fill in a valid value for every "dest"`)
-		}
-		if fb.Xver > 2 {
-			return nil, errors.New(`Trojan fallbacks: invalid PROXY protocol version, "xver" only accepts 0, 1, 2`)
-		}
+	if c.FallbackCheck != nil {
+		config.FallbackCheck = c.FallbackCheck.Build()
 	}
 
 	return config, nil
@@ -0,0 +1,63 @@
+package conf
+
+import (
+	"strings"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/uuid"
+	"github.com/xtls/xray-core/proxy/tuic"
+	"github.com/xtls/xray-core/transport/internet/tls"
+	"google.golang.org/protobuf/proto"
+)
+
+type TUICClientConfig struct {
+	Address          *Address   `json:"address"`
+	Port             uint16     `json:"port"`
+	UUID             string     `json:"uuid"`
+	Password         string     `json:"password"`
+	UDPRelayMode     string     `json:"udpRelayMode"`
+	ZeroRTTHandshake bool       `json:"zeroRttHandshake"`
+	Heartbeat        uint32     `json:"heartbeat"`
+	TLSSettings      *TLSConfig `json:"tlsSettings"`
+	Level            byte       `json:"level"`
+	Email            string     `json:"email"`
+}
+
+// Build implements Buildable
+func (c *TUICClientConfig) Build() (proto.Message, error) {
+	if c.Address == nil {
+		return nil, errors.New("TUIC server address is not set.")
+	}
+	if c.Port == 0 {
+		return nil, errors.New("Invalid TUIC port.")
+	}
+	if _, err := uuid.ParseString(c.UUID); err != nil {
+		return nil, errors.New(`TUIC "uuid" is invalid: `, c.UUID).Base(err)
+	}
+	config := &tuic.ClientConfig{
+		Address:          c.Address.Build(),
+		Port:             uint32(c.Port),
+		Uuid:             c.UUID,
+		Password:         c.Password,
+		ZeroRttHandshake: c.ZeroRTTHandshake,
+		Heartbeat:        c.Heartbeat,
+		Level:            uint32(c.Level),
+		Email:            c.Email,
+	}
+	switch strings.ToLower(c.UDPRelayMode) {
+	case "", "native":
+		config.UdpRelayMode = tuic.UDPRelayMode_Native
+	case "quic":
+		config.UdpRelayMode = tuic.UDPRelayMode_Quic
+	default:
+		return nil, errors.New(`TUIC "udpRelayMode" must be "native" or "quic": `, c.UDPRelayMode)
+	}
+	if c.TLSSettings != nil {
+		ts, err := c.TLSSettings.Build()
+		if err != nil {
+			return nil, errors.New("Failed to build TUIC TLS config.").Base(err)
+		}
+		config.TlsSettings = ts.(*tls.Config)
+	}
+	return config, nil
+}
@@ -0,0 +1,99 @@
+package conf
+
+import (
+	"database/sql"
+	"slices"
+
+	"github.com/xtls/xray-core/app/userstore"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/infra/conf/cfgcommon/duration"
+	"google.golang.org/protobuf/proto"
+)
+
+type UserStoreRedisConfig struct {
+	Address  string `json:"address"`
+	Password string `json:"password"`
+	DB       uint32 `json:"db"`
+	Key      string `json:"key"`
+	Channel  string `json:"channel"`
+}
+
+type UserStoreSQLConfig struct {
+	Driver       string `json:"driver"`
+	DSN          string `json:"dsn"`
+	Query        string `json:"query"`
+	VersionQuery string `json:"versionQuery"`
+}
+
+// UserStoreConfig syncs the users of inbounds from Redis or an SQL database.
+type UserStoreConfig struct {
+	Name     string                `json:"name"`
+	Inbounds *StringList           `json:"inbounds"`
+	Interval duration.Duration     `json:"interval"`
+	Redis    *UserStoreRedisConfig `json:"redis"`
+	SQL      *UserStoreSQLConfig   `json:"sql"`
+}
+
+type UserStoresConfig []*UserStoreConfig
+
+func (c UserStoresConfig) Build() (proto.Message, error) {
+	config := &userstore.Config{}
+	names := make(map[string]bool, len(c))
+	for _, s := range c {
+		if s.Name == "" {
+			return nil, errors.New("user store without name")
+		}
+		if names[s.Name] {
+			return nil, errors.New("duplicate user store ", s.Name)
+		}
+		names[s.Name] = true
+		if s.Inbounds == nil || len(*s.Inbounds) == 0 {
+			return nil, errors.New("user store ", s.Name, " without inbounds")
+		}
+		store := &userstore.Store{
+			Name:       s.Name,
+			InboundTag: *s.Inbounds,
+			Interval:   int64(s.Interval),
+		}
+		switch {
+		case s.Redis != nil && s.SQL != nil:
+			return nil, errors.New("user store ", s.Name, " with both redis and sql")
+		case s.Redis != nil:
+			r := &userstore.Redis{
+				Address:  s.Redis.Address,
+				Password: s.Redis.Password,
+				Db:       s.Redis.DB,
+				Key:      s.Redis.Key,
+				Channel:  s.Redis.Channel,
+			}
+			if r.Address == "" {
+				r.Address = "127.0.0.1:6379"
+			}
+			if r.Key == "" {
+				r.Key = "xray:users"
+			}
+			store.Backend = &userstore.Store_Redis{Redis: r}
+		case s.SQL != nil:
+			if s.SQL.Driver == "" || s.SQL.Query == "" {
+				return nil, errors.New("user store ", s.Name, " requires driver and query of sql")
+			}
+			driver := s.SQL.Driver
+			if driver == "sqlite3" {
+				driver = "sqlite"
+			}
+			if !slices.Contains(sql.Drivers(), driver) {
+				return nil, errors.New("user store ", s.Name, ": sql driver ", s.SQL.Driver, " is not compiled in, only ", sql.Drivers())
+			}
+			store.Backend = &userstore.Store_Sql{Sql: &userstore.SQL{
+				Driver:       driver,
+				Dsn:          s.SQL.DSN,
+				Query:        s.SQL.Query,
+				VersionQuery: s.SQL.VersionQuery,
+			}}
+		default:
+			return nil, errors.New("user store ", s.Name, " requires redis or sql")
+		}
+		config.Stores = append(config.Stores, store)
+	}
+	return config, nil
+}
@@ -2,14 +2,8 @@ package conf
 
 import (
 	"encoding/json"
-	"path/filepath"
-	"runtime"
-	"strconv"
-	"strings"
-	"syscall"
 
 	"github.com/xtls/xray-core/common/errors"
-	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
 	"github.com/xtls/xray-core/common/uuid"
@@ -28,10 +22,65 @@ type VLessInboundFallback struct {
 	Xver uint64          `json:"xver"`
 }
 
+// VLessPaddingProfile is a named way to pad Vision sessions. The sizes not
+// set are of the default padding.
+type VLessPaddingProfile struct {
+	Name           string `json:"name"`
+	LongMin        *int32 `json:"longMin"`
+	LongMax        *int32 `json:"longMax"`
+	ShortMax       *int32 `json:"shortMax"`
+	ContinueWrites uint32 `json:"continueWrites"`
+	MaxDelay       uint32 `json:"maxDelay"`
+}
+
+func (c *VLessPaddingProfile) Build() (*vless.PaddingProfile, error) {
+	if c.Name == "" {
+		return nil, errors.New(`VLESS paddingProfiles: "name" is not set`)
+	}
+	p := &vless.PaddingProfile{
+		Name:           c.Name,
+		LongMin:        900,
+		LongMax:        1400,
+		ShortMax:       256,
+		ContinueWrites: c.ContinueWrites,
+		MaxDelay:       c.MaxDelay,
+	}
+	if c.LongMin != nil {
+		p.LongMin = *c.LongMin
+	}
+	if c.LongMax != nil {
+		p.LongMax = *c.LongMax
+	}
+	if c.ShortMax != nil {
+		p.ShortMax = *c.ShortMax
+	}
+	// The ranges are checked by vless.NewPaddings.
+	return p, nil
+}
+
+func buildVLessPaddingProfiles(configs []*VLessPaddingProfile) ([]*vless.PaddingProfile, error) {
+	profiles := make([]*vless.PaddingProfile, 0, len(configs))
+	names := make(map[string]bool, len(configs))
+	for _, c := range configs {
+		p, err := c.Build()
+		if err != nil {
+			return nil, err
+		}
+		if names[p.Name] {
+			return nil, errors.New(`VLESS paddingProfiles: duplicate "name" `, p.Name)
+		}
+		names[p.Name] = true
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
 type VLessInboundConfig struct {
-	Clients    []json.RawMessage       `json:"clients"`
-	Decryption string                  `json:"decryption"`
-	Fallbacks  []*VLessInboundFallback `json:"fallbacks"`
+	Clients         []json.RawMessage       `json:"clients"`
+	Decryption      string                  `json:"decryption"`
+	Fallbacks       []*VLessInboundFallback `json:"fallbacks"`
+	FallbackCheck   *FallbackCheckConfig    `json:"fallbackCheck"`
+	PaddingProfiles []*VLessPaddingProfile  `json:"paddingProfiles"`
 }
 
 // Build implements Buildable
@@ -64,6 +113,10 @@ func (c *VLessInboundConfig) Build() (proto.Message, error) {
 			return nil, errors.New(`VLESS clients: "encryption" should not in inbound settings`)
 		}
 
+		if account.Padding != "" {
+			return nil, errors.New(`VLESS clients: "padding" should not in inbound settings`)
+		}
+
 		user.Account = serial.ToTypedMessage(account)
 		config.Clients[idx] = user
 	}
@@ -74,58 +127,30 @@ func (c *VLessInboundConfig) Build() (proto.Message, error) {
 	config.Decryption = c.Decryption
 
 	for _, fb := range c.Fallbacks {
-		var i uint16
-		var s string
-		if err := json.Unmarshal(fb.Dest, &i); err == nil {
-			s = strconv.Itoa(int(i))
-		} else {
-			_ = json.Unmarshal(fb.Dest, &s)
+		f, err := (*FallbackConfig)(fb).Build("VLESS")
+		if err != nil {
+			return nil, err
 		}
 		config.Fallbacks = append(config.Fallbacks, &inbound.Fallback{
-			Name: fb.Name,
-			Alpn: fb.Alpn,
-			Path: fb.Path,
-			Type: fb.Type,
-			Dest: s,
-			Xver: fb.Xver,
+			Name:   f.Name,
+			Alpn:   f.Alpn,
+			Path:   f.Path,
+			Type:   f.Dest[0].Type,
+			Dest:   f.Dest[0].Dest,
+			Xver:   f.Xver,
+			Backup: f.Dest[1:],
 		})
 	}
-	for _, fb := range config.Fallbacks {
-		/*
-			if fb.Alpn == "h2" && fb.Path != "" {
-				return nil, errors.New(`VLESS fallbacks: "alpn":"h2" doesn't support "path"`)
-			}
-		*/
-		if fb.Path != "" && fb.Path[0] != '/' {
-			return nil, errors.New(`VLESS fallbacks: "path" must be empty or start with "/"`)
-		}
-		if fb.Type == "" && fb.Dest != "" {
-			if fb.Dest == "serve-ws-none" {
-				fb.Type = "serve"
-			} else if filepath.IsAbs(fb.Dest) || fb.Dest[0] == '@' {
-				fb.Type = "unix"
-				if strings.HasPrefix(fb.Dest, "@@") && (runtime.GOOS == "linux" || runtime.GOOS == "android") {
-					fullAddr := make([]byte, len(syscall.RawSockaddrUnix{}.Path)) // may need padding to work with haproxy
-					copy(fullAddr, fb.Dest[1:])
-					fb.Dest = string(fullAddr)
-				}
-			} else {
-				if _, err := strconv.Atoi(fb.Dest); err == nil {
-					fb.Dest = "localhost:" + fb.Dest
-				}
-				if _, _, err := net.SplitHostPort(fb.Dest); err == nil {
-					fb.Type = "tcp"
-				}
-			}
-		}
-		if fb.Type == "" {
-			return nil, errors.New(`VLESS fallbacks: please fill in a valid value for every "dest"`)
-		}
-		if fb.Xver > 2 {
-			return nil, errors.New(`VLESS fallbacks: invalid PROXY protocol version, "xver" only accepts 0, 1, 2`)
-		}
+	if c.FallbackCheck != nil {
+		config.FallbackCheck = c.FallbackCheck.Build()
 	}
 
+	profiles, err := buildVLessPaddingProfiles(c.PaddingProfiles)
+	if err != nil {
+		return nil, err
+	}
+	config.PaddingProfiles = profiles
+
 	return config, nil
 }
 
@@ -136,13 +161,20 @@ type VLessOutboundVnext struct {
 }
 
 type VLessOutboundConfig struct {
-	Vnext []*VLessOutboundVnext `json:"vnext"`
+	Vnext           []*VLessOutboundVnext  `json:"vnext"`
+	PaddingProfiles []*VLessPaddingProfile `json:"paddingProfiles"`
 }
 
 // Build implements Buildable
 func (c *VLessOutboundConfig) Build() (proto.Message, error) {
 	config := new(outbound.Config)
 
+	profiles, err := buildVLessPaddingProfiles(c.PaddingProfiles)
+	if err != nil {
+		return nil, err
+	}
+	config.PaddingProfiles = profiles
+
 	if len(c.Vnext) == 0 {
 		return nil, errors.New(`VLESS settings: "vnext" is empty`)
 	}
@@ -185,6 +217,19 @@ func (c *VLessOutboundConfig) Build() (proto.Message, error) {
 				return nil, errors.New(`VLESS users: please add/set "encryption":"none" for every user`)
 			}
 
+			if account.Padding != "" {
+				if account.Flow == "" {
+					return nil, errors.New(`VLESS users: "padding" is only for "flow" "` + vless.XRV + `"`)
+				}
+				found := false
+				for _, p := range profiles {
+					found = found || p.Name == account.Padding
+				}
+				if !found {
+					return nil, errors.New(`VLESS users: unknown "padding" `, account.Padding, ` not in "paddingProfiles"`)
+				}
+			}
+
 			user.Account = serial.ToTypedMessage(account)
 			spec.User[idx] = user
 		}
@@ -72,11 +72,33 @@ type VMessInboundConfig struct {
 	Users        []json.RawMessage   `json:"clients"`
 	Defaults     *VMessDefaultConfig `json:"default"`
 	DetourConfig *VMessDetourConfig  `json:"detour"`
+	AEADOnly     bool                `json:"aeadOnly"`
+	Replay       *VMessReplayConfig  `json:"replay"`
+}
+
+// VMessReplayConfig is of how requests are checked for replays.
+type VMessReplayConfig struct {
+	Window uint32 `json:"window"`
+	Memory uint64 `json:"memory"`
+	File   string `json:"file"`
 }
 
 // Build implements Buildable
 func (c *VMessInboundConfig) Build() (proto.Message, error) {
-	config := &inbound.Config{}
+	config := &inbound.Config{
+		AeadOnly: c.AEADOnly,
+	}
+
+	if c.Replay != nil {
+		if c.Replay.Memory != 0 && c.Replay.Memory < 1024 {
+			return nil, errors.New("VMess replay memory must be at least 1024 bytes")
+		}
+		config.Replay = &inbound.ReplayConfig{
+			Window: c.Replay.Window,
+			Memory: c.Replay.Memory,
+			File:   c.Replay.File,
+		}
+	}
 
 	if c.Defaults != nil {
 		config.Default = c.Defaults.Build()
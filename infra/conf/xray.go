@@ -7,15 +7,19 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/xtls/xray-core/app/dispatcher"
+	"github.com/xtls/xray-core/app/policy"
 	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/app/router"
 	"github.com/xtls/xray-core/app/stats"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/serial"
 	core "github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/infra/conf/cfgcommon/duration"
 	"github.com/xtls/xray-core/transport/internet"
 )
 
@@ -27,6 +31,7 @@ var (
 		"shadowsocks":   func() interface{} { return new(ShadowsocksServerConfig) },
 		"mixed":         func() interface{} { return new(SocksServerConfig) },
 		"socks":         func() interface{} { return new(SocksServerConfig) },
+		"sni":           func() interface{} { return new(SNIConfig) },
 		"vless":         func() interface{} { return new(VLessInboundConfig) },
 		"vmess":         func() interface{} { return new(VMessInboundConfig) },
 		"trojan":        func() interface{} { return new(TrojanServerConfig) },
@@ -39,11 +44,15 @@ var (
 		"direct":      func() interface{} { return new(FreedomConfig) },
 		"freedom":     func() interface{} { return new(FreedomConfig) },
 		"http":        func() interface{} { return new(HTTPClientConfig) },
+		"hysteria2":   func() interface{} { return new(Hysteria2ClientConfig) },
 		"shadowsocks": func() interface{} { return new(ShadowsocksClientConfig) },
+		"selector":    func() interface{} { return new(SelectorConfig) },
 		"socks":       func() interface{} { return new(SocksClientConfig) },
+		"ssh":         func() interface{} { return new(SSHClientConfig) },
 		"vless":       func() interface{} { return new(VLessOutboundConfig) },
 		"vmess":       func() interface{} { return new(VMessOutboundConfig) },
 		"trojan":      func() interface{} { return new(TrojanClientConfig) },
+		"tuic":        func() interface{} { return new(TUICClientConfig) },
 		"dns":         func() interface{} { return new(DNSOutboundConfig) },
 	}, "protocol", "settings")
 
@@ -56,6 +65,17 @@ type SniffingConfig struct {
 	DomainsExcluded *StringList `json:"domainsExcluded"`
 	MetadataOnly    bool        `json:"metadataOnly"`
 	RouteOnly       bool        `json:"routeOnly"`
+	PortsExcluded   *PortList   `json:"portsExcluded"`
+	IPsExcluded     *StringList `json:"ipsExcluded"`
+
+	Redirect []*SniffingRedirectConfig `json:"redirect"`
+}
+
+// SniffingRedirectConfig redirects the connections to the sniffed domains of
+// Domains to Dest, a host or host:port.
+type SniffingRedirectConfig struct {
+	Domains StringList `json:"domains"`
+	Dest    string     `json:"dest"`
 }
 
 // Build implements Buildable.
@@ -85,13 +105,38 @@ func (c *SniffingConfig) Build() (*proxyman.SniffingConfig, error) {
 		}
 	}
 
-	return &proxyman.SniffingConfig{
+	config := &proxyman.SniffingConfig{
 		Enabled:             c.Enabled,
 		DestinationOverride: p,
 		DomainsExcluded:     d,
 		MetadataOnly:        c.MetadataOnly,
 		RouteOnly:           c.RouteOnly,
-	}, nil
+	}
+	if c.PortsExcluded != nil {
+		config.PortsExcluded = c.PortsExcluded.Build()
+	}
+	if c.IPsExcluded != nil {
+		ips, err := ToCidrList(*c.IPsExcluded)
+		if err != nil {
+			return nil, errors.New("failed to parse ipsExcluded").Base(err)
+		}
+		config.IpsExcluded = ips
+	}
+	for _, r := range c.Redirect {
+		if len(r.Domains) == 0 || r.Dest == "" {
+			return nil, errors.New("sniffing redirect needs domains and dest")
+		}
+		redirect := &proxyman.SniffingRedirect{Destination: r.Dest}
+		for _, domain := range r.Domains {
+			rules, err := parseDomainRule(domain)
+			if err != nil {
+				return nil, errors.New("failed to parse domain of sniffing redirect: ", domain).Base(err)
+			}
+			redirect.Domain = append(redirect.Domain, rules...)
+		}
+		config.Redirect = append(config.Redirect, redirect)
+	}
+	return config, nil
 }
 
 type MuxConfig struct {
@@ -99,6 +144,9 @@ type MuxConfig struct {
 	Concurrency     int16  `json:"concurrency"`
 	XudpConcurrency int16  `json:"xudpConcurrency"`
 	XudpProxyUDP443 string `json:"xudpProxyUDP443"`
+	MaxConnections  int16  `json:"maxConnections"`
+	MaxLifetime     int32  `json:"maxLifetime"`
+	Padding         bool   `json:"padding"`
 }
 
 // Build creates MultiplexingConfig, Concurrency < 0 completely disables mux.
@@ -110,11 +158,17 @@ func (m *MuxConfig) Build() (*proxyman.MultiplexingConfig, error) {
 	default:
 		return nil, errors.New(`unknown "xudpProxyUDP443": `, m.XudpProxyUDP443)
 	}
+	if m.MaxConnections < 0 || m.MaxLifetime < 0 {
+		return nil, errors.New(`"maxConnections" and "maxLifetime" must not be negative`)
+	}
 	return &proxyman.MultiplexingConfig{
 		Enabled:         m.Enabled,
 		Concurrency:     int32(m.Concurrency),
 		XudpConcurrency: int32(m.XudpConcurrency),
 		XudpProxyUDP443: m.XudpProxyUDP443,
+		MaxConnections:  int32(m.MaxConnections),
+		MaxLifetime:     m.MaxLifetime,
+		Padding:         m.Padding,
 	}, nil
 }
 
@@ -161,6 +215,49 @@ type InboundDetourConfig struct {
 	Allocation     *InboundDetourAllocationConfig `json:"allocate"`
 	StreamSetting  *StreamConfig                  `json:"streamSettings"`
 	SniffingConfig *SniffingConfig                `json:"sniffing"`
+	Knock          *KnockConfig                   `json:"knock"`
+	NAT            string                         `json:"nat"`
+}
+
+// parseNATBehavior returns the NAT behavior of name, of RFC 4787.
+func parseNATBehavior(name string) (proxyman.NATBehavior, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return proxyman.NATBehavior_Default, nil
+	case "fullcone":
+		return proxyman.NATBehavior_FullCone, nil
+	case "addressrestricted":
+		return proxyman.NATBehavior_AddressRestricted, nil
+	case "symmetric":
+		return proxyman.NATBehavior_Symmetric, nil
+	}
+	return 0, errors.New("unknown nat behavior: ", name)
+}
+
+// KnockConfig keeps an inbound dark until a source knocks, see "xray knock".
+type KnockConfig struct {
+	Port uint16 `json:"port"`
+	Key  string `json:"key"`
+	TTL  uint32 `json:"ttl"`
+}
+
+// Build implements Buildable.
+func (c *KnockConfig) Build() (*proxyman.KnockConfig, error) {
+	if c.Port == 0 {
+		return nil, errors.New("knock requires port")
+	}
+	if len(c.Key) == 0 {
+		return nil, errors.New("knock requires key")
+	}
+	ttl := c.TTL
+	if ttl == 0 {
+		ttl = 60
+	}
+	return &proxyman.KnockConfig{
+		Port: uint32(c.Port),
+		Key:  c.Key,
+		Ttl:  ttl,
+	}, nil
 }
 
 // Build implements Buildable.
@@ -176,7 +273,7 @@ func (c *InboundDetourConfig) Build() (*core.InboundHandlerConfig, error) {
 	} else {
 		// Listen on specific IP or Unix Domain Socket
 		receiverSettings.Listen = c.ListenOn.Build()
-		listenDS := c.ListenOn.Family().IsDomain() && (filepath.IsAbs(c.ListenOn.Domain()) || c.ListenOn.Domain()[0] == '@')
+		listenDS := c.ListenOn.Family().IsDomain() && (filepath.IsAbs(c.ListenOn.Domain()) || c.ListenOn.Domain()[0] == '@' || strings.HasPrefix(c.ListenOn.Domain(), "fd://"))
 		listenIP := c.ListenOn.Family().IsIP() || (c.ListenOn.Family().IsDomain() && c.ListenOn.Domain() == "localhost")
 		if listenIP {
 			// Listen on specific IP, must set PortList
@@ -233,6 +330,18 @@ func (c *InboundDetourConfig) Build() (*core.InboundHandlerConfig, error) {
 		}
 		receiverSettings.SniffingSettings = s
 	}
+	if c.Knock != nil {
+		k, err := c.Knock.Build()
+		if err != nil {
+			return nil, errors.New("failed to build knock config").Base(err)
+		}
+		receiverSettings.Knock = k
+	}
+	nat, err := parseNATBehavior(c.NAT)
+	if err != nil {
+		return nil, err
+	}
+	receiverSettings.Nat = nat
 
 	settings := []byte("{}")
 	if c.Settings != nil {
@@ -257,14 +366,66 @@ func (c *InboundDetourConfig) Build() (*core.InboundHandlerConfig, error) {
 	}, nil
 }
 
+// SendThroughPool is a pool of addresses and CIDRs to send traffic through,
+// one picked for each connection.
+type SendThroughPool struct {
+	Addresses StringList `json:"addresses"`
+	Strategy  string     `json:"strategy"`
+}
+
+func (p *SendThroughPool) Build() ([]string, proxyman.SenderConfig_ViaStrategy, error) {
+	if len(p.Addresses) == 0 {
+		return nil, 0, errors.New("sendThroughPool requires addresses")
+	}
+	var strategy proxyman.SenderConfig_ViaStrategy
+	switch strings.ToLower(p.Strategy) {
+	case "", "roundrobin":
+		strategy = proxyman.SenderConfig_RoundRobin
+	case "sticky":
+		strategy = proxyman.SenderConfig_Sticky
+	case "random":
+		strategy = proxyman.SenderConfig_Random
+	default:
+		return nil, 0, errors.New("unknown sendThroughPool strategy: ", p.Strategy)
+	}
+	return p.Addresses, strategy, nil
+}
+
+// DialConfig is the policy of an outbound dialing its connections.
+type DialConfig struct {
+	Timeout duration.Duration `json:"timeout"`
+	Retries *uint32           `json:"retries"`
+	Backoff duration.Duration `json:"backoff"`
+	NextIP  bool              `json:"nextIp"`
+}
+
+// Build implements Buildable.
+func (c *DialConfig) Build() (*proxyman.DialConfig, error) {
+	if c.Timeout < 0 || c.Backoff < 0 {
+		return nil, errors.New("negative timeout or backoff of dialSettings")
+	}
+	config := &proxyman.DialConfig{
+		Timeout: int64(c.Timeout),
+		Backoff: int64(c.Backoff),
+		NextIp:  c.NextIP,
+	}
+	if c.Retries != nil {
+		config.Attempts = *c.Retries + 1
+	}
+	return config, nil
+}
+
 type OutboundDetourConfig struct {
-	Protocol      string           `json:"protocol"`
-	SendThrough   *string          `json:"sendThrough"`
-	Tag           string           `json:"tag"`
-	Settings      *json.RawMessage `json:"settings"`
-	StreamSetting *StreamConfig    `json:"streamSettings"`
-	ProxySettings *ProxyConfig     `json:"proxySettings"`
-	MuxSettings   *MuxConfig       `json:"mux"`
+	Protocol        string           `json:"protocol"`
+	SendThrough     *string          `json:"sendThrough"`
+	SendThroughPool *SendThroughPool `json:"sendThroughPool"`
+	Tag             string           `json:"tag"`
+	Settings        *json.RawMessage `json:"settings"`
+	StreamSetting   *StreamConfig    `json:"streamSettings"`
+	ProxySettings   *ProxyConfig     `json:"proxySettings"`
+	MuxSettings     *MuxConfig       `json:"mux"`
+	DialSettings    *DialConfig      `json:"dialSettings"`
+	NAT             string           `json:"nat"`
 }
 
 func (c *OutboundDetourConfig) checkChainProxyConfig() error {
@@ -300,6 +461,18 @@ func (c *OutboundDetourConfig) Build() (*core.OutboundHandlerConfig, error) {
 		senderSettings.Via = address.Build()
 	}
 
+	if c.SendThroughPool != nil {
+		if c.SendThrough != nil {
+			return nil, errors.New("sendThrough and sendThroughPool can not be both set")
+		}
+		addresses, strategy, err := c.SendThroughPool.Build()
+		if err != nil {
+			return nil, err
+		}
+		senderSettings.ViaPool = addresses
+		senderSettings.ViaStrategy = strategy
+	}
+
 	if c.StreamSetting != nil {
 		ss, err := c.StreamSetting.Build()
 		if err != nil {
@@ -336,6 +509,20 @@ func (c *OutboundDetourConfig) Build() (*core.OutboundHandlerConfig, error) {
 		senderSettings.MultiplexSettings = ms
 	}
 
+	if c.DialSettings != nil {
+		ds, err := c.DialSettings.Build()
+		if err != nil {
+			return nil, err
+		}
+		senderSettings.DialSettings = ds
+	}
+
+	nat, err := parseNATBehavior(c.NAT)
+	if err != nil {
+		return nil, err
+	}
+	senderSettings.Nat = nat
+
 	settings := []byte("{}")
 	if c.Settings != nil {
 		settings = ([]byte)(*c.Settings)
@@ -375,13 +562,20 @@ type Config struct {
 	InboundConfigs   []InboundDetourConfig   `json:"inbounds"`
 	OutboundConfigs  []OutboundDetourConfig  `json:"outbounds"`
 	Policy           *PolicyConfig           `json:"policy"`
+	Tenants          []*TenantConfig         `json:"tenants"`
 	API              *APIConfig              `json:"api"`
 	Metrics          *MetricsConfig          `json:"metrics"`
+	Events           *EventsConfig           `json:"events"`
+	Tracing          *TracingConfig          `json:"tracing"`
+	Guard            *GuardConfig            `json:"guard"`
 	Stats            *StatsConfig            `json:"stats"`
 	Reverse          *ReverseConfig          `json:"reverse"`
 	FakeDNS          *FakeDNSConfig          `json:"fakeDns"`
 	Observatory      *ObservatoryConfig      `json:"observatory"`
 	BurstObservatory *BurstObservatoryConfig `json:"burstObservatory"`
+	Providers        OutboundProvidersConfig `json:"outboundProviders"`
+	UserStores       UserStoresConfig        `json:"userStores"`
+	Cluster          *ClusterConfig          `json:"cluster"`
 	Version          *VersionConfig          `json:"version"`
 }
 
@@ -426,12 +620,24 @@ func (c *Config) Override(o *Config, fn string) {
 	if o.Policy != nil {
 		c.Policy = o.Policy
 	}
+	if o.Tenants != nil {
+		c.Tenants = o.Tenants
+	}
 	if o.API != nil {
 		c.API = o.API
 	}
 	if o.Metrics != nil {
 		c.Metrics = o.Metrics
 	}
+	if o.Events != nil {
+		c.Events = o.Events
+	}
+	if o.Tracing != nil {
+		c.Tracing = o.Tracing
+	}
+	if o.Guard != nil {
+		c.Guard = o.Guard
+	}
 	if o.Stats != nil {
 		c.Stats = o.Stats
 	}
@@ -451,6 +657,18 @@ func (c *Config) Override(o *Config, fn string) {
 		c.BurstObservatory = o.BurstObservatory
 	}
 
+	if o.Providers != nil {
+		c.Providers = o.Providers
+	}
+
+	if o.UserStores != nil {
+		c.UserStores = o.UserStores
+	}
+
+	if o.Cluster != nil {
+		c.Cluster = o.Cluster
+	}
+
 	if o.Version != nil {
 		c.Version = o.Version
 	}
@@ -521,6 +739,27 @@ func (c *Config) Build() (*core.Config, error) {
 		}
 		config.App = append(config.App, serial.ToTypedMessage(metricsConf))
 	}
+	if c.Events != nil {
+		eventsConf, err := c.Events.Build()
+		if err != nil {
+			return nil, errors.New("failed to build events configuration").Base(err)
+		}
+		config.App = append(config.App, serial.ToTypedMessage(eventsConf))
+	}
+	if c.Tracing != nil {
+		tracingConf, err := c.Tracing.Build()
+		if err != nil {
+			return nil, errors.New("failed to build tracing configuration").Base(err)
+		}
+		config.App = append(config.App, serial.ToTypedMessage(tracingConf))
+	}
+	if c.Guard != nil {
+		guardConf, err := c.Guard.Build()
+		if err != nil {
+			return nil, errors.New("failed to build guard configuration").Base(err)
+		}
+		config.App = append(config.App, serial.ToTypedMessage(guardConf))
+	}
 	if c.Stats != nil {
 		statsConf, err := c.Stats.Build()
 		if err != nil {
@@ -531,7 +770,11 @@ func (c *Config) Build() (*core.Config, error) {
 
 	var logConfMsg *serial.TypedMessage
 	if c.LogConfig != nil {
-		logConfMsg = serial.ToTypedMessage(c.LogConfig.Build())
+		logConf, err := c.LogConfig.Build()
+		if err != nil {
+			return nil, errors.New("failed to build log configuration").Base(err)
+		}
+		logConfMsg = serial.ToTypedMessage(logConf)
 	} else {
 		logConfMsg = serial.ToTypedMessage(DefaultLogConfig())
 	}
@@ -539,11 +782,21 @@ func (c *Config) Build() (*core.Config, error) {
 	// so that other modules could print log during initiating
 	config.App = append([]*serial.TypedMessage{logConfMsg}, config.App...)
 
-	if c.RouterConfig != nil {
-		routerConfig, err := c.RouterConfig.Build()
-		if err != nil {
-			return nil, errors.New("failed to build routing configuration").Base(err)
+	tenants, tenantRules, err := buildTenants(c.Tenants)
+	if err != nil {
+		return nil, errors.New("failed to build tenants").Base(err)
+	}
+
+	if c.RouterConfig != nil || len(tenantRules) > 0 {
+		routerConfig := &router.Config{}
+		if c.RouterConfig != nil {
+			routerConfig, err = c.RouterConfig.Build()
+			if err != nil {
+				return nil, errors.New("failed to build routing configuration").Base(err)
+			}
 		}
+		// The rules of the tenants take precedence.
+		routerConfig.Rule = append(tenantRules, routerConfig.Rule...)
 		config.App = append(config.App, serial.ToTypedMessage(routerConfig))
 	}
 
@@ -555,10 +808,19 @@ func (c *Config) Build() (*core.Config, error) {
 		config.App = append(config.App, serial.ToTypedMessage(dnsApp))
 	}
 
-	if c.Policy != nil {
-		pc, err := c.Policy.Build()
-		if err != nil {
-			return nil, errors.New("failed to build policy configuration").Base(err)
+	if c.Policy != nil || len(tenants) > 0 {
+		pc := &policy.Config{}
+		if c.Policy != nil {
+			pc, err = c.Policy.Build()
+			if err != nil {
+				return nil, errors.New("failed to build policy configuration").Base(err)
+			}
+		}
+		if len(tenants) > 0 {
+			if pc.System == nil {
+				pc.System = &policy.SystemPolicy{Stats: &policy.SystemPolicy_Stats{}}
+			}
+			pc.System.Tenant = tenants
 		}
 		config.App = append(config.App, serial.ToTypedMessage(pc))
 	}
@@ -595,6 +857,30 @@ func (c *Config) Build() (*core.Config, error) {
 		config.App = append(config.App, serial.ToTypedMessage(r))
 	}
 
+	if len(c.Providers) > 0 {
+		r, err := c.Providers.Build()
+		if err != nil {
+			return nil, errors.New("failed to build outbound providers configuration").Base(err)
+		}
+		config.App = append(config.App, serial.ToTypedMessage(r))
+	}
+
+	if len(c.UserStores) > 0 {
+		r, err := c.UserStores.Build()
+		if err != nil {
+			return nil, errors.New("failed to build user stores configuration").Base(err)
+		}
+		config.App = append(config.App, serial.ToTypedMessage(r))
+	}
+
+	if c.Cluster != nil {
+		r, err := c.Cluster.Build()
+		if err != nil {
+			return nil, errors.New("failed to build cluster configuration").Base(err)
+		}
+		config.App = append(config.App, serial.ToTypedMessage(r))
+	}
+
 	if c.Version != nil {
 		r, err := c.Version.Build()
 		if err != nil {
@@ -627,6 +913,10 @@ func (c *Config) Build() (*core.Config, error) {
 		outbounds = append(outbounds, c.OutboundConfigs...)
 	}
 
+	if err := checkDialerProxyLoops(outbounds); err != nil {
+		return nil, err
+	}
+
 	for _, rawOutboundConfig := range outbounds {
 		oc, err := rawOutboundConfig.Build()
 		if err != nil {
@@ -638,6 +928,47 @@ func (c *Config) Build() (*core.Config, error) {
 	return config, nil
 }
 
+// checkDialerProxyLoops reports outbounds that end up dialing through
+// themselves, by proxySettings or dialerProxy.
+func checkDialerProxyLoops(outbounds []OutboundDetourConfig) error {
+	next := make(map[string][]string)
+	for _, ob := range outbounds {
+		if ob.ProxySettings != nil && ob.ProxySettings.Tag != "" {
+			next[ob.Tag] = append(next[ob.Tag], ob.ProxySettings.Tag)
+		}
+		if ob.StreamSetting != nil && ob.StreamSetting.SocketSettings != nil {
+			next[ob.Tag] = append(next[ob.Tag], ob.StreamSetting.SocketSettings.DialerProxy...)
+		}
+	}
+
+	done := make(map[string]bool)
+	var path []string
+	var visit func(tag string) error
+	visit = func(tag string) error {
+		if i := slices.Index(path, tag); i >= 0 {
+			return errors.New("outbound dials through itself: ", strings.Join(append(path[i:], tag), " -> "))
+		}
+		if done[tag] {
+			return nil
+		}
+		path = append(path, tag)
+		for _, n := range next[tag] {
+			if err := visit(n); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		done[tag] = true
+		return nil
+	}
+	for _, ob := range outbounds {
+		if err := visit(ob.Tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Convert string to Address.
 func ParseSendThough(Addr *string) *Address {
 	var addr Address
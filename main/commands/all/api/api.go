@@ -9,20 +9,43 @@ var CmdAPI = &base.Command{
 	UsageLine: "{{.Exec}} api",
 	Short:     "Call an API in an Xray process",
 	Long: `{{.Exec}} {{.LongName}} provides tools to manipulate Xray via its API.
+
+All commands accept these arguments, for servers with "api.tls" or
+"api.credentials" configured:
+
+	-token <token>
+		The token of the API credential. Default $XRAY_API_TOKEN
+
+	-tls
+		Connect with TLS, verified with the system CAs.
+
+	-cacert <file>
+		Connect with TLS, verified with the CA certificates in file.
+
+	-cert <file>, -key <file>
+		Connect with TLS, with the client certificate and key in the files.
 `,
 	Commands: []*base.Command{
 		cmdRestartLogger,
 		cmdGetStats,
 		cmdQueryStats,
+		cmdResetStats,
 		cmdSysStats,
 		cmdBalancerInfo,
 		cmdBalancerOverride,
+		cmdObservatoryStatus,
+		cmdEvents,
+		cmdBans,
+		cmdUnban,
 		cmdAddInbounds,
 		cmdAddOutbounds,
 		cmdRemoveInbounds,
+		cmdSetFallbacks,
+		cmdSetTLSPolicy,
 		cmdRemoveOutbounds,
 		cmdListInbounds,
 		cmdListOutbounds,
+		cmdSelectOutbound,
 		cmdAddInboundUsers,
 		cmdRemoveInboundUsers,
 		cmdInboundUser,
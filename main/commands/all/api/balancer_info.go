@@ -66,7 +66,7 @@ func showBalancerInfo(b *routerService.BalancerMsg) {
 	const tableIndent = 4
 	sb := new(strings.Builder)
 	// Override
-	if b.Override != nil {
+	if b.Override != nil && b.Override.Target != "" {
 		sb.WriteString("  - Selecting Override:\n")
 		for i, s := range []string{b.Override.Target} {
 			writeRow(sb, tableIndent, i+1, []string{s}, nil)
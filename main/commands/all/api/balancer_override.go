@@ -52,6 +52,9 @@ func executeBalancerOverride(cmd *base.Command, args []string) {
 	if balancer == "" {
 		base.Fatalf("balancer tag not specified")
 	}
+	if !remove && cmd.Flag.NArg() != 1 {
+		base.Fatalf("outbound tag not specified")
+	}
 
 	conn, ctx, close := dialAPIServer()
 	defer close()
@@ -68,6 +71,6 @@ func executeBalancerOverride(cmd *base.Command, args []string) {
 
 	_, err := client.OverrideBalancerTarget(ctx, r)
 	if err != nil {
-		base.Fatalf("failed to perform balancer health checks: %s", err)
+		base.Fatalf("failed to override balancer target: %s", err)
 	}
 }
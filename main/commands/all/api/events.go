@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	eventService "github.com/xtls/xray-core/app/events/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdEvents = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api events [--server=127.0.0.1:8080] [type]...",
+	Short:       "Follow events",
+	Long: `
+Follow the events of Xray, such as outbounds going down, of the specified
+types, or of all types if none is specified. A type ending in "*" matches
+the types of its prefix.
+
+> Ensure that "EventService" is enabled under "config.api.services" in the server configuration.
+
+Arguments:
+
+	-s, -server <server:port>
+		The API server address. Default 127.0.0.1:8080
+
+	-t, -timeout <seconds>
+		Timeout in seconds for connecting to the API. Default 3
+
+	-json
+		Print each event as JSON.
+
+Example:
+
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080 "outbound.*"
+`,
+	Run: executeEvents,
+}
+
+func executeEvents(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	cmd.Flag.Parse(args)
+
+	conn, _, close := dialAPIServer()
+	defer close()
+
+	client := eventService.NewEventServiceClient(conn)
+	stream, err := client.SubscribeEvents(withAPIToken(context.Background()), &eventService.SubscribeEventsRequest{
+		Type: cmd.Flag.Args(),
+	})
+	if err != nil {
+		base.Fatalf("failed to subscribe events: %s", err)
+	}
+	for {
+		e, err := stream.Recv()
+		if err != nil {
+			base.Fatalf("failed to receive events: %s", err)
+		}
+		if apiJSON {
+			showJSONResponse(e)
+			continue
+		}
+		keys := make([]string, 0, len(e.Data))
+		for k := range e.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		sb := new(strings.Builder)
+		sb.WriteString(time.UnixMilli(e.Time).Format(time.RFC3339))
+		sb.WriteString(" " + e.Type)
+		for _, k := range keys {
+			fmt.Fprintf(sb, " %s=%q", k, e.Data[k])
+		}
+		fmt.Println(sb.String())
+	}
+}
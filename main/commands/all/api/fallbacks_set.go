@@ -0,0 +1,111 @@
+package api
+
+import (
+	"fmt"
+
+	handlerService "github.com/xtls/xray-core/app/proxyman/command"
+	cserial "github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/infra/conf/serial"
+	"github.com/xtls/xray-core/main/commands/base"
+	"github.com/xtls/xray-core/proxy/fallback"
+	"github.com/xtls/xray-core/proxy/trojan"
+	vlessInbound "github.com/xtls/xray-core/proxy/vless/inbound"
+)
+
+var cmdSetFallbacks = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api fallbacks [--server=127.0.0.1:8080] -tag=tag <c1.json> [c2.json]...",
+	Short:       "Replace the fallbacks of an inbound",
+	Long: `
+Replace the fallbacks of a VLESS or Trojan inbound with those of the
+inbound of the same tag in the configs, without restarting it. The
+connections already fallen back are kept.
+
+Arguments:
+
+	-s, -server <server:port>
+		The API server address. Default 127.0.0.1:8080
+
+	-t, -timeout <seconds>
+		Timeout in seconds for calling API. Default 3
+
+	-tag
+		The tag of the inbound.
+
+Example:
+
+	{{.Exec}} {{.LongName}} --server=127.0.0.1:8080 -tag=vless-in config.json
+`,
+	Run: executeSetFallbacks,
+}
+
+func executeSetFallbacks(cmd *base.Command, args []string) {
+	var tag string
+	setSharedFlags(cmd)
+	cmd.Flag.StringVar(&tag, "tag", "", "")
+	cmd.Flag.Parse(args)
+	if tag == "" {
+		base.Fatalf("no inbound tag specified")
+	}
+	unnamedArgs := cmd.Flag.Args()
+	if len(unnamedArgs) == 0 {
+		fmt.Println("reading from stdin:")
+		unnamedArgs = []string{"stdin:"}
+	}
+
+	var fallbacks []*fallback.Fallback
+	found := false
+	for _, arg := range unnamedArgs {
+		r, err := loadArg(arg)
+		if err != nil {
+			base.Fatalf("failed to load %s: %s", arg, err)
+		}
+		conf, err := serial.DecodeJSONConfig(r)
+		if err != nil {
+			base.Fatalf("failed to decode %s: %s", arg, err)
+		}
+		for _, in := range conf.InboundConfigs {
+			if in.Tag != tag {
+				continue
+			}
+			i, err := in.Build()
+			if err != nil {
+				base.Fatalf("failed to build conf: %s", err)
+			}
+			settings, err := i.ProxySettings.GetInstance()
+			if err != nil {
+				base.Fatalf("failed to build conf: %s", err)
+			}
+			fallbacks = fallbacks[:0]
+			switch settings := settings.(type) {
+			case *vlessInbound.Config:
+				for _, fb := range settings.Fallbacks {
+					fallbacks = append(fallbacks, fb.AsFallback())
+				}
+			case *trojan.ServerConfig:
+				for _, fb := range settings.Fallbacks {
+					fallbacks = append(fallbacks, fb.AsFallback())
+				}
+			default:
+				base.Fatalf("inbound %s is neither VLESS nor Trojan", tag)
+			}
+			found = true
+		}
+	}
+	if !found {
+		base.Fatalf("no inbound %s found", tag)
+	}
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := handlerService.NewHandlerServiceClient(conn)
+	resp, err := client.AlterInbound(ctx, &handlerService.AlterInboundRequest{
+		Tag:       tag,
+		Operation: cserial.ToTypedMessage(&handlerService.SetFallbacksOperation{Fallbacks: fallbacks}),
+	})
+	if err != nil {
+		base.Fatalf("failed to set fallbacks: %s", err)
+	}
+	showJSONResponse(resp)
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	guardService "github.com/xtls/xray-core/app/guard/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdBans = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api bans [--server=127.0.0.1:8080]",
+	Short:       "List the sources banned by the guard",
+	Long: `
+List the sources banned by the guard for failing to authenticate too
+often, with the time their bans end.
+
+> Ensure that "GuardService" is enabled under "config.api.services" in the server configuration.
+
+Arguments:
+
+	-s, -server <server:port>
+		The API server address. Default 127.0.0.1:8080
+
+	-t, -timeout <seconds>
+		Timeout in seconds for calling API. Default 3
+
+	-json
+		Print the bans as JSON.
+
+Example:
+
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080
+`,
+	Run: executeBans,
+}
+
+var cmdUnban = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api unban [--server=127.0.0.1:8080] <ip>...",
+	Short:       "Lift bans of the guard",
+	Long: `
+Lift the bans of the specified IPs, and forget their failed
+authentications.
+
+> Ensure that "GuardService" is enabled under "config.api.services" in the server configuration.
+
+Arguments:
+
+	-s, -server <server:port>
+		The API server address. Default 127.0.0.1:8080
+
+	-t, -timeout <seconds>
+		Timeout in seconds for calling API. Default 3
+
+Example:
+
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080 1.2.3.4
+`,
+	Run: executeUnban,
+}
+
+func executeBans(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	cmd.Flag.Parse(args)
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := guardService.NewGuardServiceClient(conn)
+	resp, err := client.ListBans(ctx, &guardService.ListBansRequest{})
+	if err != nil {
+		base.Fatalf("failed to list bans: %s", err)
+	}
+	if apiJSON {
+		showJSONResponse(resp)
+		return
+	}
+	if len(resp.Ban) == 0 {
+		fmt.Println("no source banned")
+		return
+	}
+	for _, ban := range resp.Ban {
+		until := time.Unix(ban.Until, 0)
+		fmt.Printf("%s until %s (%s left)\n", ban.Ip, until.Format(time.RFC3339), time.Until(until).Truncate(time.Second))
+	}
+}
+
+func executeUnban(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	cmd.Flag.Parse(args)
+
+	ips := cmd.Flag.Args()
+	if len(ips) == 0 {
+		base.Fatalf("no IP specified")
+	}
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := guardService.NewGuardServiceClient(conn)
+	for _, ip := range ips {
+		resp, err := client.Unban(ctx, &guardService.UnbanRequest{Ip: ip})
+		if err != nil {
+			base.Fatalf("failed to unban %s: %s", ip, err)
+		}
+		if resp.Banned {
+			fmt.Println("unbanned", ip)
+		} else {
+			fmt.Println(ip, "is not banned")
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	observatoryService "github.com/xtls/xray-core/app/observatory/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdObservatoryStatus = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api obs [--server=127.0.0.1:8080] [-history] [outboundTag]...",
+	Short:       "Retrieve observatory status",
+	Long: `
+Retrieve the latest probe results of the observatory, for the specified
+outbounds, or for all observed outbounds if none is specified.
+
+> Ensure that "ObservatoryService" is enabled under "config.api.services" in the server configuration.
+
+Arguments:
+
+	-s, -server <server:port>
+		The API server address. Default 127.0.0.1:8080
+
+	-t, -timeout <seconds>
+		Timeout in seconds for calling API. Default 3
+
+	-json
+		Print the status as JSON.
+
+	-history
+		Print the recent probe results of each outbound too.
+
+Example:
+
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080 proxy1 proxy2
+`,
+	Run: executeObservatoryStatus,
+}
+
+func executeObservatoryStatus(cmd *base.Command, args []string) {
+	var history bool
+	cmd.Flag.BoolVar(&history, "history", false, "")
+	setSharedFlags(cmd)
+	cmd.Flag.Parse(args)
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := observatoryService.NewObservatoryServiceClient(conn)
+	resp, err := client.GetOutboundStatus(ctx, &observatoryService.GetOutboundStatusRequest{})
+	if err != nil {
+		base.Fatalf("failed to get observatory status: %s", err)
+	}
+
+	if tags := cmd.Flag.Args(); len(tags) > 0 && resp.Status != nil {
+		status := resp.Status.Status[:0]
+		for _, s := range resp.Status.Status {
+			for _, tag := range tags {
+				if s.OutboundTag == tag {
+					status = append(status, s)
+					break
+				}
+			}
+		}
+		resp.Status.Status = status
+	}
+
+	if apiJSON {
+		showJSONResponse(resp)
+		return
+	}
+	if resp.Status == nil || len(resp.Status.Status) == 0 {
+		fmt.Println("no outbound observed")
+		return
+	}
+
+	titles := []string{"Outbound", "Alive", "Delay", "Handshake", "TTFB", "Last Seen", "Last Try", "Last Error"}
+	rows := make([][]string, 0, len(resp.Status.Status))
+	for _, s := range resp.Status.Status {
+		rows = append(rows, []string{
+			s.OutboundTag,
+			fmt.Sprint(s.Alive),
+			formatMilliseconds(s.Alive, s.Delay),
+			formatMilliseconds(s.Alive, s.Handshake),
+			formatMilliseconds(s.Alive, s.Ttfb),
+			formatAgo(s.LastSeenTime),
+			formatAgo(s.LastTryTime),
+			s.LastErrorReason,
+		})
+	}
+	printTable(titles, rows)
+
+	if !history {
+		return
+	}
+	titles = []string{"Time", "Alive", "Delay", "Handshake", "TTFB", "Error"}
+	for _, s := range resp.Status.Status {
+		rows = rows[:0]
+		for _, r := range s.History {
+			rows = append(rows, []string{
+				formatAgo(r.Time),
+				fmt.Sprint(r.Alive),
+				formatMilliseconds(r.Alive, r.Delay),
+				formatMilliseconds(r.Alive, r.Handshake),
+				formatMilliseconds(r.Alive, r.Ttfb),
+				r.LastErrorReason,
+			})
+		}
+		fmt.Printf("\nHistory of %s:\n", s.OutboundTag)
+		printTable(titles, rows)
+	}
+}
+
+// printTable prints rows under titles, in columns but the last one.
+func printTable(titles []string, rows [][]string) {
+	// The last column, of errors, is not padded.
+	formats := make([]string, len(titles))
+	formats[len(titles)-1] = "%s"
+	for i, title := range titles[:len(titles)-1] {
+		width := len(title)
+		for _, row := range rows {
+			width = max(width, len(row[i]))
+		}
+		formats[i] = fmt.Sprintf("%%-%ds ", width)
+	}
+
+	sb := new(strings.Builder)
+	writeRow(sb, 0, 0, titles, formats)
+	for i, row := range rows {
+		writeRow(sb, 0, i+1, row, formats)
+	}
+	os.Stdout.WriteString(sb.String())
+}
+
+// formatMilliseconds formats a time of a probe, in milliseconds, if alive.
+func formatMilliseconds(alive bool, ms int64) string {
+	if !alive {
+		return "-"
+	}
+	return (time.Duration(ms) * time.Millisecond).String()
+}
+
+// formatAgo formats a unix time as the time elapsed since.
+func formatAgo(unix int64) string {
+	if unix == 0 {
+		return "never"
+	}
+	return time.Since(time.Unix(unix, 0)).Truncate(time.Second).String() + " ago"
+}
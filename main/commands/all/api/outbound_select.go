@@ -0,0 +1,59 @@
+package api
+
+import (
+	handlerService "github.com/xtls/xray-core/app/proxyman/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdSelectOutbound = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api select [--server=127.0.0.1:8080] -tag tag [-outbound tag]",
+	Short:       "Switch the outbound of a selector",
+	Long: `
+Switch the selected candidate of a selector outbound, printing the selected
+one and the candidates. Only prints them without -outbound.
+
+Arguments:
+
+	-s, -server <server:port>
+		The API server address. Default 127.0.0.1:8080
+
+	-t, -timeout <seconds>
+		Timeout in seconds for calling API. Default 3
+
+	-tag
+		The tag of the selector outbound.
+
+	-outbound
+		The tag of the candidate to select.
+
+Example:
+
+	{{.Exec}} {{.LongName}} --server=127.0.0.1:8080 -tag proxySelect -outbound tokyo1
+`,
+	Run: executeSelectOutbound,
+}
+
+func executeSelectOutbound(cmd *base.Command, args []string) {
+	var tag, outbound string
+	setSharedFlags(cmd)
+	cmd.Flag.StringVar(&tag, "tag", "", "")
+	cmd.Flag.StringVar(&outbound, "outbound", "", "")
+	cmd.Flag.Parse(args)
+	if tag == "" {
+		base.Fatalf("no selector tag specified")
+	}
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := handlerService.NewHandlerServiceClient(conn)
+	resp, err := client.SelectOutbound(ctx, &handlerService.SelectOutboundRequest{
+		Tag:         tag,
+		OutboundTag: outbound,
+	})
+	if err != nil {
+		base.Fatalf("failed to select outbound: %s", err)
+	}
+	showJSONResponse(resp)
+}
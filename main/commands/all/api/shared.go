@@ -3,6 +3,8 @@ package api
 import (
 	"bytes"
 	"context"
+	gotls "crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -13,11 +15,14 @@ import (
 	"strings"
 	"time"
 
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/xtls/xray-core/common/buf"
 	creflect "github.com/xtls/xray-core/common/reflect"
 	"github.com/xtls/xray-core/main/commands/base"
+	"github.com/xtls/xray-core/transport/internet/tls"
 	"google.golang.org/grpc"
 	"google.golang.org/protobuf/proto"
 )
@@ -28,6 +33,11 @@ var (
 	apiServerAddrPtr string
 	apiTimeout       int
 	apiJSON          bool
+	apiToken         string
+	apiTLS           bool
+	apiCACert        string
+	apiCert          string
+	apiKey           string
 )
 
 func setSharedFlags(cmd *base.Command) {
@@ -36,14 +46,45 @@ func setSharedFlags(cmd *base.Command) {
 	cmd.Flag.IntVar(&apiTimeout, "t", 3, "")
 	cmd.Flag.IntVar(&apiTimeout, "timeout", 3, "")
 	cmd.Flag.BoolVar(&apiJSON, "json", false, "")
+	cmd.Flag.StringVar(&apiToken, "token", os.Getenv("XRAY_API_TOKEN"), "")
+	cmd.Flag.BoolVar(&apiTLS, "tls", false, "")
+	cmd.Flag.StringVar(&apiCACert, "cacert", "", "")
+	cmd.Flag.StringVar(&apiCert, "cert", "", "")
+	cmd.Flag.StringVar(&apiKey, "key", "", "")
+}
+
+func apiTransportCredentials() credentials.TransportCredentials {
+	if !apiTLS && apiCACert == "" && apiCert == "" {
+		return insecure.NewCredentials()
+	}
+	config := &gotls.Config{}
+	if apiCACert != "" {
+		pem, err := os.ReadFile(apiCACert)
+		if err != nil {
+			base.Fatalf("failed to read CA certificate: %s", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			base.Fatalf("no certificate in %s", apiCACert)
+		}
+	}
+	if apiCert != "" {
+		cert, err := gotls.LoadX509KeyPair(apiCert, apiKey)
+		if err != nil {
+			base.Fatalf("failed to load client certificate: %s", err)
+		}
+		config.Certificates = []gotls.Certificate{cert}
+	}
+	return credentials.NewTLS(config)
 }
 
 func dialAPIServer() (conn *grpc.ClientConn, ctx context.Context, close func()) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(apiTimeout)*time.Second)
-	conn, err := grpc.DialContext(ctx, apiServerAddrPtr, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	conn, err := grpc.DialContext(ctx, apiServerAddrPtr, grpc.WithTransportCredentials(apiTransportCredentials()), grpc.WithBlock())
 	if err != nil {
 		base.Fatalf("failed to dial %s", apiServerAddrPtr)
 	}
+	ctx = withAPIToken(ctx)
 	close = func() {
 		cancel()
 		conn.Close()
@@ -51,6 +92,14 @@ func dialAPIServer() (conn *grpc.ClientConn, ctx context.Context, close func())
 	return
 }
 
+// withAPIToken returns ctx with the API token of the flags, if any.
+func withAPIToken(ctx context.Context) context.Context {
+	if apiToken != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+apiToken)
+	}
+	return ctx
+}
+
 // loadArg loads one arg, maybe an remote url, or local file path
 func loadArg(arg string) (out io.Reader, err error) {
 	var data []byte
@@ -83,9 +132,7 @@ func fetchHTTPContent(target string) ([]byte, error) {
 		return nil, fmt.Errorf("invalid scheme: %s", parsedTarget.Scheme)
 	}
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
+	client := tls.NewHTTPClient(30 * time.Second)
 	resp, err := client.Do(&http.Request{
 		Method: "GET",
 		URL:    parsedTarget,
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+
+	statsService "github.com/xtls/xray-core/app/stats/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdResetStats = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api statsreset [--server=127.0.0.1:8080] [-pattern '']",
+	Short:       "Reset statistics",
+	Long: `
+Reset the counters of Xray whose names contain the pattern, and print the
+values they had.
+
+Arguments:
+
+	-s, -server <server:port>
+		The API server address. Default 127.0.0.1:8080
+
+	-t, -timeout <seconds>
+		Timeout in seconds for calling API. Default 3
+
+	-pattern
+		Filter pattern for the counters to reset. All counters are reset
+		without it.
+
+	-json
+		Print the counters as JSON.
+
+Example:
+
+	{{.Exec}} {{.LongName}} --server=127.0.0.1:8080 -pattern "user>>>"
+`,
+	Run: executeResetStats,
+}
+
+func executeResetStats(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	pattern := cmd.Flag.String("pattern", "", "")
+	cmd.Flag.Parse(args)
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := statsService.NewStatsServiceClient(conn)
+	r := &statsService.QueryStatsRequest{
+		Pattern: *pattern,
+		Reset_:  true,
+	}
+	resp, err := client.QueryStats(ctx, r)
+	if err != nil {
+		base.Fatalf("failed to reset stats: %s", err)
+	}
+	if apiJSON {
+		showJSONResponse(resp)
+		return
+	}
+	for _, stat := range resp.Stat {
+		fmt.Printf("%s\t%d\n", stat.Name, stat.Value)
+	}
+	fmt.Printf("%d counter(s) reset\n", len(resp.Stat))
+}
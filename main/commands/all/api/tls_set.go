@@ -0,0 +1,106 @@
+package api
+
+import (
+	"fmt"
+
+	handlerService "github.com/xtls/xray-core/app/proxyman/command"
+	cserial "github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/infra/conf/serial"
+	"github.com/xtls/xray-core/main/commands/base"
+	"github.com/xtls/xray-core/transport/internet/tls"
+)
+
+var cmdSetTLSPolicy = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api tls [--server=127.0.0.1:8080] -tag=tag <c1.json> [c2.json]...",
+	Short:       "Replace the TLS policy of an inbound",
+	Long: `
+Replace the ALPN, "alpnOrder", "allowAlpnMismatch", versions, cipher
+suites and curve preferences of a TLS inbound with those in the
+"tlsSettings" of the inbound of the same tag in the configs, without
+restarting it. The ALPN is kept if none is in the configs. The
+connections already established are kept.
+
+Arguments:
+
+	-s, -server <server:port>
+		The API server address. Default 127.0.0.1:8080
+
+	-t, -timeout <seconds>
+		Timeout in seconds for calling API. Default 3
+
+	-tag
+		The tag of the inbound.
+
+Example:
+
+	{{.Exec}} {{.LongName}} --server=127.0.0.1:8080 -tag=vless-in config.json
+`,
+	Run: executeSetTLSPolicy,
+}
+
+func executeSetTLSPolicy(cmd *base.Command, args []string) {
+	var tag string
+	setSharedFlags(cmd)
+	cmd.Flag.StringVar(&tag, "tag", "", "")
+	cmd.Flag.Parse(args)
+	if tag == "" {
+		base.Fatalf("no inbound tag specified")
+	}
+	unnamedArgs := cmd.Flag.Args()
+	if len(unnamedArgs) == 0 {
+		fmt.Println("reading from stdin:")
+		unnamedArgs = []string{"stdin:"}
+	}
+
+	var policy *tls.Config
+	for _, arg := range unnamedArgs {
+		r, err := loadArg(arg)
+		if err != nil {
+			base.Fatalf("failed to load %s: %s", arg, err)
+		}
+		conf, err := serial.DecodeJSONConfig(r)
+		if err != nil {
+			base.Fatalf("failed to decode %s: %s", arg, err)
+		}
+		for _, in := range conf.InboundConfigs {
+			if in.Tag != tag {
+				continue
+			}
+			if in.StreamSetting == nil || in.StreamSetting.TLSSettings == nil {
+				base.Fatalf("inbound %s has no tlsSettings", tag)
+			}
+			config, err := in.StreamSetting.TLSSettings.Build()
+			if err != nil {
+				base.Fatalf("failed to build conf: %s", err)
+			}
+			// Just the policy is sent, not the certificates and keys.
+			c := config.(*tls.Config)
+			policy = &tls.Config{
+				NextProtocol:      c.NextProtocol,
+				AlpnOrder:         c.AlpnOrder,
+				AllowAlpnMismatch: c.AllowAlpnMismatch,
+				MinVersion:        c.MinVersion,
+				MaxVersion:        c.MaxVersion,
+				CipherSuites:      c.CipherSuites,
+				CurvePreferences:  c.CurvePreferences,
+			}
+		}
+	}
+	if policy == nil {
+		base.Fatalf("no inbound %s found", tag)
+	}
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := handlerService.NewHandlerServiceClient(conn)
+	resp, err := client.AlterInbound(ctx, &handlerService.AlterInboundRequest{
+		Tag:       tag,
+		Operation: cserial.ToTypedMessage(&handlerService.SetTLSPolicyOperation{Tls: policy}),
+	})
+	if err != nil {
+		base.Fatalf("failed to set TLS policy: %s", err)
+	}
+	showJSONResponse(resp)
+}
@@ -3,6 +3,8 @@ package all
 import (
 	"github.com/xtls/xray-core/main/commands/all/api"
 	"github.com/xtls/xray-core/main/commands/all/convert"
+	"github.com/xtls/xray-core/main/commands/all/geodata"
+	"github.com/xtls/xray-core/main/commands/all/link"
 	"github.com/xtls/xray-core/main/commands/all/tls"
 	"github.com/xtls/xray-core/main/commands/base"
 )
@@ -12,10 +14,14 @@ func init() {
 		base.RootCommand.Commands,
 		api.CmdAPI,
 		convert.CmdConvert,
+		geodata.CmdGeodata,
+		link.CmdLink,
 		tls.CmdTLS,
 		cmdUUID,
 		cmdX25519,
 		cmdWG,
 		cmdMLDSA65,
+		cmdKnock,
+		cmdMeasure,
 	)
 }
@@ -0,0 +1,76 @@
+package convert
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseClash parses the proxies of a Clash config.
+func parseClash(b []byte) ([]*proxyEntry, error) {
+	var config struct {
+		Proxies []map[string]interface{} `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return nil, err
+	}
+	if len(config.Proxies) == 0 {
+		return nil, fmt.Errorf("neither sing-box outbounds nor Clash proxies")
+	}
+	entries := make([]*proxyEntry, 0, len(config.Proxies))
+	for _, m := range config.Proxies {
+		entries = append(entries, clashProxy(m))
+	}
+	return entries, nil
+}
+
+func clashProxy(m map[string]interface{}) *proxyEntry {
+	p := &proxyEntry{
+		name:        getString(m, "name"),
+		protocol:    getString(m, "type"),
+		server:      getString(m, "server"),
+		port:        getInt(m, "port"),
+		id:          getString(m, "uuid"),
+		flow:        getString(m, "flow"),
+		password:    getString(m, "password"),
+		network:     getString(m, "network"),
+		tls:         getBool(m, "tls"),
+		serverName:  getString(m, "servername"),
+		insecure:    getBool(m, "skip-cert-verify"),
+		alpn:        getStrings(m, "alpn"),
+		fingerprint: getString(m, "client-fingerprint"),
+	}
+	if sni := getString(m, "sni"); sni != "" {
+		p.serverName = sni
+	}
+	switch p.protocol {
+	case "vmess":
+		p.security = getString(m, "cipher")
+	case "trojan":
+		p.tls = true
+	case "ss":
+		p.protocol = "shadowsocks"
+		p.method = getString(m, "cipher")
+		p.plugin = getString(m, "plugin")
+	case "hysteria2":
+		p.obfs = getString(m, "obfs")
+		p.obfsPassword = getString(m, "obfs-password")
+		p.down = getInt(m, "down")
+	}
+	if reality := getMap(m, "reality-opts"); reality != nil {
+		p.publicKey = getString(reality, "public-key")
+		p.shortID = getString(reality, "short-id")
+	}
+	switch p.network {
+	case "ws":
+		ws := getMap(m, "ws-opts")
+		p.path = getString(ws, "path")
+		p.host = getString(getMap(ws, "headers"), "Host")
+		if getBool(ws, "v2ray-http-upgrade") {
+			p.network = "httpupgrade"
+		}
+	case "grpc":
+		p.serviceName = getString(getMap(m, "grpc-opts"), "grpc-service-name")
+	}
+	return p
+}
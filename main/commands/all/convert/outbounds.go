@@ -0,0 +1,359 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xtls/xray-core/main/commands/base"
+	"github.com/xtls/xray-core/main/confloader"
+)
+
+var cmdOutbounds = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} convert outbounds [-balancer tag] [-strategy random] [file] [file] ...",
+	Short:       "Convert Clash or sing-box proxies to outbounds",
+	Long: `
+Convert the proxies of Clash YAML configs and the outbounds of sing-box JSON
+configs to a config of the equivalent outbounds, which can be merged into
+other configs. The vmess, vless, trojan, shadowsocks and hysteria2 proxies
+are converted, the others are left out with a warning.
+
+Arguments:
+
+	-balancer
+		Also add a balancer of this tag over the outbounds.
+
+	-strategy
+		The strategy of the balancer: random, roundRobin, leastPing or
+		leastLoad. An observatory of the outbounds is added for leastPing,
+		and a burst observatory for leastLoad. Defaults to random.
+
+Examples:
+
+    {{.Exec}} convert outbounds clash.yaml > outbounds.json
+    {{.Exec}} convert outbounds -balancer proxy -strategy leastPing sing-box.json
+	`,
+	Run: executeConvertOutbounds,
+}
+
+func executeConvertOutbounds(cmd *base.Command, args []string) {
+	var balancer, strategy string
+	cmd.Flag.StringVar(&balancer, "balancer", "", "")
+	cmd.Flag.StringVar(&strategy, "strategy", "random", "")
+	cmd.Flag.Parse(args)
+
+	if cmd.Flag.NArg() < 1 {
+		base.Fatalf("empty input list")
+	}
+	switch strategy {
+	case "random", "roundRobin", "leastPing", "leastLoad":
+	default:
+		base.Fatalf("unknown strategy: %s", strategy)
+	}
+
+	var proxies []*proxyEntry
+	for _, file := range cmd.Flag.Args() {
+		reader, err := confloader.LoadConfig(file)
+		if err != nil {
+			base.Fatalf("failed to load %s: %s", file, err)
+		}
+		b, err := io.ReadAll(reader)
+		if err != nil {
+			base.Fatalf("failed to read %s: %s", file, err)
+		}
+		entries, err := parseProxies(b)
+		if err != nil {
+			base.Fatalf("failed to parse %s: %s", file, err)
+		}
+		proxies = append(proxies, entries...)
+	}
+
+	config := map[string]interface{}{}
+	var outbounds []interface{}
+	tags := make([]string, 0, len(proxies))
+	used := make(map[string]bool, len(proxies))
+	for _, p := range proxies {
+		outbound, err := p.outbound()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "left out %s: %s\n", p.name, err)
+			continue
+		}
+		tag := p.name
+		for i := 2; used[tag] || tag == ""; i++ {
+			tag = p.name + "-" + strconv.Itoa(i)
+		}
+		used[tag] = true
+		outbound["tag"] = tag
+		outbounds = append(outbounds, outbound)
+		tags = append(tags, tag)
+	}
+	if len(outbounds) == 0 {
+		base.Fatalf("no proxy converted")
+	}
+	config["outbounds"] = outbounds
+
+	if balancer != "" {
+		config["routing"] = map[string]interface{}{
+			"balancers": []interface{}{map[string]interface{}{
+				"tag":      balancer,
+				"selector": tags,
+				"strategy": map[string]interface{}{"type": strategy},
+			}},
+		}
+		switch strategy {
+		case "leastPing":
+			config["observatory"] = map[string]interface{}{"subjectSelector": tags}
+		case "leastLoad":
+			config["burstObservatory"] = map[string]interface{}{"subjectSelector": tags, "pingConfig": map[string]interface{}{}}
+		}
+	}
+
+	b, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		base.Fatalf("failed to marshal config: %s", err)
+	}
+	fmt.Println(string(b))
+}
+
+// parseProxies parses the proxies of b, a sing-box config if it is JSON with
+// outbounds, or else a Clash config.
+func parseProxies(b []byte) ([]*proxyEntry, error) {
+	var singBox map[string]interface{}
+	if json.Unmarshal(b, &singBox) == nil {
+		if _, ok := singBox["outbounds"]; ok {
+			return parseSingBox(singBox)
+		}
+	}
+	return parseClash(b)
+}
+
+// proxyEntry is a proxy of a Clash or sing-box config.
+type proxyEntry struct {
+	name     string
+	protocol string
+	server   string
+	port     int
+
+	id       string // vmess and vless
+	security string // of vmess
+	flow     string // of vless
+	password string // trojan, shadowsocks and hysteria2
+	method   string // of shadowsocks
+	plugin   string // of shadowsocks, not supported
+
+	network     string // tcp, ws, grpc or httpupgrade
+	path        string
+	host        string
+	serviceName string
+
+	tls         bool
+	serverName  string
+	insecure    bool
+	alpn        []string
+	fingerprint string
+	publicKey   string // of REALITY
+	shortID     string
+
+	obfs         string // of hysteria2
+	obfsPassword string
+	down         int // Mbps
+}
+
+// outbound returns the outbound of p.
+func (p *proxyEntry) outbound() (map[string]interface{}, error) {
+	if p.server == "" || p.port <= 0 || p.port > 65535 {
+		return nil, fmt.Errorf("invalid server %s:%d", p.server, p.port)
+	}
+	outbound := map[string]interface{}{"protocol": p.protocol}
+	switch p.protocol {
+	case "vmess":
+		security := p.security
+		if security == "" {
+			security = "auto"
+		}
+		outbound["settings"] = map[string]interface{}{"vnext": []interface{}{map[string]interface{}{
+			"address": p.server,
+			"port":    p.port,
+			"users":   []interface{}{map[string]interface{}{"id": p.id, "security": security}},
+		}}}
+	case "vless":
+		user := map[string]interface{}{"id": p.id, "encryption": "none"}
+		if p.flow != "" {
+			user["flow"] = p.flow
+		}
+		outbound["settings"] = map[string]interface{}{"vnext": []interface{}{map[string]interface{}{
+			"address": p.server,
+			"port":    p.port,
+			"users":   []interface{}{user},
+		}}}
+	case "trojan":
+		outbound["settings"] = map[string]interface{}{"servers": []interface{}{map[string]interface{}{
+			"address":  p.server,
+			"port":     p.port,
+			"password": p.password,
+		}}}
+	case "shadowsocks":
+		if p.plugin != "" {
+			return nil, fmt.Errorf("plugin %s is not supported", p.plugin)
+		}
+		outbound["settings"] = map[string]interface{}{"servers": []interface{}{map[string]interface{}{
+			"address":  p.server,
+			"port":     p.port,
+			"method":   p.method,
+			"password": p.password,
+		}}}
+	case "hysteria2":
+		settings := map[string]interface{}{
+			"address":     p.server,
+			"port":        p.port,
+			"password":    p.password,
+			"tlsSettings": p.tlsSettings(),
+		}
+		if p.obfs != "" {
+			settings["obfs"] = map[string]interface{}{"type": p.obfs, "password": p.obfsPassword}
+		}
+		if p.down > 0 {
+			settings["down"] = p.down
+		}
+		outbound["settings"] = settings
+		return outbound, nil
+	default:
+		return nil, fmt.Errorf("type %s is not supported", p.protocol)
+	}
+
+	stream, err := p.streamSettings()
+	if err != nil {
+		return nil, err
+	}
+	if len(stream) > 0 {
+		outbound["streamSettings"] = stream
+	}
+	return outbound, nil
+}
+
+func (p *proxyEntry) streamSettings() (map[string]interface{}, error) {
+	stream := map[string]interface{}{}
+	switch p.network {
+	case "", "tcp":
+	case "ws":
+		stream["network"] = "ws"
+		stream["wsSettings"] = p.httpSettings()
+	case "httpupgrade":
+		stream["network"] = "httpupgrade"
+		stream["httpupgradeSettings"] = p.httpSettings()
+	case "grpc":
+		stream["network"] = "grpc"
+		stream["grpcSettings"] = map[string]interface{}{"serviceName": p.serviceName}
+	default:
+		return nil, fmt.Errorf("transport %s is not supported", p.network)
+	}
+	switch {
+	case p.publicKey != "":
+		reality := map[string]interface{}{
+			"serverName": p.serverName,
+			"publicKey":  p.publicKey,
+			"shortId":    p.shortID,
+		}
+		fingerprint := p.fingerprint
+		if fingerprint == "" {
+			fingerprint = "chrome"
+		}
+		reality["fingerprint"] = fingerprint
+		stream["security"] = "reality"
+		stream["realitySettings"] = reality
+	case p.tls:
+		stream["security"] = "tls"
+		stream["tlsSettings"] = p.tlsSettings()
+	}
+	return stream, nil
+}
+
+func (p *proxyEntry) httpSettings() map[string]interface{} {
+	settings := map[string]interface{}{"path": p.path}
+	if p.host != "" {
+		settings["host"] = p.host
+	}
+	return settings
+}
+
+func (p *proxyEntry) tlsSettings() map[string]interface{} {
+	settings := map[string]interface{}{}
+	if p.serverName != "" {
+		settings["serverName"] = p.serverName
+	}
+	if p.insecure {
+		settings["allowInsecure"] = true
+	}
+	if len(p.alpn) > 0 {
+		settings["alpn"] = p.alpn
+	}
+	if p.fingerprint != "" {
+		settings["fingerprint"] = p.fingerprint
+	}
+	return settings
+}
+
+// The getters of the values of decoded configs, which are of the types of
+// the decoders, or of strings for numbers and bools.
+
+func getMap(m map[string]interface{}, key string) map[string]interface{} {
+	v, _ := m[key].(map[string]interface{})
+	return v
+}
+
+func getString(m map[string]interface{}, key string) string {
+	switch v := m[key].(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func getInt(m map[string]interface{}, key string) int {
+	switch v := m[key].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case string:
+		// Such as "100 Mbps".
+		n, _ := strconv.Atoi(strings.Fields(v + " ")[0])
+		return n
+	default:
+		return 0
+	}
+}
+
+func getBool(m map[string]interface{}, key string) bool {
+	switch v := m[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	default:
+		return false
+	}
+}
+
+func getStrings(m map[string]interface{}, key string) []string {
+	switch v := m[key].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		s := make([]string, 0, len(v))
+		for _, e := range v {
+			s = append(s, fmt.Sprint(e))
+		}
+		return s
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,78 @@
+package convert
+
+import (
+	"fmt"
+)
+
+// singBoxOthers are the types of sing-box outbounds that are not proxies,
+// left out without warnings.
+var singBoxOthers = map[string]bool{
+	"direct":   true,
+	"block":    true,
+	"dns":      true,
+	"selector": true,
+	"urltest":  true,
+}
+
+// parseSingBox parses the outbounds of a sing-box config.
+func parseSingBox(config map[string]interface{}) ([]*proxyEntry, error) {
+	outbounds, ok := config["outbounds"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid sing-box outbounds")
+	}
+	entries := make([]*proxyEntry, 0, len(outbounds))
+	for _, o := range outbounds {
+		m, ok := o.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid sing-box outbound: %v", o)
+		}
+		if singBoxOthers[getString(m, "type")] {
+			continue
+		}
+		entries = append(entries, singBoxProxy(m))
+	}
+	return entries, nil
+}
+
+func singBoxProxy(m map[string]interface{}) *proxyEntry {
+	p := &proxyEntry{
+		name:     getString(m, "tag"),
+		protocol: getString(m, "type"),
+		server:   getString(m, "server"),
+		port:     getInt(m, "server_port"),
+		id:       getString(m, "uuid"),
+		security: getString(m, "security"),
+		flow:     getString(m, "flow"),
+		password: getString(m, "password"),
+		method:   getString(m, "method"),
+		plugin:   getString(m, "plugin"),
+		down:     getInt(m, "down_mbps"),
+	}
+	if obfs := getMap(m, "obfs"); obfs != nil {
+		p.obfs = getString(obfs, "type")
+		p.obfsPassword = getString(obfs, "password")
+	}
+	if tls := getMap(m, "tls"); tls != nil {
+		p.tls = getBool(tls, "enabled")
+		p.serverName = getString(tls, "server_name")
+		p.insecure = getBool(tls, "insecure")
+		p.alpn = getStrings(tls, "alpn")
+		if utls := getMap(tls, "utls"); getBool(utls, "enabled") {
+			p.fingerprint = getString(utls, "fingerprint")
+		}
+		if reality := getMap(tls, "reality"); getBool(reality, "enabled") {
+			p.publicKey = getString(reality, "public_key")
+			p.shortID = getString(reality, "short_id")
+		}
+	}
+	if transport := getMap(m, "transport"); transport != nil {
+		p.network = getString(transport, "type")
+		p.path = getString(transport, "path")
+		p.serviceName = getString(transport, "service_name")
+		p.host = getString(transport, "host")
+		if hosts := getStrings(getMap(transport, "headers"), "Host"); len(hosts) > 0 {
+			p.host = hosts[0]
+		}
+	}
+	return p
+}
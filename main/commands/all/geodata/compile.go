@@ -0,0 +1,55 @@
+package geodata
+
+import (
+	"fmt"
+
+	"github.com/xtls/xray-core/common/geodata"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdCompile = &base.Command{
+	UsageLine: "{{.Exec}} geodata compile [-o output] <file> [file] ...",
+	Short:     "Compile geodata files",
+	Long: `
+Compile geoip and geosite files into the indexed form, which Xray loads a
+code of without scanning the file. The compiled files are used in place of
+the plain ones, under the same names.
+
+Arguments:
+
+	-o <file>
+		The output file, for the only input file. Without it, each file is
+		compiled in place.
+
+Examples:
+
+    {{.Exec}} {{.LongName}} geoip.dat geosite.dat
+    {{.Exec}} {{.LongName}} -o /usr/local/share/xray/geosite.dat geosite.dat
+	`,
+}
+
+func init() {
+	cmdCompile.Run = executeCompile // break init loop
+}
+
+var compileOutput = cmdCompile.Flag.String("o", "", "")
+
+func executeCompile(cmd *base.Command, args []string) {
+	files := cmd.Flag.Args()
+	if len(files) == 0 {
+		base.Fatalf("no geodata file to compile")
+	}
+	if *compileOutput != "" && len(files) != 1 {
+		base.Fatalf("-o is for a single input file")
+	}
+	for _, file := range files {
+		output := file
+		if *compileOutput != "" {
+			output = *compileOutput
+		}
+		if err := geodata.Compile(output, file); err != nil {
+			base.Fatalf("failed to compile %s: %s", file, err)
+		}
+		fmt.Println(file, "compiled to", output)
+	}
+}
@@ -0,0 +1,17 @@
+package geodata
+
+import (
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+// CmdGeodata holds all geodata sub commands
+var CmdGeodata = &base.Command{
+	UsageLine: "{{.Exec}} geodata",
+	Short:     "Geodata tools",
+	Long: `{{.Exec}} {{.LongName}} provides tools for geoip and geosite files.
+`,
+	Commands: []*base.Command{
+		cmdCompile,
+		cmdList,
+	},
+}
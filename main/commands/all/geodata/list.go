@@ -0,0 +1,37 @@
+package geodata
+
+import (
+	"fmt"
+
+	"github.com/xtls/xray-core/common/geodata"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdList = &base.Command{
+	UsageLine: "{{.Exec}} geodata list [geodata file]",
+	Short:     "List the codes of a geodata file",
+	Long: `
+List the codes of a geoip or geosite file, plain or compiled.
+
+Example:
+
+    {{.Exec}} {{.LongName}} geosite.dat
+	`,
+}
+
+func init() {
+	cmdList.Run = executeList // break init loop
+}
+
+func executeList(cmd *base.Command, args []string) {
+	if cmd.Flag.NArg() != 1 {
+		base.Fatalf("a single geodata file is required")
+	}
+	file, err := geodata.Open(cmd.Flag.Arg(0))
+	if err != nil {
+		base.Fatalf("%s", err)
+	}
+	for _, code := range file.Codes() {
+		fmt.Println(code)
+	}
+}
@@ -0,0 +1,54 @@
+package all
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/xtls/xray-core/common/knock"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdKnock = &base.Command{
+	UsageLine: `{{.Exec}} knock [-key "key"] <host:port>`,
+	Short:     `Knock at an inbound kept dark`,
+	Long: `
+Knock at an inbound kept dark by its "knock" settings, sending a packet
+authenticated by the key to the UDP port of the knocks. The inbound then
+accepts the connections of this host for the ttl of its settings.
+
+Arguments:
+
+	-key
+		The key of the knock settings of the inbound.
+
+Example:
+
+	{{.Exec}} knock -key "secret" example.com:62201
+`,
+}
+
+func init() {
+	cmdKnock.Run = executeKnock // break init loop
+}
+
+var knockKey = cmdKnock.Flag.String("key", "", "")
+
+func executeKnock(cmd *base.Command, args []string) {
+	if cmdKnock.Flag.NArg() < 1 {
+		base.Fatalf("address not specified")
+	}
+	if len(*knockKey) == 0 {
+		base.Fatalf("key not specified")
+	}
+	address := cmdKnock.Flag.Arg(0)
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		base.Fatalf("failed to knock at %s: %s", address, err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(knock.NewPacket([]byte(*knockKey), time.Now())); err != nil {
+		base.Fatalf("failed to knock at %s: %s", address, err)
+	}
+	fmt.Println("Knocked at", conn.RemoteAddr())
+}
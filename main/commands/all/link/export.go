@@ -0,0 +1,387 @@
+package link
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/common/cmdarg"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/main/commands/base"
+	"github.com/xtls/xray-core/proxy/shadowsocks"
+	"github.com/xtls/xray-core/proxy/shadowsocks_2022"
+	"github.com/xtls/xray-core/proxy/trojan"
+	"github.com/xtls/xray-core/proxy/vless"
+	vlessinbound "github.com/xtls/xray-core/proxy/vless/inbound"
+	"github.com/xtls/xray-core/proxy/vmess"
+	vmessinbound "github.com/xtls/xray-core/proxy/vmess/inbound"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/grpc"
+	"github.com/xtls/xray-core/transport/internet/httpupgrade"
+	"github.com/xtls/xray-core/transport/internet/reality"
+	"github.com/xtls/xray-core/transport/internet/splithttp"
+	"github.com/xtls/xray-core/transport/internet/tls"
+	"github.com/xtls/xray-core/transport/internet/websocket"
+	"golang.org/x/crypto/curve25519"
+)
+
+var cmdExport = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} link export [-c config.json] -tag tag [-address address]",
+	Short:       "Export the share links of an inbound",
+	Long: `
+Export the share links of the users of a vless, vmess, trojan or shadowsocks
+inbound, one per line, named by the emails of the users. The links carry the
+transport and the TLS or REALITY settings of the inbound, with the public key
+of REALITY derived from its private key.
+
+Arguments:
+
+	-c, -config
+		Config file, may be used more than once. Defaults to config.json.
+
+	-tag
+		The tag of the inbound.
+
+	-address
+		The address of the server in the links. Defaults to the listen
+		address of the inbound, if it is not of all interfaces.
+
+Example:
+
+	{{.Exec}} link export -c config.json -tag vless -address example.com
+`,
+	Run: executeExport,
+}
+
+func executeExport(cmd *base.Command, args []string) {
+	var configs cmdarg.Arg
+	var tag, address string
+	cmd.Flag.Var(&configs, "c", "")
+	cmd.Flag.Var(&configs, "config", "")
+	cmd.Flag.StringVar(&tag, "tag", "", "")
+	cmd.Flag.StringVar(&address, "address", "", "")
+	cmd.Flag.Parse(args)
+
+	if tag == "" {
+		base.Fatalf("empty inbound tag")
+	}
+	if len(configs) == 0 {
+		configs.Set("config.json")
+	}
+	config, err := core.LoadConfig("auto", configs)
+	if err != nil {
+		base.Fatalf("failed to load config: %s", err)
+	}
+	var inbound *core.InboundHandlerConfig
+	for _, i := range config.Inbound {
+		if i.Tag == tag {
+			inbound = i
+		}
+	}
+	if inbound == nil {
+		base.Fatalf("inbound %s not found", tag)
+	}
+	links, err := exportLinks(inbound, address)
+	if err != nil {
+		base.Fatalf("failed to export inbound %s: %s", tag, err)
+	}
+	for _, link := range links {
+		fmt.Println(link)
+	}
+}
+
+// exportLinks returns the links of the users of inbound, at address or at the
+// listen address of inbound if address is empty.
+func exportLinks(inbound *core.InboundHandlerConfig, address string) ([]string, error) {
+	r, err := inbound.ReceiverSettings.GetInstance()
+	if err != nil {
+		return nil, err
+	}
+	receiver, ok := r.(*proxyman.ReceiverConfig)
+	if !ok {
+		return nil, fmt.Errorf("not an inbound of ports")
+	}
+	if address == "" {
+		if receiver.Listen != nil {
+			if listen := receiver.Listen.AsAddress(); listen != net.AnyIP && listen != net.AnyIPv6 {
+				address = listen.String()
+			}
+		}
+		if address == "" {
+			return nil, fmt.Errorf("listening on all interfaces, specify -address")
+		}
+	}
+	if receiver.PortList == nil || len(receiver.PortList.Range) == 0 {
+		return nil, fmt.Errorf("no port")
+	}
+	host := net.JoinHostPort(address, strconv.Itoa(int(receiver.PortList.Range[0].From)))
+
+	params, err := streamParams(receiver.StreamSettings, address)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := inbound.ProxySettings.GetInstance()
+	if err != nil {
+		return nil, err
+	}
+	name := func(user *protocol.User) string {
+		if user.Email != "" {
+			return user.Email
+		}
+		return inbound.Tag
+	}
+
+	var links []string
+	switch settings := settings.(type) {
+	case *vlessinbound.Config:
+		for _, user := range settings.Clients {
+			a, err := user.Account.GetInstance()
+			if err != nil {
+				return nil, err
+			}
+			account := a.(*vless.Account)
+			query := cloneValues(params)
+			query.Set("encryption", "none")
+			if account.Flow != "" {
+				query.Set("flow", account.Flow)
+			}
+			links = append(links, uriLink("vless", url.User(account.Id), host, query, name(user)))
+		}
+	case *vmessinbound.Config:
+		for _, user := range settings.User {
+			link, err := vmessLink(user, address, receiver.PortList.Range[0].From, params, name(user))
+			if err != nil {
+				return nil, err
+			}
+			links = append(links, link)
+		}
+	case *trojan.ServerConfig:
+		for _, user := range settings.Users {
+			a, err := user.Account.GetInstance()
+			if err != nil {
+				return nil, err
+			}
+			account := a.(*trojan.Account)
+			query := cloneValues(params)
+			if account.Flow != "" {
+				query.Set("flow", account.Flow)
+			}
+			links = append(links, uriLink("trojan", url.User(account.Password), host, query, name(user)))
+		}
+	case *shadowsocks.ServerConfig:
+		for _, user := range settings.Users {
+			a, err := user.Account.GetInstance()
+			if err != nil {
+				return nil, err
+			}
+			account := a.(*shadowsocks.Account)
+			method, ok := shadowsocksMethods[account.CipherType]
+			if !ok {
+				return nil, fmt.Errorf("cipher %s has no link", account.CipherType)
+			}
+			links = append(links, shadowsocksLink(method, account.Password, host, params, name(user)))
+		}
+	case *shadowsocks_2022.ServerConfig:
+		links = append(links, shadowsocksLink(settings.Method, settings.Key, host, params, settings.Email))
+	case *shadowsocks_2022.MultiUserServerConfig:
+		for _, user := range settings.Users {
+			a, err := user.Account.GetInstance()
+			if err != nil {
+				return nil, err
+			}
+			key := settings.Key + ":" + a.(*shadowsocks_2022.Account).Key
+			links = append(links, shadowsocksLink(settings.Method, key, host, params, name(user)))
+		}
+	default:
+		return nil, fmt.Errorf("links of %T are not supported", settings)
+	}
+	if len(links) == 0 {
+		return nil, fmt.Errorf("no user")
+	}
+	return links, nil
+}
+
+// shadowsocksMethods are the methods of ss:// links of the ciphers.
+var shadowsocksMethods = map[shadowsocks.CipherType]string{
+	shadowsocks.CipherType_AES_128_GCM:        "aes-128-gcm",
+	shadowsocks.CipherType_AES_256_GCM:        "aes-256-gcm",
+	shadowsocks.CipherType_CHACHA20_POLY1305:  "chacha20-ietf-poly1305",
+	shadowsocks.CipherType_XCHACHA20_POLY1305: "xchacha20-ietf-poly1305",
+	shadowsocks.CipherType_NONE:               "none",
+}
+
+// streamParams returns the query parameters of the links of the transport and
+// security of config, for a server at address.
+func streamParams(config *internet.StreamConfig, address string) (url.Values, error) {
+	params := url.Values{}
+	protocolName := config.GetEffectiveProtocol()
+	transport, err := config.GetTransportSettingsFor(protocolName)
+	if err != nil {
+		return nil, err
+	}
+	switch protocolName {
+	case "tcp":
+		params.Set("type", "tcp")
+	case "websocket":
+		params.Set("type", "ws")
+		c := transport.(*websocket.Config)
+		setParam(params, "host", c.Host)
+		setParam(params, "path", c.Path)
+	case "httpupgrade":
+		params.Set("type", "httpupgrade")
+		c := transport.(*httpupgrade.Config)
+		setParam(params, "host", c.Host)
+		setParam(params, "path", c.Path)
+	case "splithttp":
+		params.Set("type", "xhttp")
+		c := transport.(*splithttp.Config)
+		setParam(params, "host", c.Host)
+		setParam(params, "path", c.Path)
+		setParam(params, "mode", c.Mode)
+	case "grpc":
+		params.Set("type", "grpc")
+		c := transport.(*grpc.Config)
+		setParam(params, "serviceName", c.ServiceName)
+		if c.MultiMode {
+			params.Set("mode", "multi")
+		}
+	default:
+		return nil, fmt.Errorf("transport %s has no link", protocolName)
+	}
+
+	var security interface{}
+	if config != nil && config.HasSecuritySettings() {
+		security, err = config.GetEffectiveSecuritySettings()
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch security := security.(type) {
+	case nil:
+		params.Set("security", "none")
+	case *tls.Config:
+		params.Set("security", "tls")
+		if security.ServerName != "" {
+			params.Set("sni", security.ServerName)
+		} else if net.ParseAddress(address).Family().IsDomain() {
+			params.Set("sni", address)
+		}
+		if len(security.NextProtocol) > 0 {
+			params.Set("alpn", strings.Join(security.NextProtocol, ","))
+		}
+	case *reality.Config:
+		params.Set("security", "reality")
+		if len(security.ServerNames) == 0 {
+			return nil, fmt.Errorf("REALITY without server names")
+		}
+		params.Set("sni", security.ServerNames[0])
+		params.Set("fp", "chrome")
+		publicKey, err := curve25519.X25519(security.PrivateKey, curve25519.Basepoint)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("pbk", base64.RawURLEncoding.EncodeToString(publicKey))
+		if len(security.ShortIds) > 0 {
+			params.Set("sid", hex.EncodeToString(security.ShortIds[0]))
+		}
+		if len(security.Mldsa65Seed) == mldsa65.SeedSize {
+			var seed [mldsa65.SeedSize]byte
+			copy(seed[:], security.Mldsa65Seed)
+			verify, _ := mldsa65.NewKeyFromSeed(&seed)
+			params.Set("pqv", base64.RawURLEncoding.EncodeToString(verify.Bytes()))
+		}
+	default:
+		return nil, fmt.Errorf("security %T has no link", security)
+	}
+	return params, nil
+}
+
+func setParam(params url.Values, key, value string) {
+	if value != "" {
+		params.Set(key, value)
+	}
+}
+
+func cloneValues(v url.Values) url.Values {
+	c := make(url.Values, len(v))
+	for key, values := range v {
+		c[key] = append([]string(nil), values...)
+	}
+	return c
+}
+
+func uriLink(scheme string, user *url.Userinfo, host string, query url.Values, name string) string {
+	u := &url.URL{
+		Scheme:   scheme,
+		User:     user,
+		Host:     host,
+		RawQuery: query.Encode(),
+		Fragment: name,
+	}
+	return u.String()
+}
+
+// shadowsocksLink returns the SIP002 link, with the user info in base64 but for
+// the 2022 methods.
+func shadowsocksLink(method, password, host string, params url.Values, name string) string {
+	var user *url.Userinfo
+	if strings.HasPrefix(method, "2022-") {
+		user = url.UserPassword(method, password)
+	} else {
+		user = url.User(base64.RawURLEncoding.EncodeToString([]byte(method + ":" + password)))
+	}
+	query := url.Values{}
+	if params.Get("type") != "tcp" || params.Get("security") != "none" {
+		query = params
+	}
+	return uriLink("ss", user, host, query, name)
+}
+
+// vmessLink returns the link of the base64 JSON of v2rayN.
+func vmessLink(user *protocol.User, address string, port uint32, params url.Values, name string) (string, error) {
+	a, err := user.Account.GetInstance()
+	if err != nil {
+		return "", err
+	}
+	account := a.(*vmess.Account)
+	path := params.Get("path")
+	if params.Get("type") == "grpc" {
+		path = params.Get("serviceName")
+	}
+	security := params.Get("security")
+	if security == "none" {
+		security = ""
+	}
+	b, err := json.Marshal(map[string]string{
+		"v":    "2",
+		"ps":   name,
+		"add":  address,
+		"port": strconv.Itoa(int(port)),
+		"id":   account.Id,
+		"aid":  "0",
+		"scy":  "auto",
+		"net":  params.Get("type"),
+		"type": "none",
+		"host": params.Get("host"),
+		"path": path,
+		"tls":  security,
+		"sni":  params.Get("sni"),
+		"alpn": params.Get("alpn"),
+		"fp":   params.Get("fp"),
+		"pbk":  params.Get("pbk"),
+		"sid":  params.Get("sid"),
+	})
+	if err != nil {
+		return "", err
+	}
+	return "vmess://" + base64.StdEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,40 @@
+package link
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/xtls/xray-core/infra/conf/link"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdImport = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} link import [link]",
+	Short:       "Import a share link as an outbound",
+	Long: `
+Import a vless://, vmess://, trojan:// or ss:// share link, printing the JSON
+of the equivalent outbound, tagged by the name of the link.
+
+Example:
+
+	{{.Exec}} link import "vless://27848739-7e62-4138-9fd3-098a63964b6b@example.com:443?security=tls&type=ws&path=%2Fws#proxy"
+`,
+	Run: executeImport,
+}
+
+func executeImport(cmd *base.Command, args []string) {
+	cmd.Flag.Parse(args)
+	if cmd.Flag.NArg() != 1 {
+		base.Fatalf("expected exactly one link")
+	}
+	outbound, err := link.Parse(cmd.Flag.Arg(0))
+	if err != nil {
+		base.Fatalf("failed to import link: %s", err)
+	}
+	b, err := json.MarshalIndent(outbound, "", "  ")
+	if err != nil {
+		base.Fatalf("failed to marshal outbound: %s", err)
+	}
+	fmt.Println(string(b))
+}
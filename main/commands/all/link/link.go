@@ -0,0 +1,18 @@
+package link
+
+import (
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+// CmdLink holds all share link sub commands
+var CmdLink = &base.Command{
+	UsageLine: "{{.Exec}} link",
+	Short:     "Share link tools",
+	Long: `{{.Exec}} {{.LongName}} provides tools for the share links of vless://,
+vmess://, trojan:// and ss:// URIs.
+`,
+	Commands: []*base.Command{
+		cmdExport,
+		cmdImport,
+	},
+}
@@ -0,0 +1,278 @@
+package all
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/xtls/xray-core/common/cmdarg"
+	xnet "github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdMeasure = &base.Command{
+	UsageLine: `{{.Exec}} measure [-c config.json] [-outbound tag] [-url url] [-download url] [-rounds 3] [-duration 10s] [-timeout 10s]`,
+	Short:     `Measure the latency and throughput of outbounds`,
+	Long: `
+Measure the outbounds of a config: it runs the config without its inbounds
+and API, and requests a test URL through each outbound in turn, printing a
+table of the best of the rounds of:
+
+	HANDSHAKE   the time to the end of the TLS handshake with the test URL,
+	            through the outbound, for an https test URL
+	TTFB        the time to the first byte of the response to the test URL
+	THROUGHPUT  the rate of downloading the download URL for the duration
+
+A new connection is used for every request.
+
+Arguments:
+
+	-c, -config
+		Config file, may be used more than once. Defaults to config.json.
+
+	-outbound
+		Tag of an outbound to measure, may be used more than once. Defaults
+		to all the tagged outbounds of the config.
+
+	-url
+		The test URL. Defaults to https://www.google.com/generate_204.
+
+	-download
+		The URL to measure the throughput with. Not measured if empty.
+
+	-rounds
+		Requests of the test URL per outbound. Defaults to 3.
+
+	-duration
+		The most time to download for. Defaults to 10s.
+
+	-timeout
+		The timeout of a request of the test URL. Defaults to 10s.
+
+Example:
+
+	{{.Exec}} measure -c config.json -download https://speed.cloudflare.com/__down?bytes=100000000
+`,
+}
+
+func init() {
+	cmdMeasure.Run = executeMeasure // break init loop
+}
+
+var (
+	measureConfigs   cmdarg.Arg
+	measureOutbounds cmdarg.Arg
+	measureURL       = cmdMeasure.Flag.String("url", "https://www.google.com/generate_204", "")
+	measureDownload  = cmdMeasure.Flag.String("download", "", "")
+	measureRounds    = cmdMeasure.Flag.Int("rounds", 3, "")
+	measureDuration  = cmdMeasure.Flag.Duration("duration", 10*time.Second, "")
+	measureTimeout   = cmdMeasure.Flag.Duration("timeout", 10*time.Second, "")
+)
+
+func init() {
+	cmdMeasure.Flag.Var(&measureConfigs, "c", "")
+	cmdMeasure.Flag.Var(&measureConfigs, "config", "")
+	cmdMeasure.Flag.Var(&measureOutbounds, "outbound", "")
+}
+
+// measureApps are the types of the apps that measure leaves out, as they
+// listen as inbounds.
+var measureApps = map[string]bool{
+	"xray.app.commander.Config": true,
+	"xray.app.metrics.Config":   true,
+}
+
+func executeMeasure(cmd *base.Command, args []string) {
+	if len(measureConfigs) == 0 {
+		measureConfigs.Set("config.json")
+	}
+	if *measureRounds < 1 {
+		base.Fatalf("invalid rounds: %d", *measureRounds)
+	}
+	config, err := core.LoadConfig("auto", measureConfigs)
+	if err != nil {
+		base.Fatalf("failed to load config: %s", err)
+	}
+	config.Inbound = nil
+	apps := config.App[:0]
+	for _, app := range config.App {
+		if !measureApps[app.Type] {
+			apps = append(apps, app)
+		}
+	}
+	config.App = apps
+
+	tags := []string(measureOutbounds)
+	if len(tags) == 0 {
+		for _, outbound := range config.Outbound {
+			if outbound.Tag != "" {
+				tags = append(tags, outbound.Tag)
+			}
+		}
+	}
+	if len(tags) == 0 {
+		base.Fatalf("no tagged outbound to measure")
+	}
+
+	server, err := core.New(config)
+	if err != nil {
+		base.Fatalf("failed to create server: %s", err)
+	}
+	if err := server.Start(); err != nil {
+		base.Fatalf("failed to start server: %s", err)
+	}
+	defer server.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OUTBOUND\tHANDSHAKE\tTTFB\tTHROUGHPUT\tERROR")
+	for _, tag := range tags {
+		m := measureOutbound(server, tag)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", tag, formatLatency(m.handshake), formatLatency(m.ttfb), formatThroughput(m.throughput), m.err)
+	}
+	w.Flush()
+}
+
+// measurement is of an outbound. Zero values are of what is not measured.
+type measurement struct {
+	handshake  time.Duration
+	ttfb       time.Duration
+	throughput float64 // bits per second
+	err        string
+}
+
+func measureOutbound(server *core.Instance, tag string) *measurement {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				dest, err := xnet.ParseDestination(network + ":" + addr)
+				if err != nil {
+					return nil, err
+				}
+				content := new(session.Content)
+				content.SkipDNSResolve = true
+				ctx = session.ContextWithContent(ctx, content)
+				ctx = session.SetForcedOutboundTagToContext(ctx, tag)
+				return core.Dial(ctx, server, dest)
+			},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	m := new(measurement)
+	for i := 0; i < *measureRounds; i++ {
+		handshake, ttfb, err := measureRequest(client)
+		if err != nil {
+			m.err = err.Error()
+			continue
+		}
+		if m.ttfb == 0 || ttfb < m.ttfb {
+			m.ttfb = ttfb
+		}
+		if handshake > 0 && (m.handshake == 0 || handshake < m.handshake) {
+			m.handshake = handshake
+		}
+	}
+	if m.ttfb == 0 || *measureDownload == "" {
+		return m
+	}
+	throughput, err := measureThroughput(client)
+	if err != nil {
+		m.err = err.Error()
+	}
+	m.throughput = throughput
+	return m
+}
+
+// measureRequest requests the test URL, returning the time to the end of the
+// TLS handshake, if any, and to the first byte of the response.
+func measureRequest(client *http.Client) (time.Duration, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *measureTimeout)
+	defer cancel()
+
+	var start time.Time
+	var handshake, ttfb time.Duration
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			handshake = time.Since(start)
+		},
+		GotFirstResponseByte: func() {
+			ttfb = time.Since(start)
+		},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, *measureURL, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	start = time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	resp.Body.Close()
+	return handshake, ttfb, nil
+}
+
+// measureThroughput downloads the download URL for up to the duration,
+// returning the rate from the first byte of the response.
+func measureThroughput(client *http.Client) (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *measureTimeout+*measureDuration)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, *measureDownload, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("download: %s", resp.Status)
+	}
+
+	start := time.Now()
+	deadline := time.AfterFunc(*measureDuration, cancel)
+	defer deadline.Stop()
+	n, err := io.Copy(io.Discard, resp.Body)
+	elapsed := time.Since(start)
+	if err != nil && elapsed < *measureDuration {
+		return 0, err
+	}
+	if elapsed <= 0 {
+		return 0, nil
+	}
+	return float64(n) * 8 / elapsed.Seconds(), nil
+}
+
+func formatLatency(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+func formatThroughput(bps float64) string {
+	switch {
+	case bps == 0:
+		return "-"
+	case bps >= 1e9:
+		return fmt.Sprintf("%.2f Gbps", bps/1e9)
+	case bps >= 1e6:
+		return fmt.Sprintf("%.2f Mbps", bps/1e6)
+	default:
+		return fmt.Sprintf("%.2f Kbps", bps/1e3)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/platform/ctlcmd"
 	"github.com/xtls/xray-core/main/confloader"
+	"github.com/xtls/xray-core/transport/internet/tls"
 )
 
 func ConfigLoader(arg string) (out io.Reader, err error) {
@@ -45,9 +46,7 @@ func FetchHTTPContent(target string) ([]byte, error) {
 		return nil, errors.New("invalid scheme: ", parsedTarget.Scheme)
 	}
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
+	client := tls.NewHTTPClient(30 * time.Second)
 	resp, err := client.Do(&http.Request{
 		Method: "GET",
 		URL:    parsedTarget,
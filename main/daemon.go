@@ -0,0 +1,259 @@
+package main
+
+import (
+	goerrors "errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	applog "github.com/xtls/xray-core/app/log"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+// daemonEnv marks the process started in background by run -daemon.
+const daemonEnv = "XRAY_DAEMON_CHILD"
+
+const (
+	// daemonStartTimeout is how long run -daemon waits for the daemon to start.
+	daemonStartTimeout = 30 * time.Second
+	// daemonStopTimeout is how long restart waits for the daemon to exit.
+	daemonStopTimeout = 10 * time.Second
+)
+
+var errNotRunning = errors.New("not running")
+
+var cmdStop = &base.Command{
+	UsageLine: "{{.Exec}} stop [-pidfile file] [-timeout seconds]",
+	Short:     "Stop Xray running in background",
+	Long: `
+Stop Xray started in background by "run -daemon", with the pid in the
+pidfile.
+
+The -pidfile=file flag sets the pidfile of the daemon. Default
+"xray.pid" in the temp directory.
+
+The -timeout=seconds flag sets how long to wait for the daemon to exit,
+which includes draining its connections. Default 10.
+	`,
+}
+
+var cmdRestart = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} restart [-pidfile file] [-c config.json] [-confdir dir]",
+	Short:       "Restart Xray running in background",
+	Long: `
+Restart Xray in background: stop the daemon with the pid in the pidfile,
+if it is running, and start it again with the flags of run, which are
+accepted by restart.
+
+Example:
+
+	{{.Exec}} restart -pidfile /var/run/xray.pid -c /etc/xray/config.json
+	`,
+}
+
+var (
+	stopPidFile = cmdStop.Flag.String("pidfile", defaultPidFile(), "")
+	stopTimeout = cmdStop.Flag.Int("timeout", int(daemonStopTimeout/time.Second), "")
+)
+
+func init() {
+	cmdStop.Run = executeStop
+	cmdRestart.Run = executeRestart
+}
+
+func defaultPidFile() string {
+	return filepath.Join(os.TempDir(), "xray.pid")
+}
+
+func executeStop(cmd *base.Command, args []string) {
+	if err := stopDaemon(*stopPidFile, time.Duration(*stopTimeout)*time.Second); err != nil {
+		base.Fatalf("failed to stop Xray: %s", err)
+	}
+	fmt.Println("Xray stopped.")
+}
+
+func executeRestart(cmd *base.Command, args []string) {
+	cmdRun.Flag.Parse(args)
+	// Load the config before stopping the daemon, to keep it running if the
+	// config is broken, and to wait for it to drain.
+	config, err := core.LoadConfig(getConfigFormat(), getConfigFilePath(true))
+	if err != nil {
+		base.Fatalf("failed to load config: %s", err)
+	}
+	if err := stopDaemon(*pidFile, daemonStopTimeout+configDrainTimeout(config)); err != nil && err != errNotRunning {
+		base.Fatalf("failed to stop Xray: %s", err)
+	}
+	os.Exit(startDaemon())
+}
+
+// isDaemon returns whether this process is the one started in background by
+// run -daemon, which writes the pidfile.
+func isDaemon() bool {
+	return os.Getenv(daemonEnv) == "1"
+}
+
+// startDaemon starts Xray in background with the config of the flags of run,
+// and waits for it to start, which it tells by writing its pid to the
+// pidfile. It returns the exit code of run.
+func startDaemon() int {
+	if pid, err := readPidFile(*pidFile); err == nil && processAlive(pid) {
+		log.Println("Xray is already running with pid", pid)
+		return 1
+	}
+
+	files := getConfigFilePath(true)
+	for _, file := range files {
+		if file == "stdin:" {
+			log.Println("Failed to start: the config of a daemon can not be read from stdin")
+			return 23
+		}
+	}
+	config, err := core.LoadConfig(getConfigFormat(), files)
+	if err != nil {
+		log.Println("Failed to start:", err)
+		return 23
+	}
+	outputPath := daemonOutputPath(config)
+	output, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Println("Failed to open the log file of the daemon:", err)
+		return 1
+	}
+	defer output.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		log.Println("Failed to start:", err)
+		return 1
+	}
+	args := []string{"run", "-daemon", "-pidfile", *pidFile, "-format", *format}
+	if isDrainTimeoutSet() {
+		args = append(args, "-drain-timeout", drainTimeout.String())
+	}
+	for _, file := range files {
+		args = append(args, "-c", file)
+	}
+	cmd := exec.Command(executable, args...)
+	cmd.Env = append(os.Environ(), daemonEnv+"=1")
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := detach(cmd); err != nil {
+		log.Println("Failed to start in background:", err)
+		return 1
+	}
+	if err := cmd.Start(); err != nil {
+		log.Println("Failed to start in background:", err)
+		return 1
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	deadline := time.After(daemonStartTimeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-exited:
+			log.Println("Failed to start, see", outputPath)
+			if code := cmd.ProcessState.ExitCode(); code > 0 {
+				return code
+			}
+			return 1
+		case <-deadline:
+			log.Println("Xray is still starting in background with pid", cmd.Process.Pid)
+			return 0
+		case <-ticker.C:
+			if pid, err := readPidFile(*pidFile); err == nil && pid == cmd.Process.Pid {
+				log.Println("Xray started in background with pid", pid)
+				return 0
+			}
+		}
+	}
+}
+
+// daemonOutputPath returns the file of the stdout and stderr of the daemon,
+// which is the error log file of config, or the null device.
+func daemonOutputPath(config *core.Config) string {
+	for _, app := range config.App {
+		instance, err := app.GetInstance()
+		if err != nil {
+			continue
+		}
+		if c, ok := instance.(*applog.Config); ok && c.ErrorLogType == applog.LogType_File && len(c.ErrorLogPath) > 0 {
+			return c.ErrorLogPath
+		}
+	}
+	return os.DevNull
+}
+
+func writePidFile(path string) error {
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644)
+}
+
+// removePidFile removes the pidfile of this process, if it is still in it.
+func removePidFile(path string) {
+	if pid, err := readPidFile(path); err == nil && pid == os.Getpid() {
+		os.Remove(path)
+	}
+}
+
+func readPidFile(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || pid <= 0 {
+		return 0, errors.New("invalid pidfile ", path)
+	}
+	return pid, nil
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// stopDaemon terminates the daemon with the pid in pidFile, and waits for it
+// to exit. It returns errNotRunning if it is not running.
+func stopDaemon(pidFile string, timeout time.Duration) error {
+	pid, err := readPidFile(pidFile)
+	if goerrors.Is(err, os.ErrNotExist) {
+		return errNotRunning
+	}
+	if err != nil {
+		return err
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		if goerrors.Is(err, os.ErrProcessDone) {
+			os.Remove(pidFile)
+			return errNotRunning
+		}
+		return err
+	}
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if !processAlive(pid) {
+			return nil
+		}
+	}
+	return errors.New("Xray with pid ", pid, " did not exit in ", timeout)
+}
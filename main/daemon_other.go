@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import (
+	"os/exec"
+	"runtime"
+
+	"github.com/xtls/xray-core/common/errors"
+)
+
+func detach(cmd *exec.Cmd) error {
+	return errors.New("not supported on ", runtime.GOOS, ", use a service manager instead")
+}
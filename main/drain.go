@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/xtls/xray-core/app/policy"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/inbound"
+	featurespolicy "github.com/xtls/xray-core/features/policy"
+)
+
+// drainReportInterval is how often the connections left are reported while
+// draining.
+const drainReportInterval = 5 * time.Second
+
+// inbounds is the inbound manager of a server, which suspends the inbounds
+// and counts their connections.
+type inbounds interface {
+	Suspend() error
+	Resume() error
+	ActiveConnections() int64
+}
+
+func getInbounds(server core.Server) (inbounds, error) {
+	instance, ok := server.(*core.Instance)
+	if !ok {
+		return nil, errors.New("unknown server")
+	}
+	manager, ok := instance.GetFeature(inbound.ManagerType()).(inbounds)
+	if !ok {
+		return nil, errors.New("inbounds can not be suspended")
+	}
+	return manager, nil
+}
+
+// isDrainTimeoutSet returns whether -drain-timeout is set, overriding the
+// system policy.
+func isDrainTimeoutSet() bool {
+	set := false
+	cmdRun.Flag.Visit(func(f *flag.Flag) {
+		if f.Name == "drain-timeout" {
+			set = true
+		}
+	})
+	return set
+}
+
+// getDrainTimeout returns how long to drain server on shutdown.
+func getDrainTimeout(server core.Server) time.Duration {
+	if isDrainTimeoutSet() {
+		return *drainTimeout
+	}
+	if instance, ok := server.(*core.Instance); ok {
+		if manager, ok := instance.GetFeature(featurespolicy.ManagerType()).(featurespolicy.Manager); ok {
+			return manager.ForSystem().DrainTimeout
+		}
+	}
+	return 0
+}
+
+// configDrainTimeout returns how long a server of config drains on shutdown.
+func configDrainTimeout(config *core.Config) time.Duration {
+	if isDrainTimeoutSet() {
+		return *drainTimeout
+	}
+	for _, app := range config.App {
+		instance, err := app.GetInstance()
+		if err != nil {
+			continue
+		}
+		if c, ok := instance.(*policy.Config); ok && c.System != nil {
+			return time.Duration(c.System.DrainTimeout) * time.Second
+		}
+	}
+	return 0
+}
+
+// drainXray stops the inbounds of server from accepting connections, and
+// waits for the connections they have to finish, up to timeout or until a
+// signal is received from signals, reporting the connections left.
+func drainXray(server core.Server, timeout time.Duration, signals <-chan os.Signal) {
+	if timeout <= 0 {
+		return
+	}
+	manager, err := getInbounds(server)
+	if err != nil {
+		log.Println("Failed to drain:", err)
+		return
+	}
+	if err := manager.Suspend(); err != nil {
+		log.Println("Failed to stop accepting connections:", err)
+	}
+	active := manager.ActiveConnections()
+	if active == 0 {
+		return
+	}
+	log.Printf("Draining %d connections for up to %s, signal again to close them", active, timeout)
+
+	deadline := time.Now().Add(timeout)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	lastReport := time.Now()
+	for {
+		select {
+		case <-signals:
+			log.Println("Closing", manager.ActiveConnections(), "connections")
+			return
+		case <-timer.C:
+			log.Println("Drain timed out, closing", manager.ActiveConnections(), "connections")
+			return
+		case now := <-ticker.C:
+			active := manager.ActiveConnections()
+			if active == 0 {
+				log.Println("All connections finished")
+				return
+			}
+			if now.Sub(lastReport) >= drainReportInterval {
+				log.Printf("Draining %d connections, %s left", active, deadline.Sub(now).Round(time.Second))
+				lastReport = now
+			}
+		}
+	}
+}
@@ -15,6 +15,10 @@ func main() {
 	base.RootCommand.Commands = append(
 		[]*base.Command{
 			cmdRun,
+			cmdStop,
+			cmdRestart,
+			cmdUpgrade,
+			cmdService,
 			cmdVersion,
 		},
 		base.RootCommand.Commands...,
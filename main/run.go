@@ -21,7 +21,7 @@ import (
 )
 
 var cmdRun = &base.Command{
-	UsageLine: "{{.Exec}} run [-c config.json] [-confdir dir]",
+	UsageLine: "{{.Exec}} run [-c config.json] [-confdir dir] [-daemon] [-pidfile file] [-drain-timeout duration]",
 	Short:     "Run Xray with config, the default command",
 	Long: `
 Run Xray with config, the default command.
@@ -38,6 +38,19 @@ The -test flag tells Xray to test config files only,
 without launching the server.
 
 The -dump flag tells Xray to print the merged config.
+
+The -daemon flag tells Xray to run in background, on Unix, with
+its stdout and stderr in the error log file of the config. It
+writes its pid to the file of the -pidfile=file flag, default
+"xray.pid" in the temp directory, for "stop" and "restart".
+
+The -drain-timeout=duration flag sets how long Xray waits on SIGTERM
+or interrupt for the connections to finish, once it stops accepting
+new ones, such as "30s". A second signal closes them at once.
+Default the drainTimeout of the system policy, in seconds, or 0.
+
+On SIGUSR2, Xray upgrades itself without closing its listening sockets,
+on Unix. See "upgrade".
 	`,
 }
 
@@ -47,11 +60,14 @@ func init() {
 }
 
 var (
-	configFiles cmdarg.Arg // "Config file for Xray.", the option is customed type, parse in main
-	configDir   string
-	dump        = cmdRun.Flag.Bool("dump", false, "Dump merged config only, without launching Xray server.")
-	test        = cmdRun.Flag.Bool("test", false, "Test config file only, without launching Xray server.")
-	format      = cmdRun.Flag.String("format", "auto", "Format of input file.")
+	configFiles  cmdarg.Arg // "Config file for Xray.", the option is customed type, parse in main
+	configDir    string
+	dump         = cmdRun.Flag.Bool("dump", false, "Dump merged config only, without launching Xray server.")
+	test         = cmdRun.Flag.Bool("test", false, "Test config file only, without launching Xray server.")
+	format       = cmdRun.Flag.String("format", "auto", "Format of input file.")
+	daemon       = cmdRun.Flag.Bool("daemon", false, "Run in background.")
+	pidFile      = cmdRun.Flag.String("pidfile", defaultPidFile(), "Pidfile of Xray running in background.")
+	drainTimeout = cmdRun.Flag.Duration("drain-timeout", 0, "Time to wait for connections to finish on shutdown.")
 
 	/* We have to do this here because Golang's Test will also need to parse flag, before
 	 * main func in this file is run.
@@ -72,6 +88,10 @@ func executeRun(cmd *base.Command, args []string) {
 		os.Exit(errCode)
 	}
 
+	if *daemon && !*test && !isDaemon() {
+		os.Exit(startDaemon())
+	}
+
 	printVersion()
 	server, err := startXray()
 	if err != nil {
@@ -85,11 +105,24 @@ func executeRun(cmd *base.Command, args []string) {
 		os.Exit(0)
 	}
 
+	p, err := takeOver(server)
+	if err != nil {
+		log.Println("Failed to start:", err)
+		os.Exit(-1)
+	}
 	if err := server.Start(); err != nil {
 		log.Println("Failed to start:", err)
 		os.Exit(-1)
 	}
 	defer server.Close()
+	p.started()
+
+	if isDaemon() {
+		if err := writePidFile(*pidFile); err != nil {
+			log.Println("Failed to write pidfile:", err)
+		}
+		defer removePidFile(*pidFile)
+	}
 
 	/*
 		conf.FileCache = nil
@@ -104,7 +137,8 @@ func executeRun(cmd *base.Command, args []string) {
 	{
 		osSignals := make(chan os.Signal, 1)
 		signal.Notify(osSignals, os.Interrupt, syscall.SIGTERM)
-		<-osSignals
+		waitStop(server, osSignals)
+		drainXray(server, getDrainTimeout(server), osSignals)
 	}
 }
 
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+const defaultServiceName = "Xray"
+
+var cmdService = &base.Command{
+	UsageLine: "{{.Exec}} service",
+	Short:     "Run Xray as a Windows service",
+	Long: `{{.Exec}} {{.LongName}} registers Xray as a Windows service, and controls it.
+
+The service accepts stop, shutdown, pause and continue. Pausing stops the
+inbounds from accepting connections, keeping the ones they have, until the
+service continues.
+
+All commands accept -name=name, the name of the service. Default "Xray".
+`,
+	Commands: []*base.Command{
+		cmdServiceInstall,
+		cmdServiceUninstall,
+		cmdServiceStart,
+		cmdServiceStop,
+		cmdServiceRun,
+	},
+}
+
+var cmdServiceInstall = &base.Command{
+	UsageLine: "{{.Exec}} service install [-name Xray] [-c config.json] [-confdir dir] [-format auto]",
+	Short:     "Install the service",
+	Long: `
+Install the service, started automatically, which runs Xray with the
+config of the flags, as of "run". The paths of local config files and
+dirs are made absolute.
+
+Example:
+
+	{{.Exec}} {{.LongName}} -c C:\Xray\config.json
+	`,
+	Run: executeServiceInstall,
+}
+
+var cmdServiceUninstall = &base.Command{
+	UsageLine: "{{.Exec}} service uninstall [-name Xray]",
+	Short:     "Uninstall the service",
+	Long: `
+Stop the service, if it is running, and uninstall it.
+	`,
+	Run: executeServiceUninstall,
+}
+
+var cmdServiceStart = &base.Command{
+	UsageLine: "{{.Exec}} service start [-name Xray]",
+	Short:     "Start the service",
+	Long: `
+Start the service.
+	`,
+	Run: executeServiceStart,
+}
+
+var cmdServiceStop = &base.Command{
+	UsageLine: "{{.Exec}} service stop [-name Xray]",
+	Short:     "Stop the service",
+	Long: `
+Stop the service, and wait for it to stop.
+	`,
+	Run: executeServiceStop,
+}
+
+var cmdServiceRun = &base.Command{
+	UsageLine: "{{.Exec}} service run [-name Xray] [-c config.json] [-confdir dir] [-format auto]",
+	Short:     "Run as the service, started by the service manager",
+	Long: `
+Run Xray as the service. It is the command the service manager runs,
+set by "service install".
+	`,
+	Run: executeServiceRun,
+}
+
+// serviceName is the -name flag of each command of service.
+var serviceName = map[*base.Command]*string{}
+
+func init() {
+	for _, cmd := range []*base.Command{cmdServiceInstall, cmdServiceUninstall, cmdServiceStart, cmdServiceStop, cmdServiceRun} {
+		serviceName[cmd] = cmd.Flag.String("name", defaultServiceName, "")
+	}
+	for _, cmd := range []*base.Command{cmdServiceInstall, cmdServiceRun} {
+		addConfigFlags(&cmd.Flag)
+	}
+}
+
+// addConfigFlags adds the flags of the config of run to fs.
+func addConfigFlags(fs *flag.FlagSet) {
+	fs.Var(&configFiles, "config", "")
+	fs.Var(&configFiles, "c", "")
+	fs.StringVar(&configDir, "confdir", "", "")
+	fs.StringVar(format, "format", "auto", "")
+}
+
+// serviceArgs returns the arguments of "service run", for the service of name
+// with the config of the flags.
+func serviceArgs(name string) ([]string, error) {
+	args := []string{"service", "run", "-name", name, "-format", *format}
+	if len(configDir) > 0 {
+		dir, err := filepath.Abs(configDir)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-confdir", dir)
+	}
+	for _, file := range configFiles {
+		if !strings.Contains(file, "://") && !strings.HasPrefix(file, "stdin:") {
+			abs, err := filepath.Abs(file)
+			if err != nil {
+				return nil, err
+			}
+			file = abs
+		}
+		args = append(args, "-c", file)
+	}
+	return args, nil
+}
+
+func executeServiceInstall(cmd *base.Command, args []string) {
+	name := *serviceName[cmd]
+	runArgs, err := serviceArgs(name)
+	if err != nil {
+		base.Fatalf("failed to install service %s: %s", name, err)
+	}
+	if err := installService(name, runArgs); err != nil {
+		base.Fatalf("failed to install service %s: %s", name, err)
+	}
+	fmt.Println("Service", name, "installed.")
+}
+
+func executeServiceUninstall(cmd *base.Command, args []string) {
+	name := *serviceName[cmd]
+	if err := uninstallService(name); err != nil {
+		base.Fatalf("failed to uninstall service %s: %s", name, err)
+	}
+	fmt.Println("Service", name, "uninstalled.")
+}
+
+func executeServiceStart(cmd *base.Command, args []string) {
+	name := *serviceName[cmd]
+	if err := startService(name); err != nil {
+		base.Fatalf("failed to start service %s: %s", name, err)
+	}
+	fmt.Println("Service", name, "started.")
+}
+
+func executeServiceStop(cmd *base.Command, args []string) {
+	name := *serviceName[cmd]
+	if err := stopService(name); err != nil {
+		base.Fatalf("failed to stop service %s: %s", name, err)
+	}
+	fmt.Println("Service", name, "stopped.")
+}
+
+func executeServiceRun(cmd *base.Command, args []string) {
+	name := *serviceName[cmd]
+	if err := runService(name); err != nil {
+		base.Fatalf("failed to run service %s: %s", name, err)
+	}
+}
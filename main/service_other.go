@@ -0,0 +1,31 @@
+//go:build !windows
+
+package main
+
+import (
+	"runtime"
+
+	"github.com/xtls/xray-core/common/errors"
+)
+
+var errServiceUnsupported = errors.New("Windows services are not supported on ", runtime.GOOS, ", see \"run -daemon\" on Unix")
+
+func installService(name string, args []string) error {
+	return errServiceUnsupported
+}
+
+func uninstallService(name string) error {
+	return errServiceUnsupported
+}
+
+func startService(name string) error {
+	return errServiceUnsupported
+}
+
+func stopService(name string) error {
+	return errServiceUnsupported
+}
+
+func runService(name string) error {
+	return errServiceUnsupported
+}
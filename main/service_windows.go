@@ -0,0 +1,215 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/core"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceStopTimeout is how long stopService waits for the service to stop.
+const serviceStopTimeout = 20 * time.Second
+
+var errServiceNotRunning = errors.New("service not running")
+
+func installService(name string, args []string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return errors.New("service exists")
+	}
+	s, err := m.CreateService(name, executable, mgr.Config{
+		DisplayName: name,
+		Description: "Xray, a platform for building proxies.",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// Restart on crashes, but not on errors such as of the config.
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+		{Type: mgr.NoAction},
+	}, uint32((24 * time.Hour).Seconds())); err != nil {
+		s.Delete()
+		return err
+	}
+	if err := eventlog.InstallAsEventCreate(name, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return errors.New("failed to install event log source").Base(err)
+	}
+	return nil
+}
+
+func uninstallService(name string) error {
+	if err := stopService(name); err != nil && err != errServiceNotRunning {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	eventlog.Remove(name)
+	return nil
+}
+
+func startService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return s.Start()
+}
+
+func stopService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	status, err := s.Query()
+	if err != nil {
+		return err
+	}
+	if status.State == svc.Stopped {
+		return errServiceNotRunning
+	}
+	if status, err = s.Control(svc.Stop); err != nil {
+		return err
+	}
+	for deadline := time.Now().Add(serviceStopTimeout); status.State != svc.Stopped; {
+		if time.Now().After(deadline) {
+			return errors.New("service did not stop in ", serviceStopTimeout)
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runService(name string) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		return errors.New("not started by the service manager, use \"run\" instead")
+	}
+	elog, err := eventlog.Open(name)
+	if err != nil {
+		return err
+	}
+	defer elog.Close()
+	return svc.Run(name, &service{elog: elog})
+}
+
+// service runs Xray for the service manager, which controls it.
+type service struct {
+	elog *eventlog.Log
+}
+
+// Execute implements svc.Handler.
+func (s *service) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	server, err := startXray()
+	if err != nil {
+		s.elog.Error(1, "Failed to start: "+err.Error())
+		// Configuration error, as of run.
+		return true, 23
+	}
+	if err := server.Start(); err != nil {
+		s.elog.Error(1, "Failed to start: "+err.Error())
+		return true, 1
+	}
+	runtime.GC()
+	debug.FreeOSMemory()
+
+	const accepts = svc.AcceptStop | svc.AcceptShutdown | svc.AcceptPauseAndContinue
+	changes <- svc.Status{State: svc.Running, Accepts: accepts}
+	s.elog.Info(1, "Xray "+core.Version()+" started")
+	for request := range requests {
+		switch request.Cmd {
+		case svc.Interrogate:
+			changes <- request.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			timeout := getDrainTimeout(server)
+			changes <- svc.Status{State: svc.StopPending, WaitHint: uint32((timeout + 5*time.Second) / time.Millisecond)}
+			drainXray(server, timeout, nil)
+			if err := server.Close(); err != nil {
+				s.elog.Warning(1, "Failed to close: "+err.Error())
+			}
+			return false, 0
+		case svc.Pause:
+			changes <- svc.Status{State: svc.PausePending}
+			if err := suspendInbounds(server, true); err != nil {
+				s.elog.Warning(1, "Failed to pause: "+err.Error())
+			}
+			changes <- svc.Status{State: svc.Paused, Accepts: accepts}
+		case svc.Continue:
+			changes <- svc.Status{State: svc.ContinuePending}
+			if err := suspendInbounds(server, false); err != nil {
+				s.elog.Warning(1, "Failed to continue: "+err.Error())
+			}
+			changes <- svc.Status{State: svc.Running, Accepts: accepts}
+		}
+	}
+	return false, 0
+}
+
+// suspendInbounds stops the inbounds of server from accepting connections, or
+// makes them accept connections again.
+func suspendInbounds(server core.Server, suspend bool) error {
+	manager, err := getInbounds(server)
+	if err != nil {
+		return err
+	}
+	if suspend {
+		return manager.Suspend()
+	}
+	return manager.Resume()
+}
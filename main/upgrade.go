@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+// upgradeTimeout is how long Xray waits for each step of the process
+// upgrading it.
+const upgradeTimeout = 30 * time.Second
+
+var cmdUpgrade = &base.Command{
+	UsageLine: "{{.Exec}} upgrade [-pidfile file] [-timeout seconds]",
+	Short:     "Upgrade Xray running in background without downtime",
+	Long: `
+Upgrade Xray started in background by "run -daemon", with the pid in the
+pidfile, to the executable of the same path, such as once it is replaced,
+on Unix.
+
+Xray starts the executable with the flags it was started with, which
+listens on the sockets Xray listens on instead of new ones, and takes the
+mappings of fake DNS. Once the new process is started, the old one drains
+its connections as on SIGTERM and exits. The listening sockets are kept
+open all along, so no connection is refused. If the new process fails to
+start, the old one keeps running. Xray upgrades itself on SIGUSR2 as well,
+which this command sends, if it runs with -daemon, or as a systemd service
+of Type=notify, which it tells the pid of the new process.
+
+The -pidfile=file flag sets the pidfile of the daemon. Default
+"xray.pid" in the temp directory.
+
+The -timeout=seconds flag sets how long to wait for the new process to
+start. Default 60.
+	`,
+}
+
+var (
+	upgradePidFile = cmdUpgrade.Flag.String("pidfile", defaultPidFile(), "")
+	upgradeWait    = cmdUpgrade.Flag.Int("timeout", int(2*upgradeTimeout/time.Second), "")
+)
+
+func init() {
+	cmdUpgrade.Run = executeUpgrade
+}
+
+func executeUpgrade(cmd *base.Command, args []string) {
+	pid, err := upgradeDaemon(*upgradePidFile, time.Duration(*upgradeWait)*time.Second)
+	if err != nil {
+		base.Fatalf("failed to upgrade Xray: %s", err)
+	}
+	fmt.Println("Xray upgraded, running with pid", pid)
+}
+
+// upgradeDaemon tells the daemon with the pid in pidFile to upgrade, and
+// waits for the new process to write its pid to pidFile, which it returns.
+func upgradeDaemon(pidFile string, timeout time.Duration) (int, error) {
+	pid, err := readPidFile(pidFile)
+	if err != nil {
+		return 0, err
+	}
+	if err := signalUpgrade(pid); err != nil {
+		return 0, err
+	}
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if newPid, err := readPidFile(pidFile); err == nil && newPid != pid && processAlive(newPid) {
+			return newPid, nil
+		}
+	}
+	return 0, errors.New("Xray with pid ", pid, " did not upgrade in ", timeout, ", see its log")
+}
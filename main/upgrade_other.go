@@ -0,0 +1,27 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/core"
+)
+
+func signalUpgrade(pid int) error {
+	return errors.New("not supported on ", runtime.GOOS)
+}
+
+func waitStop(server core.Server, signals <-chan os.Signal) {
+	<-signals
+}
+
+type predecessor struct{}
+
+func takeOver(server core.Server) (*predecessor, error) {
+	return nil, nil
+}
+
+func (p *predecessor) started() {}
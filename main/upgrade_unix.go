@@ -0,0 +1,240 @@
+//go:build unix
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+// upgradeEnv passes the file descriptor of the socket between the process
+// being upgraded and the one upgrading it.
+const upgradeEnv = "XRAY_UPGRADE_FD"
+
+// notifySocketEnv is the socket systemd takes the state of its services of,
+// as in sd_notify(3), when the service is of Type=notify.
+const notifySocketEnv = "NOTIFY_SOCKET"
+
+// upgradeMessage is a message over the socket of upgradeEnv. The new process
+// sends Loaded once its config is loaded, for the old one to stop accepting
+// connections and reply with its States, and then Started.
+type upgradeMessage struct {
+	Loaded  bool              `json:"loaded,omitempty"`
+	States  map[string][]byte `json:"states,omitempty"`
+	Started bool              `json:"started,omitempty"`
+}
+
+func signalUpgrade(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Signal(syscall.SIGUSR2)
+}
+
+// waitStop waits for a signal of signals, or for server to be upgraded on
+// SIGUSR2, after which its inbounds are suspended.
+func waitStop(server core.Server, signals <-chan os.Signal) {
+	upgrade := make(chan os.Signal, 1)
+	signal.Notify(upgrade, syscall.SIGUSR2)
+	defer signal.Stop(upgrade)
+	for {
+		select {
+		case <-signals:
+			return
+		case <-upgrade:
+			log.Println("Upgrading")
+			if err := upgradeXray(server); err != nil {
+				log.Println("Failed to upgrade:", err)
+				continue
+			}
+			log.Println("Upgraded, the new process is running")
+			return
+		}
+	}
+}
+
+// upgradeXray starts the executable with the flags of this process, passing
+// it the listening sockets, and the states of server once it has loaded its
+// config, with the inbounds of server suspended. If the new process fails to
+// start, server resumes.
+//
+// The new process is a child of this one, so only a daemon of run -daemon,
+// or a service systemd is told the new pid of by notifyMainPID, is upgraded;
+// otherwise what started this process, such as systemd killing the control
+// group of a service once its main process exits, would stop the new one.
+func upgradeXray(server core.Server) error {
+	instance, ok := server.(*core.Instance)
+	if !ok {
+		return errors.New("unknown server")
+	}
+	notify := os.Getenv(notifySocketEnv) != ""
+	if !isDaemon() && !notify {
+		return errors.New("only Xray run with -daemon, or by systemd with Type=notify, can be upgraded")
+	}
+	manager, err := getInbounds(server)
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return errors.New("failed to create socket").Base(err)
+	}
+	syscall.CloseOnExec(fds[0])
+	syscall.CloseOnExec(fds[1])
+	local := os.NewFile(uintptr(fds[0]), "upgrade")
+	remote := os.NewFile(uintptr(fds[1]), "upgrade")
+	conn, err := net.FileConn(local)
+	local.Close()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{remote}
+	cmd.Env = append(os.Environ(), upgradeEnv+"=3")
+	sockets, err := internet.StartWithListenedSockets(cmd)
+	remote.Close()
+	if err != nil {
+		return errors.New("failed to start ", executable).Base(err)
+	}
+	closeSockets := func() {
+		for _, file := range sockets {
+			file.Close()
+		}
+	}
+	go cmd.Wait()
+
+	var m upgradeMessage
+	dec := json.NewDecoder(conn)
+	conn.SetDeadline(time.Now().Add(upgradeTimeout))
+	if err := dec.Decode(&m); err != nil || !m.Loaded {
+		cmd.Process.Kill()
+		closeSockets()
+		return errors.New("the new process failed to load its config").Base(err)
+	}
+	if notify {
+		if err := notifyMainPID(cmd.Process.Pid); err != nil {
+			cmd.Process.Kill()
+			closeSockets()
+			return errors.New("failed to tell systemd the pid of the new process").Base(err)
+		}
+	}
+
+	// The sockets are kept listening while the inbounds are suspended, so
+	// what they receive waits for the new process.
+	if err := manager.Suspend(); err != nil {
+		log.Println("Failed to stop accepting connections:", err)
+	}
+	states, err := instance.ExportStates()
+	if err != nil {
+		log.Println("Failed to export states:", err)
+	}
+	conn.SetDeadline(time.Now().Add(upgradeTimeout))
+	if err = json.NewEncoder(conn).Encode(upgradeMessage{States: states}); err == nil {
+		err = dec.Decode(&m)
+	}
+	if err != nil || !m.Started {
+		cmd.Process.Kill()
+		if notify {
+			if err := notifyMainPID(os.Getpid()); err != nil {
+				log.Println("Failed to tell systemd the pid of this process:", err)
+			}
+		}
+		internet.InheritSockets(sockets)
+		if err := manager.Resume(); err != nil {
+			log.Println("Failed to accept connections again:", err)
+		}
+		internet.ReleaseInheritedSockets()
+		return errors.New("the new process failed to start").Base(err)
+	}
+	closeSockets()
+	return nil
+}
+
+// notifyMainPID tells systemd, over the socket of notifySocketEnv, that pid
+// is the main process of the service, which it is allowed to be told by the
+// main process with the default NotifyAccess=main.
+func notifyMainPID(pid int) error {
+	conn, err := net.Dial("unixgram", os.Getenv(notifySocketEnv))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte("MAINPID=" + strconv.Itoa(pid)))
+	return err
+}
+
+// predecessor is the process being upgraded to this one.
+type predecessor struct {
+	conn net.Conn
+	enc  *json.Encoder
+}
+
+// takeOver takes the states of the process being upgraded to this one, which
+// stops accepting connections, for server to start with. It returns nil if
+// this process does not upgrade another one.
+func takeOver(server core.Server) (*predecessor, error) {
+	value := os.Getenv(upgradeEnv)
+	os.Unsetenv(upgradeEnv)
+	if value == "" {
+		return nil, nil
+	}
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, errors.New("invalid ", upgradeEnv).Base(err)
+	}
+	syscall.CloseOnExec(fd)
+	file := os.NewFile(uintptr(fd), "upgrade")
+	conn, err := net.FileConn(file)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	p := &predecessor{conn: conn, enc: json.NewEncoder(conn)}
+	var m upgradeMessage
+	if err = p.enc.Encode(upgradeMessage{Loaded: true}); err == nil {
+		err = json.NewDecoder(conn).Decode(&m)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, errors.New("failed to take over from the process being upgraded").Base(err)
+	}
+	if instance, ok := server.(*core.Instance); ok {
+		if err := instance.ImportStates(m.States); err != nil {
+			log.Println("Failed to import states:", err)
+		}
+	}
+	return p, nil
+}
+
+// started tells the process being upgraded that this one is started, and
+// closes the sockets it has passed which are not listened on.
+func (p *predecessor) started() {
+	if p == nil {
+		return
+	}
+	if err := p.enc.Encode(upgradeMessage{Started: true}); err != nil {
+		log.Println("Failed to tell the process being upgraded:", err)
+	}
+	p.conn.Close()
+	internet.ReleaseInheritedSockets()
+}
@@ -0,0 +1,57 @@
+package mobile
+
+import (
+	"sync/atomic"
+
+	applog "github.com/xtls/xray-core/app/log"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/log"
+)
+
+// LogCallback receives the logs of Xray that would go to the console.
+type LogCallback interface {
+	// OnLog is called with the severity of error logs, from 1 for errors to 4
+	// for debug, or 0 for the other logs such as access logs.
+	OnLog(level int, message string)
+}
+
+type logCallback struct {
+	LogCallback
+}
+
+var callback atomic.Pointer[logCallback]
+
+// SetLogCallback sets the callback of the logs. The logs are dropped if it is
+// nil.
+func SetLogCallback(cb LogCallback) {
+	if cb == nil {
+		callback.Store(nil)
+		return
+	}
+	callback.Store(&logCallback{cb})
+}
+
+type callbackHandler struct{}
+
+// Handle implements log.Handler.
+func (callbackHandler) Handle(msg log.Message) {
+	cb := callback.Load()
+	if cb == nil {
+		return
+	}
+	inner := msg
+	if m, ok := msg.(*applog.MaskedMsgWrapper); ok {
+		inner = m.Message
+	}
+	level := 0
+	if m, ok := inner.(*log.GeneralMessage); ok {
+		level = int(m.Severity)
+	}
+	cb.OnLog(level, msg.String())
+}
+
+func init() {
+	common.Must(applog.RegisterHandlerCreator(applog.LogType_Event, func(applog.LogType, applog.HandlerCreatorOptions) (log.Handler, error) {
+		return callbackHandler{}, nil
+	}))
+}
@@ -0,0 +1,135 @@
+// Package mobile is the API of Xray for the apps on mobile platforms. It can
+// be bound with gomobile, so it only uses the types gomobile supports.
+package mobile
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	applog "github.com/xtls/xray-core/app/log"
+	appstats "github.com/xtls/xray-core/app/stats"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/stats"
+
+	// The features and the JSON config loader.
+	_ "github.com/xtls/xray-core/main/distro/all"
+)
+
+var (
+	access   sync.Mutex
+	instance *core.Instance
+)
+
+// Version returns the version of Xray.
+func Version() string {
+	return core.Version()
+}
+
+// StartWithJSON starts an instance of Xray with the config in JSON. Only one
+// instance runs at a time.
+func StartWithJSON(config string) error {
+	access.Lock()
+	defer access.Unlock()
+
+	if instance != nil {
+		return errors.New("Xray is already running")
+	}
+	c, err := core.LoadConfig("json", bytes.NewReader([]byte(config)))
+	if err != nil {
+		return errors.New("failed to load config").Base(err)
+	}
+	if err := redirectLogs(c); err != nil {
+		return err
+	}
+	server, err := core.New(c)
+	if err != nil {
+		return errors.New("failed to create Xray").Base(err)
+	}
+	if err := server.Start(); err != nil {
+		return errors.New("failed to start Xray").Base(err)
+	}
+	instance = server
+	return nil
+}
+
+// Stop stops the running instance of Xray, if any.
+func Stop() error {
+	access.Lock()
+	defer access.Unlock()
+
+	if instance == nil {
+		return nil
+	}
+	err := instance.Close()
+	instance = nil
+	return err
+}
+
+// IsRunning returns whether an instance of Xray is running.
+func IsRunning() bool {
+	access.Lock()
+	defer access.Unlock()
+
+	return instance != nil
+}
+
+// QueryStats returns the counters whose names contain pattern, as a JSON
+// object of their names to their values. The counters are reset if reset is
+// true.
+func QueryStats(pattern string, reset bool) (string, error) {
+	access.Lock()
+	defer access.Unlock()
+
+	if instance == nil {
+		return "", errors.New("Xray is not running")
+	}
+	manager, ok := instance.GetFeature(stats.ManagerType()).(*appstats.Manager)
+	if !ok {
+		return "", errors.New("stats are not enabled")
+	}
+	counters := make(map[string]int64)
+	manager.VisitCounters(func(name string, c stats.Counter) bool {
+		if strings.Contains(name, pattern) {
+			if reset {
+				counters[name] = c.Set(0)
+			} else {
+				counters[name] = c.Value()
+			}
+		}
+		return true
+	})
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(counters); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// redirectLogs makes the logs to the console of the config go to the
+// LogCallback instead, as apps do not see the console.
+func redirectLogs(c *core.Config) error {
+	for i, app := range c.App {
+		settings, err := app.GetInstance()
+		if err != nil {
+			return err
+		}
+		config, ok := settings.(*applog.Config)
+		if !ok {
+			continue
+		}
+		if config.ErrorLogType == applog.LogType_Console {
+			config.ErrorLogType = applog.LogType_Event
+		}
+		if config.AccessLogType == applog.LogType_Console {
+			config.AccessLogType = applog.LogType_Event
+		}
+		c.App[i] = serial.ToTypedMessage(config)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package mobile
+
+import (
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+// SocketProtector keeps the sockets of Xray out of the VPN of the app, such
+// as with VpnService.protect on Android.
+type SocketProtector interface {
+	// Protect is called with the file descriptor of every socket before it
+	// is used, and returns whether it succeeds.
+	Protect(fd int) bool
+}
+
+type socketProtector struct {
+	SocketProtector
+}
+
+var (
+	protector   atomic.Pointer[socketProtector]
+	protectOnce sync.Once
+	protectErr  error
+)
+
+// SetSocketProtector sets the protector of the sockets of Xray. Sockets are
+// not protected if it is nil.
+func SetSocketProtector(p SocketProtector) error {
+	protectOnce.Do(func() {
+		internet.SetSocketProtector(protect)
+		// Some outbounds listen for their packets instead of dialing.
+		protectErr = internet.RegisterListenerController(func(network, address string, c syscall.RawConn) error {
+			var err error
+			if cerr := c.Control(func(fd uintptr) {
+				err = protect(network, address, fd)
+			}); cerr != nil {
+				return cerr
+			}
+			return err
+		})
+	})
+	if protectErr != nil {
+		return errors.New("failed to protect listened sockets").Base(protectErr)
+	}
+	if p == nil {
+		protector.Store(nil)
+		return nil
+	}
+	protector.Store(&socketProtector{p})
+	return nil
+}
+
+func protect(network, address string, fd uintptr) error {
+	p := protector.Load()
+	if p == nil {
+		return nil
+	}
+	if !p.Protect(int(fd)) {
+		return errors.New("failed to protect socket for ", network, " ", address)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"github.com/xtls/xray-core/common/retry"
+	"github.com/xtls/xray-core/common/session"
+)
+
+// DialRetry returns the strategy of retrying the dials of the outbound of ctx,
+// by its dial policy, or by attempts and delay in milliseconds without one.
+func DialRetry(ctx context.Context, attempts int, delay uint32) retry.Strategy {
+	if p := session.DialPolicyFromContext(ctx); p != nil && p.Attempts > 0 {
+		return retry.ExponentialBackoff(p.Attempts, uint32(p.Backoff/time.Millisecond))
+	}
+	return retry.ExponentialBackoff(attempts, delay)
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/proxy"
 	"github.com/xtls/xray-core/transport/internet/stat"
 	"github.com/xtls/xray-core/transport/internet/tls"
 )
@@ -59,6 +60,9 @@ func (d *DokodemoDoor) Init(config *Config, pm policy.Manager, sockopt *session.
 
 // Network implements proxy.Inbound.
 func (d *DokodemoDoor) Network() []net.Network {
+	if net.HasNetwork(d.config.Networks, net.Network_TCP) {
+		return append(d.config.Networks[:len(d.config.Networks):len(d.config.Networks)], net.Network_UNIX)
+	}
 	return d.config.Networks
 }
 
@@ -71,6 +75,10 @@ func (d *DokodemoDoor) policy() policy.Session {
 // Process implements proxy.Inbound.
 func (d *DokodemoDoor) Process(ctx context.Context, network net.Network, conn stat.Connection, dispatcher routing.Dispatcher) error {
 	errors.LogDebug(ctx, "processing connection from: ", conn.RemoteAddr())
+	if network == net.Network_UNIX {
+		// Unix domain sockets carry streams, forwarded as such.
+		network = net.Network_TCP
+	}
 	dest := net.Destination{
 		Network: network,
 		Address: d.address,
@@ -90,7 +98,7 @@ func (d *DokodemoDoor) Process(ctx context.Context, network net.Network, conn st
 				}
 			}
 		}
-		if dest.Port == 0 {
+		if dest.Port == 0 && err == nil {
 			dest.Port = net.Port(common.Must2(strconv.Atoi(port)).(int))
 		}
 		if d.portMap != nil && d.portMap[port] != "" {
@@ -125,7 +133,7 @@ func (d *DokodemoDoor) Process(ctx context.Context, network net.Network, conn st
 			}
 		}
 	}
-	if !dest.IsValid() || dest.Address == nil {
+	if !dest.IsValid() || dest.Address == nil || dest.Port == 0 {
 		return errors.New("unable to get destination")
 	}
 
@@ -152,6 +160,13 @@ func (d *DokodemoDoor) Process(ctx context.Context, network net.Network, conn st
 		inbound.Timer = timer
 	}
 
+	var handover *session.SpliceHandover
+	if dest.Network == net.Network_TCP && proxy.IsRawTCPConn(conn) {
+		handover = session.NewSpliceHandover()
+		outbounds := session.OutboundsFromContext(ctx)
+		outbounds[len(outbounds)-1].SpliceHandover = handover
+	}
+
 	ctx = policy.ContextWithBufferPolicy(ctx, plcy.Buffer)
 	link, err := dispatcher.Dispatch(ctx, dest)
 	if err != nil {
@@ -166,6 +181,13 @@ func (d *DokodemoDoor) Process(ctx context.Context, network net.Network, conn st
 			}
 		}()
 
+		if handover != nil {
+			if err := proxy.CopyUplinkOrHandOver(ctx, conn, link.Writer, timer, handover); err != nil {
+				return errors.New("failed to transport request").Base(err)
+			}
+			return nil
+		}
+
 		var reader buf.Reader
 		if dest.Network == net.Network_UDP {
 			reader = buf.NewPacketReader(conn)
@@ -0,0 +1,26 @@
+package dokodemo
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// bindAny allows the socket to bind the original destination of packets,
+// which is not a local address.
+func bindAny(fd int, af int, mark int) error {
+	if mark != 0 {
+		if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_USER_COOKIE, mark); err != nil {
+			return fmt.Errorf("set socket option: SO_USER_COOKIE: %s", err)
+		}
+	}
+	if af == syscall.AF_INET6 {
+		if err := syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_BINDANY, 1); err != nil {
+			return fmt.Errorf("set socket option: IPV6_BINDANY: %s", err)
+		}
+		return nil
+	}
+	if err := syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_BINDANY, 1); err != nil {
+		return fmt.Errorf("set socket option: IP_BINDANY: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package dokodemo
+
+import (
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// bindAny allows the socket to bind the original destination of packets,
+// which is not a local address.
+func bindAny(fd int, af int, mark int) error {
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, unix.SO_BINDANY, 1); err != nil {
+		return fmt.Errorf("set socket option: SO_BINDANY: %s", err)
+	}
+	return nil
+}
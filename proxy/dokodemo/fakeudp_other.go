@@ -1,5 +1,5 @@
-//go:build !linux
-// +build !linux
+//go:build !linux && !freebsd && !openbsd && !windows
+// +build !linux,!freebsd,!openbsd,!windows
 
 package dokodemo
 
@@ -0,0 +1,11 @@
+package dokodemo
+
+import (
+	"net"
+
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+func FakeUDP(addr *net.UDPAddr, mark int) (net.PacketConn, error) {
+	return internet.ListenRedirectedUDP(addr)
+}
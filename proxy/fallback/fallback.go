@@ -0,0 +1,521 @@
+// Package fallback serves the connections that VLESS and Trojan inbounds fall
+// back, by passing them to the dest of the fallback matching their server
+// name, ALPN and HTTP path. A fallback may have several dests, tried in order,
+// of which the down ones are skipped until a health check finds them up. A
+// dest may be a small site served in the core, of static files or proxied
+// to an upstream URL, so that no web server is needed alongside.
+package fallback
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/retry"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/features/inbound"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/transport/internet/reality"
+	"github.com/xtls/xray-core/transport/internet/stat"
+	"github.com/xtls/xray-core/transport/internet/tls"
+)
+
+const (
+	defaultCheckInterval = 30 * time.Second
+	defaultCheckTimeout  = 5 * time.Second
+)
+
+// target is a dest of a fallback, with whether it is down.
+type target struct {
+	*Dest
+	site *site // or nil
+	down atomic.Bool
+}
+
+// entry is a fallback with its targets.
+type entry struct {
+	*Fallback
+	targets []*target
+}
+
+// Table is the fallbacks of an inbound, by server name, ALPN and path.
+type Table struct {
+	inbounds  inbound.Manager
+	fallbacks map[string]map[string]map[string]*entry
+
+	// checked is the targets of the fallbacks with more than one.
+	checked  []*target
+	sites    []*site
+	interval time.Duration
+	timeout  time.Duration
+	done     chan struct{}
+	close    sync.Once
+}
+
+// NewTable creates the Table of fallbacks, dialing the dests of type
+// "inbound" at the listen address of the inbound of inbounds. It checks the
+// health of the dests of the fallbacks with more than one as of check, until
+// it is closed. It returns nil if there is no fallback.
+func NewTable(inbounds inbound.Manager, fallbacks []*Fallback, check *HealthCheck) (*Table, error) {
+	if len(fallbacks) == 0 {
+		return nil, nil
+	}
+	t := &Table{
+		inbounds:  inbounds,
+		fallbacks: make(map[string]map[string]map[string]*entry),
+		interval:  defaultCheckInterval,
+		timeout:   defaultCheckTimeout,
+		done:      make(chan struct{}),
+	}
+	if check.GetInterval() > 0 {
+		t.interval = time.Duration(check.Interval) * time.Second
+	}
+	if check.GetTimeout() > 0 {
+		t.timeout = time.Duration(check.Timeout) * time.Second
+	}
+
+	for _, fb := range fallbacks {
+		if len(fb.Dest) == 0 {
+			t.Close()
+			return nil, errors.New("fallback without dest")
+		}
+		e := &entry{Fallback: fb}
+		for _, d := range fb.Dest {
+			site, err := newSite(d)
+			if err != nil {
+				t.Close()
+				return nil, errors.New("failed to create fallback ", d.Type, ":", d.Dest).Base(err)
+			}
+			if site != nil {
+				t.sites = append(t.sites, site)
+			}
+			e.targets = append(e.targets, &target{Dest: d, site: site})
+		}
+		if len(e.targets) > 1 {
+			t.checked = append(t.checked, e.targets...)
+		}
+		if t.fallbacks[fb.Name] == nil {
+			t.fallbacks[fb.Name] = make(map[string]map[string]*entry)
+		}
+		if t.fallbacks[fb.Name][fb.Alpn] == nil {
+			t.fallbacks[fb.Name][fb.Alpn] = make(map[string]*entry)
+		}
+		t.fallbacks[fb.Name][fb.Alpn][fb.Path] = e
+	}
+	if t.fallbacks[""] != nil {
+		for name, apfb := range t.fallbacks {
+			if name != "" {
+				for alpn := range t.fallbacks[""] {
+					if apfb[alpn] == nil {
+						apfb[alpn] = make(map[string]*entry)
+					}
+				}
+			}
+		}
+	}
+	for _, apfb := range t.fallbacks {
+		if apfb[""] != nil {
+			for alpn, pfb := range apfb {
+				if alpn != "" { // && alpn != "h2" {
+					for path, e := range apfb[""] {
+						if pfb[path] == nil {
+							pfb[path] = e
+						}
+					}
+				}
+			}
+		}
+	}
+	if t.fallbacks[""] != nil {
+		for name, apfb := range t.fallbacks {
+			if name != "" {
+				for alpn, pfb := range t.fallbacks[""] {
+					for path, e := range pfb {
+						if apfb[alpn][path] == nil {
+							apfb[alpn][path] = e
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if len(t.checked) > 0 {
+		go t.watch()
+	}
+	return t, nil
+}
+
+// Close stops checking the health of the dests, and serving the sites once
+// the requests being served are done.
+func (t *Table) Close() error {
+	if t != nil {
+		t.close.Do(func() {
+			close(t.done)
+			for _, site := range t.sites {
+				site.Close()
+			}
+		})
+	}
+	return nil
+}
+
+func (t *Table) watch() {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			t.check()
+		}
+	}
+}
+
+// check dials each checked target, marking it down if it fails.
+func (t *Table) check() {
+	var wg sync.WaitGroup
+	for _, tg := range t.checked {
+		wg.Add(1)
+		go func(tg *target) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+			defer cancel()
+			var err error
+			if tg.site != nil {
+				err = tg.site.check(ctx)
+			} else {
+				var conn net.Conn
+				if conn, err = t.dial(ctx, tg.Dest); err == nil {
+					conn.Close()
+				}
+			}
+			t.mark(tg, err)
+		}(tg)
+	}
+	wg.Wait()
+}
+
+// mark marks tg down if err is not nil, and up otherwise, logging the change.
+func (t *Table) mark(tg *target, err error) {
+	if err != nil {
+		if !tg.down.Swap(true) {
+			errors.LogWarningInner(context.Background(), err, "fallback ", tg.Type, ":", tg.Dest.Dest, " is down")
+		}
+		return
+	}
+	if tg.down.Swap(false) {
+		errors.LogInfo(context.Background(), "fallback ", tg.Type, ":", tg.Dest.Dest, " is up")
+	}
+}
+
+// dial dials d.
+func (t *Table) dial(ctx context.Context, d *Dest) (net.Conn, error) {
+	network, address := d.Type, d.Dest
+	if network == "inbound" {
+		var err error
+		if network, address, err = t.inboundAddress(ctx, d.Dest); err != nil {
+			return nil, err
+		}
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, address)
+}
+
+// inboundAddress returns the address to dial the inbound of tag at.
+func (t *Table) inboundAddress(ctx context.Context, tag string) (string, string, error) {
+	if t.inbounds == nil {
+		return "", "", errors.New("no inbound manager")
+	}
+	handler, err := t.inbounds.GetHandler(ctx, tag)
+	if err != nil {
+		return "", "", errors.New("failed to get inbound ", tag).Base(err)
+	}
+	settings, err := handler.ReceiverSettings().GetInstance()
+	if err != nil {
+		return "", "", errors.New("failed to get the settings of inbound ", tag).Base(err)
+	}
+	receiver, ok := settings.(*proxyman.ReceiverConfig)
+	if !ok {
+		return "", "", errors.New("inbound ", tag, " does not listen")
+	}
+	address := receiver.Listen.AsAddress()
+	if address != nil && address.Family().IsDomain() {
+		path := address.Domain()
+		if filepath.IsAbs(path) || path[0] == '@' {
+			return "unix", unixAddress(path), nil
+		}
+	}
+	if len(receiver.PortList.GetRange()) == 0 {
+		return "", "", errors.New("inbound ", tag, " has no port")
+	}
+	switch {
+	case address == nil || address == net.AnyIP:
+		address = net.LocalHostIP
+	case address == net.AnyIPv6:
+		address = net.LocalHostIPv6
+	}
+	return "tcp", net.JoinHostPort(address.String(), strconv.Itoa(int(receiver.PortList.Range[0].From))), nil
+}
+
+// unixAddress returns the address to dial the unix socket an inbound listens
+// on at path.
+func unixAddress(path string) string {
+	path, _, _ = strings.Cut(path, ",")
+	if strings.HasPrefix(path, "@@") && (runtime.GOOS == "linux" || runtime.GOOS == "android") {
+		fullAddr := make([]byte, len(syscall.RawSockaddrUnix{}.Path)) // padded as the inbound listens
+		copy(fullAddr, path[1:])
+		path = string(fullAddr)
+	}
+	return path
+}
+
+// find returns the entry matching name, alpn and the path of first.
+func (t *Table) find(ctx context.Context, name, alpn string, first *buf.Buffer, firstLen int64) (*entry, error) {
+	napfb := t.fallbacks
+	if len(napfb) > 1 || napfb[""] == nil {
+		if name != "" && napfb[name] == nil {
+			match := ""
+			for n := range napfb {
+				if n != "" && strings.Contains(name, n) && len(n) > len(match) {
+					match = n
+				}
+			}
+			name = match
+		}
+	}
+
+	if napfb[name] == nil {
+		name = ""
+	}
+	apfb := napfb[name]
+	if apfb == nil {
+		return nil, errors.New(`failed to find the default "name" config`).AtWarning()
+	}
+
+	if apfb[alpn] == nil {
+		alpn = ""
+	}
+	pfb := apfb[alpn]
+	if pfb == nil {
+		return nil, errors.New(`failed to find the default "alpn" config`).AtWarning()
+	}
+
+	path := ""
+	if len(pfb) > 1 || pfb[""] == nil {
+		if firstLen >= 18 && first.Byte(4) != '*' { // not h2c
+			firstBytes := first.Bytes()
+			for i := 4; i <= 8; i++ { // 5 -> 9
+				if firstBytes[i] == '/' && firstBytes[i-1] == ' ' {
+					search := len(firstBytes)
+					if search > 64 {
+						search = 64 // up to about 60
+					}
+					for j := i + 1; j < search; j++ {
+						k := firstBytes[j]
+						if k == '\r' || k == '\n' { // avoid logging \r or \n
+							break
+						}
+						if k == '?' || k == ' ' {
+							path = string(firstBytes[i:j])
+							errors.LogInfo(ctx, "realPath = "+path)
+							if pfb[path] == nil {
+								path = ""
+							}
+							break
+						}
+					}
+					break
+				}
+			}
+		}
+	}
+	e := pfb[path]
+	if e == nil {
+		return nil, errors.New(`failed to find the default "path" config`).AtWarning()
+	}
+	return e, nil
+}
+
+// connect connects connection to the targets of e in order, those down last,
+// marking down those that fail if e has more than one. It returns the target
+// connected to.
+func (t *Table) connect(ctx context.Context, e *entry, connection net.Conn) (net.Conn, *target, error) {
+	targets := make([]*target, 0, len(e.targets))
+	for _, tg := range e.targets {
+		if !tg.down.Load() {
+			targets = append(targets, tg)
+		}
+	}
+	for _, tg := range e.targets {
+		if tg.down.Load() {
+			targets = append(targets, tg)
+		}
+	}
+
+	var conn net.Conn
+	var connected *target
+	err := retry.ExponentialBackoff(5, 100).On(func() error {
+		var err error
+		for _, tg := range targets {
+			if tg.site != nil {
+				conn, err = tg.site.connect(ctx, connection)
+			} else {
+				conn, err = t.dial(ctx, tg.Dest)
+			}
+			if err == nil {
+				if len(e.targets) > 1 {
+					t.mark(tg, nil)
+				}
+				connected = tg
+				return nil
+			}
+			if len(e.targets) > 1 {
+				t.mark(tg, err)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, errors.New("failed to dial to ", e.targets[0].Dest.Dest).Base(err).AtWarning()
+	}
+	return conn, connected, nil
+}
+
+// Serve passes connection, of which first has been read into reader, to the
+// fallback matching it.
+func (t *Table) Serve(ctx context.Context, err error, sessionPolicy policy.Session, connection stat.Connection, iConn stat.Connection, first *buf.Buffer, firstLen int64, reader buf.Reader) error {
+	if err := connection.SetReadDeadline(time.Time{}); err != nil {
+		errors.LogWarningInner(ctx, err, "unable to set back read deadline")
+	}
+	errors.LogInfoInner(ctx, err, "fallback starts")
+
+	name := ""
+	alpn := ""
+	if tlsConn, ok := iConn.(*tls.Conn); ok {
+		cs := tlsConn.ConnectionState()
+		name = cs.ServerName
+		alpn = cs.NegotiatedProtocol
+		errors.LogInfo(ctx, "realName = "+name)
+		errors.LogInfo(ctx, "realAlpn = "+alpn)
+	} else if realityConn, ok := iConn.(*reality.Conn); ok {
+		cs := realityConn.ConnectionState()
+		name = cs.ServerName
+		alpn = cs.NegotiatedProtocol
+		errors.LogInfo(ctx, "realName = "+name)
+		errors.LogInfo(ctx, "realAlpn = "+alpn)
+	}
+	name = strings.ToLower(name)
+	alpn = strings.ToLower(alpn)
+
+	fb, err := t.find(ctx, name, alpn, first, firstLen)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
+	ctx = policy.ContextWithBufferPolicy(ctx, sessionPolicy.Buffer)
+
+	conn, tg, err := t.connect(ctx, fb, connection)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	serverReader := buf.NewReader(conn)
+	serverWriter := buf.NewWriter(conn)
+
+	postRequest := func() error {
+		defer timer.SetTimeout(sessionPolicy.Timeouts.DownlinkOnly)
+		if fb.Xver != 0 && tg.site == nil {
+			ipType := 4
+			remoteAddr, remotePort, err := net.SplitHostPort(connection.RemoteAddr().String())
+			if err != nil {
+				ipType = 0
+			}
+			localAddr, localPort, err := net.SplitHostPort(connection.LocalAddr().String())
+			if err != nil {
+				ipType = 0
+			}
+			if ipType == 4 {
+				for i := 0; i < len(remoteAddr); i++ {
+					if remoteAddr[i] == ':' {
+						ipType = 6
+						break
+					}
+				}
+			}
+			pro := buf.New()
+			defer pro.Release()
+			switch fb.Xver {
+			case 1:
+				if ipType == 0 {
+					common.Must2(pro.Write([]byte("PROXY UNKNOWN\r\n")))
+					break
+				}
+				if ipType == 4 {
+					common.Must2(pro.Write([]byte("PROXY TCP4 " + remoteAddr + " " + localAddr + " " + remotePort + " " + localPort + "\r\n")))
+				} else {
+					common.Must2(pro.Write([]byte("PROXY TCP6 " + remoteAddr + " " + localAddr + " " + remotePort + " " + localPort + "\r\n")))
+				}
+			case 2:
+				common.Must2(pro.Write([]byte("\x0D\x0A\x0D\x0A\x00\x0D\x0A\x51\x55\x49\x54\x0A"))) // signature
+				if ipType == 0 {
+					common.Must2(pro.Write([]byte("\x20\x00\x00\x00"))) // v2 + LOCAL + UNSPEC + UNSPEC + 0 bytes
+					break
+				}
+				if ipType == 4 {
+					common.Must2(pro.Write([]byte("\x21\x11\x00\x0C"))) // v2 + PROXY + AF_INET + STREAM + 12 bytes
+					common.Must2(pro.Write(net.ParseIP(remoteAddr).To4()))
+					common.Must2(pro.Write(net.ParseIP(localAddr).To4()))
+				} else {
+					common.Must2(pro.Write([]byte("\x21\x21\x00\x24"))) // v2 + PROXY + AF_INET6 + STREAM + 36 bytes
+					common.Must2(pro.Write(net.ParseIP(remoteAddr).To16()))
+					common.Must2(pro.Write(net.ParseIP(localAddr).To16()))
+				}
+				p1, _ := strconv.ParseUint(remotePort, 10, 16)
+				p2, _ := strconv.ParseUint(localPort, 10, 16)
+				common.Must2(pro.Write([]byte{byte(p1 >> 8), byte(p1), byte(p2 >> 8), byte(p2)}))
+			}
+			if err := serverWriter.WriteMultiBuffer(buf.MultiBuffer{pro}); err != nil {
+				return errors.New("failed to set PROXY protocol v", fb.Xver).Base(err).AtWarning()
+			}
+		}
+		if err := buf.Copy(reader, serverWriter, buf.UpdateActivity(timer)); err != nil {
+			return errors.New("failed to fallback request payload").Base(err).AtInfo()
+		}
+		return nil
+	}
+
+	writer := buf.NewWriter(connection)
+
+	getResponse := func() error {
+		defer timer.SetTimeout(sessionPolicy.Timeouts.UplinkOnly)
+		if err := buf.Copy(serverReader, writer, buf.UpdateActivity(timer)); err != nil {
+			return errors.New("failed to deliver response payload").Base(err).AtInfo()
+		}
+		return nil
+	}
+
+	if err := task.Run(ctx, task.OnSuccess(postRequest, task.Close(serverWriter)), task.OnSuccess(getResponse, task.Close(writer))); err != nil {
+		common.Must(common.Interrupt(serverReader))
+		common.Must(common.Interrupt(serverWriter))
+		return errors.New("fallback ends").Base(err).AtInfo()
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package fallback
+
+import (
+	"context"
+	"log"
+	gonet "net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// site is a dest served in the core: the files of a dir, of type "static", or
+// an upstream URL, of type "proxy". It serves HTTP/1 and HTTP/2, with or
+// without TLS before it.
+type site struct {
+	dir      string
+	upstream *url.URL
+
+	server *http.Server
+	conns  chan net.Conn
+	done   chan struct{}
+	close  sync.Once
+}
+
+// newSite creates the site of dest d, or nil if d is not of a site.
+func newSite(d *Dest) (*site, error) {
+	s := &site{
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+	var handler http.Handler
+	switch d.Type {
+	case "static":
+		info, err := os.Stat(d.Dest)
+		if err != nil {
+			return nil, errors.New("failed to find the dir to serve").Base(err)
+		}
+		if !info.IsDir() {
+			return nil, errors.New(d.Dest, " is not a dir")
+		}
+		s.dir = d.Dest
+		handler = http.FileServer(http.Dir(d.Dest))
+	case "proxy":
+		u, err := url.Parse(d.Dest)
+		if err != nil {
+			return nil, errors.New("invalid upstream URL ", d.Dest).Base(err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.New("invalid upstream URL ", d.Dest)
+		}
+		s.upstream = u
+		handler = &httputil.ReverseProxy{
+			Rewrite: func(r *httputil.ProxyRequest) {
+				r.SetURL(u)
+				r.SetXForwarded()
+			},
+			ErrorLog: log.New(&siteLog{}, "", 0),
+		}
+	default:
+		return nil, nil
+	}
+	s.server = &http.Server{
+		Handler:  h2c.NewHandler(handler, &http2.Server{}),
+		ErrorLog: log.New(&siteLog{}, "", 0),
+	}
+	go s.server.Serve(s)
+	return s, nil
+}
+
+// siteLog logs the errors of sites.
+type siteLog struct{}
+
+func (*siteLog) Write(b []byte) (int, error) {
+	errors.LogInfo(context.Background(), "fallback site: ", strings.TrimSpace(string(b)))
+	return len(b), nil
+}
+
+// Accept implements net.Listener, accepting the connections of connect.
+func (s *site) Accept() (net.Conn, error) {
+	select {
+	case conn := <-s.conns:
+		return conn, nil
+	case <-s.done:
+		return nil, gonet.ErrClosed
+	}
+}
+
+// Addr implements net.Listener.
+func (s *site) Addr() net.Addr {
+	return &net.UnixAddr{Name: "fallback", Net: "unix"}
+}
+
+// Close implements net.Listener, and stops serving once the requests being
+// served are done.
+func (s *site) Close() error {
+	s.close.Do(func() {
+		close(s.done)
+		go s.server.Shutdown(context.Background())
+	})
+	return nil
+}
+
+// connect returns a connection to the site, from the source of connection.
+func (s *site) connect(ctx context.Context, connection net.Conn) (net.Conn, error) {
+	client, server := gonet.Pipe()
+	select {
+	case s.conns <- &siteConn{Conn: server, local: connection.LocalAddr(), remote: connection.RemoteAddr()}:
+		return client, nil
+	case <-s.done:
+		return nil, errors.New("site closed")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// check returns whether the site can serve: its dir exists, or its upstream
+// accepts connections.
+func (s *site) check(ctx context.Context) error {
+	if s.upstream == nil {
+		_, err := os.Stat(s.dir)
+		return err
+	}
+	address := s.upstream.Host
+	if s.upstream.Port() == "" {
+		port := "80"
+		if s.upstream.Scheme == "https" {
+			port = "443"
+		}
+		address = net.JoinHostPort(s.upstream.Hostname(), port)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// siteConn is a connection to a site, from the addresses of the connection
+// fallen back.
+type siteConn struct {
+	net.Conn
+	local  net.Addr
+	remote net.Addr
+}
+
+func (c *siteConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *siteConn) RemoteAddr() net.Addr {
+	return c.remote
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"crypto/rand"
 	"io"
+	"runtime"
+	"slices"
 	"time"
 
 	"github.com/pires/go-proxyproto"
+	"github.com/xtls/xray-core/app/router"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/crypto"
@@ -14,10 +17,10 @@ import (
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/platform"
-	"github.com/xtls/xray-core/common/retry"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal"
 	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/common/tracing"
 	"github.com/xtls/xray-core/common/utils"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/dns"
@@ -28,6 +31,7 @@ import (
 	"github.com/xtls/xray-core/transport/internet"
 	"github.com/xtls/xray-core/transport/internet/stat"
 	"github.com/xtls/xray-core/transport/internet/tls"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var useSplice bool
@@ -55,6 +59,15 @@ type Handler struct {
 	policyManager policy.Manager
 	dns           dns.Client
 	config        *Config
+	fragmentRules []*fragmentRule
+	nat64         *nat64
+	stunPorts     stunPorts
+}
+
+type fragmentRule struct {
+	ruleTags []string
+	domains  *router.DomainMatcher
+	fragment *Fragment
 }
 
 // Init initializes the Handler with necessary parameters.
@@ -63,15 +76,58 @@ func (h *Handler) Init(config *Config, pm policy.Manager, d dns.Client) error {
 	h.policyManager = pm
 	h.dns = d
 
+	for _, rule := range config.FragmentRules {
+		fr := &fragmentRule{
+			ruleTags: rule.RuleTags,
+			fragment: rule.Fragment,
+		}
+		if len(rule.Domains) > 0 {
+			matcher, err := router.NewMphMatcherGroup(rule.Domains)
+			if err != nil {
+				return errors.New("failed to build domains of fragment rule").Base(err)
+			}
+			fr.domains = matcher
+		}
+		h.fragmentRules = append(h.fragmentRules, fr)
+	}
+
+	if config.Nat64Prefix != "" {
+		n, err := newNAT64(config.Nat64Prefix, d)
+		if err != nil {
+			return err
+		}
+		h.nat64 = n
+	}
+
 	return nil
 }
 
+// fragment returns the fragment for connections to destination, or nil.
+func (h *Handler) fragment(ob *session.Outbound, destination net.Destination) *Fragment {
+	domain := ""
+	if destination.Address.Family().IsDomain() {
+		domain = destination.Address.Domain()
+	} else if ob.RouteTarget.Address != nil && ob.RouteTarget.Address.Family().IsDomain() {
+		domain = ob.RouteTarget.Address.Domain()
+	}
+	for _, rule := range h.fragmentRules {
+		if ob.RuleTag != "" && slices.Contains(rule.ruleTags, ob.RuleTag) {
+			return rule.fragment
+		}
+		if domain != "" && rule.domains != nil && rule.domains.ApplyDomain(domain) {
+			return rule.fragment
+		}
+	}
+	return h.config.Fragment
+}
+
 func (h *Handler) policy() policy.Session {
 	p := h.policyManager.ForLevel(h.config.UserLevel)
 	return p
 }
 
 func (h *Handler) resolveIP(ctx context.Context, domain string, localAddr net.Address) net.Address {
+	_, span := tracing.Start(ctx, tracing.Resolve, attribute.String("domain", domain))
 	ips, _, err := h.dns.LookupIP(domain, dns.IPOption{
 		IPv4Enable: (localAddr == nil || localAddr.Family().IsIPv4()) && h.config.preferIP4(),
 		IPv6Enable: (localAddr == nil || localAddr.Family().IsIPv6()) && h.config.preferIP6(),
@@ -84,6 +140,7 @@ func (h *Handler) resolveIP(ctx context.Context, domain string, localAddr net.Ad
 			})
 		}
 	}
+	tracing.End(span, err)
 	if err != nil {
 		errors.LogInfoInner(ctx, err, "failed to get IP address for domain ", domain)
 	}
@@ -115,11 +172,18 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 
 	destination := ob.Target
 	UDPOverride := net.UDPDestination(nil, 0)
+	// names are the domains the UDP packets are sent to, by the addresses
+	// they are resolved to, for the replies from them to be of the domains.
+	names := utils.NewTypedSyncMap[string, net.Address]()
+	// unixRedirect is whether streams are redirected to a unix domain socket
+	// of the config.
+	unixRedirect := false
 	if h.config.DestinationOverride != nil {
 		server := h.config.DestinationOverride.Server
 		if isValidAddress(server.Address) {
 			destination.Address = server.Address.AsAddress()
 			UDPOverride.Address = destination.Address
+			unixRedirect = destination.Network == net.Network_TCP && net.StreamDestination(destination.Address, 0).Network == net.Network_UNIX
 		}
 		if server.Port != 0 {
 			destination.Port = net.Port(server.Port)
@@ -130,10 +194,17 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 	input := link.Reader
 	output := link.Writer
 
+	stunSource := stunFlowSource(ctx, destination)
+	if stunSource != "" {
+		ob.GatewayPort = h.stunPorts.get(stunSource)
+	}
+
 	var conn stat.Connection
-	err := retry.ExponentialBackoff(5, 100).On(func() error {
+	err := proxy.DialRetry(ctx, 5, 100).On(func() error {
 		dialDest := destination
-		if h.config.hasStrategy() && dialDest.Address.Family().IsDomain() {
+		if unixRedirect {
+			dialDest = net.UnixDestination(destination.Address)
+		} else if h.config.hasStrategy() && dialDest.Address.Family().IsDomain() {
 			ip := h.resolveIP(ctx, dialDest.Address.Domain(), dialer.Address())
 			if ip != nil {
 				dialDest = net.Destination{
@@ -146,6 +217,12 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 				return dns.ErrEmptyResponse
 			}
 		}
+		if h.nat64 != nil && !unixRedirect {
+			if d := h.nat64.dialDestination(ctx, dialDest); d != dialDest {
+				dialDest = d
+				errors.LogInfo(ctx, "dialing to ", dialDest, " through NAT64")
+			}
+		}
 
 		rawConn, err := dialer.Dial(ctx, dialDest)
 		if err != nil {
@@ -171,6 +248,13 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 	}
 	defer conn.Close()
 	errors.LogInfo(ctx, "connection opened to ", destination, ", local endpoint ", conn.LocalAddr(), ", remote endpoint ", conn.RemoteAddr())
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && stunSource != "" {
+		// The port is kept from the end of the flow too, for a next one.
+		timeouts := h.policy().Timeouts
+		keep := timeouts.ConnectionIdleFor(net.Network_UDP, "stun")
+		h.stunPorts.put(stunSource, net.Port(addr.Port), keep)
+		defer h.stunPorts.put(stunSource, net.Port(addr.Port), keep)
+	}
 
 	var newCtx context.Context
 	var newCancel context.CancelFunc
@@ -192,11 +276,11 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 
 		var writer buf.Writer
 		if destination.Network == net.Network_TCP {
-			if h.config.Fragment != nil {
-				errors.LogDebug(ctx, "FRAGMENT", h.config.Fragment.PacketsFrom, h.config.Fragment.PacketsTo, h.config.Fragment.LengthMin, h.config.Fragment.LengthMax,
-					h.config.Fragment.IntervalMin, h.config.Fragment.IntervalMax)
+			if fragment := h.fragment(ob, destination); fragment != nil {
+				errors.LogDebug(ctx, "FRAGMENT", fragment.PacketsFrom, fragment.PacketsTo, fragment.LengthMin, fragment.LengthMax,
+					fragment.IntervalMin, fragment.IntervalMax, fragment.TlsRecord)
 				writer = buf.NewWriter(&FragmentWriter{
-					fragment: h.config.Fragment,
+					fragment: fragment,
 					writer:   conn,
 				})
 			} else {
@@ -204,6 +288,9 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 			}
 		} else {
 			writer = NewPacketWriter(conn, h, ctx, UDPOverride, destination)
+			if w, ok := writer.(*PacketWriter); ok {
+				w.names = names
+			}
 			if h.config.Noises != nil {
 				errors.LogDebug(ctx, "NOISE", h.config.Noises)
 				writer = &NoisePacketWriter{
@@ -215,10 +302,32 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 			}
 		}
 
+		var handover *session.SpliceHandover
+		if destination.Network == net.Network_TCP && h.fragment(ob, destination) == nil && useSplice {
+			handover = spliceHandover(ctx, conn)
+		}
+		if handover != nil {
+			defer func() {
+				select {
+				case <-handover.Handed:
+					close(handover.Done)
+				default:
+				}
+			}()
+		}
+
 		if err := buf.Copy(input, writer, buf.UpdateActivity(timer)); err != nil {
 			return errors.New("failed to process request").Base(err)
 		}
 
+		if handover != nil {
+			select {
+			case <-handover.Handed:
+				return proxy.SpliceUplink(ctx, handover, conn, timer)
+			default:
+			}
+		}
+
 		return nil
 	}
 
@@ -240,6 +349,10 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 			reader = buf.NewReader(conn)
 		} else {
 			reader = NewPacketReader(conn, UDPOverride, destination)
+			if r, ok := reader.(*PacketReader); ok {
+				r.nat64 = h.nat64
+				r.names = names
+			}
 		}
 		if err := buf.Copy(reader, output, buf.UpdateActivity(timer)); err != nil {
 			return errors.New("failed to process response").Base(err)
@@ -308,9 +421,27 @@ type PacketReader struct {
 	IsOverridden      bool
 	InitUnchangedAddr net.Address
 	InitChangedAddr   net.Address
+
+	nat64 *nat64
+	names *utils.TypedSyncMap[string, net.Address]
 }
 
 func (r *PacketReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	if r.Batch != nil {
+		msgs, err := r.Batch.ReadBatch()
+		if err != nil {
+			return nil, err
+		}
+		mb := make(buf.MultiBuffer, 0, len(msgs))
+		for _, msg := range msgs {
+			r.setSource(msg.Buffer, msg.Addr)
+			mb = append(mb, msg.Buffer)
+		}
+		if r.Counter != nil {
+			r.Counter.Add(int64(mb.Len()))
+		}
+		return mb, nil
+	}
 	b := buf.New()
 	b.Resize(0, buf.Size)
 	n, d, err := r.PacketConnWrapper.ReadFrom(b.Bytes())
@@ -319,23 +450,41 @@ func (r *PacketReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
 		return nil, err
 	}
 	b.Resize(0, int32(n))
+	r.setSource(b, d.(*net.UDPAddr))
+	if r.Counter != nil {
+		r.Counter.Add(int64(n))
+	}
+	return buf.MultiBuffer{b}, nil
+}
+
+func (r *PacketReader) setSource(b *buf.Buffer, d *net.UDPAddr) {
 	// if udp dest addr is changed, we are unable to get the correct src addr
 	// so we don't attach src info to udp packet, break cone behavior, assuming the dial dest is the expected scr addr
 	if !r.IsOverridden {
-		address := net.IPAddress(d.(*net.UDPAddr).IP)
+		address := net.IPAddress(d.IP)
 		if r.InitChangedAddr == address {
 			address = r.InitUnchangedAddr
+		} else if name := r.name(address); name != nil {
+			address = name
+		} else if r.nat64 != nil {
+			address = r.nat64.unsynthesize(address)
 		}
 		b.UDP = &net.Destination{
 			Address: address,
-			Port:    net.Port(d.(*net.UDPAddr).Port),
+			Port:    net.Port(d.Port),
 			Network: net.Network_UDP,
 		}
 	}
-	if r.Counter != nil {
-		r.Counter.Add(int64(n))
+}
+
+// name returns the domain the packets replied from address were sent to, if
+// any.
+func (r *PacketReader) name(address net.Address) net.Address {
+	if r.names == nil {
+		return nil
 	}
-	return buf.MultiBuffer{b}, nil
+	name, _ := r.names.Load(address.String())
+	return name
 }
 
 // DialDest means the dial target used in the dialer when creating conn
@@ -382,9 +531,13 @@ type PacketWriter struct {
 	// Resulting in these packets being sent to many different IPs randomly
 	// So, cache and keep the resolve result
 	resolvedUDPAddr *utils.TypedSyncMap[string, net.Address]
+	// names are the domains of the addresses packets are sent to, for their
+	// replies to be of the domains. May be nil.
+	names *utils.TypedSyncMap[string, net.Address]
 }
 
 func (w *PacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	var batch []internet.UDPMessage
 	for {
 		mb2, b := buf.SplitFirst(mb)
 		mb = mb2
@@ -400,7 +553,9 @@ func (w *PacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 			if w.UDPOverride.Port != 0 {
 				b.UDP.Port = w.UDPOverride.Port
 			}
+			var name net.Address
 			if b.UDP.Address.Family().IsDomain() {
+				name = b.UDP.Address
 				if ip, ok := w.resolvedUDPAddr.Load(b.UDP.Address.Domain()); ok {
 					b.UDP.Address = ip
 				} else {
@@ -430,13 +585,27 @@ func (w *PacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 					}
 				}
 			}
+			if w.Handler.nat64 != nil {
+				b.UDP.Address = w.Handler.nat64.synthesize(w.Context, b.UDP.Address)
+			}
+			if name != nil && w.names != nil {
+				w.names.Store(b.UDP.Address.String(), name)
+			}
 			destAddr, _ := net.ResolveUDPAddr("udp", b.UDP.NetAddr())
 			if destAddr == nil {
 				b.Release()
 				continue
 			}
+			if w.Batch != nil {
+				batch = append(batch, internet.UDPMessage{Buffer: b, Addr: destAddr})
+				continue
+			}
 			n, err = w.PacketConnWrapper.WriteTo(b.Bytes(), destAddr)
 		} else {
+			if addr, ok := w.Dest.(*net.UDPAddr); ok && w.Batch != nil {
+				batch = append(batch, internet.UDPMessage{Buffer: b, Addr: addr})
+				continue
+			}
 			n, err = w.PacketConnWrapper.Write(b.Bytes())
 		}
 		b.Release()
@@ -448,7 +617,23 @@ func (w *PacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 			w.Counter.Add(int64(n))
 		}
 	}
-	return nil
+	if len(batch) == 0 {
+		return nil
+	}
+	// Packets resolved above are written together, which lets the kernel
+	// send runs of them to an address as one.
+	n, err := w.Batch.WriteBatch(batch)
+	if w.Counter != nil {
+		var written int32
+		for _, msg := range batch[:n] {
+			written += msg.Buffer.Len()
+		}
+		w.Counter.Add(int64(written))
+	}
+	for _, msg := range batch {
+		msg.Buffer.Release()
+	}
+	return err
 }
 
 type NoisePacketWriter struct {
@@ -491,6 +676,30 @@ func (w *NoisePacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 	return w.Writer.WriteMultiBuffer(mb)
 }
 
+// spliceHandover returns the handover of the uplink of the inbound, once
+// ready for it, if the inbound and conn relay the uplink as is.
+func spliceHandover(ctx context.Context, conn net.Conn) *session.SpliceHandover {
+	if runtime.GOOS != "linux" && runtime.GOOS != "android" {
+		return nil
+	}
+	inbound := session.InboundFromContext(ctx)
+	outbounds := session.OutboundsFromContext(ctx)
+	if inbound == nil || inbound.CanSpliceCopy != 1 || len(outbounds) == 0 {
+		return nil
+	}
+	for _, ob := range outbounds {
+		if ob.CanSpliceCopy != 1 {
+			return nil
+		}
+	}
+	handover := outbounds[len(outbounds)-1].SpliceHandover
+	if handover == nil || !proxy.IsRawTCPConn(conn) {
+		return nil
+	}
+	close(handover.Ready)
+	return handover
+}
+
 type FragmentWriter struct {
 	fragment *Fragment
 	writer   io.Writer
@@ -500,6 +709,10 @@ type FragmentWriter struct {
 func (f *FragmentWriter) Write(b []byte) (int, error) {
 	f.count++
 
+	if f.fragment.TlsRecord {
+		return f.writeRecords(b)
+	}
+
 	if f.fragment.PacketsFrom == 0 && f.fragment.PacketsTo == 1 {
 		if f.count != 1 || len(b) <= 5 || b[0] != 22 {
 			return f.writer.Write(b)
@@ -569,6 +782,25 @@ func (f *FragmentWriter) Write(b []byte) (int, error) {
 	}
 }
 
+// writeRecords writes each complete TLS record of b in its own write.
+func (f *FragmentWriter) writeRecords(b []byte) (int, error) {
+	from := 0
+	for len(b)-from > 5 && b[from] >= 20 && b[from] <= 23 {
+		recordLen := 5 + ((int(b[from+3]) << 8) | int(b[from+4]))
+		if len(b)-from <= recordLen {
+			break
+		}
+		n, err := f.writer.Write(b[from : from+recordLen])
+		from += n
+		if err != nil {
+			return from, err
+		}
+		time.Sleep(time.Duration(crypto.RandBetween(int64(f.fragment.IntervalMin), int64(f.fragment.IntervalMax))) * time.Millisecond)
+	}
+	n, err := f.writer.Write(b[from:])
+	return from + n, err
+}
+
 func GenerateRandomBytes(n int64) ([]byte, error) {
 	b := make([]byte, n)
 	_, err := rand.Read(b)
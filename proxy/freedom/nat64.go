@@ -0,0 +1,165 @@
+package freedom
+
+import (
+	"context"
+	gonet "net"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/dice"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/features/dns"
+)
+
+// nat64DiscoveryInterval is the least time between discoveries of the prefix,
+// while it fails.
+const nat64DiscoveryInterval = time.Minute
+
+// nat64PrefixLengths are the lengths of the prefixes of RFC 6052.
+var nat64PrefixLengths = []int{96, 64, 56, 48, 40, 32}
+
+// nat64 synthesizes the IPv6 addresses of IPv4 ones within its prefix, for
+// IPv6 only egress networks behind NAT64.
+type nat64 struct {
+	dns dns.Client
+
+	access     sync.Mutex
+	prefix     *net.IPNet
+	discovered time.Time
+}
+
+func newNAT64(prefix string, d dns.Client) (*nat64, error) {
+	n := &nat64{dns: d}
+	if prefix == "auto" {
+		return n, nil
+	}
+	_, ipNet, err := gonet.ParseCIDR(prefix)
+	if err != nil {
+		return nil, errors.New("invalid NAT64 prefix ", prefix).Base(err)
+	}
+	ones, bits := ipNet.Mask.Size()
+	if bits != 128 || ipNet.IP.To4() != nil {
+		return nil, errors.New("NAT64 prefix ", prefix, " is not IPv6")
+	}
+	if !isNAT64PrefixLength(ones) {
+		return nil, errors.New("invalid length of NAT64 prefix ", prefix)
+	}
+	n.prefix = ipNet
+	return n, nil
+}
+
+func isNAT64PrefixLength(ones int) bool {
+	for _, l := range nat64PrefixLengths {
+		if ones == l {
+			return true
+		}
+	}
+	return false
+}
+
+// getPrefix returns the prefix, discovering it from the AAAA records of
+// ipv4only.arpa as RFC 7050 if not configured. It is nil if not found.
+func (n *nat64) getPrefix(ctx context.Context) *net.IPNet {
+	n.access.Lock()
+	defer n.access.Unlock()
+
+	if n.prefix != nil || time.Since(n.discovered) < nat64DiscoveryInterval {
+		return n.prefix
+	}
+	n.discovered = time.Now()
+	ips, _, err := n.dns.LookupIP("ipv4only.arpa", dns.IPOption{IPv6Enable: true})
+	if err != nil {
+		errors.LogWarningInner(ctx, err, "failed to discover NAT64 prefix")
+		return nil
+	}
+	for _, ip := range ips {
+		for _, l := range nat64PrefixLengths {
+			prefix := &net.IPNet{IP: ip.Mask(gonet.CIDRMask(l, 128)), Mask: gonet.CIDRMask(l, 128)}
+			if v4 := extractIPv4(prefix, ip); v4 != nil && (v4.Equal(gonet.IPv4(192, 0, 0, 170)) || v4.Equal(gonet.IPv4(192, 0, 0, 171))) {
+				errors.LogInfo(ctx, "discovered NAT64 prefix ", prefix)
+				n.prefix = prefix
+				return prefix
+			}
+		}
+	}
+	errors.LogWarning(ctx, "no NAT64 prefix in ipv4only.arpa")
+	return nil
+}
+
+// synthesize returns the IPv6 address of address if it is IPv4, or address.
+func (n *nat64) synthesize(ctx context.Context, address net.Address) net.Address {
+	if !address.Family().IsIPv4() {
+		return address
+	}
+	prefix := n.getPrefix(ctx)
+	if prefix == nil {
+		return address
+	}
+	ones, _ := prefix.Mask.Size()
+	ip := make(net.IP, 16)
+	copy(ip, prefix.IP.To16())
+	p := ones / 8
+	for _, b := range address.IP().To4() {
+		// Bits 64 to 71 are reserved.
+		if p == 8 {
+			p++
+		}
+		ip[p] = b
+		p++
+	}
+	return net.IPAddress(ip)
+}
+
+// unsynthesize returns the IPv4 address address is synthesized from, or
+// address.
+func (n *nat64) unsynthesize(address net.Address) net.Address {
+	if !address.Family().IsIPv6() {
+		return address
+	}
+	n.access.Lock()
+	prefix := n.prefix
+	n.access.Unlock()
+	if prefix == nil {
+		return address
+	}
+	if v4 := extractIPv4(prefix, address.IP()); v4 != nil {
+		return net.IPAddress(v4)
+	}
+	return address
+}
+
+// dialDestination returns dest to dial through NAT64. Domains are resolved to
+// IPv6 addresses, or to IPv4 ones synthesized if there is none.
+func (n *nat64) dialDestination(ctx context.Context, dest net.Destination) net.Destination {
+	if dest.Address.Family().IsDomain() {
+		ips, _, err := n.dns.LookupIP(dest.Address.Domain(), dns.IPOption{IPv6Enable: true})
+		if err != nil || len(ips) == 0 {
+			ips, _, err = n.dns.LookupIP(dest.Address.Domain(), dns.IPOption{IPv4Enable: true})
+		}
+		if err != nil || len(ips) == 0 {
+			return dest
+		}
+		dest.Address = net.IPAddress(ips[dice.Roll(len(ips))])
+	}
+	dest.Address = n.synthesize(ctx, dest.Address)
+	return dest
+}
+
+func extractIPv4(prefix *net.IPNet, ip net.IP) net.IP {
+	if !prefix.Contains(ip) {
+		return nil
+	}
+	ones, _ := prefix.Mask.Size()
+	ip = ip.To16()
+	if ones < 96 && ip[8] != 0 {
+		return nil
+	}
+	v4 := make(net.IP, 0, 4)
+	for p := ones / 8; len(v4) < 4; p++ {
+		if p != 8 {
+			v4 = append(v4, ip[p])
+		}
+	}
+	return gonet.IPv4(v4[0], v4[1], v4[2], v4[3])
+}
@@ -0,0 +1,62 @@
+package freedom
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+)
+
+// stunPorts keeps the local ports of the UDP flows of sniffed STUN, as of
+// WebRTC, by their sources, so that the next flows of a source are sent from
+// the same port, whose mappings its peers may still know, while kept.
+type stunPorts struct {
+	access sync.Mutex
+	ports  map[string]stunPort
+}
+
+type stunPort struct {
+	port   net.Port
+	expire time.Time
+}
+
+// stunFlowSource returns the source of the flow of ctx to keep the port of,
+// or "" if it is not of STUN.
+func stunFlowSource(ctx context.Context, destination net.Destination) string {
+	if destination.Network != net.Network_UDP {
+		return ""
+	}
+	content := session.ContentFromContext(ctx)
+	inbound := session.InboundFromContext(ctx)
+	if content == nil || content.Protocol != "stun" || inbound == nil || !inbound.Source.IsValid() {
+		return ""
+	}
+	return inbound.Source.NetAddr()
+}
+
+func (p *stunPorts) get(source string) net.Port {
+	p.access.Lock()
+	defer p.access.Unlock()
+	if entry, found := p.ports[source]; found && time.Now().Before(entry.expire) {
+		return entry.port
+	}
+	return 0
+}
+
+// put keeps port for source for keep, dropping the expired ports of others.
+func (p *stunPorts) put(source string, port net.Port, keep time.Duration) {
+	p.access.Lock()
+	defer p.access.Unlock()
+	now := time.Now()
+	if p.ports == nil {
+		p.ports = make(map[string]stunPort)
+	}
+	for s, entry := range p.ports {
+		if now.After(entry.expire) {
+			delete(p.ports, s)
+		}
+	}
+	p.ports[source] = stunPort{port: port, expire: now.Add(keep)}
+}
@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"context"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/extension"
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+// AuthFailed reports to the guard, if any, that the source of the inbound of
+// ctx failed to authenticate. The source is the peer of the transport of the
+// inbound, which the listeners check, unless the guard trusts the sources
+// forwarded in headers.
+func AuthFailed(ctx context.Context) {
+	inbound := session.InboundFromContext(ctx)
+	if inbound == nil {
+		return
+	}
+	v := core.FromContext(ctx)
+	if v == nil {
+		return
+	}
+	guard, ok := v.GetFeature(extension.GuardType()).(extension.Guard)
+	if !ok {
+		return
+	}
+	source := inbound.Source
+	if inbound.Conn != nil && !guard.TrustsForwarded() {
+		source = net.DestinationFromAddr(stat.PeerAddr(inbound.Conn))
+	}
+	if source.IsValid() && source.Address.Family().IsIP() {
+		guard.Failed(source.Address.IP())
+	}
+}
@@ -11,18 +11,20 @@ import (
 	"sync"
 	"text/template"
 
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/bytespool"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
-	"github.com/xtls/xray-core/common/retry"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal"
 	"github.com/xtls/xray-core/common/task"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/proxy"
 	"github.com/xtls/xray-core/transport"
 	"github.com/xtls/xray-core/transport/internet"
 	"github.com/xtls/xray-core/transport/internet/stat"
@@ -34,6 +36,8 @@ type Client struct {
 	serverPicker  protocol.ServerPicker
 	policyManager policy.Manager
 	header        []*Header
+	version       Version
+	tlsConfig     *tls.Config
 }
 
 type h2Conn struct {
@@ -44,6 +48,7 @@ type h2Conn struct {
 var (
 	cachedH2Mutex sync.Mutex
 	cachedH2Conns map[net.Destination]h2Conn
+	cachedH3Conns map[net.Destination]*http3.ClientConn
 )
 
 // NewClient create a new http client based on the given config.
@@ -60,11 +65,18 @@ func NewClient(ctx context.Context, config *ClientConfig) (*Client, error) {
 		return nil, errors.New("0 target server")
 	}
 
+	tlsConfig := config.TlsSettings
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+
 	v := core.MustFromContext(ctx)
 	return &Client{
 		serverPicker:  protocol.NewRoundRobinServerPicker(serverList),
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
 		header:        config.Header,
+		version:       config.Version,
+		tlsConfig:     tlsConfig,
 	}, nil
 }
 
@@ -101,14 +113,17 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 		return errors.New("failed to fill out header").Base(err)
 	}
 
-	if err := retry.ExponentialBackoff(5, 100).On(func() error {
+	if err := proxy.DialRetry(ctx, 5, 100).On(func() error {
 		server := c.serverPicker.PickServer()
 		dest := server.Destination()
 		user = server.PickUser()
 
-		netConn, err := setUpHTTPTunnel(ctx, dest, targetAddr, user, dialer, header, firstPayload)
+		netConn, err := c.setUpHTTPTunnel(ctx, dest, targetAddr, user, dialer, header, firstPayload)
 		if netConn != nil {
-			if _, ok := netConn.(*http2Conn); !ok {
+			switch netConn.(type) {
+			case *http2Conn, *http3Conn:
+				// The first payload has been sent along with the request.
+			default:
 				if _, err := netConn.Write(firstPayload); err != nil {
 					netConn.Close()
 					return err
@@ -208,7 +223,7 @@ func fillRequestHeader(ctx context.Context, header []*Header) ([]*Header, error)
 }
 
 // setUpHTTPTunnel will create a socket tunnel via HTTP CONNECT method
-func setUpHTTPTunnel(ctx context.Context, dest net.Destination, target string, user *protocol.MemoryUser, dialer internet.Dialer, header []*Header, firstPayload []byte) (net.Conn, error) {
+func (c *Client) setUpHTTPTunnel(ctx context.Context, dest net.Destination, target string, user *protocol.MemoryUser, dialer internet.Dialer, header []*Header, firstPayload []byte) (net.Conn, error) {
 	req := &http.Request{
 		Method: http.MethodConnect,
 		URL:    &url.URL{Host: target},
@@ -226,6 +241,10 @@ func setUpHTTPTunnel(ctx context.Context, dest net.Destination, target string, u
 		req.Header.Set(h.Key, h.Value)
 	}
 
+	if c.version == Version_HTTP3 {
+		return c.connectHTTP3(ctx, dest, req, dialer, firstPayload)
+	}
+
 	connectHTTP1 := func(rawConn net.Conn) (net.Conn, error) {
 		req.Header.Set("Proxy-Connection", "Keep-Alive")
 
@@ -249,6 +268,8 @@ func setUpHTTPTunnel(ctx context.Context, dest net.Destination, target string, u
 		return rawConn, nil
 	}
 
+	// connectHTTP2 opens a tunnel as one stream of h2clientConn. Errors leave
+	// rawConn open, as it may be shared by other tunnels.
 	connectHTTP2 := func(rawConn net.Conn, h2clientConn *http2.ClientConn) (net.Conn, error) {
 		pr, pw := io.Pipe()
 		req.Body = pr
@@ -264,18 +285,19 @@ func setUpHTTPTunnel(ctx context.Context, dest net.Destination, target string, u
 
 		resp, err := h2clientConn.RoundTrip(req)
 		if err != nil {
-			rawConn.Close()
+			pw.Close()
 			return nil, err
 		}
 
 		wg.Wait()
 		if pErr != nil {
-			rawConn.Close()
+			resp.Body.Close()
 			return nil, pErr
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			rawConn.Close()
+			pw.Close()
+			resp.Body.Close()
 			return nil, errors.New("Proxy responded with non 200 code: " + resp.Status)
 		}
 		return newHTTP2Conn(rawConn, pw, resp.Body), nil
@@ -317,37 +339,156 @@ func setUpHTTPTunnel(ctx context.Context, dest net.Destination, target string, u
 	}
 
 	switch nextProto {
-	case "", "http/1.1":
-		return connectHTTP1(rawConn)
-	case "h2":
-		t := http2.Transport{}
-		h2clientConn, err := t.NewClientConn(rawConn)
-		if err != nil {
+	case "", "http/1.1", "h2":
+	default:
+		rawConn.Close()
+		return nil, errors.New("negotiated unsupported application layer protocol: " + nextProto)
+	}
+
+	useHTTP2 := nextProto == "h2"
+	switch c.version {
+	case Version_HTTP1:
+		if useHTTP2 {
 			rawConn.Close()
-			return nil, err
+			return nil, errors.New("server negotiated h2 while HTTP/1.1 is required")
 		}
-
-		proxyConn, err := connectHTTP2(rawConn, h2clientConn)
-		if err != nil {
+	case Version_HTTP2:
+		if nextProto == "http/1.1" {
 			rawConn.Close()
-			return nil, err
+			return nil, errors.New("server negotiated http/1.1 while HTTP/2 is required")
 		}
+		// Without ALPN, speak HTTP/2 with prior knowledge.
+		useHTTP2 = true
+	}
 
+	if !useHTTP2 {
+		return connectHTTP1(rawConn)
+	}
+
+	t := http2.Transport{}
+	h2clientConn, err := t.NewClientConn(rawConn)
+	if err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+
+	proxyConn, err := connectHTTP2(rawConn, h2clientConn)
+	if err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+
+	cachedH2Mutex.Lock()
+	if cachedH2Conns == nil {
+		cachedH2Conns = make(map[net.Destination]h2Conn)
+	}
+
+	cachedH2Conns[dest] = h2Conn{
+		rawConn: rawConn,
+		h2Conn:  h2clientConn,
+	}
+	cachedH2Mutex.Unlock()
+
+	return proxyConn, err
+}
+
+// connectHTTP3 opens a tunnel as one request stream of a shared HTTP/3
+// connection to dest.
+func (c *Client) connectHTTP3(ctx context.Context, dest net.Destination, req *http.Request, dialer internet.Dialer, firstPayload []byte) (net.Conn, error) {
+	cc, err := c.getHTTP3Conn(ctx, dest, dialer)
+	if err != nil {
+		return nil, err
+	}
+
+	str, err := cc.OpenRequestStream(ctx)
+	if err != nil {
 		cachedH2Mutex.Lock()
-		if cachedH2Conns == nil {
-			cachedH2Conns = make(map[net.Destination]h2Conn)
+		if cachedH3Conns[dest] == cc {
+			delete(cachedH3Conns, dest)
 		}
+		cachedH2Mutex.Unlock()
+		return nil, err
+	}
+	abort := func() {
+		str.CancelWrite(quic.StreamErrorCode(http3.ErrCodeRequestCanceled))
+		str.CancelRead(quic.StreamErrorCode(http3.ErrCodeRequestCanceled))
+	}
 
-		cachedH2Conns[dest] = h2Conn{
-			rawConn: rawConn,
-			h2Conn:  h2clientConn,
+	if err := str.SendRequestHeader(req); err != nil {
+		abort()
+		return nil, err
+	}
+	if len(firstPayload) > 0 {
+		if _, err := str.Write(firstPayload); err != nil {
+			abort()
+			return nil, err
 		}
-		cachedH2Mutex.Unlock()
+	}
 
-		return proxyConn, err
-	default:
-		return nil, errors.New("negotiated unsupported application layer protocol: " + nextProto)
+	resp, err := str.ReadResponse()
+	if err != nil {
+		abort()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		abort()
+		return nil, errors.New("Proxy responded with non 200 code: " + resp.Status)
 	}
+
+	return &http3Conn{
+		RequestStream: str,
+		local:         cc.Conn().LocalAddr(),
+		remote:        cc.Conn().RemoteAddr(),
+	}, nil
+}
+
+func (c *Client) getHTTP3Conn(ctx context.Context, dest net.Destination, dialer internet.Dialer) (*http3.ClientConn, error) {
+	cachedH2Mutex.Lock()
+	cc, found := cachedH3Conns[dest]
+	cachedH2Mutex.Unlock()
+	if found && cc.Context().Err() == nil {
+		return cc, nil
+	}
+
+	rawConn, err := dialer.Dial(ctx, net.UDPDestination(dest.Address, dest.Port))
+	if err != nil {
+		return nil, err
+	}
+	packetConn, remoteAddr, err := internet.ToPacketConn(rawConn)
+	if err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+
+	tlsConfig := c.tlsConfig.GetTLSConfig(tls.WithDestination(dest))
+	if len(c.tlsConfig.NextProtocol) == 0 {
+		tlsConfig.NextProtos = []string{http3.NextProtoH3}
+	}
+	quicConfig := &quic.Config{
+		MaxIdleTimeout:  net.ConnIdleTimeout,
+		KeepAlivePeriod: net.QuicgoH3KeepAlivePeriod,
+	}
+	quicConn, err := quic.DialEarly(ctx, packetConn, remoteAddr, tlsConfig, quicConfig)
+	if err != nil {
+		rawConn.Close()
+		return nil, errors.New("QUIC handshake failed").Base(err)
+	}
+	go func() {
+		<-quicConn.Context().Done()
+		rawConn.Close()
+	}()
+
+	t := &http3.Transport{DisableCompression: true}
+	cc = t.NewClientConn(quicConn)
+
+	cachedH2Mutex.Lock()
+	if cachedH3Conns == nil {
+		cachedH3Conns = make(map[net.Destination]*http3.ClientConn)
+	}
+	cachedH3Conns[dest] = cc
+	cachedH2Mutex.Unlock()
+
+	return cc, nil
 }
 
 func newHTTP2Conn(c net.Conn, pipedReqBody *io.PipeWriter, respBody io.ReadCloser) net.Conn {
@@ -373,6 +514,26 @@ func (h *http2Conn) Close() error {
 	return h.out.Close()
 }
 
+// http3Conn is a tunnel carried by an HTTP/3 request stream.
+type http3Conn struct {
+	*http3.RequestStream
+	local  net.Addr
+	remote net.Addr
+}
+
+func (h *http3Conn) Close() error {
+	h.CancelRead(quic.StreamErrorCode(http3.ErrCodeNoError))
+	return h.RequestStream.Close()
+}
+
+func (h *http3Conn) LocalAddr() net.Addr {
+	return h.local
+}
+
+func (h *http3Conn) RemoteAddr() net.Addr {
+	return h.remote
+}
+
 func init() {
 	common.Must(common.RegisterConfig((*ClientConfig)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
 		return NewClient(ctx, config.(*ClientConfig))
@@ -0,0 +1,200 @@
+package http
+
+import (
+	"context"
+	goerrors "errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/quic-go/quicvarint"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+)
+
+const (
+	// connectUDPProtocol is the upgrade token and :protocol of RFC 9298.
+	connectUDPProtocol = "connect-udp"
+	connectUDPPath     = "/.well-known/masque/udp/"
+
+	capsuleTypeDatagram = 0x00
+	// maxCapsuleSize bounds a DATAGRAM capsule, a UDP payload plus its context ID.
+	maxCapsuleSize = 65535 + 8
+)
+
+// isConnectUDP reports whether request is an HTTP/1.1 connect-udp upgrade.
+func isConnectUDP(request *http.Request) bool {
+	return request.Method == http.MethodGet &&
+		strings.EqualFold(request.Header.Get("Upgrade"), connectUDPProtocol) &&
+		strings.Contains(strings.ToLower(request.Header.Get("Connection")), "upgrade")
+}
+
+// parseConnectUDPTarget extracts the UDP target from a connect-udp URI. Both
+// the default template "/.well-known/masque/udp/{host}/{port}/" and the
+// "?h={host}&p={port}" form are accepted.
+func parseConnectUDPTarget(u *url.URL) (net.Destination, error) {
+	var host, port string
+	if path := u.EscapedPath(); strings.HasPrefix(path, connectUDPPath) {
+		parts := strings.Split(strings.TrimPrefix(path, connectUDPPath), "/")
+		if len(parts) < 2 || (len(parts) > 2 && parts[2] != "") {
+			return net.Destination{}, errors.New("malformed connect-udp path: ", path)
+		}
+		var err error
+		if host, err = url.PathUnescape(parts[0]); err != nil {
+			return net.Destination{}, errors.New("malformed connect-udp host").Base(err)
+		}
+		port = parts[1]
+	} else {
+		query := u.Query()
+		host, port = query.Get("h"), query.Get("p")
+	}
+	if host == "" || port == "" {
+		return net.Destination{}, errors.New("missing connect-udp target in ", u.String())
+	}
+	p, err := net.PortFromString(port)
+	if err != nil {
+		return net.Destination{}, errors.New("malformed connect-udp port: ", port).Base(err)
+	}
+	return net.UDPDestination(net.ParseAddress(host), p), nil
+}
+
+// capsuleReader reads UDP payloads out of the DATAGRAM capsules (RFC 9297)
+// of a connect-udp stream. Other capsules are skipped.
+type capsuleReader struct {
+	reader quicvarint.Reader
+	dest   net.Destination
+}
+
+func newCapsuleReader(reader io.Reader, dest net.Destination) *capsuleReader {
+	return &capsuleReader{
+		reader: quicvarint.NewReader(reader),
+		dest:   dest,
+	}
+}
+
+func (r *capsuleReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	for {
+		capsuleType, err := quicvarint.Read(r.reader)
+		if err != nil {
+			return nil, err
+		}
+		length, err := quicvarint.Read(r.reader)
+		if err != nil {
+			return nil, err
+		}
+		if capsuleType != capsuleTypeDatagram {
+			if _, err := io.CopyN(io.Discard, r.reader, int64(length)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if length > maxCapsuleSize {
+			return nil, errors.New("DATAGRAM capsule too large: ", length)
+		}
+		b := buf.NewWithSize(int32(length))
+		if _, err := b.ReadFullFrom(r.reader, int32(length)); err != nil {
+			b.Release()
+			return nil, err
+		}
+		contextID, n, err := quicvarint.Parse(b.Bytes())
+		if err != nil || contextID != 0 {
+			// Only context ID 0, plain UDP payloads, is defined by RFC 9298.
+			b.Release()
+			continue
+		}
+		b.Advance(int32(n))
+		dest := r.dest
+		b.UDP = &dest
+		return buf.MultiBuffer{b}, nil
+	}
+}
+
+// capsuleWriter wraps every UDP payload into a DATAGRAM capsule.
+type capsuleWriter struct {
+	writer io.Writer
+	flush  func()
+}
+
+func (w *capsuleWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	defer buf.ReleaseMulti(mb)
+	for _, b := range mb {
+		length := uint64(1 + b.Len())
+		capsule := make([]byte, 0, quicvarint.Len(capsuleTypeDatagram)+quicvarint.Len(length)+int(length))
+		capsule = quicvarint.Append(capsule, capsuleTypeDatagram)
+		capsule = quicvarint.Append(capsule, length)
+		capsule = append(capsule, 0) // context ID
+		capsule = append(capsule, b.Bytes()...)
+		if _, err := w.writer.Write(capsule); err != nil {
+			return err
+		}
+	}
+	if w.flush != nil {
+		w.flush()
+	}
+	return nil
+}
+
+// datagramReader reads UDP payloads out of the HTTP/3 datagrams (RFC 9297) of
+// a connect-udp stream, until the stream ends. Capsules on the stream are
+// skipped.
+type datagramReader struct {
+	ctx    context.Context
+	stream *http3.Stream
+	dest   net.Destination
+}
+
+func newDatagramReader(ctx context.Context, stream *http3.Stream, dest net.Destination) *datagramReader {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		io.Copy(io.Discard, stream)
+		cancel()
+	}()
+	return &datagramReader{
+		ctx:    ctx,
+		stream: stream,
+		dest:   dest,
+	}
+}
+
+func (r *datagramReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	for {
+		datagram, err := r.stream.ReceiveDatagram(r.ctx)
+		if err != nil {
+			return nil, err
+		}
+		contextID, n, err := quicvarint.Parse(datagram)
+		if err != nil || contextID != 0 {
+			continue
+		}
+		b := buf.FromBytes(datagram[n:])
+		dest := r.dest
+		b.UDP = &dest
+		return buf.MultiBuffer{b}, nil
+	}
+}
+
+// datagramWriter sends every UDP payload in an HTTP/3 datagram.
+type datagramWriter struct {
+	stream *http3.Stream
+}
+
+func (w *datagramWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	defer buf.ReleaseMulti(mb)
+	for _, b := range mb {
+		datagram := make([]byte, 0, 1+b.Len())
+		datagram = append(datagram, 0) // context ID
+		datagram = append(datagram, b.Bytes()...)
+		if err := w.stream.SendDatagram(datagram); err != nil {
+			var tooLarge *quic.DatagramTooLargeError
+			if !goerrors.As(err, &tooLarge) {
+				return err
+			}
+			// Dropped like a UDP packet beyond the MTU.
+		}
+	}
+	return nil
+}
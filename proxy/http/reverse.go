@@ -0,0 +1,264 @@
+package http
+
+import (
+	"context"
+	gotls "crypto/tls"
+	golog "log"
+	gonet "net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+
+	c "github.com/xtls/xray-core/common/ctx"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/net/cnc"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/routing"
+	"golang.org/x/net/http2"
+)
+
+// reverseRoute is a ReverseRoute with its upstream parsed, nil for the host
+// of the request.
+type reverseRoute struct {
+	*ReverseRoute
+	upstream *url.URL
+}
+
+// newReverseRoutes returns the routes of config, checked.
+func newReverseRoutes(config []*ReverseRoute) ([]*reverseRoute, error) {
+	routes := make([]*reverseRoute, 0, len(config))
+	for _, r := range config {
+		route := &reverseRoute{ReverseRoute: r}
+		if r.Upstream != "" {
+			u, err := url.Parse(r.Upstream)
+			if err != nil {
+				return nil, errors.New("invalid upstream URL ", r.Upstream).Base(err)
+			}
+			if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, errors.New("invalid upstream URL ", r.Upstream)
+			}
+			route.upstream = u
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// matches returns whether the request of host and path is of r.
+func (r *reverseRoute) matches(host string, path string) bool {
+	if !strings.HasPrefix(path, r.Path) {
+		return false
+	}
+	if len(r.Hosts) == 0 {
+		return true
+	}
+	for _, h := range r.Hosts {
+		if suffix, found := strings.CutPrefix(h, "*."); found {
+			if strings.HasSuffix(host, "."+strings.ToLower(suffix)) {
+				return true
+			}
+		} else if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// reverseRoute returns the first route of the request, or nil if there is
+// none.
+func (s *Server) reverseRoute(r *http.Request) *reverseRoute {
+	host := strings.ToLower(r.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, route := range s.reverse {
+		if route.matches(host, r.URL.Path) {
+			return route
+		}
+	}
+	return nil
+}
+
+// serveReverse serves the requests of conn as a reverse proxy, until it is
+// closed.
+func (s *Server) serveReverse(ctx context.Context, conn net.Conn, h2 bool, dispatcher routing.Dispatcher) error {
+	// Upgraded connections are relayed by their handlers, after the
+	// server is done with them.
+	var handlers sync.WaitGroup
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.Add(1)
+		defer handlers.Done()
+		s.handleReverse(ctx, w, r, dispatcher)
+	})
+	defer handlers.Wait()
+
+	if h2 {
+		server := &http2.Server{
+			IdleTimeout: s.policy().Timeouts.ConnectionIdle,
+		}
+		server.ServeConn(conn, &http2.ServeConnOpts{
+			Context: ctx,
+			Handler: handler,
+		})
+		return nil
+	}
+	listener := &connListener{conn: conn, done: make(chan struct{})}
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: s.policy().Timeouts.Handshake,
+		IdleTimeout:       s.policy().Timeouts.ConnectionIdle,
+		BaseContext: func(gonet.Listener) context.Context {
+			return ctx
+		},
+		ConnState: func(_ gonet.Conn, state http.ConnState) {
+			if state == http.StateClosed || state == http.StateHijacked {
+				listener.close()
+			}
+		},
+		ErrorLog: golog.New(&reverseLog{ctx: ctx}, "", 0),
+	}
+	server.Serve(listener)
+	return nil
+}
+
+// handleReverse relays a request to the upstream of its route.
+func (s *Server) handleReverse(ctx context.Context, w http.ResponseWriter, r *http.Request, dispatcher routing.Dispatcher) {
+	// Requests are served in parallel over HTTP/2, so each of them gets its
+	// own session metadata.
+	ctx = c.ContextWithID(ctx, session.NewID())
+	ctx = session.ContextCloneOutboundsAndContent(ctx)
+	inbound := *session.InboundFromContext(ctx)
+	inbound.CanSpliceCopy = 3
+	inbound.User = &protocol.MemoryUser{
+		Level: s.config.UserLevel,
+	}
+	ctx = session.ContextWithInbound(ctx, &inbound)
+
+	route := s.reverseRoute(r)
+	if route == nil {
+		errors.LogInfo(ctx, "no reverse route for Host [", r.Host, "] with URL [", r.URL, "]")
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method == http.MethodConnect {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	upstream := route.upstream
+	if upstream == nil {
+		upstream = &url.URL{Scheme: "http", Host: r.Host}
+	}
+	if route.OutboundTag != "" {
+		ctx = session.SetForcedOutboundTagToContext(ctx, route.OutboundTag)
+	}
+	log.Record(&log.AccessMessage{
+		From:   inbound.Source,
+		To:     upstream.String() + r.URL.Path,
+		Status: log.AccessAccepted,
+		Reason: "",
+	})
+	errors.LogInfo(ctx, "relaying Method [", r.Method, "] Host [", r.Host, "] with URL [", r.URL, "] to ", upstream)
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(p *httputil.ProxyRequest) {
+			if route.StripPath {
+				p.Out.URL.Path = "/" + strings.TrimLeft(strings.TrimPrefix(p.Out.URL.Path, route.Path), "/")
+				p.Out.URL.RawPath = ""
+			}
+			p.SetURL(upstream)
+			p.SetXForwarded()
+			if route.PreserveHost {
+				p.Out.Host = p.In.Host
+			}
+			setHeaders(p.Out.Header, route.RequestHeaders)
+		},
+		ModifyResponse: func(response *http.Response) error {
+			setHeaders(response.Header, route.ResponseHeaders)
+			return nil
+		},
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, network string, address string) (gonet.Conn, error) {
+				dest, err := net.ParseDestination(network + ":" + address)
+				if err != nil {
+					return nil, errors.New("cannot understand address").Base(err)
+				}
+				link, err := dispatcher.Dispatch(ctx, dest)
+				if err != nil {
+					return nil, err
+				}
+				return cnc.NewConnection(cnc.ConnectionInputMulti(link.Writer), cnc.ConnectionOutputMulti(link.Reader)), nil
+			},
+			TLSClientConfig: &gotls.Config{
+				ServerName: upstream.Hostname(),
+			},
+			// The connections are of the session of the request.
+			DisableKeepAlives:     true,
+			TLSHandshakeTimeout:   s.policy().Timeouts.Handshake,
+			ResponseHeaderTimeout: s.policy().Timeouts.ConnectionIdle,
+		},
+		ErrorLog: golog.New(&reverseLog{ctx: ctx}, "", 0),
+	}
+	proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// setHeaders sets headers on header, removing those of empty values.
+func setHeaders(header http.Header, headers map[string]string) {
+	for key, value := range headers {
+		if value == "" {
+			header.Del(key)
+		} else {
+			header.Set(key, value)
+		}
+	}
+}
+
+// reverseLog logs the errors of the reverse proxy.
+type reverseLog struct {
+	ctx context.Context
+}
+
+func (l *reverseLog) Write(b []byte) (int, error) {
+	errors.LogInfo(l.ctx, "reverse proxy: ", strings.TrimSpace(string(b)))
+	return len(b), nil
+}
+
+// connListener is a listener accepting conn only, which is closed once the
+// server is done with conn.
+type connListener struct {
+	conn     net.Conn
+	accepted bool
+	done     chan struct{}
+	once     sync.Once
+}
+
+// Accept implements net.Listener.
+func (l *connListener) Accept() (net.Conn, error) {
+	if !l.accepted {
+		l.accepted = true
+		return l.conn, nil
+	}
+	<-l.done
+	return nil, gonet.ErrClosed
+}
+
+func (l *connListener) close() {
+	l.once.Do(func() {
+		close(l.done)
+	})
+}
+
+// Close implements net.Listener.
+func (l *connListener) Close() error {
+	l.close()
+	return nil
+}
+
+// Addr implements net.Listener.
+func (l *connListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
@@ -9,9 +9,13 @@ import (
 	"net/http"
 	"strings"
 	"time"
+	_ "unsafe"
 
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
+	c "github.com/xtls/xray-core/common/ctx"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/net"
@@ -24,12 +28,25 @@ import (
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
 	"github.com/xtls/xray-core/transport/internet/stat"
+	"github.com/xtls/xray-core/transport/internet/tls"
+	"golang.org/x/net/http2"
 )
 
+//go:linkname h2DisableExtendedConnect golang.org/x/net/http2.disableExtendedConnectProtocol
+var h2DisableExtendedConnect bool
+
+func init() {
+	// golang.org/x/net/http2 only accepts extended CONNECT, of connect-udp,
+	// with GODEBUG=http2xconnect=1.
+	h2DisableExtendedConnect = false
+}
+
 // Server is an HTTP proxy server.
 type Server struct {
 	config        *ServerConfig
 	policyManager policy.Manager
+	// reverse is the routes of the server as a reverse proxy, if it is one.
+	reverse []*reverseRoute
 }
 
 // NewServer creates a new HTTP inbound handler.
@@ -39,6 +56,13 @@ func NewServer(ctx context.Context, config *ServerConfig) (*Server, error) {
 		config:        config,
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
 	}
+	if len(config.Reverse) > 0 {
+		reverse, err := newReverseRoutes(config.Reverse)
+		if err != nil {
+			return nil, err
+		}
+		s.reverse = reverse
+	}
 
 	return s, nil
 }
@@ -95,6 +119,12 @@ func (s *Server) ProcessWithFirstbyte(ctx context.Context, network net.Network,
 	inbound.User = &protocol.MemoryUser{
 		Level: s.config.UserLevel,
 	}
+	if conn, ok := quicConn(conn); ok {
+		if s.reverse != nil {
+			return errors.New("reverse proxy over HTTP/3 is not supported")
+		}
+		return s.serveHTTP3(ctx, conn, dispatcher)
+	}
 	var reader *bufio.Reader
 	if len(firstbyte) > 0 {
 		readerWithoutFirstbyte := bufio.NewReaderSize(readerOnly{conn}, buf.Size)
@@ -104,6 +134,14 @@ func (s *Server) ProcessWithFirstbyte(ctx context.Context, network net.Network,
 		reader = bufio.NewReaderSize(readerOnly{conn}, buf.Size)
 	}
 
+	if s.reverse != nil {
+		inbound.CanSpliceCopy = 3
+		return s.serveReverse(ctx, &bufferedConn{Connection: conn, reader: reader}, s.isHTTP2(ctx, conn, reader), dispatcher)
+	}
+	if s.isHTTP2(ctx, conn, reader) {
+		return s.serveHTTP2(ctx, &bufferedConn{Connection: conn, reader: reader}, dispatcher)
+	}
+
 Start:
 	if err := conn.SetReadDeadline(time.Now().Add(s.policy().Timeouts.Handshake)); err != nil {
 		errors.LogInfoInner(ctx, err, "failed to set read deadline")
@@ -133,6 +171,24 @@ Start:
 		errors.LogDebugInner(ctx, err, "failed to clear read deadline")
 	}
 
+	if isConnectUDP(request) {
+		dest, err := parseConnectUDPTarget(request.URL)
+		if err != nil {
+			conn.Write([]byte("HTTP/1.1 400 Bad Request\r\nConnection: close\r\n\r\n"))
+			return err
+		}
+		ctx = log.ContextWithAccessMessage(ctx, &log.AccessMessage{
+			From:   conn.RemoteAddr(),
+			To:     dest,
+			Status: log.AccessAccepted,
+			Reason: "",
+		})
+		if _, err := conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: connect-udp\r\nCapsule-Protocol: ?1\r\n\r\n")); err != nil {
+			return errors.New("failed to write back upgrade response").Base(err)
+		}
+		return s.handleConnectUDP(ctx, newCapsuleReader(reader, dest), &capsuleWriter{writer: conn}, dest, dispatcher, inbound)
+	}
+
 	defaultPort := net.Port(80)
 	if strings.EqualFold(request.URL.Scheme, "https") {
 		defaultPort = net.Port(443)
@@ -228,6 +284,266 @@ func (s *Server) handleConnect(ctx context.Context, _ *http.Request, reader *buf
 	return nil
 }
 
+// handleConnectUDP relays the UDP payloads of a connect-udp stream to dest.
+func (s *Server) handleConnectUDP(ctx context.Context, reader buf.Reader, writer buf.Writer, dest net.Destination, dispatcher routing.Dispatcher, inbound *session.Inbound) error {
+	plcy := s.policy()
+	ctx, cancel := context.WithCancel(ctx)
+	timer := signal.CancelAfterInactivity(ctx, cancel, plcy.Timeouts.ConnectionIdle)
+
+	if inbound != nil {
+		inbound.Timer = timer
+	}
+
+	ctx = policy.ContextWithBufferPolicy(ctx, plcy.Buffer)
+	link, err := dispatcher.Dispatch(ctx, dest)
+	if err != nil {
+		return err
+	}
+
+	requestDone := func() error {
+		defer timer.SetTimeout(plcy.Timeouts.DownlinkOnly)
+		return buf.Copy(reader, link.Writer, buf.UpdateActivity(timer))
+	}
+
+	responseDone := func() error {
+		defer timer.SetTimeout(plcy.Timeouts.UplinkOnly)
+		return buf.Copy(link.Reader, writer, buf.UpdateActivity(timer))
+	}
+
+	closeWriter := task.OnSuccess(requestDone, task.Close(link.Writer))
+	if err := task.Run(ctx, closeWriter, responseDone); err != nil {
+		common.Interrupt(link.Reader)
+		common.Interrupt(link.Writer)
+		return errors.New("connection ends").Base(err)
+	}
+
+	return nil
+}
+
+// isHTTP2 reports whether the client speaks HTTP/2, either negotiated through
+// TLS ALPN or with prior knowledge on a cleartext connection.
+func (s *Server) isHTTP2(ctx context.Context, conn stat.Connection, reader *bufio.Reader) bool {
+	if err := conn.SetReadDeadline(time.Now().Add(s.policy().Timeouts.Handshake)); err != nil {
+		errors.LogInfoInner(ctx, err, "failed to set read deadline")
+	}
+	defer conn.SetReadDeadline(time.Time{})
+
+	// Peeking completes the TLS handshake, if any.
+	if _, err := reader.Peek(1); err != nil {
+		return false
+	}
+	iConn := conn
+	if statConn, ok := iConn.(*stat.CounterConnection); ok {
+		iConn = statConn.Connection
+	}
+	if tlsConn, ok := iConn.(*tls.Conn); ok && tlsConn.ConnectionState().NegotiatedProtocol == "h2" {
+		return true
+	}
+	// "PRI" is never a valid HTTP/1 method, so it is safe to wait for the whole preface.
+	if prefix, err := reader.Peek(3); err != nil || string(prefix) != "PRI" {
+		return false
+	}
+	preface, err := reader.Peek(len(http2.ClientPreface))
+	return err == nil && string(preface) == http2.ClientPreface
+}
+
+// bufferedConn replays the bytes already buffered by reader.
+type bufferedConn struct {
+	stat.Connection
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func (s *Server) serveHTTP2(ctx context.Context, conn net.Conn, dispatcher routing.Dispatcher) error {
+	server := &http2.Server{
+		IdleTimeout: s.policy().Timeouts.ConnectionIdle,
+	}
+	server.ServeConn(conn, &http2.ServeConnOpts{
+		Context: ctx,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.handleHTTP2Request(ctx, w, r, dispatcher)
+		}),
+	})
+	return nil
+}
+
+// quicConn returns the QUIC connection conn is, if it is one of HTTP/3.
+func quicConn(conn stat.Connection) (*quic.Conn, bool) {
+	if statConn, ok := conn.(*stat.CounterConnection); ok {
+		conn = statConn.Connection
+	}
+	if c, ok := conn.(interface{ QUICConn() *quic.Conn }); ok {
+		return c.QUICConn(), true
+	}
+	return nil, false
+}
+
+// serveHTTP3 serves the streams of a QUIC connection of HTTP/3 as those of
+// HTTP/2. connect-udp relays the payloads in HTTP/3 datagrams.
+func (s *Server) serveHTTP3(ctx context.Context, conn *quic.Conn, dispatcher routing.Dispatcher) error {
+	server := &http3.Server{
+		EnableDatagrams: true,
+		IdleTimeout:     s.policy().Timeouts.ConnectionIdle,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.handleHTTP2Request(ctx, w, r, dispatcher)
+		}),
+	}
+	server.ServeQUICConn(conn)
+	return nil
+}
+
+// extendedProtocol returns the :protocol of an extended CONNECT request,
+// which quic-go puts in Proto, or "" if it is none.
+func extendedProtocol(r *http.Request) string {
+	if r.ProtoMajor == 3 {
+		if r.Proto != "HTTP/3.0" {
+			return r.Proto
+		}
+		return ""
+	}
+	return r.Header.Get(":protocol")
+}
+
+// handleHTTP2Request serves one stream of an HTTP/2 or HTTP/3 connection.
+// Only CONNECT is supported, including connect-udp as an extended CONNECT
+// (RFC 9298).
+func (s *Server) handleHTTP2Request(ctx context.Context, w http.ResponseWriter, r *http.Request, dispatcher routing.Dispatcher) {
+	// Streams run in parallel, so each of them gets its own session metadata.
+	ctx = c.ContextWithID(ctx, session.NewID())
+	ctx = session.ContextCloneOutboundsAndContent(ctx)
+	inbound := *session.InboundFromContext(ctx)
+	inbound.CanSpliceCopy = 3
+	inbound.User = &protocol.MemoryUser{
+		Level: s.config.UserLevel,
+	}
+	ctx = session.ContextWithInbound(ctx, &inbound)
+
+	if len(s.config.Accounts) > 0 {
+		user, pass, ok := parseBasicAuth(r.Header.Get("Proxy-Authorization"))
+		if !ok || !s.config.HasAccount(user, pass) {
+			w.Header().Set("Proxy-Authenticate", "Basic realm=\"proxy\"")
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		inbound.User.Email = user
+	}
+
+	errors.LogInfo(ctx, "request to Method [", r.Method, "] Host [", r.Host, "] with URL [", r.URL, "] over HTTP/", r.ProtoMajor)
+	if r.Method != http.MethodConnect {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	flush := func() {
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			errors.LogDebugInner(ctx, err, "failed to flush HTTP/2 response")
+		}
+	}
+
+	var err error
+	switch extendedProtocol(r) {
+	case connectUDPProtocol:
+		var dest net.Destination
+		dest, err = parseConnectUDPTarget(r.URL)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			break
+		}
+		ctx = log.ContextWithAccessMessage(ctx, &log.AccessMessage{
+			From:   inbound.Source,
+			To:     dest,
+			Status: log.AccessAccepted,
+			Reason: "",
+		})
+		w.Header().Set("Capsule-Protocol", "?1")
+		w.WriteHeader(http.StatusOK)
+		flush()
+		var reader buf.Reader = newCapsuleReader(r.Body, dest)
+		var writer buf.Writer = &capsuleWriter{writer: w, flush: flush}
+		if streamer, ok := w.(http3.HTTPStreamer); ok {
+			stream := streamer.HTTPStream()
+			defer func() {
+				stream.CancelRead(0)
+				stream.Close()
+			}()
+			reader, writer = newDatagramReader(ctx, stream, dest), &datagramWriter{stream: stream}
+		}
+		err = s.handleConnectUDP(ctx, reader, writer, dest, dispatcher, &inbound)
+	case "":
+		var dest net.Destination
+		dest, err = http_proto.ParseHost(r.Host, net.Port(443))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			break
+		}
+		ctx = log.ContextWithAccessMessage(ctx, &log.AccessMessage{
+			From:   inbound.Source,
+			To:     dest,
+			Status: log.AccessAccepted,
+			Reason: "",
+		})
+		w.WriteHeader(http.StatusOK)
+		flush()
+		err = s.handleHTTP2Connect(ctx, r.Body, &flushWriter{writer: w, flush: flush}, dest, dispatcher, &inbound)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		errors.LogInfoInner(ctx, err, "failed to serve HTTP/", r.ProtoMajor, " request")
+	}
+}
+
+// flushWriter flushes the HTTP/2 response after every write so that the
+// tunnel is not delayed by buffering.
+type flushWriter struct {
+	writer io.Writer
+	flush  func()
+}
+
+func (w *flushWriter) Write(b []byte) (int, error) {
+	n, err := w.writer.Write(b)
+	if err == nil {
+		w.flush()
+	}
+	return n, err
+}
+
+func (s *Server) handleHTTP2Connect(ctx context.Context, reader io.Reader, writer io.Writer, dest net.Destination, dispatcher routing.Dispatcher, inbound *session.Inbound) error {
+	plcy := s.policy()
+	ctx, cancel := context.WithCancel(ctx)
+	timer := signal.CancelAfterInactivity(ctx, cancel, plcy.Timeouts.ConnectionIdle)
+	inbound.Timer = timer
+
+	ctx = policy.ContextWithBufferPolicy(ctx, plcy.Buffer)
+	link, err := dispatcher.Dispatch(ctx, dest)
+	if err != nil {
+		return err
+	}
+
+	requestDone := func() error {
+		defer timer.SetTimeout(plcy.Timeouts.DownlinkOnly)
+		return buf.Copy(buf.NewReader(reader), link.Writer, buf.UpdateActivity(timer))
+	}
+
+	responseDone := func() error {
+		defer timer.SetTimeout(plcy.Timeouts.UplinkOnly)
+		return buf.Copy(link.Reader, buf.NewWriter(writer), buf.UpdateActivity(timer))
+	}
+
+	closeWriter := task.OnSuccess(requestDone, task.Close(link.Writer))
+	if err := task.Run(ctx, closeWriter, responseDone); err != nil {
+		common.Interrupt(link.Reader)
+		common.Interrupt(link.Writer)
+		return errors.New("connection ends").Base(err)
+	}
+
+	return nil
+}
+
 var errWaitAnother = errors.New("keep alive")
 
 func (s *Server) handlePlainHTTP(ctx context.Context, request *http.Request, writer io.Writer, dest net.Destination, dispatcher routing.Dispatcher) error {
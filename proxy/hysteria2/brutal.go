@@ -0,0 +1,82 @@
+package hysteria2
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/buf"
+)
+
+// brutalRate returns the rate in bytes per second to send at by Brutal, as
+// negotiated from up and the receive rate rx the server answered with, or 0
+// for the congestion control of QUIC, which the server asks for with "auto".
+func brutalRate(up uint64, rx string) uint64 {
+	if up == 0 || rx == "auto" {
+		return 0
+	}
+	if serverRx, err := strconv.ParseUint(rx, 10, 64); err == nil && serverRx > 0 && serverRx < up {
+		return serverRx
+	}
+	return up
+}
+
+// pacer spaces the sends of a connection out to its rate, as Brutal does
+// regardless of losses. quic-go has no pluggable congestion control, so its
+// own still bounds the sends under the rate.
+type pacer struct {
+	access sync.Mutex
+	rate   uint64
+	next   time.Time
+}
+
+func newPacer(rate uint64) *pacer {
+	if rate == 0 {
+		return nil
+	}
+	return &pacer{rate: rate}
+}
+
+// wait blocks until size bytes may be sent, or ctx is done.
+func (p *pacer) wait(ctx context.Context, size int) error {
+	if p == nil {
+		return nil
+	}
+	p.access.Lock()
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	at := p.next
+	p.next = p.next.Add(time.Duration(uint64(size) * uint64(time.Second) / p.rate))
+	p.access.Unlock()
+
+	d := time.Until(at)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pacedWriter writes to Writer at the rate of pacer.
+type pacedWriter struct {
+	buf.Writer
+	ctx   context.Context
+	pacer *pacer
+}
+
+func (w *pacedWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	if err := w.pacer.wait(w.ctx, int(mb.Len())); err != nil {
+		buf.ReleaseMulti(mb)
+		return err
+	}
+	return w.Writer.WriteMultiBuffer(mb)
+}
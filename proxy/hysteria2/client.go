@@ -0,0 +1,432 @@
+package hysteria2
+
+import (
+	"bufio"
+	"context"
+	gotls "crypto/tls"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/dice"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/tls"
+)
+
+func init() {
+	common.Must(common.RegisterConfig((*ClientConfig)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		return NewClient(ctx, config.(*ClientConfig))
+	}))
+}
+
+// Client is an outbound handler for the Hysteria2 protocol. All requests
+// share a single QUIC connection which is re-established on demand.
+type Client struct {
+	server        net.Destination
+	password      string
+	obfsPassword  []byte
+	tlsConfig     *tls.Config
+	up            uint64
+	down          uint64
+	level         uint32
+	policyManager policy.Manager
+
+	access sync.Mutex
+	conn   *clientConn
+}
+
+// NewClient creates a new Hysteria2 client.
+func NewClient(ctx context.Context, config *ClientConfig) (*Client, error) {
+	if config.Address == nil {
+		return nil, errors.New("hysteria2: server address is not set")
+	}
+	if config.Port == 0 {
+		return nil, errors.New("hysteria2: server port is not set")
+	}
+	c := &Client{
+		server:    net.UDPDestination(config.Address.AsAddress(), net.Port(config.Port)),
+		password:  config.Password,
+		tlsConfig: config.TlsSettings,
+		up:        config.Up,
+		down:      config.Down,
+		level:     config.Level,
+	}
+	if c.tlsConfig == nil {
+		c.tlsConfig = &tls.Config{}
+	}
+	if obfs := config.Obfs; obfs != nil && obfs.Type != "" {
+		if obfs.Type != "salamander" {
+			return nil, errors.New("hysteria2: unknown obfs type ", obfs.Type)
+		}
+		if len(obfs.Password) < salamanderMinPSK {
+			return nil, errors.New("hysteria2: salamander password must be at least ", salamanderMinPSK, " bytes")
+		}
+		c.obfsPassword = []byte(obfs.Password)
+	}
+	v := core.MustFromContext(ctx)
+	c.policyManager = v.GetFeature(policy.ManagerType()).(policy.Manager)
+	return c, nil
+}
+
+// Process implements proxy.Outbound.Process().
+func (c *Client) Process(ctx context.Context, link *transport.Link, dialer internet.Dialer) error {
+	outbounds := session.OutboundsFromContext(ctx)
+	ob := outbounds[len(outbounds)-1]
+	if !ob.Target.IsValid() {
+		return errors.New("target not specified")
+	}
+	ob.Name = "hysteria2"
+	ob.CanSpliceCopy = 3
+	destination := ob.Target
+
+	conn, err := c.getConn(ctx, dialer)
+	if err != nil {
+		return errors.New("failed to connect to server ", c.server.NetAddr()).Base(err).AtWarning()
+	}
+	errors.LogInfo(ctx, "tunneling request to ", destination, " via ", c.server.NetAddr())
+
+	if session.TimeoutOnlyFromContext(ctx) {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+	}
+
+	sessionPolicy := c.policyManager.ForLevel(c.level)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
+
+	if destination.Network == net.Network_UDP {
+		return c.processUDP(ctx, conn, link, destination, timer, sessionPolicy)
+	}
+	return c.processTCP(ctx, conn, link, destination, timer, sessionPolicy)
+}
+
+func (c *Client) processTCP(ctx context.Context, conn *clientConn, link *transport.Link, destination net.Destination, timer *signal.ActivityTimer, sessionPolicy policy.Session) error {
+	stream, err := conn.quic.OpenStreamSync(ctx)
+	if err != nil {
+		return errors.New("failed to open stream").Base(err)
+	}
+	defer stream.CancelRead(0)
+	defer stream.Close()
+
+	if err := writeTCPRequest(stream, destination.NetAddr()); err != nil {
+		return errors.New("failed to write request").Base(err)
+	}
+
+	postRequest := func() error {
+		defer timer.SetTimeout(sessionPolicy.Timeouts.DownlinkOnly)
+		writer := buf.NewWriter(stream)
+		if conn.pacer != nil {
+			writer = &pacedWriter{Writer: writer, ctx: ctx, pacer: conn.pacer}
+		}
+		if err := buf.Copy(link.Reader, writer, buf.UpdateActivity(timer)); err != nil {
+			return errors.New("failed to transfer request payload").Base(err).AtInfo()
+		}
+		return stream.Close()
+	}
+
+	getResponse := func() error {
+		defer timer.SetTimeout(sessionPolicy.Timeouts.UplinkOnly)
+		reader := bufio.NewReader(stream)
+		if err := readTCPResponse(reader); err != nil {
+			return err
+		}
+		return buf.Copy(buf.NewReader(reader), link.Writer, buf.UpdateActivity(timer))
+	}
+
+	responseDoneAndCloseWriter := task.OnSuccess(getResponse, task.Close(link.Writer))
+	if err := task.Run(ctx, postRequest, responseDoneAndCloseWriter); err != nil {
+		return errors.New("connection ends").Base(err)
+	}
+	return nil
+}
+
+func (c *Client) processUDP(ctx context.Context, conn *clientConn, link *transport.Link, destination net.Destination, timer *signal.ActivityTimer, sessionPolicy policy.Session) error {
+	if !conn.udp {
+		return errors.New("UDP relay is not enabled on server")
+	}
+	s := conn.newUDPSession()
+	defer conn.removeUDPSession(s.id)
+
+	postRequest := func() error {
+		defer timer.SetTimeout(sessionPolicy.Timeouts.DownlinkOnly)
+		for {
+			mb, err := link.Reader.ReadMultiBuffer()
+			if err != nil {
+				return err
+			}
+			for _, b := range mb {
+				target := destination
+				if b.UDP != nil {
+					target = *b.UDP
+				}
+				err = conn.sendUDP(&udpMessage{
+					SessionID: s.id,
+					FragCount: 1,
+					Addr:      target.NetAddr(),
+					Data:      b.Bytes(),
+				})
+				if err != nil {
+					break
+				}
+			}
+			buf.ReleaseMulti(mb)
+			if err != nil {
+				return errors.New("failed to send datagram").Base(err)
+			}
+			timer.Update()
+		}
+	}
+
+	getResponse := func() error {
+		defer timer.SetTimeout(sessionPolicy.Timeouts.UplinkOnly)
+		for {
+			var m *udpMessage
+			select {
+			case m = <-s.ch:
+			case <-s.done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			dest, err := net.ParseDestination("udp:" + m.Addr)
+			if err != nil {
+				errors.LogDebugInner(ctx, err, "dropping datagram with invalid address ", m.Addr)
+				continue
+			}
+			b := buf.New()
+			b.Write(m.Data)
+			b.UDP = &dest
+			if err := link.Writer.WriteMultiBuffer(buf.MultiBuffer{b}); err != nil {
+				return err
+			}
+			timer.Update()
+		}
+	}
+
+	responseDoneAndCloseWriter := task.OnSuccess(getResponse, task.Close(link.Writer))
+	if err := task.Run(ctx, postRequest, responseDoneAndCloseWriter); err != nil {
+		return errors.New("connection ends").Base(err)
+	}
+	return nil
+}
+
+func (c *Client) getConn(ctx context.Context, dialer internet.Dialer) (*clientConn, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.conn != nil && c.conn.quic.Context().Err() == nil {
+		return c.conn, nil
+	}
+	conn, err := c.dial(ctx, dialer)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+func (c *Client) dial(ctx context.Context, dialer internet.Dialer) (*clientConn, error) {
+	rawConn, err := dialer.Dial(ctx, c.server)
+	if err != nil {
+		return nil, err
+	}
+
+	packetConn, remoteAddr, err := internet.ToPacketConn(rawConn)
+	if err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	if c.obfsPassword != nil {
+		packetConn, err = NewSalamanderConn(packetConn, c.obfsPassword)
+		if err != nil {
+			rawConn.Close()
+			return nil, err
+		}
+	}
+
+	tlsConfig := c.tlsConfig.GetTLSConfig(tls.WithDestination(c.server))
+	if len(c.tlsConfig.NextProtocol) == 0 {
+		tlsConfig.NextProtos = []string{http3.NextProtoH3}
+	}
+	quicConfig := &quic.Config{
+		InitialStreamReceiveWindow:     8 * 1024 * 1024,
+		MaxStreamReceiveWindow:         8 * 1024 * 1024,
+		InitialConnectionReceiveWindow: 20 * 1024 * 1024,
+		MaxConnectionReceiveWindow:     20 * 1024 * 1024,
+		MaxIdleTimeout:                 30 * time.Second,
+		KeepAlivePeriod:                net.QuicgoH3KeepAlivePeriod,
+		EnableDatagrams:                true,
+	}
+	quicConn, err := quic.Dial(ctx, packetConn, remoteAddr, tlsConfig, quicConfig)
+	if err != nil {
+		rawConn.Close()
+		return nil, errors.New("QUIC handshake failed").Base(err)
+	}
+	go func() {
+		<-quicConn.Context().Done()
+		rawConn.Close()
+	}()
+
+	udp, rate, err := c.authenticate(ctx, quicConn, tlsConfig)
+	if err != nil {
+		quicConn.CloseWithError(0, "")
+		return nil, err
+	}
+
+	conn := &clientConn{
+		quic:     quicConn,
+		udp:      udp,
+		pacer:    newPacer(rate),
+		sessions: make(map[uint32]*udpSession),
+	}
+	if udp {
+		go conn.receiveLoop()
+	}
+	return conn, nil
+}
+
+// authenticate performs the HTTP/3 auth request that every Hysteria2
+// connection must start with, and reports whether the server relays UDP and
+// the rate to send at by Brutal.
+func (c *Client) authenticate(ctx context.Context, quicConn *quic.Conn, tlsConfig *gotls.Config) (bool, uint64, error) {
+	h3Transport := &http3.Transport{TLSClientConfig: tlsConfig}
+	h3Conn := h3Transport.NewClientConn(quicConn)
+	req := &http.Request{
+		Method: http.MethodPost,
+		URL: &url.URL{
+			Scheme: "https",
+			Host:   authHost,
+			Path:   authPath,
+		},
+		Header: make(http.Header),
+	}
+	req.Header.Set(headerAuth, c.password)
+	req.Header.Set(headerCCRX, strconv.FormatUint(c.down, 10))
+	req.Header.Set(headerPadding, randomPadding(256, 2048))
+	resp, err := h3Conn.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		return false, 0, errors.New("auth request failed").Base(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != authStatusOK {
+		return false, 0, errors.New("authentication failed, status code: ", resp.StatusCode)
+	}
+	return resp.Header.Get(headerUDP) == "true", brutalRate(c.up, resp.Header.Get(headerCCRX)), nil
+}
+
+type udpSession struct {
+	id     uint32
+	ch     chan *udpMessage
+	done   chan struct{}
+	defrag defragger
+}
+
+type clientConn struct {
+	quic  *quic.Conn
+	udp   bool
+	pacer *pacer
+
+	access      sync.Mutex
+	sessions    map[uint32]*udpSession
+	nextSession uint32
+}
+
+func (c *clientConn) newUDPSession() *udpSession {
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.nextSession++
+	s := &udpSession{
+		id:   c.nextSession,
+		ch:   make(chan *udpMessage, 64),
+		done: make(chan struct{}),
+	}
+	c.sessions[s.id] = s
+	return s
+}
+
+func (c *clientConn) removeUDPSession(id uint32) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if s, found := c.sessions[id]; found {
+		delete(c.sessions, id)
+		close(s.done)
+	}
+}
+
+func (c *clientConn) sendUDP(m *udpMessage) error {
+	data := m.marshal()
+	if err := c.pacer.wait(c.quic.Context(), len(data)); err != nil {
+		return err
+	}
+	err := c.quic.SendDatagram(data)
+	tooLarge, ok := err.(*quic.DatagramTooLargeError)
+	if !ok {
+		return err
+	}
+	m.PacketID = dice.RollUint16()
+	frags := fragment(m, int(tooLarge.MaxDatagramPayloadSize))
+	if frags == nil {
+		return errors.New("datagram too large: ", len(m.Data))
+	}
+	for _, f := range frags {
+		data := f.marshal()
+		if err := c.pacer.wait(c.quic.Context(), len(data)); err != nil {
+			return err
+		}
+		if err := c.quic.SendDatagram(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *clientConn) receiveLoop() {
+	ctx := c.quic.Context()
+	for {
+		data, err := c.quic.ReceiveDatagram(ctx)
+		if err != nil {
+			break
+		}
+		m, err := parseUDPMessage(data)
+		if err != nil {
+			errors.LogDebugInner(ctx, err, "invalid datagram from server")
+			continue
+		}
+		c.access.Lock()
+		s, found := c.sessions[m.SessionID]
+		if found {
+			m = s.defrag.feed(m)
+		}
+		c.access.Unlock()
+		if !found || m == nil {
+			continue
+		}
+		select {
+		case s.ch <- m:
+		case <-s.done:
+		default:
+		}
+	}
+
+	c.access.Lock()
+	defer c.access.Unlock()
+	for id, s := range c.sessions {
+		delete(c.sessions, id)
+		close(s.done)
+	}
+}
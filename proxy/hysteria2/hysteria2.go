@@ -0,0 +1,3 @@
+// Package hysteria2 implements the outbound of the Hysteria 2 protocol, over
+// QUIC and optionally obfuscated by Salamander.
+package hysteria2
@@ -0,0 +1,99 @@
+package hysteria2
+
+import (
+	"crypto/rand"
+	"sync"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	salamanderSaltLen = 8
+	salamanderMinPSK  = 4
+)
+
+// SalamanderConn obfuscates every QUIC packet with the salamander scheme:
+// an 8-byte random salt followed by the payload XORed with
+// BLAKE2b-256(psk || salt).
+type SalamanderConn struct {
+	net.PacketConn
+	psk []byte
+
+	readBuf  []byte
+	readMu   sync.Mutex
+	writeBuf []byte
+	writeMu  sync.Mutex
+}
+
+func NewSalamanderConn(conn net.PacketConn, psk []byte) (*SalamanderConn, error) {
+	if len(psk) < salamanderMinPSK {
+		return nil, errors.New("salamander password must be at least ", salamanderMinPSK, " bytes")
+	}
+	return &SalamanderConn{
+		PacketConn: conn,
+		psk:        psk,
+		readBuf:    make([]byte, 2048),
+		writeBuf:   make([]byte, 2048+salamanderSaltLen),
+	}, nil
+}
+
+func (c *SalamanderConn) key(salt []byte) [blake2b.Size256]byte {
+	h, _ := blake2b.New256(nil)
+	h.Write(c.psk)
+	h.Write(salt)
+	var k [blake2b.Size256]byte
+	copy(k[:], h.Sum(nil))
+	return k
+}
+
+func (c *SalamanderConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(c.readBuf)
+		if err != nil {
+			return 0, addr, err
+		}
+		if n <= salamanderSaltLen {
+			continue
+		}
+		k := c.key(c.readBuf[:salamanderSaltLen])
+		payload := c.readBuf[salamanderSaltLen:n]
+		if len(payload) > len(p) {
+			payload = payload[:len(p)]
+		}
+		for i := range payload {
+			p[i] = payload[i] ^ k[i%blake2b.Size256]
+		}
+		return len(payload), addr, nil
+	}
+}
+
+func (c *SalamanderConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if len(p)+salamanderSaltLen > len(c.writeBuf) {
+		c.writeBuf = make([]byte, len(p)+salamanderSaltLen)
+	}
+	if _, err := rand.Read(c.writeBuf[:salamanderSaltLen]); err != nil {
+		return 0, err
+	}
+	k := c.key(c.writeBuf[:salamanderSaltLen])
+	for i := range p {
+		c.writeBuf[salamanderSaltLen+i] = p[i] ^ k[i%blake2b.Size256]
+	}
+	if _, err := c.PacketConn.WriteTo(c.writeBuf[:salamanderSaltLen+len(p)], addr); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// SetReadBuffer keeps quic-go from complaining about wrapped sockets.
+func (c *SalamanderConn) SetReadBuffer(bytes int) error {
+	if s, ok := c.PacketConn.(interface{ SetReadBuffer(int) error }); ok {
+		return s.SetReadBuffer(bytes)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package hysteria2
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/quic-go/quic-go/quicvarint"
+	"github.com/xtls/xray-core/common/dice"
+	"github.com/xtls/xray-core/common/errors"
+)
+
+const (
+	frameTypeTCPRequest = 0x401
+
+	maxAddressLength = 2048
+	maxMessageLength = 2048
+	maxPaddingLength = 4096
+
+	udpMessageHeaderSize = 4 + 2 + 1 + 1
+
+	authPath     = "/auth"
+	authHost     = "hysteria"
+	authStatusOK = 233
+
+	headerAuth    = "Hysteria-Auth"
+	headerCCRX    = "Hysteria-CC-RX"
+	headerUDP     = "Hysteria-UDP"
+	headerPadding = "Hysteria-Padding"
+)
+
+const paddingChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+func randomPadding(min, max int) string {
+	b := make([]byte, min+dice.Roll(max-min))
+	for i := range b {
+		b[i] = paddingChars[dice.Roll(len(paddingChars))]
+	}
+	return string(b)
+}
+
+// writeTCPRequest writes the header opening a proxied TCP stream:
+// [varint 0x401][varint addr len][addr][varint padding len][padding]
+func writeTCPRequest(w io.Writer, addr string) error {
+	padding := randomPadding(64, 512)
+	b := make([]byte, 0, 16+len(addr)+len(padding))
+	b = quicvarint.Append(b, frameTypeTCPRequest)
+	b = quicvarint.Append(b, uint64(len(addr)))
+	b = append(b, addr...)
+	b = quicvarint.Append(b, uint64(len(padding)))
+	b = append(b, padding...)
+	_, err := w.Write(b)
+	return err
+}
+
+// readTCPResponse reads the server reply to a TCP request:
+// [uint8 status][varint msg len][msg][varint padding len][padding]
+func readTCPResponse(r *bufio.Reader) error {
+	status, err := r.ReadByte()
+	if err != nil {
+		return errors.New("failed to read response status").Base(err)
+	}
+	msgLen, err := quicvarint.Read(r)
+	if err != nil {
+		return errors.New("failed to read response message length").Base(err)
+	}
+	if msgLen > maxMessageLength {
+		return errors.New("response message too long: ", msgLen)
+	}
+	msg := make([]byte, msgLen)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return errors.New("failed to read response message").Base(err)
+	}
+	paddingLen, err := quicvarint.Read(r)
+	if err != nil {
+		return errors.New("failed to read response padding length").Base(err)
+	}
+	if paddingLen > maxPaddingLength {
+		return errors.New("response padding too long: ", paddingLen)
+	}
+	if _, err := r.Discard(int(paddingLen)); err != nil {
+		return errors.New("failed to read response padding").Base(err)
+	}
+	if status != 0 {
+		return errors.New("server rejected request: ", string(msg))
+	}
+	return nil
+}
+
+// udpMessage is a single (possibly fragmented) datagram:
+// [uint32 session][uint16 packet][uint8 frag id][uint8 frag count][varint addr len][addr][data]
+type udpMessage struct {
+	SessionID uint32
+	PacketID  uint16
+	FragID    uint8
+	FragCount uint8
+	Addr      string
+	Data      []byte
+}
+
+func (m *udpMessage) headerSize() int {
+	return udpMessageHeaderSize + quicvarint.Len(uint64(len(m.Addr))) + len(m.Addr)
+}
+
+func (m *udpMessage) marshal() []byte {
+	b := make([]byte, udpMessageHeaderSize, m.headerSize()+len(m.Data))
+	binary.BigEndian.PutUint32(b[0:], m.SessionID)
+	binary.BigEndian.PutUint16(b[4:], m.PacketID)
+	b[6] = m.FragID
+	b[7] = m.FragCount
+	b = quicvarint.Append(b, uint64(len(m.Addr)))
+	b = append(b, m.Addr...)
+	return append(b, m.Data...)
+}
+
+func parseUDPMessage(b []byte) (*udpMessage, error) {
+	if len(b) < udpMessageHeaderSize {
+		return nil, errors.New("datagram too short")
+	}
+	m := &udpMessage{
+		SessionID: binary.BigEndian.Uint32(b[0:]),
+		PacketID:  binary.BigEndian.Uint16(b[4:]),
+		FragID:    b[6],
+		FragCount: b[7],
+	}
+	b = b[udpMessageHeaderSize:]
+	addrLen, n, err := quicvarint.Parse(b)
+	if err != nil {
+		return nil, errors.New("failed to parse address length").Base(err)
+	}
+	b = b[n:]
+	if addrLen == 0 || addrLen > maxAddressLength || uint64(len(b)) < addrLen {
+		return nil, errors.New("invalid address length: ", addrLen)
+	}
+	m.Addr = string(b[:addrLen])
+	m.Data = b[addrLen:]
+	return m, nil
+}
+
+// fragment splits m into messages whose encoded size does not exceed maxSize.
+func fragment(m *udpMessage, maxSize int) []*udpMessage {
+	if m.headerSize()+len(m.Data) <= maxSize {
+		return []*udpMessage{m}
+	}
+	chunk := maxSize - m.headerSize()
+	if chunk <= 0 {
+		return nil
+	}
+	count := (len(m.Data) + chunk - 1) / chunk
+	if count > 255 {
+		return nil
+	}
+	frags := make([]*udpMessage, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * chunk
+		if end > len(m.Data) {
+			end = len(m.Data)
+		}
+		frags = append(frags, &udpMessage{
+			SessionID: m.SessionID,
+			PacketID:  m.PacketID,
+			FragID:    uint8(i),
+			FragCount: uint8(count),
+			Addr:      m.Addr,
+			Data:      m.Data[i*chunk : end],
+		})
+	}
+	return frags
+}
+
+// defragger reassembles fragments of the most recent packet of a session.
+type defragger struct {
+	packetID uint16
+	frags    [][]byte
+	count    int
+	size     int
+}
+
+func (d *defragger) feed(m *udpMessage) *udpMessage {
+	if m.FragCount <= 1 {
+		return m
+	}
+	if m.FragID >= m.FragCount {
+		return nil
+	}
+	if d.frags == nil || m.PacketID != d.packetID || len(d.frags) != int(m.FragCount) {
+		d.packetID = m.PacketID
+		d.frags = make([][]byte, m.FragCount)
+		d.count = 0
+		d.size = 0
+	}
+	if d.frags[m.FragID] != nil {
+		return nil
+	}
+	d.frags[m.FragID] = append([]byte(nil), m.Data...)
+	d.count++
+	d.size += len(m.Data)
+	if d.count < len(d.frags) {
+		return nil
+	}
+	data := make([]byte, 0, d.size)
+	for _, f := range d.frags {
+		data = append(data, f...)
+	}
+	d.frags = nil
+	return &udpMessage{
+		SessionID: m.SessionID,
+		PacketID:  m.PacketID,
+		FragCount: 1,
+		Addr:      m.Addr,
+		Data:      data,
+	}
+}
@@ -11,22 +11,28 @@ import (
 	"crypto/rand"
 	"io"
 	"math/big"
+	"reflect"
 	"runtime"
 	"strconv"
 	"time"
+	"unsafe"
 
 	"github.com/pires/go-proxyproto"
 	"github.com/xtls/xray-core/app/dispatcher"
+	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/platform"
 	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal"
 	"github.com/xtls/xray-core/features/routing"
 	"github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/proxy/fallback"
 	"github.com/xtls/xray-core/transport"
 	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/ebpf"
 	"github.com/xtls/xray-core/transport/internet/reality"
 	"github.com/xtls/xray-core/transport/internet/stat"
 	"github.com/xtls/xray-core/transport/internet/tls"
@@ -47,6 +53,24 @@ var (
 	}
 )
 
+// useSockmap splices the TCP connections with sockmap instead of splice(2)
+// when they are copied as is, which keeps the data in the kernel as well as
+// out of the pipes. It is off by default.
+var useSockmap bool
+
+func init() {
+	const defaultFlagValue = "NOT_DEFINED_AT_ALL"
+	value := platform.NewEnvFlag(platform.UseSockmap).GetValue(func() string { return defaultFlagValue })
+	switch value {
+	case "enable":
+		if err := ebpf.Supported(); err != nil {
+			errors.LogWarningInner(context.Background(), err, "sockmap is not supported, splice is used instead")
+		} else {
+			useSockmap = true
+		}
+	}
+}
+
 const (
 	TlsHandshakeTypeClientHello byte = 0x01
 	TlsHandshakeTypeServerHello byte = 0x02
@@ -89,6 +113,21 @@ type UserManager interface {
 	GetUsersCount(context.Context) int64
 }
 
+// FallbackManager is the interface for Inbounds whose fallbacks can be replaced.
+type FallbackManager interface {
+	// SetFallbacks replaces the fallbacks, with none if fallbacks is empty.
+	SetFallbacks(ctx context.Context, fallbacks []*fallback.Fallback) error
+}
+
+// OutboundSelector is the interface for Outbounds forwarding to the selected
+// one of their candidate outbounds, which can be switched.
+type OutboundSelector interface {
+	// Select selects the candidate of tag.
+	Select(ctx context.Context, tag string) error
+	// Selected returns the selected candidate and all the candidates.
+	Selected() (string, []string)
+}
+
 type GetInbound interface {
 	GetInbound() Inbound
 }
@@ -101,6 +140,7 @@ type GetOutbound interface {
 // It is used by XTLS to determine if switch to raw copy mode, It is used by Vision to calculate padding
 type TrafficState struct {
 	UserUUID               []byte
+	Padding                *Padding // or nil
 	NumberOfPacketToFilter int
 	EnableXtls             bool
 	IsTLS12orAbove         bool
@@ -169,6 +209,58 @@ func NewTrafficState(userUUID []byte) *TrafficState {
 	}
 }
 
+// Padding is how Vision pads the writes of a session, which the receiver
+// unpads whatever it is.
+type Padding struct {
+	// LongMin and LongMax are the range of sizes to pad the handshake to.
+	LongMin int32
+	LongMax int32
+	// ShortMax is the most padding of the other writes.
+	ShortMax int32
+	// ContinueWrites is the writes to keep padding, if the traffic is not
+	// TLS, after the filter ends.
+	ContinueWrites int
+	// MaxDelay is the most a padded write is delayed by.
+	MaxDelay time.Duration
+}
+
+// paddingShape is the sizes a session pads to, drawn from its Padding.
+type paddingShape struct {
+	padding  *Padding
+	longMin  int32
+	longMax  int32
+	shortMax int32
+}
+
+// defaultPaddingShape is the padding of Vision without a Padding.
+var defaultPaddingShape = paddingShape{longMin: 900, longMax: 1400, shortMax: 256}
+
+func randInt32(n int32) int32 {
+	if n <= 0 {
+		return 0
+	}
+	l, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0
+	}
+	return int32(l.Int64())
+}
+
+func newPaddingShape(padding *Padding) paddingShape {
+	if padding == nil {
+		return defaultPaddingShape
+	}
+	// The sizes are drawn from at least half of each range, so that sessions
+	// differ in sizes. They are drawn once, as only the first writes of a
+	// session are padded.
+	s := paddingShape{padding: padding}
+	width := padding.LongMax - padding.LongMin
+	s.longMin = padding.LongMin + randInt32(width/2+1)
+	s.longMax = s.longMin + width/2 + randInt32(padding.LongMax-s.longMin-width/2+1)
+	s.shortMax = padding.ShortMax/2 + randInt32(padding.ShortMax-padding.ShortMax/2+1)
+	return s
+}
+
 // VisionReader is used to read xtls vision protocol
 // Note Vision probably only make sense as the inner most layer of reader, since it need assess traffic state from origin proxy traffic
 type VisionReader struct {
@@ -244,6 +336,8 @@ type VisionWriter struct {
 	ctx               context.Context
 	writeOnceUserUUID []byte
 	isUplink          bool
+	shape             paddingShape
+	writes            int
 }
 
 func NewVisionWriter(writer buf.Writer, state *TrafficState, isUplink bool, context context.Context) *VisionWriter {
@@ -255,6 +349,7 @@ func NewVisionWriter(writer buf.Writer, state *TrafficState, isUplink bool, cont
 		ctx:               context,
 		writeOnceUserUUID: w,
 		isUplink:          isUplink,
+		shape:             newPaddingShape(state.Padding),
 	}
 }
 
@@ -272,8 +367,16 @@ func (w *VisionWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 		switchToDirectCopy = &w.trafficState.Inbound.DownlinkWriterDirectCopy
 	}
 	if *isPadding {
+		shape := &w.shape
+		if p := shape.padding; p != nil && p.MaxDelay > 0 {
+			time.Sleep(time.Duration(randInt32(int32(p.MaxDelay/time.Millisecond))) * time.Millisecond)
+		}
+		continueWrites := 0
+		if shape.padding != nil {
+			continueWrites = shape.padding.ContinueWrites
+		}
 		if len(mb) == 1 && mb[0] == nil {
-			mb[0] = XtlsPadding(nil, CommandPaddingContinue, &w.writeOnceUserUUID, true, w.ctx) // we do a long padding to hide vless header
+			mb[0] = xtlsPadding(nil, CommandPaddingContinue, &w.writeOnceUserUUID, true, shape, w.ctx) // we do a long padding to hide vless header
 			return w.Writer.WriteMultiBuffer(mb)
 		}
 		mb = ReshapeMultiBuffer(w.ctx, mb)
@@ -290,13 +393,13 @@ func (w *VisionWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 						command = CommandPaddingDirect
 					}
 				}
-				mb[i] = XtlsPadding(b, command, &w.writeOnceUserUUID, true, w.ctx)
+				mb[i] = xtlsPadding(b, command, &w.writeOnceUserUUID, true, shape, w.ctx)
 				*isPadding = false // padding going to end
 				longPadding = false
 				continue
-			} else if !w.trafficState.IsTLS12orAbove && w.trafficState.NumberOfPacketToFilter <= 1 { // For compatibility with earlier vision receiver, we finish padding 1 packet early
+			} else if !w.trafficState.IsTLS12orAbove && w.trafficState.NumberOfPacketToFilter <= 1 && w.writes >= continueWrites { // For compatibility with earlier vision receiver, we finish padding 1 packet early
 				*isPadding = false
-				mb[i] = XtlsPadding(b, CommandPaddingEnd, &w.writeOnceUserUUID, longPadding, w.ctx)
+				mb[i] = xtlsPadding(b, CommandPaddingEnd, &w.writeOnceUserUUID, longPadding, shape, w.ctx)
 				break
 			}
 			var command byte = CommandPaddingContinue
@@ -306,7 +409,8 @@ func (w *VisionWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 					command = CommandPaddingDirect
 				}
 			}
-			mb[i] = XtlsPadding(b, command, &w.writeOnceUserUUID, longPadding, w.ctx)
+			mb[i] = xtlsPadding(b, command, &w.writeOnceUserUUID, longPadding, shape, w.ctx)
+			w.writes++
 		}
 	}
 	return w.Writer.WriteMultiBuffer(mb)
@@ -349,23 +453,19 @@ func ReshapeMultiBuffer(ctx context.Context, buffer buf.MultiBuffer) buf.MultiBu
 
 // XtlsPadding add padding to eliminate length signature during tls handshake
 func XtlsPadding(b *buf.Buffer, command byte, userUUID *[]byte, longPadding bool, ctx context.Context) *buf.Buffer {
+	return xtlsPadding(b, command, userUUID, longPadding, &defaultPaddingShape, ctx)
+}
+
+func xtlsPadding(b *buf.Buffer, command byte, userUUID *[]byte, longPadding bool, shape *paddingShape, ctx context.Context) *buf.Buffer {
 	var contentLen int32 = 0
 	var paddingLen int32 = 0
 	if b != nil {
 		contentLen = b.Len()
 	}
-	if contentLen < 900 && longPadding {
-		l, err := rand.Int(rand.Reader, big.NewInt(500))
-		if err != nil {
-			errors.LogDebugInner(ctx, err, "failed to generate padding")
-		}
-		paddingLen = int32(l.Int64()) + 900 - contentLen
+	if contentLen < shape.longMin && longPadding {
+		paddingLen = randInt32(shape.longMax-shape.longMin) + shape.longMin - contentLen
 	} else {
-		l, err := rand.Int(rand.Reader, big.NewInt(256))
-		if err != nil {
-			errors.LogDebugInner(ctx, err, "failed to generate padding")
-		}
-		paddingLen = int32(l.Int64())
+		paddingLen = randInt32(shape.shortMax)
 	}
 	if paddingLen > buf.Size-21-contentLen {
 		paddingLen = buf.Size - 21 - contentLen
@@ -543,6 +643,9 @@ func UnwrapRawConn(conn net.Conn) (net.Conn, stats.Counter, stats.Counter) {
 		} else if realityUConn, ok := conn.(*reality.UConn); ok {
 			conn = realityUConn.NetConn()
 		}
+		if pc, ok := conn.(*reality.PeekedConn); ok {
+			conn = pc.Conn // the peeked record has been consumed by the handshake
+		}
 		if pc, ok := conn.(*proxyproto.Conn); ok {
 			conn = pc.Raw()
 			// 8192 > 4096, there is no need to process pc's bufReader
@@ -558,6 +661,7 @@ func UnwrapRawConn(conn net.Conn) (net.Conn, stats.Counter, stats.Counter) {
 // - If caller don't want to turn on splice, do not pass in both reader conn and writer conn
 // - writer are from *transport.Link
 func CopyRawConnIfExist(ctx context.Context, readerConn net.Conn, writerConn net.Conn, writer buf.Writer, timer *signal.ActivityTimer, inTimer *signal.ActivityTimer) error {
+	spliceCounter := spliceCounter(readerConn, writerConn)
 	readerConn, readCounter, _ := UnwrapRawConn(readerConn)
 	writerConn, _, writeCounter := UnwrapRawConn(writerConn)
 	reader := buf.NewReader(readerConn)
@@ -600,7 +704,7 @@ func CopyRawConnIfExist(ctx context.Context, readerConn net.Conn, writerConn net
 			if inTimer != nil {
 				inTimer.SetTimeout(8 * time.Hour)
 			}
-			w, err := tc.ReadFrom(readerConn)
+			w, err := spliceCopy(ctx, tc, readerConn)
 			if readCounter != nil {
 				readCounter.Add(w) // outbound stats
 			}
@@ -610,6 +714,9 @@ func CopyRawConnIfExist(ctx context.Context, readerConn net.Conn, writerConn net
 			if statWriter != nil {
 				statWriter.Counter.Add(w) // user stats
 			}
+			if spliceCounter != nil {
+				spliceCounter.Add(w)
+			}
 			if err != nil && errors.Cause(err) != io.EOF {
 				return err
 			}
@@ -631,6 +738,102 @@ func CopyRawConnIfExist(ctx context.Context, readerConn net.Conn, writerConn net
 	}
 }
 
+// spliceCopy copies from readerConn to tc in the kernel.
+func spliceCopy(ctx context.Context, tc *net.TCPConn, readerConn net.Conn) (int64, error) {
+	if rc, ok := readerConn.(*net.TCPConn); ok && useSockmap {
+		errors.LogInfo(ctx, "CopyRawConn sockmap")
+		return ebpf.Splice(rc, tc)
+	}
+	return tc.ReadFrom(readerConn)
+}
+
+// spliceCounter returns the counter of spliced traffic of the first of conns
+// that has one.
+func spliceCounter(conns ...net.Conn) stats.Counter {
+	for _, conn := range conns {
+		if statConn, ok := conn.(*stat.CounterConnection); ok && statConn.SpliceCounter != nil {
+			return statConn.SpliceCounter
+		}
+	}
+	return nil
+}
+
+// IsRawTCPConn reports whether conn is a TCP connection that nothing but
+// stats wrap, whose data is spliceable as is.
+func IsRawTCPConn(conn net.Conn) bool {
+	if statConn, ok := conn.(*stat.CounterConnection); ok {
+		conn = statConn.Connection
+	}
+	_, ok := conn.(*net.TCPConn)
+	return ok
+}
+
+// CopyUplinkOrHandOver copies the uplink from conn to writer, until the
+// outbound is ready to splice it. It then hands conn over and waits for the
+// outbound to finish the uplink.
+func CopyUplinkOrHandOver(ctx context.Context, conn net.Conn, writer buf.Writer, timer signal.ActivityUpdater, handover *session.SpliceHandover) error {
+	reader := buf.NewReader(conn)
+	for {
+		select {
+		case <-handover.Ready:
+			handover.Conn = conn
+			handover.Writer = writer
+			close(handover.Handed)
+			common.Close(writer)
+			select {
+			case <-handover.Done:
+			case <-ctx.Done():
+			}
+			return nil
+		default:
+		}
+		buffer, err := reader.ReadMultiBuffer()
+		if !buffer.IsEmpty() {
+			timer.Update()
+			if werr := writer.WriteMultiBuffer(buffer); werr != nil {
+				return werr
+			}
+		}
+		if err != nil {
+			if errors.Cause(err) == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// SpliceUplink splices the rest of the uplink from the connection an inbound
+// handed over into conn, a raw TCP connection.
+func SpliceUplink(ctx context.Context, handover *session.SpliceHandover, conn net.Conn, timer *signal.ActivityTimer) error {
+	errors.LogInfo(ctx, "CopyRawConn splice uplink")
+	spliceCounter := spliceCounter(conn, handover.Conn)
+	readerConn, readCounter, _ := UnwrapRawConn(handover.Conn)
+	writerConn, _, writeCounter := UnwrapRawConn(conn)
+	statWriter, _ := handover.Writer.(*dispatcher.SizeStatWriter)
+	timer.SetTimeout(8 * time.Hour) // prevent leak, just in case
+	if inbound := session.InboundFromContext(ctx); inbound != nil && inbound.Timer != nil {
+		inbound.Timer.SetTimeout(8 * time.Hour)
+	}
+	w, err := spliceCopy(ctx, writerConn.(*net.TCPConn), readerConn)
+	if readCounter != nil {
+		readCounter.Add(w) // inbound stats
+	}
+	if writeCounter != nil {
+		writeCounter.Add(w) // outbound stats
+	}
+	if statWriter != nil {
+		statWriter.Counter.Add(w) // user stats
+	}
+	if spliceCounter != nil {
+		spliceCounter.Add(w)
+	}
+	if err != nil && errors.Cause(err) != io.EOF {
+		return err
+	}
+	return nil
+}
+
 func readV(ctx context.Context, reader buf.Reader, writer buf.Writer, timer signal.ActivityUpdater, readCounter stats.Counter) error {
 	errors.LogInfo(ctx, "CopyRawConn readv")
 	if err := buf.Copy(reader, writer, buf.UpdateActivity(timer), buf.AddToStatCounter(readCounter)); err != nil {
@@ -638,3 +841,119 @@ func readV(ctx context.Context, reader buf.Reader, writer buf.Writer, timer sign
 	}
 	return nil
 }
+
+// XtlsInput returns the buffers of conn, a TLS or REALITY conn, of what it has
+// read but not returned, which XTLS Vision copies before the raw conn.
+func XtlsInput(conn net.Conn) (*bytes.Reader, *bytes.Buffer, error) {
+	var t reflect.Type
+	var p unsafe.Pointer
+	switch c := conn.(type) {
+	case *tls.Conn:
+		t, p = reflect.TypeOf(c.Conn).Elem(), unsafe.Pointer(c.Conn)
+	case *tls.UConn:
+		t, p = reflect.TypeOf(c.Conn).Elem(), unsafe.Pointer(c.Conn)
+	case *reality.Conn:
+		t, p = reflect.TypeOf(c.Conn).Elem(), unsafe.Pointer(c.Conn)
+	case *reality.UConn:
+		t, p = reflect.TypeOf(c.Conn).Elem(), unsafe.Pointer(c.Conn)
+	default:
+		return nil, nil, errors.New("XTLS only supports TLS and REALITY directly for now.").AtWarning()
+	}
+	i, _ := t.FieldByName("input")
+	r, _ := t.FieldByName("rawInput")
+	return (*bytes.Reader)(unsafe.Add(p, i.Offset)), (*bytes.Buffer)(unsafe.Add(p, r.Offset)), nil
+}
+
+// XtlsRead filter and read xtls protocol
+func XtlsRead(reader buf.Reader, writer buf.Writer, timer *signal.ActivityTimer, conn net.Conn, input *bytes.Reader, rawInput *bytes.Buffer, trafficState *TrafficState, ob *session.Outbound, isUplink bool, ctx context.Context) error {
+	err := func() error {
+		for {
+			if isUplink && trafficState.Inbound.UplinkReaderDirectCopy || !isUplink && trafficState.Outbound.DownlinkReaderDirectCopy {
+				var writerConn net.Conn
+				var inTimer *signal.ActivityTimer
+				if inbound := session.InboundFromContext(ctx); inbound != nil && inbound.Conn != nil {
+					writerConn = inbound.Conn
+					inTimer = inbound.Timer
+					if isUplink && inbound.CanSpliceCopy == 2 {
+						inbound.CanSpliceCopy = 1
+					}
+					if !isUplink && ob != nil && ob.CanSpliceCopy == 2 { // ob need to be passed in due to context can change
+						ob.CanSpliceCopy = 1
+					}
+				}
+				return CopyRawConnIfExist(ctx, conn, writerConn, writer, timer, inTimer)
+			}
+			buffer, err := reader.ReadMultiBuffer()
+			if !buffer.IsEmpty() {
+				timer.Update()
+				if isUplink && trafficState.Inbound.UplinkReaderDirectCopy || !isUplink && trafficState.Outbound.DownlinkReaderDirectCopy {
+					// XTLS Vision processes struct TLS Conn's input and rawInput
+					if inputBuffer, err := buf.ReadFrom(input); err == nil {
+						if !inputBuffer.IsEmpty() {
+							buffer, _ = buf.MergeMulti(buffer, inputBuffer)
+						}
+					}
+					if rawInputBuffer, err := buf.ReadFrom(rawInput); err == nil {
+						if !rawInputBuffer.IsEmpty() {
+							buffer, _ = buf.MergeMulti(buffer, rawInputBuffer)
+						}
+					}
+				}
+				if werr := writer.WriteMultiBuffer(buffer); werr != nil {
+					return werr
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}()
+	if err != nil && errors.Cause(err) != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// XtlsWrite filter and write xtls protocol
+func XtlsWrite(reader buf.Reader, writer buf.Writer, timer signal.ActivityUpdater, conn net.Conn, trafficState *TrafficState, ob *session.Outbound, isUplink bool, ctx context.Context) error {
+	err := func() error {
+		var ct stats.Counter
+		for {
+			buffer, err := reader.ReadMultiBuffer()
+			if isUplink && trafficState.Outbound.UplinkWriterDirectCopy || !isUplink && trafficState.Inbound.DownlinkWriterDirectCopy {
+				if inbound := session.InboundFromContext(ctx); inbound != nil {
+					if !isUplink && inbound.CanSpliceCopy == 2 {
+						inbound.CanSpliceCopy = 1
+					}
+					if isUplink && ob != nil && ob.CanSpliceCopy == 2 {
+						ob.CanSpliceCopy = 1
+					}
+				}
+				rawConn, _, writerCounter := UnwrapRawConn(conn)
+				writer = buf.NewWriter(rawConn)
+				ct = writerCounter
+				if isUplink {
+					trafficState.Outbound.UplinkWriterDirectCopy = false
+				} else {
+					trafficState.Inbound.DownlinkWriterDirectCopy = false
+				}
+			}
+			if !buffer.IsEmpty() {
+				if ct != nil {
+					ct.Add(int64(buffer.Len()))
+				}
+				timer.Update()
+				if werr := writer.WriteMultiBuffer(buffer); werr != nil {
+					return werr
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}()
+	if err != nil && errors.Cause(err) != io.EOF {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// Package selector is an outbound forwarding to the selected one of its
+// candidate outbounds, switchable through the API.
+package selector
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		s := new(Selector)
+		err := core.RequireFeatures(ctx, func(ohm outbound.Manager) error {
+			return s.init(config.(*Config), ohm)
+		})
+		return s, err
+	}))
+}
+
+// Selector is an outbound handler forwarding to the selected candidate.
+type Selector struct {
+	ohm        outbound.Manager
+	candidates []string
+
+	access   sync.RWMutex
+	selected string
+}
+
+func (s *Selector) init(config *Config, ohm outbound.Manager) error {
+	if len(config.Candidates) == 0 {
+		return errors.New("selector without candidates")
+	}
+	s.ohm = ohm
+	s.candidates = config.Candidates
+	s.selected = config.Candidates[0]
+	if config.Selected != "" {
+		if !slices.Contains(s.candidates, config.Selected) {
+			return errors.New("selected outbound ", config.Selected, " is not a candidate")
+		}
+		s.selected = config.Selected
+	}
+	return nil
+}
+
+// Process implements proxy.Outbound.
+func (s *Selector) Process(ctx context.Context, link *transport.Link, _ internet.Dialer) error {
+	tag, _ := s.Selected()
+	outbounds := session.OutboundsFromContext(ctx)
+	ob := outbounds[len(outbounds)-1]
+	if tag == ob.Tag {
+		return errors.New("selector ", tag, " selecting itself")
+	}
+	ob.Name = "selector"
+
+	handler := s.ohm.GetHandler(tag)
+	if handler == nil {
+		return errors.New("selected outbound ", tag, " not found")
+	}
+	errors.LogInfo(ctx, ob.Tag, " forwarding to ", tag)
+	handler.Dispatch(ctx, link)
+	return nil
+}
+
+// Select implements proxy.OutboundSelector.
+func (s *Selector) Select(ctx context.Context, tag string) error {
+	if !slices.Contains(s.candidates, tag) {
+		return errors.New(tag, " is not a candidate")
+	}
+	s.access.Lock()
+	s.selected = tag
+	s.access.Unlock()
+	errors.LogInfo(ctx, "selected ", tag)
+	return nil
+}
+
+// Selected implements proxy.OutboundSelector.
+func (s *Selector) Selected() (string, []string) {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	return s.selected, s.candidates
+}
@@ -4,17 +4,20 @@ import (
 	"context"
 	"time"
 
+	"github.com/sagernet/sing/common/uot"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/net/cnc"
 	"github.com/xtls/xray-core/common/protocol"
-	"github.com/xtls/xray-core/common/retry"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/common/singbridge"
 	"github.com/xtls/xray-core/common/task"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/proxy"
 	"github.com/xtls/xray-core/transport"
 	"github.com/xtls/xray-core/transport/internet"
 	"github.com/xtls/xray-core/transport/internet/stat"
@@ -24,6 +27,7 @@ import (
 type Client struct {
 	serverPicker  protocol.ServerPicker
 	policyManager policy.Manager
+	uotClient     *uot.Client
 }
 
 // NewClient create a new Shadowsocks client.
@@ -45,6 +49,9 @@ func NewClient(ctx context.Context, config *ClientConfig) (*Client, error) {
 		serverPicker:  protocol.NewRoundRobinServerPicker(serverList),
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
 	}
+	if config.UdpOverTcp {
+		client.uotClient = &uot.Client{Version: uint8(config.UdpOverTcpVersion)}
+	}
 	return client, nil
 }
 
@@ -59,11 +66,15 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 	ob.CanSpliceCopy = 3
 	destination := ob.Target
 	network := destination.Network
+	udpOverTCP := network == net.Network_UDP && c.uotClient != nil
+	if udpOverTCP {
+		network = net.Network_TCP
+	}
 
 	var server *protocol.ServerSpec
 	var conn stat.Connection
 
-	err := retry.ExponentialBackoff(5, 100).On(func() error {
+	err := proxy.DialRetry(ctx, 5, 100).On(func() error {
 		server = c.serverPicker.PickServer()
 		dest := server.Destination()
 		dest.Network = network
@@ -87,7 +98,12 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 		Address: destination.Address,
 		Port:    destination.Port,
 	}
-	if destination.Network == net.Network_TCP {
+	if udpOverTCP {
+		request.Command = protocol.RequestCommandTCP
+		uotDestination := uot.RequestDestination(c.uotClient.Version)
+		request.Address = net.DomainAddress(uotDestination.Fqdn)
+		request.Port = net.Port(uotDestination.Port)
+	} else if destination.Network == net.Network_TCP {
 		request.Command = protocol.RequestCommandTCP
 	} else {
 		request.Command = protocol.RequestCommandUDP
@@ -119,6 +135,59 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 		ctx = newCtx
 	}
 
+	if udpOverTCP {
+		requestDone := func() error {
+			defer timer.SetTimeout(sessionPolicy.Timeouts.DownlinkOnly)
+			bufferedWriter := buf.NewBufferedWriter(buf.NewWriter(conn))
+			bodyWriter, err := WriteTCPRequest(request, bufferedWriter)
+			if err != nil {
+				return errors.New("failed to write request").Base(err)
+			}
+			uConn, err := c.uotClient.DialEarlyConn(cnc.NewConnection(cnc.ConnectionInputMulti(bodyWriter)), false, singbridge.ToSocksaddr(destination))
+			if err != nil {
+				return err
+			}
+			writer := &singbridge.PacketWriter{PacketWriter: uConn, Dest: destination}
+
+			if err = buf.CopyOnceTimeout(link.Reader, writer, time.Millisecond*100); err != nil && err != buf.ErrNotTimeoutReader && err != buf.ErrReadTimeout {
+				return errors.New("failed to write A request payload").Base(err).AtWarning()
+			}
+
+			if err := bufferedWriter.SetBuffered(false); err != nil {
+				return err
+			}
+
+			if err := buf.Copy(link.Reader, writer, buf.UpdateActivity(timer)); err != nil {
+				return errors.New("failed to transport all UDP request").Base(err)
+			}
+			return nil
+		}
+
+		responseDone := func() error {
+			defer timer.SetTimeout(sessionPolicy.Timeouts.UplinkOnly)
+
+			responseReader, err := ReadTCPResponse(user, conn)
+			if err != nil {
+				return err
+			}
+			// Replies always carry their source, whatever the version.
+			uConn := uot.NewConn(cnc.NewConnection(cnc.ConnectionOutputMulti(responseReader)), uot.Request{})
+			reader := &singbridge.PacketReader{PacketReader: uConn}
+
+			if err := buf.Copy(reader, link.Writer, buf.UpdateActivity(timer)); err != nil {
+				return errors.New("failed to transport all UDP response").Base(err)
+			}
+			return nil
+		}
+
+		responseDoneAndCloseWriter := task.OnSuccess(responseDone, task.Close(link.Writer))
+		if err := task.Run(ctx, requestDone, responseDoneAndCloseWriter); err != nil {
+			return errors.New("connection ends").Base(err)
+		}
+
+		return nil
+	}
+
 	if request.Command == protocol.RequestCommandTCP {
 		requestDone := func() error {
 			defer timer.SetTimeout(sessionPolicy.Timeouts.DownlinkOnly)
@@ -83,6 +83,9 @@ func (s *Server) Network() []net.Network {
 	if len(list) == 0 {
 		list = append(list, net.Network_TCP)
 	}
+	if net.HasNetwork(list, net.Network_TCP) {
+		list = append(list[:len(list):len(list)], net.Network_UNIX)
+	}
 	return list
 }
 
@@ -92,7 +95,7 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 	inbound.CanSpliceCopy = 3
 
 	switch network {
-	case net.Network_TCP:
+	case net.Network_TCP, net.Network_UNIX:
 		return s.handleConnection(ctx, conn, dispatcher)
 	case net.Network_UDP:
 		return s.handleUDPPayload(ctx, conn, dispatcher)
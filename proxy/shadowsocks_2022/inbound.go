@@ -44,6 +44,9 @@ func NewServer(ctx context.Context, config *ServerConfig) (*Inbound, error) {
 			net.Network_UDP,
 		}
 	}
+	if net.HasNetwork(networks, net.Network_TCP) {
+		networks = append(networks[:len(networks):len(networks)], net.Network_UNIX)
+	}
 	inbound := &Inbound{
 		networks: networks,
 		email:    config.Email,
@@ -76,7 +79,7 @@ func (i *Inbound) Process(ctx context.Context, network net.Network, connection s
 
 	ctx = session.ContextWithDispatcher(ctx, dispatcher)
 
-	if network == net.Network_TCP {
+	if network == net.Network_TCP || network == net.Network_UNIX {
 		return singbridge.ReturnError(i.service.NewConnection(ctx, connection, metadata))
 	} else {
 		reader := buf.NewReader(connection)
@@ -38,7 +38,7 @@ type MultiUserInbound struct {
 	sync.Mutex
 	networks []net.Network
 	users    []*protocol.MemoryUser
-	service  *shadowaead_2022.MultiService[int]
+	service  *shadowaead_2022.MultiService[*protocol.MemoryUser]
 }
 
 func NewMultiServer(ctx context.Context, config *MultiUserServerConfig) (*MultiUserInbound, error) {
@@ -49,6 +49,9 @@ func NewMultiServer(ctx context.Context, config *MultiUserServerConfig) (*MultiU
 			net.Network_UDP,
 		}
 	}
+	if net.HasNetwork(networks, net.Network_TCP) {
+		networks = append(networks[:len(networks):len(networks)], net.Network_UNIX)
+	}
 	memUsers := []*protocol.MemoryUser{}
 	for i, user := range config.Users {
 		if user.Email == "" {
@@ -73,22 +76,32 @@ func NewMultiServer(ctx context.Context, config *MultiUserServerConfig) (*MultiU
 	if err != nil {
 		return nil, errors.New("parse config").Base(err)
 	}
-	service, err := shadowaead_2022.NewMultiService[int](config.Method, psk, 500, inbound, nil)
+	service, err := shadowaead_2022.NewMultiService[*protocol.MemoryUser](config.Method, psk, 500, inbound, nil)
 	if err != nil {
 		return nil, errors.New("create service").Base(err)
 	}
-	err = service.UpdateUsersWithPasswords(
-		C.MapIndexed(memUsers, func(index int, it *protocol.MemoryUser) int { return index }),
-		C.Map(memUsers, func(it *protocol.MemoryUser) string { return it.Account.(*MemoryAccount).Key }),
-	)
-	if err != nil {
+	inbound.service = service
+	if err := inbound.syncUsers(memUsers); err != nil {
 		return nil, errors.New("create service").Base(err)
 	}
-
-	inbound.service = service
 	return inbound, nil
 }
 
+// syncUsers pushes the user list and their identity PSKs to the service.
+// The user pointers themselves are the service's user keys, so identities
+// stay stable when other users are added or removed.
+func (i *MultiUserInbound) syncUsers(users []*protocol.MemoryUser) error {
+	err := i.service.UpdateUsersWithPasswords(
+		users,
+		C.Map(users, func(it *protocol.MemoryUser) string { return it.Account.(*MemoryAccount).Key }),
+	)
+	if err != nil {
+		return err
+	}
+	i.users = users
+	return nil
+}
+
 // AddUser implements proxy.UserManager.AddUser().
 func (i *MultiUserInbound) AddUser(ctx context.Context, u *protocol.MemoryUser) error {
 	i.Lock()
@@ -101,14 +114,19 @@ func (i *MultiUserInbound) AddUser(ctx context.Context, u *protocol.MemoryUser)
 			}
 		}
 	}
-	i.users = append(i.users, u)
+	if _, ok := u.Account.(*MemoryAccount); !ok {
+		return errors.New("User ", u.Email, " is not a shadowsocks 2022 account.")
+	}
+
+	users := make([]*protocol.MemoryUser, len(i.users), len(i.users)+1)
+	copy(users, i.users)
+	users = append(users, u)
 
 	// sync to multi service
 	// Considering implements shadowsocks2022 in xray-core may have better performance.
-	i.service.UpdateUsersWithPasswords(
-		C.MapIndexed(i.users, func(index int, it *protocol.MemoryUser) int { return index }),
-		C.Map(i.users, func(it *protocol.MemoryUser) string { return it.Account.(*MemoryAccount).Key }),
-	)
+	if err := i.syncUsers(users); err != nil {
+		return errors.New("failed to add user ", u.Email).Base(err)
+	}
 
 	return nil
 }
@@ -134,18 +152,15 @@ func (i *MultiUserInbound) RemoveUser(ctx context.Context, email string) error {
 		return errors.New("User ", email, " not found.")
 	}
 
-	ulen := len(i.users)
-
-	i.users[idx] = i.users[ulen-1]
-	i.users[ulen-1] = nil
-	i.users = i.users[:ulen-1]
+	users := make([]*protocol.MemoryUser, 0, len(i.users)-1)
+	users = append(users, i.users[:idx]...)
+	users = append(users, i.users[idx+1:]...)
 
 	// sync to multi service
 	// Considering implements shadowsocks2022 in xray-core may have better performance.
-	i.service.UpdateUsersWithPasswords(
-		C.MapIndexed(i.users, func(index int, it *protocol.MemoryUser) int { return index }),
-		C.Map(i.users, func(it *protocol.MemoryUser) string { return it.Account.(*MemoryAccount).Key }),
-	)
+	if err := i.syncUsers(users); err != nil {
+		return errors.New("failed to remove user ", email).Base(err)
+	}
 
 	return nil
 }
@@ -199,7 +214,7 @@ func (i *MultiUserInbound) Process(ctx context.Context, network net.Network, con
 
 	ctx = session.ContextWithDispatcher(ctx, dispatcher)
 
-	if network == net.Network_TCP {
+	if network == net.Network_TCP || network == net.Network_UNIX {
 		return singbridge.ReturnError(i.service.NewConnection(ctx, connection, metadata))
 	} else {
 		reader := buf.NewReader(connection)
@@ -226,8 +241,7 @@ func (i *MultiUserInbound) Process(ctx context.Context, network net.Network, con
 
 func (i *MultiUserInbound) NewConnection(ctx context.Context, conn net.Conn, metadata M.Metadata) error {
 	inbound := session.InboundFromContext(ctx)
-	userInt, _ := A.UserFromContext[int](ctx)
-	user := i.users[userInt]
+	user, _ := A.UserFromContext[*protocol.MemoryUser](ctx)
 	inbound.User = user
 	ctx = log.ContextWithAccessMessage(ctx, &log.AccessMessage{
 		From:   metadata.Source,
@@ -251,8 +265,7 @@ func (i *MultiUserInbound) NewConnection(ctx context.Context, conn net.Conn, met
 
 func (i *MultiUserInbound) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata M.Metadata) error {
 	inbound := session.InboundFromContext(ctx)
-	userInt, _ := A.UserFromContext[int](ctx)
-	user := i.users[userInt]
+	user, _ := A.UserFromContext[*protocol.MemoryUser](ctx)
 	inbound.User = user
 	ctx = log.ContextWithAccessMessage(ctx, &log.AccessMessage{
 		From:   metadata.Source,
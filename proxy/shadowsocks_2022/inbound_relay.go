@@ -46,6 +46,9 @@ func NewRelayServer(ctx context.Context, config *RelayServerConfig) (*RelayInbou
 			net.Network_UDP,
 		}
 	}
+	if net.HasNetwork(networks, net.Network_TCP) {
+		networks = append(networks[:len(networks):len(networks)], net.Network_UNIX)
+	}
 	inbound := &RelayInbound{
 		networks:     networks,
 		destinations: config.Destinations,
@@ -97,7 +100,7 @@ func (i *RelayInbound) Process(ctx context.Context, network net.Network, connect
 
 	ctx = session.ContextWithDispatcher(ctx, dispatcher)
 
-	if network == net.Network_TCP {
+	if network == net.Network_TCP || network == net.Network_UNIX {
 		return singbridge.ReturnError(i.service.NewConnection(ctx, connection, metadata))
 	} else {
 		reader := buf.NewReader(connection)
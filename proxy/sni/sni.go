@@ -0,0 +1,229 @@
+// Package sni is an inbound routing TLS streams by the server names of their
+// ClientHellos, as they are, to outbounds or backends, so that several TLS
+// services may be fronted on a port without being terminated.
+package sni
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/protocol/tls"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+// maxHello is the size of the first TLS record read at most, for the
+// ClientHello in it.
+const maxHello = 5 + 16*1024
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		h := new(Handler)
+		err := core.RequireFeatures(ctx, func(pm policy.Manager) error {
+			return h.Init(config.(*Config), pm)
+		})
+		return h, err
+	}))
+}
+
+// Handler is the inbound routing TLS streams by server name.
+type Handler struct {
+	policyManager policy.Manager
+	config        *Config
+
+	exact     map[string]*Route
+	wildcards map[string]*Route
+	fallback  *Route
+}
+
+// Init initializes the Handler with the routes of config.
+func (h *Handler) Init(config *Config, pm policy.Manager) error {
+	h.config = config
+	h.policyManager = pm
+	h.exact = make(map[string]*Route)
+	h.wildcards = make(map[string]*Route)
+	for _, route := range config.Routes {
+		if route.Dest != "" {
+			if _, _, err := net.SplitHostPort(route.Dest); err != nil {
+				return errors.New("invalid dest ", route.Dest).Base(err)
+			}
+		}
+		if len(route.ServerNames) == 0 {
+			if h.fallback != nil {
+				return errors.New("more than one route without server names")
+			}
+			h.fallback = route
+		}
+		for _, name := range route.ServerNames {
+			name = strings.ToLower(name)
+			names := h.exact
+			if suffix, found := strings.CutPrefix(name, "*."); found {
+				name, names = suffix, h.wildcards
+			}
+			if names[name] != nil {
+				return errors.New("server name ", name, " of more than one route")
+			}
+			names[name] = route
+		}
+	}
+	return nil
+}
+
+// Network implements proxy.Inbound.
+func (*Handler) Network() []net.Network {
+	return []net.Network{net.Network_TCP, net.Network_UNIX}
+}
+
+// route returns the route of serverName, or nil if there is none.
+func (h *Handler) route(serverName string) *Route {
+	serverName = strings.ToLower(serverName)
+	if route := h.exact[serverName]; route != nil {
+		return route
+	}
+	// The longest suffix wins.
+	for name := serverName; ; {
+		_, parent, found := strings.Cut(name, ".")
+		if !found {
+			break
+		}
+		if route := h.wildcards[parent]; route != nil {
+			return route
+		}
+		name = parent
+	}
+	return h.fallback
+}
+
+// readHello reads the first TLS record of conn, and returns what is read and
+// the server name of the ClientHello in it.
+func readHello(conn stat.Connection) ([]byte, string, error) {
+	data := make([]byte, 0, 1024)
+	for {
+		if len(data) == cap(data) {
+			data = append(data, 0)[:len(data)]
+		}
+		n, err := conn.Read(data[len(data):min(cap(data), maxHello)])
+		data = data[:len(data)+n]
+		header, sniffErr := tls.SniffClientHello(data)
+		if sniffErr == nil {
+			return data, header.Domain(), nil
+		}
+		if sniffErr != common.ErrNoClue {
+			return nil, "", errors.New("not a TLS ClientHello").Base(sniffErr)
+		}
+		if err != nil {
+			return nil, "", errors.New("failed to read ClientHello").Base(err)
+		}
+		if len(data) >= maxHello {
+			return nil, "", errors.New("ClientHello too long")
+		}
+	}
+}
+
+// Process implements proxy.Inbound.
+func (h *Handler) Process(ctx context.Context, network net.Network, conn stat.Connection, dispatcher routing.Dispatcher) error {
+	plcy := h.policyManager.ForLevel(h.config.UserLevel)
+	if err := conn.SetReadDeadline(time.Now().Add(plcy.Timeouts.Handshake)); err != nil {
+		return errors.New("unable to set read deadline").Base(err).AtWarning()
+	}
+	hello, serverName, err := readHello(conn)
+	if err != nil {
+		return errors.New("invalid TLS stream from ", conn.RemoteAddr()).Base(err).AtInfo()
+	}
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return errors.New("unable to clear read deadline").Base(err).AtWarning()
+	}
+
+	route := h.route(serverName)
+	if route == nil {
+		return errors.New("no route for server name \"", serverName, "\" from ", conn.RemoteAddr()).AtInfo()
+	}
+	dest := net.Destination{Network: net.Network_TCP}
+	host, port := serverName, ""
+	if _, localPort, err := net.SplitHostPort(conn.LocalAddr().String()); err == nil {
+		port = localPort
+	}
+	if route.Dest != "" {
+		destHost, destPort, _ := net.SplitHostPort(route.Dest)
+		if destHost != "" {
+			host = destHost
+		}
+		if destPort != "" {
+			port = destPort
+		}
+	}
+	if host == "" {
+		return errors.New("no dest for ClientHello without server name from ", conn.RemoteAddr()).AtInfo()
+	}
+	dest.Address = net.ParseAddress(host)
+	p, err := strconv.Atoi(port)
+	if err != nil || p <= 0 || p > 65535 {
+		return errors.New("no port to relay ", serverName, " to")
+	}
+	dest.Port = net.Port(p)
+	if route.OutboundTag != "" {
+		ctx = session.SetForcedOutboundTagToContext(ctx, route.OutboundTag)
+	}
+
+	inbound := session.InboundFromContext(ctx)
+	inbound.Name = "sni"
+	inbound.CanSpliceCopy = 1
+	inbound.User = &protocol.MemoryUser{
+		Level: h.config.UserLevel,
+	}
+	ctx = log.ContextWithAccessMessage(ctx, &log.AccessMessage{
+		From:   conn.RemoteAddr(),
+		To:     dest,
+		Status: log.AccessAccepted,
+		Reason: "",
+	})
+	errors.LogInfo(ctx, "routing server name \"", serverName, "\" to ", dest)
+
+	ctx, cancel := context.WithCancel(ctx)
+	timer := signal.CancelAfterInactivity(ctx, cancel, plcy.Timeouts.ConnectionIdle)
+	inbound.Timer = timer
+
+	ctx = policy.ContextWithBufferPolicy(ctx, plcy.Buffer)
+	link, err := dispatcher.Dispatch(ctx, dest)
+	if err != nil {
+		return errors.New("failed to dispatch request").Base(err)
+	}
+
+	requestDone := func() error {
+		defer timer.SetTimeout(plcy.Timeouts.DownlinkOnly)
+		if err := link.Writer.WriteMultiBuffer(buf.MergeBytes(nil, hello)); err != nil {
+			return errors.New("failed to transport ClientHello").Base(err)
+		}
+		if err := buf.Copy(buf.NewReader(conn), link.Writer, buf.UpdateActivity(timer)); err != nil {
+			return errors.New("failed to transport request").Base(err)
+		}
+		return nil
+	}
+	responseDone := func() error {
+		defer timer.SetTimeout(plcy.Timeouts.UplinkOnly)
+		if err := buf.Copy(link.Reader, buf.NewWriter(conn), buf.UpdateActivity(timer)); err != nil {
+			return errors.New("failed to transport response").Base(err)
+		}
+		return nil
+	}
+
+	if err := task.Run(ctx, task.OnSuccess(requestDone, task.Close(link.Writer)), responseDone); err != nil {
+		common.Interrupt(link.Reader)
+		common.Interrupt(link.Writer)
+		return errors.New("connection ends").Base(err)
+	}
+	return nil
+}
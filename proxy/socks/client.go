@@ -2,19 +2,22 @@ package socks
 
 import (
 	"context"
+	"io"
 	"time"
 
+	"github.com/sagernet/sing/common/uot"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
-	"github.com/xtls/xray-core/common/retry"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/common/singbridge"
 	"github.com/xtls/xray-core/common/task"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/proxy"
 	"github.com/xtls/xray-core/transport"
 	"github.com/xtls/xray-core/transport/internet"
 	"github.com/xtls/xray-core/transport/internet/stat"
@@ -24,6 +27,7 @@ import (
 type Client struct {
 	serverPicker  protocol.ServerPicker
 	policyManager policy.Manager
+	uotClient     *uot.Client
 }
 
 // NewClient create a new Socks5 client based on the given config.
@@ -45,6 +49,9 @@ func NewClient(ctx context.Context, config *ClientConfig) (*Client, error) {
 		serverPicker:  protocol.NewRoundRobinServerPicker(serverList),
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
 	}
+	if config.UdpOverTcp {
+		c.uotClient = &uot.Client{Version: uint8(config.UdpOverTcpVersion)}
+	}
 
 	return c, nil
 }
@@ -68,7 +75,7 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 	// Connection to the outbound server.
 	var conn stat.Connection
 
-	if err := retry.ExponentialBackoff(5, 100).On(func() error {
+	if err := proxy.DialRetry(ctx, 5, 100).On(func() error {
 		server = c.serverPicker.PickServer()
 		dest = server.Destination()
 		rawConn, err := dialer.Dial(ctx, dest)
@@ -97,7 +104,14 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 		Port:    destination.Port,
 	}
 
-	if destination.Network == net.Network_UDP {
+	udpOverTCP := destination.Network == net.Network_UDP && c.uotClient != nil
+	if udpOverTCP {
+		// UDP-over-TCP is a CONNECT to a magic address, the server relays
+		// the packets read from that stream.
+		uotDestination := uot.RequestDestination(c.uotClient.Version)
+		request.Address = net.DomainAddress(uotDestination.Fqdn)
+		request.Port = net.Port(uotDestination.Port)
+	} else if destination.Network == net.Network_UDP {
 		request.Command = protocol.RequestCommandUDP
 	}
 
@@ -131,16 +145,32 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 	}
 
 	ctx, cancel := context.WithCancel(ctx)
-	timer := signal.CancelAfterInactivity(ctx, func() {
+	cancelAll := func() {
 		cancel()
 		if newCancel != nil {
 			newCancel()
 		}
-	}, p.Timeouts.ConnectionIdle)
+	}
+	timer := signal.CancelAfterInactivity(ctx, cancelAll, p.Timeouts.ConnectionIdle)
 
 	var requestFunc func() error
 	var responseFunc func() error
-	if request.Command == protocol.RequestCommandTCP {
+	if udpOverTCP {
+		uConn, err := c.uotClient.DialEarlyConn(conn, false, singbridge.ToSocksaddr(destination))
+		if err != nil {
+			return err
+		}
+		requestFunc = func() error {
+			defer timer.SetTimeout(p.Timeouts.DownlinkOnly)
+			writer := &singbridge.PacketWriter{PacketWriter: uConn, Dest: destination}
+			return buf.Copy(link.Reader, writer, buf.UpdateActivity(timer))
+		}
+		responseFunc = func() error {
+			defer timer.SetTimeout(p.Timeouts.UplinkOnly)
+			reader := &singbridge.PacketReader{PacketReader: uConn}
+			return buf.Copy(reader, link.Writer, buf.UpdateActivity(timer))
+		}
+	} else if request.Command == protocol.RequestCommandTCP {
 		requestFunc = func() error {
 			defer timer.SetTimeout(p.Timeouts.DownlinkOnly)
 			return buf.Copy(link.Reader, buf.NewWriter(conn), buf.UpdateActivity(timer))
@@ -156,6 +186,16 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 			return errors.New("failed to create UDP connection").Base(err)
 		}
 		defer udpConn.Close()
+		// The association is only valid while the control connection stays
+		// open, so watch it and tear down the relay once the server closes it.
+		// It is kept alive by the TCP keepalive the system dialer enables,
+		// tunable or disabled with tcpKeepAliveIdle and tcpKeepAliveInterval
+		// of its sockopt, so NATs keep it and dead servers end it.
+		go func() {
+			io.Copy(io.Discard, conn)
+			errors.LogDebug(ctx, "UDP association control connection closed")
+			cancelAll()
+		}()
 		requestFunc = func() error {
 			defer timer.SetTimeout(p.Timeouts.DownlinkOnly)
 			writer := &UDPWriter{Writer: udpConn, Request: request}
@@ -1,8 +1,10 @@
 package socks
 
 import (
+	"context"
 	"encoding/binary"
 	"io"
+	"time"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
@@ -39,6 +41,8 @@ var addrParser = protocol.NewAddressParser(
 	protocol.AddressFamilyByte(0x03, net.AddressFamilyDomain),
 )
 
+var errInvalidCredentials = errors.New("invalid username or password")
+
 type ServerSession struct {
 	config       *ServerConfig
 	address      net.Address
@@ -125,7 +129,7 @@ func (s *ServerSession) auth5(nMethod byte, reader io.Reader, writer io.Writer)
 
 		if !s.config.HasAccount(username, password) {
 			writeSocks5AuthenticationResponse(writer, 0x01, 0xFF)
-			return "", errors.New("invalid username or password")
+			return "", errInvalidCredentials
 		}
 
 		if err := writeSocks5AuthenticationResponse(writer, 0x01, 0x00); err != nil {
@@ -322,8 +326,21 @@ func writeSocks4Response(writer io.Writer, errCode byte, address net.Address, po
 }
 
 func DecodeUDPPacket(packet *buf.Buffer) (*protocol.RequestHeader, error) {
+	frag, request, err := decodeUDPHeader(packet)
+	if err != nil {
+		return nil, err
+	}
+	if frag != 0 {
+		return nil, errors.New("discarding fragmented payload.")
+	}
+	return request, nil
+}
+
+// decodeUDPHeader strips the SOCKS5 UDP header from packet and returns its
+// FRAG field along with the address it carries.
+func decodeUDPHeader(packet *buf.Buffer) (byte, *protocol.RequestHeader, error) {
 	if packet.Len() < 5 {
-		return nil, errors.New("insufficient length of packet.")
+		return 0, nil, errors.New("insufficient length of packet.")
 	}
 	request := &protocol.RequestHeader{
 		Version: socks5Version,
@@ -331,19 +348,17 @@ func DecodeUDPPacket(packet *buf.Buffer) (*protocol.RequestHeader, error) {
 	}
 
 	// packet[0] and packet[1] are reserved
-	if packet.Byte(2) != 0 /* fragments */ {
-		return nil, errors.New("discarding fragmented payload.")
-	}
+	frag := packet.Byte(2)
 
 	packet.Advance(3)
 
 	addr, port, err := addrParser.ReadAddressPort(nil, packet)
 	if err != nil {
-		return nil, errors.New("failed to read UDP header").Base(err)
+		return 0, nil, errors.New("failed to read UDP header").Base(err)
 	}
 	request.Address = addr
 	request.Port = port
-	return request, nil
+	return frag, request, nil
 }
 
 func EncodeUDPPacket(request *protocol.RequestHeader, data []byte) (*buf.Buffer, error) {
@@ -357,25 +372,88 @@ func EncodeUDPPacket(request *protocol.RequestHeader, data []byte) (*buf.Buffer,
 	return b, nil
 }
 
+// udpReassemblyTimeout is the REASSEMBLY TIMER of RFC 1928, which must be
+// no less than 5 seconds.
+const udpReassemblyTimeout = 5 * time.Second
+
+// udpReassembler collects the fragments of one datagram sequence.
+type udpReassembler struct {
+	dest     net.Destination
+	last     byte
+	data     []byte
+	deadline time.Time
+}
+
+func (a *udpReassembler) reset() {
+	a.last = 0
+	a.data = a.data[:0]
+}
+
+// feed adds one fragment to the queue and returns the whole datagram once
+// the fragment flagged as the end of the sequence arrives.
+func (a *udpReassembler) feed(frag byte, dest net.Destination, payload []byte) []byte {
+	pos := frag & 0x7f
+	now := time.Now()
+	if a.last != 0 && now.After(a.deadline) {
+		a.reset()
+	}
+	if pos == 1 {
+		a.reset()
+		a.dest = dest
+		a.deadline = now.Add(udpReassemblyTimeout)
+	} else if a.last == 0 || pos != a.last+1 {
+		// Out of order or missing fragments: abandon the sequence.
+		a.reset()
+		return nil
+	}
+	a.last = pos
+	a.data = append(a.data, payload...)
+	if frag&0x80 == 0 {
+		return nil
+	}
+	data := a.data
+	a.data = nil
+	a.last = 0
+	return data
+}
+
 type UDPReader struct {
 	Reader io.Reader
+
+	reassembler udpReassembler
 }
 
 func (r *UDPReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
-	buffer := buf.New()
-	_, err := buffer.ReadFrom(r.Reader)
-	if err != nil {
-		buffer.Release()
-		return nil, err
-	}
-	u, err := DecodeUDPPacket(buffer)
-	if err != nil {
+	for {
+		buffer := buf.New()
+		_, err := buffer.ReadFrom(r.Reader)
+		if err != nil {
+			buffer.Release()
+			return nil, err
+		}
+		frag, u, err := decodeUDPHeader(buffer)
+		if err != nil {
+			errors.LogDebugInner(context.Background(), err, "discarding invalid UDP packet")
+			buffer.Release()
+			continue
+		}
+		dest := u.Destination()
+		if frag == 0 {
+			r.reassembler.reset()
+			buffer.UDP = &dest
+			return buf.MultiBuffer{buffer}, nil
+		}
+		data := r.reassembler.feed(frag, dest, buffer.Bytes())
 		buffer.Release()
-		return nil, err
+		if data == nil {
+			continue
+		}
+		packet := buf.NewWithSize(int32(len(data)))
+		common.Must2(packet.Write(data))
+		dest = r.reassembler.dest
+		packet.UDP = &dest
+		return buf.MultiBuffer{packet}, nil
 	}
-	dest := u.Destination()
-	buffer.UDP = &dest
-	return buf.MultiBuffer{buffer}, nil
 }
 
 type UDPWriter struct {
@@ -414,15 +492,20 @@ func (w *UDPWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 }
 
 func ClientHandshake(request *protocol.RequestHeader, reader io.Reader, writer io.Writer) (*protocol.RequestHeader, error) {
-	authByte := byte(authNotRequired)
-	if request.User != nil {
-		authByte = byte(authPassword)
-	}
-
 	b := buf.New()
 	defer b.Release()
 
-	common.Must2(b.Write([]byte{socks5Version, 0x01, authByte}))
+	// Offer "no authentication" alongside username/password, so that servers
+	// which do not require credentials still accept the connection.
+	if request.User != nil {
+		account := request.User.Account.(*Account)
+		if len(account.Username) == 0 || len(account.Username) > 255 || len(account.Password) > 255 {
+			return nil, errors.New("invalid username or password length.").AtWarning()
+		}
+		common.Must2(b.Write([]byte{socks5Version, 0x02, authNotRequired, authPassword}))
+	} else {
+		common.Must2(b.Write([]byte{socks5Version, 0x01, authNotRequired}))
+	}
 	if err := buf.WriteAllBytes(writer, b.Bytes(), nil); err != nil {
 		return nil, err
 	}
@@ -435,8 +518,14 @@ func ClientHandshake(request *protocol.RequestHeader, reader io.Reader, writer i
 	if b.Byte(0) != socks5Version {
 		return nil, errors.New("unexpected server version: ", b.Byte(0)).AtWarning()
 	}
-	if b.Byte(1) != authByte {
-		return nil, errors.New("auth method not supported.").AtWarning()
+	authByte := b.Byte(1)
+	switch {
+	case authByte == authNotRequired:
+	case authByte == authPassword && request.User != nil:
+	case authByte == authNoMatchingMethod && request.User == nil:
+		return nil, errors.New("server requires authentication.").AtWarning()
+	default:
+		return nil, errors.New("auth method not supported: ", authByte).AtWarning()
 	}
 
 	if authByte == authPassword {
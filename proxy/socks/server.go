@@ -19,6 +19,7 @@ import (
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/proxy"
 	"github.com/xtls/xray-core/proxy/http"
 	"github.com/xtls/xray-core/transport/internet/stat"
 	"github.com/xtls/xray-core/transport/internet/udp"
@@ -60,7 +61,7 @@ func (s *Server) policy() policy.Session {
 
 // Network implements proxy.Inbound.
 func (s *Server) Network() []net.Network {
-	list := []net.Network{net.Network_TCP}
+	list := []net.Network{net.Network_TCP, net.Network_UNIX}
 	if s.config.UdpEnabled {
 		list = append(list, net.Network_UDP)
 	}
@@ -77,7 +78,12 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 	}
 
 	switch network {
-	case net.Network_TCP:
+	case net.Network_TCP, net.Network_UNIX:
+		// The first byte tells SOCKS4/4a/5 apart from HTTP, which lets this
+		// inbound serve as the "mixed" protocol on a single port.
+		if err := conn.SetReadDeadline(time.Now().Add(s.policy().Timeouts.Handshake)); err != nil {
+			errors.LogInfoInner(ctx, err, "failed to set deadline")
+		}
 		firstbyte := make([]byte, 1)
 		if n, err := conn.Read(firstbyte); n == 0 {
 			if goerrors.Is(err, io.EOF) {
@@ -113,7 +119,10 @@ func (s *Server) processTCP(ctx context.Context, conn stat.Connection, dispatche
 		config:       s.config,
 		address:      inbound.Gateway.Address,
 		port:         inbound.Gateway.Port,
-		localAddress: net.IPAddress(conn.LocalAddr().(*net.TCPAddr).IP),
+		localAddress: net.LocalHostIP,
+	}
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		svrSession.localAddress = net.IPAddress(addr.IP)
 	}
 
 	// Firstbyte is for forwarded conn from SOCKS inbound
@@ -133,6 +142,9 @@ func (s *Server) processTCP(ctx context.Context, conn stat.Connection, dispatche
 				Reason: err,
 			})
 		}
+		if errors.Cause(err) == errInvalidCredentials {
+			proxy.AuthFailed(ctx)
+		}
 		return errors.New("failed to read request").Base(err)
 	}
 	if request.User != nil {
@@ -0,0 +1,255 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/internet"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const keepAliveInterval = 30 * time.Second
+
+func init() {
+	common.Must(common.RegisterConfig((*ClientConfig)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		return NewClient(ctx, config.(*ClientConfig))
+	}))
+}
+
+// Client is an outbound handler that tunnels TCP connections through the
+// direct-tcpip channels of a single shared SSH session.
+type Client struct {
+	server        net.Destination
+	config        *ClientConfig
+	hostKeys      [][]byte
+	signer        ssh.Signer
+	level         uint32
+	policyManager policy.Manager
+
+	access sync.Mutex
+	client *ssh.Client
+}
+
+// NewClient creates a new SSH client.
+func NewClient(ctx context.Context, config *ClientConfig) (*Client, error) {
+	if config.Address == nil {
+		return nil, errors.New("ssh: server address is not set")
+	}
+	if config.Port == 0 {
+		return nil, errors.New("ssh: server port is not set")
+	}
+	c := &Client{
+		server: net.StreamDestination(config.Address.AsAddress(), net.Port(config.Port)),
+		config: config,
+		level:  config.Level,
+	}
+	for _, k := range config.HostKey {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k))
+		if err != nil {
+			return nil, errors.New("ssh: invalid host key ", k).Base(err)
+		}
+		c.hostKeys = append(c.hostKeys, key.Marshal())
+	}
+	if config.PrivateKey != "" {
+		var signer ssh.Signer
+		var err error
+		if config.PrivateKeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(config.PrivateKey), []byte(config.PrivateKeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(config.PrivateKey))
+		}
+		if err != nil {
+			return nil, errors.New("ssh: invalid private key").Base(err)
+		}
+		c.signer = signer
+	}
+	if len(c.hostKeys) == 0 {
+		if !config.InsecureSkipHostKey {
+			return nil, errors.New("ssh: no host key configured for ", c.server.NetAddr())
+		}
+		errors.LogWarning(ctx, "ssh: no host key configured for ", c.server.NetAddr(), ", server identity will not be verified")
+	}
+	v := core.MustFromContext(ctx)
+	c.policyManager = v.GetFeature(policy.ManagerType()).(policy.Manager)
+	return c, nil
+}
+
+// Process implements proxy.Outbound.Process().
+func (c *Client) Process(ctx context.Context, link *transport.Link, dialer internet.Dialer) error {
+	outbounds := session.OutboundsFromContext(ctx)
+	ob := outbounds[len(outbounds)-1]
+	if !ob.Target.IsValid() {
+		return errors.New("target not specified")
+	}
+	ob.Name = "ssh"
+	ob.CanSpliceCopy = 3
+	destination := ob.Target
+	if destination.Network != net.Network_TCP {
+		return errors.New("ssh: only TCP is supported")
+	}
+
+	client, err := c.getClient(ctx, dialer)
+	if err != nil {
+		return errors.New("failed to connect to server ", c.server.NetAddr()).Base(err).AtWarning()
+	}
+	errors.LogInfo(ctx, "tunneling request to ", destination, " via ", c.server.NetAddr())
+
+	conn, err := client.DialContext(ctx, "tcp", destination.NetAddr())
+	if err != nil {
+		return errors.New("failed to open channel to ", destination).Base(err)
+	}
+	defer conn.Close()
+
+	if session.TimeoutOnlyFromContext(ctx) {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+	}
+
+	sessionPolicy := c.policyManager.ForLevel(c.level)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
+
+	postRequest := func() error {
+		defer timer.SetTimeout(sessionPolicy.Timeouts.DownlinkOnly)
+		if err := buf.Copy(link.Reader, buf.NewWriter(conn), buf.UpdateActivity(timer)); err != nil {
+			return errors.New("failed to transfer request payload").Base(err).AtInfo()
+		}
+		if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		}
+		return nil
+	}
+
+	getResponse := func() error {
+		defer timer.SetTimeout(sessionPolicy.Timeouts.UplinkOnly)
+		return buf.Copy(buf.NewReader(conn), link.Writer, buf.UpdateActivity(timer))
+	}
+
+	responseDoneAndCloseWriter := task.OnSuccess(getResponse, task.Close(link.Writer))
+	if err := task.Run(ctx, postRequest, responseDoneAndCloseWriter); err != nil {
+		return errors.New("connection ends").Base(err)
+	}
+	return nil
+}
+
+func (c *Client) getClient(ctx context.Context, dialer internet.Dialer) (*ssh.Client, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.client != nil {
+		return c.client, nil
+	}
+	client, err := c.dial(ctx, dialer)
+	if err != nil {
+		return nil, err
+	}
+	c.client = client
+	closed := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(closed)
+		c.access.Lock()
+		if c.client == client {
+			c.client = nil
+		}
+		c.access.Unlock()
+	}()
+	go keepAlive(client, closed)
+	return client, nil
+}
+
+func (c *Client) dial(ctx context.Context, dialer internet.Dialer) (*ssh.Client, error) {
+	conn, err := dialer.Dial(ctx, c.server)
+	if err != nil {
+		return nil, err
+	}
+
+	var auth []ssh.AuthMethod
+	if c.config.UseAgent {
+		if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+			agentConn, err := net.Dial("unix", sock)
+			if err != nil {
+				errors.LogWarningInner(ctx, err, "ssh: failed to connect to agent")
+			} else {
+				defer agentConn.Close()
+				auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+			}
+		} else {
+			errors.LogWarning(ctx, "ssh: SSH_AUTH_SOCK is not set, agent authentication is skipped")
+		}
+	}
+	if c.signer != nil {
+		auth = append(auth, ssh.PublicKeys(c.signer))
+	}
+	if c.config.Password != "" {
+		auth = append(auth, ssh.Password(c.config.Password))
+	}
+
+	sshConfig := &ssh.ClientConfig{
+		User:              c.config.User,
+		Auth:              auth,
+		HostKeyCallback:   c.checkHostKey,
+		HostKeyAlgorithms: c.config.HostKeyAlgorithms,
+		ClientVersion:     c.config.ClientVersion,
+		Timeout:           16 * time.Second,
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(sshConfig.Timeout))
+	}
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, c.server.NetAddr(), sshConfig)
+	if err != nil {
+		conn.Close()
+		return nil, errors.New("SSH handshake failed").Base(err)
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
+
+func (c *Client) checkHostKey(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	if len(c.hostKeys) == 0 {
+		return nil
+	}
+	marshaled := key.Marshal()
+	for _, k := range c.hostKeys {
+		if bytes.Equal(k, marshaled) {
+			return nil
+		}
+	}
+	return errors.New("ssh: host key mismatch, got ", key.Type(), " ", ssh.FingerprintSHA256(key))
+}
+
+// keepAlive detects dead sessions so that the next request reconnects, until
+// the session is closed.
+func keepAlive(client *ssh.Client, closed <-chan struct{}) {
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				client.Close()
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,3 @@
+// Package ssh implements the outbound tunneling TCP connections through an
+// SSH server, as ssh -W does.
+package ssh
@@ -1,23 +1,29 @@
 package trojan
 
 import (
+	"bytes"
 	"context"
+	gotls "crypto/tls"
 	"time"
 
+	utls "github.com/refraction-networking/utls"
+
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
-	"github.com/xtls/xray-core/common/retry"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal"
 	"github.com/xtls/xray-core/common/task"
 	core "github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/proxy"
+	"github.com/xtls/xray-core/proxy/vless"
 	"github.com/xtls/xray-core/transport"
 	"github.com/xtls/xray-core/transport/internet"
 	"github.com/xtls/xray-core/transport/internet/stat"
+	"github.com/xtls/xray-core/transport/internet/tls"
 )
 
 // Client is a inbound handler for trojan protocol
@@ -56,14 +62,13 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 		return errors.New("target not specified")
 	}
 	ob.Name = "trojan"
-	ob.CanSpliceCopy = 3
 	destination := ob.Target
 	network := destination.Network
 
 	var server *protocol.ServerSpec
 	var conn stat.Connection
 
-	err := retry.ExponentialBackoff(5, 100).On(func() error {
+	err := proxy.DialRetry(ctx, 5, 100).On(func() error {
 		server = c.serverPicker.PickServer()
 		rawConn, err := dialer.Dial(ctx, server.Destination())
 		if err != nil {
@@ -80,12 +85,36 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 
 	defer conn.Close()
 
+	iConn := conn
+	if statConn, ok := iConn.(*stat.CounterConnection); ok {
+		iConn = statConn.Connection
+	}
+
 	user := server.PickUser()
 	account, ok := user.Account.(*MemoryAccount)
 	if !ok {
 		return errors.New("user account is not valid")
 	}
 
+	// XTLS Vision is only for TCP, UDP is as without it.
+	flow := account.Flow
+	if network == net.Network_UDP {
+		flow = ""
+	}
+	var input *bytes.Reader
+	var rawInput *bytes.Buffer
+	var trafficState *proxy.TrafficState
+	switch flow {
+	case vless.XRV:
+		ob.CanSpliceCopy = 2
+		if input, rawInput, err = proxy.XtlsInput(iConn); err != nil {
+			return err
+		}
+		trafficState = proxy.NewTrafficState(account.visionID())
+	default:
+		ob.CanSpliceCopy = 3
+	}
+
 	var newCtx context.Context
 	var newCancel context.CancelFunc
 	if session.TimeoutOnlyFromContext(ctx) {
@@ -110,17 +139,25 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 			Writer:  bufferWriter,
 			Target:  destination,
 			Account: account,
+			Flow:    flow,
 		}
 
 		var bodyWriter buf.Writer
 		if destination.Network == net.Network_UDP {
 			bodyWriter = &PacketWriter{Writer: connWriter, Target: destination}
+		} else if flow == vless.XRV {
+			bodyWriter = proxy.NewVisionWriter(connWriter, trafficState, true, ctx)
 		} else {
 			bodyWriter = connWriter
 		}
 
 		// write some request payload to buffer
-		if err = buf.CopyOnceTimeout(link.Reader, bodyWriter, time.Millisecond*100); err != nil && err != buf.ErrNotTimeoutReader && err != buf.ErrReadTimeout {
+		if err = buf.CopyOnceTimeout(link.Reader, bodyWriter, time.Millisecond*100); err == buf.ErrReadTimeout && flow == vless.XRV {
+			errors.LogInfo(ctx, "Insert padding with empty content to camouflage trojan header")
+			if err = bodyWriter.WriteMultiBuffer(make(buf.MultiBuffer, 1)); err != nil {
+				return err
+			}
+		} else if err != nil && err != buf.ErrNotTimeoutReader && err != buf.ErrReadTimeout {
 			return errors.New("failed to write A request payload").Base(err).AtWarning()
 		}
 
@@ -134,7 +171,16 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 			return err.(*errors.Error).AtWarning()
 		}
 
-		if err = buf.Copy(link.Reader, bodyWriter, buf.UpdateActivity(timer)); err != nil {
+		if flow == vless.XRV {
+			if err := checkXtlsVersion(iConn); err != nil {
+				return err
+			}
+			ctx1 := session.ContextWithInbound(ctx, nil) // TODO enable splice
+			err = proxy.XtlsWrite(link.Reader, bodyWriter, timer, conn, trafficState, ob, true, ctx1)
+		} else {
+			err = buf.Copy(link.Reader, bodyWriter, buf.UpdateActivity(timer))
+		}
+		if err != nil {
 			return errors.New("failed to transfer request payload").Base(err).AtInfo()
 		}
 
@@ -152,6 +198,10 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 		} else {
 			reader = buf.NewReader(conn)
 		}
+		if flow == vless.XRV {
+			reader = proxy.NewVisionReader(reader, trafficState, false, ctx)
+			return proxy.XtlsRead(reader, link.Writer, timer, conn, input, rawInput, trafficState, ob, false, ctx)
+		}
 		return buf.Copy(reader, link.Writer, buf.UpdateActivity(timer))
 	}
 
@@ -167,6 +217,21 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 	return nil
 }
 
+// checkXtlsVersion returns an error if conn, of TLS, is not of TLS 1.3, as
+// XTLS Vision requires.
+func checkXtlsVersion(conn stat.Connection) error {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if tlsConn.ConnectionState().Version != gotls.VersionTLS13 {
+			return errors.New(`failed to use `+vless.XRV+`, found outer tls version `, tlsConn.ConnectionState().Version).AtWarning()
+		}
+	} else if utlsConn, ok := conn.(*tls.UConn); ok {
+		if utlsConn.ConnectionState().Version != utls.VersionTLS13 {
+			return errors.New(`failed to use `+vless.XRV+`, found outer tls version `, utlsConn.ConnectionState().Version).AtWarning()
+		}
+	}
+	return nil
+}
+
 func init() {
 	common.Must(common.RegisterConfig((*ClientConfig)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
 		return NewClient(ctx, config.(*ClientConfig))
@@ -8,12 +8,15 @@ import (
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/proxy/fallback"
 )
 
 // MemoryAccount is an account type converted from Account.
 type MemoryAccount struct {
 	Password string
 	Key      []byte
+	// Flow of the account. May be "xtls-rprx-vision".
+	Flow string
 }
 
 // AsAccount implements protocol.AsAccount.
@@ -23,6 +26,7 @@ func (a *Account) AsAccount() (protocol.Account, error) {
 	return &MemoryAccount{
 		Password: password,
 		Key:      key,
+		Flow:     a.Flow,
 	}, nil
 }
 
@@ -37,9 +41,18 @@ func (a *MemoryAccount) Equals(another protocol.Account) bool {
 func (a *MemoryAccount) ToProto() proto.Message {
 	return &Account{
 		Password: a.Password,
+		Flow:     a.Flow,
 	}
 }
 
+// visionID returns the ID of a for XTLS Vision: the first 16 bytes of the
+// hash of its password.
+func (a *MemoryAccount) visionID() []byte {
+	id := make([]byte, 16)
+	common.Must2(hex.Decode(id, a.Key[:32]))
+	return id
+}
+
 func hexSha224(password string) []byte {
 	buf := make([]byte, 56)
 	hash := sha256.New224()
@@ -55,3 +68,14 @@ func hexString(data []byte) string {
 	}
 	return str
 }
+
+// AsFallback returns fb as a fallback.Fallback, with dest before the backups.
+func (fb *Fallback) AsFallback() *fallback.Fallback {
+	return &fallback.Fallback{
+		Name: fb.Name,
+		Alpn: fb.Alpn,
+		Path: fb.Path,
+		Dest: append([]*fallback.Dest{{Type: fb.Type, Dest: fb.Dest}}, fb.Backup...),
+		Xver: fb.Xver,
+	}
+}
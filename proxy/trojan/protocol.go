@@ -8,6 +8,7 @@ import (
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/proxy/vless"
 )
 
 var (
@@ -25,6 +26,8 @@ const (
 
 	commandTCP byte = 1
 	commandUDP byte = 3
+	// commandXRV is commandTCP with the flow XTLS Vision.
+	commandXRV byte = 0xf2
 )
 
 // ConnWriter is TCP Connection Writer Wrapper for trojan protocol
@@ -32,6 +35,7 @@ type ConnWriter struct {
 	io.Writer
 	Target     net.Destination
 	Account    *MemoryAccount
+	Flow       string
 	headerSent bool
 }
 
@@ -68,6 +72,8 @@ func (c *ConnWriter) writeHeader() error {
 	command := commandTCP
 	if c.Target.Network == net.Network_UDP {
 		command = commandUDP
+	} else if c.Flow == vless.XRV {
+		command = commandXRV
 	}
 
 	if _, err := buffer.Write(c.Account.Key); err != nil {
@@ -175,6 +181,8 @@ func (c *ConnReader) ParseHeader() error {
 	network := net.Network_TCP
 	if command[0] == commandUDP {
 		network = net.Network_UDP
+	} else if command[0] == commandXRV {
+		c.Flow = vless.XRV
 	}
 
 	addr, port, err := addrParser.ReadAddressPort(nil, c.Reader)
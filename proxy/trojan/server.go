@@ -1,10 +1,11 @@
 package trojan
 
 import (
+	"bytes"
 	"context"
+	gotls "crypto/tls"
 	"io"
-	"strconv"
-	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/xtls/xray-core/common"
@@ -14,13 +15,16 @@ import (
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
 	udp_proto "github.com/xtls/xray-core/common/protocol/udp"
-	"github.com/xtls/xray-core/common/retry"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal"
 	"github.com/xtls/xray-core/common/task"
 	"github.com/xtls/xray-core/core"
+	feature_inbound "github.com/xtls/xray-core/features/inbound"
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/proxy"
+	"github.com/xtls/xray-core/proxy/fallback"
+	"github.com/xtls/xray-core/proxy/vless"
 	"github.com/xtls/xray-core/transport/internet/reality"
 	"github.com/xtls/xray-core/transport/internet/stat"
 	"github.com/xtls/xray-core/transport/internet/tls"
@@ -37,7 +41,9 @@ func init() {
 type Server struct {
 	policyManager policy.Manager
 	validator     *Validator
-	fallbacks     map[string]map[string]map[string]*Fallback // or nil
+	inbounds      feature_inbound.Manager
+	fallbackCheck *fallback.HealthCheck
+	fallbacks     atomic.Pointer[fallback.Table] // or nil
 	cone          bool
 }
 
@@ -59,62 +65,36 @@ func NewServer(ctx context.Context, config *ServerConfig) (*Server, error) {
 	server := &Server{
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
 		validator:     validator,
+		inbounds:      v.GetFeature(feature_inbound.ManagerType()).(feature_inbound.Manager),
+		fallbackCheck: config.FallbackCheck,
 		cone:          ctx.Value("cone").(bool),
 	}
 
-	if config.Fallbacks != nil {
-		server.fallbacks = make(map[string]map[string]map[string]*Fallback)
-		for _, fb := range config.Fallbacks {
-			if server.fallbacks[fb.Name] == nil {
-				server.fallbacks[fb.Name] = make(map[string]map[string]*Fallback)
-			}
-			if server.fallbacks[fb.Name][fb.Alpn] == nil {
-				server.fallbacks[fb.Name][fb.Alpn] = make(map[string]*Fallback)
-			}
-			server.fallbacks[fb.Name][fb.Alpn][fb.Path] = fb
-		}
-		if server.fallbacks[""] != nil {
-			for name, apfb := range server.fallbacks {
-				if name != "" {
-					for alpn := range server.fallbacks[""] {
-						if apfb[alpn] == nil {
-							apfb[alpn] = make(map[string]*Fallback)
-						}
-					}
-				}
-			}
-		}
-		for _, apfb := range server.fallbacks {
-			if apfb[""] != nil {
-				for alpn, pfb := range apfb {
-					if alpn != "" { // && alpn != "h2" {
-						for path, fb := range apfb[""] {
-							if pfb[path] == nil {
-								pfb[path] = fb
-							}
-						}
-					}
-				}
-			}
-		}
-		if server.fallbacks[""] != nil {
-			for name, apfb := range server.fallbacks {
-				if name != "" {
-					for alpn, pfb := range server.fallbacks[""] {
-						for path, fb := range pfb {
-							if apfb[alpn][path] == nil {
-								apfb[alpn][path] = fb
-							}
-						}
-					}
-				}
-			}
-		}
+	fallbacks := make([]*fallback.Fallback, 0, len(config.Fallbacks))
+	for _, fb := range config.Fallbacks {
+		fallbacks = append(fallbacks, fb.AsFallback())
+	}
+	if err := server.SetFallbacks(ctx, fallbacks); err != nil {
+		return nil, err
 	}
 
 	return server, nil
 }
 
+// Close implements common.Closable.Close().
+func (s *Server) Close() error {
+	return s.fallbacks.Load().Close()
+}
+
+// SetFallbacks implements proxy.FallbackManager.SetFallbacks().
+func (s *Server) SetFallbacks(ctx context.Context, fallbacks []*fallback.Fallback) error {
+	table, err := fallback.NewTable(s.inbounds, fallbacks, s.fallbackCheck)
+	if err != nil {
+		return errors.New("failed to set fallbacks").Base(err)
+	}
+	return s.fallbacks.Swap(table).Close()
+}
+
 // AddUser implements proxy.UserManager.AddUser().
 func (s *Server) AddUser(ctx context.Context, u *protocol.MemoryUser) error {
 	return s.validator.Add(u)
@@ -173,8 +153,8 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 
 	var user *protocol.MemoryUser
 
-	napfb := s.fallbacks
-	isfb := napfb != nil
+	fallbacks := s.fallbacks.Load()
+	isfb := fallbacks != nil
 
 	shouldFallback := false
 	if firstLen < 58 || first.Byte(56) != '\r' {
@@ -199,13 +179,14 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 				Status: log.AccessRejected,
 				Reason: err,
 			})
+			proxy.AuthFailed(ctx)
 
 			shouldFallback = true
 		}
 	}
 
 	if isfb && shouldFallback {
-		return s.fallback(ctx, err, sessionPolicy, conn, iConn, napfb, first, firstLen, bufferedReader)
+		return fallbacks.Serve(ctx, err, sessionPolicy, conn, iConn, first, firstLen, bufferedReader)
 	} else if shouldFallback {
 		return errors.New("invalid protocol or invalid user")
 	}
@@ -232,6 +213,37 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 	inbound.User = user
 	sessionPolicy = s.policyManager.ForLevel(user.Level)
 
+	account := user.Account.(*MemoryAccount)
+	var vision *visionState
+	switch clientReader.Flow {
+	case vless.XRV:
+		if account.Flow != vless.XRV {
+			return errors.New("account " + user.Email + " is not able to use the flow " + vless.XRV).AtWarning()
+		}
+		inbound.CanSpliceCopy = 2
+		if tlsConn, ok := iConn.(*tls.Conn); ok {
+			if tlsConn.ConnectionState().Version != gotls.VersionTLS13 {
+				return errors.New(`failed to use `+vless.XRV+`, found outer tls version `, tlsConn.ConnectionState().Version).AtWarning()
+			}
+		} else if _, ok := iConn.(*reality.Conn); !ok {
+			return errors.New("XTLS only supports TLS and REALITY directly for now.").AtWarning()
+		}
+		input, rawInput, err := proxy.XtlsInput(iConn)
+		if err != nil {
+			return err
+		}
+		vision = &visionState{
+			conn:     conn,
+			input:    input,
+			rawInput: rawInput,
+			state:    proxy.NewTrafficState(account.visionID()),
+		}
+	case "":
+		if account.Flow == vless.XRV && destination.Network == net.Network_TCP {
+			return errors.New("account " + user.Email + " is rejected since the client flow is empty. Note that the pure TLS proxy has certain TLS in TLS characters.").AtWarning()
+		}
+	}
+
 	if destination.Network == net.Network_UDP { // handle udp request
 		return s.handleUDPPayload(ctx, &PacketReader{Reader: clientReader}, &PacketWriter{Writer: conn}, dispatcher)
 	}
@@ -245,7 +257,15 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 	})
 
 	errors.LogInfo(ctx, "received request for ", destination)
-	return s.handleConnection(ctx, sessionPolicy, destination, clientReader, buf.NewWriter(conn), dispatcher)
+	return s.handleConnection(ctx, sessionPolicy, destination, clientReader, buf.NewWriter(conn), dispatcher, vision)
+}
+
+// visionState is of a connection with the flow XTLS Vision.
+type visionState struct {
+	conn     stat.Connection
+	input    *bytes.Reader
+	rawInput *bytes.Buffer
+	state    *proxy.TrafficState
 }
 
 func (s *Server) handleUDPPayload(ctx context.Context, clientReader *PacketReader, clientWriter *PacketWriter, dispatcher routing.Dispatcher) error {
@@ -312,7 +332,7 @@ func (s *Server) handleUDPPayload(ctx context.Context, clientReader *PacketReade
 func (s *Server) handleConnection(ctx context.Context, sessionPolicy policy.Session,
 	destination net.Destination,
 	clientReader buf.Reader,
-	clientWriter buf.Writer, dispatcher routing.Dispatcher,
+	clientWriter buf.Writer, dispatcher routing.Dispatcher, vision *visionState,
 ) error {
 	ctx, cancel := context.WithCancel(ctx)
 	timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
@@ -325,6 +345,14 @@ func (s *Server) handleConnection(ctx context.Context, sessionPolicy policy.Sess
 
 	requestDone := func() error {
 		defer timer.SetTimeout(sessionPolicy.Timeouts.DownlinkOnly)
+		if vision != nil {
+			ctx1 := session.ContextWithInbound(ctx, nil) // TODO enable splice
+			reader := proxy.NewVisionReader(clientReader, vision.state, true, ctx1)
+			if err := proxy.XtlsRead(reader, link.Writer, timer, vision.conn, vision.input, vision.rawInput, vision.state, nil, true, ctx1); err != nil {
+				return errors.New("failed to transfer request").Base(err)
+			}
+			return nil
+		}
 		if buf.Copy(clientReader, link.Writer, buf.UpdateActivity(timer)) != nil {
 			return errors.New("failed to transfer request").Base(err)
 		}
@@ -334,6 +362,13 @@ func (s *Server) handleConnection(ctx context.Context, sessionPolicy policy.Sess
 	responseDone := func() error {
 		defer timer.SetTimeout(sessionPolicy.Timeouts.UplinkOnly)
 
+		if vision != nil {
+			writer := proxy.NewVisionWriter(clientWriter, vision.state, false, ctx)
+			if err := proxy.XtlsWrite(link.Reader, writer, timer, vision.conn, vision.state, nil, false, ctx); err != nil {
+				return errors.New("failed to write response").Base(err)
+			}
+			return nil
+		}
 		if err := buf.Copy(link.Reader, clientWriter, buf.UpdateActivity(timer)); err != nil {
 			return errors.New("failed to write response").Base(err)
 		}
@@ -349,190 +384,3 @@ func (s *Server) handleConnection(ctx context.Context, sessionPolicy policy.Sess
 
 	return nil
 }
-
-func (s *Server) fallback(ctx context.Context, err error, sessionPolicy policy.Session, connection stat.Connection, iConn stat.Connection, napfb map[string]map[string]map[string]*Fallback, first *buf.Buffer, firstLen int64, reader buf.Reader) error {
-	if err := connection.SetReadDeadline(time.Time{}); err != nil {
-		errors.LogWarningInner(ctx, err, "unable to set back read deadline")
-	}
-	errors.LogInfoInner(ctx, err, "fallback starts")
-
-	name := ""
-	alpn := ""
-	if tlsConn, ok := iConn.(*tls.Conn); ok {
-		cs := tlsConn.ConnectionState()
-		name = cs.ServerName
-		alpn = cs.NegotiatedProtocol
-		errors.LogInfo(ctx, "realName = "+name)
-		errors.LogInfo(ctx, "realAlpn = "+alpn)
-	} else if realityConn, ok := iConn.(*reality.Conn); ok {
-		cs := realityConn.ConnectionState()
-		name = cs.ServerName
-		alpn = cs.NegotiatedProtocol
-		errors.LogInfo(ctx, "realName = "+name)
-		errors.LogInfo(ctx, "realAlpn = "+alpn)
-	}
-	name = strings.ToLower(name)
-	alpn = strings.ToLower(alpn)
-
-	if len(napfb) > 1 || napfb[""] == nil {
-		if name != "" && napfb[name] == nil {
-			match := ""
-			for n := range napfb {
-				if n != "" && strings.Contains(name, n) && len(n) > len(match) {
-					match = n
-				}
-			}
-			name = match
-		}
-	}
-
-	if napfb[name] == nil {
-		name = ""
-	}
-	apfb := napfb[name]
-	if apfb == nil {
-		return errors.New(`failed to find the default "name" config`).AtWarning()
-	}
-
-	if apfb[alpn] == nil {
-		alpn = ""
-	}
-	pfb := apfb[alpn]
-	if pfb == nil {
-		return errors.New(`failed to find the default "alpn" config`).AtWarning()
-	}
-
-	path := ""
-	if len(pfb) > 1 || pfb[""] == nil {
-		if firstLen >= 18 && first.Byte(4) != '*' { // not h2c
-			firstBytes := first.Bytes()
-			for i := 4; i <= 8; i++ { // 5 -> 9
-				if firstBytes[i] == '/' && firstBytes[i-1] == ' ' {
-					search := len(firstBytes)
-					if search > 64 {
-						search = 64 // up to about 60
-					}
-					for j := i + 1; j < search; j++ {
-						k := firstBytes[j]
-						if k == '\r' || k == '\n' { // avoid logging \r or \n
-							break
-						}
-						if k == '?' || k == ' ' {
-							path = string(firstBytes[i:j])
-							errors.LogInfo(ctx, "realPath = "+path)
-							if pfb[path] == nil {
-								path = ""
-							}
-							break
-						}
-					}
-					break
-				}
-			}
-		}
-	}
-	fb := pfb[path]
-	if fb == nil {
-		return errors.New(`failed to find the default "path" config`).AtWarning()
-	}
-
-	ctx, cancel := context.WithCancel(ctx)
-	timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
-	ctx = policy.ContextWithBufferPolicy(ctx, sessionPolicy.Buffer)
-
-	var conn net.Conn
-	if err := retry.ExponentialBackoff(5, 100).On(func() error {
-		var dialer net.Dialer
-		conn, err = dialer.DialContext(ctx, fb.Type, fb.Dest)
-		if err != nil {
-			return err
-		}
-		return nil
-	}); err != nil {
-		return errors.New("failed to dial to " + fb.Dest).Base(err).AtWarning()
-	}
-	defer conn.Close()
-
-	serverReader := buf.NewReader(conn)
-	serverWriter := buf.NewWriter(conn)
-
-	postRequest := func() error {
-		defer timer.SetTimeout(sessionPolicy.Timeouts.DownlinkOnly)
-		if fb.Xver != 0 {
-			ipType := 4
-			remoteAddr, remotePort, err := net.SplitHostPort(connection.RemoteAddr().String())
-			if err != nil {
-				ipType = 0
-			}
-			localAddr, localPort, err := net.SplitHostPort(connection.LocalAddr().String())
-			if err != nil {
-				ipType = 0
-			}
-			if ipType == 4 {
-				for i := 0; i < len(remoteAddr); i++ {
-					if remoteAddr[i] == ':' {
-						ipType = 6
-						break
-					}
-				}
-			}
-			pro := buf.New()
-			defer pro.Release()
-			switch fb.Xver {
-			case 1:
-				if ipType == 0 {
-					common.Must2(pro.Write([]byte("PROXY UNKNOWN\r\n")))
-					break
-				}
-				if ipType == 4 {
-					common.Must2(pro.Write([]byte("PROXY TCP4 " + remoteAddr + " " + localAddr + " " + remotePort + " " + localPort + "\r\n")))
-				} else {
-					common.Must2(pro.Write([]byte("PROXY TCP6 " + remoteAddr + " " + localAddr + " " + remotePort + " " + localPort + "\r\n")))
-				}
-			case 2:
-				common.Must2(pro.Write([]byte("\x0D\x0A\x0D\x0A\x00\x0D\x0A\x51\x55\x49\x54\x0A"))) // signature
-				if ipType == 0 {
-					common.Must2(pro.Write([]byte("\x20\x00\x00\x00"))) // v2 + LOCAL + UNSPEC + UNSPEC + 0 bytes
-					break
-				}
-				if ipType == 4 {
-					common.Must2(pro.Write([]byte("\x21\x11\x00\x0C"))) // v2 + PROXY + AF_INET + STREAM + 12 bytes
-					common.Must2(pro.Write(net.ParseIP(remoteAddr).To4()))
-					common.Must2(pro.Write(net.ParseIP(localAddr).To4()))
-				} else {
-					common.Must2(pro.Write([]byte("\x21\x21\x00\x24"))) // v2 + PROXY + AF_INET6 + STREAM + 36 bytes
-					common.Must2(pro.Write(net.ParseIP(remoteAddr).To16()))
-					common.Must2(pro.Write(net.ParseIP(localAddr).To16()))
-				}
-				p1, _ := strconv.ParseUint(remotePort, 10, 16)
-				p2, _ := strconv.ParseUint(localPort, 10, 16)
-				common.Must2(pro.Write([]byte{byte(p1 >> 8), byte(p1), byte(p2 >> 8), byte(p2)}))
-			}
-			if err := serverWriter.WriteMultiBuffer(buf.MultiBuffer{pro}); err != nil {
-				return errors.New("failed to set PROXY protocol v", fb.Xver).Base(err).AtWarning()
-			}
-		}
-		if err := buf.Copy(reader, serverWriter, buf.UpdateActivity(timer)); err != nil {
-			return errors.New("failed to fallback request payload").Base(err).AtInfo()
-		}
-		return nil
-	}
-
-	writer := buf.NewWriter(connection)
-
-	getResponse := func() error {
-		defer timer.SetTimeout(sessionPolicy.Timeouts.UplinkOnly)
-		if err := buf.Copy(serverReader, writer, buf.UpdateActivity(timer)); err != nil {
-			return errors.New("failed to deliver response payload").Base(err).AtInfo()
-		}
-		return nil
-	}
-
-	if err := task.Run(ctx, task.OnSuccess(postRequest, task.Close(serverWriter)), task.OnSuccess(getResponse, task.Close(writer))); err != nil {
-		common.Must(common.Interrupt(serverReader))
-		common.Must(common.Interrupt(serverWriter))
-		return errors.New("fallback ends").Base(err).AtInfo()
-	}
-
-	return nil
-}
@@ -0,0 +1,500 @@
+package tuic
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/dice"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/common/uuid"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/tls"
+)
+
+const defaultHeartbeat = 10 * time.Second
+
+func init() {
+	common.Must(common.RegisterConfig((*ClientConfig)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		return NewClient(ctx, config.(*ClientConfig))
+	}))
+}
+
+// Client is an outbound handler for the TUIC v5 protocol. All requests
+// share a single QUIC connection which is re-established on demand.
+type Client struct {
+	server        net.Destination
+	uuid          []byte
+	password      []byte
+	udpRelayMode  UDPRelayMode
+	zeroRTT       bool
+	heartbeat     time.Duration
+	tlsConfig     *tls.Config
+	level         uint32
+	policyManager policy.Manager
+
+	access sync.Mutex
+	conn   *clientConn
+}
+
+// NewClient creates a new TUIC client.
+func NewClient(ctx context.Context, config *ClientConfig) (*Client, error) {
+	if config.Address == nil {
+		return nil, errors.New("tuic: server address is not set")
+	}
+	if config.Port == 0 {
+		return nil, errors.New("tuic: server port is not set")
+	}
+	id, err := uuid.ParseString(config.Uuid)
+	if err != nil {
+		return nil, errors.New("tuic: invalid uuid").Base(err)
+	}
+	c := &Client{
+		server:       net.UDPDestination(config.Address.AsAddress(), net.Port(config.Port)),
+		uuid:         id.Bytes(),
+		password:     []byte(config.Password),
+		udpRelayMode: config.UdpRelayMode,
+		zeroRTT:      config.ZeroRttHandshake,
+		heartbeat:    time.Duration(config.Heartbeat) * time.Second,
+		tlsConfig:    config.TlsSettings,
+		level:        config.Level,
+	}
+	if c.heartbeat == 0 {
+		c.heartbeat = defaultHeartbeat
+	}
+	if c.tlsConfig == nil {
+		c.tlsConfig = &tls.Config{}
+	}
+	v := core.MustFromContext(ctx)
+	c.policyManager = v.GetFeature(policy.ManagerType()).(policy.Manager)
+	return c, nil
+}
+
+// Process implements proxy.Outbound.Process().
+func (c *Client) Process(ctx context.Context, link *transport.Link, dialer internet.Dialer) error {
+	outbounds := session.OutboundsFromContext(ctx)
+	ob := outbounds[len(outbounds)-1]
+	if !ob.Target.IsValid() {
+		return errors.New("target not specified")
+	}
+	ob.Name = "tuic"
+	ob.CanSpliceCopy = 3
+	destination := ob.Target
+
+	conn, err := c.getConn(ctx, dialer)
+	if err != nil {
+		return errors.New("failed to connect to server ", c.server.NetAddr()).Base(err).AtWarning()
+	}
+	errors.LogInfo(ctx, "tunneling request to ", destination, " via ", c.server.NetAddr())
+
+	if session.TimeoutOnlyFromContext(ctx) {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+	}
+
+	sessionPolicy := c.policyManager.ForLevel(c.level)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
+
+	if destination.Network == net.Network_UDP {
+		return c.processUDP(ctx, conn, link, destination, timer, sessionPolicy)
+	}
+	return c.processTCP(ctx, conn, link, destination, timer, sessionPolicy)
+}
+
+func (c *Client) processTCP(ctx context.Context, conn *clientConn, link *transport.Link, destination net.Destination, timer *signal.ActivityTimer, sessionPolicy policy.Session) error {
+	header, err := encodeConnect(destination)
+	if err != nil {
+		return errors.New("failed to encode request").Base(err)
+	}
+	stream, err := conn.quic.OpenStreamSync(ctx)
+	if err != nil {
+		return errors.New("failed to open stream").Base(err)
+	}
+	defer stream.CancelRead(0)
+	defer stream.Close()
+
+	if _, err := stream.Write(header); err != nil {
+		return errors.New("failed to write request").Base(err)
+	}
+
+	postRequest := func() error {
+		defer timer.SetTimeout(sessionPolicy.Timeouts.DownlinkOnly)
+		if err := buf.Copy(link.Reader, buf.NewWriter(stream), buf.UpdateActivity(timer)); err != nil {
+			return errors.New("failed to transfer request payload").Base(err).AtInfo()
+		}
+		return stream.Close()
+	}
+
+	getResponse := func() error {
+		defer timer.SetTimeout(sessionPolicy.Timeouts.UplinkOnly)
+		return buf.Copy(buf.NewReader(stream), link.Writer, buf.UpdateActivity(timer))
+	}
+
+	responseDoneAndCloseWriter := task.OnSuccess(getResponse, task.Close(link.Writer))
+	if err := task.Run(ctx, postRequest, responseDoneAndCloseWriter); err != nil {
+		return errors.New("connection ends").Base(err)
+	}
+	return nil
+}
+
+func (c *Client) processUDP(ctx context.Context, conn *clientConn, link *transport.Link, destination net.Destination, timer *signal.ActivityTimer, sessionPolicy policy.Session) error {
+	s := conn.newAssociation()
+	defer conn.dissociate(s.id)
+
+	postRequest := func() error {
+		defer timer.SetTimeout(sessionPolicy.Timeouts.DownlinkOnly)
+		for {
+			mb, err := link.Reader.ReadMultiBuffer()
+			if err != nil {
+				return err
+			}
+			for _, b := range mb {
+				target := destination
+				if b.UDP != nil {
+					target = *b.UDP
+				}
+				err = conn.sendPacket(ctx, c.udpRelayMode, &packet{
+					AssocID:   s.id,
+					PacketID:  s.nextPacketID(),
+					FragTotal: 1,
+					Dest:      &target,
+					Data:      b.Bytes(),
+				})
+				if err != nil {
+					break
+				}
+			}
+			buf.ReleaseMulti(mb)
+			if err != nil {
+				return errors.New("failed to send packet").Base(err)
+			}
+			timer.Update()
+		}
+	}
+
+	getResponse := func() error {
+		defer timer.SetTimeout(sessionPolicy.Timeouts.UplinkOnly)
+		for {
+			var p *packet
+			select {
+			case p = <-s.ch:
+			case <-s.done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if p.Dest == nil {
+				continue
+			}
+			b := buf.New()
+			b.Write(p.Data)
+			b.UDP = p.Dest
+			if err := link.Writer.WriteMultiBuffer(buf.MultiBuffer{b}); err != nil {
+				return err
+			}
+			timer.Update()
+		}
+	}
+
+	responseDoneAndCloseWriter := task.OnSuccess(getResponse, task.Close(link.Writer))
+	if err := task.Run(ctx, postRequest, responseDoneAndCloseWriter); err != nil {
+		return errors.New("connection ends").Base(err)
+	}
+	return nil
+}
+
+func (c *Client) getConn(ctx context.Context, dialer internet.Dialer) (*clientConn, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.conn != nil && c.conn.quic.Context().Err() == nil {
+		return c.conn, nil
+	}
+	conn, err := c.dial(ctx, dialer)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+func (c *Client) dial(ctx context.Context, dialer internet.Dialer) (*clientConn, error) {
+	rawConn, err := dialer.Dial(ctx, c.server)
+	if err != nil {
+		return nil, err
+	}
+	packetConn, remoteAddr, err := internet.ToPacketConn(rawConn)
+	if err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+
+	tlsConfig := c.tlsConfig.GetTLSConfig(tls.WithDestination(c.server))
+	if len(c.tlsConfig.NextProtocol) == 0 {
+		tlsConfig.NextProtos = []string{"h3"}
+	}
+	quicConfig := &quic.Config{
+		MaxIdleTimeout:  30 * time.Second,
+		KeepAlivePeriod: c.heartbeat,
+		EnableDatagrams: c.udpRelayMode == UDPRelayMode_Native,
+	}
+	var quicConn *quic.Conn
+	if c.zeroRTT {
+		quicConn, err = quic.DialEarly(ctx, packetConn, remoteAddr, tlsConfig, quicConfig)
+	} else {
+		quicConn, err = quic.Dial(ctx, packetConn, remoteAddr, tlsConfig, quicConfig)
+	}
+	if err != nil {
+		rawConn.Close()
+		return nil, errors.New("QUIC handshake failed").Base(err)
+	}
+	go func() {
+		<-quicConn.Context().Done()
+		rawConn.Close()
+	}()
+
+	conn := &clientConn{
+		quic:         quicConn,
+		associations: make(map[uint16]*association),
+	}
+	if c.zeroRTT {
+		// Requests may already be sent as 0-RTT data, the server holds them
+		// until the authentication arrives.
+		go func() {
+			if err := c.authenticate(quicConn); err != nil {
+				errors.LogInfoInner(ctx, err, "tuic: authentication failed")
+				quicConn.CloseWithError(0, "")
+			}
+		}()
+	} else if err := c.authenticate(quicConn); err != nil {
+		quicConn.CloseWithError(0, "")
+		return nil, err
+	}
+
+	if c.udpRelayMode == UDPRelayMode_Native {
+		go conn.heartbeatLoop(c.heartbeat)
+	}
+	go conn.acceptUniStreams()
+	if c.udpRelayMode == UDPRelayMode_Native {
+		go conn.receiveDatagrams()
+	}
+	return conn, nil
+}
+
+// authenticate sends the UUID and a token derived from the TLS exporter,
+// which requires the handshake to be complete.
+func (c *Client) authenticate(quicConn *quic.Conn) error {
+	select {
+	case <-quicConn.HandshakeComplete():
+	case <-quicConn.Context().Done():
+		return quicConn.Context().Err()
+	}
+	state := quicConn.ConnectionState().TLS
+	token, err := state.ExportKeyingMaterial(string(c.uuid), c.password, 32)
+	if err != nil {
+		return errors.New("failed to export keying material").Base(err)
+	}
+	stream, err := quicConn.OpenUniStream()
+	if err != nil {
+		return errors.New("failed to open stream").Base(err)
+	}
+	if _, err := stream.Write(encodeAuthenticate(c.uuid, token)); err != nil {
+		return errors.New("failed to write authentication").Base(err)
+	}
+	return stream.Close()
+}
+
+type association struct {
+	id       uint16
+	ch       chan *packet
+	done     chan struct{}
+	defrag   defragger
+	packetID uint16
+}
+
+func (a *association) nextPacketID() uint16 {
+	a.packetID++
+	return a.packetID
+}
+
+type clientConn struct {
+	quic *quic.Conn
+
+	access       sync.Mutex
+	associations map[uint16]*association
+	nextAssocID  uint16
+}
+
+func (c *clientConn) newAssociation() *association {
+	c.access.Lock()
+	defer c.access.Unlock()
+	for {
+		c.nextAssocID++
+		if _, found := c.associations[c.nextAssocID]; !found {
+			break
+		}
+	}
+	a := &association{
+		id:       c.nextAssocID,
+		ch:       make(chan *packet, 64),
+		done:     make(chan struct{}),
+		packetID: dice.RollUint16(),
+	}
+	c.associations[a.id] = a
+	return a
+}
+
+func (c *clientConn) dissociate(id uint16) {
+	c.access.Lock()
+	a, found := c.associations[id]
+	if found {
+		delete(c.associations, id)
+		close(a.done)
+	}
+	c.access.Unlock()
+	if !found {
+		return
+	}
+	if stream, err := c.quic.OpenUniStream(); err == nil {
+		stream.Write(encodeDissociate(id))
+		stream.Close()
+	}
+}
+
+func (c *clientConn) sendPacket(ctx context.Context, mode UDPRelayMode, p *packet) error {
+	if mode == UDPRelayMode_Quic {
+		b, err := p.marshal()
+		if err != nil {
+			return err
+		}
+		stream, err := c.quic.OpenUniStreamSync(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := stream.Write(b); err != nil {
+			return err
+		}
+		return stream.Close()
+	}
+
+	b, err := p.marshal()
+	if err != nil {
+		return err
+	}
+	err = c.quic.SendDatagram(b)
+	tooLarge, ok := err.(*quic.DatagramTooLargeError)
+	if !ok {
+		return err
+	}
+	frags := fragment(p, int(tooLarge.MaxDatagramPayloadSize))
+	if frags == nil {
+		return errors.New("packet too large: ", len(p.Data))
+	}
+	for _, f := range frags {
+		b, err := f.marshal()
+		if err != nil {
+			return err
+		}
+		if err := c.quic.SendDatagram(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *clientConn) deliver(p *packet) {
+	c.access.Lock()
+	a, found := c.associations[p.AssocID]
+	if found {
+		p = a.defrag.feed(p)
+	}
+	c.access.Unlock()
+	if !found || p == nil {
+		return
+	}
+	select {
+	case a.ch <- p:
+	case <-a.done:
+	default:
+	}
+}
+
+func (c *clientConn) receiveDatagrams() {
+	ctx := c.quic.Context()
+	for {
+		data, err := c.quic.ReceiveDatagram(ctx)
+		if err != nil {
+			return
+		}
+		command, p, err := readCommand(bytes.NewReader(data))
+		if err != nil {
+			errors.LogDebugInner(ctx, err, "tuic: invalid datagram from server")
+			continue
+		}
+		if command == commandPacket {
+			c.deliver(p)
+		}
+	}
+}
+
+func (c *clientConn) acceptUniStreams() {
+	ctx := c.quic.Context()
+	for {
+		stream, err := c.quic.AcceptUniStream(ctx)
+		if err != nil {
+			break
+		}
+		go func() {
+			command, p, err := readCommand(stream)
+			if err != nil {
+				errors.LogDebugInner(ctx, err, "tuic: invalid stream from server")
+				return
+			}
+			if command == commandPacket {
+				c.deliver(p)
+			}
+		}()
+	}
+
+	c.access.Lock()
+	defer c.access.Unlock()
+	for id, a := range c.associations {
+		delete(c.associations, id)
+		close(a.done)
+	}
+}
+
+func (c *clientConn) heartbeatLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	ctx := c.quic.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		c.access.Lock()
+		active := len(c.associations) > 0
+		c.access.Unlock()
+		if !active {
+			continue
+		}
+		// Heartbeats keep NAT bindings of UDP relays alive; TCP relays are
+		// covered by QUIC keep-alive.
+		c.quic.SendDatagram(encodeHeartbeat())
+	}
+}
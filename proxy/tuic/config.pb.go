@@ -0,0 +1,291 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.35.1
+// 	protoc        v5.28.2
+// source: proxy/tuic/config.proto
+
+package tuic
+
+import (
+	net "github.com/xtls/xray-core/common/net"
+	tls "github.com/xtls/xray-core/transport/internet/tls"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type UDPRelayMode int32
+
+const (
+	// UDP packets are carried in QUIC datagrams.
+	UDPRelayMode_Native UDPRelayMode = 0
+	// UDP packets are carried in unidirectional QUIC streams.
+	UDPRelayMode_Quic UDPRelayMode = 1
+)
+
+// Enum value maps for UDPRelayMode.
+var (
+	UDPRelayMode_name = map[int32]string{
+		0: "Native",
+		1: "Quic",
+	}
+	UDPRelayMode_value = map[string]int32{
+		"Native": 0,
+		"Quic":   1,
+	}
+)
+
+func (x UDPRelayMode) Enum() *UDPRelayMode {
+	p := new(UDPRelayMode)
+	*p = x
+	return p
+}
+
+func (x UDPRelayMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (UDPRelayMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_proxy_tuic_config_proto_enumTypes[0].Descriptor()
+}
+
+func (UDPRelayMode) Type() protoreflect.EnumType {
+	return &file_proxy_tuic_config_proto_enumTypes[0]
+}
+
+func (x UDPRelayMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use UDPRelayMode.Descriptor instead.
+func (UDPRelayMode) EnumDescriptor() ([]byte, []int) {
+	return file_proxy_tuic_config_proto_rawDescGZIP(), []int{0}
+}
+
+type ClientConfig struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Address          *net.IPOrDomain `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Port             uint32          `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
+	Uuid             string          `protobuf:"bytes,3,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Password         string          `protobuf:"bytes,4,opt,name=password,proto3" json:"password,omitempty"`
+	UdpRelayMode     UDPRelayMode    `protobuf:"varint,5,opt,name=udp_relay_mode,json=udpRelayMode,proto3,enum=xray.proxy.tuic.UDPRelayMode" json:"udp_relay_mode,omitempty"`
+	ZeroRttHandshake bool            `protobuf:"varint,6,opt,name=zero_rtt_handshake,json=zeroRttHandshake,proto3" json:"zero_rtt_handshake,omitempty"`
+	// Interval between heartbeats in seconds, 0 means the default.
+	Heartbeat   uint32      `protobuf:"varint,7,opt,name=heartbeat,proto3" json:"heartbeat,omitempty"`
+	TlsSettings *tls.Config `protobuf:"bytes,8,opt,name=tls_settings,json=tlsSettings,proto3" json:"tls_settings,omitempty"`
+	Level       uint32      `protobuf:"varint,9,opt,name=level,proto3" json:"level,omitempty"`
+	Email       string      `protobuf:"bytes,10,opt,name=email,proto3" json:"email,omitempty"`
+}
+
+func (x *ClientConfig) Reset() {
+	*x = ClientConfig{}
+	mi := &file_proxy_tuic_config_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientConfig) ProtoMessage() {}
+
+func (x *ClientConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_proxy_tuic_config_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientConfig.ProtoReflect.Descriptor instead.
+func (*ClientConfig) Descriptor() ([]byte, []int) {
+	return file_proxy_tuic_config_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ClientConfig) GetAddress() *net.IPOrDomain {
+	if x != nil {
+		return x.Address
+	}
+	return nil
+}
+
+func (x *ClientConfig) GetPort() uint32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *ClientConfig) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *ClientConfig) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *ClientConfig) GetUdpRelayMode() UDPRelayMode {
+	if x != nil {
+		return x.UdpRelayMode
+	}
+	return UDPRelayMode_Native
+}
+
+func (x *ClientConfig) GetZeroRttHandshake() bool {
+	if x != nil {
+		return x.ZeroRttHandshake
+	}
+	return false
+}
+
+func (x *ClientConfig) GetHeartbeat() uint32 {
+	if x != nil {
+		return x.Heartbeat
+	}
+	return 0
+}
+
+func (x *ClientConfig) GetTlsSettings() *tls.Config {
+	if x != nil {
+		return x.TlsSettings
+	}
+	return nil
+}
+
+func (x *ClientConfig) GetLevel() uint32 {
+	if x != nil {
+		return x.Level
+	}
+	return 0
+}
+
+func (x *ClientConfig) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+var File_proxy_tuic_config_proto protoreflect.FileDescriptor
+
+var file_proxy_tuic_config_proto_rawDesc = []byte{
+	0x0a, 0x17, 0x70, 0x72, 0x6f, 0x78, 0x79, 0x2f, 0x74, 0x75, 0x69, 0x63, 0x2f, 0x63, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0f, 0x78, 0x72, 0x61, 0x79, 0x2e,
+	0x70, 0x72, 0x6f, 0x78, 0x79, 0x2e, 0x74, 0x75, 0x69, 0x63, 0x1a, 0x18, 0x63, 0x6f, 0x6d, 0x6d,
+	0x6f, 0x6e, 0x2f, 0x6e, 0x65, 0x74, 0x2f, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x23, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2f,
+	0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x65, 0x74, 0x2f, 0x74, 0x6c, 0x73, 0x2f, 0x63, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x8e, 0x03, 0x0a, 0x0c, 0x43, 0x6c,
+	0x69, 0x65, 0x6e, 0x74, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x35, 0x0a, 0x07, 0x61, 0x64,
+	0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x78, 0x72,
+	0x61, 0x79, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x6e, 0x65, 0x74, 0x2e, 0x49, 0x50,
+	0x4f, 0x72, 0x44, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73,
+	0x73, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x04, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x75, 0x75, 0x69, 0x64, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x75, 0x75, 0x69, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x61, 0x73,
+	0x73, 0x77, 0x6f, 0x72, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x61, 0x73,
+	0x73, 0x77, 0x6f, 0x72, 0x64, 0x12, 0x43, 0x0a, 0x0e, 0x75, 0x64, 0x70, 0x5f, 0x72, 0x65, 0x6c,
+	0x61, 0x79, 0x5f, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1d, 0x2e,
+	0x78, 0x72, 0x61, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x78, 0x79, 0x2e, 0x74, 0x75, 0x69, 0x63, 0x2e,
+	0x55, 0x44, 0x50, 0x52, 0x65, 0x6c, 0x61, 0x79, 0x4d, 0x6f, 0x64, 0x65, 0x52, 0x0c, 0x75, 0x64,
+	0x70, 0x52, 0x65, 0x6c, 0x61, 0x79, 0x4d, 0x6f, 0x64, 0x65, 0x12, 0x2c, 0x0a, 0x12, 0x7a, 0x65,
+	0x72, 0x6f, 0x5f, 0x72, 0x74, 0x74, 0x5f, 0x68, 0x61, 0x6e, 0x64, 0x73, 0x68, 0x61, 0x6b, 0x65,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x10, 0x7a, 0x65, 0x72, 0x6f, 0x52, 0x74, 0x74, 0x48,
+	0x61, 0x6e, 0x64, 0x73, 0x68, 0x61, 0x6b, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x68, 0x65, 0x61, 0x72,
+	0x74, 0x62, 0x65, 0x61, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x68, 0x65, 0x61,
+	0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x12, 0x46, 0x0a, 0x0c, 0x74, 0x6c, 0x73, 0x5f, 0x73, 0x65,
+	0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x78,
+	0x72, 0x61, 0x79, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2e, 0x69, 0x6e,
+	0x74, 0x65, 0x72, 0x6e, 0x65, 0x74, 0x2e, 0x74, 0x6c, 0x73, 0x2e, 0x43, 0x6f, 0x6e, 0x66, 0x69,
+	0x67, 0x52, 0x0b, 0x74, 0x6c, 0x73, 0x53, 0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x12, 0x14,
+	0x0a, 0x05, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x6c,
+	0x65, 0x76, 0x65, 0x6c, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x6d, 0x61, 0x69, 0x6c, 0x18, 0x0a, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x6d, 0x61, 0x69, 0x6c, 0x2a, 0x24, 0x0a, 0x0c, 0x55, 0x44,
+	0x50, 0x52, 0x65, 0x6c, 0x61, 0x79, 0x4d, 0x6f, 0x64, 0x65, 0x12, 0x0a, 0x0a, 0x06, 0x4e, 0x61,
+	0x74, 0x69, 0x76, 0x65, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x51, 0x75, 0x69, 0x63, 0x10, 0x01,
+	0x42, 0x4f, 0x0a, 0x13, 0x63, 0x6f, 0x6d, 0x2e, 0x78, 0x72, 0x61, 0x79, 0x2e, 0x70, 0x72, 0x6f,
+	0x78, 0x79, 0x2e, 0x74, 0x75, 0x69, 0x63, 0x50, 0x01, 0x5a, 0x24, 0x67, 0x69, 0x74, 0x68, 0x75,
+	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x78, 0x74, 0x6c, 0x73, 0x2f, 0x78, 0x72, 0x61, 0x79, 0x2d,
+	0x63, 0x6f, 0x72, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x78, 0x79, 0x2f, 0x74, 0x75, 0x69, 0x63, 0xaa,
+	0x02, 0x0f, 0x58, 0x72, 0x61, 0x79, 0x2e, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x2e, 0x54, 0x75, 0x69,
+	0x63, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_proxy_tuic_config_proto_rawDescOnce sync.Once
+	file_proxy_tuic_config_proto_rawDescData = file_proxy_tuic_config_proto_rawDesc
+)
+
+func file_proxy_tuic_config_proto_rawDescGZIP() []byte {
+	file_proxy_tuic_config_proto_rawDescOnce.Do(func() {
+		file_proxy_tuic_config_proto_rawDescData = protoimpl.X.CompressGZIP(file_proxy_tuic_config_proto_rawDescData)
+	})
+	return file_proxy_tuic_config_proto_rawDescData
+}
+
+var file_proxy_tuic_config_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_proxy_tuic_config_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_proxy_tuic_config_proto_goTypes = []any{
+	(UDPRelayMode)(0),      // 0: xray.proxy.tuic.UDPRelayMode
+	(*ClientConfig)(nil),   // 1: xray.proxy.tuic.ClientConfig
+	(*net.IPOrDomain)(nil), // 2: xray.common.net.IPOrDomain
+	(*tls.Config)(nil),     // 3: xray.transport.internet.tls.Config
+}
+var file_proxy_tuic_config_proto_depIdxs = []int32{
+	2, // 0: xray.proxy.tuic.ClientConfig.address:type_name -> xray.common.net.IPOrDomain
+	0, // 1: xray.proxy.tuic.ClientConfig.udp_relay_mode:type_name -> xray.proxy.tuic.UDPRelayMode
+	3, // 2: xray.proxy.tuic.ClientConfig.tls_settings:type_name -> xray.transport.internet.tls.Config
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_proxy_tuic_config_proto_init() }
+func file_proxy_tuic_config_proto_init() {
+	if File_proxy_tuic_config_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_proxy_tuic_config_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_proxy_tuic_config_proto_goTypes,
+		DependencyIndexes: file_proxy_tuic_config_proto_depIdxs,
+		EnumInfos:         file_proxy_tuic_config_proto_enumTypes,
+		MessageInfos:      file_proxy_tuic_config_proto_msgTypes,
+	}.Build()
+	File_proxy_tuic_config_proto = out.File
+	file_proxy_tuic_config_proto_rawDesc = nil
+	file_proxy_tuic_config_proto_goTypes = nil
+	file_proxy_tuic_config_proto_depIdxs = nil
+}
@@ -0,0 +1,231 @@
+package tuic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+)
+
+const (
+	version = 0x05
+
+	commandAuthenticate = 0x00
+	commandConnect      = 0x01
+	commandPacket       = 0x02
+	commandDissociate   = 0x03
+	commandHeartbeat    = 0x04
+
+	addressTypeNone = 0xff
+
+	// VER + TYPE + ASSOC_ID + PKT_ID + FRAG_TOTAL + FRAG_ID + SIZE
+	packetHeaderSize = 2 + 2 + 2 + 1 + 1 + 2
+)
+
+var addrParser = protocol.NewAddressParser(
+	protocol.AddressFamilyByte(0x00, net.AddressFamilyDomain),
+	protocol.AddressFamilyByte(0x01, net.AddressFamilyIPv4),
+	protocol.AddressFamilyByte(0x02, net.AddressFamilyIPv6),
+)
+
+func encodeAuthenticate(uuid []byte, token []byte) []byte {
+	b := make([]byte, 0, 2+len(uuid)+len(token))
+	b = append(b, version, commandAuthenticate)
+	b = append(b, uuid...)
+	return append(b, token...)
+}
+
+func encodeConnect(dest net.Destination) ([]byte, error) {
+	b := bytes.NewBuffer([]byte{version, commandConnect})
+	if err := addrParser.WriteAddressPort(b, dest.Address, dest.Port); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func encodeDissociate(assocID uint16) []byte {
+	b := []byte{version, commandDissociate, 0, 0}
+	binary.BigEndian.PutUint16(b[2:], assocID)
+	return b
+}
+
+func encodeHeartbeat() []byte {
+	return []byte{version, commandHeartbeat}
+}
+
+// packet is one fragment of a relayed UDP packet. Only the first fragment
+// carries the address.
+type packet struct {
+	AssocID   uint16
+	PacketID  uint16
+	FragTotal uint8
+	FragID    uint8
+	Dest      *net.Destination
+	Data      []byte
+}
+
+func (p *packet) marshal() ([]byte, error) {
+	b := bytes.NewBuffer(make([]byte, packetHeaderSize, packetHeaderSize+1+256+2+len(p.Data)))
+	h := b.Bytes()
+	h[0] = version
+	h[1] = commandPacket
+	binary.BigEndian.PutUint16(h[2:], p.AssocID)
+	binary.BigEndian.PutUint16(h[4:], p.PacketID)
+	h[6] = p.FragTotal
+	h[7] = p.FragID
+	binary.BigEndian.PutUint16(h[8:], uint16(len(p.Data)))
+	if p.Dest == nil {
+		b.WriteByte(addressTypeNone)
+	} else if err := addrParser.WriteAddressPort(b, p.Dest.Address, p.Dest.Port); err != nil {
+		return nil, err
+	}
+	b.Write(p.Data)
+	return b.Bytes(), nil
+}
+
+func (p *packet) addressSize() int {
+	if p.Dest == nil {
+		return 1
+	}
+	switch p.Dest.Address.Family() {
+	case net.AddressFamilyIPv4:
+		return 1 + 4 + 2
+	case net.AddressFamilyIPv6:
+		return 1 + 16 + 2
+	default:
+		return 1 + 1 + len(p.Dest.Address.Domain()) + 2
+	}
+}
+
+// fragment splits p into fragments whose encoded size does not exceed maxSize.
+func fragment(p *packet, maxSize int) []*packet {
+	if packetHeaderSize+p.addressSize()+len(p.Data) <= maxSize {
+		return []*packet{p}
+	}
+	// Leave room for the address in the first fragment; the others carry none.
+	chunk := maxSize - packetHeaderSize - p.addressSize()
+	if chunk <= 0 {
+		return nil
+	}
+	count := (len(p.Data) + chunk - 1) / chunk
+	if count > 255 {
+		return nil
+	}
+	frags := make([]*packet, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * chunk
+		if end > len(p.Data) {
+			end = len(p.Data)
+		}
+		f := &packet{
+			AssocID:   p.AssocID,
+			PacketID:  p.PacketID,
+			FragTotal: uint8(count),
+			FragID:    uint8(i),
+			Data:      p.Data[i*chunk : end],
+		}
+		if i == 0 {
+			f.Dest = p.Dest
+		}
+		frags = append(frags, f)
+	}
+	return frags
+}
+
+// readCommand reads a packet command from r. Other commands are reported
+// with a nil packet.
+func readCommand(r io.Reader) (byte, *packet, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	if header[0] != version {
+		return 0, nil, errors.New("unknown version: ", header[0])
+	}
+	if header[1] != commandPacket {
+		return header[1], nil, nil
+	}
+	var h [packetHeaderSize - 2]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return 0, nil, err
+	}
+	p := &packet{
+		AssocID:   binary.BigEndian.Uint16(h[0:]),
+		PacketID:  binary.BigEndian.Uint16(h[2:]),
+		FragTotal: h[4],
+		FragID:    h[5],
+	}
+	size := binary.BigEndian.Uint16(h[6:])
+	var addrType [1]byte
+	if _, err := io.ReadFull(r, addrType[:]); err != nil {
+		return 0, nil, err
+	}
+	if addrType[0] != addressTypeNone {
+		b := buf.New()
+		defer b.Release()
+		addr, port, err := addrParser.ReadAddressPort(b, io.MultiReader(bytes.NewReader(addrType[:]), r))
+		if err != nil {
+			return 0, nil, errors.New("failed to read address").Base(err)
+		}
+		dest := net.UDPDestination(addr, port)
+		p.Dest = &dest
+	}
+	p.Data = make([]byte, size)
+	if _, err := io.ReadFull(r, p.Data); err != nil {
+		return 0, nil, err
+	}
+	return commandPacket, p, nil
+}
+
+// defragger reassembles fragments of the most recent packet of an association.
+type defragger struct {
+	packetID uint16
+	frags    [][]byte
+	dest     *net.Destination
+	count    int
+	size     int
+}
+
+func (d *defragger) feed(p *packet) *packet {
+	if p.FragTotal <= 1 {
+		return p
+	}
+	if p.FragID >= p.FragTotal {
+		return nil
+	}
+	if d.frags == nil || p.PacketID != d.packetID || len(d.frags) != int(p.FragTotal) {
+		d.packetID = p.PacketID
+		d.frags = make([][]byte, p.FragTotal)
+		d.dest = nil
+		d.count = 0
+		d.size = 0
+	}
+	if d.frags[p.FragID] != nil {
+		return nil
+	}
+	if p.Dest != nil {
+		d.dest = p.Dest
+	}
+	d.frags[p.FragID] = p.Data
+	d.count++
+	d.size += len(p.Data)
+	if d.count < len(d.frags) {
+		return nil
+	}
+	data := make([]byte, 0, d.size)
+	for _, f := range d.frags {
+		data = append(data, f...)
+	}
+	d.frags = nil
+	return &packet{
+		AssocID:   p.AssocID,
+		PacketID:  p.PacketID,
+		FragTotal: 1,
+		Dest:      d.dest,
+		Data:      data,
+	}
+}
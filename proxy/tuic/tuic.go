@@ -0,0 +1,2 @@
+// Package tuic implements the outbound of the TUIC v5 protocol, over QUIC.
+package tuic
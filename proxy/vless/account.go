@@ -18,6 +18,7 @@ func (a *Account) AsAccount() (protocol.Account, error) {
 		ID:         protocol.NewID(id),
 		Flow:       a.Flow,       // needs parser here?
 		Encryption: a.Encryption, // needs parser here?
+		Padding:    a.Padding,
 	}, nil
 }
 
@@ -29,6 +30,8 @@ type MemoryAccount struct {
 	Flow string
 	// Encryption of the account. Used for client connections, and only accepts "none" for now.
 	Encryption string
+	// Padding is the name of the padding profile to ask for. Used for client connections.
+	Padding string
 }
 
 // Equals implements protocol.Account.Equals().
@@ -45,5 +48,6 @@ func (a *MemoryAccount) ToProto() proto.Message {
 		Id:         a.ID.String(),
 		Flow:       a.Flow,
 		Encryption: a.Encryption,
+		Padding:    a.Padding,
 	}
 }
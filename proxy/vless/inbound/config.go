@@ -1 +1,16 @@
 package inbound
+
+import (
+	"github.com/xtls/xray-core/proxy/fallback"
+)
+
+// AsFallback returns fb as a fallback.Fallback, with dest before the backups.
+func (fb *Fallback) AsFallback() *fallback.Fallback {
+	return &fallback.Fallback{
+		Name: fb.Name,
+		Alpn: fb.Alpn,
+		Path: fb.Path,
+		Dest: append([]*fallback.Dest{{Type: fb.Type, Dest: fb.Dest}}, fb.Backup...),
+		Xver: fb.Xver,
+	}
+}
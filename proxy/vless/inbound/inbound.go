@@ -5,11 +5,8 @@ import (
 	"context"
 	gotls "crypto/tls"
 	"io"
-	"reflect"
-	"strconv"
-	"strings"
+	"sync/atomic"
 	"time"
-	"unsafe"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
@@ -17,7 +14,6 @@ import (
 	"github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
-	"github.com/xtls/xray-core/common/retry"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal"
 	"github.com/xtls/xray-core/common/task"
@@ -27,6 +23,7 @@ import (
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
 	"github.com/xtls/xray-core/proxy"
+	"github.com/xtls/xray-core/proxy/fallback"
 	"github.com/xtls/xray-core/proxy/vless"
 	"github.com/xtls/xray-core/proxy/vless/encoding"
 	"github.com/xtls/xray-core/transport/internet/reality"
@@ -67,78 +64,34 @@ type Handler struct {
 	policyManager         policy.Manager
 	validator             vless.Validator
 	dns                   dns.Client
-	fallbacks             map[string]map[string]map[string]*Fallback // or nil
-	// regexps               map[string]*regexp.Regexp       // or nil
+	fallbackCheck         *fallback.HealthCheck
+	fallbacks             atomic.Pointer[fallback.Table] // or nil
+	paddings              vless.Paddings
 }
 
 // New creates a new VLess inbound handler.
 func New(ctx context.Context, config *Config, dc dns.Client, validator vless.Validator) (*Handler, error) {
+	paddings, err := vless.NewPaddings(config.PaddingProfiles)
+	if err != nil {
+		return nil, err
+	}
+
 	v := core.MustFromContext(ctx)
 	handler := &Handler{
 		inboundHandlerManager: v.GetFeature(feature_inbound.ManagerType()).(feature_inbound.Manager),
 		policyManager:         v.GetFeature(policy.ManagerType()).(policy.Manager),
 		dns:                   dc,
 		validator:             validator,
+		fallbackCheck:         config.FallbackCheck,
+		paddings:              paddings,
 	}
 
-	if config.Fallbacks != nil {
-		handler.fallbacks = make(map[string]map[string]map[string]*Fallback)
-		// handler.regexps = make(map[string]*regexp.Regexp)
-		for _, fb := range config.Fallbacks {
-			if handler.fallbacks[fb.Name] == nil {
-				handler.fallbacks[fb.Name] = make(map[string]map[string]*Fallback)
-			}
-			if handler.fallbacks[fb.Name][fb.Alpn] == nil {
-				handler.fallbacks[fb.Name][fb.Alpn] = make(map[string]*Fallback)
-			}
-			handler.fallbacks[fb.Name][fb.Alpn][fb.Path] = fb
-			/*
-				if fb.Path != "" {
-					if r, err := regexp.Compile(fb.Path); err != nil {
-						return nil, errors.New("invalid path regexp").Base(err).AtError()
-					} else {
-						handler.regexps[fb.Path] = r
-					}
-				}
-			*/
-		}
-		if handler.fallbacks[""] != nil {
-			for name, apfb := range handler.fallbacks {
-				if name != "" {
-					for alpn := range handler.fallbacks[""] {
-						if apfb[alpn] == nil {
-							apfb[alpn] = make(map[string]*Fallback)
-						}
-					}
-				}
-			}
-		}
-		for _, apfb := range handler.fallbacks {
-			if apfb[""] != nil {
-				for alpn, pfb := range apfb {
-					if alpn != "" { // && alpn != "h2" {
-						for path, fb := range apfb[""] {
-							if pfb[path] == nil {
-								pfb[path] = fb
-							}
-						}
-					}
-				}
-			}
-		}
-		if handler.fallbacks[""] != nil {
-			for name, apfb := range handler.fallbacks {
-				if name != "" {
-					for alpn, pfb := range handler.fallbacks[""] {
-						for path, fb := range pfb {
-							if apfb[alpn][path] == nil {
-								apfb[alpn][path] = fb
-							}
-						}
-					}
-				}
-			}
-		}
+	fallbacks := make([]*fallback.Fallback, 0, len(config.Fallbacks))
+	for _, fb := range config.Fallbacks {
+		fallbacks = append(fallbacks, fb.AsFallback())
+	}
+	if err := handler.SetFallbacks(ctx, fallbacks); err != nil {
+		return nil, err
 	}
 
 	return handler, nil
@@ -159,7 +112,16 @@ func isMuxAndNotXUDP(request *protocol.RequestHeader, first *buf.Buffer) bool {
 
 // Close implements common.Closable.Close().
 func (h *Handler) Close() error {
-	return errors.Combine(common.Close(h.validator))
+	return errors.Combine(common.Close(h.validator), h.fallbacks.Load().Close())
+}
+
+// SetFallbacks implements proxy.FallbackManager.SetFallbacks().
+func (h *Handler) SetFallbacks(ctx context.Context, fallbacks []*fallback.Fallback) error {
+	table, err := fallback.NewTable(h.inboundHandlerManager, fallbacks, h.fallbackCheck)
+	if err != nil {
+		return errors.New("failed to set fallbacks").Base(err)
+	}
+	return h.fallbacks.Swap(table).Close()
 }
 
 // AddUser implements proxy.UserManager.AddUser().
@@ -221,8 +183,8 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 	var requestAddons *encoding.Addons
 	var err error
 
-	napfb := h.fallbacks
-	isfb := napfb != nil
+	fallbacks := h.fallbacks.Load()
+	isfb := fallbacks != nil
 
 	if isfb && firstLen < 18 {
 		err = errors.New("fallback directly")
@@ -232,204 +194,7 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 
 	if err != nil {
 		if isfb {
-			if err := connection.SetReadDeadline(time.Time{}); err != nil {
-				errors.LogWarningInner(ctx, err, "unable to set back read deadline")
-			}
-			errors.LogInfoInner(ctx, err, "fallback starts")
-
-			name := ""
-			alpn := ""
-			if tlsConn, ok := iConn.(*tls.Conn); ok {
-				cs := tlsConn.ConnectionState()
-				name = cs.ServerName
-				alpn = cs.NegotiatedProtocol
-				errors.LogInfo(ctx, "realName = "+name)
-				errors.LogInfo(ctx, "realAlpn = "+alpn)
-			} else if realityConn, ok := iConn.(*reality.Conn); ok {
-				cs := realityConn.ConnectionState()
-				name = cs.ServerName
-				alpn = cs.NegotiatedProtocol
-				errors.LogInfo(ctx, "realName = "+name)
-				errors.LogInfo(ctx, "realAlpn = "+alpn)
-			}
-			name = strings.ToLower(name)
-			alpn = strings.ToLower(alpn)
-
-			if len(napfb) > 1 || napfb[""] == nil {
-				if name != "" && napfb[name] == nil {
-					match := ""
-					for n := range napfb {
-						if n != "" && strings.Contains(name, n) && len(n) > len(match) {
-							match = n
-						}
-					}
-					name = match
-				}
-			}
-
-			if napfb[name] == nil {
-				name = ""
-			}
-			apfb := napfb[name]
-			if apfb == nil {
-				return errors.New(`failed to find the default "name" config`).AtWarning()
-			}
-
-			if apfb[alpn] == nil {
-				alpn = ""
-			}
-			pfb := apfb[alpn]
-			if pfb == nil {
-				return errors.New(`failed to find the default "alpn" config`).AtWarning()
-			}
-
-			path := ""
-			if len(pfb) > 1 || pfb[""] == nil {
-				/*
-					if lines := bytes.Split(firstBytes, []byte{'\r', '\n'}); len(lines) > 1 {
-						if s := bytes.Split(lines[0], []byte{' '}); len(s) == 3 {
-							if len(s[0]) < 8 && len(s[1]) > 0 && len(s[2]) == 8 {
-								errors.New("realPath = " + string(s[1])).AtInfo().WriteToLog(sid)
-								for _, fb := range pfb {
-									if fb.Path != "" && h.regexps[fb.Path].Match(s[1]) {
-										path = fb.Path
-										break
-									}
-								}
-							}
-						}
-					}
-				*/
-				if firstLen >= 18 && first.Byte(4) != '*' { // not h2c
-					firstBytes := first.Bytes()
-					for i := 4; i <= 8; i++ { // 5 -> 9
-						if firstBytes[i] == '/' && firstBytes[i-1] == ' ' {
-							search := len(firstBytes)
-							if search > 64 {
-								search = 64 // up to about 60
-							}
-							for j := i + 1; j < search; j++ {
-								k := firstBytes[j]
-								if k == '\r' || k == '\n' { // avoid logging \r or \n
-									break
-								}
-								if k == '?' || k == ' ' {
-									path = string(firstBytes[i:j])
-									errors.LogInfo(ctx, "realPath = "+path)
-									if pfb[path] == nil {
-										path = ""
-									}
-									break
-								}
-							}
-							break
-						}
-					}
-				}
-			}
-			fb := pfb[path]
-			if fb == nil {
-				return errors.New(`failed to find the default "path" config`).AtWarning()
-			}
-
-			ctx, cancel := context.WithCancel(ctx)
-			timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
-			ctx = policy.ContextWithBufferPolicy(ctx, sessionPolicy.Buffer)
-
-			var conn net.Conn
-			if err := retry.ExponentialBackoff(5, 100).On(func() error {
-				var dialer net.Dialer
-				conn, err = dialer.DialContext(ctx, fb.Type, fb.Dest)
-				if err != nil {
-					return err
-				}
-				return nil
-			}); err != nil {
-				return errors.New("failed to dial to " + fb.Dest).Base(err).AtWarning()
-			}
-			defer conn.Close()
-
-			serverReader := buf.NewReader(conn)
-			serverWriter := buf.NewWriter(conn)
-
-			postRequest := func() error {
-				defer timer.SetTimeout(sessionPolicy.Timeouts.DownlinkOnly)
-				if fb.Xver != 0 {
-					ipType := 4
-					remoteAddr, remotePort, err := net.SplitHostPort(connection.RemoteAddr().String())
-					if err != nil {
-						ipType = 0
-					}
-					localAddr, localPort, err := net.SplitHostPort(connection.LocalAddr().String())
-					if err != nil {
-						ipType = 0
-					}
-					if ipType == 4 {
-						for i := 0; i < len(remoteAddr); i++ {
-							if remoteAddr[i] == ':' {
-								ipType = 6
-								break
-							}
-						}
-					}
-					pro := buf.New()
-					defer pro.Release()
-					switch fb.Xver {
-					case 1:
-						if ipType == 0 {
-							pro.Write([]byte("PROXY UNKNOWN\r\n"))
-							break
-						}
-						if ipType == 4 {
-							pro.Write([]byte("PROXY TCP4 " + remoteAddr + " " + localAddr + " " + remotePort + " " + localPort + "\r\n"))
-						} else {
-							pro.Write([]byte("PROXY TCP6 " + remoteAddr + " " + localAddr + " " + remotePort + " " + localPort + "\r\n"))
-						}
-					case 2:
-						pro.Write([]byte("\x0D\x0A\x0D\x0A\x00\x0D\x0A\x51\x55\x49\x54\x0A")) // signature
-						if ipType == 0 {
-							pro.Write([]byte("\x20\x00\x00\x00")) // v2 + LOCAL + UNSPEC + UNSPEC + 0 bytes
-							break
-						}
-						if ipType == 4 {
-							pro.Write([]byte("\x21\x11\x00\x0C")) // v2 + PROXY + AF_INET + STREAM + 12 bytes
-							pro.Write(net.ParseIP(remoteAddr).To4())
-							pro.Write(net.ParseIP(localAddr).To4())
-						} else {
-							pro.Write([]byte("\x21\x21\x00\x24")) // v2 + PROXY + AF_INET6 + STREAM + 36 bytes
-							pro.Write(net.ParseIP(remoteAddr).To16())
-							pro.Write(net.ParseIP(localAddr).To16())
-						}
-						p1, _ := strconv.ParseUint(remotePort, 10, 16)
-						p2, _ := strconv.ParseUint(localPort, 10, 16)
-						pro.Write([]byte{byte(p1 >> 8), byte(p1), byte(p2 >> 8), byte(p2)})
-					}
-					if err := serverWriter.WriteMultiBuffer(buf.MultiBuffer{pro}); err != nil {
-						return errors.New("failed to set PROXY protocol v", fb.Xver).Base(err).AtWarning()
-					}
-				}
-				if err := buf.Copy(reader, serverWriter, buf.UpdateActivity(timer)); err != nil {
-					return errors.New("failed to fallback request payload").Base(err).AtInfo()
-				}
-				return nil
-			}
-
-			writer := buf.NewWriter(connection)
-
-			getResponse := func() error {
-				defer timer.SetTimeout(sessionPolicy.Timeouts.UplinkOnly)
-				if err := buf.Copy(serverReader, writer, buf.UpdateActivity(timer)); err != nil {
-					return errors.New("failed to deliver response payload").Base(err).AtInfo()
-				}
-				return nil
-			}
-
-			if err := task.Run(ctx, task.OnSuccess(postRequest, task.Close(serverWriter)), task.OnSuccess(getResponse, task.Close(writer))); err != nil {
-				common.Interrupt(serverReader)
-				common.Interrupt(serverWriter)
-				return errors.New("fallback ends").Base(err).AtInfo()
-			}
-			return nil
+			return fallbacks.Serve(ctx, err, sessionPolicy, connection, iConn, first, firstLen, reader)
 		}
 
 		if errors.Cause(err) != io.EOF {
@@ -439,6 +204,7 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 				Status: log.AccessRejected,
 				Reason: err,
 			})
+			proxy.AuthFailed(ctx)
 			err = errors.New("invalid request from ", connection.RemoteAddr()).Base(err).AtInfo()
 		}
 		return err
@@ -474,24 +240,17 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 			case protocol.RequestCommandMux:
 				fallthrough // we will break Mux connections that contain TCP requests
 			case protocol.RequestCommandTCP:
-				var t reflect.Type
-				var p uintptr
 				if tlsConn, ok := iConn.(*tls.Conn); ok {
 					if tlsConn.ConnectionState().Version != gotls.VersionTLS13 {
 						return errors.New(`failed to use `+requestAddons.Flow+`, found outer tls version `, tlsConn.ConnectionState().Version).AtWarning()
 					}
-					t = reflect.TypeOf(tlsConn.Conn).Elem()
-					p = uintptr(unsafe.Pointer(tlsConn.Conn))
-				} else if realityConn, ok := iConn.(*reality.Conn); ok {
-					t = reflect.TypeOf(realityConn.Conn).Elem()
-					p = uintptr(unsafe.Pointer(realityConn.Conn))
-				} else {
+				} else if _, ok := iConn.(*reality.Conn); !ok {
 					return errors.New("XTLS only supports TLS and REALITY directly for now.").AtWarning()
 				}
-				i, _ := t.FieldByName("input")
-				r, _ := t.FieldByName("rawInput")
-				input = (*bytes.Reader)(unsafe.Pointer(p + i.Offset))
-				rawInput = (*bytes.Buffer)(unsafe.Pointer(p + r.Offset))
+				var err error
+				if input, rawInput, err = proxy.XtlsInput(iConn); err != nil {
+					return err
+				}
 			}
 		} else {
 			return errors.New("account " + account.ID.String() + " is not able to use the flow " + requestAddons.Flow).AtWarning()
@@ -531,6 +290,13 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 	serverReader := link.Reader // .(*pipe.Reader)
 	serverWriter := link.Writer // .(*pipe.Writer)
 	trafficState := proxy.NewTrafficState(account.ID.Bytes())
+	if requestAddons.Padding != "" {
+		if padding, found := h.paddings[requestAddons.Padding]; found {
+			trafficState.Padding = padding
+		} else {
+			errors.LogInfo(ctx, "unknown padding profile ", requestAddons.Padding, ", padding by default")
+		}
+	}
 	postRequest := func() error {
 		defer timer.SetTimeout(sessionPolicy.Timeouts.DownlinkOnly)
 
@@ -542,7 +308,7 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 		if requestAddons.Flow == vless.XRV {
 			ctx1 := session.ContextWithInbound(ctx, nil) // TODO enable splice
 			clientReader = proxy.NewVisionReader(clientReader, trafficState, true, ctx1)
-			err = encoding.XtlsRead(clientReader, serverWriter, timer, connection, input, rawInput, trafficState, nil, true, ctx1)
+			err = proxy.XtlsRead(clientReader, serverWriter, timer, connection, input, rawInput, trafficState, nil, true, ctx1)
 		} else {
 			// from clientReader.ReadMultiBuffer to serverWriter.WriteMultiBuffer
 			err = buf.Copy(clientReader, serverWriter, buf.UpdateActivity(timer))
@@ -579,7 +345,7 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 
 		var err error
 		if requestAddons.Flow == vless.XRV {
-			err = encoding.XtlsWrite(serverReader, clientWriter, timer, connection, trafficState, nil, false, ctx)
+			err = proxy.XtlsWrite(serverReader, clientWriter, timer, connection, trafficState, nil, false, ctx)
 		} else {
 			// from serverReader.ReadMultiBuffer to clientWriter.WriteMultiBuffer
 			err = buf.Copy(serverReader, clientWriter, buf.UpdateActivity(timer))
@@ -0,0 +1,45 @@
+package vless
+
+import (
+	"time"
+
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/proxy"
+)
+
+// AsPadding returns the Vision padding of p.
+func (p *PaddingProfile) AsPadding() *proxy.Padding {
+	return &proxy.Padding{
+		LongMin:        p.LongMin,
+		LongMax:        p.LongMax,
+		ShortMax:       p.ShortMax,
+		ContinueWrites: int(p.ContinueWrites),
+		MaxDelay:       time.Duration(p.MaxDelay) * time.Millisecond,
+	}
+}
+
+// Paddings are the padding profiles of a handler, by name.
+type Paddings map[string]*proxy.Padding
+
+// NewPaddings returns the Paddings of profiles.
+func NewPaddings(profiles []*PaddingProfile) (Paddings, error) {
+	paddings := make(Paddings, len(profiles))
+	for _, p := range profiles {
+		if p.Name == "" {
+			return nil, errors.New("padding profile without name")
+		}
+		if _, found := paddings[p.Name]; found {
+			return nil, errors.New("duplicate padding profile ", p.Name)
+		}
+		// The padding and its header of 21 bytes fit in a buffer.
+		if p.LongMin < 0 || p.LongMax < p.LongMin || p.LongMax > buf.Size-21 {
+			return nil, errors.New("invalid longMin or longMax of padding profile ", p.Name)
+		}
+		if p.ShortMax < 0 || p.ShortMax > buf.Size-21 {
+			return nil, errors.New("invalid shortMax of padding profile ", p.Name)
+		}
+		paddings[p.Name] = p.AsPadding()
+	}
+	return paddings, nil
+}
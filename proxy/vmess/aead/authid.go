@@ -65,13 +65,16 @@ func (aidd *AuthIDDecoder) Decode(data [16]byte) (int64, uint32, int32, []byte)
 	return t, zero, rand, data[:]
 }
 
-func NewAuthIDDecoderHolder() *AuthIDDecoderHolder {
-	return &AuthIDDecoderHolder{make(map[string]*AuthIDDecoderItem), antireplay.NewReplayFilter(120)}
+// NewAuthIDDecoderHolder returns a holder checking the auth IDs for replays
+// with filter, whose interval is also how far off the time of an auth ID may
+// be.
+func NewAuthIDDecoderHolder(filter antireplay.GeneralizedReplayFilter) *AuthIDDecoderHolder {
+	return &AuthIDDecoderHolder{make(map[string]*AuthIDDecoderItem), filter}
 }
 
 type AuthIDDecoderHolder struct {
 	decoders map[string]*AuthIDDecoderItem
-	filter   *antireplay.ReplayFilter
+	filter   antireplay.GeneralizedReplayFilter
 }
 
 type AuthIDDecoderItem struct {
@@ -105,12 +108,13 @@ func (a *AuthIDDecoderHolder) Match(authID [16]byte) (interface{}, error) {
 			continue
 		}
 
-		if math.Abs(math.Abs(float64(t))-float64(time.Now().Unix())) > 120 {
+		if math.Abs(math.Abs(float64(t))-float64(time.Now().Unix())) > float64(a.filter.Interval()) {
 			continue
 		}
 
+		// The user is returned along, for the replay to be told of.
 		if !a.filter.Check(authID[:]) {
-			return nil, ErrReplay
+			return v.ticket, ErrReplay
 		}
 
 		return v.ticket, nil
@@ -0,0 +1,69 @@
+//go:build !vmess_aead_only
+
+package encoding
+
+import (
+	"io"
+
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/crypto"
+	"github.com/xtls/xray-core/common/protocol"
+)
+
+// PlainSupported is whether bodies of security none, which are not
+// authenticated, are supported. They are not if built with the tag
+// vmess_aead_only.
+const PlainSupported = true
+
+// [DEPRECATED 2023-06]
+type NoOpAuthenticator struct{}
+
+func (NoOpAuthenticator) NonceSize() int {
+	return 0
+}
+
+func (NoOpAuthenticator) Overhead() int {
+	return 0
+}
+
+// Seal implements AEAD.Seal().
+func (NoOpAuthenticator) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	return append(dst[:0], plaintext...)
+}
+
+// Open implements AEAD.Open().
+func (NoOpAuthenticator) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	return append(dst[:0], ciphertext...), nil
+}
+
+func newPlainReader(request *protocol.RequestHeader, sizeParser crypto.ChunkSizeDecoder, reader io.Reader, padding crypto.PaddingLengthGenerator) (buf.Reader, error) {
+	if request.Option.Has(protocol.RequestOptionChunkStream) {
+		if request.Command.TransferType() == protocol.TransferTypeStream {
+			return crypto.NewChunkStreamReader(sizeParser, reader), nil
+		}
+
+		auth := &crypto.AEADAuthenticator{
+			AEAD:                    new(NoOpAuthenticator),
+			NonceGenerator:          crypto.GenerateEmptyBytes(),
+			AdditionalDataGenerator: crypto.GenerateEmptyBytes(),
+		}
+		return crypto.NewAuthenticationReader(auth, sizeParser, reader, protocol.TransferTypePacket, padding), nil
+	}
+	return buf.NewReader(reader), nil
+}
+
+func newPlainWriter(request *protocol.RequestHeader, sizeParser crypto.ChunkSizeEncoder, writer io.Writer, padding crypto.PaddingLengthGenerator) (buf.Writer, error) {
+	if request.Option.Has(protocol.RequestOptionChunkStream) {
+		if request.Command.TransferType() == protocol.TransferTypeStream {
+			return crypto.NewChunkStreamWriter(sizeParser, writer), nil
+		}
+
+		auth := &crypto.AEADAuthenticator{
+			AEAD:                    new(NoOpAuthenticator),
+			NonceGenerator:          crypto.GenerateEmptyBytes(),
+			AdditionalDataGenerator: crypto.GenerateEmptyBytes(),
+		}
+		return crypto.NewAuthenticationWriter(auth, sizeParser, writer, protocol.TransferTypePacket, padding), nil
+	}
+	return buf.NewWriter(writer), nil
+}
@@ -0,0 +1,27 @@
+//go:build vmess_aead_only
+
+package encoding
+
+import (
+	"io"
+
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/crypto"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/protocol"
+)
+
+// PlainSupported is whether bodies of security none, which are not
+// authenticated, are supported. They are not if built with the tag
+// vmess_aead_only.
+const PlainSupported = false
+
+var errPlainUnsupported = errors.New("security none is not supported in this build")
+
+func newPlainReader(*protocol.RequestHeader, crypto.ChunkSizeDecoder, io.Reader, crypto.PaddingLengthGenerator) (buf.Reader, error) {
+	return nil, errPlainUnsupported
+}
+
+func newPlainWriter(*protocol.RequestHeader, crypto.ChunkSizeEncoder, io.Writer, crypto.PaddingLengthGenerator) (buf.Writer, error) {
+	return nil, errPlainUnsupported
+}
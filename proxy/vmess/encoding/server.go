@@ -104,6 +104,15 @@ type ServerSession struct {
 	responseHeader  byte
 }
 
+// ReplayError is of a request rejected as a replay of one of User.
+type ReplayError struct {
+	User *protocol.MemoryUser
+}
+
+func (e *ReplayError) Error() string {
+	return "replayed request of user " + e.User.Email
+}
+
 // NewServerSession creates a new ServerSession, using the given UserValidator.
 // The ServerSession instance doesn't take ownership of the validator.
 func NewServerSession(validator *vmess.TimedUserValidator, sessionHistory *SessionHistory) *ServerSession {
@@ -174,6 +183,8 @@ func (s *ServerSession) DecodeRequestHeader(reader io.Reader, isDrain bool) (*pr
 			}
 		}
 		decryptor = bytes.NewReader(aeadData)
+	case errorAEAD == vmessaead.ErrReplay:
+		return nil, drainConnection(&ReplayError{User: user})
 	default:
 		return nil, drainConnection(errors.New("invalid user").Base(errorAEAD))
 	}
@@ -196,7 +207,7 @@ func (s *ServerSession) DecodeRequestHeader(reader io.Reader, isDrain bool) (*pr
 	sid.key = s.requestBodyKey
 	sid.nonce = s.requestBodyIV
 	if !s.sessionHistory.addIfNotExits(sid) {
-		return nil, errors.New("duplicated session id, possibly under replay attack, but this is a AEAD request")
+		return nil, errors.New("duplicated session id, possibly under replay attack, but this is a AEAD request").Base(&ReplayError{User: user})
 	}
 
 	s.responseHeader = buffer.Byte(33)             // 1 byte
@@ -265,19 +276,7 @@ func (s *ServerSession) DecodeRequestBody(request *protocol.RequestHeader, reade
 
 	switch request.Security {
 	case protocol.SecurityType_NONE:
-		if request.Option.Has(protocol.RequestOptionChunkStream) {
-			if request.Command.TransferType() == protocol.TransferTypeStream {
-				return crypto.NewChunkStreamReader(sizeParser, reader), nil
-			}
-
-			auth := &crypto.AEADAuthenticator{
-				AEAD:                    new(NoOpAuthenticator),
-				NonceGenerator:          crypto.GenerateEmptyBytes(),
-				AdditionalDataGenerator: crypto.GenerateEmptyBytes(),
-			}
-			return crypto.NewAuthenticationReader(auth, sizeParser, reader, protocol.TransferTypePacket, padding), nil
-		}
-		return buf.NewReader(reader), nil
+		return newPlainReader(request, sizeParser, reader, padding)
 
 	case protocol.SecurityType_AES128_GCM:
 		aead := crypto.NewAesGcm(s.requestBodyKey[:])
@@ -389,19 +388,7 @@ func (s *ServerSession) EncodeResponseBody(request *protocol.RequestHeader, writ
 
 	switch request.Security {
 	case protocol.SecurityType_NONE:
-		if request.Option.Has(protocol.RequestOptionChunkStream) {
-			if request.Command.TransferType() == protocol.TransferTypeStream {
-				return crypto.NewChunkStreamWriter(sizeParser, writer), nil
-			}
-
-			auth := &crypto.AEADAuthenticator{
-				AEAD:                    new(NoOpAuthenticator),
-				NonceGenerator:          crypto.GenerateEmptyBytes(),
-				AdditionalDataGenerator: crypto.GenerateEmptyBytes(),
-			}
-			return crypto.NewAuthenticationWriter(auth, sizeParser, writer, protocol.TransferTypePacket, padding), nil
-		}
-		return buf.NewWriter(writer), nil
+		return newPlainWriter(request, sizeParser, writer, padding)
 
 	case protocol.SecurityType_AES128_GCM:
 		aead := crypto.NewAesGcm(s.responseBodyKey[:])
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/antireplay"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/log"
@@ -21,6 +22,8 @@ import (
 	feature_inbound "github.com/xtls/xray-core/features/inbound"
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/proxy"
 	"github.com/xtls/xray-core/proxy/vmess"
 	"github.com/xtls/xray-core/proxy/vmess/encoding"
 	"github.com/xtls/xray-core/transport/internet/stat"
@@ -108,18 +111,46 @@ type Handler struct {
 	usersByEmail          *userByEmail
 	detours               *DetourConfig
 	sessionHistory        *encoding.SessionHistory
+	stats                 stats.Manager
+	aeadOnly              bool
+	replayFilter          antireplay.GeneralizedReplayFilter
+}
+
+const (
+	defaultReplayWindow = 120
+	defaultReplayMemory = 256 * 1024
+)
+
+// newReplayFilter returns the filter of the requests seen, as of config.
+func newReplayFilter(config *ReplayConfig) antireplay.GeneralizedReplayFilter {
+	window := int64(defaultReplayWindow)
+	if config.GetWindow() != 0 {
+		window = int64(config.GetWindow())
+	}
+	memory := uint64(defaultReplayMemory)
+	if config.GetMemory() != 0 {
+		memory = config.GetMemory()
+	}
+	if config.GetFile() != "" {
+		return antireplay.NewBloomFilter(window, memory, config.GetFile())
+	}
+	return antireplay.NewReplayFilterOfMemory(window, memory)
 }
 
 // New creates a new VMess inbound handler.
 func New(ctx context.Context, config *Config) (*Handler, error) {
 	v := core.MustFromContext(ctx)
+	replayFilter := newReplayFilter(config.Replay)
 	handler := &Handler{
 		policyManager:         v.GetFeature(policy.ManagerType()).(policy.Manager),
 		inboundHandlerManager: v.GetFeature(feature_inbound.ManagerType()).(feature_inbound.Manager),
-		clients:               vmess.NewTimedUserValidator(),
+		clients:               vmess.NewTimedUserValidator(replayFilter),
 		detours:               config.Detour,
 		usersByEmail:          newUserByEmail(config.GetDefaultValue()),
 		sessionHistory:        encoding.NewSessionHistory(),
+		stats:                 v.GetFeature(stats.ManagerType()).(stats.Manager),
+		aeadOnly:              config.AeadOnly || !encoding.PlainSupported,
+		replayFilter:          replayFilter,
 	}
 
 	for _, user := range config.User {
@@ -140,7 +171,8 @@ func New(ctx context.Context, config *Config) (*Handler, error) {
 func (h *Handler) Close() error {
 	return errors.Combine(
 		h.sessionHistory.Close(),
-		common.Close(h.usersByEmail))
+		common.Close(h.usersByEmail),
+		common.Close(h.replayFilter))
 }
 
 // Network implements proxy.Inbound.Network().
@@ -224,6 +256,45 @@ func transferResponse(timer signal.ActivityUpdater, session *encoding.ServerSess
 	return nil
 }
 
+// rejectNonAEAD rejects request, of a body not authenticated, counting it as
+// of its inbound and user.
+func (h *Handler) rejectNonAEAD(ctx context.Context, connection stat.Connection, request *protocol.RequestHeader) error {
+	err := errors.New("rejected non-AEAD request of user ", request.User.Email)
+	log.Record(&log.AccessMessage{
+		From:   connection.RemoteAddr(),
+		To:     request.Destination(),
+		Status: log.AccessRejected,
+		Reason: err,
+		Email:  request.User.Email,
+	})
+	if inbound := session.InboundFromContext(ctx); inbound != nil && inbound.Tag != "" {
+		if c, _ := stats.GetOrRegisterCounter(h.stats, "inbound>>>"+inbound.Tag+">>>vmess>>>nonaead"); c != nil {
+			c.Add(1)
+		}
+	}
+	if request.User.Email != "" {
+		if c, _ := stats.GetOrRegisterCounter(h.stats, "user>>>"+request.User.Email+">>>vmess>>>nonaead"); c != nil {
+			c.Add(1)
+		}
+	}
+	return err.AtWarning()
+}
+
+// countReplay counts a request rejected as a replay of one of user, as of its
+// inbound and user.
+func (h *Handler) countReplay(ctx context.Context, user *protocol.MemoryUser) {
+	if inbound := session.InboundFromContext(ctx); inbound != nil && inbound.Tag != "" {
+		if c, _ := stats.GetOrRegisterCounter(h.stats, "inbound>>>"+inbound.Tag+">>>vmess>>>replay"); c != nil {
+			c.Add(1)
+		}
+	}
+	if user.Email != "" {
+		if c, _ := stats.GetOrRegisterCounter(h.stats, "user>>>"+user.Email+">>>vmess>>>replay"); c != nil {
+			c.Add(1)
+		}
+	}
+}
+
 // Process implements proxy.Inbound.Process().
 func (h *Handler) Process(ctx context.Context, network net.Network, connection stat.Connection, dispatcher routing.Dispatcher) error {
 	sessionPolicy := h.policyManager.ForLevel(0)
@@ -251,11 +322,19 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 				Status: log.AccessRejected,
 				Reason: err,
 			})
+			if replay, ok := errors.Cause(err).(*encoding.ReplayError); ok {
+				h.countReplay(ctx, replay.User)
+			}
+			proxy.AuthFailed(ctx)
 			err = errors.New("invalid request from ", connection.RemoteAddr()).Base(err).AtInfo()
 		}
 		return err
 	}
 
+	if h.aeadOnly && request.Security == protocol.SecurityType_NONE {
+		return h.rejectNonAEAD(ctx, connection, request)
+	}
+
 	if request.Command != protocol.RequestCommandMux {
 		ctx = log.ContextWithAccessMessage(ctx, &log.AccessMessage{
 			From:   connection.RemoteAddr(),
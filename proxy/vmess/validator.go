@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/xtls/xray-core/common/antireplay"
 	"github.com/xtls/xray-core/common/dice"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/protocol"
@@ -24,11 +25,12 @@ type TimedUserValidator struct {
 	aeadDecoderHolder *aead.AuthIDDecoderHolder
 }
 
-// NewTimedUserValidator creates a new TimedUserValidator.
-func NewTimedUserValidator() *TimedUserValidator {
+// NewTimedUserValidator creates a new TimedUserValidator, checking requests
+// for replays with filter.
+func NewTimedUserValidator(filter antireplay.GeneralizedReplayFilter) *TimedUserValidator {
 	tuv := &TimedUserValidator{
 		users:             make([]*protocol.MemoryUser, 0, 16),
-		aeadDecoderHolder: aead.NewAuthIDDecoderHolder(),
+		aeadDecoderHolder: aead.NewAuthIDDecoderHolder(filter),
 	}
 	return tuv
 }
@@ -78,6 +80,9 @@ func (v *TimedUserValidator) GetAEAD(userHash []byte) (*protocol.MemoryUser, boo
 	copy(userHashFL[:], userHash)
 
 	userd, err := v.aeadDecoderHolder.Match(userHashFL)
+	if err == aead.ErrReplay {
+		return userd.(*protocol.MemoryUser), false, err
+	}
 	if err != nil {
 		return nil, false, err
 	}
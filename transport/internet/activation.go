@@ -0,0 +1,14 @@
+package internet
+
+import (
+	"strings"
+)
+
+// activationPrefix is the prefix of the addresses of the listening sockets
+// passed by the service manager, such as systemd: "fd://3" for a number of
+// file descriptor, or "fd://xray.socket" for a name of FileDescriptorName=.
+const activationPrefix = "fd://"
+
+func isActivationAddress(address string) bool {
+	return strings.HasPrefix(address, activationPrefix)
+}
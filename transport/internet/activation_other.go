@@ -0,0 +1,14 @@
+//go:build !unix
+
+package internet
+
+import (
+	"runtime"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+)
+
+func activatedListener(address string) (net.Listener, error) {
+	return nil, errors.New("listening on ", address, ": socket activation is not supported on ", runtime.GOOS)
+}
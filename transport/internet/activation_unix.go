@@ -0,0 +1,92 @@
+//go:build unix
+
+package internet
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd, after
+// stdin, stdout and stderr. See sd_listen_fds(3).
+const listenFdsStart = 3
+
+// activatedFile is a socket passed by the service manager.
+type activatedFile struct {
+	file *os.File
+	name string
+}
+
+var (
+	activatedFiles     []activatedFile
+	activatedFilesOnce sync.Once
+)
+
+// loadActivatedFiles takes the sockets passed to this process in
+// LISTEN_FDS, and unsets the variables so that the processes it starts do
+// not take them.
+func loadActivatedFiles() {
+	pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	n, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if pid != os.Getpid() || n <= 0 {
+		return
+	}
+	for i := 0; i < n; i++ {
+		fd := listenFdsStart + i
+		syscall.CloseOnExec(fd)
+		f := activatedFile{file: os.NewFile(uintptr(fd), "fd://"+strconv.Itoa(fd))}
+		if i < len(names) {
+			f.name = names[i]
+		}
+		activatedFiles = append(activatedFiles, f)
+	}
+}
+
+// activatedListener returns a listener of the socket of address, passed by
+// the service manager. The socket is kept open when the listener is closed,
+// to be listened on again.
+func activatedListener(address string) (net.Listener, error) {
+	activatedFilesOnce.Do(loadActivatedFiles)
+	if len(activatedFiles) == 0 {
+		return nil, errors.New("listening on ", address, ": no sockets passed by the service manager")
+	}
+
+	key := strings.TrimPrefix(address, activationPrefix)
+	var file *os.File
+	if fd, err := strconv.Atoi(key); err == nil {
+		if i := fd - listenFdsStart; i >= 0 && i < len(activatedFiles) {
+			file = activatedFiles[i].file
+		}
+	} else {
+		for _, f := range activatedFiles {
+			if f.name != key {
+				continue
+			}
+			if file != nil {
+				return nil, errors.New("listening on ", address, ": more than one socket of the name, use the number of its file descriptor")
+			}
+			file = f.file
+		}
+	}
+	if file == nil {
+		return nil, errors.New("listening on ", address, ": no such socket passed by the service manager")
+	}
+	l, err := net.FileListener(file)
+	if err != nil {
+		return nil, errors.New("listening on ", address).Base(err)
+	}
+	if l, ok := l.(*net.UnixListener); ok {
+		return &UnixListenerWrapper{UnixListener: l}, nil
+	}
+	return l, nil
+}
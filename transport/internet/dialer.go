@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	gonet "net"
+	"slices"
 	"strings"
 
 	"github.com/xtls/xray-core/common"
@@ -12,11 +13,14 @@ import (
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/net/cnc"
 	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/tracing"
 	"github.com/xtls/xray-core/features/dns"
 	"github.com/xtls/xray-core/features/outbound"
 	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/internet/padding"
 	"github.com/xtls/xray-core/transport/internet/stat"
 	"github.com/xtls/xray-core/transport/pipe"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Dialer is the interface for dialing outbound connections.
@@ -45,9 +49,29 @@ func RegisterTransportDialer(protocol string, dialer dialFunc) error {
 	return nil
 }
 
+var udpTransports = make(map[string]bool)
+
+// RegisterUDPTransport declares that the dialer of a transport dials UDP
+// destinations too, and its listener passes their flows as
+// stat.PacketConnection.
+func RegisterUDPTransport(protocol string) {
+	udpTransports[protocol] = true
+}
+
 // Dial dials a internet connection towards the given destination.
 func Dial(ctx context.Context, dest net.Destination, streamSettings *MemoryStreamConfig) (stat.Connection, error) {
-	if dest.Network == net.Network_TCP {
+	ctx, span := tracing.Start(ctx, tracing.Dial, attribute.String("target", dest.NetAddr()))
+	if streamSettings != nil {
+		span.SetAttributes(attribute.String("transport", streamSettings.ProtocolName))
+	}
+	conn, err := dial(ctx, dest, streamSettings)
+	tracing.End(span, err)
+	return conn, err
+}
+
+func dial(ctx context.Context, dest net.Destination, streamSettings *MemoryStreamConfig) (stat.Connection, error) {
+	// Unix domain sockets of the config carry streams as TCP does.
+	if dest.Network == net.Network_TCP || dest.Network == net.Network_UNIX {
 		if streamSettings == nil {
 			s, err := ToMemoryStreamConfig(nil)
 			if err != nil {
@@ -65,6 +89,9 @@ func Dial(ctx context.Context, dest net.Destination, streamSettings *MemoryStrea
 	}
 
 	if dest.Network == net.Network_UDP {
+		if streamSettings != nil && streamSettings.CarriesUDP() {
+			return transportDialerCache[streamSettings.ProtocolName](ctx, dest, streamSettings)
+		}
 		udpDialer := transportDialerCache["udp"]
 		if udpDialer == nil {
 			return nil, errors.New("UDP dialer not registered").AtError()
@@ -85,12 +112,14 @@ var (
 	obm       outbound.Manager
 )
 
-func lookupIP(domain string, strategy DomainStrategy, localAddr net.Address) ([]net.IP, error) {
+func lookupIP(ctx context.Context, domain string, strategy DomainStrategy, localAddr net.Address) (ips []net.IP, err error) {
 	if dnsClient == nil {
 		return nil, errors.New("DNS client not initialized").AtError()
 	}
+	_, span := tracing.Start(ctx, tracing.Resolve, attribute.String("domain", domain))
+	defer func() { tracing.End(span, err) }()
 
-	ips, _, err := dnsClient.LookupIP(domain, dns.IPOption{
+	ips, _, err = dnsClient.LookupIP(domain, dns.IPOption{
 		IPv4Enable: (localAddr == nil || localAddr.Family().IsIPv4()) && strategy.preferIP4(),
 		IPv6Enable: (localAddr == nil || localAddr.Family().IsIPv6()) && strategy.preferIP6(),
 	})
@@ -233,13 +262,49 @@ func checkAddressPortStrategy(ctx context.Context, dest net.Destination, sockopt
 
 // DialSystem calls system dialer to create a network connection.
 func DialSystem(ctx context.Context, dest net.Destination, sockopt *SocketConfig) (net.Conn, error) {
+	conn, err := dialSystem(ctx, dest, sockopt)
+	if err != nil || sockopt == nil || dest.Network != net.Network_TCP {
+		return conn, err
+	}
+	if sockopt.SendProxyProtocol != 0 {
+		if err := writeProxyProtocolHeader(ctx, conn, sockopt.SendProxyProtocol, dest); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	// The PROXY protocol header is for the server in front, which passes the
+	// padded stream on as it is.
+	if sockopt.Padding != nil {
+		conn = padding.Client(conn, sockopt.Padding)
+	}
+	return conn, nil
+}
+
+func dialSystem(ctx context.Context, dest net.Destination, sockopt *SocketConfig) (net.Conn, error) {
+	if dest.Network == net.Network_UNIX {
+		return effectiveSystemDialer.Dial(ctx, nil, dest, sockopt)
+	}
+
 	var src net.Address
 	outbounds := session.OutboundsFromContext(ctx)
 	if len(outbounds) > 0 {
 		ob := outbounds[len(outbounds)-1]
 		src = ob.Gateway
 	}
+	chain := session.DialerChainFromContext(ctx)
+	if len(chain) == 0 && sockopt != nil && len(sockopt.DialerProxy) > 0 {
+		chain = append(slices.Clip(sockopt.DialerChain), sockopt.DialerProxy)
+	}
+	policy := session.DialPolicyFromContext(ctx)
+	if policy != nil && policy.Timeout > 0 && len(chain) == 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		defer cancel()
+	}
 	if sockopt == nil {
+		if len(chain) > 0 {
+			return dialThroughChain(ctx, dest, chain)
+		}
 		return effectiveSystemDialer.Dial(ctx, src, dest, sockopt)
 	}
 
@@ -249,34 +314,53 @@ func DialSystem(ctx context.Context, dest net.Destination, sockopt *SocketConfig
 	}
 
 	if canLookupIP(dest, sockopt) {
-		ips, err := lookupIP(dest.Address.String(), sockopt.DomainStrategy, src)
+		ips, err := lookupIP(ctx, dest.Address.String(), sockopt.DomainStrategy, src)
 		if err != nil {
 			errors.LogErrorInner(ctx, err, "failed to resolve ip")
 			if sockopt.DomainStrategy.forceIP() {
 				return nil, err
 			}
-		} else if sockopt.HappyEyeballs == nil || sockopt.HappyEyeballs.TryDelayMs == 0 || sockopt.HappyEyeballs.MaxConcurrentTry == 0 || len(ips) < 2 || len(sockopt.DialerProxy) > 0 || dest.Network != net.Network_TCP {
-			dest.Address = net.IPAddress(ips[dice.Roll(len(ips))])
+		} else if sockopt.HappyEyeballs == nil || sockopt.HappyEyeballs.TryDelayMs == 0 || sockopt.HappyEyeballs.MaxConcurrentTry == 0 || len(ips) < 2 || len(chain) > 0 || dest.Network != net.Network_TCP {
+			i := dice.Roll(len(ips))
+			if policy != nil && policy.NextIP {
+				i = int(policy.Dials.Load()) % len(ips)
+			}
+			dest.Address = net.IPAddress(ips[i])
 			errors.LogInfo(ctx, "replace destination with "+dest.String())
 		} else {
 			return TcpRaceDial(ctx, src, ips, dest.Port, sockopt)
 		}
 	}
 
-	if len(sockopt.DialerProxy) > 0 {
-		if obm == nil {
-			return nil, errors.New("there is no outbound manager for dialerProxy").AtError()
-		}
-		h := obm.GetHandler(sockopt.DialerProxy)
-		if h == nil {
-			return nil, errors.New("there is no outbound handler for dialerProxy").AtError()
-		}
-		return redirect(ctx, dest, sockopt.DialerProxy, h), nil
+	if len(chain) > 0 {
+		return dialThroughChain(ctx, dest, chain)
 	}
 
+	if policy != nil {
+		policy.Dials.Add(1)
+	}
 	return effectiveSystemDialer.Dial(ctx, src, dest, sockopt)
 }
 
+// dialThroughChain redirects the connection to the last outbound of chain,
+// which dials through the others.
+func dialThroughChain(ctx context.Context, dest net.Destination, chain []string) (net.Conn, error) {
+	if obm == nil {
+		return nil, errors.New("there is no outbound manager for dialerProxy").AtError()
+	}
+	tag := chain[len(chain)-1]
+	for _, ob := range session.OutboundsFromContext(ctx) {
+		if ob.Tag == tag {
+			return nil, errors.New("dialerProxy loops back to outbound ", tag).AtError()
+		}
+	}
+	h := obm.GetHandler(tag)
+	if h == nil {
+		return nil, errors.New("there is no outbound handler for dialerProxy ", tag).AtError()
+	}
+	return redirect(session.ContextWithDialerChain(ctx, chain[:len(chain)-1]), dest, tag, h), nil
+}
+
 func InitSystemDialer(dc dns.Client, om outbound.Manager) {
 	dnsClient = dc
 	obm = om
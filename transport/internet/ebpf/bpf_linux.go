@@ -0,0 +1,239 @@
+package ebpf
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Helper functions of the kernel called by the programs.
+const (
+	fnMapLookupElem     = 1
+	fnMapUpdateElem     = 2
+	fnGetCurrentPidTgid = 14
+	fnGetSocketCookie   = 46
+	fnSkRedirectHash    = 72
+)
+
+// Registers of the virtual machine. R10 is the read-only frame pointer.
+const (
+	r0 = iota
+	r1
+	r2
+	r3
+	r4
+	r5
+	r6
+	r7
+	r8
+	r9
+	r10
+)
+
+// insn is a struct bpf_insn.
+type insn struct {
+	code uint8
+	regs uint8 // dst in the low nibble, src in the high nibble
+	off  int16
+	imm  int32
+}
+
+// asm assembles a program. Jumps refer to labels, which are resolved into
+// offsets by assemble.
+type asm struct {
+	insns  []insn
+	labels map[string]int
+	jumps  map[int]string
+}
+
+func (a *asm) emit(code uint8, dst, src uint8, off int16, imm int32) {
+	a.insns = append(a.insns, insn{code: code, regs: src<<4 | dst, off: off, imm: imm})
+}
+
+func (a *asm) label(name string) {
+	if a.labels == nil {
+		a.labels = make(map[string]int)
+	}
+	a.labels[name] = len(a.insns)
+}
+
+func (a *asm) jump(code uint8, dst, src uint8, imm int32, label string) {
+	if a.jumps == nil {
+		a.jumps = make(map[int]string)
+	}
+	a.jumps[len(a.insns)] = label
+	a.emit(code, dst, src, 0, imm)
+}
+
+func (a *asm) movImm(dst uint8, imm int32)           { a.emit(0xb7, dst, 0, 0, imm) }
+func (a *asm) movReg(dst, src uint8)                 { a.emit(0xbf, dst, src, 0, 0) }
+func (a *asm) addImm(dst uint8, imm int32)           { a.emit(0x07, dst, 0, 0, imm) }
+func (a *asm) andImm(dst uint8, imm int32)           { a.emit(0x57, dst, 0, 0, imm) }
+func (a *asm) rshImm(dst uint8, imm int32)           { a.emit(0x77, dst, 0, 0, imm) }
+func (a *asm) ldxW(dst, src uint8, off int16)        { a.emit(0x61, dst, src, off, 0) }
+func (a *asm) ldxDW(dst, src uint8, off int16)       { a.emit(0x79, dst, src, off, 0) }
+func (a *asm) stxW(dst uint8, off int16, src uint8)  { a.emit(0x63, dst, src, off, 0) }
+func (a *asm) stxDW(dst uint8, off int16, src uint8) { a.emit(0x7b, dst, src, off, 0) }
+func (a *asm) stW(dst uint8, off int16, imm int32)   { a.emit(0x62, dst, 0, off, imm) }
+func (a *asm) call(fn int32)                         { a.emit(0x85, 0, 0, 0, fn) }
+func (a *asm) exit()                                 { a.emit(0x95, 0, 0, 0, 0) }
+
+// jeqImm and jneImm compare the 32 bits of dst as an unsigned value.
+func (a *asm) jeqImm(dst uint8, imm uint32, label string) {
+	a.jump(0x16, dst, 0, int32(imm), label)
+}
+
+func (a *asm) jneImm(dst uint8, imm uint32, label string) {
+	a.jump(0x56, dst, 0, int32(imm), label)
+}
+
+func (a *asm) ja(label string) { a.jump(0x05, 0, 0, 0, label) }
+
+// jeqZero compares all the 64 bits of dst, for pointers returned by helpers.
+func (a *asm) jeqZero(dst uint8, label string) { a.jump(0x15, dst, 0, 0, label) }
+
+// ldMap loads the pointer of the map by its file descriptor.
+func (a *asm) ldMap(dst uint8, fd int) {
+	a.emit(0x18, dst, unix.BPF_PSEUDO_MAP_FD, 0, int32(fd))
+	a.emit(0, 0, 0, 0, 0)
+}
+
+func (a *asm) assemble() []insn {
+	for pc, label := range a.jumps {
+		a.insns[pc].off = int16(a.labels[label] - pc - 1)
+	}
+	return a.insns
+}
+
+func bpf(cmd int, attr unsafe.Pointer, size uintptr) (int, error) {
+	r, _, errno := unix.Syscall(unix.SYS_BPF, uintptr(cmd), uintptr(attr), size)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(r), nil
+}
+
+type mapCreateAttr struct {
+	mapType    uint32
+	keySize    uint32
+	valueSize  uint32
+	maxEntries uint32
+	flags      uint32
+}
+
+func createMap(mapType, keySize, valueSize, maxEntries uint32) (int, error) {
+	attr := mapCreateAttr{
+		mapType:    mapType,
+		keySize:    keySize,
+		valueSize:  valueSize,
+		maxEntries: maxEntries,
+	}
+	return bpf(unix.BPF_MAP_CREATE, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+}
+
+type mapElemAttr struct {
+	fd    uint32
+	_     uint32
+	key   pointer
+	value pointer
+	flags uint64
+}
+
+func updateElem(fd int, key, value unsafe.Pointer, flags uint64) error {
+	attr := mapElemAttr{
+		fd:    uint32(fd),
+		key:   pointer{ptr: key},
+		value: pointer{ptr: value},
+		flags: flags,
+	}
+	_, err := bpf(unix.BPF_MAP_UPDATE_ELEM, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	return err
+}
+
+func lookupElem(fd int, key, value unsafe.Pointer) error {
+	attr := mapElemAttr{
+		fd:    uint32(fd),
+		key:   pointer{ptr: key},
+		value: pointer{ptr: value},
+	}
+	_, err := bpf(unix.BPF_MAP_LOOKUP_ELEM, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	return err
+}
+
+func deleteElem(fd int, key unsafe.Pointer) error {
+	attr := mapElemAttr{
+		fd:  uint32(fd),
+		key: pointer{ptr: key},
+	}
+	_, err := bpf(unix.BPF_MAP_DELETE_ELEM, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	return err
+}
+
+type progLoadAttr struct {
+	progType           uint32
+	insnCnt            uint32
+	insns              pointer
+	license            pointer
+	logLevel           uint32
+	logSize            uint32
+	logBuf             pointer
+	kernVersion        uint32
+	progFlags          uint32
+	progName           [16]byte
+	progIfindex        uint32
+	expectedAttachType uint32
+}
+
+var license = []byte("GPL\x00")
+
+// loadProgram loads the program and returns the log of the verifier on errors.
+func loadProgram(progType, attachType uint32, name string, a *asm) (int, string, error) {
+	insns := a.assemble()
+	attr := progLoadAttr{
+		progType:           progType,
+		insnCnt:            uint32(len(insns)),
+		insns:              pointer{ptr: unsafe.Pointer(&insns[0])},
+		license:            pointer{ptr: unsafe.Pointer(&license[0])},
+		expectedAttachType: attachType,
+	}
+	copy(attr.progName[:len(attr.progName)-1], name)
+	fd, err := bpf(unix.BPF_PROG_LOAD, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		// Load again with the log of the verifier, which is only worth it on errors.
+		log := make([]byte, 64*1024)
+		attr.logLevel = 1
+		attr.logSize = uint32(len(log))
+		attr.logBuf = pointer{ptr: unsafe.Pointer(&log[0])}
+		bpf(unix.BPF_PROG_LOAD, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+		return 0, unix.ByteSliceToString(log), err
+	}
+	return fd, "", nil
+}
+
+type progAttachAttr struct {
+	targetFd    uint32
+	attachBpfFd uint32
+	attachType  uint32
+	attachFlags uint32
+}
+
+func attachProgram(target, prog int, attachType, flags uint32) error {
+	attr := progAttachAttr{
+		targetFd:    uint32(target),
+		attachBpfFd: uint32(prog),
+		attachType:  attachType,
+		attachFlags: flags,
+	}
+	_, err := bpf(unix.BPF_PROG_ATTACH, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	return err
+}
+
+func detachProgram(target, prog int, attachType uint32) error {
+	attr := progAttachAttr{
+		targetFd:    uint32(target),
+		attachBpfFd: uint32(prog),
+		attachType:  attachType,
+	}
+	_, err := bpf(unix.BPF_PROG_DETACH, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	return err
+}
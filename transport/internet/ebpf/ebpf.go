@@ -0,0 +1,4 @@
+// Package ebpf redirects the TCP connections of cgroups to inbounds with
+// cgroup programs instead of iptables rules, and splices the connections
+// being copied as is in the kernel with sockmap, on Linux.
+package ebpf
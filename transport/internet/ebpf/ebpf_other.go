@@ -0,0 +1,33 @@
+//go:build !linux
+// +build !linux
+
+package ebpf
+
+import (
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+)
+
+var errNotLinux = errors.New("eBPF is only supported on Linux")
+
+type Redirector struct{}
+
+func Redirect(path string, target *net.TCPAddr) (*Redirector, error) {
+	return nil, errNotLinux
+}
+
+func (r *Redirector) Close() error {
+	return nil
+}
+
+func OriginalDestination(remote net.Addr) (net.Destination, error) {
+	return net.Destination{}, errNotLinux
+}
+
+func Supported() error {
+	return errNotLinux
+}
+
+func Splice(reader, writer *net.TCPConn) (int64, error) {
+	return 0, errNotLinux
+}
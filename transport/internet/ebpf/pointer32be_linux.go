@@ -0,0 +1,12 @@
+//go:build linux && mips
+
+package ebpf
+
+import "unsafe"
+
+// pointer is a __aligned_u64 pointer in the attributes of bpf, which keeps
+// what it points to alive and up to date when stacks move.
+type pointer struct {
+	_   uint32
+	ptr unsafe.Pointer
+}
@@ -0,0 +1,12 @@
+//go:build linux && (386 || arm || mipsle)
+
+package ebpf
+
+import "unsafe"
+
+// pointer is a __aligned_u64 pointer in the attributes of bpf, which keeps
+// what it points to alive and up to date when stacks move.
+type pointer struct {
+	ptr unsafe.Pointer
+	_   uint32
+}
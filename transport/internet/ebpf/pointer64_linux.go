@@ -0,0 +1,11 @@
+//go:build linux && (amd64 || arm64 || loong64 || mips64 || mips64le || ppc64 || ppc64le || riscv64 || s390x)
+
+package ebpf
+
+import "unsafe"
+
+// pointer is a __aligned_u64 pointer in the attributes of bpf, which keeps
+// what it points to alive and up to date when stacks move.
+type pointer struct {
+	ptr unsafe.Pointer
+}
@@ -0,0 +1,362 @@
+package ebpf
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+	"unsafe"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	sockOpsTCPConnect = 3 // BPF_SOCK_OPS_TCP_CONNECT_CB
+
+	// Offsets of struct bpf_sock_addr.
+	sockAddrUserIP4  = 4
+	sockAddrUserIP6  = 8
+	sockAddrUserPort = 24
+	sockAddrType     = 32
+
+	// Offsets of struct bpf_sock_ops.
+	sockOpsOp        = 0
+	sockOpsLocalPort = 68
+
+	maxRedirected = 65536
+)
+
+// origDest is the original destination of a redirected connection. The
+// address and the port are in network byte order.
+type origDest struct {
+	ip     [16]byte
+	port   uint32
+	family uint32
+}
+
+// The maps are shared by all the redirectors. The destinations are keyed by
+// the socket cookies of the clients when they connect, and then moved to be
+// keyed by their local ports when the ports are bound.
+var redirectMaps struct {
+	sync.Mutex
+	refs  int
+	dests int
+	ports int
+}
+
+func acquireRedirectMaps() error {
+	redirectMaps.Lock()
+	defer redirectMaps.Unlock()
+	if redirectMaps.refs == 0 {
+		dests, err := createMap(unix.BPF_MAP_TYPE_LRU_HASH, 8, uint32(unsafe.Sizeof(origDest{})), maxRedirected)
+		if err != nil {
+			return errors.New("failed to create map of destinations").Base(err)
+		}
+		ports, err := createMap(unix.BPF_MAP_TYPE_LRU_HASH, 4, uint32(unsafe.Sizeof(origDest{})), maxRedirected)
+		if err != nil {
+			unix.Close(dests)
+			return errors.New("failed to create map of ports").Base(err)
+		}
+		redirectMaps.dests, redirectMaps.ports = dests, ports
+	}
+	redirectMaps.refs++
+	return nil
+}
+
+func releaseRedirectMaps() {
+	redirectMaps.Lock()
+	defer redirectMaps.Unlock()
+	redirectMaps.refs--
+	if redirectMaps.refs == 0 {
+		unix.Close(redirectMaps.dests)
+		unix.Close(redirectMaps.ports)
+	}
+}
+
+// networkUint32 returns the value loaded by the programs from b in network
+// byte order.
+func networkUint32(b []byte) uint32 {
+	return binary.NativeEndian.Uint32(b)
+}
+
+// networkPort returns the value of user_port for port.
+func networkPort(port int) uint32 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(port))
+	return uint32(binary.NativeEndian.Uint16(b[:]))
+}
+
+// hostPort is the reverse of networkPort.
+func hostPort(v uint32) int {
+	var b [2]byte
+	binary.NativeEndian.PutUint16(b[:], uint16(v))
+	return int(binary.BigEndian.Uint16(b[:]))
+}
+
+// allowIfSelf skips the connections to the target itself, which would loop,
+// and the connections of Xray.
+func allowIfSelf(a *asm, ip []uint32, port uint32) {
+	for i, w := range ip {
+		a.ldxW(r2, r10, int16(-24+4*i))
+		a.jneImm(r2, w, "other")
+	}
+	a.ldxW(r2, r10, -8)
+	a.jeqImm(r2, port, "allow")
+	a.label("other")
+	a.call(fnGetCurrentPidTgid)
+	a.rshImm(r0, 32)
+	// The process ID is of the initial namespace, which differs from that of
+	// Xray inside a container, where Xray should be out of the cgroup.
+	a.jeqImm(r0, uint32(os.Getpid()), "allow")
+}
+
+// rewrite saves the original destination which has been put on the stack,
+// and connects to the target instead.
+func rewrite(a *asm, dests int, ipOffset int16, ip []uint32, port uint32) {
+	a.movReg(r1, r6)
+	a.call(fnGetSocketCookie)
+	a.stxDW(r10, -32, r0)
+	a.ldMap(r1, dests)
+	a.movReg(r2, r10)
+	a.addImm(r2, -32)
+	a.movReg(r3, r10)
+	a.addImm(r3, -24)
+	a.movImm(r4, unix.BPF_ANY)
+	a.call(fnMapUpdateElem)
+	for i, w := range ip {
+		a.movImm(r2, int32(w))
+		a.stxW(r6, ipOffset+int16(4*i), r2)
+	}
+	a.movImm(r2, int32(port))
+	a.stxW(r6, sockAddrUserPort, r2)
+	a.label("allow")
+	a.movImm(r0, 1)
+	a.exit()
+}
+
+// connect4 redirects the TCP connections to IPv4 addresses except loopback
+// ones. The original destination is kept at fp-24 as an origDest.
+func connect4(dests int, target *net.TCPAddr) *asm {
+	a := new(asm)
+	ip := []uint32{networkUint32(target.IP.To4())}
+	port := networkPort(target.Port)
+	a.movReg(r6, r1)
+	a.ldxW(r2, r6, sockAddrType)
+	a.jneImm(r2, unix.SOCK_STREAM, "allow")
+	a.ldxW(r2, r6, sockAddrUserIP4)
+	a.stxW(r10, -24, r2)
+	a.stW(r10, -20, 0)
+	a.stW(r10, -16, 0)
+	a.stW(r10, -12, 0)
+	a.ldxW(r3, r6, sockAddrUserPort)
+	a.stxW(r10, -8, r3)
+	a.stW(r10, -4, unix.AF_INET)
+	a.andImm(r2, int32(networkUint32([]byte{0xff, 0, 0, 0})))
+	a.jeqImm(r2, networkUint32([]byte{127, 0, 0, 0}), "allow")
+	allowIfSelf(a, ip, port)
+	rewrite(a, dests, sockAddrUserIP4, ip, port)
+	return a
+}
+
+// connect6 is connect4 for IPv6 addresses, including IPv4-mapped ones.
+func connect6(dests int, target *net.TCPAddr) *asm {
+	a := new(asm)
+	var ip []uint32
+	target16 := target.IP.To16()
+	for i := 0; i < 16; i += 4 {
+		ip = append(ip, networkUint32(target16[i:i+4]))
+	}
+	port := networkPort(target.Port)
+	a.movReg(r6, r1)
+	a.ldxW(r2, r6, sockAddrType)
+	a.jneImm(r2, unix.SOCK_STREAM, "allow")
+	for i := int16(0); i < 4; i++ {
+		a.ldxW(r2, r6, sockAddrUserIP6+4*i)
+		a.stxW(r10, -24+4*i, r2)
+	}
+	a.ldxW(r2, r6, sockAddrUserPort)
+	a.stxW(r10, -8, r2)
+	a.stW(r10, -4, unix.AF_INET6)
+	a.ldxW(r2, r10, -24)
+	a.jneImm(r2, 0, "global")
+	a.ldxW(r2, r10, -20)
+	a.jneImm(r2, 0, "global")
+	a.ldxW(r2, r10, -16)
+	a.jeqImm(r2, 0, "unmapped")
+	a.jneImm(r2, networkUint32([]byte{0, 0, 0xff, 0xff}), "global")
+	a.ldxW(r2, r10, -12)
+	a.andImm(r2, int32(networkUint32([]byte{0xff, 0, 0, 0})))
+	a.jeqImm(r2, networkUint32([]byte{127, 0, 0, 0}), "allow")
+	a.ja("global")
+	a.label("unmapped")
+	a.ldxW(r2, r10, -12)
+	a.jeqImm(r2, networkUint32([]byte{0, 0, 0, 1}), "allow")
+	a.label("global")
+	allowIfSelf(a, ip, port)
+	rewrite(a, dests, sockAddrUserIP6, ip, port)
+	return a
+}
+
+// bindPorts moves the original destination of a redirected connection to be
+// keyed by its local port, once the port is bound.
+func bindPorts(dests, ports int) *asm {
+	a := new(asm)
+	a.movReg(r6, r1)
+	a.ldxW(r2, r6, sockOpsOp)
+	a.jneImm(r2, sockOpsTCPConnect, "out")
+	a.movReg(r1, r6)
+	a.call(fnGetSocketCookie)
+	a.stxDW(r10, -8, r0)
+	a.ldMap(r1, dests)
+	a.movReg(r2, r10)
+	a.addImm(r2, -8)
+	a.call(fnMapLookupElem)
+	a.jeqZero(r0, "out")
+	a.movReg(r3, r0)
+	a.ldxW(r2, r6, sockOpsLocalPort)
+	a.stxW(r10, -16, r2)
+	a.ldMap(r1, ports)
+	a.movReg(r2, r10)
+	a.addImm(r2, -16)
+	a.movImm(r4, unix.BPF_ANY)
+	a.call(fnMapUpdateElem)
+	a.label("out")
+	a.movImm(r0, 1)
+	a.exit()
+	return a
+}
+
+type attachedProgram struct {
+	fd         int
+	attachType uint32
+}
+
+// Redirector redirects the TCP connections made in a cgroup to a listener.
+type Redirector struct {
+	cgroup int
+	progs  []attachedProgram
+}
+
+// Redirect attaches the programs of redirection to the TCP listener at
+// target to the cgroup v2 at path, or the root one if path is empty. The
+// connections to loopback addresses and those of Xray itself are not
+// redirected. IPv4 connections are only redirected to IPv4 targets as well
+// as unspecified IPv6 ones, which accept both.
+func Redirect(path string, target *net.TCPAddr) (*Redirector, error) {
+	if path == "" {
+		path = cgroupRoot()
+		if path == "" {
+			return nil, errors.New("cgroup v2 is not mounted")
+		}
+	}
+	if err := acquireRedirectMaps(); err != nil {
+		return nil, err
+	}
+	cgroup, err := unix.Open(path, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		releaseRedirectMaps()
+		return nil, errors.New("failed to open cgroup ", path).Base(err)
+	}
+	r := &Redirector{cgroup: cgroup}
+
+	target4, target6 := targets(target)
+	if target4 != nil {
+		if err := r.attach(unix.BPF_PROG_TYPE_CGROUP_SOCK_ADDR, unix.BPF_CGROUP_INET4_CONNECT, "xray_connect4", connect4(redirectMaps.dests, target4)); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+	if err := r.attach(unix.BPF_PROG_TYPE_CGROUP_SOCK_ADDR, unix.BPF_CGROUP_INET6_CONNECT, "xray_connect6", connect6(redirectMaps.dests, target6)); err != nil {
+		r.Close()
+		return nil, err
+	}
+	if err := r.attach(unix.BPF_PROG_TYPE_SOCK_OPS, unix.BPF_CGROUP_SOCK_OPS, "xray_bind_ports", bindPorts(redirectMaps.dests, redirectMaps.ports)); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// targets returns the addresses to connect to instead, for IPv4 and IPv6.
+func targets(target *net.TCPAddr) (*net.TCPAddr, *net.TCPAddr) {
+	ip := target.IP
+	switch {
+	case ip.To4() != nil:
+		if ip.IsUnspecified() {
+			ip = net.IP{127, 0, 0, 1}
+		}
+		return &net.TCPAddr{IP: ip.To4(), Port: target.Port}, &net.TCPAddr{IP: ip.To16(), Port: target.Port}
+	case ip.IsUnspecified() || ip == nil:
+		return &net.TCPAddr{IP: net.IP{127, 0, 0, 1}, Port: target.Port}, &net.TCPAddr{IP: net.LocalHostIPv6.IP(), Port: target.Port}
+	default:
+		return nil, &net.TCPAddr{IP: ip, Port: target.Port}
+	}
+}
+
+func (r *Redirector) attach(progType, attachType uint32, name string, a *asm) error {
+	fd, log, err := loadProgram(progType, attachType, name, a)
+	if err != nil {
+		return errors.New("failed to load program ", name, ": ", log).Base(err)
+	}
+	if err := attachProgram(r.cgroup, fd, attachType, unix.BPF_F_ALLOW_MULTI); err != nil {
+		unix.Close(fd)
+		return errors.New("failed to attach program ", name).Base(err)
+	}
+	r.progs = append(r.progs, attachedProgram{fd: fd, attachType: attachType})
+	return nil
+}
+
+// Close detaches the programs of redirection.
+func (r *Redirector) Close() error {
+	var errs []error
+	for _, p := range r.progs {
+		if err := detachProgram(r.cgroup, p.fd, p.attachType); err != nil {
+			errs = append(errs, err)
+		}
+		unix.Close(p.fd)
+	}
+	r.progs = nil
+	unix.Close(r.cgroup)
+	releaseRedirectMaps()
+	if len(errs) > 0 {
+		return errors.New("failed to detach programs").Base(errors.Combine(errs...))
+	}
+	return nil
+}
+
+// OriginalDestination returns the destination of a redirected connection
+// before it is redirected, by the address of its client.
+func OriginalDestination(remote net.Addr) (net.Destination, error) {
+	addr, ok := remote.(*net.TCPAddr)
+	if !ok {
+		return net.Destination{}, errors.New("not a TCP address: ", remote)
+	}
+	redirectMaps.Lock()
+	defer redirectMaps.Unlock()
+	if redirectMaps.refs == 0 {
+		return net.Destination{}, errors.New("no connection is redirected")
+	}
+	port := uint32(addr.Port)
+	var dest origDest
+	if err := lookupElem(redirectMaps.ports, unsafe.Pointer(&port), unsafe.Pointer(&dest)); err != nil {
+		return net.Destination{}, errors.New("failed to find the original destination of ", remote).Base(err)
+	}
+	deleteElem(redirectMaps.ports, unsafe.Pointer(&port))
+	ip := net.IP(dest.ip[:])
+	if dest.family == unix.AF_INET {
+		ip = net.IP(dest.ip[:4])
+	}
+	return net.TCPDestination(net.IPAddress(ip), net.Port(hostPort(dest.port))), nil
+}
+
+// cgroupRoot returns where the unified hierarchy of cgroup is mounted.
+func cgroupRoot() string {
+	for _, path := range []string{"/sys/fs/cgroup", "/sys/fs/cgroup/unified"} {
+		var st unix.Statfs_t
+		if unix.Statfs(path, &st) == nil && st.Type == unix.CGROUP2_SUPER_MAGIC {
+			return path
+		}
+	}
+	return ""
+}
@@ -0,0 +1,315 @@
+package ebpf
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	skBuffLen  = 0
+	maxSpliced = 65536
+)
+
+// The sockets being read are added to sources, whose verdict program
+// redirects what they receive to be sent by their peers in targets. A socket
+// is added to sources only after its peer is set, so that what it receives is
+// never passed back to it, which the kernel does not handle well under
+// pressure.
+var sockmap struct {
+	sync.Mutex
+	once    sync.Once
+	err     error
+	sources int
+	targets int
+	peers   int
+	verdict int
+}
+
+// verdict redirects the data received by a socket to be sent by its peer.
+// The empty buffer of FIN is dropped, which would fail to be sent once the
+// peer is shut down, and the peer would fail with EPIPE.
+func verdict(targets, peers int) *asm {
+	a := new(asm)
+	a.ldxW(r2, r1, skBuffLen)
+	a.jeqImm(r2, 0, "drop")
+	a.movReg(r6, r1)
+	a.call(fnGetSocketCookie)
+	a.stxDW(r10, -8, r0)
+	a.ldMap(r1, peers)
+	a.movReg(r2, r10)
+	a.addImm(r2, -8)
+	a.call(fnMapLookupElem)
+	a.jeqZero(r0, "pass")
+	a.ldxDW(r2, r0, 0)
+	a.stxDW(r10, -16, r2)
+	a.movReg(r1, r6)
+	a.ldMap(r2, targets)
+	a.movReg(r3, r10)
+	a.addImm(r3, -16)
+	a.movImm(r4, 0)
+	a.call(fnSkRedirectHash)
+	a.exit()
+	a.label("pass")
+	a.movImm(r0, 1) // SK_PASS
+	a.exit()
+	a.label("drop")
+	a.movImm(r0, 0) // SK_DROP
+	a.exit()
+	return a
+}
+
+func initSockmap() (err error) {
+	var fds []int
+	defer func() {
+		if err != nil {
+			for _, fd := range fds {
+				unix.Close(fd)
+			}
+		}
+	}()
+	for _, m := range []struct {
+		fd      *int
+		mapType uint32
+	}{
+		{&sockmap.sources, unix.BPF_MAP_TYPE_SOCKHASH},
+		{&sockmap.targets, unix.BPF_MAP_TYPE_SOCKHASH},
+		{&sockmap.peers, unix.BPF_MAP_TYPE_HASH},
+	} {
+		fd, err := createMap(m.mapType, 8, 8, maxSpliced)
+		if err != nil {
+			return errors.New("failed to create map").Base(err)
+		}
+		fds = append(fds, fd)
+		*m.fd = fd
+	}
+	prog, log, err := loadProgram(unix.BPF_PROG_TYPE_SK_SKB, unix.BPF_SK_SKB_STREAM_VERDICT, "xray_verdict", verdict(sockmap.targets, sockmap.peers))
+	if err != nil {
+		return errors.New("failed to load program xray_verdict: ", log).Base(err)
+	}
+	fds = append(fds, prog)
+	if err := attachProgram(sockmap.sources, prog, unix.BPF_SK_SKB_STREAM_VERDICT, 0); err != nil {
+		return errors.New("failed to attach program xray_verdict").Base(err)
+	}
+	sockmap.verdict = prog
+	return nil
+}
+
+// Supported returns why TCP connections can not be spliced with sockmap, or
+// nil if they can. The maps and the program are set up on the first call.
+func Supported() error {
+	sockmap.once.Do(func() {
+		sockmap.err = initSockmap()
+	})
+	return sockmap.err
+}
+
+func control(conn *net.TCPConn, f func(fd int) error) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ferr error
+	if err := raw.Control(func(fd uintptr) {
+		ferr = f(int(fd))
+	}); err != nil {
+		return err
+	}
+	return ferr
+}
+
+func cookie(conn *net.TCPConn) (uint64, error) {
+	var c uint64
+	err := control(conn, func(fd int) (err error) {
+		c, err = unix.GetsockoptUint64(fd, unix.SOL_SOCKET, unix.SO_COOKIE)
+		return
+	})
+	return c, err
+}
+
+// addSocket adds conn to the sockhash m, which fails unless conn is
+// established.
+func addSocket(m int, conn *net.TCPConn, cookie uint64) error {
+	sockmap.Lock()
+	defer sockmap.Unlock()
+	return control(conn, func(fd int) error {
+		value := uint64(fd)
+		err := updateElem(m, unsafe.Pointer(&cookie), unsafe.Pointer(&value), unix.BPF_NOEXIST)
+		if err == unix.EEXIST || err == unix.EBUSY {
+			// The socket may have been added by the other direction, when the
+			// kernel looks up its cookie.
+			if lookupElem(m, unsafe.Pointer(&cookie), unsafe.Pointer(&value)) == nil {
+				return nil
+			}
+		}
+		return err
+	})
+}
+
+// counts returns the bytes received by conn, those queued to be sent, and
+// those of them not sent yet.
+func counts(conn *net.TCPConn) (received, queued, unsent uint64, err error) {
+	err = control(conn, func(fd int) error {
+		info, err := unix.GetsockoptTCPInfo(fd, unix.IPPROTO_TCP, unix.TCP_INFO)
+		if err != nil {
+			return err
+		}
+		received = info.Bytes_received
+		queued = info.Bytes_sent - info.Bytes_retrans + uint64(info.Notsent_bytes)
+		unsent = uint64(info.Notsent_bytes)
+		return nil
+	})
+	return
+}
+
+// waitClosed waits for conn to receive FIN or fail, without reading it.
+func waitClosed(conn *net.TCPConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var cerr error
+	if err := raw.Read(func(fd uintptr) bool {
+		info, err := unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+		if err != nil {
+			cerr = err
+			return true
+		}
+		if info.State == unix.BPF_TCP_ESTABLISHED {
+			return false
+		}
+		if errno, _ := unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_ERROR); errno != 0 {
+			cerr = unix.Errno(errno)
+		}
+		return true
+	}); err != nil {
+		return err
+	}
+	return cerr
+}
+
+// drain copies what reader has received from reader to writer, without
+// waiting for more. It returns io.EOF once reader reaches EOF.
+func drain(reader, writer *net.TCPConn) (int64, error) {
+	b := make([]byte, 32*1024)
+	var copied int64
+	for {
+		var n int
+		err := control(reader, func(fd int) (err error) {
+			n, err = unix.Read(fd, b)
+			return
+		})
+		switch {
+		case err == unix.EAGAIN:
+			return copied, nil
+		case err != nil:
+			return copied, err
+		case n == 0:
+			return copied, io.EOF
+		}
+		if _, err := writer.Write(b[:n]); err != nil {
+			return copied, err
+		}
+		copied += int64(n)
+	}
+}
+
+// copyPlain copies from reader to writer by reading and writing, which works
+// for the sockets added to targets, unlike splice(2).
+func copyPlain(reader, writer *net.TCPConn) (int64, error) {
+	return io.Copy(struct{ io.Writer }{writer}, struct{ io.Reader }{reader})
+}
+
+// Splice copies from reader to writer until reader reaches EOF or fails, in
+// the kernel with sockmap if possible. Everything read from reader must have
+// been written to writer. Once writer is spliced, it must not be read with
+// splice(2), which does not see what it receives while in the sockhash.
+func Splice(reader, writer *net.TCPConn) (int64, error) {
+	if err := Supported(); err != nil {
+		return copyPlain(reader, writer)
+	}
+	rc, err := cookie(reader)
+	if err != nil {
+		return 0, errors.New("failed to get socket cookie").Base(err)
+	}
+	wc, err := cookie(writer)
+	if err != nil {
+		return 0, errors.New("failed to get socket cookie").Base(err)
+	}
+	if err := addSocket(sockmap.targets, writer, wc); err != nil {
+		// Such as when writer has received FIN.
+		errors.LogDebugInner(context.Background(), err, "failed to add socket to sockhash")
+		return copyPlain(reader, writer)
+	}
+	received0, _, _, err := counts(reader)
+	if err != nil {
+		return 0, err
+	}
+	_, queued0, _, err := counts(writer)
+	if err != nil {
+		return 0, err
+	}
+	if err := updateElem(sockmap.peers, unsafe.Pointer(&rc), unsafe.Pointer(&wc), unix.BPF_ANY); err != nil {
+		errors.LogDebugInner(context.Background(), err, "failed to add peer of socket")
+		return copyPlain(reader, writer)
+	}
+	defer deleteElem(sockmap.peers, unsafe.Pointer(&rc))
+
+	// The kernel redirects the first buffer received as a whole, even if it
+	// has been read partly, so what reader has received is read first.
+	drained, err := drain(reader, writer)
+	if err != nil {
+		if err == io.EOF {
+			err = nil
+		}
+		return drained, err
+	}
+	if err := addSocket(sockmap.sources, reader, rc); err != nil {
+		errors.LogDebugInner(context.Background(), err, "failed to add socket to sockhash")
+		n, err := copyPlain(reader, writer)
+		return drained + n, err
+	}
+
+	// What reader has received before it is added would wait for more to be
+	// redirected along, unless the kernel is told that it is ready to be read.
+	control(reader, func(fd int) error {
+		return unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_RCVLOWAT, 1)
+	})
+	err = waitClosed(reader)
+
+	// The redirected data is sent by the kernel asynchronously, so wait for it
+	// to be queued to writer, before writer may be closed. FIN counts as a
+	// byte received.
+	var queued, unsent uint64
+	for stalled := 0; stalled < 100; stalled++ {
+		r, _, _, cerr := counts(reader)
+		if cerr != nil {
+			break
+		}
+		_, q, u, cerr := counts(writer)
+		if cerr != nil {
+			break
+		}
+		if q-queued0 != queued || u != unsent {
+			queued, unsent = q-queued0, u
+			stalled = 0
+		} else if unsent == 0 && queued+1 >= r-received0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	deleteElem(sockmap.sources, unsafe.Pointer(&rc))
+	if err == nil {
+		// What is left, if the kernel has not redirected it before FIN.
+		var n int64
+		n, err = copyPlain(reader, writer)
+		queued += uint64(n)
+	}
+	return int64(queued), err
+}
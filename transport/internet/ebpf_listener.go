@@ -0,0 +1,40 @@
+package internet
+
+import (
+	"context"
+	"sync"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet/ebpf"
+)
+
+// ebpfListener detaches the programs redirecting to it when it is closed.
+type ebpfListener struct {
+	net.Listener
+	redirector *ebpf.Redirector
+	once       sync.Once
+}
+
+func ebpfListen(l net.Listener, sockopt *SocketConfig) (net.Listener, error) {
+	addr, ok := l.Addr().(*net.TCPAddr)
+	if !ok {
+		l.Close()
+		return nil, errors.New("eBPF mode is only supported by TCP listeners")
+	}
+	r, err := ebpf.Redirect(sockopt.EbpfCgroup, addr)
+	if err != nil {
+		l.Close()
+		return nil, errors.New("failed to redirect connections to ", addr, " with eBPF").Base(err)
+	}
+	return &ebpfListener{Listener: l, redirector: r}, nil
+}
+
+func (l *ebpfListener) Close() error {
+	l.once.Do(func() {
+		if err := l.redirector.Close(); err != nil {
+			errors.LogWarningInner(context.Background(), err, "failed to stop redirecting connections to ", l.Addr())
+		}
+	})
+	return l.Listener.Close()
+}
@@ -42,15 +42,21 @@ type dialerConf struct {
 	*internet.MemoryStreamConfig
 }
 
+// grpcClient is a connection of an outbound, with the number of its streams.
+type grpcClient struct {
+	*grpc.ClientConn
+	streams uint32
+}
+
 var (
-	globalDialerMap    map[dialerConf]*grpc.ClientConn
+	globalDialerMap    map[dialerConf][]*grpcClient
 	globalDialerAccess sync.Mutex
 )
 
 func dialgRPC(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (net.Conn, error) {
 	grpcSettings := streamSettings.ProtocolSettings.(*Config)
 
-	conn, err := getGrpcClient(ctx, dest, streamSettings)
+	conn, release, err := getGrpcClient(ctx, dest, streamSettings)
 	if err != nil {
 		return nil, errors.New("Cannot dial gRPC").Base(err)
 	}
@@ -59,36 +65,73 @@ func dialgRPC(ctx context.Context, dest net.Destination, streamSettings *interne
 		errors.LogDebug(ctx, "using gRPC multi mode service name: `"+grpcSettings.getServiceName()+"` stream name: `"+grpcSettings.getTunMultiStreamName()+"`")
 		grpcService, err := client.(encoding.GRPCServiceClientX).TunMultiCustomName(ctx, grpcSettings.getServiceName(), grpcSettings.getTunMultiStreamName())
 		if err != nil {
+			release()
 			return nil, errors.New("Cannot dial gRPC").Base(err)
 		}
-		return encoding.NewMultiHunkConn(grpcService, nil), nil
+		return encoding.NewMultiHunkConn(grpcService, release), nil
 	}
 
 	errors.LogDebug(ctx, "using gRPC tun mode service name: `"+grpcSettings.getServiceName()+"` stream name: `"+grpcSettings.getTunStreamName()+"`")
 	grpcService, err := client.(encoding.GRPCServiceClientX).TunCustomName(ctx, grpcSettings.getServiceName(), grpcSettings.getTunStreamName())
 	if err != nil {
+		release()
 		return nil, errors.New("Cannot dial gRPC").Base(err)
 	}
 
-	return encoding.NewHunkConn(grpcService, nil), nil
+	return encoding.NewHunkConn(grpcService, release), nil
 }
 
-func getGrpcClient(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (*grpc.ClientConn, error) {
+// getGrpcClient returns a connection with less than max_concurrent_streams
+// streams, and the function to call once the new stream is closed.
+func getGrpcClient(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (*grpc.ClientConn, func(), error) {
 	globalDialerAccess.Lock()
 	defer globalDialerAccess.Unlock()
 
 	if globalDialerMap == nil {
-		globalDialerMap = make(map[dialerConf]*grpc.ClientConn)
+		globalDialerMap = make(map[dialerConf][]*grpcClient)
+	}
+	key := dialerConf{dest, streamSettings}
+	maxStreams := streamSettings.ProtocolSettings.(*Config).MaxConcurrentStreams
+
+	var client *grpcClient
+	clients := globalDialerMap[key][:0]
+	for _, c := range globalDialerMap[key] {
+		if c.GetState() == connectivity.Shutdown {
+			continue
+		}
+		clients = append(clients, c)
+		if client == nil && (maxStreams == 0 || c.streams < maxStreams) {
+			client = c
+		}
+	}
+	if client == nil {
+		conn, err := newGrpcClient(ctx, dest, streamSettings)
+		if err != nil {
+			globalDialerMap[key] = clients
+			return nil, nil, err
+		}
+		client = &grpcClient{ClientConn: conn}
+		clients = append(clients, client)
 	}
+	globalDialerMap[key] = clients
+
+	client.streams++
+	var once sync.Once
+	return client.ClientConn, func() {
+		once.Do(func() {
+			globalDialerAccess.Lock()
+			client.streams--
+			globalDialerAccess.Unlock()
+		})
+	}, nil
+}
+
+func newGrpcClient(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (*grpc.ClientConn, error) {
 	tlsConfig := tls.ConfigFromStreamSettings(streamSettings)
 	realityConfig := reality.ConfigFromStreamSettings(streamSettings)
 	sockopt := streamSettings.SocketSettings
 	grpcSettings := streamSettings.ProtocolSettings.(*Config)
 
-	if client, found := globalDialerMap[dialerConf{dest, streamSettings}]; found && client.GetState() != connectivity.Shutdown {
-		return client, nil
-	}
-
 	dialOptions := []grpc.DialOption{
 		grpc.WithConnectParams(grpc.ConnectParams{
 			Backoff: backoff.Config{
@@ -168,6 +211,10 @@ func getGrpcClient(ctx context.Context, dest net.Destination, streamSettings *in
 		dialOptions = append(dialOptions, grpc.WithInitialWindowSize(grpcSettings.InitialWindowsSize))
 	}
 
+	if grpcSettings.InitialConnWindowSize > 0 {
+		dialOptions = append(dialOptions, grpc.WithInitialConnWindowSize(grpcSettings.InitialConnWindowSize))
+	}
+
 	if grpcSettings.UserAgent != "" {
 		dialOptions = append(dialOptions, grpc.WithUserAgent(grpcSettings.UserAgent))
 	}
@@ -179,10 +226,8 @@ func getGrpcClient(ctx context.Context, dest net.Destination, streamSettings *in
 		grpcDestHost = dest.Address.IP().String()
 	}
 
-	conn, err := grpc.Dial(
+	return grpc.Dial(
 		gonet.JoinHostPort(grpcDestHost, dest.Port.String()),
 		dialOptions...,
 	)
-	globalDialerMap[dialerConf{dest, streamSettings}] = conn
-	return conn, err
 }
@@ -4,7 +4,6 @@ import (
 	"context"
 	"time"
 
-	goreality "github.com/xtls/reality"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
@@ -89,6 +88,23 @@ func Listen(ctx context.Context, address net.Address, port net.Port, settings *i
 			Timeout: time.Second * time.Duration(grpcSettings.HealthCheckTimeout),
 		}))
 	}
+	if grpcSettings.KeepaliveMinTime > 0 || grpcSettings.PermitWithoutStream {
+		// Otherwise clients pinging more often than every 5 minutes are
+		// disconnected with too_many_pings.
+		options = append(options, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             time.Second * time.Duration(grpcSettings.KeepaliveMinTime),
+			PermitWithoutStream: grpcSettings.PermitWithoutStream,
+		}))
+	}
+	if grpcSettings.InitialWindowsSize > 0 {
+		options = append(options, grpc.InitialWindowSize(grpcSettings.InitialWindowsSize))
+	}
+	if grpcSettings.InitialConnWindowSize > 0 {
+		options = append(options, grpc.InitialConnWindowSize(grpcSettings.InitialConnWindowSize))
+	}
+	if grpcSettings.MaxConcurrentStreams > 0 {
+		options = append(options, grpc.MaxConcurrentStreams(grpcSettings.MaxConcurrentStreams))
+	}
 
 	s = grpc.NewServer(options...)
 	listener.s = s
@@ -124,7 +140,9 @@ func Listen(ctx context.Context, address net.Address, port net.Port, settings *i
 		encoding.RegisterGRPCServiceServerX(s, listener, grpcSettings.getServiceName(), grpcSettings.getTunStreamName(), grpcSettings.getTunMultiStreamName())
 
 		if config := reality.ConfigFromStreamSettings(settings); config != nil {
-			streamListener = goreality.NewListener(streamListener, config.GetREALITYConfig())
+			realityConfig := config.GetREALITYConfig()
+			streamListener = reality.NewListener(streamListener, realityConfig, config.GetREALITYTargets(realityConfig))
+			defer common.Close(config.CheckTargets())
 		}
 		if err = s.Serve(streamListener); err != nil {
 			errors.LogInfoInner(ctx, err, "Listener for gRPC ended")
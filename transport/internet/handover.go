@@ -0,0 +1,47 @@
+package internet
+
+import (
+	"os"
+	"sync"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+// filer is a socket whose file can be passed to another process.
+type filer interface {
+	File() (*os.File, error)
+}
+
+// listenedSockets are the sockets listened on by this process, keyed by
+// socketKey, for the process upgrading this one to inherit them.
+var listenedSockets = struct {
+	sync.Mutex
+	sockets map[string]filer
+}{sockets: make(map[string]filer)}
+
+// socketKey returns the key of the socket listened on addr, or "" for those
+// of random ports, which are not inherited.
+func socketKey(addr net.Addr) string {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		if addr.Port == 0 {
+			return ""
+		}
+	case *net.UDPAddr:
+		if addr.Port == 0 {
+			return ""
+		}
+	}
+	return addr.Network() + " " + addr.String()
+}
+
+// recordListened records socket listened on the address of key.
+func recordListened(key string, socket interface{}) {
+	f, ok := socket.(filer)
+	if key == "" || !ok {
+		return
+	}
+	listenedSockets.Lock()
+	listenedSockets.sockets[key] = f
+	listenedSockets.Unlock()
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package internet
+
+import (
+	"github.com/xtls/xray-core/common/net"
+)
+
+func inheritedListener(key string) (net.Listener, error) {
+	return nil, nil
+}
+
+func inheritedPacketConn(key string) (net.PacketConn, error) {
+	return nil, nil
+}
@@ -0,0 +1,188 @@
+//go:build unix
+
+package internet
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+)
+
+// inheritedEnv passes the sockets to a process upgrading this one, as a JSON
+// object of their keys to their file descriptors.
+const inheritedEnv = "XRAY_INHERITED_SOCKETS"
+
+var inheritedSockets struct {
+	sync.Mutex
+	once  sync.Once
+	files map[string]*os.File
+	// unix are the listeners of inherited Unix domain sockets, which unlink
+	// their paths on close only once the sockets are released.
+	unix []*net.UnixListener
+}
+
+// loadInheritedSockets takes the sockets passed to this process in
+// inheritedEnv, and unsets it so that the processes it starts do not take
+// them.
+func loadInheritedSockets() {
+	inheritedSockets.files = make(map[string]*os.File)
+	value := os.Getenv(inheritedEnv)
+	os.Unsetenv(inheritedEnv)
+	if value == "" {
+		return
+	}
+	var fds map[string]int
+	if err := json.Unmarshal([]byte(value), &fds); err != nil {
+		errors.LogWarningInner(context.Background(), err, "failed to parse ", inheritedEnv)
+		return
+	}
+	for key, fd := range fds {
+		syscall.CloseOnExec(fd)
+		inheritedSockets.files[key] = os.NewFile(uintptr(fd), key)
+	}
+}
+
+// takeInherited returns the inherited socket of key, which the caller
+// closes, or nil if there is none.
+func takeInherited(key string) *os.File {
+	inheritedSockets.Lock()
+	defer inheritedSockets.Unlock()
+	inheritedSockets.once.Do(loadInheritedSockets)
+	file := inheritedSockets.files[key]
+	delete(inheritedSockets.files, key)
+	return file
+}
+
+// inheritedListener returns a listener of the inherited socket of key, or
+// nil if there is none.
+func inheritedListener(key string) (net.Listener, error) {
+	if key == "" {
+		return nil, nil
+	}
+	file := takeInherited(key)
+	if file == nil {
+		return nil, nil
+	}
+	defer file.Close()
+	l, err := net.FileListener(file)
+	if err != nil {
+		return nil, errors.New("failed to listen on inherited socket ", key).Base(err)
+	}
+	if l, ok := l.(*net.UnixListener); ok {
+		inheritedSockets.Lock()
+		inheritedSockets.unix = append(inheritedSockets.unix, l)
+		inheritedSockets.Unlock()
+		return &UnixListenerWrapper{UnixListener: l}, nil
+	}
+	return l, nil
+}
+
+// inheritedPacketConn is inheritedListener for packet sockets.
+func inheritedPacketConn(key string) (net.PacketConn, error) {
+	if key == "" {
+		return nil, nil
+	}
+	file := takeInherited(key)
+	if file == nil {
+		return nil, nil
+	}
+	defer file.Close()
+	conn, err := net.FilePacketConn(file)
+	if err != nil {
+		return nil, errors.New("failed to listen on inherited socket ", key).Base(err)
+	}
+	return conn, nil
+}
+
+// StartWithListenedSockets starts cmd with the sockets listened on by this
+// process, which it listens on instead of new ones of the same addresses. It
+// returns the files passed, for InheritSockets to take them back if cmd fails.
+//
+// xray:api:beta
+func StartWithListenedSockets(cmd *exec.Cmd) (map[string]*os.File, error) {
+	listenedSockets.Lock()
+	defer listenedSockets.Unlock()
+	files := make(map[string]*os.File)
+	fds := make(map[string]int)
+	for key, socket := range listenedSockets.sockets {
+		file, err := socket.File()
+		if err != nil {
+			// The socket is closed.
+			delete(listenedSockets.sockets, key)
+			continue
+		}
+		if l, ok := socket.(interface{ SetUnlinkOnClose(bool) }); ok {
+			// The path is of the socket of cmd as well.
+			l.SetUnlinkOnClose(false)
+		}
+		files[key] = file
+		fds[key] = 3 + len(cmd.ExtraFiles)
+		cmd.ExtraFiles = append(cmd.ExtraFiles, file)
+	}
+	value, err := json.Marshal(fds)
+	if err == nil {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, inheritedEnv+"="+string(value))
+		err = cmd.Start()
+	}
+	for _, file := range files {
+		// Starting cmd makes the sockets blocking, for this process as well,
+		// since the files share their descriptions.
+		if c, e := file.SyscallConn(); e == nil {
+			c.Control(func(fd uintptr) {
+				syscall.SetNonblock(int(fd), true)
+			})
+		}
+		if err != nil {
+			file.Close()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// InheritSockets makes the sockets of files listened on instead of new ones
+// of the same addresses, such as the files of StartWithListenedSockets.
+//
+// xray:api:beta
+func InheritSockets(files map[string]*os.File) {
+	inheritedSockets.Lock()
+	defer inheritedSockets.Unlock()
+	inheritedSockets.once.Do(loadInheritedSockets)
+	for key, file := range files {
+		if old := inheritedSockets.files[key]; old != nil {
+			old.Close()
+		}
+		inheritedSockets.files[key] = file
+	}
+}
+
+// ReleaseInheritedSockets closes the inherited sockets which are not listened
+// on, such as those of the inbounds removed from the config, once the process
+// they are inherited from no longer needs them.
+//
+// xray:api:beta
+func ReleaseInheritedSockets() {
+	inheritedSockets.Lock()
+	defer inheritedSockets.Unlock()
+	inheritedSockets.once.Do(loadInheritedSockets)
+	for key, file := range inheritedSockets.files {
+		errors.LogInfo(context.Background(), "closing inherited socket ", key, " not listened on")
+		file.Close()
+		delete(inheritedSockets.files, key)
+	}
+	for _, l := range inheritedSockets.unix {
+		l.SetUnlinkOnClose(true)
+	}
+	inheritedSockets.unix = nil
+}
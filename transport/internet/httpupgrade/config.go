@@ -1,7 +1,12 @@
 package httpupgrade
 
 import (
+	"regexp"
+	"strconv"
+	"strings"
+
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/dice"
 	"github.com/xtls/xray-core/transport/internet"
 )
 
@@ -16,6 +21,80 @@ func (c *Config) GetNormalizedPath() string {
 	return path
 }
 
+// pathPlaceholder matches the random parts of a path, like {hex:16}.
+var pathPlaceholder = regexp.MustCompile(`\{(rand|hex|num):(\d{1,3})\}`)
+
+var pathAlphabets = map[string]string{
+	"rand": "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+	"hex":  "0123456789abcdef",
+	"num":  "0123456789",
+}
+
+var pathClasses = map[string]string{
+	"rand": "[a-zA-Z0-9]",
+	"hex":  "[0-9a-f]",
+	"num":  "[0-9]",
+}
+
+// newPath returns the normalized path with its placeholders filled at random.
+func (c *Config) newPath() string {
+	return pathPlaceholder.ReplaceAllStringFunc(c.GetNormalizedPath(), func(placeholder string) string {
+		match := pathPlaceholder.FindStringSubmatch(placeholder)
+		alphabet := pathAlphabets[match[1]]
+		n, _ := strconv.Atoi(match[2])
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alphabet[dice.Roll(len(alphabet))]
+		}
+		return string(b)
+	})
+}
+
+// pathPattern returns the pattern of the paths newPath returns, or nil if they
+// are all the normalized path.
+func (c *Config) pathPattern() *regexp.Regexp {
+	path := c.GetNormalizedPath()
+	matches := pathPlaceholder.FindAllStringSubmatchIndex(path, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	last := 0
+	for _, match := range matches {
+		pattern.WriteString(regexp.QuoteMeta(path[last:match[0]]))
+		pattern.WriteString(pathClasses[path[match[2]:match[3]]] + "{" + path[match[4]:match[5]] + "}")
+		last = match[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(path[last:]) + "$")
+	return regexp.MustCompile(pattern.String())
+}
+
+// newHost returns the host to send on a connection, or "" for the TLS server
+// name or the address.
+func (c *Config) newHost() string {
+	if len(c.Hosts) > 0 {
+		return c.Hosts[dice.Roll(len(c.Hosts))]
+	}
+	return c.Host
+}
+
+// isValidHost reports whether listeners accept the host of a request.
+func (c *Config) isValidHost(host string) bool {
+	if c.Host == "" && len(c.Hosts) == 0 {
+		return true
+	}
+	if c.Host != "" && internet.IsValidHTTPHost(host, c.Host) {
+		return true
+	}
+	for _, h := range c.Hosts {
+		if internet.IsValidHTTPHost(host, h) {
+			return true
+		}
+	}
+	return false
+}
+
 func init() {
 	common.Must(internet.RegisterProtocolConfigCreator(protocolName, func() interface{} {
 		return new(Config)
@@ -17,3 +17,8 @@ func newConnection(conn net.Conn, remoteAddr net.Addr) *connection {
 func (c *connection) RemoteAddr() net.Addr {
 	return c.remoteAddr
 }
+
+// PeerAddr implements stat.ForwardedConnection.
+func (c *connection) PeerAddr() net.Addr {
+	return c.Conn.RemoteAddr()
+}
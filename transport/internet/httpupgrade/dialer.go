@@ -70,14 +70,14 @@ func dialhttpUpgrade(ctx context.Context, dest net.Destination, streamSettings *
 		requestURL.Scheme = "http"
 	}
 
-	requestURL.Host = transportConfiguration.Host
+	requestURL.Host = transportConfiguration.newHost()
 	if requestURL.Host == "" && tConfig != nil {
 		requestURL.Host = tConfig.ServerName
 	}
 	if requestURL.Host == "" {
 		requestURL.Host = dest.Address.String()
 	}
-	requestURL.Path = transportConfiguration.GetNormalizedPath()
+	requestURL.Path = transportConfiguration.newPath()
 	req := &http.Request{
 		Method: http.MethodGet,
 		URL:    &requestURL,
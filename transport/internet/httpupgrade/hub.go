@@ -5,12 +5,14 @@ import (
 	"context"
 	"crypto/tls"
 	"net/http"
+	"regexp"
 	"strings"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	http_proto "github.com/xtls/xray-core/common/protocol/http"
+	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/transport/internet"
 	"github.com/xtls/xray-core/transport/internet/stat"
 	v2tls "github.com/xtls/xray-core/transport/internet/tls"
@@ -18,8 +20,10 @@ import (
 
 type server struct {
 	config         *Config
+	path           *regexp.Regexp
 	addConn        internet.ConnHandler
 	innnerListener net.Listener
+	gate           session.ListenGate
 }
 
 func (s *server) Close() error {
@@ -39,11 +43,14 @@ func (s *server) Handle(conn net.Conn) (stat.Connection, error) {
 
 	if s.config != nil {
 		host := req.Host
-		if len(s.config.Host) > 0 && !internet.IsValidHTTPHost(host, s.config.Host) {
+		if !s.config.isValidHost(host) {
 			return nil, errors.New("bad host: ", host)
 		}
-		path := s.config.GetNormalizedPath()
-		if req.URL.Path != path {
+		if s.path != nil {
+			if !s.path.MatchString(req.URL.Path) {
+				return nil, errors.New("bad path: ", req.URL.Path)
+			}
+		} else if req.URL.Path != s.config.GetNormalizedPath() {
 			return nil, errors.New("bad path: ", req.URL.Path)
 		}
 	}
@@ -54,6 +61,11 @@ func (s *server) Handle(conn net.Conn) (stat.Connection, error) {
 		_ = conn.Close()
 		return nil, errors.New("unrecognized request")
 	}
+	forwardedAddrs := http_proto.ParseXForwardedFor(req.Header)
+	if len(forwardedAddrs) > 0 && forwardedAddrs[0].Family().IsIP() && s.gate != nil && !s.gate.AllowForwarded(forwardedAddrs[0].IP()) {
+		_ = conn.Close()
+		return nil, errors.New("forwarded source not allowed: ", forwardedAddrs[0])
+	}
 	resp := &http.Response{
 		Status:     "101 Switching Protocols",
 		StatusCode: 101,
@@ -62,6 +74,11 @@ func (s *server) Handle(conn net.Conn) (stat.Connection, error) {
 		ProtoMinor: 1,
 		Header:     http.Header{},
 	}
+	if s.config != nil {
+		for key, value := range s.config.ResponseHeader {
+			AddHeader(resp.Header, key, value)
+		}
+	}
 	resp.Header.Set("Connection", "Upgrade")
 	resp.Header.Set("Upgrade", "websocket")
 	err = resp.Write(conn)
@@ -70,7 +87,6 @@ func (s *server) Handle(conn net.Conn) (stat.Connection, error) {
 		return nil, err
 	}
 
-	forwardedAddrs := http_proto.ParseXForwardedFor(req.Header)
 	remoteAddr := conn.RemoteAddr()
 	if len(forwardedAddrs) > 0 && forwardedAddrs[0].Family().IsIP() {
 		remoteAddr = &net.TCPAddr{
@@ -141,6 +157,10 @@ func ListenHTTPUpgrade(ctx context.Context, address net.Address, port net.Port,
 		config:         transportConfiguration,
 		addConn:        addConn,
 		innnerListener: listener,
+		gate:           session.ListenGateFromContext(ctx),
+	}
+	if transportConfiguration != nil {
+		serverInstance.path = transportConfiguration.pathPattern()
 	}
 	go serverInstance.keepAccepting()
 	return serverInstance, nil
@@ -0,0 +1,53 @@
+package kcp
+
+// bbrRounds is how many rounds the bottleneck bandwidth is the highest
+// delivery rate of.
+const bbrRounds = 10
+
+// bbrMinRTTExpiry is how long the minimum RTT lasts without being seen again,
+// in milliseconds.
+const bbrMinRTTExpiry = 10000
+
+// bbrGains cycle the window through probing for more bandwidth and draining
+// the queue it builds, in quarters.
+var bbrGains = [...]uint32{5, 3, 4, 4, 4, 4, 4, 4}
+
+// bbrController estimates the bandwidth-delay product of the path from the
+// delivery rate and the minimum RTT, like BBR, and is indifferent to loss
+// that does not slow deliveries.
+type bbrController struct {
+	minRTT      uint32
+	minRTTStamp uint32
+	delivered   uint32
+	roundStart  uint32
+	rates       [bbrRounds]uint32
+	round       int
+}
+
+// OnAck records acked segments, with the RTT the ack measured if any, and
+// returns the window for the bandwidth-delay product, or 0 before it is
+// known.
+func (c *bbrController) OnAck(current, rtt, acked uint32) uint32 {
+	if rtt > 0 && (c.minRTT == 0 || rtt <= c.minRTT || current-c.minRTTStamp > bbrMinRTTExpiry) {
+		c.minRTT = rtt
+		c.minRTTStamp = current
+	}
+	if c.roundStart == 0 {
+		c.roundStart = current
+	}
+	c.delivered += acked
+	if elapsed := current - c.roundStart; c.minRTT > 0 && elapsed >= c.minRTT {
+		c.rates[c.round%bbrRounds] = c.delivered * 1000 / elapsed
+		c.round++
+		c.delivered = 0
+		c.roundStart = current
+	}
+
+	var bandwidth uint32
+	for _, rate := range c.rates {
+		bandwidth = max(bandwidth, rate)
+	}
+	// Twice the product keeps the window from limiting deliveries between
+	// acks.
+	return 2 * bandwidth * c.minRTT / 1000 * bbrGains[c.round%len(bbrGains)] / 4
+}
@@ -76,6 +76,9 @@ func (c *Config) GetPackerHeader() (internet.PacketHeader, error) {
 }
 
 func (c *Config) GetSendingInFlightSize() uint32 {
+	if c.SendWindow > 0 {
+		return c.SendWindow
+	}
 	size := c.GetUplinkCapacityValue() * 1024 * 1024 / c.GetMTUValue() / (1000 / c.GetTTIValue())
 	if size < 8 {
 		size = 8
@@ -88,6 +91,9 @@ func (c *Config) GetSendingBufferSize() uint32 {
 }
 
 func (c *Config) GetReceivingInFlightSize() uint32 {
+	if c.ReceiveWindow > 0 {
+		return c.ReceiveWindow
+	}
 	size := c.GetDownlinkCapacityValue() * 1024 * 1024 / c.GetMTUValue() / (1000 / c.GetTTIValue())
 	if size < 8 {
 		size = 8
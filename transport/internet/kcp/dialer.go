@@ -67,10 +67,12 @@ func DialKCP(ctx context.Context, dest net.Destination, streamSettings *internet
 	reader := &KCPPacketReader{
 		Header:   header,
 		Security: security,
+		FEC:      newFECDecoder(kcpSettings),
 	}
 	writer := &KCPPacketWriter{
 		Header:   header,
 		Security: security,
+		FEC:      newFECEncoder(kcpSettings),
 		Writer:   rawConn,
 	}
 
@@ -0,0 +1,312 @@
+package kcp
+
+import (
+	"encoding/binary"
+)
+
+// Packets with forward error correction start with the sequence number of
+// their shard. The shards of a group are its data shards, each being the size
+// and payload of a packet, followed by Reed-Solomon parity shards over them
+// padded to the same length, so that any dataShards of the group recover the
+// others.
+const (
+	fecSequenceSize = 4
+	fecSizeSize     = 2
+	fecOverhead     = fecSequenceSize + fecSizeSize
+)
+
+// fecGroupSpan is how many groups behind the latest one are still recovered.
+const fecGroupSpan = 32
+
+// GF(2^8) with the polynomial x^8 + x^4 + x^3 + x^2 + 1.
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+	gfMul [256][256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < 510; i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			gfMul[a][b] = gfExp[int(gfLog[a])+int(gfLog[b])]
+		}
+	}
+}
+
+func gfInverse(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// mulAdd adds c times src to dst.
+func mulAdd(dst, src []byte, c byte) {
+	table := &gfMul[c]
+	for i, b := range src {
+		dst[i] ^= table[b]
+	}
+}
+
+// parityMatrix returns the Cauchy matrix whose rows make the parity shards,
+// any square submatrix of which is invertible.
+func parityMatrix(dataShards, parityShards int) [][]byte {
+	matrix := make([][]byte, parityShards)
+	for i := range matrix {
+		matrix[i] = make([]byte, dataShards)
+		for j := range matrix[i] {
+			matrix[i][j] = gfInverse(byte(dataShards+i) ^ byte(j))
+		}
+	}
+	return matrix
+}
+
+// invert inverts a square matrix in place, or reports that it is singular.
+func invert(matrix [][]byte) bool {
+	n := len(matrix)
+	inverse := make([][]byte, n)
+	for i := range inverse {
+		inverse[i] = make([]byte, n)
+		inverse[i][i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := col
+		for pivot < n && matrix[pivot][col] == 0 {
+			pivot++
+		}
+		if pivot == n {
+			return false
+		}
+		matrix[col], matrix[pivot] = matrix[pivot], matrix[col]
+		inverse[col], inverse[pivot] = inverse[pivot], inverse[col]
+		if c := matrix[col][col]; c != 1 {
+			c = gfInverse(c)
+			for j := 0; j < n; j++ {
+				matrix[col][j] = gfMul[c][matrix[col][j]]
+				inverse[col][j] = gfMul[c][inverse[col][j]]
+			}
+		}
+		for row := 0; row < n; row++ {
+			if c := matrix[row][col]; row != col && c != 0 {
+				mulAdd(matrix[row], matrix[col], c)
+				mulAdd(inverse[row], inverse[col], c)
+			}
+		}
+	}
+	copy(matrix, inverse)
+	return true
+}
+
+type fecEncoder struct {
+	dataShards   int
+	parityShards int
+	matrix       [][]byte
+	next         uint32
+	shards       [][]byte
+	maxSize      int
+}
+
+func newFECEncoder(config *Config) *fecEncoder {
+	if config.ParityShards == 0 {
+		return nil
+	}
+	return &fecEncoder{
+		dataShards:   int(config.DataShards),
+		parityShards: int(config.ParityShards),
+		matrix:       parityMatrix(int(config.DataShards), int(config.ParityShards)),
+	}
+}
+
+// encode passes the data shard of b to write, followed by the parity shards
+// once it completes a group.
+func (e *fecEncoder) encode(b []byte, write func([]byte) error) error {
+	groupSize := uint32(e.dataShards + e.parityShards)
+	if e.next%groupSize == 0 && e.next > ^uint32(0)-groupSize {
+		// Groups never straddle the wraparound.
+		e.next = 0
+	}
+	packet := make([]byte, fecOverhead+len(b))
+	binary.BigEndian.PutUint32(packet, e.next)
+	binary.BigEndian.PutUint16(packet[fecSequenceSize:], uint16(len(b)))
+	copy(packet[fecOverhead:], b)
+	e.next++
+	if err := write(packet); err != nil {
+		return err
+	}
+
+	e.shards = append(e.shards, packet[fecSequenceSize:])
+	e.maxSize = max(e.maxSize, len(packet)-fecSequenceSize)
+	if len(e.shards) < e.dataShards {
+		return nil
+	}
+	parity := make([][]byte, e.parityShards)
+	for i := range parity {
+		parity[i] = make([]byte, fecSequenceSize+e.maxSize)
+		binary.BigEndian.PutUint32(parity[i], e.next)
+		e.next++
+		for j, shard := range e.shards {
+			mulAdd(parity[i][fecSequenceSize:], shard, e.matrix[i][j])
+		}
+	}
+	e.shards = e.shards[:0]
+	e.maxSize = 0
+	for _, packet := range parity {
+		if err := write(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type fecGroup struct {
+	shards   [][]byte
+	received int
+	done     bool
+}
+
+type fecDecoder struct {
+	dataShards   int
+	parityShards int
+	matrix       [][]byte
+	groups       map[uint32]*fecGroup
+	latest       uint32
+	// sessions counts the connections from the address it decodes for.
+	sessions int
+}
+
+func newFECDecoder(config *Config) *fecDecoder {
+	if config.ParityShards == 0 {
+		return nil
+	}
+	return &fecDecoder{
+		dataShards:   int(config.DataShards),
+		parityShards: int(config.ParityShards),
+		matrix:       parityMatrix(int(config.DataShards), int(config.ParityShards)),
+		groups:       make(map[uint32]*fecGroup),
+	}
+}
+
+func dataShardPayload(shard []byte) []byte {
+	if len(shard) < fecSizeSize {
+		return nil
+	}
+	size := int(binary.BigEndian.Uint16(shard))
+	if fecSizeSize+size > len(shard) {
+		return nil
+	}
+	return shard[fecSizeSize : fecSizeSize+size]
+}
+
+// decode returns the payload of a data shard, and those the shard recovers.
+func (d *fecDecoder) decode(packet []byte) [][]byte {
+	if len(packet) < fecOverhead {
+		return nil
+	}
+	groupSize := uint32(d.dataShards + d.parityShards)
+	sequence := binary.BigEndian.Uint32(packet)
+	id, index := sequence/groupSize, int(sequence%groupSize)
+	shard := packet[fecSequenceSize:]
+
+	var payloads [][]byte
+	if index < d.dataShards {
+		payload := dataShardPayload(shard)
+		if payload == nil {
+			return nil
+		}
+		payloads = append(payloads, payload)
+	}
+
+	group := d.groups[id]
+	if group == nil {
+		if id-d.latest < 1<<31 || d.latest-id >= fecGroupSpan {
+			// Newer, or the peer starting over.
+			d.latest = id
+			for other := range d.groups {
+				if d.latest-other >= fecGroupSpan {
+					delete(d.groups, other)
+				}
+			}
+		}
+		group = &fecGroup{shards: make([][]byte, groupSize)}
+		d.groups[id] = group
+	}
+	if group.done || group.shards[index] != nil {
+		return payloads
+	}
+	group.shards[index] = append([]byte(nil), shard...)
+	group.received++
+	if group.received < d.dataShards {
+		return payloads
+	}
+
+	group.done = true
+	recovered := d.reconstruct(group.shards)
+	group.shards = nil
+	return append(payloads, recovered...)
+}
+
+// reconstruct returns the payloads of the missing data shards, from the
+// dataShards shards received.
+func (d *fecDecoder) reconstruct(shards [][]byte) [][]byte {
+	var missing []int
+	for i := 0; i < d.dataShards; i++ {
+		if shards[i] == nil {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	size := 0
+	for _, shard := range shards[d.dataShards:] {
+		if shard != nil {
+			size = len(shard)
+			break
+		}
+	}
+	matrix := make([][]byte, 0, d.dataShards)
+	inputs := make([][]byte, 0, d.dataShards)
+	for i, shard := range shards {
+		if shard == nil || len(matrix) == d.dataShards {
+			continue
+		}
+		if len(shard) > size {
+			return nil
+		}
+		row := make([]byte, d.dataShards)
+		if i < d.dataShards {
+			row[i] = 1
+		} else {
+			copy(row, d.matrix[i-d.dataShards])
+		}
+		matrix = append(matrix, row)
+		input := make([]byte, size)
+		copy(input, shard)
+		inputs = append(inputs, input)
+	}
+	if !invert(matrix) {
+		return nil
+	}
+
+	var payloads [][]byte
+	for _, i := range missing {
+		shard := make([]byte, size)
+		for j, input := range inputs {
+			mulAdd(shard, input, matrix[i][j])
+		}
+		if payload := dataShardPayload(shard); payload != nil {
+			payloads = append(payloads, payload)
+		}
+	}
+	return payloads
+}
@@ -22,9 +22,15 @@ type PacketWriter interface {
 type KCPPacketReader struct {
 	Security cipher.AEAD
 	Header   internet.PacketHeader
+	FEC      *fecDecoder
 }
 
 func (r *KCPPacketReader) Read(b []byte) []Segment {
+	return r.read(b, r.FEC)
+}
+
+// read reads the packets of a peer with the FEC decoder of the peer.
+func (r *KCPPacketReader) read(b []byte, fec *fecDecoder) []Segment {
 	if r.Header != nil {
 		if int32(len(b)) <= r.Header.Size() {
 			return nil
@@ -43,6 +49,17 @@ func (r *KCPPacketReader) Read(b []byte) []Segment {
 		}
 		b = out
 	}
+	if fec == nil {
+		return readSegments(b)
+	}
+	var result []Segment
+	for _, payload := range fec.decode(b) {
+		result = append(result, readSegments(payload)...)
+	}
+	return result
+}
+
+func readSegments(b []byte) []Segment {
 	var result []Segment
 	for len(b) > 0 {
 		seg, x := ReadSegment(b)
@@ -58,6 +75,7 @@ func (r *KCPPacketReader) Read(b []byte) []Segment {
 type KCPPacketWriter struct {
 	Header   internet.PacketHeader
 	Security cipher.AEAD
+	FEC      *fecEncoder
 	Writer   io.Writer
 }
 
@@ -69,10 +87,20 @@ func (w *KCPPacketWriter) Overhead() int {
 	if w.Security != nil {
 		overhead += w.Security.Overhead()
 	}
+	if w.FEC != nil {
+		overhead += fecOverhead
+	}
 	return overhead
 }
 
 func (w *KCPPacketWriter) Write(b []byte) (int, error) {
+	if w.FEC != nil {
+		return len(b), w.FEC.encode(b, w.writePacket)
+	}
+	return len(b), w.writePacket(b)
+}
+
+func (w *KCPPacketWriter) writePacket(b []byte) error {
 	bb := buf.StackNew()
 	defer bb.Release()
 
@@ -91,5 +119,5 @@ func (w *KCPPacketWriter) Write(b []byte) (int, error) {
 	}
 
 	_, err := w.Writer.Write(bb.Bytes())
-	return len(b), err
+	return err
 }
@@ -26,10 +26,11 @@ type ConnectionID struct {
 type Listener struct {
 	sync.Mutex
 	sessions  map[ConnectionID]*Connection
+	decoders  map[net.Destination]*fecDecoder
 	hub       *udp.Hub
 	tlsConfig *gotls.Config
 	config    *Config
-	reader    PacketReader
+	reader    *KCPPacketReader
 	header    internet.PacketHeader
 	security  cipher.AEAD
 	addConn   internet.ConnHandler
@@ -53,6 +54,7 @@ func NewListener(ctx context.Context, address net.Address, port net.Port, stream
 			Security: security,
 		},
 		sessions: make(map[ConnectionID]*Connection),
+		decoders: make(map[net.Destination]*fecDecoder),
 		config:   kcpSettings,
 		addConn:  addConn,
 	}
@@ -83,7 +85,14 @@ func (l *Listener) handlePackets() {
 }
 
 func (l *Listener) OnReceive(payload *buf.Buffer, src net.Destination) {
-	segments := l.reader.Read(payload.Bytes())
+	// Every peer has its FEC decoder, kept while it has connections.
+	l.Lock()
+	decoder, decoding := l.decoders[src]
+	l.Unlock()
+	if !decoding {
+		decoder = newFECDecoder(l.config)
+	}
+	segments := l.reader.read(payload.Bytes(), decoder)
 	payload.Release()
 
 	if len(segments) == 0 {
@@ -127,6 +136,7 @@ func (l *Listener) OnReceive(payload *buf.Buffer, src net.Destination) {
 		}, &KCPPacketWriter{
 			Header:   l.header,
 			Security: l.security,
+			FEC:      newFECEncoder(l.config),
 			Writer:   writer,
 		}, writer, l.config)
 		var netConn stat.Connection = conn
@@ -136,14 +146,28 @@ func (l *Listener) OnReceive(payload *buf.Buffer, src net.Destination) {
 
 		l.addConn(netConn)
 		l.sessions[id] = conn
+		if decoder != nil {
+			decoder.sessions++
+			l.decoders[src] = decoder
+		}
 	}
 	conn.Input(segments)
 }
 
 func (l *Listener) Remove(id ConnectionID) {
 	l.Lock()
+	defer l.Unlock()
+	if _, found := l.sessions[id]; !found {
+		return
+	}
 	delete(l.sessions, id)
-	l.Unlock()
+	src := net.UDPDestination(id.Remote, id.Port)
+	if decoder := l.decoders[src]; decoder != nil {
+		decoder.sessions--
+		if decoder.sessions == 0 {
+			delete(l.decoders, src)
+		}
+	}
 }
 
 // Close stops listening on the UDP address. Already Accepted connections are not closed.
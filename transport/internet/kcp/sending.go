@@ -162,6 +162,7 @@ type SendingWorker struct {
 	windowSize                 uint32
 	firstUnacknowledgedUpdated bool
 	closed                     bool
+	bbr                        *bbrController
 }
 
 func NewSendingWorker(kcp *Connection) *SendingWorker {
@@ -172,6 +173,9 @@ func NewSendingWorker(kcp *Connection) *SendingWorker {
 		controlWindow:    kcp.Config.GetSendingInFlightSize(),
 		windowSize:       kcp.Config.GetSendingBufferSize(),
 	}
+	if kcp.Config.CongestionControl == "bbr" {
+		worker.bbr = new(bbrController)
+	}
 	worker.window = NewSendingWindow(worker, worker.OnPacketLoss)
 	return worker
 }
@@ -241,18 +245,29 @@ func (w *SendingWorker) ProcessSegment(current uint32, seg *AckSegment, rto uint
 
 	var maxack uint32
 	var maxackRemoved bool
+	var acked uint32
 	for _, number := range seg.NumberList {
 		removed := w.processAck(number)
 		if maxack < number {
 			maxack = number
 			maxackRemoved = removed
 		}
+		if removed {
+			acked++
+		}
 	}
 
+	var rtt uint32
 	if maxackRemoved {
 		w.window.HandleFastAck(maxack, rto)
 		if current-seg.Timestamp < 10000 {
-			w.conn.roundTrip.Update(current-seg.Timestamp, current)
+			rtt = current - seg.Timestamp
+			w.conn.roundTrip.Update(rtt, current)
+		}
+	}
+	if w.bbr != nil && w.conn.Config.Congestion {
+		if window := w.bbr.OnAck(current, rtt, acked); window > 0 {
+			w.controlWindow = min(max(window, 16), 2*w.conn.Config.GetSendingInFlightSize())
 		}
 	}
 }
@@ -288,7 +303,7 @@ func (w *SendingWorker) Write(seg Segment) error {
 }
 
 func (w *SendingWorker) OnPacketLoss(lossRate uint32) {
-	if !w.conn.Config.Congestion || w.conn.roundTrip.Timeout() == 0 {
+	if !w.conn.Config.Congestion || w.bbr != nil || w.conn.roundTrip.Timeout() == 0 {
 		return
 	}
 
@@ -0,0 +1,65 @@
+package internet
+
+import (
+	"github.com/pires/go-proxyproto"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+)
+
+// gatedListener accepts the connections of the sources its gate allows, and
+// resets the others.
+type gatedListener struct {
+	net.Listener
+	gate session.ListenGate
+}
+
+func (l *gatedListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+		if !ok || l.gate.Allow(addr.IP) {
+			return conn, nil
+		}
+		if conn, ok := conn.(*net.TCPConn); ok {
+			conn.SetLinger(0)
+		}
+		conn.Close()
+	}
+}
+
+// gatedPacketConn reads the packets of the sources its gate allows, and drops
+// the others.
+type gatedPacketConn struct {
+	net.PacketConn
+	gate session.ListenGate
+}
+
+func (c *gatedPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(p)
+		if err != nil {
+			return n, addr, err
+		}
+		if addr, ok := addr.(*net.UDPAddr); !ok || c.gate.Allow(addr.IP) {
+			return n, addr, nil
+		}
+	}
+}
+
+// gateProxyHeader returns the validator of the PROXY protocol headers of the
+// sources gate allows, or nil if gate is nil.
+func gateProxyHeader(gate session.ListenGate) proxyproto.Validator {
+	if gate == nil {
+		return nil
+	}
+	return func(header *proxyproto.Header) error {
+		if addr, ok := header.SourceAddr.(*net.TCPAddr); ok && !gate.Allow(addr.IP) {
+			return errors.New("source not allowed: ", addr.IP)
+		}
+		return nil
+	}
+}
@@ -47,3 +47,8 @@ func ToMemoryStreamConfig(s *StreamConfig) (*MemoryStreamConfig, error) {
 
 	return mss, nil
 }
+
+// CarriesUDP reports whether the transport carries UDP flows too.
+func (m *MemoryStreamConfig) CarriesUDP() bool {
+	return udpTransports[m.ProtocolName]
+}
@@ -0,0 +1,194 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.35.1
+// 	protoc        v5.28.2
+// source: transport/internet/padding/config.proto
+
+package padding
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Config is of the padding of streams into frames, which both ends of them
+// must have.
+type Config struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Sizes frames are padded up to, ascending. Frames larger than the last one
+	// are not padded.
+	Buckets []uint32 `protobuf:"varint,1,rep,packed,name=buckets,proto3" json:"buckets,omitempty"`
+	// How many writes are padded and delayed, 0 for all of them.
+	Packets uint32 `protobuf:"varint,2,opt,name=packets,proto3" json:"packets,omitempty"`
+	// Frames of noise sent by the dialer before its first write, of random
+	// lengths between noise_min and noise_max.
+	NoiseCount uint32 `protobuf:"varint,3,opt,name=noise_count,json=noiseCount,proto3" json:"noise_count,omitempty"`
+	NoiseMin   uint32 `protobuf:"varint,4,opt,name=noise_min,json=noiseMin,proto3" json:"noise_min,omitempty"`
+	NoiseMax   uint32 `protobuf:"varint,5,opt,name=noise_max,json=noiseMax,proto3" json:"noise_max,omitempty"`
+	// The most milliseconds each write padded and each frame of noise is
+	// delayed by at random.
+	Jitter uint32 `protobuf:"varint,6,opt,name=jitter,proto3" json:"jitter,omitempty"`
+}
+
+func (x *Config) Reset() {
+	*x = Config{}
+	mi := &file_transport_internet_padding_config_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Config) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Config) ProtoMessage() {}
+
+func (x *Config) ProtoReflect() protoreflect.Message {
+	mi := &file_transport_internet_padding_config_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Config.ProtoReflect.Descriptor instead.
+func (*Config) Descriptor() ([]byte, []int) {
+	return file_transport_internet_padding_config_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Config) GetBuckets() []uint32 {
+	if x != nil {
+		return x.Buckets
+	}
+	return nil
+}
+
+func (x *Config) GetPackets() uint32 {
+	if x != nil {
+		return x.Packets
+	}
+	return 0
+}
+
+func (x *Config) GetNoiseCount() uint32 {
+	if x != nil {
+		return x.NoiseCount
+	}
+	return 0
+}
+
+func (x *Config) GetNoiseMin() uint32 {
+	if x != nil {
+		return x.NoiseMin
+	}
+	return 0
+}
+
+func (x *Config) GetNoiseMax() uint32 {
+	if x != nil {
+		return x.NoiseMax
+	}
+	return 0
+}
+
+func (x *Config) GetJitter() uint32 {
+	if x != nil {
+		return x.Jitter
+	}
+	return 0
+}
+
+var File_transport_internet_padding_config_proto protoreflect.FileDescriptor
+
+var file_transport_internet_padding_config_proto_rawDesc = []byte{
+	0x0a, 0x27, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2f, 0x69, 0x6e, 0x74, 0x65,
+	0x72, 0x6e, 0x65, 0x74, 0x2f, 0x70, 0x61, 0x64, 0x64, 0x69, 0x6e, 0x67, 0x2f, 0x63, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x1f, 0x78, 0x72, 0x61, 0x79, 0x2e,
+	0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2e, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e,
+	0x65, 0x74, 0x2e, 0x70, 0x61, 0x64, 0x64, 0x69, 0x6e, 0x67, 0x22, 0xaf, 0x01, 0x0a, 0x06, 0x43,
+	0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x18, 0x0a, 0x07, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x0d, 0x52, 0x07, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x73, 0x12,
+	0x18, 0x0a, 0x07, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x07, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x6e, 0x6f, 0x69,
+	0x73, 0x65, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a,
+	0x6e, 0x6f, 0x69, 0x73, 0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x6e, 0x6f,
+	0x69, 0x73, 0x65, 0x5f, 0x6d, 0x69, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x6e,
+	0x6f, 0x69, 0x73, 0x65, 0x4d, 0x69, 0x6e, 0x12, 0x1b, 0x0a, 0x09, 0x6e, 0x6f, 0x69, 0x73, 0x65,
+	0x5f, 0x6d, 0x61, 0x78, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x6e, 0x6f, 0x69, 0x73,
+	0x65, 0x4d, 0x61, 0x78, 0x12, 0x16, 0x0a, 0x06, 0x6a, 0x69, 0x74, 0x74, 0x65, 0x72, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x6a, 0x69, 0x74, 0x74, 0x65, 0x72, 0x42, 0x7f, 0x0a, 0x23,
+	0x63, 0x6f, 0x6d, 0x2e, 0x78, 0x72, 0x61, 0x79, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f,
+	0x72, 0x74, 0x2e, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x65, 0x74, 0x2e, 0x70, 0x61, 0x64, 0x64,
+	0x69, 0x6e, 0x67, 0x50, 0x01, 0x5a, 0x34, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2f, 0x78, 0x74, 0x6c, 0x73, 0x2f, 0x78, 0x72, 0x61, 0x79, 0x2d, 0x63, 0x6f, 0x72, 0x65,
+	0x2f, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72,
+	0x6e, 0x65, 0x74, 0x2f, 0x70, 0x61, 0x64, 0x64, 0x69, 0x6e, 0x67, 0xaa, 0x02, 0x1f, 0x58, 0x72,
+	0x61, 0x79, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2e, 0x49, 0x6e, 0x74,
+	0x65, 0x72, 0x6e, 0x65, 0x74, 0x2e, 0x50, 0x61, 0x64, 0x64, 0x69, 0x6e, 0x67, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_transport_internet_padding_config_proto_rawDescOnce sync.Once
+	file_transport_internet_padding_config_proto_rawDescData = file_transport_internet_padding_config_proto_rawDesc
+)
+
+func file_transport_internet_padding_config_proto_rawDescGZIP() []byte {
+	file_transport_internet_padding_config_proto_rawDescOnce.Do(func() {
+		file_transport_internet_padding_config_proto_rawDescData = protoimpl.X.CompressGZIP(file_transport_internet_padding_config_proto_rawDescData)
+	})
+	return file_transport_internet_padding_config_proto_rawDescData
+}
+
+var file_transport_internet_padding_config_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_transport_internet_padding_config_proto_goTypes = []any{
+	(*Config)(nil), // 0: xray.transport.internet.padding.Config
+}
+var file_transport_internet_padding_config_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_transport_internet_padding_config_proto_init() }
+func file_transport_internet_padding_config_proto_init() {
+	if File_transport_internet_padding_config_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_transport_internet_padding_config_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_transport_internet_padding_config_proto_goTypes,
+		DependencyIndexes: file_transport_internet_padding_config_proto_depIdxs,
+		MessageInfos:      file_transport_internet_padding_config_proto_msgTypes,
+	}.Build()
+	File_transport_internet_padding_config_proto = out.File
+	file_transport_internet_padding_config_proto_rawDesc = nil
+	file_transport_internet_padding_config_proto_goTypes = nil
+	file_transport_internet_padding_config_proto_depIdxs = nil
+}
@@ -0,0 +1,175 @@
+// Package padding pads streams into frames of the sizes of buckets, sends
+// frames of noise before them and delays their first writes, for packet
+// length and timing classifiers not to tell the protocol inside.
+//
+// Each frame is the length of its data and the length of its padding, both
+// 2 bytes in big endian, then its data, then its random padding. Frames of
+// noise are of padding only.
+package padding
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/bytespool"
+	"github.com/xtls/xray-core/common/dice"
+)
+
+const (
+	headerSize = 4
+	// maxData is the most data of a frame.
+	maxData = 16 * 1024
+)
+
+// bucket returns the size a frame of size is padded up to.
+func (c *Config) bucket(size int) int {
+	for _, b := range c.Buckets {
+		if int(b) >= size {
+			return int(b)
+		}
+	}
+	return size
+}
+
+// Conn is a stream padded into frames.
+type Conn struct {
+	net.Conn
+	config *Config
+
+	// data and padding are what is left to read of the frame being read.
+	data    int
+	padding int
+	header  [headerSize]byte
+
+	writeAccess sync.Mutex
+	noise       bool
+	written     uint32
+}
+
+// Client returns conn padded, with noise before the first write.
+func Client(conn net.Conn, config *Config) *Conn {
+	return &Conn{Conn: conn, config: config, noise: config.NoiseCount > 0}
+}
+
+// Server returns conn padded.
+func Server(conn net.Conn, config *Config) *Conn {
+	return &Conn{Conn: conn, config: config}
+}
+
+// Read implements net.Conn.
+func (c *Conn) Read(b []byte) (int, error) {
+	for c.data == 0 {
+		if c.padding > 0 {
+			n, err := io.CopyN(io.Discard, c.Conn, int64(c.padding))
+			c.padding -= int(n)
+			if err != nil {
+				return 0, err
+			}
+		}
+		if _, err := io.ReadFull(c.Conn, c.header[:]); err != nil {
+			return 0, err
+		}
+		c.data = int(binary.BigEndian.Uint16(c.header[:2]))
+		c.padding = int(binary.BigEndian.Uint16(c.header[2:]))
+	}
+	if len(b) > c.data {
+		b = b[:c.data]
+	}
+	n, err := c.Conn.Read(b)
+	c.data -= n
+	return n, err
+}
+
+// Write implements net.Conn.
+func (c *Conn) Write(b []byte) (int, error) {
+	c.writeAccess.Lock()
+	defer c.writeAccess.Unlock()
+	if c.noise {
+		c.noise = false
+		if err := c.writeNoise(); err != nil {
+			return 0, err
+		}
+	}
+	var written int
+	for len(b) > 0 {
+		padded := c.config.Packets == 0 || c.written < c.config.Packets
+		size := min(len(b), maxData)
+		if padded && len(c.config.Buckets) > 0 {
+			if last := int(c.config.Buckets[len(c.config.Buckets)-1]); last > headerSize {
+				size = min(size, last-headerSize)
+			}
+		}
+		var padding int
+		if padded {
+			padding = c.config.bucket(headerSize+size) - headerSize - size
+			c.jitter()
+			if c.written < c.config.Packets {
+				c.written++
+			}
+		}
+		if err := c.writeFrame(b[:size], padding); err != nil {
+			return written, err
+		}
+		b = b[size:]
+		written += size
+	}
+	return written, nil
+}
+
+// writeNoise writes the frames of noise, each on its own.
+func (c *Conn) writeNoise() error {
+	for range c.config.NoiseCount {
+		length := int(c.config.NoiseMin)
+		if c.config.NoiseMax > c.config.NoiseMin {
+			length += dice.Roll(int(c.config.NoiseMax-c.config.NoiseMin) + 1)
+		}
+		c.jitter()
+		if err := c.writeFrame(nil, length); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Conn) jitter() {
+	if c.config.Jitter > 0 {
+		time.Sleep(time.Duration(dice.Roll(int(c.config.Jitter)+1)) * time.Millisecond)
+	}
+}
+
+func (c *Conn) writeFrame(data []byte, padding int) error {
+	padding = min(padding, 0xffff)
+	frame := bytespool.Alloc(int32(headerSize + len(data) + padding))
+	defer bytespool.Free(frame)
+	frame = frame[:headerSize+len(data)+padding]
+	binary.BigEndian.PutUint16(frame, uint16(len(data)))
+	binary.BigEndian.PutUint16(frame[2:], uint16(padding))
+	copy(frame[headerSize:], data)
+	rand.Read(frame[headerSize+len(data):])
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+// Listener pads the streams it accepts.
+type Listener struct {
+	net.Listener
+	config *Config
+}
+
+// NewListener returns l padding the streams it accepts.
+func NewListener(l net.Listener, config *Config) *Listener {
+	return &Listener{Listener: l, config: config}
+}
+
+// Accept implements net.Listener.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return Server(conn, l.config), nil
+}
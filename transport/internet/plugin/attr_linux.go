@@ -0,0 +1,13 @@
+//go:build linux
+// +build linux
+
+package plugin
+
+import "syscall"
+
+func getSysProcAttr() *syscall.SysProcAttr {
+	// Plugins do not outlive Xray.
+	return &syscall.SysProcAttr{
+		Pdeathsig: syscall.SIGKILL,
+	}
+}
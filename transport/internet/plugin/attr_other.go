@@ -0,0 +1,10 @@
+//go:build !windows && !linux
+// +build !windows,!linux
+
+package plugin
+
+import "syscall"
+
+func getSysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package plugin
+
+import "syscall"
+
+func getSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		HideWindow: true,
+	}
+}
@@ -0,0 +1,25 @@
+package plugin
+
+import (
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+func init() {
+	common.Must(internet.RegisterProtocolConfigCreator(protocolName, func() interface{} {
+		return new(Config)
+	}))
+}
+
+func (c *Config) getAddress() (net.Destination, error) {
+	dest, err := net.ParseDestination("tcp:" + c.Address)
+	if err != nil {
+		return dest, err
+	}
+	if !dest.Address.Family().IsIP() {
+		return dest, errors.New("plugin address must be an IP: ", c.Address)
+	}
+	return dest, nil
+}
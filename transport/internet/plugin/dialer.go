@@ -0,0 +1,85 @@
+package plugin
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/stat"
+	"github.com/xtls/xray-core/transport/internet/tls"
+)
+
+func init() {
+	common.Must(internet.RegisterTransportDialer(protocolName, Dial))
+}
+
+type dialerConf struct {
+	net.Destination
+	*internet.MemoryStreamConfig
+}
+
+// globalProcesses keeps a plugin running for every outbound, and restarts it
+// once it exits.
+var (
+	globalProcesses       map[dialerConf]*process
+	globalProcessesAccess sync.Mutex
+)
+
+func getLocal(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (net.Destination, error) {
+	config := streamSettings.ProtocolSettings.(*Config)
+	if config.Address != "" {
+		return config.getAddress()
+	}
+
+	globalProcessesAccess.Lock()
+	defer globalProcessesAccess.Unlock()
+
+	if globalProcesses == nil {
+		globalProcesses = make(map[dialerConf]*process)
+	}
+	key := dialerConf{dest, streamSettings}
+	if p := globalProcesses[key]; p != nil && !p.exited() {
+		return p.local, nil
+	}
+	local, err := freePort()
+	if err != nil {
+		return local, err
+	}
+	p, err := startProcess(context.WithoutCancel(ctx), config, dest, local)
+	if err != nil {
+		return local, err
+	}
+	globalProcesses[key] = p
+	return local, nil
+}
+
+// Dial connects to dest through the plugin of the outbound.
+func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (stat.Connection, error) {
+	errors.LogInfo(ctx, "creating connection to ", dest, " through plugin")
+
+	local, err := getLocal(ctx, dest, streamSettings)
+	if err != nil {
+		return nil, errors.New("failed to get plugin for ", dest).Base(err)
+	}
+	var conn net.Conn
+	// A plugin just started takes a moment to listen.
+	for i := 0; ; i++ {
+		conn, err = internet.DialSystem(ctx, local, nil)
+		if err == nil || i == 30 || ctx.Err() != nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		return nil, errors.New("failed to connect to plugin at ", local).Base(err)
+	}
+
+	if config := tls.ConfigFromStreamSettings(streamSettings); config != nil {
+		conn = tls.Client(conn, config.GetTLSConfig(tls.WithDestination(dest)))
+	}
+	return conn, nil
+}
@@ -0,0 +1,136 @@
+package plugin
+
+import (
+	"context"
+	gotls "crypto/tls"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/tls"
+)
+
+type Listener struct {
+	sync.Mutex
+	listener  net.Listener
+	process   *process
+	closed    bool
+	addr      net.Addr
+	tlsConfig *gotls.Config
+	addConn   internet.ConnHandler
+}
+
+func (l *Listener) keepAccepting(ctx context.Context) {
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			errors.LogInfoInner(ctx, err, "plugin listener on ", l.addr, " stopped")
+			return
+		}
+		if l.tlsConfig != nil {
+			conn = tls.Server(conn, l.tlsConfig)
+		}
+		l.addConn(conn)
+	}
+}
+
+// keepRunning restarts the plugin whenever it exits, until the listener is
+// closed.
+func (l *Listener) keepRunning(ctx context.Context, config *Config, remote, local net.Destination) {
+	for {
+		l.Lock()
+		p := l.process
+		l.Unlock()
+		<-p.done
+		time.Sleep(time.Second)
+
+		l.Lock()
+		if l.closed {
+			l.Unlock()
+			return
+		}
+		p, err := startProcess(ctx, config, remote, local)
+		if err != nil {
+			l.Unlock()
+			errors.LogWarningInner(ctx, err, "failed to restart plugin")
+			continue
+		}
+		l.process = p
+		l.Unlock()
+	}
+}
+
+// Addr implements internet.Listener.Addr.
+func (l *Listener) Addr() net.Addr {
+	return l.addr
+}
+
+// Close implements internet.Listener.Close.
+func (l *Listener) Close() error {
+	l.Lock()
+	l.closed = true
+	p := l.process
+	l.Unlock()
+	if p != nil {
+		p.Close()
+	}
+	return l.listener.Close()
+}
+
+// Listen starts the plugin that listens on address and port, and relays to
+// the listener.
+func Listen(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, addConn internet.ConnHandler) (internet.Listener, error) {
+	if port == net.Port(0) || !address.Family().IsIP() {
+		return nil, errors.New("plugin cannot listen on a domain socket")
+	}
+	config := streamSettings.ProtocolSettings.(*Config)
+
+	var local net.Destination
+	var err error
+	if config.Address != "" {
+		local, err = config.getAddress()
+	} else {
+		local, err = freePort()
+	}
+	if err != nil {
+		return nil, err
+	}
+	listener, err := internet.ListenSystem(ctx, &net.TCPAddr{
+		IP:   local.Address.IP(),
+		Port: int(local.Port),
+	}, nil)
+	if err != nil {
+		return nil, errors.New("failed to listen for plugin on ", local).Base(err)
+	}
+
+	l := &Listener{
+		listener: listener,
+		addr: &net.TCPAddr{
+			IP:   address.IP(),
+			Port: int(port),
+		},
+		addConn: addConn,
+	}
+	if config := tls.ConfigFromStreamSettings(streamSettings); config != nil {
+		l.tlsConfig = config.GetTLSConfig()
+	}
+	if config.Address == "" {
+		remote := net.TCPDestination(address, port)
+		if l.process, err = startProcess(ctx, config, remote, local); err != nil {
+			listener.Close()
+			return nil, err
+		}
+		go l.keepRunning(ctx, config, remote, local)
+	}
+	errors.LogInfo(ctx, "listening through plugin on ", address, ":", port)
+
+	go l.keepAccepting(ctx)
+	return l, nil
+}
+
+func init() {
+	common.Must(internet.RegisterTransportListener(protocolName, Listen))
+}
@@ -0,0 +1,96 @@
+// Package plugin carries connections through SIP003 plugins, programs that
+// relay them to the other end with their own obfuscation.
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+)
+
+const protocolName = "plugin"
+
+// process is a running plugin. On dialers it relays from its local endpoint
+// to the remote server, and on listeners from the remote endpoint it listens
+// on to the local one.
+type process struct {
+	cmd   *exec.Cmd
+	local net.Destination
+	done  chan struct{}
+}
+
+func host(address net.Address) string {
+	if address.Family().IsIP() {
+		return address.IP().String()
+	}
+	return address.Domain()
+}
+
+func startProcess(ctx context.Context, config *Config, remote, local net.Destination) (*process, error) {
+	cmd := exec.Command(config.Command, config.Args...)
+	cmd.Env = append(os.Environ(),
+		"SS_REMOTE_HOST="+host(remote.Address),
+		"SS_REMOTE_PORT="+remote.Port.String(),
+		"SS_LOCAL_HOST="+host(local.Address),
+		"SS_LOCAL_PORT="+local.Port.String(),
+		"SS_PLUGIN_OPTIONS="+config.Options,
+	)
+	cmd.SysProcAttr = getSysProcAttr()
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Start(); err != nil {
+		return nil, errors.New("failed to start plugin ", config.Command).Base(err)
+	}
+	errors.LogInfo(ctx, "started plugin ", config.Command, " for ", remote, " at ", local)
+
+	p := &process{
+		cmd:   cmd,
+		local: local,
+		done:  make(chan struct{}),
+	}
+	go func() {
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			errors.LogInfo(ctx, "<plugin ", config.Command, "> ", scanner.Text())
+		}
+		io.Copy(io.Discard, reader)
+	}()
+	go func() {
+		err := cmd.Wait()
+		writer.Close()
+		close(p.done)
+		errors.LogWarningInner(ctx, err, "plugin ", config.Command, " for ", remote, " exited")
+	}()
+	return p, nil
+}
+
+func (p *process) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *process) Close() error {
+	p.cmd.Process.Kill()
+	<-p.done
+	return nil
+}
+
+// freePort returns a loopback endpoint for a plugin to relay with.
+func freePort() (net.Destination, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return net.Destination{}, err
+	}
+	defer listener.Close()
+	return net.DestinationFromAddr(listener.Addr()), nil
+}
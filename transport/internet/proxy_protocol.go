@@ -0,0 +1,145 @@
+package internet
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pires/go-proxyproto"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+)
+
+// writeProxyProtocolHeader sends a PROXY protocol header announcing the
+// inbound client of ctx as the source of conn.
+func writeProxyProtocolHeader(ctx context.Context, conn net.Conn, version uint32, dest net.Destination) error {
+	var source, destination net.Addr
+	if inbound := session.InboundFromContext(ctx); inbound != nil && inbound.Source.IsValid() && inbound.Source.Address.Family().IsIP() {
+		source = &net.TCPAddr{
+			IP:   inbound.Source.Address.IP(),
+			Port: int(inbound.Source.Port),
+		}
+	}
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		destination = addr
+	} else if dest.Address.Family().IsIP() {
+		destination = &net.TCPAddr{
+			IP:   dest.Address.IP(),
+			Port: int(dest.Port),
+		}
+	}
+	// Without both addresses this is a LOCAL header, which receivers accept
+	// and ignore.
+	header := proxyproto.HeaderProxyFromAddrs(byte(version), source, destination)
+	if _, err := header.WriteTo(conn); err != nil {
+		return errors.New("failed to send PROXY protocol header").Base(err)
+	}
+	return nil
+}
+
+// UDPSessionIdle is how long the UDP sessions of idle clients are kept by
+// the UDP listeners of inbounds.
+const UDPSessionIdle = 2 * time.Minute
+
+// maxProxyProtocolUpstreams bounds the clients whose proxies are kept, as
+// anyone may send a datagram with a PROXY protocol header.
+const maxProxyProtocolUpstreams = 65536
+
+// proxyProtocolPacketConn strips the PROXY protocol v2 header that prefixes
+// every datagram, and reports the client announced by it as the sender.
+// Replies to such a client are sent back through the proxy it came from,
+// while the client is not idle for longer than UDPSessionIdle.
+type proxyProtocolPacketConn struct {
+	net.PacketConn
+
+	access    sync.Mutex
+	upstreams map[string]proxyProtocolUpstream // by client address string
+	swept     time.Time
+}
+
+type proxyProtocolUpstream struct {
+	addr net.Addr
+	seen time.Time
+}
+
+func newProxyProtocolPacketConn(conn net.PacketConn) *proxyProtocolPacketConn {
+	return &proxyProtocolPacketConn{
+		PacketConn: conn,
+		upstreams:  make(map[string]proxyProtocolUpstream),
+		swept:      time.Now(),
+	}
+}
+
+func (c *proxyProtocolPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		n, upstream, err := c.PacketConn.ReadFrom(p)
+		if err != nil {
+			return n, upstream, err
+		}
+		reader := bufio.NewReaderSize(bytes.NewReader(p[:n]), n)
+		header, err := proxyproto.Read(reader)
+		if err != nil {
+			errors.LogInfoInner(context.Background(), err, "dropping datagram without valid PROXY protocol header from ", upstream)
+			continue
+		}
+		payload := n - reader.Buffered()
+		n = copy(p, p[payload:n])
+		source, _, ok := header.UDPAddrs()
+		if header.Command.IsLocal() || !ok {
+			return n, upstream, nil
+		}
+		c.store(source.String(), upstream)
+		return n, source, nil
+	}
+}
+
+// store keeps upstream as the proxy of client, dropping the clients idle for
+// too long, or arbitrary ones if too many are not.
+func (c *proxyProtocolPacketConn) store(client string, upstream net.Addr) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	now := time.Now()
+	if now.Sub(c.swept) > UDPSessionIdle || len(c.upstreams) >= maxProxyProtocolUpstreams {
+		c.swept = now
+		for k, u := range c.upstreams {
+			if now.Sub(u.seen) > UDPSessionIdle {
+				delete(c.upstreams, k)
+			}
+		}
+		for k := range c.upstreams {
+			if len(c.upstreams) < maxProxyProtocolUpstreams*3/4 {
+				break
+			}
+			delete(c.upstreams, k)
+		}
+	}
+	c.upstreams[client] = proxyProtocolUpstream{addr: upstream, seen: now}
+}
+
+func (c *proxyProtocolPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.access.Lock()
+	if u, found := c.upstreams[addr.String()]; found && time.Since(u.seen) <= UDPSessionIdle {
+		addr = u.addr
+	}
+	c.access.Unlock()
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+// SetReadBuffer keeps quic-go able to tune the wrapped socket.
+func (c *proxyProtocolPacketConn) SetReadBuffer(bytes int) error {
+	if s, ok := c.PacketConn.(interface{ SetReadBuffer(int) error }); ok {
+		return s.SetReadBuffer(bytes)
+	}
+	return nil
+}
+
+// SetWriteBuffer keeps quic-go able to tune the wrapped socket.
+func (c *proxyProtocolPacketConn) SetWriteBuffer(bytes int) error {
+	if s, ok := c.PacketConn.(interface{ SetWriteBuffer(int) error }); ok {
+		return s.SetWriteBuffer(bytes)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package quic
+
+import (
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+func init() {
+	common.Must(internet.RegisterProtocolConfigCreator(protocolName, func() interface{} {
+		return new(Config)
+	}))
+}
+
+func (c *Config) getQUICConfig() *quic.Config {
+	config := &quic.Config{
+		MaxIdleTimeout:     net.ConnIdleTimeout,
+		KeepAlivePeriod:    net.QuicgoH3KeepAlivePeriod,
+		MaxIncomingStreams: int64(c.MaxIncomingStreams),
+		EnableDatagrams:    true,
+		Allow0RTT:          c.ZeroRtt,
+	}
+	if c.MaxIdleTimeout > 0 {
+		config.MaxIdleTimeout = time.Duration(c.MaxIdleTimeout) * time.Second
+	}
+	if c.KeepAlivePeriod > 0 {
+		config.KeepAlivePeriod = time.Duration(c.KeepAlivePeriod) * time.Second
+	} else if c.KeepAlivePeriod < 0 {
+		config.KeepAlivePeriod = 0
+	}
+	return config
+}
@@ -0,0 +1,269 @@
+package quic
+
+import (
+	"context"
+	goerrors "errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/quicvarint"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/common/signal/done"
+)
+
+// flowIdleTimeout is how long a UDP flow survives without packets, as in UDP
+// inbounds.
+const flowIdleTimeout = 2 * time.Minute
+
+// session is a QUIC connection, whose DATAGRAM frames start with the ID of the
+// UDP flow they belong to. The dialer numbers the flows, and a frame with the
+// ID alone closes one.
+type session struct {
+	*quic.Conn
+	access sync.Mutex
+	flows  map[uint64]*packetConn
+	nextID uint64
+	// accept handles the flows dialers open, on listeners.
+	accept func(*packetConn)
+}
+
+func newSession(conn *quic.Conn, accept func(*packetConn)) *session {
+	s := &session{
+		Conn:   conn,
+		flows:  make(map[uint64]*packetConn),
+		accept: accept,
+	}
+	go s.receiveDatagrams()
+	return s
+}
+
+func (s *session) receiveDatagrams() {
+	for {
+		datagram, err := s.ReceiveDatagram(context.Background())
+		if err != nil {
+			s.access.Lock()
+			flows := s.flows
+			s.flows = make(map[uint64]*packetConn)
+			s.access.Unlock()
+			for _, flow := range flows {
+				flow.done.Close()
+			}
+			return
+		}
+		id, n, err := quicvarint.Parse(datagram)
+		if err != nil {
+			continue
+		}
+		payload := datagram[n:]
+
+		s.access.Lock()
+		flow, found := s.flows[id]
+		if !found && len(payload) > 0 && s.accept != nil {
+			flow = s.newFlow(id)
+		}
+		s.access.Unlock()
+		if flow == nil {
+			continue
+		}
+		if !found && s.accept != nil {
+			go s.accept(flow)
+		}
+		if len(payload) == 0 {
+			flow.done.Close()
+			s.removeFlow(flow)
+			continue
+		}
+		select {
+		case flow.queue <- payload:
+		default:
+			// Like a full socket buffer.
+		}
+	}
+}
+
+// openFlow starts a UDP flow to the listener.
+func (s *session) openFlow() *packetConn {
+	s.access.Lock()
+	defer s.access.Unlock()
+	flow := s.newFlow(s.nextID)
+	s.nextID++
+	return flow
+}
+
+func (s *session) newFlow(id uint64) *packetConn {
+	flow := &packetConn{
+		session: s,
+		id:      id,
+		header:  quicvarint.Append(nil, id),
+		queue:   make(chan []byte, 64),
+		done:    done.New(),
+	}
+	flow.timer = signal.CancelAfterInactivity(context.Background(), func() {
+		flow.Close()
+	}, flowIdleTimeout)
+	s.flows[id] = flow
+	return flow
+}
+
+func (s *session) removeFlow(flow *packetConn) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	if s.flows[flow.id] == flow {
+		delete(s.flows, flow.id)
+	}
+}
+
+// openStream opens a stream to the listener, or fails once the listener
+// allows no more.
+func (s *session) openStream() (net.Conn, error) {
+	stream, err := s.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	return &streamConn{
+		Stream: stream,
+		local:  s.LocalAddr(),
+		remote: s.RemoteAddr(),
+	}, nil
+}
+
+// streamConn is a connection in a bidirectional QUIC stream.
+type streamConn struct {
+	*quic.Stream
+	local  net.Addr
+	remote net.Addr
+}
+
+func (c *streamConn) Close() error {
+	c.Stream.CancelRead(0)
+	return c.Stream.Close()
+}
+
+func (c *streamConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *streamConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// packetConn is a UDP flow, with one packet in every Read and Write.
+type packetConn struct {
+	session *session
+	id      uint64
+	header  []byte
+	queue   chan []byte
+	done    *done.Instance
+	timer   *signal.ActivityTimer
+}
+
+// IsPacketConnection implements stat.PacketConnection.
+func (c *packetConn) IsPacketConnection() {}
+
+func (c *packetConn) Read(b []byte) (int, error) {
+	select {
+	case payload := <-c.queue:
+		c.timer.Update()
+		return copy(b, payload), nil
+	case <-c.done.Wait():
+		return 0, io.EOF
+	}
+}
+
+func (c *packetConn) Write(b []byte) (int, error) {
+	if c.done.Done() {
+		return 0, io.ErrClosedPipe
+	}
+	c.timer.Update()
+	datagram := make([]byte, 0, len(c.header)+len(b))
+	datagram = append(append(datagram, c.header...), b...)
+	if err := c.session.SendDatagram(datagram); err != nil {
+		var tooLarge *quic.DatagramTooLargeError
+		if !goerrors.As(err, &tooLarge) {
+			return 0, err
+		}
+		// Dropped like a UDP packet beyond the MTU.
+		errors.LogDebug(context.Background(), "dropping UDP packet of ", len(b), " bytes beyond ", tooLarge.MaxDatagramPayloadSize-int64(len(c.header)))
+	}
+	return len(b), nil
+}
+
+func (c *packetConn) Close() error {
+	if c.done.Done() {
+		return nil
+	}
+	c.done.Close()
+	c.session.removeFlow(c)
+	c.session.SendDatagram(c.header)
+	return nil
+}
+
+func (c *packetConn) LocalAddr() net.Addr {
+	return c.session.LocalAddr()
+}
+
+func (c *packetConn) RemoteAddr() net.Addr {
+	return c.session.RemoteAddr()
+}
+
+func (c *packetConn) SetDeadline(t time.Time) error {
+	// TODO cannot do anything useful
+	return nil
+}
+
+func (c *packetConn) SetReadDeadline(t time.Time) error {
+	// TODO cannot do anything useful
+	return nil
+}
+
+func (c *packetConn) SetWriteDeadline(t time.Time) error {
+	// TODO cannot do anything useful
+	return nil
+}
+
+// HTTP3Conn is a QUIC connection handed whole to an inbound, which serves
+// HTTP/3 on it. It is not a stream to read or write.
+type HTTP3Conn struct {
+	conn *quic.Conn
+}
+
+// QUICConn returns the QUIC connection to serve HTTP/3 on.
+func (c *HTTP3Conn) QUICConn() *quic.Conn {
+	return c.conn
+}
+
+func (c *HTTP3Conn) Read(b []byte) (int, error) {
+	return 0, errors.New("QUIC connection of HTTP/3 is not a stream")
+}
+
+func (c *HTTP3Conn) Write(b []byte) (int, error) {
+	return 0, errors.New("QUIC connection of HTTP/3 is not a stream")
+}
+
+func (c *HTTP3Conn) Close() error {
+	return c.conn.CloseWithError(0, "")
+}
+
+func (c *HTTP3Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *HTTP3Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *HTTP3Conn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *HTTP3Conn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *HTTP3Conn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
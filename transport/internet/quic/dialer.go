@@ -0,0 +1,111 @@
+package quic
+
+import (
+	"context"
+	"sync"
+
+	"github.com/quic-go/quic-go"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/stat"
+	"github.com/xtls/xray-core/transport/internet/tls"
+)
+
+func init() {
+	common.Must(internet.RegisterTransportDialer(protocolName, Dial))
+	internet.RegisterUDPTransport(protocolName)
+}
+
+type dialerConf struct {
+	net.Destination
+	*internet.MemoryStreamConfig
+}
+
+// globalSessions keeps the QUIC connections of every outbound, which carry
+// all its connections and UDP flows.
+var (
+	globalSessions      map[dialerConf][]*session
+	globalSessionAccess sync.Mutex
+)
+
+// Dial opens a stream, or a UDP flow for UDP destinations, in a QUIC
+// connection to dest.
+func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (stat.Connection, error) {
+	errors.LogInfo(ctx, "creating connection to ", dest)
+
+	network := dest.Network
+	dest.Network = net.Network_UDP
+
+	globalSessionAccess.Lock()
+	defer globalSessionAccess.Unlock()
+
+	if globalSessions == nil {
+		globalSessions = make(map[dialerConf][]*session)
+	}
+	key := dialerConf{dest, streamSettings}
+	sessions := globalSessions[key][:0]
+	for _, s := range globalSessions[key] {
+		if s.Context().Err() == nil {
+			sessions = append(sessions, s)
+		}
+	}
+	globalSessions[key] = sessions
+
+	for _, s := range sessions {
+		if network == net.Network_UDP {
+			return s.openFlow(), nil
+		}
+		if conn, err := s.openStream(); err == nil {
+			return conn, nil
+		}
+	}
+
+	s, err := dialSession(ctx, dest, streamSettings)
+	if err != nil {
+		return nil, errors.New("failed to dial QUIC to ", dest).Base(err)
+	}
+	globalSessions[key] = append(sessions, s)
+	if network == net.Network_UDP {
+		return s.openFlow(), nil
+	}
+	return s.openStream()
+}
+
+func dialSession(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (*session, error) {
+	tlsConfig := tls.ConfigFromStreamSettings(streamSettings)
+	if tlsConfig == nil {
+		return nil, errors.New("QUIC requires TLS")
+	}
+	config := streamSettings.ProtocolSettings.(*Config)
+
+	rawConn, err := internet.DialSystem(ctx, dest, streamSettings.SocketSettings)
+	if err != nil {
+		return nil, err
+	}
+	udpConn, udpAddr, err := internet.ToPacketConn(rawConn)
+	if err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+
+	// The connection outlives the request it is dialed for.
+	ctx = context.WithoutCancel(ctx)
+	gotlsConfig := tlsConfig.GetTLSConfig(tls.WithDestination(dest), tls.WithNextProto("h3"))
+	var conn *quic.Conn
+	if config.ZeroRtt {
+		conn, err = quic.DialEarly(ctx, udpConn, udpAddr, gotlsConfig, config.getQUICConfig())
+	} else {
+		conn, err = quic.Dial(ctx, udpConn, udpAddr, gotlsConfig, config.getQUICConfig())
+	}
+	if err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	go func() {
+		<-conn.Context().Done()
+		rawConn.Close()
+	}()
+	return newSession(conn, nil), nil
+}
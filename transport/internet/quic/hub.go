@@ -0,0 +1,114 @@
+package quic
+
+import (
+	"context"
+
+	"github.com/quic-go/quic-go"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/tls"
+)
+
+type quicListener interface {
+	Accept(context.Context) (*quic.Conn, error)
+	Close() error
+	Addr() net.Addr
+}
+
+type Listener struct {
+	rawConn  net.PacketConn
+	listener quicListener
+	addConn  internet.ConnHandler
+	http3    bool
+}
+
+func (l *Listener) keepAccepting(ctx context.Context) {
+	for {
+		conn, err := l.listener.Accept(context.Background())
+		if err != nil {
+			errors.LogInfoInner(ctx, err, "QUIC listener on ", l.Addr(), " stopped")
+			return
+		}
+		if l.http3 {
+			l.addConn(&HTTP3Conn{conn: conn})
+			continue
+		}
+		go l.keepAcceptingStreams(ctx, conn)
+	}
+}
+
+func (l *Listener) keepAcceptingStreams(ctx context.Context, conn *quic.Conn) {
+	s := newSession(conn, func(flow *packetConn) {
+		l.addConn(flow)
+	})
+	for {
+		stream, err := s.AcceptStream(context.Background())
+		if err != nil {
+			errors.LogDebugInner(ctx, err, "QUIC connection from ", conn.RemoteAddr(), " ends")
+			return
+		}
+		l.addConn(&streamConn{
+			Stream: stream,
+			local:  conn.LocalAddr(),
+			remote: conn.RemoteAddr(),
+		})
+	}
+}
+
+// Addr implements internet.Listener.Addr.
+func (l *Listener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// Close implements internet.Listener.Close.
+func (l *Listener) Close() error {
+	l.listener.Close()
+	return l.rawConn.Close()
+}
+
+func Listen(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, addConn internet.ConnHandler) (internet.Listener, error) {
+	if address.Family().IsDomain() {
+		return nil, errors.New("QUIC cannot listen on a domain socket")
+	}
+	tlsConfig := tls.ConfigFromStreamSettings(streamSettings)
+	if tlsConfig == nil {
+		return nil, errors.New("QUIC requires TLS")
+	}
+	config := streamSettings.ProtocolSettings.(*Config)
+
+	rawConn, err := internet.ListenSystemPacket(context.Background(), &net.UDPAddr{
+		IP:   address.IP(),
+		Port: int(port),
+	}, streamSettings.SocketSettings)
+	if err != nil {
+		return nil, errors.New("failed to listen UDP for QUIC on ", address, ":", port).Base(err)
+	}
+
+	gotlsConfig := tlsConfig.GetTLSConfig(tls.WithNextProto("h3"))
+	var listener quicListener
+	if config.ZeroRtt {
+		listener, err = quic.ListenEarly(rawConn, gotlsConfig, config.getQUICConfig())
+	} else {
+		listener, err = quic.Listen(rawConn, gotlsConfig, config.getQUICConfig())
+	}
+	if err != nil {
+		rawConn.Close()
+		return nil, errors.New("failed to listen QUIC on ", address, ":", port).Base(err)
+	}
+	errors.LogInfo(ctx, "listening QUIC on ", address, ":", port)
+
+	l := &Listener{
+		rawConn:  rawConn,
+		listener: listener,
+		addConn:  addConn,
+		http3:    config.Http3,
+	}
+	go l.keepAccepting(ctx)
+	return l, nil
+}
+
+func init() {
+	common.Must(internet.RegisterTransportListener(protocolName, Listen))
+}
@@ -0,0 +1,5 @@
+// Package quic carries every connection in a stream of a QUIC connection,
+// and every UDP flow in its DATAGRAM frames.
+package quic
+
+const protocolName = "quic"
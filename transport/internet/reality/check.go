@@ -0,0 +1,306 @@
+package reality
+
+import (
+	"context"
+	"crypto/sha256"
+	gotls "crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pires/go-proxyproto"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/events"
+	"github.com/xtls/xray-core/common/signal/done"
+)
+
+const (
+	// targetCheckTimeout bounds each handshake of a check of a target.
+	targetCheckTimeout = 15 * time.Second
+	// targetExpiryWarning is how long before it expires the certificate of a
+	// target fails its check.
+	targetExpiryWarning = 7 * 24 * time.Hour
+)
+
+// oidSCTList is of the extension of the SCTs embedded in certificates, RFC
+// 6962 section 3.3.
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+var (
+	targetCheckersAccess sync.Mutex
+	targetCheckers       = make(map[string]*targetChecker)
+)
+
+// CheckTargets starts checking the targets of c every CheckInterval seconds,
+// and returns the Closable that stops it, or nil if they are not checked.
+// Listeners of the same targets share their checks.
+func (c *Config) CheckTargets() common.Closable {
+	if c.CheckInterval == 0 || c.Dest == "" {
+		return nil
+	}
+	interval := time.Duration(c.CheckInterval) * time.Second
+	checkers := targetCheckerList{acquireTargetChecker(c.Type, c.Dest, c.Xver, c.ServerNames, interval)}
+	for _, t := range c.Targets {
+		checkers = append(checkers, acquireTargetChecker(t.Type, t.Dest, t.Xver, t.ServerNames, interval))
+	}
+	return checkers
+}
+
+type targetCheckerList []*targetChecker
+
+// Close implements common.Closable.
+func (l targetCheckerList) Close() error {
+	for _, c := range l {
+		c.release()
+	}
+	return nil
+}
+
+// targetChecker checks that a target still serves its server names the way
+// REALITY needs to mimic, over TLS 1.3 with a trusted certificate logged in
+// certificate transparency, and alerts once it does not or serves otherwise.
+type targetChecker struct {
+	key string
+	// users are guarded by targetCheckersAccess.
+	users int
+	done  *done.Instance
+
+	network     string
+	dest        string
+	xver        uint64
+	serverNames []string
+	interval    time.Duration
+
+	// profiles are of the last handshake with each server name, and failures
+	// why the last check of each failed.
+	profiles map[string]*targetProfile
+	failures map[string]string
+}
+
+func acquireTargetChecker(network string, dest string, xver uint64, serverNames []string, interval time.Duration) *targetChecker {
+	key := network + " " + dest + " " + strconv.FormatUint(xver, 10) + " " + strings.Join(serverNames, ",")
+	targetCheckersAccess.Lock()
+	defer targetCheckersAccess.Unlock()
+	c := targetCheckers[key]
+	if c == nil {
+		c = &targetChecker{
+			key:         key,
+			done:        done.New(),
+			network:     network,
+			dest:        dest,
+			xver:        xver,
+			serverNames: serverNames,
+			interval:    interval,
+			profiles:    make(map[string]*targetProfile),
+			failures:    make(map[string]string),
+		}
+		targetCheckers[key] = c
+		go c.run()
+	}
+	c.users++
+	return c
+}
+
+func (c *targetChecker) release() {
+	targetCheckersAccess.Lock()
+	defer targetCheckersAccess.Unlock()
+	c.users--
+	if c.users == 0 {
+		delete(targetCheckers, c.key)
+		c.done.Close()
+	}
+}
+
+func (c *targetChecker) run() {
+	for {
+		for _, serverName := range c.serverNames {
+			// Clients without SNI are served whatever the target defaults to.
+			if serverName != "" {
+				c.check(serverName)
+			}
+		}
+		select {
+		case <-c.done.Wait():
+			return
+		case <-time.After(c.interval):
+		}
+	}
+}
+
+// check checks the target with serverName, and alerts as it goes down or up
+// again, or changes.
+func (c *targetChecker) check(serverName string) {
+	ctx := context.Background()
+	profile, err := c.handshake(serverName)
+	var failure string
+	if err != nil {
+		failure = err.Error()
+	} else {
+		failure = strings.Join(profile.problems(), "; ")
+	}
+	if failure != "" {
+		if c.failures[serverName] == "" {
+			errors.LogWarning(ctx, "REALITY target ", c.dest, " for ", serverName, " is down: ", failure)
+			events.Emit(events.RealityTargetDown, "dest", c.dest, "serverName", serverName, "error", failure)
+		}
+		c.failures[serverName] = failure
+	} else if c.failures[serverName] != "" {
+		delete(c.failures, serverName)
+		errors.LogInfo(ctx, "REALITY target ", c.dest, " for ", serverName, " is up again")
+		events.Emit(events.RealityTargetUp, "dest", c.dest, "serverName", serverName)
+	}
+	if profile == nil {
+		return
+	}
+	if last := c.profiles[serverName]; last != nil {
+		if changes := last.changes(profile); len(changes) > 0 {
+			errors.LogWarning(ctx, "REALITY target ", c.dest, " for ", serverName, " changed: ", strings.Join(changes, ", "))
+			events.Emit(events.RealityTargetChanged, "dest", c.dest, "serverName", serverName, "changes", strings.Join(changes, ", "))
+		} else if last.leaf != profile.leaf {
+			errors.LogInfo(ctx, "REALITY target ", c.dest, " for ", serverName, " renewed its certificate, expiring on ", profile.notAfter.Format(time.RFC3339))
+		}
+	}
+	c.profiles[serverName] = profile
+}
+
+// handshake does a TLS handshake with the target as a client of serverName,
+// and returns how it went.
+func (c *targetChecker) handshake(serverName string) (*targetProfile, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), targetCheckTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.dest)
+	if err != nil {
+		return nil, errors.New("failed to dial").Base(err)
+	}
+	defer conn.Close()
+	if c.xver > 0 {
+		if _, err := proxyproto.HeaderProxyFromAddrs(byte(c.xver), conn.LocalAddr(), conn.RemoteAddr()).WriteTo(conn); err != nil {
+			return nil, errors.New("failed to send PROXY protocol header").Base(err)
+		}
+	}
+	tlsConn := gotls.Client(conn, &gotls.Config{
+		ServerName: serverName,
+		NextProtos: []string{"h2", "http/1.1"},
+		MinVersion: gotls.VersionTLS10,
+		// The certificate is verified below, for the rest to be profiled
+		// anyway.
+		InsecureSkipVerify: true,
+	})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, errors.New("failed to handshake").Base(err)
+	}
+	state := tlsConn.ConnectionState()
+	leaf := state.PeerCertificates[0]
+	profile := &targetProfile{
+		version:      state.Version,
+		alpn:         state.NegotiatedProtocol,
+		cipherSuite:  state.CipherSuite,
+		issuer:       leaf.Issuer.String(),
+		keyAlgorithm: leaf.PublicKeyAlgorithm.String(),
+		chain:        len(state.PeerCertificates),
+		scts:         len(state.SignedCertificateTimestamps) + countSCTs(leaf),
+		leaf:         sha256.Sum256(leaf.Raw),
+		notAfter:     leaf.NotAfter,
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: serverName, Intermediates: intermediates}); err != nil {
+		profile.untrusted = err.Error()
+	}
+	return profile, nil
+}
+
+// countSCTs returns the number of SCTs embedded in cert.
+func countSCTs(cert *x509.Certificate) int {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSCTList) {
+			continue
+		}
+		var list []byte
+		if _, err := asn1.Unmarshal(ext.Value, &list); err != nil || len(list) < 2 {
+			return 0
+		}
+		// The list is of SCTs prefixed with their lengths, after its own.
+		list = list[2:]
+		var n int
+		for len(list) >= 2 {
+			length := int(binary.BigEndian.Uint16(list))
+			if len(list) < 2+length {
+				break
+			}
+			list = list[2+length:]
+			n++
+		}
+		return n
+	}
+	return 0
+}
+
+// targetProfile is how a target handshakes.
+type targetProfile struct {
+	version      uint16
+	alpn         string
+	cipherSuite  uint16
+	issuer       string
+	keyAlgorithm string
+	chain        int
+	scts         int
+	leaf         [sha256.Size]byte
+	notAfter     time.Time
+	// untrusted is why the certificate is not trusted, if it is not.
+	untrusted string
+}
+
+// problems returns why REALITY can not mimic the target convincingly.
+func (p *targetProfile) problems() []string {
+	var problems []string
+	if p.version != gotls.VersionTLS13 {
+		problems = append(problems, "serving "+gotls.VersionName(p.version)+" instead of TLS 1.3")
+	}
+	if p.untrusted != "" {
+		problems = append(problems, "untrusted certificate: "+p.untrusted)
+	} else if p.scts == 0 {
+		problems = append(problems, "certificate without SCTs of certificate transparency")
+	}
+	if time.Until(p.notAfter) < targetExpiryWarning {
+		problems = append(problems, "certificate expiring on "+p.notAfter.Format(time.RFC3339))
+	}
+	return problems
+}
+
+// changes returns how p changed to next, other than a renewal of the
+// certificate by the same issuer.
+func (p *targetProfile) changes(next *targetProfile) []string {
+	var changes []string
+	change := func(what string, from string, to string) {
+		if from != to {
+			changes = append(changes, what+" "+from+" -> "+to)
+		}
+	}
+	change("version", gotls.VersionName(p.version), gotls.VersionName(next.version))
+	change("ALPN", alpnName(p.alpn), alpnName(next.alpn))
+	change("cipher suite", gotls.CipherSuiteName(p.cipherSuite), gotls.CipherSuiteName(next.cipherSuite))
+	change("issuer", p.issuer, next.issuer)
+	change("key", p.keyAlgorithm, next.keyAlgorithm)
+	change("chain length", strconv.Itoa(p.chain), strconv.Itoa(next.chain))
+	if (p.scts == 0) != (next.scts == 0) {
+		change("SCTs", strconv.Itoa(p.scts), strconv.Itoa(next.scts))
+	}
+	return changes
+}
+
+func alpnName(alpn string) string {
+	if alpn == "" {
+		return "none"
+	}
+	return alpn
+}
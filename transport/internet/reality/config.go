@@ -58,6 +58,36 @@ func (c *Config) GetREALITYConfig() *reality.Config {
 	return config
 }
 
+// GetREALITYTargets returns the REALITY config of each server name served by
+// a target of its own, derived from config, or nil if there is none.
+func (c *Config) GetREALITYTargets(config *reality.Config) map[string]*reality.Config {
+	if len(c.Targets) == 0 {
+		return nil
+	}
+	targets := make(map[string]*reality.Config)
+	for _, t := range c.Targets {
+		target := config.Clone()
+		target.Mldsa65Key = config.Mldsa65Key
+		target.Type = t.Type
+		target.Dest = t.Dest
+		target.Xver = byte(t.Xver)
+		target.ServerNames = make(map[string]bool)
+		for _, serverName := range t.ServerNames {
+			target.ServerNames[serverName] = true
+		}
+		if len(t.ShortIds) > 0 {
+			target.ShortIds = make(map[[8]byte]bool)
+			for _, shortId := range t.ShortIds {
+				target.ShortIds[*(*[8]byte)(shortId)] = true
+			}
+		}
+		for serverName := range target.ServerNames {
+			targets[serverName] = target
+		}
+	}
+	return targets
+}
+
 func KeyLogWriterFromConfig(c *Config) io.Writer {
 	if len(c.MasterKeyLog) <= 0 || c.MasterKeyLog == "none" {
 		return nil
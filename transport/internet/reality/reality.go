@@ -24,11 +24,14 @@ import (
 	"unsafe"
 
 	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/pires/go-proxyproto"
 	utls "github.com/refraction-networking/utls"
 	"github.com/xtls/reality"
 	"github.com/xtls/xray-core/common/crypto"
+	"github.com/xtls/xray-core/common/dice"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
+	ptls "github.com/xtls/xray-core/common/protocol/tls"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/transport/internet/tls"
 	"golang.org/x/crypto/hkdf"
@@ -55,6 +58,131 @@ func Server(c net.Conn, config *reality.Config) (net.Conn, error) {
 	return &Conn{Conn: realityConn}, err
 }
 
+// ServerWithTargets is Server with the config of the target serving the SNI of
+// the ClientHello, or config if no target serves it.
+func ServerWithTargets(c net.Conn, config *reality.Config, targets map[string]*reality.Config) (net.Conn, error) {
+	if len(targets) == 0 {
+		return Server(c, config)
+	}
+	conn := &PeekedConn{Conn: c}
+	c.SetReadDeadline(time.Now().Add(peekTimeout))
+	conn.peekRecord()
+	c.SetReadDeadline(time.Time{})
+	if header, err := ptls.SniffTLS(conn.peeked); err == nil {
+		if target, found := targets[header.Domain()]; found {
+			config = target
+		}
+	}
+	return Server(conn, config)
+}
+
+// peekTimeout bounds the wait for the ClientHello to pick a target by, as of
+// the default handshake timeout of policies.
+const peekTimeout = 4 * time.Second
+
+// PeekedConn replays the first TLS record read from Conn to pick a target, then
+// reads on from Conn.
+type PeekedConn struct {
+	net.Conn
+	peeked []byte
+}
+
+func (c *PeekedConn) peekRecord() {
+	header := make([]byte, 5)
+	n, err := io.ReadFull(c.Conn, header)
+	c.peeked = header[:n]
+	if err != nil || header[0] != 0x16 {
+		return
+	}
+	// Only what the handshake reads anyway is peeked, as fallbacks copy from
+	// the raw connection.
+	record := make([]byte, 5+int(binary.BigEndian.Uint16(header[3:])))
+	copy(record, header)
+	n, _ = io.ReadFull(c.Conn, record[5:])
+	c.peeked = record[:5+n]
+}
+
+func (c *PeekedConn) Read(b []byte) (int, error) {
+	if len(c.peeked) > 0 {
+		n := copy(b, c.peeked)
+		c.peeked = c.peeked[n:]
+		return n, nil
+	}
+	return c.Conn.Read(b)
+}
+
+// CloseWrite is required by the fallback of reality.Server.
+func (c *PeekedConn) CloseWrite() error {
+	raw := c.Conn
+	if pc, ok := raw.(*proxyproto.Conn); ok {
+		raw = pc.Raw()
+	}
+	if cw, ok := raw.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Conn.Close()
+}
+
+type listener struct {
+	net.Listener
+	conns chan net.Conn
+	done  chan struct{}
+	err   error
+}
+
+// NewListener is reality.NewListener handshaking with ServerWithTargets.
+func NewListener(inner net.Listener, config *reality.Config, targets map[string]*reality.Config) net.Listener {
+	if len(targets) == 0 {
+		return reality.NewListener(inner, config)
+	}
+	// Targets are shared by their server names, and detected once each.
+	detected := map[*reality.Config]bool{config: true}
+	go reality.DetectPostHandshakeRecordsLens(config)
+	for _, target := range targets {
+		if !detected[target] {
+			detected[target] = true
+			go reality.DetectPostHandshakeRecordsLens(target)
+		}
+	}
+	l := &listener{
+		Listener: inner,
+		conns:    make(chan net.Conn),
+		done:     make(chan struct{}),
+	}
+	go func() {
+		for {
+			c, err := l.Listener.Accept()
+			if err != nil {
+				l.err = err
+				// Handshakes still running close their connections instead.
+				close(l.done)
+				return
+			}
+			go func() {
+				conn, err := ServerWithTargets(c, config, targets)
+				if err != nil {
+					return
+				}
+				select {
+				case l.conns <- conn.(*Conn).Conn:
+				case <-l.done:
+					conn.Close()
+				}
+			}()
+		}
+	}()
+	return l
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
 type UConn struct {
 	*utls.UConn
 	Config     *Config
@@ -116,6 +244,25 @@ func (c *UConn) VerifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x50
 	return nil
 }
 
+// fingerprintFor returns the fingerprint of a new connection towards dest. With
+// fingerprints to rotate among, a random one is picked for each connection, or
+// one for every window of FingerprintInterval seconds in an order seeded by the
+// server, so all connections of a window look alike.
+func fingerprintFor(config *Config, dest net.Destination) string {
+	if len(config.Fingerprints) == 0 {
+		return config.Fingerprint
+	}
+	if config.FingerprintInterval == 0 {
+		return config.Fingerprints[dice.Roll(len(config.Fingerprints))]
+	}
+	seed := sha256.New()
+	seed.Write(config.PublicKey)
+	seed.Write([]byte(dest.NetAddr()))
+	binary.Write(seed, binary.BigEndian, uint64(time.Now().Unix())/config.FingerprintInterval)
+	sum := seed.Sum(nil)
+	return config.Fingerprints[binary.BigEndian.Uint64(sum)%uint64(len(config.Fingerprints))]
+}
+
 func UClient(c net.Conn, config *Config, ctx context.Context, dest net.Destination) (net.Conn, error) {
 	localAddr := c.LocalAddr().String()
 	uConn := &UConn{
@@ -132,11 +279,23 @@ func UClient(c net.Conn, config *Config, ctx context.Context, dest net.Destinati
 		utlsConfig.ServerName = dest.Address.String()
 	}
 	uConn.ServerName = utlsConfig.ServerName
-	fingerprint := tls.GetFingerprint(config.Fingerprint)
+	fingerprint := tls.GetFingerprint(fingerprintFor(config, dest))
 	if fingerprint == nil {
 		return nil, errors.New("REALITY: failed to get fingerprint").AtError()
 	}
-	uConn.UConn = utls.UClient(c, utlsConfig, *fingerprint)
+	if len(config.CurvePreferences) > 0 {
+		goCurves := tls.ParseCurveName(config.CurvePreferences)
+		curves := make([]utls.CurveID, len(goCurves))
+		for i, curve := range goCurves {
+			curves[i] = utls.CurveID(curve)
+		}
+		var err error
+		if uConn.UConn, err = tls.UClientWithCurves(c, utlsConfig, *fingerprint, curves); err != nil {
+			return nil, errors.New("REALITY: failed to apply curvePreferences").Base(err)
+		}
+	} else {
+		uConn.UConn = utls.UClient(c, utlsConfig, *fingerprint)
+	}
 	{
 		uConn.BuildHandshakeState()
 		hello := uConn.HandshakeState.Hello
@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package internet
+
+import (
+	"net"
+
+	"github.com/xtls/xray-core/common/errors"
+)
+
+func redirectListen(l net.Listener, sockopt *SocketConfig) (net.Listener, error) {
+	l.Close()
+	return nil, errors.New("WinDivert mode is only supported on Windows")
+}
+
+func redirectListenPacket(conn net.PacketConn, sockopt *SocketConfig) (net.PacketConn, error) {
+	conn.Close()
+	return nil, errors.New("WinDivert mode is only supported on Windows")
+}
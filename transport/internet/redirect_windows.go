@@ -0,0 +1,466 @@
+package internet
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+	"golang.org/x/sys/windows"
+)
+
+// Traffic is redirected to a listener by reflecting the packets an app sends
+// to the listener as if it was the app receiving them, which it answers to
+// the app with its own address. The listener sees a connection from the
+// original destination with the port of the app, and looks up the original
+// port of the connection from it.
+
+const (
+	// redirectTTL marks the packets of the sockets Xray dials itself, which
+	// are never redirected.
+	redirectTTL = 111
+
+	redirectTCPTimeout = 2 * time.Hour
+	redirectUDPTimeout = 2 * time.Minute
+	redirectSweep      = 30 * time.Second
+
+	protoTCP = 6
+	protoUDP = 17
+
+	tcpFlagSYN = 0x02
+	tcpFlagRST = 0x04
+	tcpFlagACK = 0x10
+)
+
+type redirectKey struct {
+	proto uint8
+	// port is the port of the app.
+	port uint16
+	// remote is the original destination, as seen by the listener.
+	remote [16]byte
+}
+
+type redirectEntry struct {
+	// local is the address of the app.
+	local net.IP
+	// port is the original port.
+	port     uint16
+	ifIdx    uint32
+	subIfIdx uint32
+	lastSeen time.Time
+}
+
+type redirector struct {
+	port   uint16
+	divert *winDivert
+
+	access    sync.Mutex
+	entries   map[redirectKey]*redirectEntry
+	lastSweep time.Time
+	refs      int
+	closed    bool
+}
+
+var (
+	redirectAccess sync.Mutex
+	redirectors    = make(map[uint16]*redirector)
+	markOnce       sync.Once
+)
+
+func newRedirectKey(proto uint8, port uint16, remote net.IP) redirectKey {
+	key := redirectKey{proto: proto, port: port}
+	copy(key.remote[:], remote.To16())
+	return key
+}
+
+// acquireRedirector starts redirecting the traffic to port, or shares the
+// redirector of port already started.
+func acquireRedirector(port uint16, sockopt *SocketConfig) (*redirector, error) {
+	redirectAccess.Lock()
+	defer redirectAccess.Unlock()
+
+	if r, found := redirectors[port]; found {
+		r.refs++
+		return r, nil
+	}
+	var err error
+	markOnce.Do(func() {
+		err = RegisterDialerController(markSocket)
+	})
+	if err != nil {
+		return nil, errors.New("failed to mark the sockets of Xray").Base(err)
+	}
+	p, ttl := strconv.Itoa(int(port)), strconv.Itoa(redirectTTL)
+	capture := "(tcp or udp)"
+	if sockopt.WindivertFilter != "" {
+		capture = "((tcp or udp) and (" + sockopt.WindivertFilter + "))"
+	}
+	filter := "outbound and !loopback and !impostor and " +
+		"((ip and ip.TTL != " + ttl + ") or (ipv6 and ipv6.HopLimit != " + ttl + ")) and " +
+		"(tcp.SrcPort == " + p + " or udp.SrcPort == " + p + " or " + capture + ")"
+	divert, err := openWinDivert(filter)
+	if err != nil {
+		return nil, err
+	}
+	r := &redirector{
+		port:      port,
+		divert:    divert,
+		entries:   make(map[redirectKey]*redirectEntry),
+		lastSweep: time.Now(),
+		refs:      1,
+	}
+	redirectors[port] = r
+	go r.run()
+	errors.LogInfo(context.Background(), "redirecting traffic to port ", port, " with WinDivert")
+	return r, nil
+}
+
+func (r *redirector) release() {
+	redirectAccess.Lock()
+	defer redirectAccess.Unlock()
+
+	r.refs--
+	if r.refs > 0 {
+		return
+	}
+	delete(redirectors, r.port)
+	r.access.Lock()
+	r.closed = true
+	r.access.Unlock()
+	r.divert.close()
+}
+
+func (r *redirector) run() {
+	packet := make([]byte, 65535)
+	var addr winDivertAddress
+	for {
+		n, err := r.divert.recv(packet, &addr)
+		if err != nil {
+			r.access.Lock()
+			closed := r.closed
+			r.access.Unlock()
+			if !closed {
+				errors.LogWarningInner(context.Background(), err, "failed to capture packets with WinDivert")
+			}
+			return
+		}
+		p := packet[:n]
+		if r.rewrite(p, &addr) {
+			calcChecksums(p, &addr)
+		}
+		if err := r.divert.send(p, &addr); err != nil {
+			errors.LogDebugInner(context.Background(), err, "failed to reinject packet with WinDivert")
+		}
+	}
+}
+
+// parsePacket returns the addresses and the TCP or UDP header of an IP
+// packet, which are modified in place.
+func parsePacket(p []byte) (src, dst net.IP, proto uint8, l4 []byte, ok bool) {
+	if len(p) < 20 {
+		return
+	}
+	switch p[0] >> 4 {
+	case 4:
+		ihl := int(p[0]&0x0f) * 4
+		// Fragments other than the first have no ports.
+		if ihl < 20 || len(p) < ihl+8 || binary.BigEndian.Uint16(p[6:8])&0x1fff != 0 {
+			return
+		}
+		src, dst, proto, l4 = p[12:16], p[16:20], p[9], p[ihl:]
+	case 6:
+		if len(p) < 48 {
+			return
+		}
+		src, dst, proto, l4 = p[8:24], p[24:40], p[6], p[40:]
+	default:
+		return
+	}
+	if proto == protoTCP && len(l4) < 20 {
+		return
+	}
+	return src, dst, proto, l4, proto == protoTCP || proto == protoUDP
+}
+
+// rewrite redirects an outbound packet to the listener or back to the app,
+// and returns whether it is modified.
+func (r *redirector) rewrite(p []byte, addr *winDivertAddress) bool {
+	src, dst, proto, l4, ok := parsePacket(p)
+	if !ok {
+		return false
+	}
+	srcPort := binary.BigEndian.Uint16(l4[0:2])
+	dstPort := binary.BigEndian.Uint16(l4[2:4])
+	var flags byte
+	if proto == protoTCP {
+		flags = l4[13]
+	}
+	now := time.Now()
+
+	r.access.Lock()
+	defer r.access.Unlock()
+
+	if now.Sub(r.lastSweep) > redirectSweep {
+		r.sweep(now)
+	}
+
+	if srcPort == r.port {
+		// From the listener, to the original destination with the port of
+		// the app.
+		key := newRedirectKey(proto, dstPort, dst)
+		e := r.entries[key]
+		if e == nil {
+			return false
+		}
+		e.lastSeen = now
+		copy(src, dst)
+		copy(dst, e.local)
+		binary.BigEndian.PutUint16(l4[0:2], e.port)
+		addr.Flags &^= winDivertFlagOutbound
+		if flags&tcpFlagRST != 0 {
+			delete(r.entries, key)
+		}
+		return true
+	}
+
+	key := newRedirectKey(proto, srcPort, dst)
+	e := r.entries[key]
+	switch {
+	case proto == protoTCP && flags&tcpFlagSYN != 0 && flags&tcpFlagACK == 0,
+		proto == protoUDP && (e == nil || e.port != dstPort):
+		e = &redirectEntry{
+			local:    append(net.IP(nil), src...),
+			port:     dstPort,
+			ifIdx:    addr.IfIdx,
+			subIfIdx: addr.SubIfIdx,
+		}
+		r.entries[key] = e
+	case e == nil || e.port != dstPort:
+		// A connection from before the redirection.
+		return false
+	}
+	e.lastSeen = now
+	local := e.local
+	copy(src, dst)
+	copy(dst, local)
+	binary.BigEndian.PutUint16(l4[2:4], r.port)
+	addr.Flags &^= winDivertFlagOutbound
+	if flags&tcpFlagRST != 0 {
+		delete(r.entries, key)
+	}
+	return true
+}
+
+func (r *redirector) sweep(now time.Time) {
+	r.lastSweep = now
+	for key, e := range r.entries {
+		timeout := redirectUDPTimeout
+		if key.proto == protoTCP {
+			timeout = redirectTCPTimeout
+		}
+		if now.Sub(e.lastSeen) > timeout {
+			delete(r.entries, key)
+		}
+	}
+}
+
+func (r *redirector) lookup(proto uint8, ra net.Addr) *redirectEntry {
+	ip, port := addrIPPort(ra)
+	r.access.Lock()
+	defer r.access.Unlock()
+	return r.entries[newRedirectKey(proto, port, ip)]
+}
+
+func addrIPPort(a net.Addr) (net.IP, uint16) {
+	switch a := a.(type) {
+	case *net.TCPAddr:
+		return a.IP, uint16(a.Port)
+	case *net.UDPAddr:
+		return a.IP, uint16(a.Port)
+	}
+	return nil, 0
+}
+
+// OriginalDst looks up the original destination of the connections that
+// WinDivert redirected to the listener of la.
+func OriginalDst(la, ra net.Addr) (net.IP, int, error) {
+	var proto uint8 = protoTCP
+	if _, ok := la.(*net.UDPAddr); ok {
+		proto = protoUDP
+	}
+	_, port := addrIPPort(la)
+	redirectAccess.Lock()
+	r := redirectors[port]
+	redirectAccess.Unlock()
+	if r == nil {
+		return nil, -1, errors.New("no redirection to ", la)
+	}
+	e := r.lookup(proto, ra)
+	if e == nil {
+		return nil, -1, errors.New("no redirected connection from ", ra)
+	}
+	ip, _ := addrIPPort(ra)
+	return ip, int(e.port), nil
+}
+
+func markSocket(network, address string, c syscall.RawConn) error {
+	var err error
+	if cerr := c.Control(func(fd uintptr) {
+		err1 := windows.SetsockoptInt(windows.Handle(fd), windows.IPPROTO_IPV6, windows.IPV6_UNICAST_HOPS, redirectTTL)
+		err2 := windows.SetsockoptInt(windows.Handle(fd), windows.IPPROTO_IP, windows.IP_TTL, redirectTTL)
+		if err1 != nil && err2 != nil {
+			err = err2
+		}
+	}); cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+type redirectListener struct {
+	net.Listener
+	r    *redirector
+	once sync.Once
+}
+
+func (l *redirectListener) Close() error {
+	l.once.Do(l.r.release)
+	return l.Listener.Close()
+}
+
+type redirectPacketConn struct {
+	net.PacketConn
+	conn *net.UDPConn
+	r    *redirector
+	once sync.Once
+}
+
+// UDPConn returns the connection for reading messages of it.
+func (c *redirectPacketConn) UDPConn() *net.UDPConn {
+	return c.conn
+}
+
+func (c *redirectPacketConn) Close() error {
+	c.once.Do(c.r.release)
+	return c.PacketConn.Close()
+}
+
+func redirectListen(l net.Listener, sockopt *SocketConfig) (net.Listener, error) {
+	_, port := addrIPPort(l.Addr())
+	r, err := acquireRedirector(port, sockopt)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	return &redirectListener{Listener: l, r: r}, nil
+}
+
+func redirectListenPacket(conn net.PacketConn, sockopt *SocketConfig) (net.PacketConn, error) {
+	udpConn, ok := conn.(*net.UDPConn)
+	if !ok {
+		conn.Close()
+		return nil, errors.New("WinDivert mode requires a UDP socket")
+	}
+	_, port := addrIPPort(conn.LocalAddr())
+	r, err := acquireRedirector(port, sockopt)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &redirectPacketConn{PacketConn: conn, conn: udpConn, r: r}, nil
+}
+
+// redirectedUDPConn sends packets to the apps whose traffic is redirected from
+// an address other than the listener.
+type redirectedUDPConn struct {
+	src *net.UDPAddr
+}
+
+// ListenRedirectedUDP returns a connection that sends packets from src to the
+// apps whose UDP traffic WinDivert redirects, which is any address they sent
+// packets to.
+func ListenRedirectedUDP(src *net.UDPAddr) (net.PacketConn, error) {
+	if src.IP.To4() == nil && src.IP.To16() == nil {
+		return nil, errors.New("invalid address ", src)
+	}
+	return &redirectedUDPConn{src: src}, nil
+}
+
+func (c *redirectedUDPConn) WriteTo(p []byte, back net.Addr) (int, error) {
+	redirectAccess.Lock()
+	var r *redirector
+	var e *redirectEntry
+	for _, v := range redirectors {
+		if e = v.lookup(protoUDP, back); e != nil {
+			r = v
+			break
+		}
+	}
+	redirectAccess.Unlock()
+	if e == nil {
+		return 0, errors.New("no redirected connection from ", back)
+	}
+	_, port := addrIPPort(back)
+	var packet []byte
+	if ip4 := c.src.IP.To4(); ip4 != nil && e.local.To4() != nil {
+		packet = make([]byte, 28+len(p))
+		packet[0] = 0x45
+		binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+		packet[8] = 64
+		packet[9] = protoUDP
+		copy(packet[12:16], ip4)
+		copy(packet[16:20], e.local.To4())
+	} else if c.src.IP.To4() == nil && e.local.To4() == nil {
+		packet = make([]byte, 48+len(p))
+		packet[0] = 0x60
+		binary.BigEndian.PutUint16(packet[4:6], uint16(8+len(p)))
+		packet[6] = protoUDP
+		packet[7] = 64
+		copy(packet[8:24], c.src.IP.To16())
+		copy(packet[24:40], e.local.To16())
+	} else {
+		return 0, errors.New("mismatched address family of ", c.src, " and ", back)
+	}
+	udp := packet[len(packet)-8-len(p):]
+	binary.BigEndian.PutUint16(udp[0:2], uint16(c.src.Port))
+	binary.BigEndian.PutUint16(udp[2:4], port)
+	binary.BigEndian.PutUint16(udp[4:6], uint16(8+len(p)))
+	copy(udp[8:], p)
+	addr := winDivertAddress{
+		IfIdx:    e.ifIdx,
+		SubIfIdx: e.subIfIdx,
+	}
+	calcChecksums(packet, &addr)
+	if err := r.divert.send(packet, &addr); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *redirectedUDPConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	return 0, nil, errors.New("redirected UDP connections only send packets")
+}
+
+func (c *redirectedUDPConn) Close() error {
+	return nil
+}
+
+func (c *redirectedUDPConn) LocalAddr() net.Addr {
+	return c.src
+}
+
+func (c *redirectedUDPConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *redirectedUDPConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *redirectedUDPConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
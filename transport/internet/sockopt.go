@@ -1,5 +1,13 @@
 package internet
 
+import (
+	"context"
+	"net"
+	"syscall"
+
+	"github.com/xtls/xray-core/common/errors"
+)
+
 func isTCPSocket(network string) bool {
 	switch network {
 	case "tcp", "tcp4", "tcp6":
@@ -28,3 +36,38 @@ func (v *SocketConfig) ParseTFOValue() int {
 	}
 	return tfo
 }
+
+// applyConnectedConnOptions applies the socket options of established TCP
+// connections to conn.
+func applyConnectedConnOptions(ctx context.Context, conn net.Conn, config *SocketConfig) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return
+	}
+	rawConn, err := sc.SyscallConn()
+	if err != nil {
+		return
+	}
+	rawConn.Control(func(fd uintptr) {
+		if err := applyConnectedSocketOptions(fd, config); err != nil {
+			errors.LogInfoInner(ctx, err, "failed to apply socket options")
+		}
+	})
+}
+
+// connectedListener applies the socket options of established TCP connections
+// to the connections it accepts.
+type connectedListener struct {
+	net.Listener
+	ctx    context.Context
+	config *SocketConfig
+}
+
+func (l *connectedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	applyConnectedConnOptions(l.ctx, conn, l.config)
+	return conn, nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package internet
+
+func applyConnectedSocketOptions(fd uintptr, config *SocketConfig) error {
+	return nil
+}
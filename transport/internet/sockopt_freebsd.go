@@ -1,24 +1,14 @@
 package internet
 
 import (
-	"encoding/binary"
 	"net"
-	"os"
 	"syscall"
-	"unsafe"
 
 	"github.com/xtls/xray-core/common/errors"
 	"golang.org/x/sys/unix"
 )
 
-const (
-	sysPFINOUT     = 0x0
-	sysPFIN        = 0x1
-	sysPFOUT       = 0x2
-	sysPFFWD       = 0x3
-	sysDIOCNATLOOK = 0xc04c4417
-)
-
+// pfiocNatlook is struct pfioc_natlook of FreeBSD.
 type pfiocNatlook struct {
 	Saddr     [16]byte /* pf_addr */
 	Daddr     [16]byte /* pf_addr */
@@ -35,95 +25,12 @@ type pfiocNatlook struct {
 }
 
 const (
+	sysDIOCNATLOOK     = 0xc04c4417
 	sizeofPfiocNatlook = 0x4c
 	soReUsePort        = 0x00000200
 	soReUsePortLB      = 0x00010000
 )
 
-func ioctl(s uintptr, ioc int, b []byte) error {
-	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, s, uintptr(ioc), uintptr(unsafe.Pointer(&b[0]))); errno != 0 {
-		return error(errno)
-	}
-	return nil
-}
-
-func (nl *pfiocNatlook) rdPort() int {
-	return int(binary.BigEndian.Uint16((*[2]byte)(unsafe.Pointer(&nl.Rdport))[:]))
-}
-
-func (nl *pfiocNatlook) setPort(remote, local int) {
-	binary.BigEndian.PutUint16((*[2]byte)(unsafe.Pointer(&nl.Sport))[:], uint16(remote))
-	binary.BigEndian.PutUint16((*[2]byte)(unsafe.Pointer(&nl.Dport))[:], uint16(local))
-}
-
-// OriginalDst uses ioctl to read original destination from /dev/pf
-func OriginalDst(la, ra net.Addr) (net.IP, int, error) {
-	f, err := os.Open("/dev/pf")
-	if err != nil {
-		return net.IP{}, -1, errors.New("failed to open device /dev/pf").Base(err)
-	}
-	defer f.Close()
-	fd := f.Fd()
-	b := make([]byte, sizeofPfiocNatlook)
-	nl := (*pfiocNatlook)(unsafe.Pointer(&b[0]))
-	var raIP, laIP net.IP
-	var raPort, laPort int
-	switch la.(type) {
-	case *net.TCPAddr:
-		raIP = ra.(*net.TCPAddr).IP
-		laIP = la.(*net.TCPAddr).IP
-		raPort = ra.(*net.TCPAddr).Port
-		laPort = la.(*net.TCPAddr).Port
-		nl.Proto = syscall.IPPROTO_TCP
-	case *net.UDPAddr:
-		raIP = ra.(*net.UDPAddr).IP
-		laIP = la.(*net.UDPAddr).IP
-		raPort = ra.(*net.UDPAddr).Port
-		laPort = la.(*net.UDPAddr).Port
-		nl.Proto = syscall.IPPROTO_UDP
-	}
-	if raIP.To4() != nil {
-		if laIP.IsUnspecified() {
-			laIP = net.ParseIP("127.0.0.1")
-		}
-		copy(nl.Saddr[:net.IPv4len], raIP.To4())
-		copy(nl.Daddr[:net.IPv4len], laIP.To4())
-		nl.Af = syscall.AF_INET
-	}
-	if raIP.To16() != nil && raIP.To4() == nil {
-		if laIP.IsUnspecified() {
-			laIP = net.ParseIP("::1")
-		}
-		copy(nl.Saddr[:], raIP)
-		copy(nl.Daddr[:], laIP)
-		nl.Af = syscall.AF_INET6
-	}
-	nl.setPort(raPort, laPort)
-	ioc := uintptr(sysDIOCNATLOOK)
-	for _, dir := range []byte{sysPFOUT, sysPFIN} {
-		nl.Direction = dir
-		err = ioctl(fd, int(ioc), b)
-		if err == nil || err != syscall.ENOENT {
-			break
-		}
-	}
-	if err != nil {
-		return net.IP{}, -1, os.NewSyscallError("ioctl", err)
-	}
-
-	odPort := nl.rdPort()
-	var odIP net.IP
-	switch nl.Af {
-	case syscall.AF_INET:
-		odIP = make(net.IP, net.IPv4len)
-		copy(odIP, nl.Rdaddr[:net.IPv4len])
-	case syscall.AF_INET6:
-		odIP = make(net.IP, net.IPv6len)
-		copy(odIP, nl.Rdaddr[:])
-	}
-	return odIP, odPort, nil
-}
-
 func applyOutboundSocketOptions(network string, address string, fd uintptr, config *SocketConfig) error {
 	if config.Mark != 0 {
 		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_USER_COOKIE, int(config.Mark)); err != nil {
@@ -219,6 +126,15 @@ func applyInboundSocketOptions(network string, fd uintptr, config *SocketConfig)
 		}
 	}
 
+	// The original destinations of the packets diverted with divert-to.
+	if config.ReceiveOriginalDestAddress && config.Tproxy == SocketConfig_TProxy && isUDPSocket(network) {
+		err1 := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, unix.IPV6_RECVORIGDSTADDR, 1)
+		err2 := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, unix.IP_RECVORIGDSTADDR, 1)
+		if err1 != nil && err2 != nil {
+			return errors.New("failed to set IP_RECVORIGDSTADDR").Base(err2)
+		}
+	}
+
 	return nil
 }
 
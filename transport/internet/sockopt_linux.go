@@ -2,6 +2,8 @@ package internet
 
 import (
 	"context"
+	"encoding/binary"
+	"math"
 	"net"
 	"runtime"
 	"strconv"
@@ -47,6 +49,12 @@ func applyOutboundSocketOptions(network string, address string, fd uintptr, conf
 		}
 	}
 
+	if isUDPSocket(network) {
+		if err := applyMtuDiscover(fd, config); err != nil {
+			return err
+		}
+	}
+
 	if config.Interface != "" {
 		if err := syscall.BindToDevice(int(fd), config.Interface); err != nil {
 			return errors.New("failed to set Interface").Base(err)
@@ -145,6 +153,12 @@ func applyInboundSocketOptions(network string, fd uintptr, config *SocketConfig)
 			return errors.New("failed to set SO_MARK").Base(err)
 		}
 	}
+
+	if isUDPSocket(network) {
+		if err := applyMtuDiscover(fd, config); err != nil {
+			return err
+		}
+	}
 	if isTCPSocket(network) {
 		tfo := config.ParseTFOValue()
 		if tfo >= 0 {
@@ -258,6 +272,49 @@ func applyInboundSocketOptions(network string, fd uintptr, config *SocketConfig)
 	return nil
 }
 
+// applyMtuDiscover sets the path MTU discovery mode for IPv6 and IPv4, either
+// of which dual-stack sockets may carry.
+func applyMtuDiscover(fd uintptr, config *SocketConfig) error {
+	if config.MtuDiscover == SocketConfig_Default {
+		return nil
+	}
+	// IP_PMTUDISC_* and IPV6_PMTUDISC_* follow the modes, from 0.
+	mode := int(config.MtuDiscover) - 1
+	err1 := syscall.SetsockoptInt(int(fd), syscall.SOL_IPV6, syscall.IPV6_MTU_DISCOVER, mode)
+	err2 := syscall.SetsockoptInt(int(fd), syscall.SOL_IP, syscall.IP_MTU_DISCOVER, mode)
+	if err1 != nil && err2 != nil {
+		return errors.New("failed to set IP_MTU_DISCOVER").Base(err2)
+	}
+	return nil
+}
+
+// tcpBrutalParams is the option of the tcp-brutal kernel module for its
+// sending rate, in bytes per second, and its congestion window gain, in tenths.
+const tcpBrutalParams = 23301
+
+// applyConnectedSocketOptions applies socket options for established TCP
+// connections, as congestion control resets on connecting and listeners may
+// be MPTCP sockets, which have neither pacing nor path MTU discovery.
+func applyConnectedSocketOptions(fd uintptr, config *SocketConfig) error {
+	if err := applyMtuDiscover(fd, config); err != nil {
+		return err
+	}
+	if config.TcpMaxPacingRate > 0 {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, unix.SO_MAX_PACING_RATE, int(min(config.TcpMaxPacingRate, math.MaxInt32))); err != nil {
+			return errors.New("failed to set SO_MAX_PACING_RATE").Base(err)
+		}
+	}
+	if config.TcpCongestion == "brutal" && config.TcpMaxPacingRate > 0 {
+		params := make([]byte, 12)
+		binary.NativeEndian.PutUint64(params, config.TcpMaxPacingRate)
+		binary.NativeEndian.PutUint32(params[8:], 20)
+		if err := unix.SetsockoptString(int(fd), syscall.IPPROTO_TCP, tcpBrutalParams, string(params)); err != nil {
+			return errors.New("failed to set TCP_BRUTAL_PARAMS").Base(err)
+		}
+	}
+	return nil
+}
+
 func setReuseAddr(fd uintptr) error {
 	if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
 		return errors.New("failed to set SO_REUSEADDR").Base(err).AtWarning()
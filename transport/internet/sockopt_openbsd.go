@@ -0,0 +1,107 @@
+package internet
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/xtls/xray-core/common/errors"
+	"golang.org/x/sys/unix"
+)
+
+// pfiocNatlook is struct pfioc_natlook of OpenBSD.
+type pfiocNatlook struct {
+	Saddr     [16]byte /* pf_addr */
+	Daddr     [16]byte /* pf_addr */
+	Rsaddr    [16]byte /* pf_addr */
+	Rdaddr    [16]byte /* pf_addr */
+	Rdomain   uint16
+	Rrdomain  uint16
+	Sport     uint16
+	Dport     uint16
+	Rsport    uint16
+	Rdport    uint16
+	Af        uint8
+	Proto     uint8
+	Direction uint8
+	Pad       [1]byte
+}
+
+const (
+	sysDIOCNATLOOK     = 0xc0504417
+	sizeofPfiocNatlook = 0x50
+)
+
+func applyOutboundSocketOptions(network string, address string, fd uintptr, config *SocketConfig) error {
+	if config.Tproxy.IsEnabled() {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, unix.SO_BINDANY, 1); err != nil {
+			return errors.New("failed to set outbound SO_BINDANY").Base(err)
+		}
+	}
+	return nil
+}
+
+func applyInboundSocketOptions(network string, fd uintptr, config *SocketConfig) error {
+	if config.Tproxy.IsEnabled() {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, unix.SO_BINDANY, 1); err != nil {
+			return errors.New("failed to set inbound SO_BINDANY").Base(err)
+		}
+	}
+
+	// The original destinations of the packets diverted with divert-to.
+	if config.ReceiveOriginalDestAddress && config.Tproxy == SocketConfig_TProxy && isUDPSocket(network) {
+		err1 := setRecvDst(fd, syscall.IPPROTO_IPV6, unix.IPV6_RECVPKTINFO, unix.IPV6_RECVDSTPORT)
+		err2 := setRecvDst(fd, syscall.IPPROTO_IP, unix.IP_RECVDSTADDR, unix.IP_RECVDSTPORT)
+		if err1 != nil && err2 != nil {
+			return errors.New("failed to set IP_RECVDSTADDR").Base(err2)
+		}
+	}
+
+	return nil
+}
+
+func setRecvDst(fd uintptr, level, addr, port int) error {
+	if err := syscall.SetsockoptInt(int(fd), level, addr, 1); err != nil {
+		return err
+	}
+	return syscall.SetsockoptInt(int(fd), level, port, 1)
+}
+
+func bindAddr(fd uintptr, ip []byte, port uint32) error {
+	setReuseAddr(fd)
+	setReusePort(fd)
+
+	var sockaddr syscall.Sockaddr
+
+	switch len(ip) {
+	case net.IPv4len:
+		a4 := &syscall.SockaddrInet4{
+			Port: int(port),
+		}
+		copy(a4.Addr[:], ip)
+		sockaddr = a4
+	case net.IPv6len:
+		a6 := &syscall.SockaddrInet6{
+			Port: int(port),
+		}
+		copy(a6.Addr[:], ip)
+		sockaddr = a6
+	default:
+		return errors.New("unexpected length of ip")
+	}
+
+	return syscall.Bind(int(fd), sockaddr)
+}
+
+func setReuseAddr(fd uintptr) error {
+	if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+		return errors.New("failed to set SO_REUSEADDR").Base(err).AtWarning()
+	}
+	return nil
+}
+
+func setReusePort(fd uintptr) error {
+	if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEPORT, 1); err != nil {
+		return errors.New("failed to set SO_REUSEPORT").Base(err).AtWarning()
+	}
+	return nil
+}
@@ -1,5 +1,5 @@
-//go:build js || netbsd || openbsd || solaris
-// +build js netbsd openbsd solaris
+//go:build js || netbsd || solaris
+// +build js netbsd solaris
 
 package internet
 
@@ -10,6 +10,7 @@ type splitConn struct {
 	writer     io.WriteCloser
 	reader     io.ReadCloser
 	remoteAddr net.Addr
+	peerAddr   net.Addr
 	localAddr  net.Addr
 	onClose    func()
 }
@@ -48,6 +49,15 @@ func (c *splitConn) RemoteAddr() net.Addr {
 	return c.remoteAddr
 }
 
+// PeerAddr implements stat.ForwardedConnection. It is the address the request
+// came from, or the remote address on the client side.
+func (c *splitConn) PeerAddr() net.Addr {
+	if c.peerAddr != nil {
+		return c.peerAddr
+	}
+	return c.remoteAddr
+}
+
 func (c *splitConn) SetDeadline(t time.Time) error {
 	// TODO cannot do anything useful
 	return nil
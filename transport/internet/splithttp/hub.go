@@ -14,11 +14,11 @@ import (
 
 	"github.com/quic-go/quic-go"
 	"github.com/quic-go/quic-go/http3"
-	goreality "github.com/xtls/reality"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	http_proto "github.com/xtls/xray-core/common/protocol/http"
+	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal/done"
 	"github.com/xtls/xray-core/transport/internet"
 	"github.com/xtls/xray-core/transport/internet/reality"
@@ -31,11 +31,59 @@ type requestHandler struct {
 	host      string
 	path      string
 	ln        *Listener
-	sessionMu *sync.Mutex
-	sessions  sync.Map
+	sessions  *sessionRegistry
 	localAddr net.Addr
 }
 
+// sessionRegistry holds the sessions of the listeners on an address and path.
+// A listener that replaces a closed one on a reload takes over its sessions,
+// whose GET requests keep going on the connections accepted before.
+type sessionRegistry struct {
+	sync.Mutex // for upserting sessions
+	sync.Map   // session ID -> *httpSession
+	key        string
+	listeners  int
+}
+
+// sessionRegistryTTL is how long the sessions of the last closed listener on an
+// address wait for a new one.
+const sessionRegistryTTL = 30 * time.Second
+
+var (
+	sessionRegistriesAccess sync.Mutex
+	sessionRegistries       = make(map[string]*sessionRegistry)
+)
+
+func acquireSessionRegistry(key string) *sessionRegistry {
+	sessionRegistriesAccess.Lock()
+	defer sessionRegistriesAccess.Unlock()
+	r, found := sessionRegistries[key]
+	if !found {
+		r = &sessionRegistry{key: key}
+		sessionRegistries[key] = r
+	} else if r.listeners == 0 {
+		errors.LogInfo(context.Background(), "taking over XHTTP sessions on ", key)
+	}
+	r.listeners++
+	return r
+}
+
+func (r *sessionRegistry) release() {
+	sessionRegistriesAccess.Lock()
+	defer sessionRegistriesAccess.Unlock()
+	r.listeners--
+	if r.listeners > 0 {
+		return
+	}
+	time.AfterFunc(sessionRegistryTTL, func() {
+		sessionRegistriesAccess.Lock()
+		defer sessionRegistriesAccess.Unlock()
+		if r.listeners == 0 && sessionRegistries[r.key] == r {
+			delete(sessionRegistries, r.key)
+		}
+	})
+}
+
 type httpSession struct {
 	uploadQueue *uploadQueue
 	// for as long as the GET request is not opened by the client, this will be
@@ -53,8 +101,8 @@ func (h *requestHandler) upsertSession(sessionId string) *httpSession {
 	}
 
 	// slow path
-	h.sessionMu.Lock()
-	defer h.sessionMu.Unlock()
+	h.sessions.Lock()
+	defer h.sessions.Unlock()
 
 	currentSessionAny, ok = h.sessions.Load(sessionId)
 	if ok {
@@ -155,7 +203,13 @@ func (h *requestHandler) ServeHTTP(writer http.ResponseWriter, request *http.Req
 			Port: remoteAddr.(*net.TCPAddr).Port,
 		}
 	}
+	peerAddr := remoteAddr
 	if len(forwardedAddrs) > 0 && forwardedAddrs[0].Family().IsIP() {
+		if h.ln.gate != nil && !h.ln.gate.AllowForwarded(forwardedAddrs[0].IP()) {
+			errors.LogInfo(context.Background(), "forwarded source not allowed: ", forwardedAddrs[0])
+			writer.WriteHeader(http.StatusForbidden)
+			return
+		}
 		remoteAddr = &net.TCPAddr{
 			IP:   forwardedAddrs[0].IP(),
 			Port: 0,
@@ -287,6 +341,7 @@ func (h *requestHandler) ServeHTTP(writer http.ResponseWriter, request *http.Req
 			writer:     httpSC,
 			reader:     httpSC,
 			remoteAddr: remoteAddr,
+			peerAddr:   peerAddr,
 			localAddr:  h.localAddr,
 		}
 		if sessionId != "" { // if not stream-one
@@ -336,18 +391,22 @@ func (c *httpServerConn) Close() error {
 
 type Listener struct {
 	sync.Mutex
-	server     http.Server
-	h3server   *http3.Server
-	listener   net.Listener
-	h3listener *quic.EarlyListener
-	config     *Config
-	addConn    internet.ConnHandler
-	isH3       bool
+	server        http.Server
+	h3server      *http3.Server
+	listener      net.Listener
+	h3listener    *quic.EarlyListener
+	config        *Config
+	addConn       internet.ConnHandler
+	isH3          bool
+	sessions      *sessionRegistry
+	realityChecks common.Closable
+	gate          session.ListenGate
 }
 
 func ListenXH(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, addConn internet.ConnHandler) (internet.Listener, error) {
 	l := &Listener{
 		addConn: addConn,
+		gate:    session.ListenGateFromContext(ctx),
 	}
 	l.config = streamSettings.ProtocolSettings.(*Config)
 	if l.config != nil {
@@ -356,16 +415,23 @@ func ListenXH(ctx context.Context, address net.Address, port net.Port, streamSet
 		}
 	}
 	handler := &requestHandler{
-		config:    l.config,
-		host:      l.config.Host,
-		path:      l.config.GetNormalizedPath(),
-		ln:        l,
-		sessionMu: &sync.Mutex{},
-		sessions:  sync.Map{},
+		config: l.config,
+		host:   l.config.Host,
+		path:   l.config.GetNormalizedPath(),
+		ln:     l,
 	}
 	tlsConfig := getTLSConfig(streamSettings)
 	l.isH3 = len(tlsConfig.NextProtos) == 1 && tlsConfig.NextProtos[0] == "h3"
 
+	registryKey := "tcp:" + net.JoinHostPort(address.String(), port.String())
+	if port == net.Port(0) {
+		registryKey = "unix:" + address.Domain()
+	} else if l.isH3 {
+		registryKey = "udp:" + net.JoinHostPort(address.String(), port.String())
+	}
+	handler.sessions = acquireSessionRegistry(registryKey + handler.path)
+	l.sessions = handler.sessions
+
 	var err error
 	if port == net.Port(0) { // unix
 		l.listener, err = internet.ListenSystem(ctx, &net.UnixAddr{
@@ -373,6 +439,7 @@ func ListenXH(ctx context.Context, address net.Address, port net.Port, streamSet
 			Net:  "unix",
 		}, streamSettings.SocketSettings)
 		if err != nil {
+			l.sessions.release()
 			return nil, errors.New("failed to listen UNIX domain socket for XHTTP on ", address).Base(err)
 		}
 		errors.LogInfo(ctx, "listening UNIX domain socket for XHTTP on ", address)
@@ -382,10 +449,12 @@ func ListenXH(ctx context.Context, address net.Address, port net.Port, streamSet
 			Port: int(port),
 		}, streamSettings.SocketSettings)
 		if err != nil {
+			l.sessions.release()
 			return nil, errors.New("failed to listen UDP for XHTTP/3 on ", address, ":", port).Base(err)
 		}
 		l.h3listener, err = quic.ListenEarly(Conn, tlsConfig, nil)
 		if err != nil {
+			l.sessions.release()
 			return nil, errors.New("failed to listen QUIC for XHTTP/3 on ", address, ":", port).Base(err)
 		}
 		errors.LogInfo(ctx, "listening QUIC for XHTTP/3 on ", address, ":", port)
@@ -406,6 +475,7 @@ func ListenXH(ctx context.Context, address net.Address, port net.Port, streamSet
 			Port: int(port),
 		}, streamSettings.SocketSettings)
 		if err != nil {
+			l.sessions.release()
 			return nil, errors.New("failed to listen TCP for XHTTP on ", address, ":", port).Base(err)
 		}
 		errors.LogInfo(ctx, "listening TCP for XHTTP on ", address, ":", port)
@@ -419,7 +489,9 @@ func ListenXH(ctx context.Context, address net.Address, port net.Port, streamSet
 			}
 		}
 		if config := reality.ConfigFromStreamSettings(streamSettings); config != nil {
-			l.listener = goreality.NewListener(l.listener, config.GetREALITYConfig())
+			realityConfig := config.GetREALITYConfig()
+			l.listener = reality.NewListener(l.listener, realityConfig, config.GetREALITYTargets(realityConfig))
+			l.realityChecks = config.CheckTargets()
 		}
 
 		handler.localAddr = l.listener.Addr()
@@ -457,6 +529,8 @@ func (ln *Listener) Addr() net.Addr {
 
 // Close implements net.Listener.Close().
 func (ln *Listener) Close() error {
+	ln.sessions.release()
+	common.Close(ln.realityChecks)
 	if ln.h3server != nil {
 		if err := ln.h3server.Close(); err != nil {
 			return err
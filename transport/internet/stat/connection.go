@@ -10,10 +10,20 @@ type Connection interface {
 	net.Conn
 }
 
+// PacketConnection is a Connection of a UDP flow, with one packet in every Read
+// and Write, that a stream transport carries.
+type PacketConnection interface {
+	Connection
+	IsPacketConnection()
+}
+
 type CounterConnection struct {
 	Connection
 	ReadCounter  stats.Counter
 	WriteCounter stats.Counter
+	// SpliceCounter counts the traffic spliced to or from the connection in
+	// the kernel, which the other counters count as well.
+	SpliceCounter stats.Counter
 }
 
 func (c *CounterConnection) Read(b []byte) (int, error) {
@@ -32,3 +42,23 @@ func (c *CounterConnection) Write(b []byte) (int, error) {
 	}
 	return nBytes, err
 }
+
+// ForwardedConnection is a Connection whose RemoteAddr is the source forwarded
+// in a header of its request, such as X-Forwarded-For, not its peer.
+type ForwardedConnection interface {
+	Connection
+	// PeerAddr returns the address of the peer of the transport.
+	PeerAddr() net.Addr
+}
+
+// PeerAddr returns the address of the peer of the transport of conn, not the
+// source forwarded in a header.
+func PeerAddr(conn net.Conn) net.Addr {
+	if c, ok := conn.(*CounterConnection); ok {
+		conn = c.Connection
+	}
+	if c, ok := conn.(ForwardedConnection); ok {
+		return c.PeerAddr()
+	}
+	return conn.RemoteAddr()
+}
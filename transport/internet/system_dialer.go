@@ -4,18 +4,53 @@ import (
 	"context"
 	"math/rand"
 	gonet "net"
+	"runtime"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/sagernet/sing/common/control"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/features/dns"
 	"github.com/xtls/xray-core/features/outbound"
 )
 
 var effectiveSystemDialer SystemDialer = &DefaultSystemDialer{}
 
+// SocketProtector is called with the file descriptor of each socket the
+// default system dialer dials, before it connects, such as for Android apps to
+// keep it out of their VPN with VpnService.protect. The dial fails if it
+// returns an error.
+type SocketProtector func(network, address string, fd uintptr) error
+
+var socketProtector atomic.Pointer[SocketProtector]
+
+// SetSocketProtector sets the protector of the sockets of the default system
+// dialer, or removes it if p is nil. It is kept when the system dialer is
+// replaced.
+//
+// xray:api:beta
+func SetSocketProtector(p SocketProtector) {
+	if p == nil {
+		socketProtector.Store(nil)
+		return
+	}
+	socketProtector.Store(&p)
+}
+
+func protectSocket(network, address string, fd uintptr) error {
+	p := socketProtector.Load()
+	if p == nil {
+		return nil
+	}
+	if err := (*p)(network, address, fd); err != nil {
+		return errors.New("failed to protect socket").Base(err)
+	}
+	return nil
+}
+
 type SystemDialer interface {
 	Dial(ctx context.Context, source net.Address, destination net.Destination, sockopt *SocketConfig) (net.Conn, error)
 	DestIpAddress() net.IP
@@ -45,6 +80,15 @@ func resolveSrcAddr(network net.Network, src net.Address) net.Addr {
 	}
 }
 
+// gatewayPort returns the local port the outbound of ctx sends UDP from, 0 for
+// any.
+func gatewayPort(ctx context.Context) net.Port {
+	if outbounds := session.OutboundsFromContext(ctx); len(outbounds) > 0 {
+		return outbounds[len(outbounds)-1].GatewayPort
+	}
+	return 0
+}
+
 func hasBindAddr(sockopt *SocketConfig) bool {
 	return sockopt != nil && len(sockopt.BindAddress) > 0 && sockopt.BindPort > 0
 }
@@ -52,6 +96,18 @@ func hasBindAddr(sockopt *SocketConfig) bool {
 func (d *DefaultSystemDialer) Dial(ctx context.Context, src net.Address, dest net.Destination, sockopt *SocketConfig) (net.Conn, error) {
 	errors.LogDebug(ctx, "dialing to "+dest.String())
 
+	if dest.Network == net.Network_UNIX {
+		address := dest.NetAddr()
+		if (runtime.GOOS == "linux" || runtime.GOOS == "android") && len(address) > 1 && address[0] == '@' && address[1] == '@' {
+			// padded abstract unix domain socket, as used by haproxy
+			fullAddr := make([]byte, len(syscall.RawSockaddrUnix{}.Path))
+			copy(fullAddr, address[1:])
+			address = string(fullAddr)
+		}
+		dialer := &net.Dialer{Timeout: time.Second * 16}
+		return dialer.DialContext(ctx, dest.Network.SystemString(), address)
+	}
+
 	if dest.Network == net.Network_UDP && !hasBindAddr(sockopt) {
 		srcAddr := resolveSrcAddr(net.Network_UDP, src)
 		if srcAddr == nil {
@@ -71,21 +127,45 @@ func (d *DefaultSystemDialer) Dial(ctx context.Context, src net.Address, dest ne
 					errors.LogInfoInner(ctx, err, "failed to apply external controller")
 				}
 			}
-			return c.Control(func(fd uintptr) {
+			var protectErr error
+			if err := c.Control(func(fd uintptr) {
+				protectErr = protectSocket(network, destAddr.String(), fd)
 				if sockopt != nil {
 					if err := applyOutboundSocketOptions(network, destAddr.String(), fd, sockopt); err != nil {
 						errors.LogInfo(ctx, err, "failed to apply socket options")
 					}
 				}
-			})
+			}); err != nil {
+				return err
+			}
+			return protectErr
 		}
-		packetConn, err := lc.ListenPacket(ctx, srcAddr.Network(), srcAddr.String())
-		if err != nil {
-			return nil, err
+		var packetConn net.PacketConn
+		if port := gatewayPort(ctx); port != 0 {
+			// The port is taken if free, else any is.
+			addr := *srcAddr.(*net.UDPAddr)
+			addr.Port = int(port)
+			if packetConn, err = lc.ListenPacket(ctx, addr.Network(), addr.String()); err != nil {
+				errors.LogInfoInner(ctx, err, "failed to send from port ", port)
+			}
+		}
+		if packetConn == nil {
+			packetConn, err = lc.ListenPacket(ctx, srcAddr.Network(), srcAddr.String())
+			if err != nil {
+				return nil, err
+			}
+		}
+		// Sockets of a dial only carry one session, so they do not merge
+		// reads, which would need a large buffer for each.
+		udpConn, _ := packetConn.(*net.UDPConn)
+		var batch *UDPBatchConn
+		if udpConn != nil {
+			batch = NewUDPBatchConn(udpConn, false)
 		}
 		return &PacketConnWrapper{
-			Conn: packetConn,
-			Dest: destAddr,
+			Conn:  packetConn,
+			Dest:  destAddr,
+			Batch: batch,
 		}, nil
 	}
 	// Chrome defaults
@@ -118,7 +198,7 @@ func (d *DefaultSystemDialer) Dial(ctx context.Context, src net.Address, dest ne
 		KeepAliveConfig: keepAliveConfig,
 	}
 
-	if sockopt != nil || len(d.controllers) > 0 {
+	if sockopt != nil || len(d.controllers) > 0 || socketProtector.Load() != nil {
 		if sockopt != nil && sockopt.TcpMptcp {
 			dialer.SetMultipathTCP(true)
 		}
@@ -128,7 +208,9 @@ func (d *DefaultSystemDialer) Dial(ctx context.Context, src net.Address, dest ne
 					errors.LogInfoInner(ctx, err, "failed to apply external controller")
 				}
 			}
-			return c.Control(func(fd uintptr) {
+			var protectErr error
+			if err := c.Control(func(fd uintptr) {
+				protectErr = protectSocket(network, address, fd)
 				if sockopt != nil {
 					if err := applyOutboundSocketOptions(network, address, fd, sockopt); err != nil {
 						errors.LogInfoInner(ctx, err, "failed to apply socket options")
@@ -139,11 +221,18 @@ func (d *DefaultSystemDialer) Dial(ctx context.Context, src net.Address, dest ne
 						}
 					}
 				}
-			})
+			}); err != nil {
+				return err
+			}
+			return protectErr
 		}
 	}
 
-	return dialer.DialContext(ctx, dest.Network.SystemString(), dest.NetAddr())
+	conn, err := dialer.DialContext(ctx, dest.Network.SystemString(), dest.NetAddr())
+	if err == nil && sockopt != nil && dest.Network == net.Network_TCP {
+		applyConnectedConnOptions(ctx, conn, sockopt)
+	}
+	return conn, err
 }
 
 func (d *DefaultSystemDialer) DestIpAddress() net.IP {
@@ -153,6 +242,8 @@ func (d *DefaultSystemDialer) DestIpAddress() net.IP {
 type PacketConnWrapper struct {
 	Conn net.PacketConn
 	Dest net.Addr
+	// Batch reads and writes Conn several datagrams at a time, if not nil.
+	Batch *UDPBatchConn
 }
 
 func (c *PacketConnWrapper) Close() error {
@@ -273,3 +364,25 @@ func (c *FakePacketConn) SetReadBuffer(bytes int) error {
 	// random warnings about UDP buffers to stdout
 	return nil
 }
+
+// ToPacketConn unwraps a connection returned by Dial for a UDP destination
+// into a PacketConn and the resolved remote address, as needed by QUIC.
+// Connections that are not packet based are wrapped in a FakePacketConn, while
+// a PacketConnWrapper must hold a UDP connection.
+func ToPacketConn(conn net.Conn) (net.PacketConn, *net.UDPAddr, error) {
+	switch c := conn.(type) {
+	case *PacketConnWrapper:
+		udpConn, ok := c.Conn.(*net.UDPConn)
+		if !ok {
+			return nil, nil, errors.New("PacketConnWrapper does not contain a UDP connection")
+		}
+		udpAddr, err := net.ResolveUDPAddr("udp", c.Dest.String())
+		return udpConn, udpAddr, err
+	case *net.UDPConn:
+		udpAddr, err := net.ResolveUDPAddr("udp", c.RemoteAddr().String())
+		return c, udpAddr, err
+	default:
+		udpAddr, err := net.ResolveUDPAddr("udp", c.RemoteAddr().String())
+		return &FakePacketConn{Conn: c}, udpAddr, err
+	}
+}
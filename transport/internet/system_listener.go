@@ -4,6 +4,7 @@ import (
 	"context"
 	gonet "net"
 	"os"
+	"os/user"
 	"runtime"
 	"strconv"
 	"strings"
@@ -14,6 +15,8 @@ import (
 	"github.com/sagernet/sing/common/control"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/transport/internet/padding"
 )
 
 var effectiveListener = DefaultListener{}
@@ -77,6 +80,42 @@ func (conn *UnixConnWrapper) RemoteAddr() net.Addr {
 }
 
 func (dl *DefaultListener) Listen(ctx context.Context, addr net.Addr, sockopt *SocketConfig) (l net.Listener, err error) {
+	// The socket inherited from the process being upgraded is listened on as
+	// it is, with the socket options it has.
+	key := socketKey(addr)
+	if l, err = inheritedListener(key); l == nil && err == nil {
+		l, err = dl.listen(ctx, addr, sockopt)
+	}
+	if err == nil {
+		recordListened(key, l)
+	}
+	if err == nil && sockopt != nil && sockopt.Tproxy == SocketConfig_WinDivert {
+		l, err = redirectListen(l, sockopt)
+	}
+	if err == nil && sockopt != nil && sockopt.Tproxy == SocketConfig_Ebpf {
+		l, err = ebpfListen(l, sockopt)
+	}
+	if err == nil && sockopt != nil && (sockopt.TcpMaxPacingRate > 0 || sockopt.MtuDiscover != SocketConfig_Default) {
+		if _, ok := addr.(*net.TCPAddr); ok {
+			l = &connectedListener{Listener: l, ctx: ctx, config: sockopt}
+		}
+	}
+	gate := session.ListenGateFromContext(ctx)
+	if err == nil && sockopt != nil && sockopt.AcceptProxyProtocol {
+		// The gate checks the sources in the headers, not the proxies.
+		policyFunc := func(upstream net.Addr) (proxyproto.Policy, error) { return proxyproto.REQUIRE, nil }
+		l = &proxyproto.Listener{Listener: l, Policy: policyFunc, ValidateHeader: gateProxyHeader(gate)}
+	} else if err == nil && gate != nil {
+		l = &gatedListener{Listener: l, gate: gate}
+	}
+	if err == nil && sockopt != nil && sockopt.Padding != nil {
+		l = padding.NewListener(l, sockopt.Padding)
+	}
+	return l, err
+}
+
+// listen listens on a new socket of addr.
+func (dl *DefaultListener) listen(ctx context.Context, addr net.Addr, sockopt *SocketConfig) (net.Listener, error) {
 	var lc net.ListenConfig
 	var network, address string
 	// callback is called after the Listen function returns
@@ -84,6 +123,8 @@ func (dl *DefaultListener) Listen(ctx context.Context, addr net.Addr, sockopt *S
 		return l, err
 	}
 
+	listen := lc.Listen
+
 	switch addr := addr.(type) {
 	case *net.TCPAddr:
 		network = addr.Network()
@@ -118,6 +159,13 @@ func (dl *DefaultListener) Listen(ctx context.Context, addr net.Addr, sockopt *S
 		network = addr.Network()
 		address = addr.Name
 
+		if isActivationAddress(address) {
+			listen = func(context.Context, string, string) (net.Listener, error) {
+				return activatedListener(address)
+			}
+			break
+		}
+
 		if (runtime.GOOS == "linux" || runtime.GOOS == "android") && address[0] == '@' {
 			// linux abstract unix domain socket is lockfree
 			if len(address) > 1 && address[1] == '@' {
@@ -127,17 +175,26 @@ func (dl *DefaultListener) Listen(ctx context.Context, addr net.Addr, sockopt *S
 				address = string(fullAddr)
 			}
 		} else {
-			// split permission from address
+			// split permission and owner from address
 			var filePerm *os.FileMode
-			if s := strings.Split(address, ","); len(s) == 2 {
+			uid, gid := -1, -1
+			if s := strings.Split(address, ","); len(s) == 2 || len(s) == 3 {
 				address = s[0]
-				perm, perr := strconv.ParseUint(s[1], 8, 32)
-				if perr != nil {
-					return nil, errors.New("failed to parse permission: " + s[1]).Base(perr)
-				}
+				if s[1] != "" {
+					perm, perr := strconv.ParseUint(s[1], 8, 32)
+					if perr != nil {
+						return nil, errors.New("failed to parse permission: " + s[1]).Base(perr)
+					}
 
-				mode := os.FileMode(perm)
-				filePerm = &mode
+					mode := os.FileMode(perm)
+					filePerm = &mode
+				}
+				if len(s) == 3 {
+					var err error
+					if uid, gid, err = parseSocketOwner(s[2]); err != nil {
+						return nil, err
+					}
+				}
 			}
 			// normal unix domain socket needs lock
 			locker := &FileLocker{
@@ -154,6 +211,12 @@ func (dl *DefaultListener) Listen(ctx context.Context, addr net.Addr, sockopt *S
 					return nil, err
 				}
 				l = &UnixListenerWrapper{UnixListener: l.(*net.UnixListener), locker: locker}
+				if uid != -1 || gid != -1 {
+					if err := os.Lchown(address, uid, gid); err != nil {
+						l.Close()
+						return nil, errors.New("failed to set owner for " + address).Base(err)
+					}
+				}
 				if filePerm == nil {
 					return l, nil
 				}
@@ -167,20 +230,60 @@ func (dl *DefaultListener) Listen(ctx context.Context, addr net.Addr, sockopt *S
 		}
 	}
 
-	l, err = callback(lc.Listen(ctx, network, address))
-	if err == nil && sockopt != nil && sockopt.AcceptProxyProtocol {
-		policyFunc := func(upstream net.Addr) (proxyproto.Policy, error) { return proxyproto.REQUIRE, nil }
-		l = &proxyproto.Listener{Listener: l, Policy: policyFunc}
-	}
-	return l, err
+	return callback(listen(ctx, network, address))
 }
 
 func (dl *DefaultListener) ListenPacket(ctx context.Context, addr net.Addr, sockopt *SocketConfig) (net.PacketConn, error) {
-	var lc net.ListenConfig
-
-	lc.Control = getControlFunc(ctx, sockopt, dl.controllers)
+	key := socketKey(addr)
+	conn, err := inheritedPacketConn(key)
+	if conn == nil && err == nil {
+		var lc net.ListenConfig
+		lc.Control = getControlFunc(ctx, sockopt, dl.controllers)
+		conn, err = lc.ListenPacket(ctx, addr.Network(), addr.String())
+	}
+	if err == nil {
+		recordListened(key, conn)
+	}
+	if err == nil && sockopt != nil && sockopt.Tproxy == SocketConfig_WinDivert {
+		conn, err = redirectListenPacket(conn, sockopt)
+	}
+	if err == nil && sockopt != nil && sockopt.AcceptProxyProtocolUdp {
+		conn = newProxyProtocolPacketConn(conn)
+	}
+	if gate := session.ListenGateFromContext(ctx); err == nil && gate != nil {
+		conn = &gatedPacketConn{PacketConn: conn, gate: gate}
+	}
+	return conn, err
+}
 
-	return lc.ListenPacket(ctx, addr.Network(), addr.String())
+// parseSocketOwner parses "user", "user:group" or ":group" of a unix domain
+// socket address, by name or numeric ID. Unset parts are -1.
+func parseSocketOwner(owner string) (int, int, error) {
+	uid, gid := -1, -1
+	name, group, _ := strings.Cut(owner, ":")
+	if name != "" {
+		id, err := strconv.Atoi(name)
+		if err != nil {
+			u, lerr := user.Lookup(name)
+			if lerr != nil {
+				return 0, 0, errors.New("failed to find user " + name).Base(lerr)
+			}
+			id, _ = strconv.Atoi(u.Uid)
+		}
+		uid = id
+	}
+	if group != "" {
+		id, err := strconv.Atoi(group)
+		if err != nil {
+			g, lerr := user.LookupGroup(group)
+			if lerr != nil {
+				return 0, 0, errors.New("failed to find group " + group).Base(lerr)
+			}
+			id, _ = strconv.Atoi(g.Gid)
+		}
+		gid = id
+	}
+	return uid, gid, nil
 }
 
 // RegisterListenerController adds a controller to the effective system listener.
@@ -10,10 +10,12 @@ import (
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/tracing"
 	"github.com/xtls/xray-core/transport/internet"
 	"github.com/xtls/xray-core/transport/internet/reality"
 	"github.com/xtls/xray-core/transport/internet/stat"
 	"github.com/xtls/xray-core/transport/internet/tls"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Dial dials a new TCP connection to the given destination.
@@ -64,6 +66,7 @@ func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.Me
 				tlsConfig.NextProtos = []string{"h2", "http/1.1"}
 			}
 		}
+		_, span := tracing.Start(ctx, tracing.Handshake, attribute.String("security", "tls"))
 		if fingerprint := tls.GetFingerprint(config.Fingerprint); fingerprint != nil {
 			conn = tls.UClient(conn, tlsConfig, fingerprint)
 			if len(tlsConfig.NextProtos) == 1 && tlsConfig.NextProtos[0] == "http/1.1" { // allow manually specify
@@ -75,6 +78,7 @@ func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.Me
 			conn = tls.Client(conn, tlsConfig)
 			err = conn.(*tls.Conn).HandshakeContext(ctx)
 		}
+		tracing.End(span, err)
 		if err != nil {
 			if isFromMitmVerify {
 				return nil, errors.New("MITM freedom RAW TLS: failed to verify Domain Fronting certificate from " + mitmServerName).Base(err).AtWarning()
@@ -87,7 +91,10 @@ func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.Me
 			return nil, errors.New("MITM freedom RAW TLS: unexpected Negotiated Protocol (" + negotiatedProtocol + ") with " + mitmServerName).AtWarning()
 		}
 	} else if config := reality.ConfigFromStreamSettings(streamSettings); config != nil {
-		if conn, err = reality.UClient(conn, config, ctx, dest); err != nil {
+		_, span := tracing.Start(ctx, tracing.Handshake, attribute.String("security", "reality"))
+		conn, err = reality.UClient(conn, config, ctx, dest)
+		tracing.End(span, err)
+		if err != nil {
 			return nil, err
 		}
 	}
@@ -18,12 +18,14 @@ import (
 
 // Listener is an internet.Listener that listens for TCP connections.
 type Listener struct {
-	listener      net.Listener
-	tlsConfig     *gotls.Config
-	realityConfig *goreality.Config
-	authConfig    internet.ConnectionAuthenticator
-	config        *Config
-	addConn       internet.ConnHandler
+	listener       net.Listener
+	tlsConfig      *gotls.Config
+	realityConfig  *goreality.Config
+	realityTargets map[string]*goreality.Config
+	realityChecks  common.Closable
+	authConfig     internet.ConnectionAuthenticator
+	config         *Config
+	addConn        internet.ConnHandler
 }
 
 // ListenTCP creates a new Listener based on configurations.
@@ -75,7 +77,12 @@ func ListenTCP(ctx context.Context, address net.Address, port net.Port, streamSe
 	}
 	if config := reality.ConfigFromStreamSettings(streamSettings); config != nil {
 		l.realityConfig = config.GetREALITYConfig()
+		l.realityTargets = config.GetREALITYTargets(l.realityConfig)
+		l.realityChecks = config.CheckTargets()
 		go goreality.DetectPostHandshakeRecordsLens(l.realityConfig)
+		for _, target := range l.realityTargets {
+			go goreality.DetectPostHandshakeRecordsLens(target)
+		}
 	}
 
 	if tcpSettings.HeaderSettings != nil {
@@ -112,7 +119,7 @@ func (v *Listener) keepAccepting() {
 			if v.tlsConfig != nil {
 				conn = tls.Server(conn, v.tlsConfig)
 			} else if v.realityConfig != nil {
-				if conn, err = reality.Server(conn, v.realityConfig); err != nil {
+				if conn, err = reality.ServerWithTargets(conn, v.realityConfig, v.realityTargets); err != nil {
 					errors.LogInfo(context.Background(), err.Error())
 					return
 				}
@@ -132,6 +139,7 @@ func (v *Listener) Addr() net.Addr {
 
 // Close implements internet.Listener.Close.
 func (v *Listener) Close() error {
+	common.Close(v.realityChecks)
 	return v.listener.Close()
 }
 
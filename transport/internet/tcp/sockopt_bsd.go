@@ -1,5 +1,5 @@
-//go:build freebsd
-// +build freebsd
+//go:build freebsd || openbsd
+// +build freebsd openbsd
 
 package tcp
 
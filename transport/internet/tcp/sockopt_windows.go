@@ -0,0 +1,21 @@
+package tcp
+
+import (
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+// GetOriginalDestination from tcp conn redirected by WinDivert
+func GetOriginalDestination(conn stat.Connection) (net.Destination, error) {
+	ip, port, err := internet.OriginalDst(conn.LocalAddr(), conn.RemoteAddr())
+	if err != nil {
+		return net.Destination{}, errors.New("failed to get destination").Base(err)
+	}
+	dest := net.TCPDestination(net.IPAddress(ip), net.Port(port))
+	if !dest.IsValid() {
+		return net.Destination{}, errors.New("failed to parse destination.")
+	}
+	return dest, nil
+}
@@ -0,0 +1,360 @@
+package tls
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/events"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/platform"
+	"github.com/xtls/xray-core/common/protocol/tls/cert"
+	"golang.org/x/crypto/acme"
+)
+
+// acmeManager keeps the certificate of an Acme config issued and renewed, for
+// every listener serving it.
+type acmeManager struct {
+	config  *Acme
+	storage string
+	current atomic.Pointer[tls.Certificate]
+	issued  atomic.Bool
+	// wake tells the OCSP stapling of current, if any, that it is renewed.
+	wake     chan struct{}
+	stapling sync.Once
+}
+
+var (
+	acmeManagersAccess sync.Mutex
+	acmeManagers       = make(map[string]*acmeManager)
+)
+
+func getACMEManager(config *Acme) *acmeManager {
+	key := config.DirectoryUrl + " " + strings.Join(config.Domains, ",")
+	acmeManagersAccess.Lock()
+	defer acmeManagersAccess.Unlock()
+	if m, found := acmeManagers[key]; found {
+		return m
+	}
+	m := &acmeManager{
+		config:  config,
+		storage: config.StoragePath,
+		wake:    make(chan struct{}, 1),
+	}
+	if !filepath.IsAbs(m.storage) {
+		m.storage = platform.GetCertLocation(m.storage)
+	}
+	if certificate, err := m.loadCertificate(); err == nil {
+		m.current.Store(certificate)
+		m.issued.Store(true)
+	} else {
+		// Listeners start right away, with a self-signed certificate until the
+		// first one is issued.
+		placeholder := cert.MustGenerate(nil, cert.CommonName(config.Domains[0]), cert.DNSNames(config.Domains...))
+		certPEM, keyPEM := placeholder.ToPEM()
+		placeholderPair, _ := parseKeyPair(certPEM, keyPEM)
+		m.current.Store(placeholderPair)
+	}
+	acmeManagers[key] = m
+	go m.keepRenewing()
+	return m
+}
+
+// certificate returns the certificate of m, swapped as it is renewed, which
+// every config serving it shares. Its OCSP response is stapled as of the first
+// entry with OCSP stapling.
+func (m *acmeManager) certificate(entry *Certificate) *atomic.Pointer[tls.Certificate] {
+	if entry.OcspStapling != 0 {
+		m.stapling.Do(func() {
+			go stapleOCSP(entry, &m.current, m.wake)
+		})
+	}
+	return &m.current
+}
+
+func (m *acmeManager) keepRenewing() {
+	retry := time.Minute
+	var obtained time.Time
+	for {
+		current, issued := m.current.Load(), m.issued.Load()
+
+		// Renew with a third of the lifetime left, as Let's Encrypt suggests,
+		// but never more than once a minute.
+		lifetime := current.Leaf.NotAfter.Sub(current.Leaf.NotBefore)
+		wait := time.Until(current.Leaf.NotAfter.Add(-lifetime / 3))
+		wait = max(wait, time.Until(obtained.Add(time.Minute)))
+		if issued && wait > 0 {
+			time.Sleep(min(wait, 12*time.Hour))
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		certificate, err := m.obtain(ctx)
+		cancel()
+		if err != nil {
+			errors.LogWarningInner(context.Background(), err, "failed to obtain ACME certificate for ", m.config.Domains, ", retrying in ", retry)
+			time.Sleep(retry)
+			retry = min(retry*2, 6*time.Hour)
+			continue
+		}
+		retry = time.Minute
+		obtained = time.Now()
+		errors.LogInfo(context.Background(), "ACME certificate for ", m.config.Domains, " (expire on ", certificate.Leaf.NotAfter.Format(time.RFC3339), ") issued")
+		events.Emit(events.CertificateRenewed, "domains", strings.Join(m.config.Domains, ","), "notAfter", certificate.Leaf.NotAfter.Format(time.RFC3339), "source", "acme")
+
+		m.current.Store(certificate)
+		m.issued.Store(true)
+		select {
+		case m.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (m *acmeManager) obtain(ctx context.Context) (*tls.Certificate, error) {
+	accountKey, err := m.loadAccountKey()
+	if err != nil {
+		return nil, errors.New("failed to load ACME account key").Base(err)
+	}
+	client := &acme.Client{
+		Key:          accountKey,
+		DirectoryURL: m.config.DirectoryUrl,
+		UserAgent:    "Xray",
+		HTTPClient:   NewHTTPClient(time.Minute),
+	}
+	account := &acme.Account{}
+	if m.config.Email != "" {
+		account.Contact = []string{"mailto:" + m.config.Email}
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && err != acme.ErrAccountAlreadyExists {
+		return nil, errors.New("failed to register ACME account").Base(err)
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(m.config.Domains...))
+	if err != nil {
+		return nil, errors.New("failed to create ACME order").Base(err)
+	}
+	if order.Status != acme.StatusReady {
+		var solver *http01Solver
+		if m.config.Dns01 == nil {
+			if solver, err = startHTTP01Solver(m.config.Http01Listen); err != nil {
+				return nil, err
+			}
+			defer solver.Close()
+		}
+		for _, url := range order.AuthzURLs {
+			if err := m.authorize(ctx, client, url, solver); err != nil {
+				return nil, err
+			}
+		}
+		if order, err = client.WaitOrder(ctx, order.URI); err != nil {
+			return nil, errors.New("failed to wait for ACME order").Base(err)
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: m.config.Domains[0]},
+		DNSNames: m.config.Domains,
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, errors.New("failed to finalize ACME order").Base(err)
+	}
+
+	var certPEM []byte
+	for _, der := range chain {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	certificate, err := parseKeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, errors.New("invalid ACME certificate").Base(err)
+	}
+	certPath, keyPath := m.certificatePaths()
+	if err := writeACMEFile(certPath, certPEM); err != nil {
+		errors.LogWarningInner(ctx, err, "failed to store ACME certificate")
+	} else if err := writeACMEFile(keyPath, keyPEM); err != nil {
+		errors.LogWarningInner(ctx, err, "failed to store ACME certificate key")
+	}
+	return certificate, nil
+}
+
+func (m *acmeManager) authorize(ctx context.Context, client *acme.Client, url string, solver *http01Solver) error {
+	authz, err := client.GetAuthorization(ctx, url)
+	if err != nil {
+		return errors.New("failed to get ACME authorization").Base(err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+	challengeType := "http-01"
+	if m.config.Dns01 != nil {
+		challengeType = "dns-01"
+	}
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == challengeType {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return errors.New("no ", challengeType, " challenge offered for ", authz.Identifier.Value)
+	}
+
+	if solver != nil {
+		response, err := client.HTTP01ChallengeResponse(challenge.Token)
+		if err != nil {
+			return err
+		}
+		path := client.HTTP01ChallengePath(challenge.Token)
+		solver.responses.Store(path, response)
+		defer solver.responses.Delete(path)
+	} else {
+		provider, err := newACMEDNSProvider(m.config.Dns01)
+		if err != nil {
+			return err
+		}
+		value, err := client.DNS01ChallengeRecord(challenge.Token)
+		if err != nil {
+			return err
+		}
+		name := "_acme-challenge." + authz.Identifier.Value + "."
+		if err := provider.Present(ctx, name, value); err != nil {
+			return errors.New("failed to publish DNS-01 record ", name).Base(err)
+		}
+		defer func() {
+			if err := provider.CleanUp(context.Background(), name, value); err != nil {
+				errors.LogWarningInner(ctx, err, "failed to remove DNS-01 record ", name)
+			}
+		}()
+	}
+
+	if _, err := client.Accept(ctx, challenge); err != nil {
+		return errors.New("failed to accept ", challengeType, " challenge for ", authz.Identifier.Value).Base(err)
+	}
+	if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return errors.New("failed to authorize ", authz.Identifier.Value).Base(err)
+	}
+	return nil
+}
+
+func (m *acmeManager) certificatePaths() (string, string) {
+	name := strings.ReplaceAll(m.config.Domains[0], "*", "_")
+	return filepath.Join(m.storage, name+".crt"), filepath.Join(m.storage, name+".key")
+}
+
+func (m *acmeManager) loadCertificate() (*tls.Certificate, error) {
+	certPath, keyPath := m.certificatePaths()
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := parseKeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	for _, domain := range m.config.Domains {
+		if certificate.Leaf.VerifyHostname(strings.Replace(domain, "*", "wildcard", 1)) != nil {
+			return nil, errors.New("stored certificate does not cover ", domain)
+		}
+	}
+	return certificate, nil
+}
+
+func (m *acmeManager) loadAccountKey() (crypto.Signer, error) {
+	path := filepath.Join(m.storage, "account.key")
+	if keyPEM, err := os.ReadFile(path); err == nil {
+		block, _ := pem.Decode(keyPEM)
+		if block == nil {
+			return nil, errors.New("invalid PEM in ", path)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeACMEFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func writeACMEFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0o600)
+}
+
+func parseKeyPair(certPEM, keyPEM []byte) (*tls.Certificate, error) {
+	keyPair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if keyPair.Leaf, err = x509.ParseCertificate(keyPair.Certificate[0]); err != nil {
+		return nil, err
+	}
+	return &keyPair, nil
+}
+
+// http01Solver answers the HTTP-01 challenges of an order while it is being
+// authorized. Its address may be the target of an inbound already on port 80.
+type http01Solver struct {
+	*http.Server
+	responses sync.Map // path -> key authorization
+}
+
+func startHTTP01Solver(address string) (*http01Solver, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errors.New("failed to listen for HTTP-01 challenges on ", address).Base(err)
+	}
+	solver := &http01Solver{}
+	solver.Server = &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if response, found := solver.responses.Load(r.URL.Path); found {
+				w.Write([]byte(response.(string)))
+				return
+			}
+			http.NotFound(w, r)
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go solver.Serve(listener)
+	return solver, nil
+}
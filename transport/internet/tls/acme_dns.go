@@ -0,0 +1,207 @@
+package tls
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+)
+
+// ACMEDNSProvider publishes the TXT records answering DNS-01 challenges.
+type ACMEDNSProvider interface {
+	// Present publishes value as a TXT record of the fully qualified name, and
+	// returns once it can be queried.
+	Present(ctx context.Context, name, value string) error
+	// CleanUp removes a record published by Present.
+	CleanUp(ctx context.Context, name, value string) error
+}
+
+type ACMEDNSProviderCreator func(options map[string]string) (ACMEDNSProvider, error)
+
+var acmeDNSProviders = map[string]ACMEDNSProviderCreator{
+	"cloudflare": newCloudflareDNSProvider,
+	"exec":       newExecDNSProvider,
+}
+
+// RegisterACMEDNSProvider makes a DNS provider available to DNS-01 challenges
+// by name.
+func RegisterACMEDNSProvider(name string, creator ACMEDNSProviderCreator) error {
+	if _, found := acmeDNSProviders[name]; found {
+		return errors.New("ACME DNS provider ", name, " already registered")
+	}
+	acmeDNSProviders[name] = creator
+	return nil
+}
+
+// IsACMEDNSProviderRegistered reports whether name can be used for DNS-01.
+func IsACMEDNSProviderRegistered(name string) bool {
+	_, found := acmeDNSProviders[name]
+	return found
+}
+
+func newACMEDNSProvider(config *AcmeDns01) (ACMEDNSProvider, error) {
+	creator, found := acmeDNSProviders[config.Provider]
+	if !found {
+		return nil, errors.New("unknown ACME DNS provider ", config.Provider)
+	}
+	return creator(config.Options)
+}
+
+// execDNSProvider runs the "command" option as `command present|cleanup name
+// value`, for any DNS service it supports.
+type execDNSProvider struct {
+	command string
+}
+
+func newExecDNSProvider(options map[string]string) (ACMEDNSProvider, error) {
+	if options["command"] == "" {
+		return nil, errors.New(`ACME DNS provider exec requires "command"`)
+	}
+	return &execDNSProvider{command: options["command"]}, nil
+}
+
+func (p *execDNSProvider) run(ctx context.Context, action, name, value string) error {
+	output, err := exec.CommandContext(ctx, p.command, action, name, value).CombinedOutput()
+	if err != nil {
+		return errors.New(strings.TrimSpace(string(output))).Base(err)
+	}
+	return nil
+}
+
+func (p *execDNSProvider) Present(ctx context.Context, name, value string) error {
+	return p.run(ctx, "present", name, value)
+}
+
+func (p *execDNSProvider) CleanUp(ctx context.Context, name, value string) error {
+	return p.run(ctx, "cleanup", name, value)
+}
+
+// cloudflareDNSProvider manages records through the Cloudflare API with the
+// "api_token" option, in the zone of the "zone_id" option or else the closest
+// zone found for the name.
+type cloudflareDNSProvider struct {
+	token  string
+	zoneID string
+	client *http.Client
+}
+
+const cloudflareAPI = "https://api.cloudflare.com/client/v4"
+
+// cloudflarePropagation is how long Cloudflare takes to serve a new record
+// from all its name servers.
+const cloudflarePropagation = 10 * time.Second
+
+func newCloudflareDNSProvider(options map[string]string) (ACMEDNSProvider, error) {
+	if options["api_token"] == "" {
+		return nil, errors.New(`ACME DNS provider cloudflare requires "api_token"`)
+	}
+	return &cloudflareDNSProvider{
+		token:  options["api_token"],
+		zoneID: options["zone_id"],
+		client: NewHTTPClient(30 * time.Second),
+	}, nil
+}
+
+func (p *cloudflareDNSProvider) call(ctx context.Context, method, path string, body interface{}, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, cloudflareAPI+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+p.token)
+	request.Header.Set("Content-Type", "application/json")
+	response, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	var reply struct {
+		Success bool            `json:"success"`
+		Errors  json.RawMessage `json:"errors"`
+		Result  json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&reply); err != nil {
+		return errors.New("invalid Cloudflare API response").Base(err)
+	}
+	if !reply.Success {
+		return errors.New("Cloudflare API error: ", string(reply.Errors))
+	}
+	if result != nil {
+		return json.Unmarshal(reply.Result, result)
+	}
+	return nil
+}
+
+func (p *cloudflareDNSProvider) zone(ctx context.Context, name string) (string, error) {
+	if p.zoneID != "" {
+		return p.zoneID, nil
+	}
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	for i := 1; i < len(labels)-1; i++ {
+		var zones []struct {
+			ID string `json:"id"`
+		}
+		if err := p.call(ctx, http.MethodGet, "/zones?name="+url.QueryEscape(strings.Join(labels[i:], ".")), nil, &zones); err != nil {
+			return "", err
+		}
+		if len(zones) > 0 {
+			return zones[0].ID, nil
+		}
+	}
+	return "", errors.New("no Cloudflare zone found for ", name)
+}
+
+func (p *cloudflareDNSProvider) Present(ctx context.Context, name, value string) error {
+	zone, err := p.zone(ctx, name)
+	if err != nil {
+		return err
+	}
+	record := map[string]interface{}{
+		"type":    "TXT",
+		"name":    strings.TrimSuffix(name, "."),
+		"content": value,
+		"ttl":     120,
+	}
+	if err := p.call(ctx, http.MethodPost, "/zones/"+zone+"/dns_records", record, nil); err != nil {
+		return err
+	}
+	select {
+	case <-time.After(cloudflarePropagation):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *cloudflareDNSProvider) CleanUp(ctx context.Context, name, value string) error {
+	zone, err := p.zone(ctx, name)
+	if err != nil {
+		return err
+	}
+	var records []struct {
+		ID string `json:"id"`
+	}
+	query := url.Values{
+		"type":    {"TXT"},
+		"name":    {strings.TrimSuffix(name, ".")},
+		"content": {value},
+	}
+	if err := p.call(ctx, http.MethodGet, "/zones/"+zone+"/dns_records?"+query.Encode(), nil, &records); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := p.call(ctx, http.MethodDelete, "/zones/"+zone+"/dns_records/"+record.ID, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
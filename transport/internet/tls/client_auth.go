@@ -0,0 +1,199 @@
+package tls
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet/stat"
+	"golang.org/x/crypto/ocsp"
+)
+
+// clientVerifier checks the certificates of clients beyond their chain, which
+// crypto/tls verifies against the trusted CAs.
+type clientVerifier struct {
+	config *ClientVerification
+	crls   []*x509.RevocationList
+	access sync.Mutex
+	ocsp   map[string]*ocsp.Response
+	client *http.Client
+}
+
+func (c *Config) applyClientVerification(config *tls.Config) {
+	v := &clientVerifier{
+		config: c.VerifyClientCertificate,
+		ocsp:   make(map[string]*ocsp.Response),
+		client: NewHTTPClient(10 * time.Second),
+	}
+	if len(v.config.CertificateAuthority) > 0 {
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(v.config.CertificateAuthority) {
+			errors.LogError(context.Background(), "no valid client CA certificate")
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		config.ClientAuth = tls.RequireAnyClientCert
+	}
+	for _, crl := range v.config.Crl {
+		lists, err := ParseCRLs(crl)
+		if err != nil {
+			errors.LogErrorInner(context.Background(), err, "ignoring invalid CRL")
+		}
+		v.crls = append(v.crls, lists...)
+	}
+	config.VerifyConnection = v.verifyConnection
+}
+
+func (v *clientVerifier) verifyConnection(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("no client certificate")
+	}
+	leaf := state.PeerCertificates[0]
+	if len(v.config.CertificateSha256) > 0 {
+		hash := sha256.Sum256(leaf.Raw)
+		if !slices.ContainsFunc(v.config.CertificateSha256, func(h []byte) bool { return hmac.Equal(h, hash[:]) }) {
+			return errors.New("client certificate is unrecognized: ", base64.StdEncoding.EncodeToString(hash[:]))
+		}
+	}
+	if len(v.config.Subjects) > 0 && !slices.ContainsFunc(CertificateNames(leaf), func(name string) bool { return slices.Contains(v.config.Subjects, name) }) {
+		return errors.New("client certificate is not allowed: ", leaf.Subject)
+	}
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) < 2 {
+		return nil
+	}
+	issuer := state.VerifiedChains[0][1]
+	for _, crl := range v.crls {
+		if !bytes.Equal(crl.RawIssuer, leaf.RawIssuer) || crl.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+		for _, revoked := range crl.RevokedCertificateEntries {
+			if revoked.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+				return errors.New("client certificate is revoked: ", leaf.Subject)
+			}
+		}
+	}
+	if v.config.Ocsp && len(leaf.OCSPServer) > 0 {
+		response, err := v.getOCSP(leaf, issuer)
+		if err != nil {
+			// Responders being unreachable does not lock every client out.
+			errors.LogWarningInner(context.Background(), err, "failed to check OCSP for client certificate ", leaf.Subject)
+		} else if response.Status == ocsp.Revoked {
+			return errors.New("client certificate is revoked: ", leaf.Subject)
+		}
+	}
+	return nil
+}
+
+// getOCSP returns the OCSP response for leaf, cached until it is updated.
+func (v *clientVerifier) getOCSP(leaf, issuer *x509.Certificate) (*ocsp.Response, error) {
+	key := string(issuer.RawSubjectPublicKeyInfo) + leaf.SerialNumber.String()
+	v.access.Lock()
+	response := v.ocsp[key]
+	v.access.Unlock()
+	if response != nil && time.Now().Before(response.NextUpdate) {
+		return response, nil
+	}
+
+	request, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := v.client.Post(leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	defer reply.Body.Close()
+	raw, err := io.ReadAll(reply.Body)
+	if err != nil {
+		return nil, err
+	}
+	response, err = ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if response.NextUpdate.IsZero() {
+		response.NextUpdate = time.Now().Add(time.Hour)
+	}
+	v.access.Lock()
+	v.ocsp[key] = response
+	v.access.Unlock()
+	return response, nil
+}
+
+// ParseCRLs parses the certificate revocation lists in PEM blocks, or a single
+// one in DER.
+func ParseCRLs(data []byte) ([]*x509.RevocationList, error) {
+	var crls []*x509.RevocationList
+	for rest := data; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if block.Type != "X509 CRL" {
+			continue
+		}
+		crl, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return crls, err
+		}
+		crls = append(crls, crl)
+	}
+	if len(crls) > 0 {
+		return crls, nil
+	}
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return nil, err
+	}
+	return append(crls, crl), nil
+}
+
+// CertificateNames returns the common name and the subject alternative names
+// of a certificate.
+func CertificateNames(cert *x509.Certificate) []string {
+	var names []string
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
+
+// ClientIdentity returns the first of the CertificateNames of the certificate a
+// client presented to a TLS server connection, or "" if there is none.
+func ClientIdentity(conn net.Conn) string {
+	if counter, ok := conn.(*stat.CounterConnection); ok {
+		conn = counter.Connection
+	}
+	tlsConn, ok := conn.(*Conn)
+	if !ok {
+		return ""
+	}
+	state := tlsConn.ConnectionState()
+	if !state.HandshakeComplete || len(state.PeerCertificates) == 0 {
+		return ""
+	}
+	if names := CertificateNames(state.PeerCertificates[0]); len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
@@ -5,18 +5,23 @@ import (
 	"context"
 	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/binary"
 	"os"
+	"runtime"
 	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"weak"
 
 	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/events"
 	"github.com/xtls/xray-core/common/net"
-	"github.com/xtls/xray-core/common/ocsp"
 	"github.com/xtls/xray-core/common/platform/filesystem"
 	"github.com/xtls/xray-core/common/protocol/tls/cert"
 	"github.com/xtls/xray-core/transport/internet"
@@ -36,6 +41,41 @@ func ParseCertificate(c *cert.Certificate) *Certificate {
 	return nil
 }
 
+// keyPairs holds the parsed key pairs in use, by the hash of their PEMs, so
+// that configs of the same certificate, as of several instances in a process,
+// share one copy. The key pairs are never modified once parsed.
+var keyPairs sync.Map // map[[sha256.Size]byte]weak.Pointer[tls.Certificate]
+
+func loadX509KeyPair(certPEM, keyPEM []byte) (*tls.Certificate, error) {
+	h := sha256.New()
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(certPEM))))
+	h.Write(certPEM)
+	h.Write(keyPEM)
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+
+	if v, ok := keyPairs.Load(key); ok {
+		if keyPair := v.(weak.Pointer[tls.Certificate]).Value(); keyPair != nil {
+			return keyPair, nil
+		}
+	}
+
+	keyPair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	keyPair.Leaf, err = x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, errors.New("invalid certificate").Base(err)
+	}
+	ptr := weak.Make(&keyPair)
+	keyPairs.Store(key, ptr)
+	runtime.AddCleanup(&keyPair, func(key [sha256.Size]byte) {
+		keyPairs.CompareAndDelete(key, ptr)
+	}, key)
+	return &keyPair, nil
+}
+
 func (c *Config) loadSelfCertPool() (*x509.CertPool, error) {
 	root := x509.NewCertPool()
 	for _, cert := range c.Certificate {
@@ -48,85 +88,91 @@ func (c *Config) loadSelfCertPool() (*x509.CertPool, error) {
 
 // BuildCertificates builds a list of TLS certificates from proto definition.
 func (c *Config) BuildCertificates() []*tls.Certificate {
-	certs := make([]*tls.Certificate, 0, len(c.Certificate))
+	var certs []*tls.Certificate
+	for _, cert := range c.buildCertificates() {
+		certs = append(certs, cert.Load())
+	}
+	return certs
+}
+
+// buildCertificates is BuildCertificates with each certificate swapped
+// atomically as it is reloaded or renewed.
+func (c *Config) buildCertificates() []*atomic.Pointer[tls.Certificate] {
+	certs := make([]*atomic.Pointer[tls.Certificate], 0, len(c.Certificate))
 	for _, entry := range c.Certificate {
 		if entry.Usage != Certificate_ENCIPHERMENT {
 			continue
 		}
+		if entry.Acme != nil {
+			certs = append(certs, getACMEManager(entry.Acme).certificate(entry))
+			continue
+		}
+		current := new(atomic.Pointer[tls.Certificate])
+		var wake chan struct{}
+		if entry.OcspStapling != 0 {
+			wake = make(chan struct{}, 1)
+		}
+		replace := func(cert *tls.Certificate) {
+			current.Store(cert)
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
 		getX509KeyPair := func() *tls.Certificate {
-			keyPair, err := tls.X509KeyPair(entry.Certificate, entry.Key)
+			keyPair, err := loadX509KeyPair(entry.Certificate, entry.Key)
 			if err != nil {
 				errors.LogWarningInner(context.Background(), err, "ignoring invalid X509 key pair")
 				return nil
 			}
-			keyPair.Leaf, err = x509.ParseCertificate(keyPair.Certificate[0])
-			if err != nil {
-				errors.LogWarningInner(context.Background(), err, "ignoring invalid certificate")
-				return nil
-			}
-			return &keyPair
+			return keyPair
 		}
-		if keyPair := getX509KeyPair(); keyPair != nil {
-			certs = append(certs, keyPair)
-		} else {
+		keyPair := getX509KeyPair()
+		if keyPair == nil {
 			continue
 		}
-		index := len(certs) - 1
-		setupOcspTicker(entry, func(isReloaded, isOcspstapling bool) {
-			cert := certs[index]
-			if isReloaded {
-				if newKeyPair := getX509KeyPair(); newKeyPair != nil {
-					cert = newKeyPair
-				} else {
-					return
-				}
+		current.Store(keyPair)
+		setupReloadTicker(entry, func() {
+			if cert := getX509KeyPair(); cert != nil {
+				errors.LogInfo(context.Background(), "certificate ", entry.CertificatePath, " (expire on ", cert.Leaf.NotAfter.Format(time.RFC3339), ") reloaded")
+				events.Emit(events.CertificateRenewed, "domains", strings.Join(cert.Leaf.DNSNames, ","), "notAfter", cert.Leaf.NotAfter.Format(time.RFC3339), "source", "file", "path", entry.CertificatePath)
+				replace(cert)
 			}
-			if isOcspstapling {
-				if newOCSPData, err := ocsp.GetOCSPForCert(cert.Certificate); err != nil {
-					errors.LogWarningInner(context.Background(), err, "ignoring invalid OCSP")
-				} else if string(newOCSPData) != string(cert.OCSPStaple) {
-					cert.OCSPStaple = newOCSPData
-				}
-			}
-			certs[index] = cert
 		})
+		certs = append(certs, current)
+		if wake != nil {
+			go stapleOCSP(entry, current, wake)
+		}
 	}
 	return certs
 }
 
-func setupOcspTicker(entry *Certificate, callback func(isReloaded, isOcspstapling bool)) {
+// certificateReloadInterval is how often certificate files are checked for
+// changes, such as a renewal by certbot.
+const certificateReloadInterval = time.Minute
+
+// setupReloadTicker calls reloaded once the files of entry change, with the
+// new certificate and key in entry.
+func setupReloadTicker(entry *Certificate, reloaded func()) {
+	if entry.OneTimeLoading || entry.CertificatePath == "" || entry.KeyPath == "" {
+		return
+	}
 	go func() {
-		if entry.OneTimeLoading {
-			return
-		}
-		var isOcspstapling bool
-		hotReloadCertInterval := uint64(3600)
-		if entry.OcspStapling != 0 {
-			hotReloadCertInterval = entry.OcspStapling
-			isOcspstapling = true
-		}
-		t := time.NewTicker(time.Duration(hotReloadCertInterval) * time.Second)
-		for {
-			var isReloaded bool
-			if entry.CertificatePath != "" && entry.KeyPath != "" {
-				newCert, err := filesystem.ReadCert(entry.CertificatePath)
-				if err != nil {
-					errors.LogErrorInner(context.Background(), err, "failed to parse certificate")
-					return
-				}
-				newKey, err := filesystem.ReadCert(entry.KeyPath)
-				if err != nil {
-					errors.LogErrorInner(context.Background(), err, "failed to parse key")
-					return
-				}
-				if string(newCert) != string(entry.Certificate) || string(newKey) != string(entry.Key) {
-					entry.Certificate = newCert
-					entry.Key = newKey
-					isReloaded = true
-				}
+		t := time.NewTicker(certificateReloadInterval)
+		for range t.C {
+			// Files that cannot be read, e.g. while being rewritten, are retried on
+			// the next check, keeping the current certificate in use.
+			newCert, certErr := filesystem.ReadCert(entry.CertificatePath)
+			newKey, keyErr := filesystem.ReadCert(entry.KeyPath)
+			if certErr != nil {
+				errors.LogWarningInner(context.Background(), certErr, "failed to read certificate ", entry.CertificatePath)
+			} else if keyErr != nil {
+				errors.LogWarningInner(context.Background(), keyErr, "failed to read key ", entry.KeyPath)
+			} else if string(newCert) != string(entry.Certificate) || string(newKey) != string(entry.Key) {
+				entry.Certificate = newCert
+				entry.Key = newKey
+				reloaded()
 			}
-			callback(isReloaded, isOcspstapling)
-			<-t.C
 		}
 	}()
 }
@@ -164,7 +210,7 @@ func (c *Config) getCustomCA() []*Certificate {
 	for _, certificate := range c.Certificate {
 		if certificate.Usage == Certificate_AUTHORITY_ISSUE {
 			certs = append(certs, certificate)
-			setupOcspTicker(certificate, func(isReloaded, isOcspstapling bool) {})
+			setupReloadTicker(certificate, func() {})
 		}
 	}
 	return certs
@@ -244,20 +290,21 @@ func getGetCertificateFunc(c *tls.Config, ca []*Certificate) func(hello *tls.Cli
 	}
 }
 
-func getNewGetCertificateFunc(certs []*tls.Certificate, rejectUnknownSNI bool) func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+func getNewGetCertificateFunc(certs []*atomic.Pointer[tls.Certificate], rejectUnknownSNI bool) func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
 		if len(certs) == 0 {
 			return nil, errNoCertificates
 		}
 		sni := strings.ToLower(hello.ServerName)
 		if !rejectUnknownSNI && (len(certs) == 1 || sni == "") {
-			return certs[0], nil
+			return certs[0].Load(), nil
 		}
 		gsni := "*"
 		if index := strings.IndexByte(sni, '.'); index != -1 {
 			gsni += sni[index:]
 		}
-		for _, keyPair := range certs {
+		for _, cert := range certs {
+			keyPair := cert.Load()
 			if keyPair.Leaf.Subject.CommonName == sni || keyPair.Leaf.Subject.CommonName == gsni {
 				return keyPair, nil
 			}
@@ -270,7 +317,7 @@ func getNewGetCertificateFunc(certs []*tls.Certificate, rejectUnknownSNI bool) f
 		if rejectUnknownSNI {
 			return nil, errNoCertificates
 		}
-		return certs[0], nil
+		return certs[0].Load(), nil
 	}
 }
 
@@ -369,7 +416,7 @@ func (c *Config) GetTLSConfig(opts ...Option) *tls.Config {
 	}
 	config := &tls.Config{
 		Rand:                   randCarrier,
-		ClientSessionCache:     globalSessionCache,
+		ClientSessionCache:     c.getSessionCache(),
 		RootCAs:                root,
 		InsecureSkipVerify:     c.AllowInsecure,
 		NextProtos:             slices.Clone(c.NextProtocol),
@@ -386,58 +433,38 @@ func (c *Config) GetTLSConfig(opts ...Option) *tls.Config {
 		opt(config)
 	}
 
+	if c.EnableSessionResumption && (len(c.SessionTicketKeys) > 0 || c.SessionTicketKeyRotation > 0) {
+		c.applySessionTicketKeys(config)
+	}
+
+	if c.VerifyClientCertificate != nil {
+		c.applyClientVerification(config)
+	}
+
 	caCerts := c.getCustomCA()
 	if len(caCerts) > 0 {
 		config.GetCertificate = getGetCertificateFunc(config, caCerts)
 	} else {
-		config.GetCertificate = getNewGetCertificateFunc(c.BuildCertificates(), c.RejectUnknownSni)
+		certs := c.buildCertificates()
+		config.GetCertificate = getNewGetCertificateFunc(certs, c.RejectUnknownSni)
+		if len(certs) > 0 {
+			// For clients of servers that verify client certificates.
+			config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return certs[0].Load(), nil
+			}
+		}
 	}
 
 	if sn := c.parseServerName(); len(sn) > 0 {
 		config.ServerName = sn
 	}
 
-	if len(c.CurvePreferences) > 0 {
-		config.CurvePreferences = ParseCurveName(c.CurvePreferences)
-	}
-
 	if len(config.NextProtos) == 0 {
 		config.NextProtos = []string{"h2", "http/1.1"}
 	}
 
-	switch c.MinVersion {
-	case "1.0":
-		config.MinVersion = tls.VersionTLS10
-	case "1.1":
-		config.MinVersion = tls.VersionTLS11
-	case "1.2":
-		config.MinVersion = tls.VersionTLS12
-	case "1.3":
-		config.MinVersion = tls.VersionTLS13
-	}
-
-	switch c.MaxVersion {
-	case "1.0":
-		config.MaxVersion = tls.VersionTLS10
-	case "1.1":
-		config.MaxVersion = tls.VersionTLS11
-	case "1.2":
-		config.MaxVersion = tls.VersionTLS12
-	case "1.3":
-		config.MaxVersion = tls.VersionTLS13
-	}
-
-	if len(c.CipherSuites) > 0 {
-		id := make(map[string]uint16)
-		for _, s := range tls.CipherSuites() {
-			id[s.Name] = s.ID
-		}
-		for _, n := range strings.Split(c.CipherSuites, ":") {
-			if id[n] != 0 {
-				config.CipherSuites = append(config.CipherSuites, id[n])
-			}
-		}
-	}
+	c.applyPolicy(config)
+	c.applyConfigForClient(config)
 
 	if len(c.MasterKeyLog) > 0 && c.MasterKeyLog != "none" {
 		writer, err := os.OpenFile(c.MasterKeyLog, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
@@ -447,7 +474,7 @@ func (c *Config) GetTLSConfig(opts ...Option) *tls.Config {
 			config.KeyLogWriter = writer
 		}
 	}
-	if len(c.EchConfigList) > 0 || len(c.EchServerKeys) > 0 {
+	if len(c.EchConfigList) > 0 || len(c.EchServerKeys) > 0 || c.EchServerKeysPath != "" {
 		err := ApplyECH(c, config)
 		if err != nil {
 			if c.EchForceQuery == "full" {
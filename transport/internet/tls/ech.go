@@ -8,6 +8,7 @@ import (
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,7 +18,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	utls "github.com/refraction-networking/utls"
 	"github.com/xtls/xray-core/common/crypto"
 	dns2 "github.com/xtls/xray-core/features/dns"
 	"golang.org/x/net/http2"
@@ -27,6 +27,7 @@ import (
 	"github.com/xtls/reality/hpke"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/platform/filesystem"
 	"github.com/xtls/xray-core/common/utils"
 	"github.com/xtls/xray-core/transport/internet"
 	"golang.org/x/crypto/cryptobyte"
@@ -50,6 +51,17 @@ func ApplyECH(c *Config, config *tls.Config) error {
 		}
 		config.EncryptedClientHelloKeys = KeySets
 	}
+	if c.EchServerKeysPath != "" {
+		keys, err := ReadECHServerKeys(c.EchServerKeysPath)
+		if err != nil {
+			return errors.New("Failed to read ECH server keys from ", c.EchServerKeysPath).Base(err)
+		}
+		serveECHServerKeysFile(config, &echServerKeysFile{
+			path:   c.EchServerKeysPath,
+			keys:   keys,
+			loaded: time.Now(),
+		})
+	}
 
 	// for client
 	if len(c.EchConfigList) != 0 {
@@ -237,10 +249,7 @@ func dnsQuery(server string, domain string, sockopt *internet.SocketConfig) ([]b
 						if err != nil {
 							return nil, err
 						}
-						conn = utls.UClient(conn, &utls.Config{ServerName: u.Hostname()}, utls.HelloChrome_Auto)
-						if err := conn.(*utls.UConn).HandshakeContext(ctx); err != nil {
-							return nil, err
-						}
+						return ClientWithFingerprint(ctx, conn, u.Hostname(), InternalFingerprint(), false)
 					}
 					return conn, nil
 				},
@@ -391,6 +400,59 @@ func ConvertToGoECHKeys(data []byte) ([]tls.EncryptedClientHelloKey, error) {
 	return keys, nil
 }
 
+// ReadECHServerKeys reads ECH server keys from a file, either in base64 or as
+// the "ECH KEYS" PEM block printed by `xray tls ech --pem`.
+func ReadECHServerKeys(path string) ([]tls.EncryptedClientHelloKey, error) {
+	content, err := filesystem.ReadCert(path)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	for rest := content; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if block.Type == "ECH KEYS" {
+			data = block.Bytes
+			break
+		}
+	}
+	if data == nil {
+		if data, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(content))); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := ConvertToGoECHKeys(data)
+	if err == nil && len(keys) == 0 {
+		err = ErrInvalidLen
+	}
+	return keys, err
+}
+
+// echServerKeysFile serves the ECH keys of a file, picking up rotated keys as
+// the file changes.
+type echServerKeysFile struct {
+	path   string
+	access sync.Mutex
+	keys   []tls.EncryptedClientHelloKey
+	loaded time.Time
+}
+
+func (f *echServerKeysFile) get(*tls.ClientHelloInfo) ([]tls.EncryptedClientHelloKey, error) {
+	f.access.Lock()
+	defer f.access.Unlock()
+	if time.Since(f.loaded) >= time.Minute {
+		f.loaded = time.Now()
+		if keys, err := ReadECHServerKeys(f.path); err != nil {
+			errors.LogWarningInner(context.Background(), err, "failed to reload ECH server keys from ", f.path, ", keeping the previous ones")
+		} else {
+			f.keys = keys
+		}
+	}
+	return f.keys, nil
+}
+
 const ExtensionEncryptedClientHello = 0xfe0d
 const KDF_HKDF_SHA384 = 0x0002
 const KDF_HKDF_SHA512 = 0x0003
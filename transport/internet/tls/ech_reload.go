@@ -0,0 +1,9 @@
+//go:build go1.25
+
+package tls
+
+import "crypto/tls"
+
+func serveECHServerKeysFile(config *tls.Config, file *echServerKeysFile) {
+	config.GetEncryptedClientHelloKeys = file.get
+}
@@ -0,0 +1,17 @@
+//go:build !go1.25
+
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+
+	"github.com/xtls/xray-core/common/errors"
+)
+
+// serveECHServerKeysFile loads the keys only once, as tls.Config can not fetch
+// them per handshake before Go 1.25.
+func serveECHServerKeysFile(config *tls.Config, file *echServerKeysFile) {
+	errors.LogWarning(context.Background(), "ECH server keys of ", file.path, " are not reloaded by builds before Go 1.25")
+	config.EncryptedClientHelloKeys = file.keys
+}
@@ -0,0 +1,80 @@
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/platform"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+// InternalFingerprint returns the name of the fingerprint of the HTTPS clients
+// of Xray itself, from the xray.tls.fingerprint environment variable.
+func InternalFingerprint() string {
+	return strings.ToLower(platform.NewEnvFlag(platform.TLSFingerprint).GetValue(func() string { return "" }))
+}
+
+// ClientWithFingerprint does the TLS handshake of a client of serverName on
+// conn, with the ClientHello of the named fingerprint, or that of crypto/tls if
+// the fingerprint is "unsafe". Only http/1.1 is offered if http11 is set, for
+// clients which can not speak h2.
+func ClientWithFingerprint(ctx context.Context, conn net.Conn, serverName string, fingerprint string, http11 bool) (net.Conn, error) {
+	config := &tls.Config{
+		ServerName: serverName,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	if http11 {
+		config.NextProtos = []string{"http/1.1"}
+	}
+	if hello := GetFingerprint(fingerprint); hello != nil {
+		uConn := UClient(conn, config, hello).(*UConn)
+		var err error
+		if http11 {
+			err = uConn.WebsocketHandshakeContext(ctx)
+		} else {
+			err = uConn.HandshakeContext(ctx)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return uConn, nil
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// NewHTTPClient returns an HTTP/1.1 client for the requests of Xray itself,
+// dialing with internet.DialSystem and the InternalFingerprint.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dest, err := net.ParseDestination(network + ":" + addr)
+		if err != nil {
+			return nil, err
+		}
+		return internet.DialSystem(ctx, dest, nil)
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: dial,
+			DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				conn, err := dial(ctx, network, addr)
+				if err != nil {
+					return nil, err
+				}
+				host, _, _ := net.SplitHostPort(addr)
+				return ClientWithFingerprint(ctx, conn, host, InternalFingerprint(), true)
+			},
+			IdleConnTimeout: net.ConnIdleTimeout,
+		},
+	}
+}
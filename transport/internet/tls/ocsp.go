@@ -0,0 +1,410 @@
+package tls
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// ocspRetryMin and ocspRetryMax bound the backoff of fetching an OCSP
+	// response again once it fails.
+	ocspRetryMin = time.Minute
+	ocspRetryMax = time.Hour
+	// ocspMaxSize is the largest OCSP response, or issuer certificate, read.
+	ocspMaxSize = 1 << 20
+)
+
+var (
+	errNoOCSPServer = errors.New("no OCSP server in certificate")
+	errNoOCSPIssuer = errors.New("no issuer of certificate in chain or its URL")
+
+	ocspClient = sync.OnceValue(func() *http.Client {
+		return NewHTTPClient(30 * time.Second)
+	})
+
+	ocspStatesAccess sync.Mutex
+	ocspStates       = make(map[[sha256.Size]byte]*ocspState)
+)
+
+// OCSPStat is the stapling of the OCSP response of a certificate.
+type OCSPStat struct {
+	Names  []string `json:"names"`
+	Serial string   `json:"serial"`
+	// Status is of the last response, "good", "revoked" or "unknown", and
+	// is empty before there is one.
+	Status     string    `json:"status,omitempty"`
+	Stapled    bool      `json:"stapled"`
+	ThisUpdate time.Time `json:"thisUpdate,omitzero"`
+	NextUpdate time.Time `json:"nextUpdate,omitzero"`
+	// Fetches are the requests to the OCSP responder, and Failures the ones
+	// of them that failed.
+	Fetches   uint64 `json:"fetches"`
+	Failures  uint64 `json:"failures"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// OCSPStats returns the stapling of the certificates with OCSP stapling, by
+// their names.
+func OCSPStats() []OCSPStat {
+	ocspStatesAccess.Lock()
+	states := make([]*ocspState, 0, len(ocspStates))
+	for _, s := range ocspStates {
+		states = append(states, s)
+	}
+	ocspStatesAccess.Unlock()
+
+	stats := make([]OCSPStat, 0, len(states))
+	now := time.Now()
+	for _, s := range states {
+		s.access.Lock()
+		stat := OCSPStat{
+			Names:     s.leaf.DNSNames,
+			Serial:    s.leaf.SerialNumber.Text(16),
+			Stapled:   s.staple(now) != nil,
+			Fetches:   s.fetches,
+			Failures:  s.failures,
+			LastError: s.lastError,
+		}
+		if s.response != nil {
+			stat.Status = ocspStatus(s.response.Status)
+			stat.ThisUpdate = s.response.ThisUpdate
+			stat.NextUpdate = s.response.NextUpdate
+		}
+		s.access.Unlock()
+		stats = append(stats, stat)
+	}
+	slices.SortFunc(stats, func(a, b OCSPStat) int {
+		return strings.Compare(strings.Join(a.Names, ","), strings.Join(b.Names, ","))
+	})
+	return stats
+}
+
+func ocspStatus(status int) string {
+	switch status {
+	case ocsp.Good:
+		return "good"
+	case ocsp.Revoked:
+		return "revoked"
+	}
+	return "unknown"
+}
+
+// stapleOCSP keeps the OCSP response of the certificate in current stapled to
+// it, for as long as the process runs. wake tells that current is replaced.
+func stapleOCSP(entry *Certificate, current *atomic.Pointer[tls.Certificate], wake <-chan struct{}) {
+	interval := time.Duration(entry.OcspStapling) * time.Second
+	var state *ocspState
+	for {
+		cert := current.Load()
+		if key := sha256.Sum256(cert.Certificate[0]); state == nil || state.key != key {
+			if state != nil {
+				state.release()
+			}
+			state = acquireOCSPState(key, cert)
+		}
+		staple, next := state.refresh(cert.Certificate, interval, entry.OcspCache)
+		if !bytes.Equal(staple, cert.OCSPStaple) {
+			stapled := *cert
+			stapled.OCSPStaple = staple
+			if !current.CompareAndSwap(cert, &stapled) {
+				continue
+			}
+		}
+		// There is nothing to refresh for a zero next, until the certificate
+		// is replaced.
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			expired = timer.C
+		}
+		select {
+		case <-expired:
+		case <-wake:
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+	}
+}
+
+// ocspState is the OCSP response of a leaf certificate, shared by the
+// listeners serving it.
+type ocspState struct {
+	key [sha256.Size]byte
+	// users are guarded by ocspStatesAccess.
+	users int
+
+	// fetching is held while the response is refreshed, for a single request
+	// at a time of all the users.
+	fetching sync.Mutex
+	loaded   bool
+	issuer   *x509.Certificate
+
+	access    sync.Mutex
+	leaf      *x509.Certificate
+	raw       []byte
+	response  *ocsp.Response
+	fetched   time.Time
+	retry     time.Duration
+	retryAt   time.Time
+	permanent bool
+	fetches   uint64
+	failures  uint64
+	lastError string
+}
+
+func acquireOCSPState(key [sha256.Size]byte, cert *tls.Certificate) *ocspState {
+	ocspStatesAccess.Lock()
+	defer ocspStatesAccess.Unlock()
+	s := ocspStates[key]
+	if s == nil {
+		leaf := cert.Leaf
+		if leaf == nil {
+			leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+		}
+		if leaf == nil {
+			leaf = new(x509.Certificate)
+		}
+		s = &ocspState{key: key, leaf: leaf}
+		ocspStates[key] = s
+	}
+	s.users++
+	return s
+}
+
+func (s *ocspState) release() {
+	ocspStatesAccess.Lock()
+	defer ocspStatesAccess.Unlock()
+	s.users--
+	if s.users == 0 {
+		delete(ocspStates, s.key)
+	}
+}
+
+// refresh fetches the response if it is due, and returns the one to staple, or
+// nil if there is none, and when to refresh again.
+func (s *ocspState) refresh(chain [][]byte, interval time.Duration, cachePath string) ([]byte, time.Time) {
+	s.fetching.Lock()
+	defer s.fetching.Unlock()
+	if !s.loaded {
+		s.loaded = true
+		if cachePath != "" {
+			s.loadCache(chain, cachePath)
+		}
+	}
+	if s.due(time.Now(), interval) {
+		raw, response, err := s.fetch(chain)
+		s.update(raw, response, err, cachePath)
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	now := time.Now()
+	return s.staple(now), s.next(now, interval)
+}
+
+// refreshAt returns when the response is refreshed, halfway through its
+// validity or after interval if sooner. s.access must be held.
+func (s *ocspState) refreshAt(interval time.Duration) time.Time {
+	at := s.fetched.Add(interval)
+	if r := s.response; r != nil && !r.NextUpdate.IsZero() {
+		if halfway := r.ThisUpdate.Add(r.NextUpdate.Sub(r.ThisUpdate) / 2); halfway.Before(at) {
+			at = halfway
+		}
+	}
+	return at
+}
+
+func (s *ocspState) due(now time.Time, interval time.Duration) bool {
+	s.access.Lock()
+	defer s.access.Unlock()
+	switch {
+	case s.permanent:
+		return false
+	case !s.retryAt.IsZero():
+		return !now.Before(s.retryAt)
+	case s.response == nil:
+		return true
+	}
+	return !now.Before(s.refreshAt(interval))
+}
+
+// next returns when to refresh the response, or when the one stapled expires
+// if sooner. s.access must be held.
+func (s *ocspState) next(now time.Time, interval time.Duration) time.Time {
+	if s.permanent {
+		return time.Time{}
+	}
+	next := s.retryAt
+	if next.IsZero() {
+		next = s.refreshAt(interval)
+	}
+	if s.staple(now) != nil {
+		if expiry := s.response.NextUpdate; !expiry.IsZero() && expiry.Before(next) {
+			next = expiry
+		}
+	}
+	return next
+}
+
+// staple returns the response to staple, if it is good and has not expired.
+// s.access must be held.
+func (s *ocspState) staple(now time.Time) []byte {
+	if s.response == nil || s.response.Status != ocsp.Good {
+		return nil
+	}
+	if !s.response.NextUpdate.IsZero() && !now.Before(s.response.NextUpdate) {
+		return nil
+	}
+	return s.raw
+}
+
+// fetch requests the response of the leaf from its OCSP responder.
+func (s *ocspState) fetch(chain [][]byte) ([]byte, *ocsp.Response, error) {
+	if len(s.leaf.OCSPServer) == 0 {
+		return nil, nil, errNoOCSPServer
+	}
+	issuer, err := s.getIssuer(chain)
+	if err != nil {
+		return nil, nil, err
+	}
+	request, err := ocsp.CreateRequest(s.leaf, issuer, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	reply, err := ocspClient().Post(s.leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(request))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reply.Body.Close()
+	if reply.StatusCode != http.StatusOK {
+		return nil, nil, errors.New("OCSP responder ", s.leaf.OCSPServer[0], " replied ", reply.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(reply.Body, ocspMaxSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	response, err := ocsp.ParseResponseForCert(raw, s.leaf, issuer)
+	if err != nil {
+		return nil, nil, errors.New("invalid OCSP response").Base(err)
+	}
+	return raw, response, nil
+}
+
+// getIssuer returns the issuer of the leaf, the next certificate of chain or
+// else the one of its URL. s.fetching must be held.
+func (s *ocspState) getIssuer(chain [][]byte) (*x509.Certificate, error) {
+	if s.issuer != nil {
+		return s.issuer, nil
+	}
+	var der []byte
+	if len(chain) > 1 {
+		der = chain[1]
+	} else if len(s.leaf.IssuingCertificateURL) > 0 {
+		reply, err := ocspClient().Get(s.leaf.IssuingCertificateURL[0])
+		if err != nil {
+			return nil, errors.New("failed to get issuer of certificate").Base(err)
+		}
+		defer reply.Body.Close()
+		if der, err = io.ReadAll(io.LimitReader(reply.Body, ocspMaxSize)); err != nil {
+			return nil, errors.New("failed to get issuer of certificate").Base(err)
+		}
+		if block, _ := pem.Decode(der); block != nil {
+			der = block.Bytes
+		}
+	} else {
+		return nil, errNoOCSPIssuer
+	}
+	issuer, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errors.New("invalid issuer of certificate").Base(err)
+	}
+	s.issuer = issuer
+	return issuer, nil
+}
+
+// update records the result of a fetch.
+func (s *ocspState) update(raw []byte, response *ocsp.Response, err error, cachePath string) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	ctx := context.Background()
+	names := strings.Join(s.leaf.DNSNames, ",")
+	if err == errNoOCSPServer || err == errNoOCSPIssuer {
+		s.permanent = true
+		s.lastError = err.Error()
+		errors.LogWarningInner(ctx, err, "not stapling OCSP for certificate of ", names)
+		return
+	}
+	s.fetches++
+	if err != nil {
+		s.failures++
+		s.lastError = err.Error()
+		s.retry = min(max(s.retry*2, ocspRetryMin), ocspRetryMax)
+		s.retryAt = time.Now().Add(s.retry)
+		errors.LogWarningInner(ctx, err, "failed to fetch OCSP response for certificate of ", names, ", retrying in ", s.retry)
+		return
+	}
+	s.raw, s.response, s.fetched = raw, response, time.Now()
+	s.retry, s.retryAt, s.lastError = 0, time.Time{}, ""
+	switch response.Status {
+	case ocsp.Good:
+		errors.LogInfo(ctx, "OCSP response for certificate of ", names, " (next update on ", response.NextUpdate.Format(time.RFC3339), ") stapled")
+	case ocsp.Revoked:
+		errors.LogError(ctx, "certificate of ", names, " is revoked on ", response.RevokedAt.Format(time.RFC3339), ", not stapling OCSP")
+	default:
+		errors.LogWarning(ctx, "certificate of ", names, " is unknown to its OCSP responder, not stapling OCSP")
+	}
+	if cachePath != "" {
+		if err := writeOCSPCache(cachePath, raw); err != nil {
+			errors.LogWarningInner(ctx, err, "failed to cache OCSP response in ", cachePath)
+		}
+	}
+}
+
+// loadCache loads the response of the leaf cached in path, if it has not
+// expired. s.fetching must be held.
+func (s *ocspState) loadCache(chain [][]byte, path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		errors.LogWarningInner(context.Background(), err, "failed to read cached OCSP response in ", path)
+		return
+	}
+	// The signature is not checked without the issuer, as the file is only
+	// written once it is.
+	issuer, _ := s.getIssuer(chain)
+	response, err := ocsp.ParseResponseForCert(raw, s.leaf, issuer)
+	if err != nil || !response.NextUpdate.IsZero() && !time.Now().Before(response.NextUpdate) {
+		return
+	}
+	s.access.Lock()
+	s.raw, s.response, s.fetched = raw, response, info.ModTime()
+	s.access.Unlock()
+	errors.LogInfo(context.Background(), "OCSP response for certificate of ", strings.Join(s.leaf.DNSNames, ","), " loaded from ", path)
+}
+
+func writeOCSPCache(path string, raw []byte) error {
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
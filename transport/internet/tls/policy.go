@@ -0,0 +1,140 @@
+package tls
+
+import (
+	"crypto/cipher"
+	"crypto/tls"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// policies holds the policies set to configs at runtime.
+var policies sync.Map // *Config -> *Config
+
+// SetPolicy replaces the policy of the servers of c, its ALPN with its order
+// and mismatch, versions, cipher suites and curve preferences, with the one
+// of p, for the handshakes from then on. The ALPN of the servers is kept if p
+// has none.
+func (c *Config) SetPolicy(p *Config) {
+	policies.Store(c, &Config{
+		NextProtocol:      p.NextProtocol,
+		AlpnOrder:         p.AlpnOrder,
+		AllowAlpnMismatch: p.AllowAlpnMismatch,
+		MinVersion:        p.MinVersion,
+		MaxVersion:        p.MaxVersion,
+		CipherSuites:      p.CipherSuites,
+		CurvePreferences:  p.CurvePreferences,
+	})
+}
+
+// policy returns the policy set to c, or nil if there is none.
+func (c *Config) policy() *Config {
+	if p, ok := policies.Load(c); ok {
+		return p.(*Config)
+	}
+	return nil
+}
+
+// applyPolicy sets the versions, cipher suites and curve preferences of c to
+// config.
+func (c *Config) applyPolicy(config *tls.Config) {
+	if len(c.CurvePreferences) > 0 {
+		config.CurvePreferences = ParseCurveName(c.CurvePreferences)
+	}
+
+	switch c.MinVersion {
+	case "1.0":
+		config.MinVersion = tls.VersionTLS10
+	case "1.1":
+		config.MinVersion = tls.VersionTLS11
+	case "1.2":
+		config.MinVersion = tls.VersionTLS12
+	case "1.3":
+		config.MinVersion = tls.VersionTLS13
+	}
+
+	switch c.MaxVersion {
+	case "1.0":
+		config.MaxVersion = tls.VersionTLS10
+	case "1.1":
+		config.MaxVersion = tls.VersionTLS11
+	case "1.2":
+		config.MaxVersion = tls.VersionTLS12
+	case "1.3":
+		config.MaxVersion = tls.VersionTLS13
+	}
+
+	if len(c.CipherSuites) > 0 {
+		id := make(map[string]uint16)
+		for _, s := range tls.CipherSuites() {
+			id[s.Name] = s.ID
+		}
+		for _, n := range strings.Split(c.CipherSuites, ":") {
+			if id[n] != 0 {
+				config.CipherSuites = append(config.CipherSuites, id[n])
+			}
+		}
+	}
+}
+
+// applyConfigForClient has the servers of config negotiate by the policy of
+// c, the one set at runtime if any, on each handshake.
+func (c *Config) applyConfigForClient(config *tls.Config) {
+	// The configs returned would each make their own keys of tickets, unless
+	// they are set, so they share ones rotated daily instead.
+	var keys *sessionTicketKeys
+	if !config.SessionTicketsDisabled && config.WrapSession == nil && len(c.SessionTicketKeys) == 0 {
+		keys = &sessionTicketKeys{
+			interval: 24 * time.Hour,
+			keys:     make(map[int64]cipher.AEAD),
+		}
+	}
+	config.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		p := c.policy()
+		if p == nil && c.AlpnOrder != "client" && !c.AllowAlpnMismatch {
+			return nil, nil
+		}
+		clone := config.Clone()
+		clone.GetConfigForClient = nil
+		if keys != nil {
+			clone.WrapSession, clone.UnwrapSession = keys.wrap, keys.unwrap
+		}
+		if p != nil {
+			clone.CurvePreferences = nil
+			clone.MinVersion, clone.MaxVersion = 0, 0
+			clone.CipherSuites = nil
+			p.applyPolicy(clone)
+			if len(p.NextProtocol) > 0 {
+				clone.NextProtos = slices.Clone(p.NextProtocol)
+			}
+		} else {
+			p = c
+		}
+		clone.NextProtos = negotiateALPN(clone.NextProtos, hello.SupportedProtos, p.AlpnOrder == "client", p.AllowAlpnMismatch)
+		return clone, nil
+	}
+}
+
+// negotiateALPN returns the protocols for a server of server to choose from
+// the ones of client by. The server chooses the first of client of them if
+// by clientOrder, and goes on without ALPN if none is of client while
+// allowMismatch.
+func negotiateALPN(server []string, client []string, clientOrder bool, allowMismatch bool) []string {
+	if len(client) == 0 {
+		return server
+	}
+	var protos []string
+	for _, proto := range client {
+		if slices.Contains(server, proto) {
+			protos = append(protos, proto)
+		}
+	}
+	switch {
+	case len(protos) == 0 && allowMismatch:
+		return nil
+	case len(protos) == 0 || !clientOrder:
+		return server
+	}
+	return protos
+}
@@ -0,0 +1,142 @@
+package tls
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+var globalUTLSSessionCache = utls.NewLRUClientSessionCache(128)
+
+// sessionCaches holds the client session caches of the configs with their own
+// session_cache_size, and utlsSessionCaches their counterparts for uTLS.
+var (
+	sessionCaches     sync.Map // *Config -> tls.ClientSessionCache
+	utlsSessionCaches sync.Map // tls.ClientSessionCache -> utls.ClientSessionCache
+)
+
+func init() {
+	utlsSessionCaches.Store(globalSessionCache, globalUTLSSessionCache)
+}
+
+func (c *Config) getSessionCache() tls.ClientSessionCache {
+	if c.SessionCacheSize == 0 {
+		return globalSessionCache
+	}
+	if cache, found := sessionCaches.Load(c); found {
+		return cache.(tls.ClientSessionCache)
+	}
+	cache := tls.NewLRUClientSessionCache(int(c.SessionCacheSize))
+	if actual, loaded := sessionCaches.LoadOrStore(c, cache); loaded {
+		return actual.(tls.ClientSessionCache)
+	}
+	utlsSessionCaches.Store(cache, utls.NewLRUClientSessionCache(int(c.SessionCacheSize)))
+	return cache
+}
+
+func getUTLSSessionCache(cache tls.ClientSessionCache) utls.ClientSessionCache {
+	if utlsCache, found := utlsSessionCaches.Load(cache); found {
+		return utlsCache.(utls.ClientSessionCache)
+	}
+	return nil
+}
+
+func (c *Config) applySessionTicketKeys(config *tls.Config) {
+	if c.SessionTicketKeyRotation == 0 {
+		keys := make([][32]byte, len(c.SessionTicketKeys))
+		for i, key := range c.SessionTicketKeys {
+			copy(keys[i][:], key)
+		}
+		config.SetSessionTicketKeys(keys)
+		return
+	}
+	k := &sessionTicketKeys{
+		interval: time.Duration(c.SessionTicketKeyRotation) * time.Second,
+		keys:     make(map[int64]cipher.AEAD),
+	}
+	if len(c.SessionTicketKeys) > 0 {
+		k.secret = c.SessionTicketKeys[0]
+	}
+	config.WrapSession = k.wrap
+	config.UnwrapSession = k.unwrap
+}
+
+// sessionTicketKeys encrypts session tickets with a key for every window of
+// the rotation interval, derived from secret if set.
+type sessionTicketKeys struct {
+	secret   []byte
+	interval time.Duration
+	access   sync.Mutex
+	keys     map[int64]cipher.AEAD
+}
+
+func (k *sessionTicketKeys) get(window int64) cipher.AEAD {
+	now := time.Now().UnixNano() / int64(k.interval)
+	if window != now && window != now-1 {
+		return nil
+	}
+	k.access.Lock()
+	defer k.access.Unlock()
+	if aead, found := k.keys[window]; found {
+		return aead
+	}
+	if k.secret == nil && window != now {
+		return nil
+	}
+	key := make([]byte, 32)
+	if k.secret != nil {
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(binary.BigEndian.AppendUint64(nil, uint64(window)))
+		key = mac.Sum(nil)
+	} else {
+		rand.Read(key)
+	}
+	block, _ := aes.NewCipher(key)
+	aead, _ := cipher.NewGCM(block)
+	for w := range k.keys {
+		if w < now-1 {
+			delete(k.keys, w)
+		}
+	}
+	k.keys[window] = aead
+	return aead
+}
+
+func (k *sessionTicketKeys) wrap(_ tls.ConnectionState, session *tls.SessionState) ([]byte, error) {
+	state, err := session.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	window := time.Now().UnixNano() / int64(k.interval)
+	aead := k.get(window)
+	ticket := binary.BigEndian.AppendUint64(nil, uint64(window))
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	ticket = append(ticket, nonce...)
+	return aead.Seal(ticket, nonce, state, ticket[:8]), nil
+}
+
+func (k *sessionTicketKeys) unwrap(identity []byte, _ tls.ConnectionState) (*tls.SessionState, error) {
+	if len(identity) < 8 {
+		return nil, nil
+	}
+	aead := k.get(int64(binary.BigEndian.Uint64(identity)))
+	if aead == nil || len(identity) < 8+aead.NonceSize() {
+		return nil, nil
+	}
+	nonce := identity[8 : 8+aead.NonceSize()]
+	state, err := aead.Open(nil, nonce, identity[8+aead.NonceSize():], identity[:8])
+	if err != nil {
+		// Tickets that are not ours start a full handshake.
+		return nil, nil
+	}
+	return tls.ParseSessionState(state)
+}
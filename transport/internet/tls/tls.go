@@ -5,10 +5,12 @@ import (
 	"crypto/rand"
 	"crypto/tls"
 	"math/big"
+	"slices"
 	"time"
 
 	utls "github.com/refraction-networking/utls"
 	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 )
 
@@ -122,12 +124,76 @@ func (c *UConn) NegotiatedProtocol() string {
 }
 
 func UClient(c net.Conn, config *tls.Config, fingerprint *utls.ClientHelloID) net.Conn {
-	utlsConn := utls.UClient(c, copyConfig(config), *fingerprint)
+	uConfig := copyConfig(config)
+	if len(config.CurvePreferences) > 0 || uConfig.ClientSessionCache != nil {
+		curves := make([]utls.CurveID, len(config.CurvePreferences))
+		for i, curve := range config.CurvePreferences {
+			curves[i] = utls.CurveID(curve)
+		}
+		utlsConn, err := UClientWithCurves(c, uConfig, *fingerprint, curves)
+		if err == nil {
+			return &UConn{UConn: utlsConn}
+		}
+		errors.LogWarningInner(context.Background(), err, "failed to customize fingerprint ", fingerprint.Client)
+	}
+	utlsConn := utls.UClient(c, uConfig, *fingerprint)
 	return &UConn{UConn: utlsConn}
 }
 
+// UClientWithCurves is utls.UClient offering curves, in order of preference,
+// instead of the groups of the fingerprint, if any. Only the first curve gets a
+// key share, along with X25519 when it is X25519MLKEM768 like browsers do.
+// With a session cache in config, TLS 1.3 sessions are resumed as well, with a
+// pre_shared_key extension that is only sent along with a session.
+func UClientWithCurves(c net.Conn, config *utls.Config, fingerprint utls.ClientHelloID, curves []utls.CurveID) (*utls.UConn, error) {
+	spec, err := utls.UTLSIdToSpec(fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	var hasPSKModes, hasPSK bool
+	for _, extension := range spec.Extensions {
+		switch e := extension.(type) {
+		case *utls.SupportedCurvesExtension:
+			if len(curves) == 0 {
+				break
+			}
+			var supported []utls.CurveID
+			if len(e.Curves) > 0 && e.Curves[0] == utls.GREASE_PLACEHOLDER {
+				supported = append(supported, utls.GREASE_PLACEHOLDER)
+			}
+			e.Curves = append(supported, curves...)
+		case *utls.KeyShareExtension:
+			if len(curves) == 0 {
+				break
+			}
+			var shares []utls.KeyShare
+			if len(e.KeyShares) > 0 && e.KeyShares[0].Group == utls.GREASE_PLACEHOLDER {
+				shares = append(shares, e.KeyShares[0])
+			}
+			shares = append(shares, utls.KeyShare{Group: curves[0]})
+			if curves[0] == utls.X25519MLKEM768 && slices.Contains(curves, utls.X25519) {
+				shares = append(shares, utls.KeyShare{Group: utls.X25519})
+			}
+			e.KeyShares = shares
+		case *utls.PSKKeyExchangeModesExtension:
+			hasPSKModes = true
+		case utls.PreSharedKeyExtension:
+			hasPSK = true
+		}
+	}
+	if config.ClientSessionCache != nil && hasPSKModes && !hasPSK {
+		spec.Extensions = append(spec.Extensions, &utls.UtlsPreSharedKeyExtension{})
+		config.OmitEmptyPsk = true
+	}
+	utlsConn := utls.UClient(c, config, utls.HelloCustom)
+	if err := utlsConn.ApplyPreset(&spec); err != nil {
+		return nil, err
+	}
+	return utlsConn, nil
+}
+
 func copyConfig(c *tls.Config) *utls.Config {
-	return &utls.Config{
+	config := &utls.Config{
 		Rand:                           c.Rand,
 		RootCAs:                        c.RootCAs,
 		ServerName:                     c.ServerName,
@@ -136,6 +202,28 @@ func copyConfig(c *tls.Config) *utls.Config {
 		KeyLogWriter:                   c.KeyLogWriter,
 		EncryptedClientHelloConfigList: c.EncryptedClientHelloConfigList,
 	}
+	if !c.SessionTicketsDisabled {
+		config.ClientSessionCache = getUTLSSessionCache(c.ClientSessionCache)
+	}
+	if c.GetClientCertificate != nil {
+		config.GetClientCertificate = func(info *utls.CertificateRequestInfo) (*utls.Certificate, error) {
+			cert, err := c.GetClientCertificate(&tls.CertificateRequestInfo{
+				AcceptableCAs: info.AcceptableCAs,
+				Version:       info.Version,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return &utls.Certificate{
+				Certificate:                 cert.Certificate,
+				PrivateKey:                  cert.PrivateKey,
+				OCSPStaple:                  cert.OCSPStaple,
+				SignedCertificateTimestamps: cert.SignedCertificateTimestamps,
+				Leaf:                        cert.Leaf,
+			}, nil
+		}
+	}
+	return config
 }
 
 func init() {
@@ -7,6 +7,7 @@ import (
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol/udp"
+	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/transport/internet"
 )
 
@@ -25,10 +26,18 @@ func HubReceiveOriginalDestination(r bool) HubOption {
 }
 
 type Hub struct {
-	conn         *net.UDPConn
+	conn net.PacketConn
+	// udpConn is conn itself, nil when conn is wrapped, for example to
+	// accept the PROXY protocol. Original destinations need it.
+	udpConn *net.UDPConn
+	// batch reads and writes udpConn several datagrams at a time, if not nil.
+	batch        *internet.UDPBatchConn
 	cache        chan *udp.Packet
 	capacity     int
 	recvOrigDest bool
+	// gate drops the packets of the sources it does not allow, here rather
+	// than in a wrapper of conn, which would lose udpConn.
+	gate session.ListenGate
 }
 
 func ListenUDP(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, options ...HubOption) (*Hub, error) {
@@ -56,6 +65,9 @@ func ListenUDP(ctx context.Context, address net.Address, port net.Port, streamSe
 		hub.recvOrigDest = true
 	}
 
+	if hub.gate = session.ListenGateFromContext(ctx); hub.gate != nil {
+		ctx = session.ContextWithListenGate(ctx, nil)
+	}
 	udpConn, err := internet.ListenSystemPacket(ctx, &net.UDPAddr{
 		IP:   address.IP(),
 		Port: int(port),
@@ -64,10 +76,24 @@ func ListenUDP(ctx context.Context, address net.Address, port net.Port, streamSe
 		return nil, err
 	}
 	errors.LogInfo(ctx, "listening UDP on ", address, ":", port)
-	hub.conn = udpConn.(*net.UDPConn)
+	hub.conn = udpConn
+	hub.udpConn, _ = udpConn.(*net.UDPConn)
+	if c, ok := udpConn.(interface{ UDPConn() *net.UDPConn }); ok {
+		hub.udpConn = c.UDPConn()
+	}
+	if hub.udpConn == nil && hub.recvOrigDest {
+		errors.LogWarning(ctx, "original destination is not available when accepting PROXY protocol")
+	}
+	if hub.udpConn != nil {
+		hub.batch = internet.NewUDPBatchConn(hub.udpConn, true)
+	}
 	hub.cache = make(chan *udp.Packet, hub.capacity)
 
-	go hub.start()
+	if hub.batch != nil {
+		go hub.startBatch()
+	} else {
+		go hub.start()
+	}
 	return hub, nil
 }
 
@@ -78,57 +104,117 @@ func (h *Hub) Close() error {
 }
 
 func (h *Hub) WriteTo(payload []byte, dest net.Destination) (int, error) {
-	return h.conn.WriteToUDP(payload, &net.UDPAddr{
+	return h.conn.WriteTo(payload, &net.UDPAddr{
 		IP:   dest.Address.IP(),
 		Port: int(dest.Port),
 	})
 }
 
+// WriteMultiBufferTo writes the packets of mb to dest, and returns how many
+// bytes are written.
+func (h *Hub) WriteMultiBufferTo(mb buf.MultiBuffer, dest net.Destination) (int, error) {
+	defer buf.ReleaseMulti(mb)
+	addr := &net.UDPAddr{
+		IP:   dest.Address.IP(),
+		Port: int(dest.Port),
+	}
+	if h.batch == nil {
+		written := 0
+		for _, b := range mb {
+			n, err := h.conn.WriteTo(b.Bytes(), addr)
+			written += n
+			if err != nil {
+				return written, err
+			}
+		}
+		return written, nil
+	}
+	msgs := make([]internet.UDPMessage, len(mb))
+	for i, b := range mb {
+		msgs[i] = internet.UDPMessage{Buffer: b, Addr: addr}
+	}
+	n, err := h.batch.WriteBatch(msgs)
+	return int(mb[:n].Len()), err
+}
+
+func (h *Hub) startBatch() {
+	defer close(h.cache)
+
+	for {
+		msgs, err := h.batch.ReadBatch()
+		if err != nil {
+			errors.LogInfoInner(context.Background(), err, "failed to read UDP msg")
+			break
+		}
+		for _, msg := range msgs {
+			h.deliver(msg.Buffer, msg.Addr, msg.OOB)
+		}
+	}
+}
+
 func (h *Hub) start() {
-	c := h.cache
-	defer close(c)
+	defer close(h.cache)
 
 	oobBytes := make([]byte, 256)
 
 	for {
 		buffer := buf.New()
-		var noob int
-		var addr *net.UDPAddr
 		rawBytes := buffer.Extend(buf.Size)
 
-		n, noob, _, addr, err := ReadUDPMsg(h.conn, rawBytes, oobBytes)
+		n, noob, addr, err := h.read(rawBytes, oobBytes)
 		if err != nil {
 			errors.LogInfoInner(context.Background(), err, "failed to read UDP msg")
 			buffer.Release()
 			break
 		}
 		buffer.Resize(0, int32(n))
+		h.deliver(buffer, addr, oobBytes[:noob])
+	}
+}
 
-		if buffer.IsEmpty() {
-			buffer.Release()
-			continue
-		}
+// deliver queues a packet read from addr with the control messages oob, and
+// drops it if the queue is full.
+func (h *Hub) deliver(buffer *buf.Buffer, addr *net.UDPAddr, oob []byte) {
+	if buffer.IsEmpty() || h.gate != nil && !h.gate.Allow(addr.IP) {
+		buffer.Release()
+		return
+	}
 
-		payload := &udp.Packet{
-			Payload: buffer,
-			Source:  net.UDPDestination(net.IPAddress(addr.IP), net.Port(addr.Port)),
-		}
-		if h.recvOrigDest && noob > 0 {
-			payload.Target = RetrieveOriginalDest(oobBytes[:noob])
-			if payload.Target.IsValid() {
-				errors.LogDebug(context.Background(), "UDP original destination: ", payload.Target)
-			} else {
-				errors.LogInfo(context.Background(), "failed to read UDP original destination")
-			}
+	payload := &udp.Packet{
+		Payload: buffer,
+		Source:  net.UDPDestination(net.IPAddress(addr.IP), net.Port(addr.Port)),
+	}
+	if h.recvOrigDest && len(oob) > 0 {
+		payload.Target = RetrieveOriginalDest(oob)
+		if payload.Target.IsValid() {
+			errors.LogDebug(context.Background(), "UDP original destination: ", payload.Target)
+		} else {
+			errors.LogInfo(context.Background(), "failed to read UDP original destination")
 		}
+	}
 
-		select {
-		case c <- payload:
-		default:
-			buffer.Release()
-			payload.Payload = nil
-		}
+	select {
+	case h.cache <- payload:
+	default:
+		buffer.Release()
+		payload.Payload = nil
+	}
+}
+
+func (h *Hub) read(payload []byte, oob []byte) (int, int, *net.UDPAddr, error) {
+	if h.udpConn != nil {
+		n, noob, _, addr, err := ReadUDPMsg(h.udpConn, payload, oob)
+		return n, noob, addr, err
+	}
+	n, addr, err := h.conn.ReadFrom(payload)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return 0, 0, nil, errors.New("unexpected source address ", addr)
 	}
+	return n, 0, udpAddr, nil
 }
 
 // Addr implements net.Listener.
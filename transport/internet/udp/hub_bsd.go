@@ -0,0 +1,57 @@
+//go:build freebsd || openbsd
+// +build freebsd openbsd
+
+package udp
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"syscall"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+// RetrieveOriginalDest from the control messages of packets diverted with
+// divert-to, or from stored laddr, caddr of packets redirected with rdr-to
+func RetrieveOriginalDest(oob []byte) net.Destination {
+	if msgs, err := syscall.ParseSocketControlMessage(oob); err == nil {
+		if dest := originalDestFromControl(msgs); dest.IsValid() {
+			return dest
+		}
+	}
+	dec := gob.NewDecoder(bytes.NewBuffer(oob))
+	var la, ra net.UDPAddr
+	dec.Decode(&la)
+	dec.Decode(&ra)
+	ip, port, err := internet.OriginalDst(&la, &ra)
+	if err != nil {
+		return net.Destination{}
+	}
+	return net.UDPDestination(net.IPAddress(ip), net.Port(port))
+}
+
+// ReadUDPMsg reads the control messages of the packet if the socket receives
+// them, or stores laddr, caddr for later use
+func ReadUDPMsg(conn *net.UDPConn, payload []byte, oob []byte) (int, int, int, *net.UDPAddr, error) {
+	nBytes, noob, flags, addr, err := conn.ReadMsgUDP(payload, oob)
+	if err != nil || noob > 0 {
+		return nBytes, noob, flags, addr, err
+	}
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	udpAddr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return 0, 0, 0, nil, errors.New("invalid local address")
+	}
+	if addr == nil {
+		return 0, 0, 0, nil, errors.New("invalid remote address")
+	}
+	enc.Encode(udpAddr)
+	enc.Encode(addr)
+	var reader io.Reader = &buf
+	noob, _ = reader.Read(oob)
+	return nBytes, noob, flags, addr, err
+}
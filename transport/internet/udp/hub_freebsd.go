@@ -1,46 +1,23 @@
-//go:build freebsd
-// +build freebsd
-
 package udp
 
 import (
-	"bytes"
-	"encoding/gob"
-	"io"
+	"syscall"
 
-	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
-	"github.com/xtls/xray-core/transport/internet"
+	"golang.org/x/sys/unix"
 )
 
-// RetrieveOriginalDest from stored laddr, caddr
-func RetrieveOriginalDest(oob []byte) net.Destination {
-	dec := gob.NewDecoder(bytes.NewBuffer(oob))
-	var la, ra net.UDPAddr
-	dec.Decode(&la)
-	dec.Decode(&ra)
-	ip, port, err := internet.OriginalDst(&la, &ra)
-	if err != nil {
-		return net.Destination{}
-	}
-	return net.UDPDestination(net.IPAddress(ip), net.Port(port))
-}
-
-// ReadUDPMsg stores laddr, caddr for later use
-func ReadUDPMsg(conn *net.UDPConn, payload []byte, oob []byte) (int, int, int, *net.UDPAddr, error) {
-	nBytes, addr, err := conn.ReadFromUDP(payload)
-	var buf bytes.Buffer
-	enc := gob.NewEncoder(&buf)
-	udpAddr, ok := conn.LocalAddr().(*net.UDPAddr)
-	if !ok {
-		return 0, 0, 0, nil, errors.New("invalid local address")
-	}
-	if addr == nil {
-		return 0, 0, 0, nil, errors.New("invalid remote address")
+func originalDestFromControl(msgs []syscall.SocketControlMessage) net.Destination {
+	for _, msg := range msgs {
+		if msg.Header.Level == syscall.IPPROTO_IP && msg.Header.Type == unix.IP_ORIGDSTADDR && len(msg.Data) >= 8 {
+			ip := net.IPAddress(msg.Data[4:8])
+			port := net.PortFromBytes(msg.Data[2:4])
+			return net.UDPDestination(ip, port)
+		} else if msg.Header.Level == syscall.IPPROTO_IPV6 && msg.Header.Type == unix.IPV6_ORIGDSTADDR && len(msg.Data) >= 24 {
+			ip := net.IPAddress(msg.Data[8:24])
+			port := net.PortFromBytes(msg.Data[2:4])
+			return net.UDPDestination(ip, port)
+		}
 	}
-	enc.Encode(udpAddr)
-	enc.Encode(addr)
-	var reader io.Reader = &buf
-	noob, _ := reader.Read(oob)
-	return nBytes, noob, 0, addr, err
+	return net.Destination{}
 }
@@ -0,0 +1,31 @@
+package udp
+
+import (
+	"syscall"
+
+	"github.com/xtls/xray-core/common/net"
+	"golang.org/x/sys/unix"
+)
+
+// originalDestFromControl combines the address and the port of the original
+// destination, which OpenBSD sends in separate control messages.
+func originalDestFromControl(msgs []syscall.SocketControlMessage) net.Destination {
+	var ip net.Address
+	var port net.Port
+	for _, msg := range msgs {
+		switch {
+		case msg.Header.Level == syscall.IPPROTO_IP && msg.Header.Type == unix.IP_RECVDSTADDR && len(msg.Data) >= 4:
+			ip = net.IPAddress(msg.Data[:4])
+		case msg.Header.Level == syscall.IPPROTO_IP && msg.Header.Type == unix.IP_RECVDSTPORT && len(msg.Data) >= 2:
+			port = net.PortFromBytes(msg.Data[:2])
+		case msg.Header.Level == syscall.IPPROTO_IPV6 && msg.Header.Type == unix.IPV6_PKTINFO && len(msg.Data) >= 16:
+			ip = net.IPAddress(msg.Data[:16])
+		case msg.Header.Level == syscall.IPPROTO_IPV6 && msg.Header.Type == unix.IPV6_RECVDSTPORT && len(msg.Data) >= 2:
+			port = net.PortFromBytes(msg.Data[:2])
+		}
+	}
+	if ip == nil || port == 0 {
+		return net.Destination{}
+	}
+	return net.UDPDestination(ip, port)
+}
@@ -1,5 +1,5 @@
-//go:build !linux && !freebsd && !darwin
-// +build !linux,!freebsd,!darwin
+//go:build !linux && !freebsd && !openbsd && !darwin && !windows
+// +build !linux,!freebsd,!openbsd,!darwin,!windows
 
 package udp
 
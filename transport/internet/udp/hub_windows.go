@@ -0,0 +1,43 @@
+package udp
+
+import (
+	"encoding/binary"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+// RetrieveOriginalDest of packets redirected by WinDivert from stored laddr
+// port, caddr
+func RetrieveOriginalDest(oob []byte) net.Destination {
+	if len(oob) < 4+net.IPv4len {
+		return net.Destination{}
+	}
+	la := &net.UDPAddr{Port: int(binary.BigEndian.Uint16(oob[0:2]))}
+	ra := &net.UDPAddr{Port: int(binary.BigEndian.Uint16(oob[2:4])), IP: net.IP(oob[4:])}
+	ip, port, err := internet.OriginalDst(la, ra)
+	if err != nil {
+		return net.Destination{}
+	}
+	return net.UDPDestination(net.IPAddress(ip), net.Port(port))
+}
+
+// ReadUDPMsg stores laddr port, caddr for later use
+func ReadUDPMsg(conn *net.UDPConn, payload []byte, oob []byte) (int, int, int, *net.UDPAddr, error) {
+	nBytes, addr, err := conn.ReadFromUDP(payload)
+	if err != nil {
+		return nBytes, 0, 0, addr, err
+	}
+	udpAddr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return 0, 0, 0, nil, errors.New("invalid local address")
+	}
+	if len(oob) < 4+len(addr.IP) {
+		return nBytes, 0, 0, addr, nil
+	}
+	binary.BigEndian.PutUint16(oob[0:2], uint16(udpAddr.Port))
+	binary.BigEndian.PutUint16(oob[2:4], uint16(addr.Port))
+	noob := 4 + copy(oob[4:], addr.IP)
+	return nBytes, noob, 0, addr, nil
+}
@@ -0,0 +1,15 @@
+package internet
+
+import (
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+)
+
+// UDPMessage is a datagram UDPBatchConn reads or writes.
+type UDPMessage struct {
+	Buffer *buf.Buffer
+	Addr   *net.UDPAddr
+	// OOB are the control messages the datagram was read with. They are only
+	// valid until the next read.
+	OOB []byte
+}
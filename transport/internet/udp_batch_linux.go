@@ -0,0 +1,345 @@
+//go:build linux
+// +build linux
+
+package internet
+
+import (
+	"encoding/binary"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// udpBatchSize is how many datagrams a read takes at most.
+	udpBatchSize = 16
+	// udpGROBatchSize is how many datagrams a read takes at most with GRO,
+	// where each of them is read into udpGROBufferSize bytes.
+	udpGROBatchSize  = 8
+	udpGROBufferSize = 65535
+	// udpOOBSize fits the control messages of a datagram read, such as its
+	// original destination and GRO segment size.
+	udpOOBSize = 256
+	// udpWriteBatchSize is how many datagrams a write takes at most, as the
+	// kernel takes at most UIO_MAXIOV headers and iovecs.
+	udpWriteBatchSize = 1024
+	// udpMaxSegments and udpMaxPayload bound the datagrams a GSO write is
+	// split into.
+	udpMaxSegments = 64
+	udpMaxPayload  = 65507
+)
+
+// mmsghdr is struct mmsghdr of recvmmsg and sendmmsg.
+type mmsghdr struct {
+	Hdr unix.Msghdr
+	Len uint32
+}
+
+// UDPBatchConn reads and writes several datagrams a system call, with
+// recvmmsg and sendmmsg. Where the kernel supports it, consecutive writes of a
+// size to an address are sent as one datagram it splits (GSO), and reads may
+// take datagrams it merged (GRO), which are split again.
+type UDPBatchConn struct {
+	raw  syscall.RawConn
+	ipv6 bool
+
+	// Reads are done by one goroutine at a time.
+	gro      bool
+	hdrs     []mmsghdr
+	iovs     []unix.Iovec
+	names    []unix.RawSockaddrAny
+	oob      []byte
+	payloads [][]byte
+	buffers  []*buf.Buffer
+	msgs     []UDPMessage
+
+	writeAccess sync.Mutex
+	gso         bool
+	whdrs       []mmsghdr
+	wiovs       []unix.Iovec
+	wnames      []unix.RawSockaddrInet6
+	woob        []byte
+	wcounts     []int
+}
+
+// NewUDPBatchConn returns a UDPBatchConn of conn, which has the kernel merge
+// the datagrams it reads if gro is set, or nil if conn can not do batches.
+func NewUDPBatchConn(conn *net.UDPConn, gro bool) *UDPBatchConn {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil
+	}
+	c := &UDPBatchConn{raw: raw}
+	var family int
+	if cerr := raw.Control(func(fd uintptr) {
+		family, err = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_DOMAIN)
+		if _, err := unix.GetsockoptInt(int(fd), unix.SOL_UDP, unix.UDP_SEGMENT); err == nil {
+			c.gso = true
+		}
+		if gro && unix.SetsockoptInt(int(fd), unix.SOL_UDP, unix.UDP_GRO, 1) == nil {
+			c.gro = true
+		}
+	}); cerr != nil || err != nil {
+		return nil
+	}
+	c.ipv6 = family == unix.AF_INET6
+
+	size := udpBatchSize
+	if c.gro {
+		size = udpGROBatchSize
+	}
+	c.hdrs = make([]mmsghdr, size)
+	c.iovs = make([]unix.Iovec, size)
+	c.names = make([]unix.RawSockaddrAny, size)
+	c.oob = make([]byte, size*udpOOBSize)
+	c.payloads = make([][]byte, size)
+	if c.gro {
+		for i := range c.payloads {
+			c.payloads[i] = make([]byte, udpGROBufferSize)
+		}
+	} else {
+		c.buffers = make([]*buf.Buffer, size)
+	}
+	return c
+}
+
+// ReadBatch waits for datagrams and returns the ones there are. Their buffers
+// are the caller's, while the slice and the control messages are reused by the
+// next read.
+func (c *UDPBatchConn) ReadBatch() ([]UDPMessage, error) {
+	for i := range c.hdrs {
+		if !c.gro && c.buffers[i] == nil {
+			c.buffers[i] = buf.New()
+			c.payloads[i] = c.buffers[i].Extend(buf.Size)
+		}
+		c.iovs[i].Base = &c.payloads[i][0]
+		c.iovs[i].SetLen(len(c.payloads[i]))
+		h := &c.hdrs[i].Hdr
+		h.Name = (*byte)(unsafe.Pointer(&c.names[i]))
+		h.Namelen = unix.SizeofSockaddrAny
+		h.Iov = &c.iovs[i]
+		h.SetIovlen(1)
+		h.Control = &c.oob[i*udpOOBSize]
+		h.SetControllen(udpOOBSize)
+		h.Flags = 0
+	}
+	n, err := mmsg(unix.SYS_RECVMMSG, c.hdrs, c.raw.Read)
+	if err != nil {
+		return nil, err
+	}
+
+	c.msgs = c.msgs[:0]
+	for i := range n {
+		addr := udpAddrFromSockaddr(&c.names[i])
+		oob := c.oob[i*udpOOBSize : i*udpOOBSize+int(c.hdrs[i].Hdr.Controllen)]
+		size := int(c.hdrs[i].Len)
+		if !c.gro {
+			b := c.buffers[i]
+			c.buffers[i] = nil
+			b.Resize(0, int32(size))
+			c.msgs = append(c.msgs, UDPMessage{Buffer: b, Addr: addr, OOB: oob})
+			continue
+		}
+		payload := c.payloads[i][:size]
+		segment := groSegmentSize(oob)
+		if segment <= 0 {
+			segment = size
+		}
+		for start := 0; ; start += segment {
+			end := min(start+segment, size)
+			var b *buf.Buffer
+			if end-start > buf.Size {
+				b = buf.NewWithSize(int32(end - start))
+			} else {
+				b = buf.New()
+			}
+			b.Write(payload[start:end])
+			c.msgs = append(c.msgs, UDPMessage{Buffer: b, Addr: addr, OOB: oob})
+			if end >= size {
+				break
+			}
+		}
+	}
+	return c.msgs, nil
+}
+
+// WriteBatch writes the datagrams to their addresses, and returns how many are
+// written.
+func (c *UDPBatchConn) WriteBatch(msgs []UDPMessage) (int, error) {
+	c.writeAccess.Lock()
+	defer c.writeAccess.Unlock()
+
+	gso := c.gso
+	written := 0
+	for written < len(msgs) {
+		hdrs, counts := c.packWrites(msgs[written:], gso)
+		n, err := mmsg(unix.SYS_SENDMMSG, hdrs, c.raw.Write)
+		for _, count := range counts[:n] {
+			written += count
+		}
+		if err != nil {
+			// EIO is the device not supporting GSO, and the others are the
+			// segments being too large for the path.
+			if gso && counts[0] > 1 && (err == unix.EIO || err == unix.EINVAL || err == unix.EMSGSIZE) {
+				if err == unix.EIO {
+					c.gso = false
+				}
+				gso = false
+				continue
+			}
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// packWrites fills the write headers for msgs, and returns them with how many
+// datagrams each of them carries.
+func (c *UDPBatchConn) packWrites(msgs []UDPMessage, gso bool) ([]mmsghdr, []int) {
+	if len(msgs) > udpWriteBatchSize {
+		msgs = msgs[:udpWriteBatchSize]
+	}
+	if len(c.whdrs) < len(msgs) {
+		c.whdrs = make([]mmsghdr, len(msgs))
+		c.wiovs = make([]unix.Iovec, len(msgs))
+		c.wnames = make([]unix.RawSockaddrInet6, len(msgs))
+		c.woob = make([]byte, len(msgs)*unix.CmsgSpace(2))
+		c.wcounts = make([]int, len(msgs))
+	}
+
+	hdrs := c.whdrs[:0]
+	counts := c.wcounts[:0]
+	iov := 0
+	for i := 0; i < len(msgs); {
+		size := int(msgs[i].Buffer.Len())
+		addr := msgs[i].Addr
+		j := i + 1
+		if gso && size > 0 {
+			total := size
+			for j < len(msgs) && j-i < udpMaxSegments {
+				next := int(msgs[j].Buffer.Len())
+				if next == 0 || next > size || total+next > udpMaxPayload || !sameUDPAddr(msgs[j].Addr, addr) {
+					break
+				}
+				total += next
+				j++
+				// Only the last segment may be shorter.
+				if next < size {
+					break
+				}
+			}
+		}
+
+		k := len(hdrs)
+		hdrs = append(hdrs, mmsghdr{})
+		h := &hdrs[k].Hdr
+		h.Name = (*byte)(unsafe.Pointer(&c.wnames[k]))
+		h.Namelen = c.putSockaddr(&c.wnames[k], addr)
+		first := iov
+		for _, msg := range msgs[i:j] {
+			c.wiovs[iov] = unix.Iovec{}
+			if b := msg.Buffer.Bytes(); len(b) > 0 {
+				c.wiovs[iov].Base = &b[0]
+				c.wiovs[iov].SetLen(len(b))
+			}
+			iov++
+		}
+		h.Iov = &c.wiovs[first]
+		h.SetIovlen(iov - first)
+		if j-i > 1 {
+			oob := c.woob[k*unix.CmsgSpace(2) : (k+1)*unix.CmsgSpace(2)]
+			cmsg := (*unix.Cmsghdr)(unsafe.Pointer(&oob[0]))
+			cmsg.Level = unix.SOL_UDP
+			cmsg.Type = unix.UDP_SEGMENT
+			cmsg.SetLen(unix.CmsgLen(2))
+			binary.NativeEndian.PutUint16(oob[unix.CmsgLen(0):], uint16(size))
+			h.Control = &oob[0]
+			h.SetControllen(len(oob))
+		}
+		counts = append(counts, j-i)
+		i = j
+	}
+	return hdrs, counts
+}
+
+// putSockaddr writes addr to sa as the socket family needs it, and returns its
+// length.
+func (c *UDPBatchConn) putSockaddr(sa *unix.RawSockaddrInet6, addr *net.UDPAddr) uint32 {
+	if ip := addr.IP.To4(); ip != nil && !c.ipv6 {
+		sa4 := (*unix.RawSockaddrInet4)(unsafe.Pointer(sa))
+		*sa4 = unix.RawSockaddrInet4{Family: unix.AF_INET}
+		binary.BigEndian.PutUint16((*[2]byte)(unsafe.Pointer(&sa4.Port))[:], uint16(addr.Port))
+		copy(sa4.Addr[:], ip)
+		return unix.SizeofSockaddrInet4
+	}
+	*sa = unix.RawSockaddrInet6{Family: unix.AF_INET6}
+	binary.BigEndian.PutUint16((*[2]byte)(unsafe.Pointer(&sa.Port))[:], uint16(addr.Port))
+	copy(sa.Addr[:], addr.IP.To16())
+	return unix.SizeofSockaddrInet6
+}
+
+// mmsg runs recvmmsg or sendmmsg on hdrs, with do being the Read or Write of
+// the socket, and returns how many headers it went through.
+func mmsg(trap uintptr, hdrs []mmsghdr, do func(func(uintptr) bool) error) (int, error) {
+	var n int
+	var errno syscall.Errno
+	err := do(func(fd uintptr) bool {
+		for {
+			r, _, e := unix.Syscall6(trap, fd, uintptr(unsafe.Pointer(&hdrs[0])), uintptr(len(hdrs)), 0, 0, 0)
+			switch e {
+			case unix.EINTR:
+				continue
+			case unix.EAGAIN:
+				return false
+			}
+			n, errno = int(r), e
+			return true
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	if errno != 0 {
+		return 0, errno
+	}
+	return n, nil
+}
+
+func groSegmentSize(oob []byte) int {
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0
+	}
+	for _, msg := range msgs {
+		if msg.Header.Level == unix.SOL_UDP && msg.Header.Type == unix.UDP_GRO && len(msg.Data) >= 4 {
+			return int(binary.NativeEndian.Uint32(msg.Data))
+		}
+	}
+	return 0
+}
+
+func udpAddrFromSockaddr(sa *unix.RawSockaddrAny) *net.UDPAddr {
+	switch sa.Addr.Family {
+	case unix.AF_INET:
+		sa4 := (*unix.RawSockaddrInet4)(unsafe.Pointer(sa))
+		return &net.UDPAddr{
+			IP:   net.IP(append([]byte(nil), sa4.Addr[:]...)),
+			Port: int(binary.BigEndian.Uint16((*[2]byte)(unsafe.Pointer(&sa4.Port))[:])),
+		}
+	case unix.AF_INET6:
+		sa6 := (*unix.RawSockaddrInet6)(unsafe.Pointer(sa))
+		return &net.UDPAddr{
+			IP:   net.IP(append([]byte(nil), sa6.Addr[:]...)),
+			Port: int(binary.BigEndian.Uint16((*[2]byte)(unsafe.Pointer(&sa6.Port))[:])),
+		}
+	}
+	return &net.UDPAddr{}
+}
+
+func sameUDPAddr(a, b *net.UDPAddr) bool {
+	return a.Port == b.Port && a.IP.Equal(b.IP)
+}
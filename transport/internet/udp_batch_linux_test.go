@@ -0,0 +1,130 @@
+//go:build linux
+// +build linux
+
+package internet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+)
+
+const benchmarkDatagramSize = 1200
+
+// benchmarkUDPBatch sends b.N datagrams over loopback with send, while they
+// are read with receive, which returns how many datagrams a read took.
+func benchmarkUDPBatch(b *testing.B, gro bool, send func(*net.UDPConn, *net.UDPAddr, int), receive func(*net.UDPConn, *UDPBatchConn) (int, error)) {
+	receiver, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer receiver.Close()
+	sender, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer sender.Close()
+	receiver.SetReadBuffer(8 << 20)
+	sender.SetWriteBuffer(8 << 20)
+	batch := NewUDPBatchConn(receiver, gro)
+	if batch == nil {
+		b.Skip("recvmmsg is not available")
+	}
+
+	sent := make(chan struct{})
+	received := make(chan int)
+	go func() {
+		total := 0
+		idle := time.Second
+		for {
+			select {
+			case <-sent:
+				// The datagrams in flight are read, then the lost ones are
+				// not waited for.
+				idle = 5 * time.Millisecond
+			default:
+			}
+			receiver.SetReadDeadline(time.Now().Add(idle))
+			n, err := receive(receiver, batch)
+			if err != nil {
+				break
+			}
+			total += n
+		}
+		received <- total
+	}()
+
+	b.SetBytes(benchmarkDatagramSize)
+	b.ResetTimer()
+	send(sender, receiver.LocalAddr().(*net.UDPAddr), b.N)
+	close(sent)
+	total := <-received
+	b.StopTimer()
+	b.ReportMetric(float64(total)/float64(b.N), "received/op")
+}
+
+func sendPerDatagram(conn *net.UDPConn, addr *net.UDPAddr, count int) {
+	payload := make([]byte, benchmarkDatagramSize)
+	for i := 0; i < count; i++ {
+		conn.WriteToUDP(payload, addr)
+	}
+}
+
+func readPerDatagram(conn *net.UDPConn, _ *UDPBatchConn) (int, error) {
+	var payload [buf.Size]byte
+	if _, _, err := conn.ReadFromUDP(payload[:]); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// sendBatches sends the datagrams in batches with WriteBatch, as one datagram
+// the kernel splits for each batch if gso is set.
+func sendBatches(gso bool) func(*net.UDPConn, *net.UDPAddr, int) {
+	return func(conn *net.UDPConn, addr *net.UDPAddr, count int) {
+		batch := NewUDPBatchConn(conn, false)
+		batch.gso = batch.gso && gso
+		msgs := make([]UDPMessage, 0, udpMaxSegments)
+		for count > 0 {
+			msgs = msgs[:0]
+			for len(msgs) < cap(msgs) && len(msgs) < count {
+				b := buf.New()
+				b.Extend(benchmarkDatagramSize)
+				msgs = append(msgs, UDPMessage{Buffer: b, Addr: addr})
+			}
+			n, _ := batch.WriteBatch(msgs)
+			for _, msg := range msgs {
+				msg.Buffer.Release()
+			}
+			if n == 0 {
+				n = len(msgs)
+			}
+			count -= n
+		}
+	}
+}
+
+func readBatch(_ *net.UDPConn, conn *UDPBatchConn) (int, error) {
+	msgs, err := conn.ReadBatch()
+	if err != nil {
+		return 0, err
+	}
+	for _, msg := range msgs {
+		msg.Buffer.Release()
+	}
+	return len(msgs), nil
+}
+
+func BenchmarkUDPBatch(b *testing.B) {
+	b.Run("PerDatagram", func(b *testing.B) {
+		benchmarkUDPBatch(b, false, sendPerDatagram, readPerDatagram)
+	})
+	b.Run("MMsg", func(b *testing.B) {
+		benchmarkUDPBatch(b, false, sendBatches(false), readBatch)
+	})
+	b.Run("GSO", func(b *testing.B) {
+		benchmarkUDPBatch(b, true, sendBatches(true), readBatch)
+	})
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+// +build !linux
+
+package internet
+
+import (
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+)
+
+// UDPBatchConn reads and writes several datagrams a system call. It is only
+// available on Linux.
+type UDPBatchConn struct{}
+
+// NewUDPBatchConn returns nil, as batches need recvmmsg and sendmmsg.
+func NewUDPBatchConn(conn *net.UDPConn, gro bool) *UDPBatchConn {
+	return nil
+}
+
+func (c *UDPBatchConn) ReadBatch() ([]UDPMessage, error) {
+	return nil, errors.New("UDP batches are not supported")
+}
+
+func (c *UDPBatchConn) WriteBatch(msgs []UDPMessage) (int, error) {
+	return 0, errors.New("UDP batches are not supported")
+}
@@ -0,0 +1,337 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	gotls "crypto/tls"
+	goerrors "errors"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+	_ "unsafe"
+
+	"github.com/gorilla/websocket"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/signal/done"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/tls"
+	"golang.org/x/net/http2"
+)
+
+// WebSocket over HTTP/2 (RFC 8441) and HTTP/3 (RFC 9220) opens a stream with
+// an extended CONNECT request, whose :protocol is "websocket", and exchanges
+// the frames in its bodies without any key or accept header.
+
+//go:linkname h2DisableExtendedConnect golang.org/x/net/http2.disableExtendedConnectProtocol
+var h2DisableExtendedConnect bool
+
+//go:linkname newWebSocketConn github.com/gorilla/websocket.newConn
+func newWebSocketConn(conn net.Conn, isServer bool, readBufferSize, writeBufferSize int, writeBufferPool websocket.BufferPool, br *bufio.Reader, writeBuf []byte) *websocket.Conn
+
+func init() {
+	// golang.org/x/net/http2 only advertises extended CONNECT with
+	// GODEBUG=http2xconnect=1.
+	h2DisableExtendedConnect = false
+}
+
+func isExtendedConnect(request *http.Request) bool {
+	// quic-go puts :protocol in Proto, golang.org/x/net/http2 in the header.
+	return request.Method == http.MethodConnect && (request.Proto == "websocket" || request.Header.Get(":protocol") == "websocket")
+}
+
+// acceptConnect answers an extended CONNECT request, and returns the WebSocket
+// connection in its stream, which is done once the connection is closed.
+func acceptConnect(writer http.ResponseWriter, request *http.Request, responseHeader http.Header, localAddr, remoteAddr net.Addr) (*websocket.Conn, *done.Instance, error) {
+	if request.Header.Get("Sec-WebSocket-Version") != "13" {
+		writer.Header().Set("Sec-WebSocket-Version", "13")
+		writer.WriteHeader(http.StatusBadRequest)
+		return nil, nil, errors.New("unsupported WebSocket version: ", request.Header.Get("Sec-WebSocket-Version"))
+	}
+	for k, v := range responseHeader {
+		writer.Header()[k] = v
+	}
+	writer.WriteHeader(http.StatusOK)
+	writer.(http.Flusher).Flush()
+
+	stream := &serverStream{
+		Instance:   done.New(),
+		reader:     request.Body,
+		writer:     writer,
+		controller: http.NewResponseController(writer),
+		localAddr:  localAddr,
+		remoteAddr: remoteAddr,
+	}
+	return newWebSocketConn(stream, true, 0, 0, nil, nil, nil), stream.Instance, nil
+}
+
+// serverStream is the net.Conn of an extended CONNECT stream on the server.
+type serverStream struct {
+	sync.Mutex
+	*done.Instance
+	reader     io.Reader // no need to Close request.Body
+	writer     http.ResponseWriter
+	controller *http.ResponseController
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+func (s *serverStream) Read(b []byte) (int, error) {
+	return s.reader.Read(b)
+}
+
+func (s *serverStream) Write(b []byte) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.Done() {
+		return 0, io.ErrClosedPipe
+	}
+	n, err := s.writer.Write(b)
+	if err == nil {
+		s.writer.(http.Flusher).Flush()
+	}
+	return n, err
+}
+
+func (s *serverStream) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	return s.Instance.Close()
+}
+
+func (s *serverStream) LocalAddr() net.Addr {
+	return s.localAddr
+}
+
+func (s *serverStream) RemoteAddr() net.Addr {
+	return s.remoteAddr
+}
+
+func (s *serverStream) SetDeadline(t time.Time) error {
+	if err := s.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return s.SetWriteDeadline(t)
+}
+
+func (s *serverStream) SetReadDeadline(t time.Time) error {
+	if err := s.controller.SetReadDeadline(t); err != nil && !goerrors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+func (s *serverStream) SetWriteDeadline(t time.Time) error {
+	if err := s.controller.SetWriteDeadline(t); err != nil && !goerrors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// clientStream is the net.Conn of an extended CONNECT stream on the client.
+type clientStream struct {
+	reader     io.ReadCloser
+	writer     *io.PipeWriter
+	cancel     context.CancelFunc
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+func (s *clientStream) Read(b []byte) (int, error) {
+	return s.reader.Read(b)
+}
+
+func (s *clientStream) Write(b []byte) (int, error) {
+	return s.writer.Write(b)
+}
+
+func (s *clientStream) Close() error {
+	s.writer.Close()
+	err := s.reader.Close()
+	s.cancel()
+	return err
+}
+
+func (s *clientStream) LocalAddr() net.Addr {
+	return s.localAddr
+}
+
+func (s *clientStream) RemoteAddr() net.Addr {
+	return s.remoteAddr
+}
+
+func (s *clientStream) SetDeadline(t time.Time) error {
+	// TODO cannot do anything useful
+	return nil
+}
+
+func (s *clientStream) SetReadDeadline(t time.Time) error {
+	// TODO cannot do anything useful
+	return nil
+}
+
+func (s *clientStream) SetWriteDeadline(t time.Time) error {
+	// TODO cannot do anything useful
+	return nil
+}
+
+type transportKey struct {
+	net.Destination
+	*internet.MemoryStreamConfig
+}
+
+// globalTransports keeps a transport for every outbound, so that its streams
+// share the HTTP/2 or HTTP/3 connection.
+var (
+	globalTransports      map[transportKey]http.RoundTripper
+	globalTransportAccess sync.Mutex
+)
+
+func getTransport(dest net.Destination, streamSettings *internet.MemoryStreamConfig) (http.RoundTripper, error) {
+	globalTransportAccess.Lock()
+	defer globalTransportAccess.Unlock()
+
+	if globalTransports == nil {
+		globalTransports = make(map[transportKey]http.RoundTripper)
+	}
+	key := transportKey{dest, streamSettings}
+	if transport, found := globalTransports[key]; found {
+		return transport, nil
+	}
+
+	tConfig := tls.ConfigFromStreamSettings(streamSettings)
+	var transport http.RoundTripper
+	if streamSettings.ProtocolSettings.(*Config).Mode == "h3" {
+		if tConfig == nil {
+			return nil, errors.New("WebSocket over HTTP/3 requires TLS")
+		}
+		dest.Network = net.Network_UDP
+		transport = &http3.Transport{
+			QUICConfig: &quic.Config{
+				MaxIdleTimeout:  net.ConnIdleTimeout,
+				KeepAlivePeriod: net.QuicgoH3KeepAlivePeriod,
+			},
+			TLSClientConfig: tConfig.GetTLSConfig(tls.WithDestination(dest), tls.WithNextProto("h3")),
+			Dial: func(ctx context.Context, addr string, tlsCfg *gotls.Config, cfg *quic.Config) (*quic.Conn, error) {
+				conn, err := internet.DialSystem(ctx, dest, streamSettings.SocketSettings)
+				if err != nil {
+					return nil, err
+				}
+				udpConn, udpAddr, err := internet.ToPacketConn(conn)
+				if err != nil {
+					return nil, err
+				}
+				return quic.DialEarly(ctx, udpConn, udpAddr, tlsCfg, cfg)
+			},
+		}
+	} else {
+		transport = &http2.Transport{
+			AllowHTTP: tConfig == nil,
+			DialTLSContext: func(ctx context.Context, network, addr string, cfg *gotls.Config) (net.Conn, error) {
+				return dialH2(ctx, dest, streamSettings)
+			},
+			IdleConnTimeout: net.ConnIdleTimeout,
+			ReadIdleTimeout: net.ChromeH2KeepAlivePeriod,
+		}
+	}
+	globalTransports[key] = transport
+	return transport, nil
+}
+
+// dialH2 dials the connection of the HTTP/2 transport, which is cleartext
+// without TLS.
+func dialH2(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (net.Conn, error) {
+	conn, err := internet.DialSystem(ctx, dest, streamSettings.SocketSettings)
+	if err != nil {
+		return nil, err
+	}
+	tConfig := tls.ConfigFromStreamSettings(streamSettings)
+	if tConfig == nil {
+		return conn, nil
+	}
+	tlsConfig := tConfig.GetTLSConfig(tls.WithDestination(dest), tls.WithNextProto("h2"))
+	var tlsConn tls.Interface
+	if fingerprint := tls.GetFingerprint(tConfig.Fingerprint); fingerprint != nil {
+		tlsConn = tls.UClient(conn, tlsConfig, fingerprint).(*tls.UConn)
+	} else {
+		tlsConn = tls.Client(conn, tlsConfig).(*tls.Conn)
+	}
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, ok := tlsConn.(*tls.UConn); ok && !tlsConfig.InsecureSkipVerify {
+		if err := tlsConn.VerifyHostname(tlsConfig.ServerName); err != nil {
+			tlsConn.Close()
+			return nil, err
+		}
+	}
+	if protocol := tlsConn.NegotiatedProtocol(); protocol != "h2" {
+		tlsConn.Close()
+		return nil, errors.New("server did not negotiate h2 but ", protocol)
+	}
+	return tlsConn, nil
+}
+
+// dialConnect opens the WebSocket connection in an extended CONNECT stream of
+// the HTTP/2 or HTTP/3 connection to dest.
+func dialConnect(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig, header http.Header) (*websocket.Conn, error) {
+	transport, err := getTransport(dest, streamSettings)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "http"
+	if tls.ConfigFromStreamSettings(streamSettings) != nil {
+		scheme = "https"
+	}
+	uri := scheme + "://" + header.Get("Host") + streamSettings.ProtocolSettings.(*Config).GetNormalizedPath()
+
+	var localAddr, remoteAddr net.Addr
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(connInfo httptrace.GotConnInfo) {
+			localAddr = connInfo.Conn.LocalAddr()
+			remoteAddr = connInfo.Conn.RemoteAddr()
+		},
+	})
+	reader, writer := io.Pipe()
+	request, err := http.NewRequestWithContext(ctx, http.MethodConnect, uri, reader)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	request.Host = header.Get("Host")
+	header.Del("Host")
+	header.Set("Sec-WebSocket-Version", "13")
+	request.Header = header
+	if streamSettings.ProtocolSettings.(*Config).Mode == "h3" {
+		request.Proto = "websocket"
+	} else {
+		request.Header.Set(":protocol", "websocket")
+	}
+
+	timer := time.AfterFunc(time.Second*8, cancel)
+	response, err := transport.RoundTrip(request)
+	timer.Stop()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		cancel()
+		return nil, errors.New("unexpected status ", response.Status)
+	}
+
+	return newWebSocketConn(&clientStream{
+		reader:     response.Body,
+		writer:     writer,
+		cancel:     cancel,
+		localAddr:  localAddr,
+		remoteAddr: remoteAddr,
+	}, false, 0, 0, nil, nil, nil), nil
+}
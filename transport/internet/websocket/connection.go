@@ -108,6 +108,11 @@ func (c *connection) RemoteAddr() net.Addr {
 	return c.remoteAddr
 }
 
+// PeerAddr implements stat.ForwardedConnection.
+func (c *connection) PeerAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
 func (c *connection) SetDeadline(t time.Time) error {
 	if err := c.SetReadDeadline(t); err != nil {
 		return err
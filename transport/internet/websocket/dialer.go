@@ -117,6 +117,14 @@ func dialWebSocket(ctx context.Context, dest net.Destination, streamSettings *in
 		header.Set("Sec-WebSocket-Protocol", base64.RawURLEncoding.EncodeToString(ed))
 	}
 
+	if wsSettings.Mode == "h2" || wsSettings.Mode == "h3" {
+		conn, err := dialConnect(ctx, dest, streamSettings, header)
+		if err != nil {
+			return nil, errors.New("failed to dial to (", uri, ") over ", wsSettings.Mode).Base(err)
+		}
+		return NewConnection(conn, conn.RemoteAddr(), nil, wsSettings.HeartbeatPeriod), nil
+	}
+
 	conn, resp, err := dialer.DialContext(ctx, uri, header)
 	if err != nil {
 		var reason string
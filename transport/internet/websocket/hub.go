@@ -12,12 +12,18 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	http_proto "github.com/xtls/xray-core/common/protocol/http"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/signal/done"
 	"github.com/xtls/xray-core/transport/internet"
 	v2tls "github.com/xtls/xray-core/transport/internet/tls"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 type requestHandler struct {
@@ -49,6 +55,13 @@ func (h *requestHandler) ServeHTTP(writer http.ResponseWriter, request *http.Req
 		return
 	}
 
+	forwardedAddrs := http_proto.ParseXForwardedFor(request.Header)
+	if len(forwardedAddrs) > 0 && forwardedAddrs[0].Family().IsIP() && h.ln.gate != nil && !h.ln.gate.AllowForwarded(forwardedAddrs[0].IP()) {
+		errors.LogInfo(context.Background(), "forwarded source not allowed: ", forwardedAddrs[0])
+		writer.WriteHeader(http.StatusForbidden)
+		return
+	}
+
 	var extraReader io.Reader
 	responseHeader := http.Header{}
 	if str := request.Header.Get("Sec-WebSocket-Protocol"); str != "" {
@@ -58,13 +71,19 @@ func (h *requestHandler) ServeHTTP(writer http.ResponseWriter, request *http.Req
 		}
 	}
 
-	conn, err := upgrader.Upgrade(writer, request, responseHeader)
+	var conn *websocket.Conn
+	var closed *done.Instance
+	var err error
+	if isExtendedConnect(request) {
+		conn, closed, err = acceptConnect(writer, request, responseHeader, h.ln.Addr(), requestRemoteAddr(request))
+	} else {
+		conn, err = upgrader.Upgrade(writer, request, responseHeader)
+	}
 	if err != nil {
 		errors.LogInfoInner(context.Background(), err, "failed to convert to WebSocket connection")
 		return
 	}
 
-	forwardedAddrs := http_proto.ParseXForwardedFor(request.Header)
 	remoteAddr := conn.RemoteAddr()
 	if len(forwardedAddrs) > 0 && forwardedAddrs[0].Family().IsIP() {
 		remoteAddr = &net.TCPAddr{
@@ -74,19 +93,49 @@ func (h *requestHandler) ServeHTTP(writer http.ResponseWriter, request *http.Req
 	}
 
 	h.ln.addConn(NewConnection(conn, remoteAddr, extraReader, h.ln.config.HeartbeatPeriod))
+
+	if closed != nil {
+		// "A ResponseWriter may not be used after [Handler.ServeHTTP] has returned."
+		select {
+		case <-request.Context().Done():
+		case <-closed.Wait():
+		}
+		conn.Close()
+	}
+}
+
+func requestRemoteAddr(request *http.Request) net.Addr {
+	addr, err := net.ResolveTCPAddr("tcp", request.RemoteAddr)
+	if err != nil {
+		return &net.TCPAddr{
+			IP:   []byte{0, 0, 0, 0},
+			Port: 0,
+		}
+	}
+	if request.ProtoMajor == 3 {
+		return &net.UDPAddr{
+			IP:   addr.IP,
+			Port: addr.Port,
+		}
+	}
+	return addr
 }
 
 type Listener struct {
 	sync.Mutex
-	server   http.Server
-	listener net.Listener
-	config   *Config
-	addConn  internet.ConnHandler
+	server     http.Server
+	h3server   *http3.Server
+	listener   net.Listener
+	h3listener *quic.EarlyListener
+	config     *Config
+	addConn    internet.ConnHandler
+	gate       session.ListenGate
 }
 
 func ListenWS(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, addConn internet.ConnHandler) (internet.Listener, error) {
 	l := &Listener{
 		addConn: addConn,
+		gate:    session.ListenGateFromContext(ctx),
 	}
 	wsSettings := streamSettings.ProtocolSettings.(*Config)
 	l.config = wsSettings
@@ -96,6 +145,42 @@ func ListenWS(ctx context.Context, address net.Address, port net.Port, streamSet
 		}
 		streamSettings.SocketSettings.AcceptProxyProtocol = l.config.AcceptProxyProtocol || streamSettings.SocketSettings.AcceptProxyProtocol
 	}
+	handler := &requestHandler{
+		host: wsSettings.Host,
+		path: wsSettings.GetNormalizedPath(),
+		ln:   l,
+	}
+
+	if wsSettings.Mode == "h3" {
+		config := v2tls.ConfigFromStreamSettings(streamSettings)
+		if config == nil {
+			return nil, errors.New("WebSocket over HTTP/3 requires TLS")
+		}
+		conn, err := internet.ListenSystemPacket(context.Background(), &net.UDPAddr{
+			IP:   address.IP(),
+			Port: int(port),
+		}, streamSettings.SocketSettings)
+		if err != nil {
+			return nil, errors.New("failed to listen UDP(for WS/3) on ", address, ":", port).Base(err)
+		}
+		l.h3listener, err = quic.ListenEarly(conn, config.GetTLSConfig(v2tls.WithNextProto("h3")), nil)
+		if err != nil {
+			conn.Close()
+			return nil, errors.New("failed to listen QUIC(for WS/3) on ", address, ":", port).Base(err)
+		}
+		errors.LogInfo(ctx, "listening QUIC(for WS/3) on ", address, ":", port)
+
+		l.h3server = &http3.Server{
+			Handler: handler,
+		}
+		go func() {
+			if err := l.h3server.ServeListener(l.h3listener); err != nil {
+				errors.LogWarningInner(ctx, err, "failed to serve HTTP/3 for WebSocket")
+			}
+		}()
+		return l, nil
+	}
+
 	var listener net.Listener
 	var err error
 	if port == net.Port(0) { // unix
@@ -131,14 +216,18 @@ func ListenWS(ctx context.Context, address net.Address, port net.Port, streamSet
 	l.listener = listener
 
 	l.server = http.Server{
-		Handler: &requestHandler{
-			host: wsSettings.Host,
-			path: wsSettings.GetNormalizedPath(),
-			ln:   l,
-		},
+		Handler:           handler,
 		ReadHeaderTimeout: time.Second * 4,
 		MaxHeaderBytes:    8192,
 	}
+	if wsSettings.Mode == "h2" {
+		// Besides HTTP/1.1 upgrades, both h2 and h2c.
+		h2server := &http2.Server{}
+		if err := http2.ConfigureServer(&l.server, h2server); err != nil {
+			return nil, errors.New("failed to configure HTTP/2 for WebSocket").Base(err)
+		}
+		l.server.Handler = h2c.NewHandler(handler, h2server)
+	}
 
 	go func() {
 		if err := l.server.Serve(l.listener); err != nil {
@@ -151,11 +240,17 @@ func ListenWS(ctx context.Context, address net.Address, port net.Port, streamSet
 
 // Addr implements net.Listener.Addr().
 func (ln *Listener) Addr() net.Addr {
+	if ln.h3listener != nil {
+		return ln.h3listener.Addr()
+	}
 	return ln.listener.Addr()
 }
 
 // Close implements net.Listener.Close().
 func (ln *Listener) Close() error {
+	if ln.h3server != nil {
+		return ln.h3server.Close()
+	}
 	return ln.listener.Close()
 }
 
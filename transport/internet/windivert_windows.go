@@ -0,0 +1,100 @@
+package internet
+
+import (
+	"unsafe"
+
+	"github.com/xtls/xray-core/common/errors"
+	"golang.org/x/sys/windows"
+)
+
+// WinDivert.dll and its driver are looked up next to the executable.
+var (
+	modWinDivert = windows.NewLazyDLL("WinDivert.dll")
+
+	procWinDivertOpen                = modWinDivert.NewProc("WinDivertOpen")
+	procWinDivertRecv                = modWinDivert.NewProc("WinDivertRecv")
+	procWinDivertSend                = modWinDivert.NewProc("WinDivertSend")
+	procWinDivertShutdown            = modWinDivert.NewProc("WinDivertShutdown")
+	procWinDivertClose               = modWinDivert.NewProc("WinDivertClose")
+	procWinDivertHelperCalcChecksums = modWinDivert.NewProc("WinDivertHelperCalcChecksums")
+)
+
+const (
+	winDivertLayerNetwork = 0
+	winDivertShutdownBoth = 0x3
+
+	winDivertFlagOutbound = 1 << 1
+)
+
+// winDivertAddress is WINDIVERT_ADDRESS of the network layer.
+type winDivertAddress struct {
+	Timestamp int64
+	Layer     uint8
+	Event     uint8
+	// Flags are the bits from Sniffed to UDPChecksum.
+	Flags     uint8
+	Reserved1 uint8
+	Reserved2 uint32
+	IfIdx     uint32
+	SubIfIdx  uint32
+	Reserved3 [56]byte
+}
+
+type winDivert struct {
+	handle uintptr
+}
+
+// uint64Args passes v as the arguments of a UINT64 parameter.
+func uint64Args(v uint64) []uintptr {
+	if unsafe.Sizeof(uintptr(0)) == 4 {
+		return []uintptr{uintptr(v), uintptr(v >> 32)}
+	}
+	return []uintptr{uintptr(v)}
+}
+
+func openWinDivert(filter string) (*winDivert, error) {
+	if err := modWinDivert.Load(); err != nil {
+		return nil, errors.New("failed to load WinDivert.dll").Base(err)
+	}
+	f, err := windows.BytePtrFromString(filter)
+	if err != nil {
+		return nil, err
+	}
+	args := append([]uintptr{uintptr(unsafe.Pointer(f)), winDivertLayerNetwork, 0}, uint64Args(0)...)
+	handle, _, err := procWinDivertOpen.Call(args...)
+	if handle == uintptr(windows.InvalidHandle) {
+		return nil, errors.New("failed to open WinDivert with filter ", filter).Base(err)
+	}
+	return &winDivert{handle: handle}, nil
+}
+
+func (d *winDivert) recv(packet []byte, addr *winDivertAddress) (int, error) {
+	var n uint32
+	r, _, err := procWinDivertRecv.Call(d.handle, uintptr(unsafe.Pointer(&packet[0])), uintptr(len(packet)), uintptr(unsafe.Pointer(&n)), uintptr(unsafe.Pointer(addr)))
+	if r == 0 {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+func (d *winDivert) send(packet []byte, addr *winDivertAddress) error {
+	var n uint32
+	r, _, err := procWinDivertSend.Call(d.handle, uintptr(unsafe.Pointer(&packet[0])), uintptr(len(packet)), uintptr(unsafe.Pointer(&n)), uintptr(unsafe.Pointer(addr)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+func (d *winDivert) close() error {
+	procWinDivertShutdown.Call(d.handle, winDivertShutdownBoth)
+	if r, _, err := procWinDivertClose.Call(d.handle); r == 0 {
+		return err
+	}
+	return nil
+}
+
+func calcChecksums(packet []byte, addr *winDivertAddress) {
+	args := append([]uintptr{uintptr(unsafe.Pointer(&packet[0])), uintptr(len(packet)), uintptr(unsafe.Pointer(addr))}, uint64Args(0)...)
+	procWinDivertHelperCalcChecksums.Call(args...)
+}
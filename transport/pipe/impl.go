@@ -24,8 +24,15 @@ const (
 type pipeOption struct {
 	limit           int32 // maximum buffer size in bytes
 	discardOverflow bool
+	// limit is tuned between minLimit and maxLimit if maxLimit is positive.
+	minLimit int32
+	maxLimit int32
 }
 
+// tuneInterval is how long reads of a pipe are watched before its size limit
+// shrinks.
+const tuneInterval = time.Second
+
 func (o *pipeOption) isFull(curSize int32) bool {
 	return o.limit >= 0 && curSize > o.limit
 }
@@ -39,6 +46,11 @@ type pipe struct {
 	errChan     chan error
 	option      pipeOption
 	state       state
+	// stalled is set when a writer waits for the pipe to be read, and peak is
+	// the most a read has taken since tuned. They tune the size limit.
+	stalled bool
+	peak    int32
+	tuned   time.Time
 }
 
 var (
@@ -58,6 +70,7 @@ func (p *pipe) getState(forRead bool) error {
 	switch p.state {
 	case open:
 		if !forRead && p.option.isFull(p.data.Len()) {
+			p.stalled = true
 			return errBufferFull
 		}
 		return nil
@@ -86,9 +99,33 @@ func (p *pipe) readMultiBufferInternal() (buf.MultiBuffer, error) {
 
 	data := p.data
 	p.data = nil
+	if data != nil && p.option.maxLimit > 0 {
+		p.tune(data.Len())
+	}
 	return data, nil
 }
 
+// tune adjusts the size limit after a read of n bytes. It doubles when a
+// writer had to wait, as the other end then takes more than the limit in the
+// time it takes to come back for more. It halves for each interval in which no
+// read took a quarter of it, so that slow and idle connections hold less.
+func (p *pipe) tune(n int32) {
+	o := &p.option
+	now := time.Now()
+	switch elapsed := now.Sub(p.tuned); {
+	case p.stalled:
+		p.stalled = false
+		o.limit = min(max(o.limit*2, buf.Size), o.maxLimit)
+	case elapsed < tuneInterval:
+		p.peak = max(p.peak, n)
+		return
+	case max(p.peak, n) < o.limit/4:
+		o.limit = max(o.limit>>min(elapsed/tuneInterval, 31), o.minLimit)
+	}
+	p.peak = 0
+	p.tuned = now
+}
+
 func (p *pipe) ReadMultiBuffer() (buf.MultiBuffer, error) {
 	for {
 		data, err := p.readMultiBufferInternal()
@@ -25,6 +25,16 @@ func WithSizeLimit(limit int32) Option {
 	}
 }
 
+// WithAutoTunedSizeLimit returns an Option for Pipe to have a size limit
+// between min and max, which follows how much the pipe is asked to hold.
+func WithAutoTunedSizeLimit(min, max int32) Option {
+	return func(opt *pipeOption) {
+		opt.limit = min
+		opt.minLimit = min
+		opt.maxLimit = max
+	}
+}
+
 // DiscardOverflow returns an Option for Pipe to discard writes if full.
 func DiscardOverflow() Option {
 	return func(opt *pipeOption) {
@@ -37,7 +47,9 @@ func OptionsFromContext(ctx context.Context) []Option {
 	var opt []Option
 
 	bp := policy.BufferPolicyFromContext(ctx)
-	if bp.PerConnection >= 0 {
+	if bp.MaxPerConnection > 0 {
+		opt = append(opt, WithAutoTunedSizeLimit(bp.MinPerConnection, bp.MaxPerConnection))
+	} else if bp.PerConnection >= 0 {
 		opt = append(opt, WithSizeLimit(bp.PerConnection))
 	} else {
 		opt = append(opt, WithoutSizeLimit())